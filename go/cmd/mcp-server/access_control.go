@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// ── Access control ─────────────────────────────────────────────────────────
+//
+// The MCP transports and the admin API answer anyone who finds the URL
+// unless the network in front of the server says otherwise. These optional
+// lists narrow that down inside the server:
+//
+//   MCP_ALLOWED_IPS        IPs/CIDRs that may use /mcp-http and /mcp/
+//   MCP_ADMIN_ALLOWED_IPS  IPs/CIDRs that may use /api/admin/ (the
+//                          maintainer token is still required)
+//   MCP_ALLOWED_ORIGINS    Origins browsers may call /mcp-http and /mcp/
+//                          from, e.g. https://claude.ai; "*" allows any
+//   MCP_TRUSTED_PROXIES    IPs/CIDRs of reverse proxies whose
+//                          X-Forwarded-For is believed
+//
+// An unset list allows everything, as before. The Origin check only applies
+// to requests that send an Origin header, which browsers always do for
+// cross-site requests and other clients normally don't; it is what stops a
+// web page from driving a local server through DNS rebinding. Requests over
+// the Unix socket have no IP address and are governed by the socket's file
+// permissions instead. Refusals are 403 and counted on /metrics.
+
+type accessPolicy struct {
+	mcpIPs   []netip.Prefix // nil = any
+	adminIPs []netip.Prefix // nil = any
+	proxies  []netip.Prefix
+	origins  map[string]bool // nil = any
+}
+
+// accessRules is set at startup by loadAccessPolicy; nil allows everything.
+var accessRules *accessPolicy
+
+var accessDenied struct {
+	ip, origin atomic.Int64
+}
+
+// loadAccessPolicy reads the access lists. It returns nil when none is set.
+func loadAccessPolicy() (*accessPolicy, error) {
+	p := &accessPolicy{}
+	var err error
+	if p.mcpIPs, err = parsePrefixes("MCP_ALLOWED_IPS"); err != nil {
+		return nil, err
+	}
+	if p.adminIPs, err = parsePrefixes("MCP_ADMIN_ALLOWED_IPS"); err != nil {
+		return nil, err
+	}
+	if p.proxies, err = parsePrefixes("MCP_TRUSTED_PROXIES"); err != nil {
+		return nil, err
+	}
+	if origins := splitEnvList("MCP_ALLOWED_ORIGINS"); len(origins) > 0 && !slices.Contains(origins, "*") {
+		p.origins = map[string]bool{}
+		for _, o := range origins {
+			p.origins[normalizeOrigin(o)] = true
+		}
+	}
+	if p.mcpIPs == nil && p.adminIPs == nil && p.origins == nil && p.proxies == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// parsePrefixes reads a comma-separated list of IPs and CIDRs.
+func parsePrefixes(key string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, item := range splitEnvList(key) {
+		if strings.Contains(item, "/") {
+			p, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not an IP address or CIDR", key, item)
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not an IP address or CIDR", key, item)
+		}
+		out = append(out, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+	}
+	return out, nil
+}
+
+// normalizeOrigin lowercases an origin and drops a trailing slash, so
+// "https://Claude.ai/" matches the "https://claude.ai" a browser sends.
+func normalizeOrigin(o string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(o), "/"))
+}
+
+func inPrefixes(prefixes []netip.Prefix, a netip.Addr) bool {
+	a = a.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr is the address a request came from: the peer, or with a
+// trusted proxy as peer, the last X-Forwarded-For hop that is not itself a
+// trusted proxy. ok is false for Unix socket connections.
+func (p *accessPolicy) clientAddr(r *http.Request) (addr netip.Addr, ok bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, err = netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	if !inPrefixes(p.proxies, addr) {
+		return addr, true
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !inPrefixes(p.proxies, addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+// requestIP is the client address of r as text, honouring
+// MCP_TRUSTED_PROXIES, or "unix" for Unix socket connections.
+func requestIP(r *http.Request) string {
+	p := accessRules
+	if p == nil {
+		p = &accessPolicy{}
+	}
+	if addr, ok := p.clientAddr(r); ok {
+		return addr.String()
+	}
+	return "unix"
+}
+
+// allowIP reports whether the request's client is in allowed (nil = any).
+func (p *accessPolicy) allowIP(allowed []netip.Prefix, r *http.Request) bool {
+	if allowed == nil {
+		return true
+	}
+	addr, ok := p.clientAddr(r)
+	return !ok || inPrefixes(allowed, addr)
+}
+
+// allowOrigin reports whether a browser may send the request.
+func (p *accessPolicy) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return p.origins == nil || origin == "" || p.origins[normalizeOrigin(origin)]
+}
+
+// guardMCPAccess applies the IP and Origin lists to an MCP transport.
+func guardMCPAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := accessRules; p != nil {
+			if !p.allowIP(p.mcpIPs, r) {
+				deny(w, r, &accessDenied.ip, "your address is not allowed to use this MCP server")
+				return
+			}
+			if !p.allowOrigin(r) {
+				deny(w, r, &accessDenied.origin, "origin not allowed")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// guardAdminAccess applies the admin IP list to an /api/admin/ route.
+func guardAdminAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p := accessRules; p != nil && !p.allowIP(p.adminIPs, r) {
+			deny(w, r, &accessDenied.ip, "your address is not allowed to use the admin API")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func deny(w http.ResponseWriter, r *http.Request, counter *atomic.Int64, msg string) {
+	if counter.Add(1)%100 == 1 {
+		log.Printf("Access denied: %s %s from %s (origin %q): %s", r.Method, r.URL.Path, r.RemoteAddr, r.Header.Get("Origin"), msg)
+	}
+	writeError(w, http.StatusForbidden, msg)
+}
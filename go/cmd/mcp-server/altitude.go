@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Altitude normalization ─────────────────────────────────────────────────
+//
+// Cosmic radiation rises steeply with altitude (about 2.5 times the sea
+// level rate at 2,500 m), so a clean mountain road reads higher than a
+// valley with the same ground, and readings taken on a plane look like
+// hotspots. normalize_altitude: true on a measurement
+// tool estimates the cosmic excess over sea level at each marker's GPS
+// altitude and reports the dose rate without it as ground_usvh, for
+// comparing ground contamination between places at different heights.
+// value itself is never changed.
+//
+// The model is the UNSCEAR 2000 (Annex B) altitude dependence of the
+// directly ionizing and photon component of cosmic radiation, 32 nSv/h at
+// sea level:
+//
+//	E(z) = E(0) · (0.21·exp(-1.649·z) + 0.79·exp(0.4528·z)), z in km
+//
+// The neutron component is left out: Geiger tubes hardly respond to it.
+// Above cosmicModelMaxM the formula no longer holds and readings there are
+// aircraft data, so they are flagged instead of corrected.
+
+const (
+	cosmicSeaLevelUSvH = 0.032
+	cosmicModelMaxM    = 6000
+)
+
+type altitudeKey struct{}
+
+// withAltitudeParam adds the normalize_altitude flag to a measurement tool.
+func withAltitudeParam() mcp.ToolOption {
+	return mcp.WithBoolean("normalize_altitude",
+		mcp.Description("Add ground_usvh to each µSv/h reading: the dose rate minus the estimated cosmic radiation excess at its GPS altitude, for comparing ground contamination between places at different heights. Readings above 6 km (flights) are flagged, not corrected (default: false)"),
+	)
+}
+
+// altitudeContext records the request's normalize_altitude flag in ctx.
+func altitudeContext(ctx context.Context, req mcp.CallToolRequest) context.Context {
+	if !req.GetBool("normalize_altitude", false) {
+		return ctx
+	}
+	return context.WithValue(ctx, altitudeKey{}, true)
+}
+
+func normalizeAltitude(ctx context.Context) bool {
+	on, _ := ctx.Value(altitudeKey{}).(bool)
+	return on
+}
+
+// cosmicExcess is the estimated cosmic dose rate at heightM above what it
+// is at sea level, in µSv/h. Heights below sea level get no correction.
+func cosmicExcess(heightM float64) float64 {
+	z := max(heightM, 0) / 1000
+	return cosmicSeaLevelUSvH * (0.21*math.Exp(-1.649*z) + 0.79*math.Exp(0.4528*z) - 1)
+}
+
+// addAltitudeNormalization annotates the measurements of result with their
+// cosmic excess and ground_usvh, and summarizes what was done. Results
+// grouped per box (multi_bbox.go) have no measurements of their own; each
+// group is annotated instead.
+func addAltitudeNormalization(result map[string]any) {
+	if _, ok := result["measurements"]; !ok {
+		return
+	}
+	var list []map[string]any
+	switch ms := result["measurements"].(type) {
+	case []map[string]any:
+		list = ms
+	case []any:
+		for _, m := range ms {
+			if mm, ok := m.(map[string]any); ok {
+				list = append(list, mm)
+			}
+		}
+	}
+
+	var normalized, noAltitude, aboveRange, otherUnit int
+	maxHeight := math.Inf(-1)
+	for _, m := range list {
+		if unit, _ := m["unit"].(string); unit != "µSv/h" {
+			otherUnit++
+			continue
+		}
+		value, ok := toFloat(m["value"])
+		if !ok {
+			continue
+		}
+		height, ok := toFloat(m["height"])
+		if !ok {
+			m["altitude_flag"] = "no_altitude"
+			noAltitude++
+			continue
+		}
+		maxHeight = max(maxHeight, height)
+		if height > cosmicModelMaxM {
+			m["altitude_flag"] = "above_model_range"
+			aboveRange++
+			continue
+		}
+		excess := cosmicExcess(height)
+		m["cosmic_excess_usvh"] = excess
+		m["ground_usvh"] = max(value-excess, 0)
+		normalized++
+	}
+
+	summary := map[string]any{
+		"enabled":               true,
+		"normalized":            normalized,
+		"without_altitude":      noAltitude,
+		"above_model_range":     aboveRange,
+		"sea_level_cosmic_usvh": cosmicSeaLevelUSvH,
+		"model":                 "UNSCEAR 2000 altitude dependence of the ionizing and photon component of cosmic radiation (32 nSv/h at sea level); the neutron component is not modelled, since Geiger tubes hardly detect it",
+		"note":                  "ground_usvh = value - cosmic_excess_usvh, the estimated cosmic excess over sea level at the reading's GPS altitude; value is unchanged. GPS altitude is often off by tens of metres, which changes the correction by well under 0.01 µSv/h below 3 km.",
+	}
+	if !math.IsInf(maxHeight, -1) {
+		summary["max_height_m"] = maxHeight
+	}
+	if aboveRange > 0 {
+		summary["above_model_range_note"] = "Readings above 6,000 m are from aircraft. Their dose rate is dominated by cosmic radiation and says nothing about ground contamination; leave them out of ground comparisons."
+	}
+	if otherUnit > 0 {
+		summary["not_in_usvh"] = otherUnit
+	}
+	result["altitude_normalization"] = summary
+}
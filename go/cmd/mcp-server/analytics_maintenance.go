@@ -0,0 +1,293 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Analytics storage maintenance ──────────────────────────────────────────
+//
+// The analytics DuckDB file and the Parquet snapshots grow and fragment as
+// events are inserted and snapshots rewritten. Maintainers can tidy them up
+// through the analytics_maintenance tool or POST /api/admin/maintenance
+// instead of stopping the server and opening a shell on the VPS. Tasks:
+//
+//   checkpoint  write the WAL into the database file (FORCE CHECKPOINT)
+//   vacuum      VACUUM ANALYZE each local table, refreshing statistics
+//   reindex     drop and recreate each index from its stored definition
+//   compact     rewrite each snapshot Parquet file with ZSTD and remove
+//               leftovers from failed exports
+//
+// One run at a time; each task expands to steps so progress can be
+// reported per table, index or file.
+
+var maintenanceTasks = []string{"checkpoint", "vacuum", "reindex", "compact"}
+
+type maintenanceStep struct {
+	Task       string `json:"task"`
+	Target     string `json:"target,omitempty"`
+	Status     string `json:"status"` // pending, running, done, failed
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type maintenanceRun struct {
+	ID         int64             `json:"id"`
+	StartedBy  string            `json:"started_by"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Status     string            `json:"status"` // running, done, failed
+	SizeBefore string            `json:"database_size_before,omitempty"`
+	SizeAfter  string            `json:"database_size_after,omitempty"`
+	Steps      []maintenanceStep `json:"steps"`
+}
+
+var maintenance struct {
+	mu      sync.Mutex
+	current *maintenanceRun
+	last    *maintenanceRun
+	nextID  int64
+}
+
+// parseMaintenanceTasks validates a comma-separated task list; "all"
+// selects every task.
+func parseMaintenanceTasks(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "all" {
+		return maintenanceTasks, nil
+	}
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		found := false
+		for _, known := range maintenanceTasks {
+			if t == known {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown maintenance task %q (available: %s, or all)", t, strings.Join(maintenanceTasks, ", "))
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// startMaintenance plans a run. It fails while another run is in progress.
+func startMaintenance(tasks []string, by string) (*maintenanceRun, error) {
+	if duckDB == nil {
+		return nil, fmt.Errorf("analytics maintenance needs DuckDB, which is not initialized")
+	}
+	maintenance.mu.Lock()
+	defer maintenance.mu.Unlock()
+	if c := maintenance.current; c != nil {
+		return nil, fmt.Errorf("maintenance run %d started by %s at %s is still running", c.ID, c.StartedBy, c.StartedAt.UTC().Format(time.RFC3339))
+	}
+
+	var steps []maintenanceStep
+	for _, task := range tasks {
+		targets, err := maintenanceTargets(task)
+		if err != nil {
+			return nil, fmt.Errorf("planning %s: %v", task, err)
+		}
+		for _, t := range targets {
+			steps = append(steps, maintenanceStep{Task: task, Target: t, Status: "pending"})
+		}
+	}
+
+	maintenance.nextID++
+	run := &maintenanceRun{
+		ID:         maintenance.nextID,
+		StartedBy:  by,
+		StartedAt:  time.Now(),
+		Status:     "running",
+		SizeBefore: duckDBSize(),
+		Steps:      steps,
+	}
+	maintenance.current = run
+	log.Printf("Analytics maintenance run %d (%s, %d steps) started by %s", run.ID, strings.Join(tasks, ","), len(steps), by)
+	return run, nil
+}
+
+// maintenanceTargets lists what a task works on: local tables, indexes
+// or snapshot files. checkpoint has a single unnamed step.
+func maintenanceTargets(task string) ([]string, error) {
+	switch task {
+	case "vacuum":
+		return duckStrings(`SELECT table_name FROM duckdb_tables() WHERE database_name = current_database() ORDER BY table_name`)
+	case "reindex":
+		return duckStrings(`SELECT index_name FROM duckdb_indexes() WHERE database_name = current_database() AND sql IS NOT NULL ORDER BY index_name`)
+	case "compact":
+		return filepath.Glob(filepath.Join(snapshotDir(), "*.parquet"))
+	}
+	return []string{""}, nil
+}
+
+func duckStrings(query string, args ...any) ([]string, error) {
+	rows, err := duckDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// runMaintenance executes the planned steps in order, calling progress
+// before each one and once at the end. A failed step is recorded and the
+// run carries on with the rest.
+func runMaintenance(run *maintenanceRun, progress func(done, total int, message string)) {
+	failed := 0
+	for i := range run.Steps {
+		step := &run.Steps[i]
+		message := step.Task
+		if step.Target != "" {
+			message += " " + filepath.Base(step.Target)
+		}
+		progress(i, len(run.Steps), message)
+
+		maintenance.mu.Lock()
+		step.Status = "running"
+		maintenance.mu.Unlock()
+
+		start := time.Now()
+		detail, err := runMaintenanceStep(step.Task, step.Target)
+
+		maintenance.mu.Lock()
+		step.DurationMs = time.Since(start).Milliseconds()
+		step.Status, step.Detail = "done", detail
+		if err != nil {
+			step.Status, step.Error = "failed", err.Error()
+			failed++
+		}
+		maintenance.mu.Unlock()
+		if err != nil {
+			log.Printf("Analytics maintenance run %d: %s %s: %v", run.ID, step.Task, step.Target, err)
+		}
+	}
+
+	size := duckDBSize()
+	maintenance.mu.Lock()
+	now := time.Now()
+	run.FinishedAt, run.SizeAfter, run.Status = &now, size, "done"
+	if failed > 0 {
+		run.Status = "failed"
+	}
+	maintenance.current, maintenance.last = nil, run
+	maintenance.mu.Unlock()
+
+	progress(len(run.Steps), len(run.Steps), "finished")
+	log.Printf("Analytics maintenance run %d finished in %s (%d of %d steps failed)",
+		run.ID, now.Sub(run.StartedAt).Round(time.Millisecond), failed, len(run.Steps))
+}
+
+func runMaintenanceStep(task, target string) (detail string, err error) {
+	switch task {
+	case "checkpoint":
+		_, err = duckExec("FORCE CHECKPOINT")
+	case "vacuum":
+		_, err = duckExec("VACUUM ANALYZE " + quoteIdent(target))
+	case "reindex":
+		err = rebuildIndex(target)
+	case "compact":
+		var before, after int64
+		if before, after, err = compactParquet(target); err == nil {
+			detail = fmt.Sprintf("%d → %d bytes", before, after)
+		}
+	default:
+		err = fmt.Errorf("unknown task %q", task)
+	}
+	return detail, err
+}
+
+// rebuildIndex drops and recreates an index from duckdb_indexes().sql in
+// one transaction, so the index is never missing for other connections.
+func rebuildIndex(name string) error {
+	var def string
+	err := duckDB.QueryRow(`SELECT sql FROM duckdb_indexes() WHERE database_name = current_database() AND index_name = ?`, name).Scan(&def)
+	if err != nil {
+		return err
+	}
+	return duckWriteTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DROP INDEX " + quoteIdent(name)); err != nil {
+			return err
+		}
+		_, err := tx.Exec(def)
+		return err
+	})
+}
+
+// compactParquet rewrites a snapshot file with ZSTD compression. It holds
+// snapshotMu so the exporter cannot replace the file halfway.
+func compactParquet(path string) (before, after int64, err error) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	tmp := path + ".compact.tmp"
+	q := fmt.Sprintf("COPY (SELECT * FROM read_parquet(%s)) TO %s (FORMAT PARQUET, COMPRESSION ZSTD)", pgQuote(path), pgQuote(tmp))
+	if _, err := duckDB.Exec(q); err != nil {
+		os.Remove(tmp)
+		return 0, 0, err
+	}
+	tmpInfo, err := os.Stat(tmp)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, 0, err
+	}
+	// Leftovers from exports that failed before their rename.
+	stale, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	for _, f := range stale {
+		os.Remove(f)
+	}
+	return info.Size(), tmpInfo.Size(), nil
+}
+
+// duckDBSize is the local database's size as DuckDB reports it.
+func duckDBSize() string {
+	var size string
+	if err := duckDB.QueryRow(`SELECT database_size FROM pragma_database_size() WHERE database_name = current_database()`).Scan(&size); err != nil {
+		return ""
+	}
+	return size
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// maintenanceStatus returns the running run, or the last finished one.
+// The copy is taken under the lock so callers can marshal it safely.
+func maintenanceStatus() (maintenanceRun, bool) {
+	maintenance.mu.Lock()
+	defer maintenance.mu.Unlock()
+	r := maintenance.current
+	if r == nil {
+		r = maintenance.last
+	}
+	if r == nil {
+		return maintenanceRun{}, false
+	}
+	out := *r
+	out.Steps = append([]maintenanceStep(nil), r.Steps...)
+	return out, true
+}
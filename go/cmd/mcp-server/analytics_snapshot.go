@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ── Analytics snapshots ────────────────────────────────────────────────────
+//
+// radiation_stats and query_extreme_readings scan the attached Postgres
+// replica. When the ATTACH fails at startup (replica down, credentials
+// rotated) they would have nothing to read, so a background exporter keeps
+// the latest aggregates in local Parquet files and the tools read those
+// instead, saying how old they are.
+//
+//   radiation_stats_<interval>.parquet  the radiationStatsQuery result
+//   extreme_readings.parquet            the snapshotExtremeRows highest and
+//                                       lowest markers, raw columns
+//   country_daily.parquet               measurement count and average dose
+//                                       per country and day, read by
+//                                       /api/stats/timeseries
+
+const (
+	defaultSnapshotInterval  = 6 * time.Hour
+	snapshotExtremeRows      = 1000
+	extremeSnapshotFile      = "extreme_readings.parquet"
+	countryDailySnapshotFile = "country_daily.parquet"
+)
+
+var radiationStatsIntervals = []string{"year", "month", "overall"}
+
+// snapshotMu serializes writes to the snapshot files (export, compaction).
+var snapshotMu sync.Mutex
+
+func snapshotDir() string {
+	return envOr("ANALYTICS_SNAPSHOT_DIR", "./analytics_snapshots")
+}
+
+func snapshotPath(name string) string {
+	return filepath.Join(snapshotDir(), name)
+}
+
+func statsSnapshotFile(interval string) string {
+	return "radiation_stats_" + interval + ".parquet"
+}
+
+// startSnapshotExporter refreshes the snapshots every
+// ANALYTICS_SNAPSHOT_INTERVAL while Postgres is attached. Demo data is
+// never exported, so it cannot replace snapshots of the real database.
+func startSnapshotExporter() {
+	interval := durationEnv("ANALYTICS_SNAPSHOT_INTERVAL", defaultSnapshotInterval)
+	if !duckDBPostgresAttached() || demoMode || interval <= 0 {
+		return
+	}
+	log.Printf("Analytics snapshot exporter started (every %s, in %s)", interval, snapshotDir())
+	go func() {
+		exportSnapshots()
+		for range time.Tick(interval) {
+			exportSnapshots()
+		}
+	}()
+}
+
+func exportSnapshots() {
+	if err := os.MkdirAll(snapshotDir(), 0o755); err != nil {
+		log.Printf("Analytics snapshot: %v", err)
+		return
+	}
+	for _, interval := range radiationStatsIntervals {
+		if err := exportSnapshot(statsSnapshotFile(interval), radiationStatsQuery(interval, nil)); err != nil {
+			log.Printf("Analytics snapshot %s: %v", interval, err)
+		}
+	}
+	extremes := fmt.Sprintf(`
+		(SELECT id, doserate, lat, lon, device_id, date, trackid, detector
+		 FROM postgres_db.public.markers
+		 WHERE %[2]s
+		 ORDER BY doserate DESC LIMIT %[1]d)
+		UNION ALL
+		(SELECT id, doserate, lat, lon, device_id, date, trackid, detector
+		 FROM postgres_db.public.markers
+		 WHERE %[2]s
+		 ORDER BY doserate ASC LIMIT %[1]d)`, snapshotExtremeRows, extremeBaseCondition)
+	if err := exportSnapshot(extremeSnapshotFile, extremes); err != nil {
+		log.Printf("Analytics snapshot extremes: %v", err)
+	}
+	if err := exportSnapshot(countryDailySnapshotFile, countryDailyQuery(timeseriesCountries())); err != nil {
+		log.Printf("Analytics snapshot country_daily: %v", err)
+	}
+}
+
+// exportSnapshot writes query to a temporary file first so readers never
+// see a half-written snapshot.
+func exportSnapshot(name, query string) error {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	path := snapshotPath(name)
+	tmp := path + ".tmp"
+	if _, err := duckDB.Exec(fmt.Sprintf("COPY (%s) TO %s (FORMAT PARQUET)", query, pgQuote(tmp))); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// snapshotSource returns a DuckDB table expression for a snapshot file and
+// when it was written. ok is false when there is no snapshot to read.
+func snapshotSource(name string) (table string, takenAt time.Time, ok bool) {
+	if duckDB == nil {
+		return "", time.Time{}, false
+	}
+	path := snapshotPath(name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return "read_parquet(" + pgQuote(path) + ")", info.ModTime(), true
+}
+
+// snapshotInfo is the "snapshot" block added to results read from Parquet.
+func snapshotInfo(takenAt time.Time, note string) map[string]any {
+	return map[string]any{
+		"taken_at":          takenAt.UTC().Format(time.RFC3339),
+		"staleness_seconds": int(time.Since(takenAt).Seconds()),
+		"note":              note,
+	}
+}
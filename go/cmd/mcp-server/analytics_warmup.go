@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// ── Analytics warm-up and prepared statements ──────────────────────────────
+//
+// The first analytics query after startup pays for the postgres scanner
+// opening its connections and fetching catalog metadata, plus DuckDB
+// planning the query. warmUpAnalytics runs the common queries once at boot
+// so no user waits on that, and the fixed-shape queries (radiation_stats
+// without filters, unfiltered extremes) keep their prepared statement so
+// later calls skip planning.
+
+var (
+	duckStmtMu sync.Mutex
+	duckStmts  = map[string]*sql.Stmt{}
+
+	warmupMu     sync.Mutex
+	warmupResult map[string]any
+)
+
+// duckPreparedQuery runs query through a cached prepared statement. Only
+// pass fixed SQL text: every distinct query keeps a statement open.
+func duckPreparedQuery(query string, args ...any) (*sql.Rows, error) {
+	duckStmtMu.Lock()
+	stmt, ok := duckStmts[query]
+	if !ok {
+		var err error
+		stmt, err = duckDB.Prepare(query)
+		if err != nil {
+			duckStmtMu.Unlock()
+			return nil, err
+		}
+		duckStmts[query] = stmt
+	}
+	duckStmtMu.Unlock()
+	return stmt.Query(args...)
+}
+
+// warmUpAnalytics prepares and runs the fixed-shape analytics queries in
+// the background. Needs DuckDB with Postgres attached.
+func warmUpAnalytics() {
+	if !duckDBPostgresAttached() {
+		return
+	}
+	go func() {
+		start := time.Now()
+		timings := map[string]any{}
+		var failed []string
+		run := func(name, query string, args ...any) {
+			t := time.Now()
+			rows, err := duckPreparedQuery(query, args...)
+			if err == nil {
+				for rows.Next() {
+				}
+				err = rows.Err()
+				rows.Close()
+			}
+			if err != nil {
+				log.Printf("Analytics warm-up %s: %v", name, err)
+				failed = append(failed, name)
+				return
+			}
+			timings[name] = time.Since(t).Milliseconds()
+		}
+		for _, interval := range radiationStatsIntervals {
+			run("radiation_stats_"+interval, radiationStatsQuery(interval, nil))
+		}
+		for _, direction := range []string{"highest", "lowest"} {
+			run("extreme_readings_"+direction, extremeReadingsQuery("postgres_db.public.markers", extremeBaseCondition, direction), 10)
+		}
+		log.Printf("Analytics warm-up finished in %s", time.Since(start).Round(time.Millisecond))
+
+		warmupMu.Lock()
+		warmupResult = map[string]any{
+			"finished_at": time.Now().UTC().Format(time.RFC3339),
+			"duration_ms": time.Since(start).Milliseconds(),
+			"queries_ms":  timings,
+		}
+		if len(failed) > 0 {
+			warmupResult["failed"] = failed
+		}
+		warmupMu.Unlock()
+	}()
+}
+
+// analyticsStats reports the warm-up for db_info.
+func analyticsStats() map[string]any {
+	duckStmtMu.Lock()
+	prepared := len(duckStmts)
+	duckStmtMu.Unlock()
+
+	out := map[string]any{
+		"duckdb":              duckDB != nil,
+		"postgres_attached":   duckDBPostgresAttached(),
+		"spatial":             duckDBSpatial(),
+		"prepared_statements": prepared,
+	}
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+	if warmupResult != nil {
+		out["warmup"] = warmupResult
+	} else if duckDBPostgresAttached() {
+		out["warmup"] = "running"
+	}
+	return out
+}
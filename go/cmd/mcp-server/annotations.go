@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Maintainer annotations ─────────────────────────────────────────────────
+//
+// Maintainers attach notes to markers, tracks and devices ("known Ra-226
+// check source", "GPS error") with annotate_measurement. The Postgres
+// replica is read-only, so annotations live in DuckDB next to the query
+// log. The table is small, so it is mirrored in memory and every read tool
+// result is checked against it: any marker, track or device it mentions
+// gets its annotations attached under "maintainer_annotations".
+//
+// Writers authenticate with a bearer token from MAINTAINER_TOKENS
+// ("alice:token1,bob:token2"). In stdio mode there are no headers; the
+// local user is trusted as MCP_STDIO_MAINTAINER when that is set.
+
+const (
+	annotationMarker = "marker"
+	annotationTrack  = "track"
+	annotationDevice = "device"
+)
+
+var annotationFlags = []string{"check_source", "gps_error", "sensor_fault", "calibration", "duplicate", "verified", "other"}
+
+type annotation struct {
+	ID         int64     `json:"id"`
+	TargetType string    `json:"target_type"`
+	TargetID   string    `json:"target_id"`
+	Flag       string    `json:"flag"`
+	Note       string    `json:"note"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type annotationStore struct {
+	mu       sync.RWMutex
+	byTarget map[string][]annotation // "type:id" → annotations, oldest first
+}
+
+var annotations = &annotationStore{byTarget: map[string][]annotation{}}
+
+func annotationKey(targetType, targetID string) string {
+	return targetType + ":" + targetID
+}
+
+// load reads every annotation from DuckDB. Called once from initDuckDB.
+func (s *annotationStore) load() error {
+	rows, err := duckDB.Query(`
+		SELECT id, target_type, target_id, flag, note, author, created_at
+		FROM measurement_annotations
+		ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byTarget := map[string][]annotation{}
+	for rows.Next() {
+		var a annotation
+		if err := rows.Scan(&a.ID, &a.TargetType, &a.TargetID, &a.Flag, &a.Note, &a.Author, &a.CreatedAt); err != nil {
+			return err
+		}
+		k := annotationKey(a.TargetType, a.TargetID)
+		byTarget[k] = append(byTarget[k], a)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.byTarget = byTarget
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *annotationStore) add(targetType, targetID, flag, note, author string) (annotation, error) {
+	if duckDB == nil {
+		return annotation{}, fmt.Errorf("annotation store unavailable (DuckDB not initialized)")
+	}
+	a := annotation{TargetType: targetType, TargetID: targetID, Flag: flag, Note: note, Author: author}
+	err := duckWriteTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO measurement_annotations (target_type, target_id, flag, note, author)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING id, created_at`,
+			targetType, targetID, flag, note, author).Scan(&a.ID, &a.CreatedAt)
+	})
+	if err != nil {
+		return annotation{}, err
+	}
+
+	s.mu.Lock()
+	k := annotationKey(targetType, targetID)
+	s.byTarget[k] = append(s.byTarget[k], a)
+	s.mu.Unlock()
+	return a, nil
+}
+
+// remove deletes an annotation by ID and returns what was removed.
+func (s *annotationStore) remove(id int64) (annotation, error) {
+	if duckDB == nil {
+		return annotation{}, fmt.Errorf("annotation store unavailable (DuckDB not initialized)")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, list := range s.byTarget {
+		for i, a := range list {
+			if a.ID != id {
+				continue
+			}
+			if _, err := duckExec(`DELETE FROM measurement_annotations WHERE id = ?`, id); err != nil {
+				return annotation{}, err
+			}
+			s.byTarget[k] = append(list[:i:i], list[i+1:]...)
+			if len(s.byTarget[k]) == 0 {
+				delete(s.byTarget, k)
+			}
+			return a, nil
+		}
+	}
+	return annotation{}, fmt.Errorf("annotation %d not found", id)
+}
+
+func (s *annotationStore) empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byTarget) == 0
+}
+
+func (s *annotationStore) lookup(targetType, targetID string) []annotation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byTarget[annotationKey(targetType, targetID)]
+}
+
+// attach adds the annotations for every marker, track and device mentioned
+// in a tool result. Results without matches are returned unchanged.
+func (s *annotationStore) attach(res *mcp.CallToolResult) *mcp.CallToolResult {
+	if res == nil || res.IsError || s.empty() {
+		return res
+	}
+
+	var found []annotation
+	seen := map[int64]bool{}
+	check := func(targetType string, v any) {
+		if v == nil {
+			return
+		}
+		for _, a := range s.lookup(targetType, fmt.Sprint(v)) {
+			if !seen[a.ID] {
+				seen[a.ID] = true
+				found = append(found, a)
+			}
+		}
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			check(annotationTrack, t["track_id"])
+			check(annotationTrack, t["trackid"])
+			check(annotationDevice, t["device_id"])
+			check(annotationMarker, t["marker_id"])
+			// Measurement rows carry their marker ID as plain "id".
+			if _, isMeasurement := t["captured_at"]; isMeasurement {
+				check(annotationMarker, t["id"])
+			}
+			for _, child := range t {
+				walk(child)
+			}
+		case []any:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+
+	return amendJSONResult(res, func(m map[string]any) {
+		walk(m)
+		if len(found) == 0 {
+			return
+		}
+		sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+		m["maintainer_annotations"] = map[string]any{
+			"note":  "Notes added by Safecast maintainers about data in this result. Treat them as authoritative context: mention any that apply (e.g. a known check source or GPS error) before interpreting the readings.",
+			"items": found,
+		}
+	})
+}
+
+// ── Maintainer authentication ──────────────────────────────────────────────
+
+// maintainerTokens parses MAINTAINER_TOKENS into token → name.
+func maintainerTokens() map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("MAINTAINER_TOKENS"), ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && name != "" && token != "" {
+			out[token] = name
+		}
+	}
+	return out
+}
+
+// maintainersConfigured reports whether anyone can write annotations; the
+// write tool is only registered when this is true.
+func maintainersConfigured() bool {
+	return len(maintainerTokens()) > 0 || os.Getenv("MCP_STDIO_MAINTAINER") != ""
+}
+
+// authenticateMaintainer returns the maintainer name for a request, or an
+// error safe to show the caller. The token comes from X-Maintainer-Token
+// when set, since behind OAuth the Authorization header carries the OAuth
+// access token (oauth.go), and from Authorization: Bearer otherwise.
+func authenticateMaintainer(h http.Header) (string, error) {
+	if token := h.Get("X-Maintainer-Token"); token != "" {
+		return maintainerName(token)
+	}
+	auth := h.Get("Authorization")
+	if auth == "" {
+		if name := os.Getenv("MCP_STDIO_MAINTAINER"); name != "" && os.Getenv("MCP_TRANSPORT") == "stdio" {
+			return name, nil
+		}
+		return "", fmt.Errorf("maintainer authentication required: send Authorization: Bearer <token> or X-Maintainer-Token: <token>")
+	}
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return "", fmt.Errorf("the Authorization header must use the Bearer scheme")
+	}
+	return maintainerName(token)
+}
+
+// maintainerName looks up a maintainer token.
+func maintainerName(token string) (string, error) {
+	for known, name := range maintainerTokens() {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid maintainer token")
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -125,9 +126,20 @@ func (c *SafecastClient) getObject(ctx context.Context, path string, params url.
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordRead(ctx, sourceAPI, apiObjectRows(result))
 	return result, nil
 }
 
+// apiObjectRows is the number of rows in a simplemap object response: its
+// count or the length of its longest list (the tracks or markers it
+// wraps), or 1.
+func apiObjectRows(result map[string]any) int {
+	if n, ok := resultRowCount(result); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
 func (c *SafecastClient) getList(ctx context.Context, path string, params url.Values) ([]map[string]any, error) {
 	body, err := c.doGet(ctx, path, params)
 	if err != nil {
@@ -137,6 +149,7 @@ func (c *SafecastClient) getList(ctx context.Context, path string, params url.Va
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordRead(ctx, sourceAPI, len(result))
 	return result, nil
 }
 
@@ -222,10 +235,48 @@ func normalizeGetMarker(m map[string]any) map[string]any {
 }
 
 // jsonResult serializes v to indented JSON and returns it as a tool result.
+// Values are normalized first (see normalize.go).
 func jsonResult(v any) (*mcp.CallToolResult, error) {
+	return marshalResult(normalizeResult(v, false))
+}
+
+// rawJSONResult is jsonResult without rounding, for measurement tools
+// called with raw_units (see raw_units.go).
+func rawJSONResult(v any) (*mcp.CallToolResult, error) {
+	return marshalResult(normalizeResult(v, true))
+}
+
+func marshalResult(v any) (*mcp.CallToolResult, error) {
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return mcp.NewToolResultError("failed to serialize response"), nil
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+// amendJSONResult lets a wrapper add fields to a result built by jsonResult;
+// its _meta is kept. Results that are not a JSON object (errors, plain
+// text) are returned as is.
+func amendJSONResult(res *mcp.CallToolResult, fn func(map[string]any)) *mcp.CallToolResult {
+	if res == nil || res.IsError || len(res.Content) != 1 {
+		return res
+	}
+	tc, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		return res
+	}
+	dec := json.NewDecoder(strings.NewReader(tc.Text))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil || m == nil {
+		return res
+	}
+	fn(m)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return res
+	}
+	out := mcp.NewToolResultText(string(data))
+	out.Meta = res.Meta
+	return out
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Audit trail ────────────────────────────────────────────────────────────
+//
+// Deployments under research-data governance need to show who called what,
+// when and from where. The event log (event_log.go) does not serve: it is
+// sampled, batched, rotated and stored in a database anyone with DuckDB
+// access can edit. MCP_AUDIT_LOG_FILE enables a separate audit log with one
+// JSON line per tool call and per /api/ request, written before the call
+// returns, never sampled and never rotated or rewritten by the server.
+//
+// Each line carries a sequence number, the SHA-256 of the line before it
+// (prev) and an HMAC-SHA256 over itself keyed with MCP_AUDIT_SIGNING_KEY
+// (sig). Editing, removing or reordering lines breaks the chain, and lines
+// cannot be forged without the key. The log is exported as is by
+// GET /api/admin/audit and checked with `mcp-server --verify-audit FILE`.
+// Results are never stored; arguments are, so the file is as sensitive as
+// the queries people run.
+
+type auditEntry struct {
+	Seq       int64           `json:"seq"`
+	Time      time.Time       `json:"time"`
+	Kind      string          `json:"kind"`   // "tool" or "http"
+	Action    string          `json:"action"` // tool name, or method and path
+	Params    json.RawMessage `json:"params,omitempty"`
+	Query     string          `json:"query,omitempty"`
+	Principal string          `json:"principal,omitempty"` // "maintainer:<name>" or "oauth:<subject>"
+	RemoteIP  string          `json:"remote_ip,omitempty"`
+	Transport string          `json:"transport,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Client    string          `json:"client,omitempty"`
+	Status    int             `json:"status,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Prev      string          `json:"prev"`
+	Sig       string          `json:"sig,omitempty"`
+}
+
+type auditLog struct {
+	path string
+	key  []byte
+
+	mu   sync.Mutex
+	file *os.File
+	seq  int64
+	prev string
+
+	written atomic.Int64
+	failed  atomic.Int64
+}
+
+// audit is set at startup by openAuditLog; nil disables the audit trail.
+var audit *auditLog
+
+// openAuditLog opens MCP_AUDIT_LOG_FILE for appending and picks up the
+// chain where the file ends. It returns nil when no file is configured.
+func openAuditLog() (*auditLog, error) {
+	path := os.Getenv("MCP_AUDIT_LOG_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	key := os.Getenv("MCP_AUDIT_SIGNING_KEY")
+	if len(key) < 16 {
+		return nil, fmt.Errorf("MCP_AUDIT_LOG_FILE is set but MCP_AUDIT_SIGNING_KEY is missing or shorter than 16 bytes")
+	}
+	a := &auditLog{path: path, key: []byte(key)}
+	if f, err := os.Open(path); err == nil {
+		v, err := verifyAuditLog(f, a.key)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("audit log %s: %w", path, err)
+		}
+		if !v.Valid {
+			// Appending to a damaged chain would bury the damage; a person
+			// should look at it and move the file aside.
+			return nil, fmt.Errorf("audit log %s fails verification at line %d (%s); move it aside to start a new log", path, v.BrokenAt, v.Problem)
+		}
+		a.seq, a.prev = v.LastSeq, v.lastHash
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	a.file = f
+	log.Printf("Audit trail enabled: %s (continuing at seq %d)", path, a.seq+1)
+	return a, nil
+}
+
+// append signs e, chains it to the previous line and writes it.
+func (a *auditLog) append(e auditEntry) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.Seq, e.Prev, e.Sig = a.seq+1, a.prev, ""
+	unsigned, err := json.Marshal(e)
+	if err != nil {
+		a.failed.Add(1)
+		log.Printf("Audit entry for %s not written: %v", e.Action, err)
+		return
+	}
+	e.Sig = auditSignature(a.key, unsigned)
+	line, _ := json.Marshal(e)
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		a.failed.Add(1)
+		log.Printf("Audit entry for %s not written: %v", e.Action, err)
+		return
+	}
+	a.seq, a.prev = e.Seq, auditHash(line)
+	a.written.Add(1)
+}
+
+func auditSignature(key, unsigned []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(unsigned)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func auditHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// ── Recording ──
+
+type auditCallerKey struct{}
+
+// auditCaller is what the transport knows about the HTTP request a tool
+// call arrived in.
+type auditCaller struct {
+	remoteIP     string
+	oauthSubject string
+}
+
+// withAuditCaller records the request's origin for the tool call; the
+// transports' context funcs call it.
+func withAuditCaller(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, auditCaller{
+		remoteIP:     requestIP(r),
+		oauthSubject: oauthSubject(r.Context()),
+	})
+}
+
+// auditPrincipal names who made a request: a maintainer when a valid
+// maintainer token came with it, else the OAuth subject, else nobody.
+func auditPrincipal(h http.Header, oauthSub string) string {
+	if name, err := authenticateMaintainer(h); err == nil {
+		return "maintainer:" + name
+	}
+	if oauthSub != "" {
+		return "oauth:" + oauthSub
+	}
+	return ""
+}
+
+// recordTool appends a finished tool call.
+func (a *auditLog) recordTool(ctx context.Context, name string, req mcp.CallToolRequest, res *mcp.CallToolResult, err error) {
+	if a == nil {
+		return
+	}
+	caller, _ := ctx.Value(auditCallerKey{}).(auditCaller)
+	e := auditEntry{
+		Time:      time.Now().UTC(),
+		Kind:      "tool",
+		Action:    name,
+		Principal: auditPrincipal(req.Header, caller.oauthSubject),
+		RemoteIP:  caller.remoteIP,
+		Transport: transportFromContext(ctx),
+		Error:     errString(err),
+	}
+	if e.Transport == "" {
+		e.Transport = "internal" // reports, saved queries, self_test
+	}
+	if args := req.GetArguments(); len(args) > 0 {
+		e.Params, _ = json.Marshal(args)
+	}
+	if s := server.ClientSessionFromContext(ctx); s != nil {
+		e.SessionID = s.SessionID()
+		if ci, ok := s.(server.SessionWithClientInfo); ok {
+			e.Client = ci.GetClientInfo().Name
+		}
+	}
+	if res != nil && res.IsError && e.Error == "" {
+		e.Error = sourceFailure(res, nil)
+	}
+	a.append(e)
+}
+
+// middleware appends every /api/ request handled by next.
+func (a *auditLog) middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		a.append(auditEntry{
+			Time:      time.Now().UTC(),
+			Kind:      "http",
+			Action:    r.Method + " " + r.URL.Path,
+			Query:     r.URL.RawQuery,
+			Principal: auditPrincipal(r.Header, ""),
+			RemoteIP:  requestIP(r),
+			Status:    rec.status,
+		})
+	})
+}
+
+// auditRecorder captures the status code; Flush and Unwrap keep streaming
+// handlers working behind it.
+type auditRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *auditRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status, s.wroteHeader = code, true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *auditRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		s.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (s *auditRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// ── Export and verification ──
+
+// handleAuditExport handles GET /api/admin/audit
+//
+// @Summary     Export the audit trail
+// @Description Maintainers only. Returns the signed audit log as JSON lines, unchanged from disk, optionally limited to entries between since and until. Check an export with mcp-server --verify-audit.
+// @Tags        admin
+// @Produce     json
+// @Param       since query string false "Earliest entry time (RFC 3339)"
+// @Param       until query string false "Latest entry time (RFC 3339)"
+// @Success     200 {string} string "JSON lines"
+// @Failure     401 {object} map[string]string "Missing or invalid maintainer token"
+// @Failure     404 {object} map[string]string "Audit trail not enabled"
+// @Router      /admin/audit [get]
+func (h *RESTHandler) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateMaintainer(r.Header); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if audit == nil {
+		writeError(w, http.StatusNotFound, "the audit trail is not enabled (MCP_AUDIT_LOG_FILE)")
+		return
+	}
+	var since, until time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := r.URL.Query().Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, p.name+" must be an RFC 3339 time")
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	f, err := os.Open(audit.path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "audit log unavailable")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().UTC().Format("20060102T150405Z")))
+	w.Header().Set("Cache-Control", "no-store")
+	sc := newAuditScanner(f)
+	for sc.Scan() {
+		if !since.IsZero() || !until.IsZero() {
+			var e struct {
+				Time time.Time `json:"time"`
+			}
+			if json.Unmarshal(sc.Bytes(), &e) == nil &&
+				((!since.IsZero() && e.Time.Before(since)) || (!until.IsZero() && e.Time.After(until))) {
+				continue
+			}
+		}
+		w.Write(sc.Bytes())
+		w.Write([]byte{'\n'})
+	}
+}
+
+func newAuditScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	return sc
+}
+
+// auditVerification is the outcome of checking an audit log or export.
+type auditVerification struct {
+	Entries  int64     `json:"entries"`
+	FirstSeq int64     `json:"first_seq,omitempty"`
+	LastSeq  int64     `json:"last_seq,omitempty"`
+	First    time.Time `json:"first,omitzero"`
+	Last     time.Time `json:"last,omitzero"`
+	Valid    bool      `json:"valid"`
+	BrokenAt int64     `json:"broken_at_line,omitempty"`
+	Problem  string    `json:"problem,omitempty"`
+
+	lastHash string
+}
+
+// verifyAuditLog checks every signature and link of the chain. The first
+// line's prev is taken on trust, so an export that starts mid-log verifies
+// too; anything cut from inside it does not. The error is for read
+// failures, not for a broken chain.
+func verifyAuditLog(r io.Reader, key []byte) (auditVerification, error) {
+	v := auditVerification{Valid: true}
+	sc := newAuditScanner(r)
+	var line int64
+	for sc.Scan() {
+		line++
+		raw := sc.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		problem := ""
+		var e auditEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			problem = "not a JSON audit entry"
+		} else {
+			sig := e.Sig
+			e.Sig = ""
+			unsigned, _ := json.Marshal(e)
+			switch {
+			case !hmac.Equal([]byte(sig), []byte(auditSignature(key, unsigned))):
+				problem = "signature does not match (entry edited, or a different key)"
+			case v.Entries > 0 && e.Seq != v.LastSeq+1:
+				problem = fmt.Sprintf("seq %d follows %d", e.Seq, v.LastSeq)
+			case v.Entries > 0 && e.Prev != v.lastHash:
+				problem = "prev does not match the line before"
+			}
+		}
+		if problem != "" {
+			v.Valid, v.BrokenAt, v.Problem = false, line, problem
+			return v, nil
+		}
+		if v.Entries == 0 {
+			v.FirstSeq, v.First = e.Seq, e.Time
+		}
+		v.Entries++
+		v.LastSeq, v.Last, v.lastHash = e.Seq, e.Time, auditHash(raw)
+	}
+	return v, sc.Err()
+}
+
+// runVerifyAudit implements --verify-audit: it prints the verification of
+// path as JSON and reports whether the chain is intact.
+func runVerifyAudit(w io.Writer, path string) bool {
+	key := os.Getenv("MCP_AUDIT_SIGNING_KEY")
+	if key == "" {
+		fmt.Fprintln(w, "MCP_AUDIT_SIGNING_KEY must be set to verify an audit log")
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return false
+	}
+	defer f.Close()
+	v, err := verifyAuditLog(f, []byte(key))
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return false
+	}
+	out, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Fprintln(w, string(out))
+	return v.Valid
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ── Build information ──────────────────────────────────────────────────────
+//
+// The commit a binary was built from is stamped at link time:
+//
+//   go build -ldflags "-X main.buildCommit=$(git rev-parse HEAD)" ./cmd/mcp-server/
+//
+// Without the flag it falls back to the VCS stamp the Go toolchain embeds
+// when building inside a git checkout (debug.ReadBuildInfo). Neither needs
+// git or the repository at runtime, so the commit is also known in
+// containers.
+
+// buildCommit is set with -ldflags "-X main.buildCommit=<sha>".
+var buildCommit string
+
+type buildDetails struct {
+	Commit    string `json:"commit,omitempty"`
+	CommitAt  string `json:"commit_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	Build     string `json:"build"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+}
+
+var (
+	buildOnce sync.Once
+	build     buildDetails
+)
+
+// buildInfo returns the version, build and commit of this binary.
+func buildInfo() buildDetails {
+	buildOnce.Do(func() {
+		build = buildDetails{
+			Commit:    buildCommit,
+			Build:     buildVersion,
+			Version:   serverVersion,
+			GoVersion: runtime.Version(),
+		}
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if build.Commit == "" {
+					build.Commit = s.Value
+				}
+			case "vcs.time":
+				build.CommitAt = s.Value
+			case "vcs.modified":
+				build.Modified = s.Value == "true"
+			}
+		}
+	})
+	return build
+}
+
+// getGitCommit returns the commit this binary was built from, or "" when
+// it was built without VCS information.
+func getGitCommit() string {
+	return buildInfo().Commit
+}
+
+// shortCommit is the first 12 characters of the build commit.
+func shortCommit() string {
+	c := getGitCommit()
+	if len(c) > 12 {
+		c = c[:12]
+	}
+	return c
+}
+
+// fullVersion is the version string reported to MCP clients, with the
+// commit as semver build metadata (1.0.0+0123456789ab).
+func fullVersion() string {
+	v := serverVersion
+	if c := shortCommit(); c != "" {
+		v += "+" + c
+		if buildInfo().Modified {
+			v += ".dirty"
+		}
+	}
+	return v
+}
+
+var startedAt = time.Now()
+
+// handleHealth handles GET /health for load balancers and deploy checks.
+// It always answers 200 while the process serves HTTP; the database fields
+// say which sources are usable.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":         "ok",
+		"version":        fullVersion(),
+		"build":          buildInfo(),
+		"uptime_seconds": int(time.Since(startedAt).Seconds()),
+		"database":       dbAvailable(),
+		"database_state": dbConn.Stats()["state"],
+		"duckdb":         duckDB != nil,
+		"demo_mode":      demoMode,
+	})
+}
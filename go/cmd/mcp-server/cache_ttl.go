@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Cache lifetimes ────────────────────────────────────────────────────────
+//
+// How long a result stays good depends on the data behind it: the latest
+// sensor reading changes every minute, historical markers only when someone
+// uploads, and reference text practically never. Tools advertise their
+// lifetime in tools/list and in each result's _meta under "safecast/cache",
+// and the REST API sends matching Cache-Control/Expires headers so the
+// CloudFront distribution in front of it can cache instead of passing
+// every request through.
+
+const (
+	ttlNone       = time.Duration(0) // per-caller or write results
+	ttlLive       = 60 * time.Second // latest fixed-sensor readings
+	ttlRecent     = 5 * time.Minute  // sensor lists, recent uploads, histories
+	ttlHistorical = 2 * time.Hour    // marker queries; new uploads still land
+	ttlStats      = 6 * time.Hour    // aggregates over the whole dataset
+	ttlImmutable  = 24 * time.Hour   // one track, spectrum or stored report
+	ttlReference  = 7 * 24 * time.Hour
+)
+
+// toolCacheTTL is the result lifetime per tool. Tools not listed get ttlNone.
+var toolCacheTTL = map[string]time.Duration{
+	"query_radiation":           ttlHistorical,
+	"search_area":               ttlHistorical,
+	"export_csv":                ttlHistorical,
+	"aggregate_area":            ttlHistorical,
+	"list_tracks":               ttlRecent,
+	"get_track":                 ttlImmutable,
+	"device_history":            ttlRecent,
+	"get_spectrum":              ttlImmutable,
+	"list_spectra":              ttlHistorical,
+	"spectrum_roi_rate":         ttlHistorical,
+	"spectrum_dose_check":       ttlHistorical,
+	"radiation_info":            ttlReference,
+	"list_sensors":              ttlRecent,
+	"sensor_current":            ttlLive,
+	"sensor_history":            ttlRecent,
+	"calibration_drift":         ttlStats,
+	"cross_validate_sensor":     ttlStats,
+	"radiation_stats":           ttlStats,
+	"query_extreme_readings":    ttlStats,
+	"top_uploaders":             ttlStats,
+	"search_tracks_by_location": ttlHistorical,
+	"search_safecast":           ttlRecent,
+	"find_duplicate_uploads":    ttlHistorical,
+	"latest_report":             ttlRecent,
+}
+
+// cacheMeta is the "safecast/cache" _meta entry for a tool.
+func cacheMeta(tool string) map[string]any {
+	ttl := toolCacheTTL[tool]
+	return map[string]any{
+		"ttl_seconds": int(ttl.Seconds()),
+		"cacheable":   ttl > 0,
+	}
+}
+
+// annotateCacheTTL is an AfterListTools hook; see annotateToolCosts.
+func annotateCacheTTL(ctx context.Context, id any, req *mcp.ListToolsRequest, result *mcp.ListToolsResult) {
+	for i := range result.Tools {
+		tool := &result.Tools[i]
+		fields := map[string]any{}
+		if tool.Meta != nil {
+			for k, v := range tool.Meta.AdditionalFields {
+				fields[k] = v
+			}
+		}
+		fields["safecast/cache"] = cacheMeta(tool.Name)
+		tool.Meta = mcp.NewMetaFromMap(fields)
+	}
+}
+
+// withCacheTTL stamps a successful tool result with its lifetime.
+func withCacheTTL(tool string, res *mcp.CallToolResult) *mcp.CallToolResult {
+	if res == nil || res.IsError {
+		return res
+	}
+	fields := map[string]any{}
+	if res.Meta != nil {
+		for k, v := range res.Meta.AdditionalFields {
+			fields[k] = v
+		}
+	}
+	fields["safecast/cache"] = cacheMeta(tool)
+	res.Meta = mcp.NewMetaFromMap(fields)
+	return res
+}
+
+// cacheFor wraps a REST handler so successful responses are cacheable for
+// ttl. Errors and ttlNone responses are marked no-store.
+func cacheFor(ttl time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	return cacheBy(func(*http.Request) time.Duration { return ttl }, h)
+}
+
+// cacheBy is cacheFor for routes whose lifetime depends on the path, such
+// as /api/sensor/{id}/current versus /history.
+func cacheBy(ttlFor func(*http.Request) time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ttl := ttlNone
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			ttl = ttlFor(r)
+		}
+		h(&cacheHeaderWriter{ResponseWriter: w, ttl: ttl}, r)
+	}
+}
+
+// cacheHeaderWriter sets the caching headers once the status is known.
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	ttl         time.Duration
+	wroteHeader bool
+}
+
+func (c *cacheHeaderWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		hdr := c.Header()
+		if status == http.StatusOK && c.ttl > 0 {
+			hdr.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(c.ttl.Seconds())))
+			hdr.Set("Expires", time.Now().Add(c.ttl).UTC().Format(http.TimeFormat))
+		} else {
+			hdr.Set("Cache-Control", "no-store")
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush and Unwrap keep streaming handlers working behind the wrapper.
+func (c *cacheHeaderWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		if !c.wroteHeader {
+			c.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (c *cacheHeaderWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// sensorCacheTTL distinguishes /api/sensor/{id}/current from /history.
+func sensorCacheTTL(r *http.Request) time.Duration {
+	if strings.HasSuffix(r.URL.Path, "/history") {
+		return ttlRecent
+	}
+	return ttlLive
+}
+
+// reportCacheTTL: a stored report never changes; "latest" does.
+func reportCacheTTL(r *http.Request) time.Duration {
+	if strings.HasSuffix(r.URL.Path, "/latest") {
+		return ttlRecent
+	}
+	return ttlImmutable
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Citation metadata ──────────────────────────────────────────────────────
+//
+// Academic users cite the data they retrieve, and a citation assembled by a
+// model from memory varies from answer to answer. include_citation: true on
+// a data tool adds a "citation" object with the dataset citation in plain
+// text and BibTeX, the CC0 license note, the retrieval time and the server
+// version, so every citation of data retrieved here reads the same.
+
+const (
+	citationDataset    = "Safecast radiation measurements"
+	citationPublisher  = "Safecast"
+	citationURL        = "https://safecast.org"
+	citationLicense    = "CC0 1.0 Universal (public domain dedication). No permission is needed to use the data; attribution to Safecast is appreciated."
+	citationLicenseURL = "https://creativecommons.org/publicdomain/zero/1.0/"
+	// citationReference is the peer-reviewed description of the project.
+	citationReference = "Brown, A., Franken, P., Bonner, S., Dolezal, N., & Moross, J. (2016). Safecast: successful citizen-science for radiation measurement and communication after Fukushima. Journal of Radiological Protection, 36(2), S82–S101. https://doi.org/10.1088/0952-4746/36/2/S82"
+)
+
+// withCitationParam adds the include_citation flag to a data tool.
+func withCitationParam() mcp.ToolOption {
+	return mcp.WithBoolean("include_citation",
+		mcp.Description("Add a citation object (dataset citation text and BibTeX, CC0 license note, retrieval time, server version) for citing the returned data in publications (default: false)"),
+	)
+}
+
+// attachCitation is a tool middleware that adds the citation to results of
+// calls made with include_citation: true.
+func attachCitation(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res, err := next(ctx, req)
+		if err != nil || !req.GetBool("include_citation", false) {
+			return res, err
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			m["citation"] = citation(req.Params.Name, time.Now().UTC())
+		}), nil
+	}
+}
+
+// citation describes data retrieved by tool at the given time.
+func citation(tool string, retrieved time.Time) map[string]any {
+	source := mapBaseURL()
+	via := fmt.Sprintf("%s %s, tool %s", serverName, fullVersion(), tool)
+	return map[string]any{
+		"text": fmt.Sprintf("%s (%d). %s [Data set]. Retrieved %s from %s via %s. License: CC0 1.0.",
+			citationPublisher, retrieved.Year(), citationDataset, retrieved.Format(time.RFC3339), source, via),
+		"bibtex": fmt.Sprintf("@misc{safecast_%s,\n  author = {{%s}},\n  title = {%s},\n  year = {%d},\n  howpublished = {\\url{%s}},\n  note = {Retrieved %s via %s. License: CC0 1.0}\n}",
+			retrieved.Format("20060102"), citationPublisher, citationDataset, retrieved.Year(), source, retrieved.Format(time.RFC3339), via),
+		"dataset":      citationDataset,
+		"publisher":    citationPublisher,
+		"url":          citationURL,
+		"source":       source,
+		"license":      citationLicense,
+		"license_url":  citationLicenseURL,
+		"retrieved_at": retrieved.Format(time.RFC3339),
+		"server":       map[string]any{"name": serverName, "version": fullVersion(), "build": buildVersion},
+		"reference":    citationReference,
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	serverName    = "safecast-mcp"
+	serverVersion = "1.1.0"
+)
+
+// buildVersion identifies the binary in logs. Bump it on deploys that
+// change behaviour so log lines can be matched to a release, or set it with
+// -ldflags "-X main.buildVersion=...". The commit comes from buildinfo.go.
+var buildVersion = "2026-10-15-1"
+
+type cliOptions struct {
+	version     bool
+	config      bool
+	listTools   bool
+	verifyAudit string
+}
+
+// parseFlags reads the troubleshooting flags. They are meant for checking a
+// Claude Desktop setup from a terminal: each prints to stdout and exits
+// without connecting to any database.
+func parseFlags() cliOptions {
+	var opts cliOptions
+	flag.BoolVar(&opts.version, "version", false, "print version and exit")
+	flag.BoolVar(&opts.config, "config", false, "print configuration summary and exit")
+	flag.BoolVar(&opts.listTools, "list-tools", false, "print registered tools and exit")
+	flag.StringVar(&opts.verifyAudit, "verify-audit", "", "check the signatures and chain of an audit log `file` and exit")
+	flag.Parse()
+	return opts
+}
+
+// setupLogging points the log package away from stdout. MCP_LOG_FILE sends
+// logs to a file (appended); otherwise they go to stderr. In stdio mode
+// os.Stdout is also swapped for stderr so a stray fmt.Print anywhere in the
+// process cannot interleave with JSON-RPC frames; the real stdout is returned
+// for the protocol (or flag output) to use.
+func setupLogging(stdio bool) *os.File {
+	protocolOut := os.Stdout
+
+	var logOut io.Writer = os.Stderr
+	if path := os.Getenv("MCP_LOG_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot open MCP_LOG_FILE %s: %v (logging to stderr)\n", path, err)
+		} else {
+			logOut = f
+		}
+	}
+	log.SetOutput(logOut)
+
+	if stdio {
+		os.Stdout = os.Stderr
+	}
+	return protocolOut
+}
+
+func printVersion(w io.Writer) {
+	commit := getGitCommit()
+	if commit == "" {
+		commit = "unknown commit"
+	}
+	fmt.Fprintf(w, "%s %s (build %s, %s, %s)\n", serverName, serverVersion, buildVersion, commit, runtime.Version())
+}
+
+// printConfigSummary shows the effective configuration with credentials
+// redacted.
+func printConfigSummary(w io.Writer) {
+	transport := os.Getenv("MCP_TRANSPORT")
+	if transport == "" {
+		transport = "http"
+	}
+
+	printVersion(w)
+	fmt.Fprintln(w)
+	rows := [][2]string{
+		{"MCP_TRANSPORT", transport},
+		{"MCP_PORT", envOr("MCP_PORT", "3333")},
+		{"MCP_SOCKET", envOr("MCP_SOCKET", defaultSocketPath)},
+		{"MCP_BASE_URL", envOr("MCP_BASE_URL", "http://localhost:3333")},
+		{"MCP_LOG_FILE", envOr("MCP_LOG_FILE", "(stderr)")},
+		{"MCP_CONFIG_FILE", envOr("MCP_CONFIG_FILE", "(not set)")},
+		{"DATABASE_URL", redactURL(os.Getenv("DATABASE_URL"))},
+		{"DEMO_MODE", strconv.FormatBool(demoModeRequested())},
+		{"DUCKDB_PATH", envOr("DUCKDB_PATH", "./analytics.duckdb")},
+		{"MCP_DUCKDB_READERS", strconv.Itoa(max(intEnv("MCP_DUCKDB_READERS", defaultDuckDBReaders), 1))},
+		{"ANALYTICS_SNAPSHOT_DIR", snapshotDir()},
+		{"SIMPLEMAP_URL", envOr("SIMPLEMAP_URL", "https://simplemap.safecast.org")},
+		{"MAP_BASE_URL", mapBaseURL()},
+		{"MCP_MAX_SESSIONS", strconv.Itoa(limiter.maxSessions)},
+		{"MCP_MAX_CALLS_PER_SESSION", strconv.Itoa(limiter.perSession)},
+		{"MCP_CALL_QUEUE_TIMEOUT", limiter.queueTimeout.String()},
+		{"MCP_SESSION_IDLE_TIMEOUT", limiter.idleTimeout.String()},
+		{"MCP_SESSION_CACHE_TTL", sessionCache.maxTTL.String()},
+		{"MCP_SESSION_CACHE_ENTRIES", strconv.Itoa(sessionCache.maxEntries)},
+		{"MCP_OAUTH_AUTHORIZATION_SERVERS", envOr("MCP_OAUTH_AUTHORIZATION_SERVERS", "(not set, MCP endpoints open)")},
+		{"MCP_OAUTH_INTROSPECTION_URL", envOr("MCP_OAUTH_INTROSPECTION_URL", "(not set)")},
+		{"MCP_ALLOWED_IPS", envOr("MCP_ALLOWED_IPS", "(any)")},
+		{"MCP_ADMIN_ALLOWED_IPS", envOr("MCP_ADMIN_ALLOWED_IPS", "(any)")},
+		{"MCP_ALLOWED_ORIGINS", envOr("MCP_ALLOWED_ORIGINS", "(any)")},
+		{"MCP_TRUSTED_PROXIES", envOr("MCP_TRUSTED_PROXIES", "(none)")},
+		{"MCP_AUDIT_LOG_FILE", envOr("MCP_AUDIT_LOG_FILE", "(not set, audit trail off)")},
+		{"MCP_LOG_SAMPLE_RATE", strconv.FormatFloat(events.sampleRate, 'g', -1, 64)},
+		{"MCP_SLO_WINDOW", slos.window.String()},
+	}
+	for _, r := range rows {
+		fmt.Fprintf(w, "  %-31s %s\n", r[0], r[1])
+	}
+}
+
+func printTools(w io.Writer, s *server.MCPServer) {
+	tools := s.ListTools()
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%d tools registered:\n", len(names))
+	for _, name := range names {
+		desc := tools[name].Tool.Description
+		if i := strings.IndexAny(desc, ".\n"); i > 0 {
+			desc = desc[:i]
+		}
+		fmt.Fprintf(w, "  %-28s %s\n", name, desc)
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+var dsnPasswordRe = regexp.MustCompile(`password=\S+`)
+
+// redactURL hides the password in a connection string.
+func redactURL(raw string) string {
+	if raw == "" {
+		return "(not set)"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		// key=value form: host=... password=...
+		return dsnPasswordRe.ReplaceAllString(raw, "password=xxxxx")
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Compact results ────────────────────────────────────────────────────────
+//
+// A measurement result carries a lot besides the readings: units, map links,
+// routing notes, long hints. That is useful to a model with a large context
+// and too much for one with 8k tokens. compact: true on a measurement tool
+// returns the short-field format of the /api/gpt routes (rest_gpt.go)
+// instead: n, total, src and items with usvh/at/lat/lon, as unindented JSON.
+// Readings in another unit than µSv/h (CPM from fixed sensors) keep their
+// value in v and the unit in u rather than posing as a dose rate.
+
+// compactTools are the tools that take the compact flag.
+var compactTools = map[string]bool{
+	"query_radiation":        true,
+	"search_area":            true,
+	"get_track":              true,
+	"device_history":         true,
+	"sensor_current":         true,
+	"sensor_history":         true,
+	"query_extreme_readings": true,
+}
+
+// compactKeep are the result fields that survive compaction: the notes the
+// model must pass on and the metadata added on request.
+var compactKeep = []string{"_ai_generated_note", "_demo_mode", "citation", "dataset_snapshot", "has_more", "next_cursor", "provenance", "suggestions"}
+
+const compactHint = "usvh: dose rate µSv/h; v/u: value and unit if not µSv/h (CPM = counts per minute); at: UTC time; det: detector; dist_m: metres from the query point. State facts objectively."
+
+// withCompactParam adds the compact flag to a measurement tool.
+func withCompactParam() mcp.ToolOption {
+	return mcp.WithBoolean("compact",
+		mcp.Description("Return only the readings in short fields (usvh, at, lat, lon, det, dist_m) without units, links or metadata, for clients with small context windows (default: false)"),
+	)
+}
+
+// compactResults is a tool middleware that shortens the results of calls
+// made with compact: true.
+func compactResults(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res, err := next(ctx, req)
+		if err != nil || !compactTools[req.Params.Name] || !req.GetBool("compact", false) {
+			return res, err
+		}
+		return compactResult(res), nil
+	}
+}
+
+// compactResult rewrites a measurement result in the short-field format.
+// Results without readings (errors, explain_only plans) are returned as is.
+func compactResult(res *mcp.CallToolResult) *mcp.CallToolResult {
+	if res == nil || res.IsError || len(res.Content) != 1 {
+		return res
+	}
+	tc, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		return res
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(tc.Text), &m); err != nil || measurementRows(m) == nil {
+		return res
+	}
+	c := compactMeasurements(m)
+	out := map[string]any{
+		"n":     c.Count,
+		"src":   c.Src,
+		"items": c.Items,
+	}
+	if c.Total > 0 {
+		out["total"] = c.Total
+	}
+	for _, k := range compactKeep {
+		if v, ok := m[k]; ok {
+			out[k] = v
+		}
+	}
+	out["_ai_hint"] = compactHint
+	data, err := json.Marshal(out)
+	if err != nil {
+		return res
+	}
+	compacted := mcp.NewToolResultText(string(data))
+	compacted.Meta = res.Meta
+	return compacted
+}
+
+// measurementRows returns the readings of a measurement result.
+func measurementRows(m map[string]any) []any {
+	for _, key := range []string{"measurements", "readings"} {
+		if rows, ok := m[key].([]any); ok {
+			return rows
+		}
+	}
+	return nil
+}
+
+// compactMeasurements maps a decoded measurement result to gptResp.
+func compactMeasurements(m map[string]any) gptResp {
+	rows := measurementRows(m)
+	resp := gptResp{Count: len(rows), Items: make([]gptItem, 0, len(rows))}
+	if n, ok := toFloat(m["count"]); ok {
+		resp.Count = int(n)
+	}
+	for _, key := range []string{"total_available", "total_in_bbox"} {
+		if n, ok := toFloat(m[key]); ok {
+			resp.Total = int(n)
+			break
+		}
+	}
+	resp.Src, _ = m["source"].(string)
+
+	for _, row := range rows {
+		r, ok := row.(map[string]any)
+		if !ok {
+			continue
+		}
+		item := gptItem{}
+		item.At = compactTime(r["captured_at"])
+		item.Det, _ = r["detector"].(string)
+		item.Dist, _ = toFloat(r["distance_m"])
+		item.Lat, item.Lon, _ = itemLocation(r)
+		if v, ok := toFloat(r["value"]); ok {
+			unit, _ := r["unit"].(string)
+			if unit == "" || strings.EqualFold(strings.ReplaceAll(unit, "μ", "µ"), "µSv/h") {
+				item.USvH = &v
+			} else {
+				item.Val, item.Unit = &v, unit
+			}
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	return resp
+}
+
+// compactTime writes a timestamp as RFC 3339, the form most results use;
+// DuckDB rows come as Go's time.Time String().
+func compactTime(v any) string {
+	s, _ := v.(string)
+	if t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s); err == nil {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return s
+}
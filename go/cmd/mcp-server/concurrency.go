@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Concurrency limits ─────────────────────────────────────────────────────
+//
+// Everything behind the tools ends up on one Postgres replica. An agent that
+// fans out a dozen parallel tool calls, or a handful of agents doing it at
+// once, can saturate it for everyone else. Two limits keep that in check:
+//
+//   MCP_MAX_SESSIONS           concurrent MCP sessions (new ones get 503)
+//   MCP_MAX_CALLS_PER_SESSION  in-flight tool calls per session; extra calls
+//                              queue for up to MCP_CALL_QUEUE_TIMEOUT
+//
+// A session that has sent nothing for MCP_SESSION_IDLE_TIMEOUT stops
+// counting towards the session limit; streamable HTTP clients often vanish
+// without a DELETE.
+//
+// tool_concurrency in the config file also caps the calls to each tool
+// across all sessions, so many agents running the same heavy scan share a
+// few database connections instead of taking the whole pool. Calls over
+// the cap queue like calls over the session limit.
+
+const (
+	defaultMaxSessions        = 100
+	defaultMaxCallsPerSession = 4
+	defaultCallQueueTimeout   = 30 * time.Second
+	defaultSessionIdleTimeout = 30 * time.Minute
+)
+
+type concurrencyLimiter struct {
+	maxSessions  int // 0 = unlimited
+	perSession   int // 0 = unlimited
+	queueTimeout time.Duration
+	idleTimeout  time.Duration
+
+	mu         sync.Mutex
+	sessions   map[string]time.Time     // streamable HTTP session → last request
+	pending    int                      // initialize requests in progress
+	sseStreams int                      // open /mcp/sse connections
+	calls      map[string]*callSlots    // per-session tool call semaphores
+	tools      map[string]chan struct{} // per-tool semaphores, for capped tools
+
+	rejected struct{ session, tool atomic.Int64 } // calls given up in the queue
+}
+
+type callSlots struct {
+	sem      chan struct{}
+	lastUsed time.Time
+}
+
+var limiter = newConcurrencyLimiterFromEnv()
+
+func newConcurrencyLimiterFromEnv() *concurrencyLimiter {
+	l := &concurrencyLimiter{
+		maxSessions:  intEnv("MCP_MAX_SESSIONS", defaultMaxSessions),
+		perSession:   intEnv("MCP_MAX_CALLS_PER_SESSION", defaultMaxCallsPerSession),
+		queueTimeout: durationEnv("MCP_CALL_QUEUE_TIMEOUT", defaultCallQueueTimeout),
+		idleTimeout:  durationEnv("MCP_SESSION_IDLE_TIMEOUT", defaultSessionIdleTimeout),
+		sessions:     map[string]time.Time{},
+		calls:        map[string]*callSlots{},
+		tools:        map[string]chan struct{}{},
+	}
+	go l.janitor()
+	return l
+}
+
+// active counts sessions that hold a slot. Caller holds mu.
+func (l *concurrencyLimiter) active() int {
+	return len(l.sessions) + l.pending + l.sseStreams
+}
+
+// prune forgets idle sessions and unused call semaphores. Caller holds mu.
+func (l *concurrencyLimiter) prune(now time.Time) {
+	if l.idleTimeout <= 0 {
+		return
+	}
+	for id, seen := range l.sessions {
+		if now.Sub(seen) > l.idleTimeout {
+			delete(l.sessions, id)
+		}
+	}
+	for id, cs := range l.calls {
+		if len(cs.sem) == 0 && now.Sub(cs.lastUsed) > l.idleTimeout {
+			delete(l.calls, id)
+		}
+	}
+}
+
+func (l *concurrencyLimiter) janitor() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for now := range t.C {
+		l.mu.Lock()
+		l.prune(now)
+		l.mu.Unlock()
+	}
+}
+
+// Stats reports current usage for db_info and logs.
+func (l *concurrencyLimiter) Stats() map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inFlight := 0
+	for _, cs := range l.calls {
+		inFlight += len(cs.sem)
+	}
+	out := map[string]any{
+		"active_sessions":       l.active(),
+		"max_sessions":          l.maxSessions,
+		"in_flight_tool_calls":  inFlight,
+		"max_calls_per_session": l.perSession,
+		"rejected_calls":        map[string]int64{"session": l.rejected.session.Load(), "tool": l.rejected.tool.Load()},
+	}
+	if len(l.tools) > 0 {
+		capped := make(map[string]any, len(l.tools))
+		for name, sem := range l.tools {
+			capped[name] = map[string]int{"in_flight": len(sem), "max": cap(sem)}
+		}
+		out["tool_concurrency"] = capped
+	}
+	return out
+}
+
+// toolConcurrency is the configured cap on concurrent calls to tool; 0 is
+// unlimited.
+func toolConcurrency(tool string) int {
+	if n, ok := serverConfig.ToolConcurrency[tool]; ok {
+		return n
+	}
+	return serverConfig.ToolConcurrency["*"]
+}
+
+// toolSem returns the semaphore of a capped tool, or nil.
+func (l *concurrencyLimiter) toolSem(tool string) chan struct{} {
+	n := toolConcurrency(tool)
+	if n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := l.tools[tool]
+	if !ok {
+		sem = make(chan struct{}, n)
+		l.tools[tool] = sem
+	}
+	return sem
+}
+
+// acquire takes a slot of sem, waiting until deadline. ok is false when
+// the deadline passed first.
+func acquire(ctx context.Context, sem chan struct{}, deadline time.Time) (ok bool, err error) {
+	select {
+	case sem <- struct{}{}:
+		return true, nil
+	default:
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+		return false, nil
+	}
+}
+
+func (l *concurrencyLimiter) rejectSession(w http.ResponseWriter) {
+	log.Printf("Rejecting new MCP session: %d/%d sessions active", l.active(), l.maxSessions)
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, "too many concurrent MCP sessions, retry later", http.StatusServiceUnavailable)
+}
+
+// limitSessions wraps the streamable HTTP endpoint. A POST without
+// Mcp-Session-Id starts a session; the ID the server hands back is recorded
+// so later requests keep it alive and DELETE releases it.
+func (l *concurrencyLimiter) limitSessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sid := r.Header.Get("Mcp-Session-Id")
+		now := time.Now()
+
+		// Only IDs the server handed out are tracked; made-up ones pass
+		// through untracked for the transport to reject, so they cannot
+		// fill the session limit.
+		switch {
+		case sid != "" && r.Method == http.MethodDelete:
+			next.ServeHTTP(w, r)
+			l.mu.Lock()
+			_, known := l.sessions[sid]
+			if known {
+				delete(l.sessions, sid)
+				delete(l.calls, sid)
+			}
+			l.mu.Unlock()
+			if known {
+				sessionCache.clear(sid, "")
+			}
+			return
+
+		case sid != "":
+			l.mu.Lock()
+			if _, known := l.sessions[sid]; known {
+				l.sessions[sid] = now
+			}
+			l.mu.Unlock()
+			next.ServeHTTP(w, r)
+			return
+
+		case r.Method != http.MethodPost:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		l.mu.Lock()
+		l.prune(now)
+		if l.maxSessions > 0 && l.active() >= l.maxSessions {
+			l.rejectSession(w)
+			l.mu.Unlock()
+			return
+		}
+		l.pending++
+		l.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+
+		l.mu.Lock()
+		l.pending--
+		if newID := w.Header().Get("Mcp-Session-Id"); newID != "" {
+			l.sessions[newID] = time.Now()
+		}
+		l.mu.Unlock()
+	})
+}
+
+// limitSSEStreams wraps the legacy SSE transport, where a session lives
+// exactly as long as its GET /mcp/sse connection.
+func (l *concurrencyLimiter) limitSSEStreams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/mcp/sse" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		l.mu.Lock()
+		l.prune(time.Now())
+		if l.maxSessions > 0 && l.active() >= l.maxSessions {
+			l.rejectSession(w)
+			l.mu.Unlock()
+			return
+		}
+		l.sseStreams++
+		l.mu.Unlock()
+
+		defer func() {
+			l.mu.Lock()
+			l.sseStreams--
+			l.mu.Unlock()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitToolCalls is a tool middleware that caps in-flight calls per
+// session and per capped tool. Calls over a limit wait for a free slot; if
+// none frees up within queueTimeout the call fails with a retryable tool
+// error instead of piling more load onto the database.
+func (l *concurrencyLimiter) limitToolCalls(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		deadline := time.Now().Add(l.queueTimeout)
+
+		if l.perSession > 0 {
+			sid := "local" // stdio has a single unnamed session
+			if s := server.ClientSessionFromContext(ctx); s != nil && s.SessionID() != "" {
+				sid = s.SessionID()
+			}
+
+			l.mu.Lock()
+			cs, ok := l.calls[sid]
+			if !ok {
+				cs = &callSlots{sem: make(chan struct{}, l.perSession)}
+				l.calls[sid] = cs
+			}
+			cs.lastUsed = time.Now()
+			l.mu.Unlock()
+
+			ok, err := acquire(ctx, cs.sem, deadline)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				l.rejected.session.Add(1)
+				log.Printf("Tool %s: session %s still has %d calls in flight after %s, giving up", req.Params.Name, sid, l.perSession, l.queueTimeout)
+				return mcp.NewToolResultError(fmt.Sprintf(
+					"Server busy: this session already has %d tool calls running. Wait for them to finish, then retry.",
+					l.perSession)), nil
+			}
+			defer func() { <-cs.sem }()
+		}
+
+		if sem := l.toolSem(req.Params.Name); sem != nil {
+			ok, err := acquire(ctx, sem, deadline)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				l.rejected.tool.Add(1)
+				log.Printf("Tool %s: %d calls still in flight across sessions after %s, giving up", req.Params.Name, cap(sem), l.queueTimeout)
+				return mcp.NewToolResultError(fmt.Sprintf(
+					"Server busy: %d calls to %s are already running on this server. Retry in a minute, or use another tool for now.",
+					cap(sem), req.Params.Name)), nil
+			}
+			defer func() { <-sem }()
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// intEnv parses a non-negative integer env var.
+func intEnv(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s=%q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// durationEnv parses a duration env var ("30s", "5m"; "0" disables).
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	if v == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
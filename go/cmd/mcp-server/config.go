@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Config file ────────────────────────────────────────────────────────────
+//
+// Most settings are environment variables. MCP_CONFIG_FILE names an
+// optional JSON file for settings that do not fit in one variable, such as
+// per-tool result limits, event log sample rates (see event_log.go),
+// latency/error objectives (see slo.go) and caps on concurrent calls (see
+// concurrency.go):
+//
+//	{
+//	  "limits": {
+//	    "query_radiation": {"default": 100, "max": 50000},
+//	    "list_tracks":     {"max": 200000}
+//	  },
+//	  "log_sampling": {"sensor_current": 0.1, "ping": 0},
+//	  "slos": {"query_radiation": {"p95_ms": 2000, "error_rate": 0.02}},
+//	  "tool_concurrency": {"*": 8, "radiation_stats": 2}
+//	}
+//
+// Omitted fields keep the compiled-in value. The file is read once at
+// startup; a file that cannot be read or parsed stops the server rather
+// than silently running with the defaults.
+
+type limitConfig struct {
+	Default int `json:"default,omitempty"`
+	Max     int `json:"max,omitempty"`
+}
+
+type fileConfig struct {
+	Limits      map[string]limitConfig `json:"limits,omitempty"`
+	LogSampling map[string]float64     `json:"log_sampling,omitempty"`
+	SLOs        map[string]sloConfig   `json:"slos,omitempty"`
+	// ToolConcurrency caps in-flight calls per tool across all sessions;
+	// "*" applies to every tool without its own entry, 0 is unlimited.
+	ToolConcurrency map[string]int `json:"tool_concurrency,omitempty"`
+}
+
+var serverConfig fileConfig
+
+// loadConfig reads MCP_CONFIG_FILE, if set, into serverConfig.
+func loadConfig() error {
+	path := os.Getenv("MCP_CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	var c fileConfig
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	for tool, l := range c.Limits {
+		if l.Default < 0 || l.Max < 0 || (l.Max > 0 && l.Default > l.Max) {
+			return fmt.Errorf("config file %s: limits for %s must be positive with default <= max", path, tool)
+		}
+	}
+	for tool, r := range c.LogSampling {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("config file %s: log_sampling for %s must be between 0 and 1", path, tool)
+		}
+	}
+	for tool, o := range c.SLOs {
+		if o.P95Ms < 0 || o.ErrorRate < 0 || o.ErrorRate > 1 {
+			return fmt.Errorf("config file %s: slos for %s need p95_ms >= 0 and error_rate between 0 and 1", path, tool)
+		}
+	}
+	for tool, n := range c.ToolConcurrency {
+		if n < 0 {
+			return fmt.Errorf("config file %s: tool_concurrency for %s must not be negative", path, tool)
+		}
+	}
+	serverConfig = c
+	log.Printf("Loaded config file %s", path)
+	return nil
+}
+
+// toolLimits returns the default and maximum limit for tool: the compiled-in
+// values unless the config file overrides them. A configured default above
+// the compiled-in max only makes sense with a configured max, so the max is
+// raised to match.
+func toolLimits(tool string, def, max int) (int, int) {
+	l, ok := serverConfig.Limits[tool]
+	if !ok {
+		return def, max
+	}
+	if l.Max > 0 {
+		max = l.Max
+	}
+	if l.Default > 0 {
+		def = l.Default
+	}
+	if def > max {
+		max = def
+	}
+	return def, max
+}
+
+var limitDescriptionRe = regexp.MustCompile(`\(default: \d+, max: \d+\)`)
+
+// applyConfiguredLimits rewrites the limit parameter of configured tools so
+// tools/list advertises the limits the handlers actually enforce.
+func applyConfiguredLimits(s *server.MCPServer) {
+	names := make([]string, 0, len(serverConfig.Limits))
+	for name := range serverConfig.Limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		st := s.GetTool(name)
+		if st == nil {
+			log.Printf("Config file: limits set for unknown tool %q", name)
+			continue
+		}
+		prop, ok := st.Tool.InputSchema.Properties["limit"].(map[string]any)
+		if !ok {
+			log.Printf("Config file: tool %q has no limit parameter", name)
+			continue
+		}
+		def, _ := toFloat(prop["default"])
+		max, _ := toFloat(prop["maximum"])
+		d, m := toolLimits(name, int(def), int(max))
+
+		patched := make(map[string]any, len(prop))
+		for k, v := range prop {
+			patched[k] = v
+		}
+		if d > 0 {
+			patched["default"] = float64(d)
+		}
+		if m > 0 {
+			patched["maximum"] = float64(m)
+		}
+		if desc, ok := patched["description"].(string); ok {
+			patched["description"] = limitDescriptionRe.ReplaceAllString(desc, fmt.Sprintf("(default: %d, max: %d)", d, m))
+		}
+
+		tool := st.Tool
+		props := make(map[string]any, len(tool.InputSchema.Properties))
+		for k, v := range tool.InputSchema.Properties {
+			props[k] = v
+		}
+		props["limit"] = patched
+		tool.InputSchema.Properties = props
+		s.AddTool(tool, st.Handler)
+	}
+}
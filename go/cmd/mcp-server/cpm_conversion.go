@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── CPM conversion ─────────────────────────────────────────────────────────
+//
+// Fixed sensors with a Geiger tube report counts per minute, and turning
+// CPM into µSv/h takes the tube's calibration factor. Left to the model,
+// that conversion goes wrong all the time: factors get inverted, borrowed
+// from the wrong tube or skipped. The sensor tools therefore convert on
+// the server. The device type (the device_id prefix or the transport)
+// gives the tube, the tube gives the factor, and a reading in CPM comes
+// back as µSv/h with the count rate kept in cpm and the factor used in
+// cpm_per_usvh. Rows of the optional detector_conversion_factors table
+// (migrations/add_detector_conversion_factors.sql) override the built-in
+// factors, for a single device_id, a device type or a tube.
+
+// cpmFactorRefresh is how long factors read from the database are reused.
+const cpmFactorRefresh = 5 * time.Minute
+
+// deviceTubes maps device types, in compactKey form, to the tube they
+// carry, most specific first; a type matches the first key it starts with.
+var deviceTubes = []struct{ key, tube string }{
+	{"geigiecastzen", "LND 7317"},
+	{"bgeigiezen", "LND 7317"},
+	{"geigiecast", "LND 7317"},
+	{"bgeigie", "LND 7317"},
+	{"ngeigie", "LND 7317"},
+	{"pointcast", "LND 7318"},
+	{"solarcast", "LND 7128 EC"},
+	{"radnote", "LND 7128 EC"},
+	{"notehub", "LND 7128 EC"},
+	{"notedev", "LND 7128 EC"},
+}
+
+// tubeFactors are CPM per µSv/h for Cs-137 by tube, in compactKey form,
+// most specific first.
+var tubeFactors = []struct {
+	key    string
+	factor float64
+}{
+	{"lnd7317", 334},
+	{"lnd7318", 334},
+	{"lnd7128", 108},
+	{"lnd712", 108},
+	{"sbm20", 175.43},
+}
+
+// cpmConversion is the factor that applies to one device.
+type cpmConversion struct {
+	Tube       string
+	CPMPerUSvh float64
+	Source     string // "built-in" or "database"
+}
+
+// cpmOverride is a row of detector_conversion_factors.
+type cpmOverride struct {
+	factor float64
+	tube   string
+}
+
+var cpmOverrides struct {
+	mu       sync.Mutex
+	rows     map[string]cpmOverride
+	loadedAt time.Time
+}
+
+// loadCPMOverrides returns the database's factors by compactKey, re-read at
+// most every cpmFactorRefresh. Without a database or the table it is empty.
+func loadCPMOverrides() map[string]cpmOverride {
+	cpmOverrides.mu.Lock()
+	defer cpmOverrides.mu.Unlock()
+	if !dbAvailable() || time.Since(cpmOverrides.loadedAt) < cpmFactorRefresh {
+		return cpmOverrides.rows
+	}
+	cpmOverrides.loadedAt = time.Now()
+
+	// Not the call's context: an explain_only preview should not list this.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := queryRows(ctx, `
+		SELECT detector, cpm_per_usvh, COALESCE(tube, '') AS tube
+		FROM detector_conversion_factors
+		WHERE cpm_per_usvh > 0`)
+	if err != nil {
+		if !strings.Contains(err.Error(), "does not exist") {
+			log.Printf("Reading detector_conversion_factors: %v", err)
+		}
+		return cpmOverrides.rows
+	}
+	loaded := make(map[string]cpmOverride, len(rows))
+	for _, r := range rows {
+		name, _ := r["detector"].(string)
+		factor, ok := toFloat(r["cpm_per_usvh"])
+		if key := compactKey(name); key != "" && ok {
+			tube, _ := r["tube"].(string)
+			loaded[key] = cpmOverride{factor: factor, tube: tube}
+		}
+	}
+	cpmOverrides.rows = loaded
+	return loaded
+}
+
+// lookupCPMFactor finds the CPM per µSv/h factor of a device from its ID
+// and the type, name or tube known for it. Database rows for the device ID,
+// then for its type, then for its tube win over the built-in table.
+func lookupCPMFactor(deviceID, deviceType, deviceName, tube string) (cpmConversion, bool) {
+	overrides := loadCPMOverrides()
+	if o, ok := overrides[compactKey(deviceID)]; ok {
+		return cpmConversion{Tube: o.tube, CPMPerUSvh: o.factor, Source: "database"}, true
+	}
+
+	prefix, _, _ := strings.Cut(deviceID, ":")
+	for _, hint := range []string{deviceType, prefix, deviceName} {
+		key := compactKey(hint)
+		if key == "" {
+			continue
+		}
+		if o, ok := overrides[key]; ok {
+			return cpmConversion{Tube: o.tube, CPMPerUSvh: o.factor, Source: "database"}, true
+		}
+		if tube != "" {
+			continue
+		}
+		for _, d := range deviceTubes {
+			if strings.HasPrefix(key, d.key) {
+				tube = d.tube
+				break
+			}
+		}
+	}
+	if tube == "" {
+		return cpmConversion{}, false
+	}
+
+	key := compactKey(tube)
+	if o, ok := overrides[key]; ok {
+		return cpmConversion{Tube: tube, CPMPerUSvh: o.factor, Source: "database"}, true
+	}
+	for _, t := range tubeFactors {
+		if strings.HasPrefix(key, t.key) {
+			return cpmConversion{Tube: tube, CPMPerUSvh: t.factor, Source: "built-in"}, true
+		}
+	}
+	return cpmConversion{}, false
+}
+
+// isCPMUnit reports whether a (corrected) unit label is counts per minute.
+func isCPMUnit(unit any) bool {
+	s, _ := unit.(string)
+	return strings.EqualFold(strings.TrimSpace(s), "cpm")
+}
+
+// convertSensorCPM converts a realtime reading in CPM to µSv/h. Call it
+// after sensorUnit. value and unit become µSv/h, and cpm, cpm_per_usvh,
+// tube and conversion_source record the conversion. With raw_units the
+// stored value and unit stay and the dose rate is added as usvh. Readings
+// of unknown tubes stay in CPM with a conversion_note.
+func convertSensorCPM(ctx context.Context, reading map[string]any, deviceID string) {
+	unit := reading["unit"]
+	if n, ok := reading["normalized_unit"]; ok {
+		unit = n
+	}
+	if !isCPMUnit(unit) {
+		return
+	}
+	cpm, ok := toFloat(reading["value"])
+	if !ok {
+		return
+	}
+	if id, ok := reading["device_id"].(string); ok && id != "" {
+		deviceID = id
+	}
+	deviceType, _ := reading["type"].(string)
+	deviceName, _ := reading["device_name"].(string)
+	tube, _ := reading["tube"].(string)
+	conv, ok := lookupCPMFactor(deviceID, deviceType, deviceName, tube)
+	if !ok {
+		reading["conversion_note"] = "No CPM per µSv/h factor is known for this device's tube; the value is in CPM and must not be read as µSv/h."
+		return
+	}
+
+	usvh := cpm / conv.CPMPerUSvh
+	reading["cpm"] = cpm
+	reading["cpm_per_usvh"] = conv.CPMPerUSvh
+	reading["conversion_source"] = conv.Source
+	if conv.Tube != "" {
+		reading["tube"] = conv.Tube
+	}
+	if rawUnits(ctx) {
+		reading["usvh"] = usvh
+		return
+	}
+	reading["value"] = usvh
+	reading["unit"] = "µSv/h"
+}
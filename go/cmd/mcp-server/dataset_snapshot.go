@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Dataset snapshots ──────────────────────────────────────────────────────
+//
+// New uploads arrive all the time, so the same query run next month returns
+// different rows. Marker IDs only grow, which makes "every marker with
+// id <= N" a cheap, exact description of the dataset at one moment. Each
+// result of a historical measurement tool carries a dataset_snapshot with a
+// snapshot_id of the form "<max marker id>@<RFC 3339 time>"; passing that
+// snapshot_id back pins the query to the same markers, so an analysis can
+// be rerun and give the same answer.
+//
+// Unpinned calls are bounded by the snapshot they report (captured at most
+// datasetSnapshotRefresh earlier), so the reported snapshot_id reproduces
+// them exactly. Pinned calls only read the database: the simplemap API
+// cannot filter by marker ID. Markers deleted or corrected since the
+// snapshot are not restored; the snapshot pins additions only.
+//
+// These are unrelated to the Parquet analytics snapshots of
+// analytics_snapshot.go.
+
+const datasetSnapshotRefresh = 10 * time.Second
+
+// snapshotTools take snapshot_id and report the snapshot they read.
+var snapshotTools = map[string]bool{
+	"query_radiation":        true,
+	"nearest_readings":       true,
+	"search_area":            true,
+	"export_csv":             true,
+	"get_track":              true,
+	"track_profile":          true,
+	"track_dose_exposure":    true,
+	"dose_contours":          true,
+	"aggregate_area":         true,
+	"compare_areas":          true,
+	"nearest_tracks":         true,
+	"query_extreme_readings": true,
+}
+
+type datasetSnapshot struct {
+	MaxMarkerID int64
+	CapturedAt  time.Time
+}
+
+func (s datasetSnapshot) id() string {
+	return strconv.FormatInt(s.MaxMarkerID, 10) + "@" + s.CapturedAt.UTC().Format(time.RFC3339)
+}
+
+// parseSnapshotID reads a snapshot_id from an earlier result.
+func parseSnapshotID(v string) (datasetSnapshot, error) {
+	idPart, atPart, ok := strings.Cut(strings.TrimSpace(v), "@")
+	maxID, err1 := strconv.ParseInt(idPart, 10, 64)
+	at, err2 := time.Parse(time.RFC3339, atPart)
+	if !ok || err1 != nil || err2 != nil || maxID <= 0 {
+		return datasetSnapshot{}, fmt.Errorf("snapshot_id %q is not valid; copy it from the dataset_snapshot of an earlier result (e.g. 84512345@2026-03-01T12:00:00Z)", v)
+	}
+	if at.After(time.Now().Add(time.Minute)) {
+		return datasetSnapshot{}, fmt.Errorf("snapshot_id %q is in the future", v)
+	}
+	return datasetSnapshot{MaxMarkerID: maxID, CapturedAt: at}, nil
+}
+
+// withSnapshotParam adds the snapshot_id parameter to a historical tool.
+func withSnapshotParam() mcp.ToolOption {
+	return mcp.WithString("snapshot_id",
+		mcp.Description("Pin the query to an earlier state of the dataset: pass the snapshot_id from the dataset_snapshot of a previous result to get exactly the markers that existed then. Reads the database only."),
+	)
+}
+
+// ── Current snapshot ──
+
+var currentSnapshot struct {
+	mu    sync.Mutex
+	snap  datasetSnapshot
+	valid bool
+}
+
+// latestSnapshot returns the newest marker ID, re-read from the database at
+// most every datasetSnapshotRefresh. ok is false without a database.
+func latestSnapshot(ctx context.Context) (datasetSnapshot, bool) {
+	currentSnapshot.mu.Lock()
+	defer currentSnapshot.mu.Unlock()
+	if currentSnapshot.valid && time.Since(currentSnapshot.snap.CapturedAt) < datasetSnapshotRefresh {
+		return currentSnapshot.snap, true
+	}
+	if !dbAvailable() {
+		return datasetSnapshot{}, false
+	}
+	row, err := queryRow(ctx, `SELECT max(id) AS max_id FROM markers`)
+	if err != nil {
+		return currentSnapshot.snap, currentSnapshot.valid
+	}
+	maxID, ok := toFloat(row["max_id"])
+	if !ok {
+		return datasetSnapshot{}, false
+	}
+	currentSnapshot.snap = datasetSnapshot{MaxMarkerID: int64(maxID), CapturedAt: time.Now().UTC().Truncate(time.Second)}
+	currentSnapshot.valid = true
+	return currentSnapshot.snap, true
+}
+
+// ── Pinning ──
+
+type snapshotPinKey struct{}
+
+// snapshotPin is the snapshot a tool call reads. used records whether a
+// query actually applied the bound, so results from other sources are not
+// labelled with it.
+type snapshotPin struct {
+	snap      datasetSnapshot
+	requested bool // snapshot_id was given
+	used      atomic.Bool
+}
+
+func snapshotPinFrom(ctx context.Context) *snapshotPin {
+	p, _ := ctx.Value(snapshotPinKey{}).(*snapshotPin)
+	return p
+}
+
+// snapshotBound is the highest marker ID a query may return: the pinned
+// snapshot's, or math.MaxInt64 when the call is not pinned.
+func snapshotBound(ctx context.Context) int64 {
+	p := snapshotPinFrom(ctx)
+	if p == nil {
+		return math.MaxInt64
+	}
+	p.used.Store(true)
+	return p.snap.MaxMarkerID
+}
+
+// snapshotRequested reports whether the caller pinned a snapshot_id, which
+// rules out sources that cannot apply it.
+func snapshotRequested(ctx context.Context) bool {
+	p := snapshotPinFrom(ctx)
+	return p != nil && p.requested
+}
+
+// snapshotNotApplied tells the pin that the result came from a source that
+// ignored it (the API, a Parquet snapshot).
+func snapshotNotApplied(ctx context.Context) {
+	if p := snapshotPinFrom(ctx); p != nil {
+		p.used.Store(false)
+	}
+}
+
+// pinDatasetSnapshot is a tool middleware that bounds the snapshot tools
+// by a snapshot and reports it in their results.
+func pinDatasetSnapshot(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !snapshotTools[req.Params.Name] {
+			return next(ctx, req)
+		}
+		pin := &snapshotPin{}
+		if v := req.GetString("snapshot_id", ""); v != "" {
+			snap, err := parseSnapshotID(v)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if !dbAvailable() && !duckDBPostgresAttached() {
+				return mcp.NewToolResultError("snapshot_id needs the database, which is not available; the simplemap API cannot be pinned to a snapshot"), nil
+			}
+			pin.snap, pin.requested = snap, true
+		} else if snap, ok := cursorSnapshot(req); ok {
+			// Later pages stay on the snapshot of the first.
+			pin.snap, pin.requested = snap, true
+		} else if snap, ok := latestSnapshot(ctx); ok {
+			pin.snap = snap
+		} else {
+			return next(ctx, req)
+		}
+
+		res, err := next(context.WithValue(ctx, snapshotPinKey{}, pin), req)
+		if err != nil {
+			return res, err
+		}
+		if !pin.used.Load() {
+			if pin.requested && res != nil && !res.IsError {
+				return mcp.NewToolResultError("snapshot_id could not be applied: the answer came from a source that cannot be pinned (the simplemap API or an analytics snapshot). Retry when the database is available."), nil
+			}
+			return res, nil
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			info := map[string]any{
+				"snapshot_id":   pin.snap.id(),
+				"max_marker_id": pin.snap.MaxMarkerID,
+				"captured_at":   pin.snap.CapturedAt.UTC().Format(time.RFC3339),
+				"pinned":        pin.requested,
+			}
+			if pin.requested {
+				info["note"] = "Only markers that existed at captured_at are included. Markers deleted or corrected since then are not restored."
+			} else {
+				info["note"] = "Pass this snapshot_id to the same tool to reproduce this result later."
+			}
+			m["dataset_snapshot"] = info
+		}), nil
+	}
+}
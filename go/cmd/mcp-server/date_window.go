@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Date windows ───────────────────────────────────────────────────────────
+//
+// query_radiation and search_area return the newest markers first, so
+// "radiation near Fukushima in 2012" got whatever was measured last.
+// start_date and end_date (YYYY-MM-DD, UTC, both days included) restrict
+// them to a time window: the database queries bound m.date, and the
+// simplemap API fallback filters the markers it gets back.
+
+// dateWindow is a start_date/end_date filter. end is exclusive, the day
+// after end_date; zero times leave that side open.
+type dateWindow struct {
+	start, end time.Time
+}
+
+// withDateWindowParams adds start_date and end_date to a measurement tool.
+func withDateWindowParams() mcp.ToolOption {
+	start := mcp.WithString("start_date",
+		mcp.Description("Only measurements captured on or after this day (YYYY-MM-DD, UTC)"),
+	)
+	end := mcp.WithString("end_date",
+		mcp.Description("Only measurements captured on or before this day (YYYY-MM-DD, UTC)"),
+	)
+	return func(t *mcp.Tool) {
+		start(t)
+		end(t)
+	}
+}
+
+// parseDateWindow reads start_date and end_date with get, which returns ""
+// for a missing value.
+func parseDateWindow(get func(string) string) (dateWindow, error) {
+	var w dateWindow
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+		days int
+	}{{"start_date", &w.start, 0}, {"end_date", &w.end, 1}} {
+		if v := get(p.name); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return w, fmt.Errorf("%s must be in YYYY-MM-DD format", p.name)
+			}
+			*p.dst = t.AddDate(0, 0, p.days)
+		}
+	}
+	if !w.start.IsZero() && !w.end.IsZero() && !w.start.Before(w.end) {
+		return w, fmt.Errorf("end_date must not be before start_date")
+	}
+	return w, nil
+}
+
+// dateWindowFromRequest reads the window of a tool call.
+func dateWindowFromRequest(req mcp.CallToolRequest) (dateWindow, error) {
+	return parseDateWindow(func(name string) string { return req.GetString(name, "") })
+}
+
+func (w dateWindow) empty() bool {
+	return w.start.IsZero() && w.end.IsZero()
+}
+
+// bounds are the window in Unix seconds for the SQL condition
+// m.date >= from AND m.date < to; open sides are unbounded.
+func (w dateWindow) bounds() (from, to int64) {
+	from, to = math.MinInt64, math.MaxInt64
+	if !w.start.IsZero() {
+		from = w.start.Unix()
+	}
+	if !w.end.IsZero() {
+		to = w.end.Unix()
+	}
+	return from, to
+}
+
+// containsUnix reports whether a capture time in Unix seconds falls in the
+// window.
+func (w dateWindow) containsUnix(sec float64) bool {
+	from, to := w.bounds()
+	return sec >= float64(from) && sec < float64(to)
+}
+
+// contains reports whether an RFC 3339 capture time falls in the window.
+// Unparseable times are outside any window but the empty one.
+func (w dateWindow) contains(captured any) bool {
+	if w.empty() {
+		return true
+	}
+	s, _ := captured.(string)
+	t, err := time.Parse(time.RFC3339, s)
+	return err == nil && w.containsUnix(float64(t.Unix()))
+}
+
+// describe is the window as given, for the result; nil when empty.
+func (w dateWindow) describe() map[string]any {
+	if w.empty() {
+		return nil
+	}
+	d := map[string]any{}
+	if !w.start.IsZero() {
+		d["start_date"] = w.start.Format("2006-01-02")
+	}
+	if !w.end.IsZero() {
+		d["end_date"] = w.end.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return d
+}
+
+// addTo records the window in a result as time_window.
+func (w dateWindow) addTo(result map[string]any) {
+	if d := w.describe(); d != nil {
+		result["time_window"] = d
+	}
+}
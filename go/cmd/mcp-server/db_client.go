@@ -2,43 +2,494 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var db *pgxpool.Pool
+// trackGeometries is set when the per-track geometry cache
+// (migrations/add_track_geometries.sql) exists in the database.
+var trackGeometries atomic.Bool
 
+// trackDoseStats is set when the per-track dose statistics table
+// (migrations/add_track_dose_stats.sql) exists in the database.
+var trackDoseStats atomic.Bool
+
+// ── Database connection ────────────────────────────────────────────────────
+//
+// The pool used to be opened once at startup: if Postgres was down then,
+// the server ran on the API until it was redeployed, and after a restart
+// of Postgres the pool kept failing calls. dbConn owns the connection
+// instead. A health check pings the pool every DB_HEALTH_CHECK_INTERVAL,
+// and a failed query triggers one at once; when the ping fails the
+// database is marked down, so dbAvailable() is false and tools route to
+// the API. While it is down, dbAvailable() starts a reconnection in the
+// background once the backoff has passed (1s, doubling up to
+// DB_RECONNECT_MAX_BACKOFF). A reconnection tries DATABASE_URL and then
+// each of DATABASE_FAILOVER_URLS, so a replica can take over while the
+// first is down, and the first is preferred again at the next
+// reconnection. Every transition is logged.
+//
+// pgxpool's defaults size the pool by CPU count, and an agent fanning out
+// calls could hold every connection the replica allows. DB_MAX_CONNS,
+// DB_MIN_CONNS, DB_MAX_CONN_LIFETIME and DB_MAX_CONN_IDLE_TIME set the
+// pool, overriding pool_* parameters in the connection string; per-tool
+// caps on concurrent calls are in the config file (concurrency.go). Pool
+// usage is on /metrics.
+
+const (
+	dbConnectTimeout    = 10 * time.Second
+	dbPingTimeout       = 5 * time.Second
+	dbMinBackoff        = time.Second
+	dbQueryCheckMinWait = 5 * time.Second
+	defaultDBMaxConns   = 10
+)
+
+// dbPoolSettings size the pool; zero durations keep pgx's defaults.
+type dbPoolSettings struct {
+	maxConns, minConns   int
+	maxLifetime, maxIdle time.Duration
+}
+
+func dbPoolSettingsFromEnv() dbPoolSettings {
+	p := dbPoolSettings{
+		maxConns:    intEnv("DB_MAX_CONNS", defaultDBMaxConns),
+		minConns:    intEnv("DB_MIN_CONNS", 0),
+		maxLifetime: durationEnv("DB_MAX_CONN_LIFETIME", 0),
+		maxIdle:     durationEnv("DB_MAX_CONN_IDLE_TIME", 0),
+	}
+	if p.maxConns < 1 {
+		log.Printf("Invalid DB_MAX_CONNS=%d, using %d", p.maxConns, defaultDBMaxConns)
+		p.maxConns = defaultDBMaxConns
+	}
+	if p.minConns > p.maxConns {
+		log.Printf("DB_MIN_CONNS=%d exceeds DB_MAX_CONNS, using %d", p.minConns, p.maxConns)
+		p.minConns = p.maxConns
+	}
+	return p
+}
+
+func (p dbPoolSettings) apply(cfg *pgxpool.Config) {
+	cfg.MaxConns, cfg.MinConns = int32(p.maxConns), int32(p.minConns)
+	if p.maxLifetime > 0 {
+		cfg.MaxConnLifetime = p.maxLifetime
+	}
+	if p.maxIdle > 0 {
+		cfg.MaxConnIdleTime = p.maxIdle
+	}
+}
+
+type dbState string
+
+const (
+	dbDisconnected dbState = "disconnected"
+	dbConnecting   dbState = "connecting"
+	dbConnected    dbState = "connected"
+)
+
+// dbConnection is the Postgres pool with its health.
+type dbConnection struct {
+	pool atomic.Pointer[pgxpool.Pool]
+
+	mu          sync.Mutex
+	dsns        []string // DATABASE_URL first, then the failover URLs
+	settings    dbPoolSettings
+	targets     []string // host:port/database of each, for logs and stats
+	healthEvery time.Duration
+	maxBackoff  time.Duration
+	state       dbState
+	active      int // index of the connected DSN
+	since       time.Time
+	failures    int // failed connection attempts since the last success
+	nextAttempt time.Time
+	lastErr     string
+	lastCheck   time.Time
+	checking    bool
+	reconnects  int
+	failovers   int
+}
+
+var dbConn = &dbConnection{state: dbDisconnected}
+
+// dbPool is the current pool; nil until the first connection.
+func dbPool() *pgxpool.Pool {
+	return dbConn.pool.Load()
+}
+
+// initDB connects to DATABASE_URL (or a failover URL) and starts the
+// health check. An error means no database was reachable; reconnection
+// goes on in the background.
 func initDB() error {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
 		return fmt.Errorf("DATABASE_URL environment variable is required")
 	}
+	dsns := []string{dsn}
+	for _, u := range strings.Split(os.Getenv("DATABASE_FAILOVER_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			dsns = append(dsns, u)
+		}
+	}
+
+	c := dbConn
+	c.mu.Lock()
+	c.dsns, c.settings = dsns, dbPoolSettingsFromEnv()
+	c.targets = make([]string, len(dsns))
+	for i, d := range dsns {
+		c.targets[i] = fmt.Sprintf("database %d", i+1)
+		if cfg, err := pgconn.ParseConfig(d); err == nil {
+			c.targets[i] = pgTarget(cfg)
+		}
+	}
+	c.healthEvery = durationEnv("DB_HEALTH_CHECK_INTERVAL", 15*time.Second)
+	c.maxBackoff = durationEnv("DB_RECONNECT_MAX_BACKOFF", time.Minute)
+	c.state = dbConnecting
+	c.mu.Unlock()
+
+	err := c.connect()
+	if c.healthEvery > 0 {
+		go c.healthLoop()
+	}
+	return err
+}
+
+// connect tries each DSN in order and swaps in the first pool that
+// answers a ping.
+func (c *dbConnection) connect() error {
+	c.mu.Lock()
+	dsns, targets, settings := c.dsns, c.targets, c.settings
+	c.mu.Unlock()
+
+	var errs []error
+	for i, dsn := range dsns {
+		pool, err := openPool(dsn, settings)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", targets[i], err))
+			continue
+		}
+		c.connected(i, pool)
+		return nil
+	}
+	err := errors.Join(errs...)
+	c.connectFailed(err)
+	return err
+}
 
-	pool, err := pgxpool.New(context.Background(), dsn)
+// openPool opens and pings a pool and checks for the optional tables.
+func openPool(dsn string, settings dbPoolSettings) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	settings.apply(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnectTimeout)
+	defer cancel()
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('public.track_geometries') IS NOT NULL`).Scan(&exists); err != nil {
+		log.Printf("Checking for track_geometries: %v", err)
+	} else if trackGeometries.Store(exists); !exists {
+		log.Println("track_geometries table not found; track area searches collect marker geometries per call (see migrations/add_track_geometries.sql)")
+	}
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('public.track_dose_stats') IS NOT NULL`).Scan(&exists); err != nil {
+		log.Printf("Checking for track_dose_stats: %v", err)
+	} else if trackDoseStats.Store(exists); !exists {
+		log.Println("track_dose_stats table not found; list_tracks dose filters read track maxima from markers per call (see migrations/add_track_dose_stats.sql)")
+	}
+	return pool, nil
+}
+
+func (c *dbConnection) connected(i int, pool *pgxpool.Pool) {
+	c.mu.Lock()
+	down, wasActive, attempts := time.Since(c.since), c.active, c.failures
+	first := c.pool.Load() == nil
+	if !first {
+		c.reconnects++
+	}
+	if i != wasActive {
+		c.failovers++
+	}
+	c.state, c.active, c.since = dbConnected, i, time.Now()
+	c.failures, c.lastErr = 0, ""
+	target := c.targets[i]
+	c.mu.Unlock()
+
+	// Calls still running on the old pool finish before it closes.
+	if old := c.pool.Swap(pool); old != nil {
+		go old.Close()
+	}
+	switch {
+	case first && i == 0 && attempts == 0:
+		log.Printf("database: connected to %s", target)
+	case first && i == 0:
+		log.Printf("database: connected to %s after %d failed attempts", target, attempts)
+	case first:
+		log.Printf("database: connected to failover %s, %s is unreachable", target, c.targets[0])
+	case i != wasActive:
+		log.Printf("database: failed over to %s after %d failed attempts, down %s", target, attempts, down.Round(time.Second))
+	default:
+		log.Printf("database: reconnected to %s after %d failed attempts, down %s", target, attempts, down.Round(time.Second))
+	}
+}
+
+func (c *dbConnection) connectFailed(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures++
+	backoff := min(dbMinBackoff<<min(c.failures-1, 20), c.maxBackoff)
+	c.state, c.lastErr = dbDisconnected, err.Error()
+	c.nextAttempt = time.Now().Add(backoff)
+	if c.failures == 1 && c.since.IsZero() {
+		c.since = time.Now()
+	}
+	log.Printf("database: connection attempt %d failed, retrying in %s: %v", c.failures, backoff, err)
+}
+
+// markDown records that the connected database stopped answering.
+func (c *dbConnection) markDown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != dbConnected {
+		return
+	}
+	c.state, c.since, c.lastErr = dbDisconnected, time.Now(), err.Error()
+	c.nextAttempt = time.Now()
+	log.Printf("database: %s stopped answering, routing to fallbacks until it reconnects: %v", c.targets[c.active], err)
+}
+
+// available reports whether the database is connected. While it is down,
+// it starts a reconnection once the backoff has passed.
+func (c *dbConnection) available() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.state == dbConnected:
+		return true
+	case c.state == dbDisconnected && len(c.dsns) > 0 && !time.Now().Before(c.nextAttempt):
+		c.state = dbConnecting
+		log.Printf("database: reconnecting (attempt %d)", c.failures+1)
+		go c.connect()
+	}
+	return false
+}
+
+// check pings the connected pool and marks the database down when the
+// ping fails. Only one check runs at a time.
+func (c *dbConnection) check() {
+	c.mu.Lock()
+	if c.checking || c.state != dbConnected {
+		c.mu.Unlock()
+		return
+	}
+	c.checking, c.lastCheck = true, time.Now()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.checking = false
+		c.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbPingTimeout)
+	defer cancel()
+	if err := c.pool.Load().Ping(ctx); err != nil {
+		c.markDown(err)
+	}
+}
+
+// queryFailed asks for a health check after a failed query, at most every
+// dbQueryCheckMinWait. Queries fail for many reasons (syntax, timeouts,
+// cancelled calls); the ping tells a lost connection apart.
+func (c *dbConnection) queryFailed(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	c.mu.Lock()
+	due := time.Since(c.lastCheck) >= dbQueryCheckMinWait
+	c.mu.Unlock()
+	if due {
+		go c.check()
+	}
+}
+
+// healthLoop pings the database while it is up and lets available()
+// reconnect it while it is down, so it recovers without traffic.
+func (c *dbConnection) healthLoop() {
+	for range time.Tick(c.healthEvery) {
+		if !c.available() {
+			continue
+		}
+		c.check()
+	}
+}
+
+// Stats reports the connection for db_info and /health.
+func (c *dbConnection) Stats() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.dsns) == 0 {
+		return map[string]any{"state": "not_configured"}
+	}
+	out := map[string]any{
+		"state":      string(c.state),
+		"target":     c.targets[c.active],
+		"reconnects": c.reconnects,
+		"failovers":  c.failovers,
+	}
+	if len(c.targets) > 1 {
+		out["failover_targets"] = c.targets[1:]
+	}
+	if !c.since.IsZero() {
+		out["since"] = c.since.UTC().Format(time.RFC3339)
+	}
+	if c.state != dbConnected {
+		out["failed_attempts"] = c.failures
+		out["last_error"] = c.lastErr
+		if wait := time.Until(c.nextAttempt); wait > 0 {
+			out["next_attempt_seconds"] = math.Ceil(wait.Seconds())
+		}
 	}
+	if pool := c.pool.Load(); pool != nil {
+		st, cfg := pool.Stat(), pool.Config()
+		out["pool"] = map[string]any{
+			"max_conns":          st.MaxConns(),
+			"min_conns":          cfg.MinConns,
+			"max_conn_lifetime":  cfg.MaxConnLifetime.String(),
+			"max_conn_idle_time": cfg.MaxConnIdleTime.String(),
+			"total_conns":        st.TotalConns(),
+			"acquired_conns":     st.AcquiredConns(),
+			"idle_conns":         st.IdleConns(),
+			"acquires":           st.AcquireCount(),
+			"waited_acquires":    st.EmptyAcquireCount(),
+			"avg_acquire_ms":     avgMs(st.AcquireDuration(), st.AcquireCount()),
+		}
+	}
+	return out
+}
+
+func avgMs(total time.Duration, n int64) float64 {
+	if n == 0 {
+		return 0
+	}
+	return math.Round(float64(total.Microseconds())/float64(n)) / 1000
+}
 
-	if err := pool.Ping(context.Background()); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+// writeMetrics adds the connection state and pool usage to /metrics.
+func (c *dbConnection) writeMetrics(w io.Writer) {
+	c.mu.Lock()
+	configured, up := len(c.dsns) > 0, c.state == dbConnected
+	reconnects, failovers := c.reconnects, c.failovers
+	c.mu.Unlock()
+	if !configured {
+		return
 	}
+	fmt.Fprintln(w, "# HELP mcp_db_up 1 while the Postgres connection is healthy.")
+	fmt.Fprintln(w, "# TYPE mcp_db_up gauge")
+	fmt.Fprintf(w, "mcp_db_up %d\n", boolInt(up))
+	fmt.Fprintln(w, "# HELP mcp_db_reconnects_total Reconnections after the database stopped answering.")
+	fmt.Fprintln(w, "# TYPE mcp_db_reconnects_total counter")
+	fmt.Fprintf(w, "mcp_db_reconnects_total %d\n", reconnects)
+	fmt.Fprintln(w, "# HELP mcp_db_failovers_total Connections to a different database URL than the one before.")
+	fmt.Fprintln(w, "# TYPE mcp_db_failovers_total counter")
+	fmt.Fprintf(w, "mcp_db_failovers_total %d\n", failovers)
 
-	db = pool
-	return nil
+	pool := c.pool.Load()
+	if pool == nil {
+		return
+	}
+	st := pool.Stat()
+	gauge := func(name, help string, v int32) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	gauge("mcp_db_pool_max_conns", "Largest number of connections the pool opens (DB_MAX_CONNS).", st.MaxConns())
+	gauge("mcp_db_pool_total_conns", "Open connections, idle, in use or being established.", st.TotalConns())
+	gauge("mcp_db_pool_acquired_conns", "Connections in use by queries.", st.AcquiredConns())
+	gauge("mcp_db_pool_idle_conns", "Idle connections.", st.IdleConns())
+	gauge("mcp_db_pool_constructing_conns", "Connections being established.", st.ConstructingConns())
+	counter("mcp_db_pool_acquires_total", "Connections handed to queries.", st.AcquireCount())
+	counter("mcp_db_pool_empty_acquires_total", "Acquires that waited because every connection was in use.", st.EmptyAcquireCount())
+	counter("mcp_db_pool_canceled_acquires_total", "Acquires given up because the call was cancelled.", st.CanceledAcquireCount())
+	fmt.Fprintln(w, "# HELP mcp_db_pool_acquire_wait_seconds_total Time spent waiting for a connection when none was idle.")
+	fmt.Fprintln(w, "# TYPE mcp_db_pool_acquire_wait_seconds_total counter")
+	fmt.Fprintf(w, "mcp_db_pool_acquire_wait_seconds_total %g\n", st.EmptyAcquireWaitTime().Seconds())
+	counter("mcp_db_pool_new_conns_total", "Connections opened.", st.NewConnsCount())
+	counter("mcp_db_pool_lifetime_closed_total", "Connections closed at DB_MAX_CONN_LIFETIME.", st.MaxLifetimeDestroyCount())
+	counter("mcp_db_pool_idle_closed_total", "Connections closed after DB_MAX_CONN_IDLE_TIME idle.", st.MaxIdleDestroyCount())
+}
+
+// trackGeometrySQL returns the join that gives each upload u a track
+// geometry m.geom and centroid m.centroid: the cached row when the
+// track_geometries table exists, the collected markers otherwise.
+func trackGeometrySQL() string {
+	if trackGeometries.Load() {
+		return `JOIN track_geometries m ON m.track_id = u.track_id`
+	}
+	return `LEFT JOIN LATERAL (
+			SELECT ST_Collect(geom) AS geom, ST_Centroid(ST_Collect(geom)) AS centroid
+			FROM markers
+			WHERE markers.trackid = u.track_id
+		) m ON true`
+}
+
+// trackDoseStatsSQL returns the join that gives each upload u its highest
+// dose rate s.max_dose_usvh: the cached row when the track_dose_stats table
+// exists, the maximum of its markers otherwise.
+func trackDoseStatsSQL() string {
+	if trackDoseStats.Load() {
+		return `JOIN track_dose_stats s ON s.track_id = u.track_id`
+	}
+	return `LEFT JOIN LATERAL (
+			SELECT MAX(doserate) AS max_dose_usvh
+			FROM markers
+			WHERE markers.trackid = u.track_id
+		) s ON true`
 }
 
 func dbAvailable() bool {
-	return db != nil
+	return dbConn.available()
 }
 
 // queryRows executes a query and returns results as a slice of maps.
 func queryRows(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
-	rows, err := db.Query(ctx, query, args...)
+	if p := queryPreviewFrom(ctx); p != nil {
+		p.explain(ctx, query, args) // explain_only (explain.go)
+		return nil, nil
+	}
+	rows, err := dbPool().Query(ctx, query, args...)
 	if err != nil {
+		dbConn.queryFailed(ctx)
 		return nil, err
 	}
+	results, err := collectMaps(rows)
+	recordRead(ctx, sourceDatabase, len(results))
+	return results, err
+}
+
+// collectMaps reads and closes rows, one map per row. Hot paths scan into
+// structs instead (db_rows.go).
+func collectMaps(rows pgx.Rows) ([]map[string]any, error) {
 	defer rows.Close()
 
 	fields := rows.FieldDescriptions()
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fakeRows serves one text-format row n times, decoding through pgx's type
+// map like a real result set.
+type fakeRows struct {
+	typeMap *pgtype.Map
+	fields  []pgconn.FieldDescription
+	raw     [][]byte
+	n, i    int
+}
+
+func newFakeRows(n int, columns ...[2]string) *fakeRows {
+	oids := map[string]uint32{
+		"int8": pgtype.Int8OID, "float8": pgtype.Float8OID, "text": pgtype.TextOID,
+		"bool": pgtype.BoolOID, "timestamptz": pgtype.TimestamptzOID,
+	}
+	r := &fakeRows{typeMap: pgtype.NewMap(), n: n}
+	for _, c := range columns {
+		typ, value, ok := strings.Cut(c[1], "=")
+		var raw []byte
+		if ok {
+			raw = []byte(value)
+		}
+		r.fields = append(r.fields, pgconn.FieldDescription{Name: c[0], DataTypeOID: oids[typ], Format: pgtype.TextFormatCode})
+		r.raw = append(r.raw, raw)
+	}
+	return r
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return r.fields }
+func (r *fakeRows) RawValues() [][]byte                          { return r.raw }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeRows) Next() bool {
+	r.i++
+	return r.i <= r.n
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	return pgx.ScanRow(r.typeMap, r.fields, r.raw, dest...)
+}
+
+func (r *fakeRows) Values() ([]any, error) {
+	values := make([]any, len(r.fields))
+	for i, fd := range r.fields {
+		if r.raw[i] == nil {
+			continue
+		}
+		t, _ := r.typeMap.TypeForOID(fd.DataTypeOID)
+		v, err := t.Codec.DecodeValue(r.typeMap, fd.DataTypeOID, fd.Format, r.raw[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// markerColumns is a queryRadiationSQL row as "type=value" (no value: NULL).
+func markerColumns() [][2]string {
+	return [][2]string{
+		{"id", "int8=123456789"},
+		{"value", "float8=0.0899999976158142"},
+		{"unit", "text=µSv/h"},
+		{"count_rate", "float8=27"},
+		{"captured_at", "timestamptz=2024-05-01 12:34:56+00"},
+		{"latitude", "float8=37.421234567"},
+		{"longitude", "float8=141.032345678"},
+		{"device_id", "text=2301"},
+		{"height", "float8=12.5"},
+		{"detector", "text=bGeigie Nano"},
+		{"trackid", "text=8A1B2C"},
+		{"has_spectrum", "bool=f"},
+		{"distance_m", "float8=412.7"},
+		{"internal_user_id", "text=42"},
+		{"uploader_username", "text=surveyor"},
+		{"uploader_email", "text"},
+	}
+}
+
+func TestScanRowsMarker(t *testing.T) {
+	rows, err := scanRows[markerRow](newFakeRows(2, markerColumns()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	r := rows[1]
+	if r.ID.Int64 != 123456789 || r.Value.Float64 != 0.0899999976158142 || r.TrackID.String != "8A1B2C" {
+		t.Errorf("unexpected row %+v", r)
+	}
+	if want := time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC); !r.CapturedAt.Time.Equal(want) {
+		t.Errorf("captured_at = %v, want %v", r.CapturedAt.Time, want)
+	}
+	if r.UploaderEmail.Valid || nullable(r.UploaderEmail) != nil {
+		t.Errorf("NULL uploader_email scanned as %+v", r.UploaderEmail)
+	}
+	if got := r.uploader(); got == nil || got["username"] != "surveyor" || got["email"] != nil {
+		t.Errorf("uploader = %v", got)
+	}
+}
+
+func BenchmarkScanRows(b *testing.B) {
+	const n = 1000
+	b.Run("maps", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := collectMaps(newFakeRows(n, markerColumns()...)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := scanRows[markerRow](newFakeRows(n, markerColumns()...)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Demo mode ──────────────────────────────────────────────────────────────
+//
+// DEMO_MODE=true without a DATABASE_URL serves a small built-in dataset so
+// the tools can be tried offline, without production credentials:
+//
+//   - three bGeigie tracks (Tokyo, the Fukushima coast, Berlin)
+//   - four fixed sensors with hourly readings for the last 30 days
+//   - three gamma spectra attached to track markers
+//
+// The simplemap client is pointed at an in-process copy of the API serving
+// this data (demo_simplemap.go), DuckDB gets the markers as
+// postgres_db.public.markers so the analytics tools work, and list_sensors,
+// sensor_history and list_spectra answer from the dataset directly. Tools
+// that need Postgres-only tables (uploads, users) still report that a
+// database is required. The data is generated from a fixed seed at start-up;
+// sensor readings end at the current hour so "latest" queries look live.
+// Every result is marked so demo values are never mistaken for real ones.
+
+// demoMode is set by startDemoMode.
+var demoMode bool
+
+const demoNote = "DEMO MODE: this server has no database and serves a small synthetic dataset. The values are invented for trying out the tools and are NOT real Safecast measurements; say so when presenting them."
+
+type demoMarker struct {
+	ID          int64
+	TrackID     string
+	Lat, Lon    float64
+	Altitude    float64
+	DoseRate    float64 // µSv/h
+	CPS         float64
+	SpeedMS     float64
+	Date        int64 // unix seconds
+	Detector    string
+	DeviceID    string
+	HasSpectrum bool
+}
+
+type demoTrack struct {
+	ID      string
+	Index   int
+	Markers []demoMarker
+}
+
+type demoReading struct {
+	ID    int64
+	At    int64 // unix seconds
+	Value float64
+}
+
+type demoSensor struct {
+	ID        string
+	Name      string
+	Transport string
+	Tube      string
+	Lat, Lon  float64
+	Readings  []demoReading
+}
+
+type demoSpectrum struct {
+	ID           int64
+	MarkerID     int64
+	Channels     []int
+	EnergyMinKeV float64
+	EnergyMaxKeV float64
+	LiveTimeSec  float64
+	RealTimeSec  float64
+	DeviceModel  string
+	SourceFormat string
+	Filename     string
+	Calibration  map[string]any
+	CreatedAt    time.Time
+}
+
+type demoDataset struct {
+	Tracks  []demoTrack
+	Sensors []demoSensor
+	Spectra []demoSpectrum
+}
+
+// marker finds a track marker by ID.
+func (d *demoDataset) marker(id int64) (demoMarker, bool) {
+	for _, t := range d.Tracks {
+		for _, m := range t.Markers {
+			if m.ID == id {
+				return m, true
+			}
+		}
+	}
+	return demoMarker{}, false
+}
+
+func (d *demoDataset) sensor(id string) (demoSensor, bool) {
+	for _, s := range d.Sensors {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return demoSensor{}, false
+}
+
+var (
+	demoOnce sync.Once
+	demoSet  *demoDataset
+)
+
+// demoData returns the dataset, generating it on first use.
+func demoData() *demoDataset {
+	demoOnce.Do(func() { demoSet = generateDemoData(time.Now()) })
+	return demoSet
+}
+
+// demoTrackSpec describes a drive: a straight line between two points,
+// sampled every 5 seconds, with an optional hotspot along the way.
+type demoTrackSpec struct {
+	id                 string
+	from, to           [2]float64
+	start              string
+	points             int
+	base               float64 // µSv/h
+	altitude           float64
+	detector, deviceID string
+	hotspot            [2]float64
+	hotspotPeak        float64
+}
+
+var demoTrackSpecs = []demoTrackSpec{
+	// Matches defaultSelfTestTrackID so self_test passes in demo mode.
+	{id: "8eh5m1", from: [2]float64{35.6812, 139.7671}, to: [2]float64{35.6896, 139.7006},
+		start: "2024-03-09T01:12:00Z", points: 240, base: 0.055, altitude: 40,
+		detector: "bGeigie Nano", deviceID: "2113"},
+	{id: "fk6r0u", from: [2]float64{37.3400, 141.0100}, to: [2]float64{37.4900, 140.9900},
+		start: "2023-11-18T03:40:00Z", points: 300, base: 0.32, altitude: 25,
+		detector: "bGeigie Zen", deviceID: "4122",
+		hotspot: [2]float64{37.4100, 141.0050}, hotspotPeak: 2.4},
+	{id: "b3r1in", from: [2]float64{52.5219, 13.4132}, to: [2]float64{52.4731, 13.4039},
+		start: "2024-06-22T09:05:00Z", points: 180, base: 0.085, altitude: 45,
+		detector: "bGeigie Nano", deviceID: "3307"},
+}
+
+var demoSensorSpecs = []struct {
+	id, name, transport, tube string
+	lat, lon, base            float64
+}{
+	{"pointcast:10004", "Pointcast Tokyo Kudan", "pointcast", "LND 7318", 35.6955, 139.7517, 0.048},
+	{"geigiecast:62007", "bGeigie Setagaya", "geigiecast", "LND 7317", 35.6466, 139.6532, 0.044},
+	{"geigiecast-zen:65049", "bGeigieZen Koriyama", "geigiecast-zen", "LND 7317", 37.3985, 140.3593, 0.115},
+	{"solarcast:1027", "Solarcast Berlin Mitte", "solarcast", "LND 7128", 52.5200, 13.4050, 0.082},
+}
+
+// bGeigie LND 7317 conversion factor.
+const demoCPMPerUSvH = 334
+
+func generateDemoData(now time.Time) *demoDataset {
+	rng := rand.New(rand.NewSource(2011))
+	d := &demoDataset{}
+
+	nextMarker := int64(900000001)
+	for i, spec := range demoTrackSpecs {
+		start, _ := time.Parse(time.RFC3339, spec.start)
+		t := demoTrack{ID: spec.id, Index: i + 1}
+		for p := 0; p < spec.points; p++ {
+			f := float64(p) / float64(spec.points-1)
+			lat := spec.from[0] + f*(spec.to[0]-spec.from[0])
+			lon := spec.from[1] + f*(spec.to[1]-spec.from[1])
+			dose := spec.base * (1 + 0.12*rng.NormFloat64())
+			if spec.hotspotPeak > 0 {
+				dist := haversineMeters(lat, lon, spec.hotspot[0], spec.hotspot[1])
+				dose += spec.hotspotPeak * math.Exp(-dist*dist/(2*400*400))
+			}
+			dose = math.Max(dose, spec.base*0.5)
+			m := demoMarker{
+				ID:       nextMarker,
+				TrackID:  spec.id,
+				Lat:      round6(lat),
+				Lon:      round6(lon),
+				Altitude: math.Round(spec.altitude + 3*rng.NormFloat64()),
+				DoseRate: math.Round(dose*1000) / 1000,
+				Date:     start.Add(time.Duration(p*5) * time.Second).Unix(),
+				Detector: spec.detector,
+				DeviceID: spec.deviceID,
+			}
+			m.CPS = math.Round(m.DoseRate*demoCPMPerUSvH/60*100) / 100
+			if p > 0 {
+				prev := t.Markers[p-1]
+				m.SpeedMS = math.Round(haversineMeters(prev.Lat, prev.Lon, m.Lat, m.Lon)/5*10) / 10
+			}
+			t.Markers = append(t.Markers, m)
+			nextMarker++
+		}
+		d.Tracks = append(d.Tracks, t)
+	}
+
+	end := now.UTC().Truncate(time.Hour)
+	nextReading := int64(1)
+	for _, spec := range demoSensorSpecs {
+		s := demoSensor{ID: spec.id, Name: spec.name, Transport: spec.transport, Tube: spec.tube, Lat: spec.lat, Lon: spec.lon}
+		for h := 30 * 24; h >= 0; h-- {
+			at := end.Add(-time.Duration(h) * time.Hour)
+			// A small daily cycle (radon washout peaks in the morning) plus noise,
+			// and a rain shower three days ago: +60% decaying over a few hours.
+			cycle := 0.04 * math.Sin(2*math.Pi*float64(at.Hour()-6)/24)
+			if rain := h - 3*24; rain >= 0 && rain < 4 {
+				cycle += 0.6 * math.Pow(0.35, float64(3-rain))
+			}
+			v := spec.base * (1 + cycle + 0.06*rng.NormFloat64())
+			s.Readings = append(s.Readings, demoReading{ID: nextReading, At: at.Unix(), Value: math.Round(v*1000) / 1000})
+			nextReading++
+		}
+		d.Sensors = append(d.Sensors, s)
+	}
+
+	// Spectra on the Fukushima hotspot, elsewhere on that drive, and in Tokyo.
+	fk := d.Tracks[1].Markers
+	hot := fk[0]
+	for _, m := range fk {
+		if m.DoseRate > hot.DoseRate {
+			hot = m
+		}
+	}
+	picks := []struct {
+		marker demoMarker
+		cesium float64
+		format string
+	}{
+		{hot, 1, "spe"},
+		{fk[len(fk)/6], 0.15, "csv"},
+		{d.Tracks[0].Markers[len(d.Tracks[0].Markers)/2], 0.01, "spe"},
+	}
+	const spectrumModel, spectrumLiveTime = "RadiaCode-102", 600
+	sensitivity, _, _ := detectorSensitivity(spectrumModel)
+	for i, p := range picks {
+		d.markSpectrum(p.marker.ID)
+		d.Spectra = append(d.Spectra, demoSpectrum{
+			ID:           int64(i + 1),
+			MarkerID:     p.marker.ID,
+			Channels:     demoSpectrumChannels(rng, p.cesium, p.marker.DoseRate*sensitivity*spectrumLiveTime),
+			EnergyMinKeV: 0,
+			EnergyMaxKeV: 3000,
+			LiveTimeSec:  spectrumLiveTime,
+			RealTimeSec:  603.4,
+			DeviceModel:  spectrumModel,
+			SourceFormat: p.format,
+			Filename:     fmt.Sprintf("demo_%s_%d.%s", p.marker.TrackID, p.marker.ID, p.format),
+			Calibration:  map[string]any{"a0": 0.0, "a1": 2.93, "a2": 0.0},
+			CreatedAt:    time.Unix(p.marker.Date, 0).UTC().Add(2 * time.Hour),
+		})
+	}
+	return d
+}
+
+func (d *demoDataset) markSpectrum(id int64) {
+	for i := range d.Tracks {
+		for j := range d.Tracks[i].Markers {
+			if d.Tracks[i].Markers[j].ID == id {
+				d.Tracks[i].Markers[j].HasSpectrum = true
+			}
+		}
+	}
+}
+
+// demoSpectrumChannels builds a 1024-channel spectrum: a falling Compton
+// continuum, the K-40 line at 1461 keV, and Cs-137 (662 keV) and Cs-134
+// (605, 796 keV) lines scaled by cesium. It is scaled to about total counts
+// above spectrumDoseThresholdKeV, so that spectrum_dose_check finds the
+// spectrum consistent with its marker.
+func demoSpectrumChannels(rng *rand.Rand, cesium, total float64) []int {
+	const channels, kevPerChannel = 1024, 3000.0 / 1024
+	peak := func(e, center, counts float64) float64 {
+		sigma := 0.03 * center // ~7% FWHM
+		return counts * math.Exp(-(e-center)*(e-center)/(2*sigma*sigma))
+	}
+	shape := make([]float64, channels)
+	var sum float64
+	for c := range shape {
+		e := (float64(c) + 0.5) * kevPerChannel
+		if e < 20 {
+			continue
+		}
+		v := 4000*math.Exp(-e/180) + 60*math.Exp(-e/900)
+		v += peak(e, 1461, 25)
+		v += peak(e, 662, 900*cesium) + peak(e, 605, 350*cesium) + peak(e, 796, 300*cesium)
+		shape[c] = v
+		if e >= spectrumDoseThresholdKeV {
+			sum += v
+		}
+	}
+	out := make([]int, channels)
+	for c, v := range shape {
+		v *= total / sum
+		out[c] = int(math.Max(0, math.Round(v+math.Sqrt(v)*rng.NormFloat64())))
+	}
+	return out
+}
+
+func round6(f float64) float64 { return math.Round(f*1e6) / 1e6 }
+
+// startDemoMode switches to the built-in dataset. Called from main when
+// DEMO_MODE=true and no DATABASE_URL is set.
+func startDemoMode() {
+	demoMode = true
+	d := demoData()
+	client.httpClient.Transport = handlerTransport{newDemoSimplemap(d)}
+	markers := 0
+	for _, t := range d.Tracks {
+		markers += len(t.Markers)
+	}
+	log.Printf("DEMO_MODE: serving the built-in dataset (%d tracks, %d markers, %d sensors, %d spectra)",
+		len(d.Tracks), markers, len(d.Sensors), len(d.Spectra))
+}
+
+func demoModeRequested() bool {
+	return os.Getenv("DEMO_MODE") == "true" && os.Getenv("DATABASE_URL") == ""
+}
+
+// handlerTransport answers HTTP client requests with an in-process handler.
+type handlerTransport struct{ h http.Handler }
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+// attachDemoMarkers loads the demo markers into an in-memory DuckDB
+// database attached as postgres_db, standing in for the Postgres attach.
+func attachDemoMarkers() error {
+	stmts := []string{
+		"ATTACH ':memory:' AS postgres_db",
+		"CREATE SCHEMA postgres_db.public",
+		`CREATE TABLE postgres_db.public.markers (
+			id BIGINT PRIMARY KEY,
+			doserate DOUBLE,
+			countrate DOUBLE,
+			lat DOUBLE,
+			lon DOUBLE,
+			altitude DOUBLE,
+			date BIGINT,
+			device_id VARCHAR,
+			trackid VARCHAR,
+			detector VARCHAR,
+			has_spectrum BOOLEAN
+		)`,
+	}
+	for _, s := range stmts {
+		if _, err := duckDB.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	tx, err := duckDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO postgres_db.public.markers VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, t := range demoData().Tracks {
+		for _, m := range t.Markers {
+			if _, err := stmt.Exec(m.ID, m.DoseRate, m.CPS*60, m.Lat, m.Lon, m.Altitude, m.Date, m.DeviceID, m.TrackID, m.Detector, m.HasSpectrum); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// markDemoResults adds demoNote to every JSON tool result in demo mode.
+func markDemoResults(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res, err := next(ctx, req)
+		if !demoMode || err != nil {
+			return res, err
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			m["_demo_mode"] = demoNote
+		}), nil
+	}
+}
+
+// demoDetectorMatch reports whether value matches the filter name or one
+// of its aliases, like detectorFilter does in SQL.
+func demoDetectorMatch(value, name string) bool {
+	v := compactKey(value)
+	for _, t := range detectorAliases(name) {
+		if strings.Contains(v, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── simplemap API over the demo dataset ────────────────────────────────────
+//
+// The subset of the simplemap REST API that api_client.go calls, answered
+// from a demoDataset in the same JSON shapes and with the same 404s as
+// simplemap.safecast.org. Demo mode wires it into the client through
+// handlerTransport, so no port is opened; the safecast-mock subcommand
+// (mock_simplemap.go) serves it over HTTP.
+
+type demoSimplemap struct {
+	data *demoDataset
+	mux  *http.ServeMux
+}
+
+func newDemoSimplemap(d *demoDataset) *demoSimplemap {
+	s := &demoSimplemap{data: d, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /api/latest", s.latest)
+	s.mux.HandleFunc("GET /get_markers", s.markers)
+	s.mux.HandleFunc("GET /api/tracks", s.tracks)
+	s.mux.HandleFunc("GET /api/tracks/years/{year}", s.tracks)
+	s.mux.HandleFunc("GET /api/tracks/months/{year}/{month}", s.tracks)
+	s.mux.HandleFunc("GET /api/track/{file}", s.track)
+	s.mux.HandleFunc("GET /realtime_history", s.realtimeHistory)
+	s.mux.HandleFunc("GET /api/spectrum/{id}", s.spectrum)
+	return s
+}
+
+func (s *demoSimplemap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func queryFloat(r *http.Request, key string) (float64, bool) {
+	f, err := strconv.ParseFloat(r.URL.Query().Get(key), 64)
+	return f, err == nil
+}
+
+// latestMarker is the /api/latest and /api/track marker shape.
+func latestMarker(m demoMarker) map[string]any {
+	return map[string]any{
+		"id":               m.ID,
+		"doseRateMicroSvH": m.DoseRate,
+		"timeUTC":          time.Unix(m.Date, 0).UTC().Format(time.RFC3339),
+		"lat":              m.Lat,
+		"lon":              m.Lon,
+		"altitudeM":        m.Altitude,
+		"detectorType":     m.Detector,
+		"speedMS":          m.SpeedMS,
+		"countRateCPS":     m.CPS,
+	}
+}
+
+// latest returns markers within radius_m of lat/lon, newest first.
+func (s *demoSimplemap) latest(w http.ResponseWriter, r *http.Request) {
+	lat, ok1 := queryFloat(r, "lat")
+	lon, ok2 := queryFloat(r, "lon")
+	if !ok1 || !ok2 {
+		http.Error(w, "lat and lon are required", http.StatusBadRequest)
+		return
+	}
+	radius, ok := queryFloat(r, "radius_m")
+	if !ok {
+		radius = 1500
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	var found []demoMarker
+	for _, t := range s.data.Tracks {
+		for _, m := range t.Markers {
+			if haversineMeters(lat, lon, m.Lat, m.Lon) <= radius {
+				found = append(found, m)
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Date > found[j].Date })
+	found = found[:min(limit, len(found))]
+
+	out := make([]map[string]any, len(found))
+	for i, m := range found {
+		out[i] = latestMarker(m)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"markers": out})
+}
+
+// markers returns every marker in the bounding box as a bare list.
+func (s *demoSimplemap) markers(w http.ResponseWriter, r *http.Request) {
+	minLat, _ := queryFloat(r, "minLat")
+	maxLat, _ := queryFloat(r, "maxLat")
+	minLon, _ := queryFloat(r, "minLon")
+	maxLon, _ := queryFloat(r, "maxLon")
+
+	out := []map[string]any{}
+	for _, t := range s.data.Tracks {
+		for _, m := range t.Markers {
+			if m.Lat < minLat || m.Lat > maxLat || m.Lon < minLon || m.Lon > maxLon {
+				continue
+			}
+			out = append(out, map[string]any{
+				"id":          m.ID,
+				"doseRate":    m.DoseRate,
+				"lat":         m.Lat,
+				"lon":         m.Lon,
+				"trackID":     m.TrackID,
+				"altitude":    m.Altitude,
+				"detector":    m.Detector,
+				"hasSpectrum": m.HasSpectrum,
+				"date":        m.Date,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// tracks lists track summaries, optionally for one year or month of the
+// first marker. Like simplemap, an empty year or month is a 404.
+func (s *demoSimplemap) tracks(w http.ResponseWriter, r *http.Request) {
+	year, _ := strconv.Atoi(r.PathValue("year"))
+	month, _ := strconv.Atoi(r.PathValue("month"))
+
+	out := []map[string]any{}
+	for _, t := range s.data.Tracks {
+		first := time.Unix(t.Markers[0].Date, 0).UTC()
+		if year != 0 && first.Year() != year {
+			continue
+		}
+		if month != 0 && int(first.Month()) != month {
+			continue
+		}
+		out = append(out, map[string]any{
+			"trackID":     t.ID,
+			"markerCount": len(t.Markers),
+			"firstID":     t.Markers[0].ID,
+			"lastID":      t.Markers[len(t.Markers)-1].ID,
+			"index":       t.Index,
+			"apiURL":      "/api/track/" + t.ID + ".json",
+		})
+	}
+	if year != 0 && len(out) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tracks": out})
+}
+
+// track returns one track's markers, optionally limited to marker IDs
+// from..to.
+func (s *demoSimplemap) track(w http.ResponseWriter, r *http.Request) {
+	id, ok := strings.CutSuffix(r.PathValue("file"), ".json")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	to, _ := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	for _, t := range s.data.Tracks {
+		if t.ID != id {
+			continue
+		}
+		out := []map[string]any{}
+		for _, m := range t.Markers {
+			if (from != 0 && m.ID < from) || (to != 0 && m.ID > to) {
+				continue
+			}
+			out = append(out, latestMarker(m))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"trackID":     t.ID,
+			"markerCount": len(t.Markers),
+			"trackIndex":  t.Index,
+			"markers":     out,
+		})
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// realtimeHistory returns a fixed sensor's dose rate series.
+func (s *demoSimplemap) realtimeHistory(w http.ResponseWriter, r *http.Request) {
+	sensor, ok := s.data.sensor(r.URL.Query().Get("device"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	points := make([]map[string]any, len(sensor.Readings))
+	lo, hi, sum := math.Inf(1), math.Inf(-1), 0.0
+	for i, rd := range sensor.Readings {
+		points[i] = map[string]any{"time": rd.At, "value": rd.Value}
+		lo, hi, sum = math.Min(lo, rd.Value), math.Max(hi, rd.Value), sum+rd.Value
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"deviceName": sensor.Name,
+		"tube":       sensor.Tube,
+		"series":     map[string]any{"doseRate": points},
+		"ranges": map[string]any{
+			"doseRate": map[string]any{"min": lo, "max": hi, "avg": math.Round(sum/float64(len(points))*1000) / 1000},
+		},
+	})
+}
+
+// spectrum returns the spectrum recorded at a marker.
+func (s *demoSimplemap) spectrum(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid marker id %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	for _, sp := range s.data.Spectra {
+		if sp.MarkerID != id {
+			continue
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"channels":     sp.Channels,
+			"channelCount": len(sp.Channels),
+			"energyMinKeV": sp.EnergyMinKeV,
+			"energyMaxKeV": sp.EnergyMaxKeV,
+			"liveTimeSec":  sp.LiveTimeSec,
+			"realTimeSec":  sp.RealTimeSec,
+			"deviceModel":  sp.DeviceModel,
+			"calibration":  sp.Calibration,
+			"sourceFormat": sp.SourceFormat,
+			"filename":     sp.Filename,
+		})
+		return
+	}
+	http.NotFound(w, r)
+}
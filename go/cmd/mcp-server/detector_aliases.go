@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ── Detector and sensor-type aliases ───────────────────────────────────────
+//
+// Detector, transport and device-model strings are free text written by
+// different firmware and upload paths: "bGeigieZen", "BGeigie Zen",
+// "bgeigie-zen" and the realtime transport "geigiecast-zen" are one family.
+// Filters compare both sides with punctuation, spacing and case removed,
+// and expand the user's term to every alias in its family.
+
+// detectorFamilies groups names that refer to the same kind of device, in
+// compactKey form. A filter matching any member matches all of them.
+var detectorFamilies = [][]string{
+	{"bgeigiezen", "zen", "geigiecastzen"},
+	{"bgeigienano", "nano"},
+	{"bgeigieplus"},
+	{"bgeigie", "geigiecast"},
+	{"pointcast"},
+	{"solarcast"},
+	{"ngeigie"},
+	{"radnote", "notehub", "notedev"},
+	{"radiacode", "rc101", "rc102", "rc103"},
+	{"atomspectra", "atomspectrometer"},
+	{"kromek", "gr1"},
+}
+
+// detectorAliases returns the compact search terms for name: the name
+// itself plus the other members of its family.
+func detectorAliases(name string) []string {
+	key := compactKey(name)
+	if key == "" {
+		return nil
+	}
+	terms := []string{key}
+	for _, family := range detectorFamilies {
+		for _, alias := range family {
+			if alias != key {
+				continue
+			}
+			for _, a := range family {
+				if a != key {
+					terms = append(terms, a)
+				}
+			}
+			return terms
+		}
+	}
+	return terms
+}
+
+// detectorFilter returns an SQL condition matching col against name and
+// its aliases. bind adds a parameter and returns its placeholder.
+func detectorFilter(col, name string, bind func(any) string) string {
+	norm := fmt.Sprintf("regexp_replace(lower(COALESCE(%s, '')), '[^a-z0-9]+', '', 'g')", col)
+	terms := detectorAliases(name)
+	conds := make([]string, len(terms))
+	for i, t := range terms {
+		conds[i] = fmt.Sprintf("%s LIKE %s", norm, bind("%"+t+"%"))
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+// pgBinder returns a bind function for detectorFilter that appends to
+// *args and numbers placeholders from *idx, which it advances.
+func pgBinder(args *[]any, idx *int) func(any) string {
+	return func(v any) string {
+		*args = append(*args, v)
+		p := fmt.Sprintf("$%d", *idx)
+		*idx++
+		return p
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── device_history intervals ───────────────────────────────────────────────
+//
+// Raw device_history rows come newest first up to limit, so a year asked
+// of a sensor that reports every minute comes back as its last few hours.
+// With interval set, the measurements of the whole period are aggregated
+// per hour, day or week instead (count, mean, min and max in µSv/h) and
+// every interval of the period is listed, including empty ones, so gaps
+// show as gaps rather than as a shorter period. The database aggregates in
+// SQL; the API's series is bucketed here. Intervals are UTC; weeks start on
+// Monday, as date_trunc does.
+
+var deviceHistoryIntervals = []string{"hour", "day", "week"}
+
+// truncateInterval returns the start of the interval containing t.
+func truncateInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// nextInterval returns the start of the interval after the one starting at t.
+func nextInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// intervalStarts lists the starts of the intervals from start to end,
+// oldest first.
+func intervalStarts(start, end time.Time, interval string) []time.Time {
+	var starts []time.Time
+	for t := truncateInterval(start, interval); !t.After(end); t = nextInterval(t, interval) {
+		starts = append(starts, t)
+	}
+	return starts
+}
+
+// intervalBucket accumulates the measurements of one interval.
+type intervalBucket struct {
+	n             int64
+	sum, min, max float64
+	provenance    string
+}
+
+func (b *intervalBucket) add(n int64, mean, lo, hi float64) {
+	if n <= 0 {
+		return
+	}
+	if b.n == 0 || lo < b.min {
+		b.min = lo
+	}
+	if b.n == 0 || hi > b.max {
+		b.max = hi
+	}
+	b.n += n
+	b.sum += mean * float64(n)
+}
+
+// intervalSeries holds the buckets of a period by interval start.
+type intervalSeries struct {
+	interval string
+	buckets  map[int64]*intervalBucket
+}
+
+func newIntervalSeries(interval string) *intervalSeries {
+	return &intervalSeries{interval: interval, buckets: map[int64]*intervalBucket{}}
+}
+
+func (s *intervalSeries) bucket(t time.Time) *intervalBucket {
+	key := truncateInterval(t, s.interval).Unix()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &intervalBucket{}
+		s.buckets[key] = b
+	}
+	return b
+}
+
+// fill copies the buckets of other into the empty buckets of s, marking
+// them with provenance, and returns how many it filled.
+func (s *intervalSeries) fill(other *intervalSeries, provenance string) int {
+	filled := 0
+	for key, ob := range other.buckets {
+		if ob.n == 0 {
+			continue
+		}
+		if b, ok := s.buckets[key]; ok && b.n > 0 {
+			continue
+		}
+		cp := *ob
+		cp.provenance = provenance
+		s.buckets[key] = &cp
+		filled++
+	}
+	return filled
+}
+
+// rows lists every interval from start to end, newest first.
+func (s *intervalSeries) rows(start, end time.Time, provenance string) ([]map[string]any, int, int64) {
+	starts := intervalStarts(start, end, s.interval)
+	rows := make([]map[string]any, 0, len(starts))
+	withData := 0
+	var total int64
+	for i := len(starts) - 1; i >= 0; i-- {
+		t := starts[i]
+		row := map[string]any{
+			"period_start": t.Format(time.RFC3339),
+			"period_end":   nextInterval(t, s.interval).Format(time.RFC3339),
+			"count":        0,
+		}
+		if b, ok := s.buckets[t.Unix()]; ok && b.n > 0 {
+			row["count"] = b.n
+			row["avg_usvh"] = b.sum / float64(b.n)
+			row["min_usvh"] = b.min
+			row["max_usvh"] = b.max
+			row["provenance"] = provenance
+			if b.provenance != "" {
+				row["provenance"] = b.provenance
+			}
+			withData++
+			total += b.n
+		}
+		rows = append(rows, row)
+	}
+	return rows, withData, total
+}
+
+// addPoints buckets single measurements in µSv/h.
+func (s *intervalSeries) addPoints(points []map[string]any) {
+	for _, p := range points {
+		t, ok := readingTime(p["captured_at"])
+		v, ok2 := toFloat(p["value"])
+		if ok && ok2 {
+			s.bucket(t).add(1, v, v, v)
+		}
+	}
+}
+
+// deviceHistoryIntervalsDB aggregates a device's markers and realtime
+// readings per interval in the database. Realtime readings in CPM are
+// converted with the device's factor (cpm_conversion.go).
+func deviceHistoryIntervalsDB(ctx context.Context, deviceID string, days int, interval string) (*mcp.CallToolResult, error) {
+	now := time.Now().UTC()
+	startDate := now.AddDate(0, 0, -days)
+	series := newIntervalSeries(interval)
+
+	markerRows, err := queryRows(ctx, `
+		SELECT date_trunc($4, to_timestamp(m.date) AT TIME ZONE 'UTC') AS period,
+			count(*) AS n, avg(m.doserate) AS avg_value,
+			min(m.doserate) AS min_value, max(m.doserate) AS max_value
+		FROM markers m
+		WHERE m.device_id = $1 AND m.date >= $2 AND m.date <= $3
+		GROUP BY 1`, deviceID, startDate.Unix(), now.Unix(), interval)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	realtimeRows, err := queryRows(ctx, `
+		SELECT date_trunc($4, to_timestamp(measured_at) AT TIME ZONE 'UTC') AS period,
+			COALESCE(unit, 'µSv/h') AS unit,
+			count(*) AS n, avg(value) AS avg_value,
+			min(value) AS min_value, max(value) AS max_value
+		FROM realtime_measurements
+		WHERE device_id = $1 AND measured_at >= $2 AND measured_at <= $3
+		GROUP BY 1, 2`, deviceID, startDate.Unix(), now.Unix(), interval)
+	if err != nil {
+		return mcp.NewToolResultError("Error querying realtime_measurements table: " + err.Error()), nil
+	}
+
+	for _, r := range markerRows {
+		addIntervalRow(series, r, 1)
+	}
+	var unconverted int64
+	var conversion map[string]any
+	for _, r := range realtimeRows {
+		// The feeds label CPM as cps; sensorUnit corrects the label.
+		unit := map[string]any{}
+		sensorUnit(context.Background(), unit, r["unit"])
+		divisor := 1.0
+		if isCPMUnit(unit["unit"]) {
+			conv, ok := lookupCPMFactor(deviceID, "", "", "")
+			if !ok {
+				n, _ := toFloat(r["n"])
+				unconverted += int64(n)
+				continue
+			}
+			divisor = conv.CPMPerUSvh
+			conversion = map[string]any{"cpm_per_usvh": conv.CPMPerUSvh, "tube": conv.Tube, "conversion_source": conv.Source}
+		}
+		addIntervalRow(series, r, divisor)
+	}
+
+	source := "database"
+	var merge map[string]any
+	if router.healthy(sourceAPI) && !snapshotRequested(ctx) && !explainOnly(ctx) {
+		merge = map[string]any{}
+		resp, err := client.GetRealtimeHistory(ctx, deviceID)
+		switch {
+		case err == nil:
+			router.record(sourceAPI, "")
+			api := newIntervalSeries(interval)
+			api.addPoints(deviceHistoryAPIPoints(resp, startDate))
+			filled := series.fill(api, "api")
+			if filled > 0 {
+				source = "database+api"
+			}
+			merge["api_intervals_filled"] = filled
+			merge["note"] = "Intervals without database measurements are filled from the simplemap API's realtime history where it has points; provenance says which."
+		case isNotFound(err):
+			merge["api"] = "no realtime history for this device"
+		default:
+			router.record(sourceAPI, err.Error())
+			merge["api"] = "unavailable: " + err.Error()
+		}
+	}
+
+	result := deviceHistoryIntervalsResult(series, deviceID, days, startDate, now, source)
+	if merge != nil {
+		result["merge"] = merge
+	}
+	if conversion != nil {
+		result["cpm_conversion"] = conversion
+	}
+	if unconverted > 0 {
+		result["unconverted_cpm_readings"] = unconverted
+		result["conversion_note"] = fmt.Sprintf("%d realtime readings in CPM were left out: no CPM per µSv/h factor is known for this device's tube.", unconverted)
+	}
+	return measurementResult(ctx, result)
+}
+
+// addIntervalRow adds an aggregated database row, dividing its values by
+// divisor to get µSv/h.
+func addIntervalRow(s *intervalSeries, r map[string]any, divisor float64) {
+	t, ok := r["period"].(time.Time)
+	n, ok2 := toFloat(r["n"])
+	mean, ok3 := toFloat(r["avg_value"])
+	lo, _ := toFloat(r["min_value"])
+	hi, _ := toFloat(r["max_value"])
+	if !ok || !ok2 || !ok3 || math.IsNaN(mean) {
+		return
+	}
+	s.bucket(t).add(int64(n), mean/divisor, lo/divisor, hi/divisor)
+}
+
+// deviceHistoryIntervalsAPI buckets the simplemap API's realtime series.
+func deviceHistoryIntervalsAPI(ctx context.Context, deviceID string, days int, interval string) (*mcp.CallToolResult, error) {
+	resp, err := client.GetRealtimeHistory(ctx, deviceID)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	now := time.Now().UTC()
+	startDate := now.AddDate(0, 0, -days)
+	series := newIntervalSeries(interval)
+	series.addPoints(deviceHistoryAPIPoints(resp, startDate))
+
+	result := deviceHistoryIntervalsResult(series, deviceID, days, startDate, now, "api")
+	device := result["device"].(map[string]any)
+	if name, ok := resp["deviceName"].(string); ok && name != "" {
+		device["name"] = name
+	}
+	if tube, ok := resp["tube"].(string); ok && tube != "" {
+		device["sensor"] = tube
+	}
+	return measurementResult(ctx, result)
+}
+
+func deviceHistoryIntervalsResult(series *intervalSeries, deviceID string, days int, start, end time.Time, source string) map[string]any {
+	rows, withData, total := series.rows(start, end, source)
+	return map[string]any{
+		"device": map[string]any{
+			"id": deviceID,
+		},
+		"period": map[string]any{
+			"days":       days,
+			"start_date": start.Format("2006-01-02") + " 00:00",
+			"end_date":   end.Format("2006-01-02") + " 23:59",
+		},
+		"interval":            series.interval,
+		"count":               len(rows),
+		"intervals_with_data": withData,
+		"measurement_count":   total,
+		"source":              source,
+		"intervals":           rows,
+		"coverage_note":       fmt.Sprintf("Every %s of the period is listed, newest first, in UTC. Intervals with count 0 had no measurements; avg_usvh, min_usvh and max_usvh summarize the measurements of the others.", series.interval),
+		"_ai_hint":            "CRITICAL INSTRUCTIONS: (1) Values are dose rates in µSv/h aggregated per interval; realtime readings in CPM were converted with the tube's factor. Intervals with count 0 are gaps in the record, not zero radiation. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. State only objective facts and measurements.",
+		"_ai_generated_note":  "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+}
+
+// checkIntervalCount rejects an interval that splits the period into more
+// than limit intervals.
+func checkIntervalCount(interval string, days, limit int) error {
+	if !slices.Contains(deviceHistoryIntervals, interval) {
+		return fmt.Errorf("interval must be one of: %s", strings.Join(deviceHistoryIntervals, ", "))
+	}
+	now := time.Now().UTC()
+	n := len(intervalStarts(now.AddDate(0, 0, -days), now, interval))
+	if n > limit {
+		return fmt.Errorf("%d days at interval=%s is %d intervals, more than limit=%d; use a longer interval or raise limit", days, interval, n, limit)
+	}
+	return nil
+}
+
+// addHistoryCoverage says how much of the requested period raw rows cover
+// when limit cut them short of its start by more than a day.
+func addHistoryCoverage(result map[string]any, measurements []map[string]any, start time.Time, days, limit int) {
+	if len(measurements) < limit {
+		return
+	}
+	var oldest time.Time
+	for _, m := range measurements {
+		if t, ok := readingTime(m["captured_at"]); ok && (oldest.IsZero() || t.Before(oldest)) {
+			oldest = t
+		}
+	}
+	if oldest.IsZero() || oldest.Sub(start) < 24*time.Hour {
+		return
+	}
+	covered := time.Since(oldest).Hours() / 24
+	result["coverage"] = map[string]any{
+		"complete":       false,
+		"oldest_at":      oldest.UTC().Format(time.RFC3339),
+		"days_covered":   math.Round(covered*10) / 10,
+		"days_requested": days,
+		"note":           fmt.Sprintf("limit cut the result: the newest %d measurements reach back to %s only, not to %s. Pass interval (hour, day or week) to summarize the whole period.", len(measurements), oldest.UTC().Format("2006-01-02 15:04"), start.Format("2006-01-02")),
+	}
+}
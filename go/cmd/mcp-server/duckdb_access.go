@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// ── DuckDB access ──────────────────────────────────────────────────────────
+//
+// One DuckDB file serves two kinds of work: the server's own writes (event
+// log batches, shortlinks, saved queries, annotations, reports) and
+// analytics reads that can run for seconds against the attached replica.
+// With one pooled connection a slow read held up every insert queued
+// behind it, and writes from different goroutines raced each other into
+// transaction conflicts under load. Both pools now share one connector, so
+// there is still a single DuckDB instance and file lock:
+//
+//   duckDB       reads, MCP_DUCKDB_READERS connections (default 4)
+//   duckWriteDB  one connection, used only by the write queue goroutine
+//
+// Writes go through duckExec and duckWriteTx, which queue them for the
+// writer and wait for the outcome, so they run one at a time in order.
+// Reads see a write once it commits. Schema migrations run on duckDB in
+// initDuckDB, before anything else touches the database.
+
+const (
+	defaultDuckDBReaders = 4
+	duckWriteQueueSize   = 256
+)
+
+var duckWriteDB *sql.DB
+
+// openDuckDB opens the read pool and the writer on one connector.
+func openDuckDB(dsn string) (readers, writer *sql.DB, err error) {
+	connector, err := duckdb.NewConnector(dsn, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	readers = sql.OpenDB(connector)
+	n := max(intEnv("MCP_DUCKDB_READERS", defaultDuckDBReaders), 1)
+	readers.SetMaxOpenConns(n)
+	readers.SetMaxIdleConns(n)
+	readers.SetConnMaxLifetime(0)
+
+	writer = sql.OpenDB(connector)
+	writer.SetMaxOpenConns(1)
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxLifetime(0)
+	return readers, writer, nil
+}
+
+type duckWriteJob struct {
+	fn     func(*sql.DB) error
+	queued time.Time
+	done   chan error
+}
+
+type duckWriteQueue struct {
+	jobs  chan duckWriteJob
+	start sync.Once
+
+	executed atomic.Int64
+	failed   atomic.Int64
+	waitedNs atomic.Int64 // total time jobs spent queued
+}
+
+var duckWrites = &duckWriteQueue{jobs: make(chan duckWriteJob, duckWriteQueueSize)}
+
+// run is the writer goroutine.
+func (q *duckWriteQueue) run() {
+	for job := range q.jobs {
+		q.waitedNs.Add(int64(time.Since(job.queued)))
+		err := job.fn(duckWriteDB)
+		q.executed.Add(1)
+		if err != nil {
+			q.failed.Add(1)
+		}
+		job.done <- err
+	}
+}
+
+// submit queues fn for the writer and waits for it to finish.
+func (q *duckWriteQueue) submit(fn func(*sql.DB) error) error {
+	if duckWriteDB == nil {
+		return fmt.Errorf("duckdb not initialized")
+	}
+	q.start.Do(func() { go q.run() })
+	done := make(chan error, 1)
+	q.jobs <- duckWriteJob{fn: fn, queued: time.Now(), done: done}
+	return <-done
+}
+
+// Stats reports the queue's counters for db_info.
+func (q *duckWriteQueue) Stats() map[string]any {
+	executed := q.executed.Load()
+	avgWait := 0.0
+	if executed > 0 {
+		avgWait = float64(q.waitedNs.Load()) / float64(executed) / 1e6
+	}
+	stats := map[string]any{
+		"queued":         len(q.jobs),
+		"queue_capacity": cap(q.jobs),
+		"executed":       executed,
+		"failed":         q.failed.Load(),
+		"avg_wait_ms":    avgWait,
+	}
+	if duckDB != nil {
+		stats["reader_connections"] = duckDB.Stats().MaxOpenConnections
+		stats["readers_in_use"] = duckDB.Stats().InUse
+	}
+	return stats
+}
+
+// duckExec runs one write statement on the writer connection.
+func duckExec(query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := duckWrites.submit(func(db *sql.DB) error {
+		var err error
+		res, err = db.Exec(query, args...)
+		return err
+	})
+	return res, err
+}
+
+// duckWriteTx runs fn in one transaction on the writer connection. The
+// transaction commits when fn returns nil and rolls back otherwise.
+func duckWriteTx(fn func(*sql.Tx) error) error {
+	return duckWrites.submit(func(db *sql.DB) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
@@ -2,16 +2,28 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"time"
-	_ "github.com/marcboeker/go-duckdb"
 )
 
 var duckDB *sql.DB
 
+// duckPostgresAttached is set once DATABASE_URL is attached as postgres_db.
+var duckPostgresAttached bool
+
+func duckDBPostgresAttached() bool {
+	return duckDB != nil && duckPostgresAttached
+}
+
+// duckSpatialLoaded is set once the spatial extension is loaded, enabling
+// ST_* functions in analytics queries (see spatial.go).
+var duckSpatialLoaded bool
+
+func duckDBSpatial() bool {
+	return duckDB != nil && duckSpatialLoaded
+}
+
 func initDuckDB() error {
 
 	// 1. Resolve DuckDB path safely
@@ -23,15 +35,13 @@ func initDuckDB() error {
 	dsn := duckPath + "?access_mode=READ_WRITE"
 
 	var err error
-	duckDB, err = sql.Open("duckdb", dsn)
+	duckDB, duckWriteDB, err = openDuckDB(dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open duckdb: %w", err)
 	}
 
-	// 2. Production-safe connection pool config
-	duckDB.SetMaxOpenConns(1)  // DuckDB works best with single writer
-	duckDB.SetMaxIdleConns(1)
-	duckDB.SetConnMaxLifetime(0)
+	// 2. Connection pools: concurrent readers, one queued writer
+	// (duckdb_access.go)
 
 	if err := duckDB.Ping(); err != nil {
 		return fmt.Errorf("failed to ping duckdb: %w", err)
@@ -50,7 +60,18 @@ func initDuckDB() error {
 		log.Printf("Warning: postgres extension load failed: %v", err)
 	}
 
-	// 5. Attach Postgres if configured
+	// 4b. Load spatial extension (non-fatal; spatial filters then fall back
+	// to PostGIS or are rejected)
+	if _, err := duckDB.Exec("INSTALL spatial;"); err != nil {
+		log.Printf("Warning: spatial extension install failed: %v", err)
+	}
+	if _, err := duckDB.Exec("LOAD spatial;"); err != nil {
+		log.Printf("Warning: spatial extension load failed: %v", err)
+	} else {
+		duckSpatialLoaded = true
+	}
+
+	// 5. Attach Postgres if configured (in demo mode, the demo markers)
 	pgURL := os.Getenv("DATABASE_URL")
 	if pgURL != "" {
 		query := fmt.Sprintf(
@@ -62,24 +83,15 @@ func initDuckDB() error {
 			log.Printf("Warning: failed to attach postgres: %v", err)
 		} else {
 			log.Println("PostgreSQL attached as postgres_db")
+			duckPostgresAttached = true
+		}
+	} else if demoMode {
+		if err := attachDemoMarkers(); err != nil {
+			log.Printf("Warning: failed to load demo markers: %v", err)
+		} else {
+			log.Println("Demo markers attached as postgres_db")
+			duckPostgresAttached = true
 		}
-	}
-
-	// Create sequence first, then the audit log table that references it
-	createSchemaQuery := `
-	CREATE SEQUENCE IF NOT EXISTS seq_query_log;
-	CREATE TABLE IF NOT EXISTS mcp_query_log (
-		id            BIGINT DEFAULT nextval('seq_query_log'),
-		tool_name     VARCHAR,
-		params        JSON,
-		result_count  INTEGER,
-		duration_ms   DOUBLE,
-		client_info   VARCHAR,
-		created_at    TIMESTAMPTZ DEFAULT now()
-	);
-	`
-	if _, err := duckDB.Exec(createSchemaQuery); err != nil {
-		return fmt.Errorf("failed to create audit log schema: %w", err)
 	}
 
 	// 6. Schema version table
@@ -171,35 +183,181 @@ func initDuckDB() error {
 		}
 	}
 
-	log.Println("DuckDB schema ready")
+	// 8. Migration to version 3 (maintainer annotations)
+	if version < 3 {
 
-	return nil
-}
+		log.Println("Running schema migration to v3")
 
-// LogQueryAsync logs a tool execution to DuckDB asynchronously.
-func LogQueryAsync(toolName string, params map[string]any, resultCount int, duration time.Duration, clientInfo string) {
-    if duckDB == nil {
-        return
-    }
-    
-    go func() {
-        // Serialize params as proper JSON for DuckDB's JSON column type.
-        paramsJSON, err := json.Marshal(params)
-        if err != nil {
-            log.Printf("Error marshaling params to JSON: %v", err)
-            return
-        }
-        paramsStr := string(paramsJSON)
+		_, err = duckDB.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS seq_annotations;
+
+		CREATE TABLE IF NOT EXISTS measurement_annotations (
+			id BIGINT DEFAULT nextval('seq_annotations'),
+			target_type TEXT,
+			target_id TEXT,
+			flag TEXT,
+			note TEXT,
+			author TEXT,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);
 
-        _, execErr := duckDB.Exec(`
-            INSERT INTO mcp_query_log (tool_name, params, result_count, duration_ms, client_info)
-            VALUES (?, ?, ?, ?, ?)
-        `, toolName, paramsStr, resultCount, float64(duration.Milliseconds()), clientInfo)
+		CREATE INDEX IF NOT EXISTS idx_annotations_target
+		ON measurement_annotations(target_type, target_id);
 
-        if execErr != nil {
-            log.Printf("Error logging query to DuckDB: %v", execErr)
-        }
-    }()
+		DELETE FROM schema_version;
+
+		INSERT INTO schema_version(version) VALUES (3);
+		`)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 9. Migration to version 4 (saved regions and queries)
+	if version < 4 {
+
+		log.Println("Running schema migration to v4")
+
+		_, err = duckDB.Exec(`
+		CREATE TABLE IF NOT EXISTS saved_regions (
+			name TEXT PRIMARY KEY,
+			kind TEXT,
+			min_lat DOUBLE,
+			max_lat DOUBLE,
+			min_lon DOUBLE,
+			max_lon DOUBLE,
+			polygon JSON,
+			description TEXT,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);
+
+		CREATE TABLE IF NOT EXISTS saved_queries (
+			name TEXT PRIMARY KEY,
+			tool TEXT,
+			arguments JSON,
+			description TEXT,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);
+
+		DELETE FROM schema_version;
+
+		INSERT INTO schema_version(version) VALUES (4);
+		`)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 10. Migration to version 5 (scheduled reports)
+	if version < 5 {
+
+		log.Println("Running schema migration to v5")
+
+		_, err = duckDB.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS seq_reports;
+
+		CREATE TABLE IF NOT EXISTS reports (
+			id BIGINT DEFAULT nextval('seq_reports'),
+			schedule TEXT,
+			title TEXT,
+			generated_at TIMESTAMPTZ,
+			markdown TEXT,
+			html TEXT
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_reports_schedule
+		ON reports(schedule, generated_at);
+
+		CREATE TABLE IF NOT EXISTS report_schedules (
+			name TEXT PRIMARY KEY,
+			title TEXT,
+			every TEXT,
+			saved_queries JSON
+		);
+
+		DELETE FROM schema_version;
+
+		INSERT INTO schema_version(version) VALUES (5);
+		`)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 11. Migration to version 6 (notification subscriptions)
+	if version < 6 {
+
+		log.Println("Running schema migration to v6")
+
+		_, err = duckDB.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS seq_subscriptions;
+
+		CREATE TABLE IF NOT EXISTS notification_subscriptions (
+			id BIGINT DEFAULT nextval('seq_subscriptions'),
+			channel TEXT,
+			target TEXT,
+			schedule TEXT,
+			created_by TEXT,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);
+
+		DELETE FROM schema_version;
+
+		INSERT INTO schema_version(version) VALUES (6);
+		`)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 12. Migration to version 7 (one event table for both tool call logs)
+	if version < 7 {
+
+		log.Println("Running schema migration to v7")
+
+		if _, err = duckDB.Exec(migrateEventLog); err != nil {
+			duckDB.Exec("ROLLBACK")
+			return fmt.Errorf("event log migration failed: %w", err)
+		}
+	}
+
+	// 13. Migration to version 8 (event log sampling)
+	if version < 8 {
+
+		log.Println("Running schema migration to v8")
+
+		if _, err = duckDB.Exec(migrateEventSampling); err != nil {
+			return fmt.Errorf("event log migration failed: %w", err)
+		}
+	}
+
+	// 14. Migration to version 9 (stored results for replay_query)
+	if version < 9 {
+
+		log.Println("Running schema migration to v9")
+
+		if _, err = duckDB.Exec(migrateEventResults); err != nil {
+			return fmt.Errorf("event log migration failed: %w", err)
+		}
+	}
+
+	// 15. Migration to version 10 (map shortlinks)
+	if version < 10 {
+
+		log.Println("Running schema migration to v10")
+
+		if _, err = duckDB.Exec(migrateShortlinks); err != nil {
+			return fmt.Errorf("shortlinks migration failed: %w", err)
+		}
+	}
+
+	if err := annotations.load(); err != nil {
+		log.Printf("Warning: failed to load annotations: %v", err)
+	}
+
+	log.Println("DuckDB schema ready")
+
+	return nil
 }
 
 // Analytics Functions
@@ -211,8 +369,8 @@ func GetToolUsageStats() ([]map[string]any, error) {
     }
     
     rows, err := duckDB.Query(`
-        SELECT tool_name, COUNT(*) as calls, AVG(duration_ms) as avg_duration, MAX(duration_ms) as max_duration
-        FROM mcp_query_log
+        SELECT tool_name, CAST(round(SUM(1 / sample_rate)) AS BIGINT) as calls, AVG(duration_ms) as avg_duration, MAX(duration_ms) as max_duration
+        FROM mcp_events
         GROUP BY tool_name
         ORDER BY calls DESC
     `)
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Event exporters ────────────────────────────────────────────────────────
+//
+// Besides DuckDB, each batch from the event writer (event_log.go) can go to
+// collectors that teams already run:
+//
+//   MCP_EVENT_LOG_FILE                 JSON lines, one logEvent per line,
+//                                      rotated at MCP_EVENT_LOG_MAX_BYTES
+//   OTEL_EXPORTER_OTLP_LOGS_ENDPOINT   OTLP/HTTP logs (JSON encoding); falls
+//   or OTEL_EXPORTER_OTLP_ENDPOINT     back to <endpoint>/v1/logs
+//
+// Both get the same sampled events as the mcp_events table.
+
+type eventExporter interface {
+	name() string
+	export(batch []logEvent) error
+}
+
+const (
+	defaultEventLogMaxBytes = 100 << 20
+	defaultEventLogBackups  = 5
+	otlpExportTimeout       = 10 * time.Second
+)
+
+// configuredExporters builds the exporters selected by the environment.
+func configuredExporters() []eventExporter {
+	var out []eventExporter
+	if path := os.Getenv("MCP_EVENT_LOG_FILE"); path != "" {
+		out = append(out, &jsonlExporter{
+			path:     path,
+			maxBytes: int64(intEnv("MCP_EVENT_LOG_MAX_BYTES", defaultEventLogMaxBytes)),
+			backups:  intEnv("MCP_EVENT_LOG_BACKUPS", defaultEventLogBackups),
+		})
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/logs"
+		}
+	}
+	if endpoint != "" {
+		out = append(out, &otlpExporter{
+			endpoint: endpoint,
+			headers:  otlpHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+			service:  envOr("OTEL_SERVICE_NAME", "safecast-mcp-server"),
+			client:   &http.Client{Timeout: otlpExportTimeout},
+		})
+	}
+	return out
+}
+
+// ── JSON lines ──
+
+type jsonlExporter struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (j *jsonlExporter) name() string { return "jsonl" }
+
+func (j *jsonlExporter) export(batch []logEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range batch {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+	if j.maxBytes > 0 && j.size > 0 && j.size+int64(buf.Len()) > j.maxBytes {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.file.Write(buf.Bytes())
+	j.size += int64(n)
+	return err
+}
+
+func (j *jsonlExporter) open() error {
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.file, j.size = f, info.Size()
+	return nil
+}
+
+// rotate renames path to path.1, path.1 to path.2 and so on, dropping the
+// oldest beyond backups.
+func (j *jsonlExporter) rotate() error {
+	j.file.Close()
+	j.file = nil
+	if j.backups <= 0 {
+		os.Remove(j.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", j.path, j.backups))
+		for i := j.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", j.path, i), fmt.Sprintf("%s.%d", j.path, i+1))
+		}
+		if err := os.Rename(j.path, j.path+".1"); err != nil {
+			return err
+		}
+	}
+	return j.open()
+}
+
+// ── OTLP/HTTP logs ──
+
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+}
+
+func (o *otlpExporter) name() string { return "otlp" }
+
+// otlpHeaders parses OTEL_EXPORTER_OTLP_HEADERS ("k1=v1,k2=v2").
+func otlpHeaders(s string) map[string]string {
+	h := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) != "" {
+			h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return h
+}
+
+func (o *otlpExporter) export(batch []logEvent) error {
+	records := make([]map[string]any, 0, len(batch))
+	for _, ev := range batch {
+		records = append(records, otlpLogRecord(ev))
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{"attributes": []any{
+				otlpAttr("service.name", o.service),
+				otlpAttr("service.version", getGitCommit()),
+			}},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": "safecast-mcp-server/events"},
+				"logRecords": records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpLogRecord maps a logEvent onto an OTLP LogRecord. The attribute keys
+// are the logEvent JSON field names, so queries work across exporters.
+func otlpLogRecord(ev logEvent) map[string]any {
+	severity, severityText := 9, "INFO"
+	if ev.Error != "" {
+		severity, severityText = 17, "ERROR"
+	}
+	attrs := []any{
+		otlpAttr("tool_name", ev.ToolName),
+		otlpAttr("params_hash", ev.ParamsHash),
+		otlpAttr("duration_ms", ev.DurationMs),
+		otlpAttr("result_bytes", ev.ResultBytes),
+		otlpAttr("result_count", ev.ResultCount),
+		otlpAttr("sample_rate", ev.SampleRate),
+	}
+	for k, v := range map[string]string{
+		"session_id":      ev.SessionID,
+		"user_id":         ev.UserID,
+		"user_email":      ev.UserEmail,
+		"generated_query": ev.GeneratedQuery,
+		"client_info":     ev.ClientInfo,
+		"commit_hash":     ev.CommitHash,
+		"error":           ev.Error,
+	} {
+		if v != "" {
+			attrs = append(attrs, otlpAttr(k, v))
+		}
+	}
+	ts := strconv.FormatInt(ev.Timestamp.UnixNano(), 10)
+	return map[string]any{
+		"timeUnixNano":         ts,
+		"observedTimeUnixNano": ts,
+		"severityNumber":       severity,
+		"severityText":         severityText,
+		"body":                 map[string]any{"stringValue": "tool call " + ev.ToolName},
+		"attributes":           attrs,
+	}
+}
+
+// otlpAttr encodes one KeyValue; int64 values are strings in OTLP JSON.
+func otlpAttr(key string, v any) map[string]any {
+	var value map[string]any
+	switch x := v.(type) {
+	case int:
+		value = map[string]any{"intValue": strconv.Itoa(x)}
+	case float64:
+		value = map[string]any{"doubleValue": x}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+	return map[string]any{"key": key, "value": value}
+}
+
+// exportEvents hands a batch to every exporter, counting failures.
+func (w *eventWriter) exportEvents(batch []logEvent) {
+	for _, e := range w.exporters {
+		if err := e.export(batch); err != nil {
+			w.exportFailed.Add(1)
+			log.Printf("Event export to %s failed (%d events): %v", e.name(), len(batch), err)
+		}
+	}
+}
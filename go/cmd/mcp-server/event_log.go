@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Event log ──────────────────────────────────────────────────────────────
+//
+// Every tool call is one row in the DuckDB table mcp_events, written through
+// LogEvent. It replaces mcp_query_log (params, result count) and
+// mcp_ai_query_log (user, session, error), which were written separately
+// for the same call with no shared key; schema migration v7 backfills them
+// and leaves views under the old names for existing queries.
+//
+// Results up to MCP_LOG_RESULT_MAX_BYTES are stored in the result column so
+// replay_query can compare a logged call with a fresh run. The result is
+// kept out of the structured log line and the exporters.
+
+// logEvent is one tool call. The JSON form is the structured log line.
+type logEvent struct {
+	Timestamp      time.Time      `json:"timestamp"`
+	SessionID      string         `json:"session_id,omitempty"`
+	UserID         string         `json:"user_id,omitempty"`
+	UserEmail      string         `json:"user_email,omitempty"`
+	ToolName       string         `json:"tool_name"`
+	Params         map[string]any `json:"-"`
+	ParamsHash     string         `json:"params_hash,omitempty"`
+	GeneratedQuery string         `json:"generated_query,omitempty"`
+	DurationMs     float64        `json:"duration_ms"`
+	ResultBytes    int            `json:"result_bytes"`
+	ResultCount    int            `json:"result_count"`
+	ClientInfo     string         `json:"client_info,omitempty"`
+	CommitHash     string         `json:"commit_hash,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	SampleRate     float64        `json:"sample_rate"`
+	Result         string         `json:"-"`
+}
+
+const defaultLogResultMaxBytes = 64 << 10
+
+var logResultMaxBytes = intEnv("MCP_LOG_RESULT_MAX_BYTES", defaultLogResultMaxBytes)
+
+// toolCallEvent describes a finished tool call.
+func toolCallEvent(ctx context.Context, name string, args map[string]any, res *mcp.CallToolResult, err error, duration time.Duration) logEvent {
+	ev := logEvent{
+		Timestamp:  time.Now().UTC(),
+		ToolName:   name,
+		Params:     args,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+		Error:      errString(err),
+	}
+	ev.UserID, _ = args["user_id"].(string)
+	ev.UserEmail, _ = args["user_email"].(string)
+	if s := server.ClientSessionFromContext(ctx); s != nil {
+		ev.SessionID = s.SessionID()
+		if ci, ok := s.(server.SessionWithClientInfo); ok {
+			ev.ClientInfo = ci.GetClientInfo().Name
+		}
+	}
+	if res != nil {
+		ev.ResultCount = len(res.Content)
+		ev.ResultBytes = resultSize(res)
+		if res.IsError && ev.Error == "" {
+			ev.Error = sourceFailure(res, nil)
+		}
+		if ev.ResultBytes <= logResultMaxBytes {
+			ev.Result = resultText(res)
+		}
+	}
+	return ev
+}
+
+// paramsHash identifies calls with the same arguments. json.Marshal sorts
+// map keys, so equal arguments hash equally; the migration computes the
+// same hash in SQL over the stored JSON text.
+func paramsHash(paramsJSON string) string {
+	sum := sha256.Sum256([]byte(paramsJSON))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// ── Sampling and batching ──
+//
+// Under heavy agent traffic an insert per call competes with the analytics
+// queries for DuckDB's single connection. LogEvent therefore only samples
+// and enqueues; one writer goroutine inserts in batches of
+// MCP_LOG_BATCH_SIZE or every MCP_LOG_FLUSH_INTERVAL, whichever comes
+// first. When the queue (MCP_LOG_BUFFER) is full the event is dropped and
+// counted rather than blocking the tool call.
+//
+// MCP_LOG_SAMPLE_RATE (0 to 1) keeps that fraction of successful calls;
+// the config file's log_sampling overrides it per tool. Failed calls are
+// always kept. Each row stores its sample_rate, so SUM(1 / sample_rate)
+// estimates the real call count.
+
+const (
+	defaultLogBatchSize     = 100
+	defaultLogFlushInterval = 2 * time.Second
+	defaultLogBuffer        = 1000
+)
+
+type eventWriter struct {
+	sampleRate float64
+	batchSize  int
+	flushEvery time.Duration
+	queue      chan logEvent
+	exporters  []eventExporter
+	start      sync.Once
+
+	written      atomic.Int64
+	dropped      atomic.Int64
+	sampledOut   atomic.Int64
+	failed       atomic.Int64
+	exportFailed atomic.Int64
+}
+
+var events = &eventWriter{
+	sampleRate: sampleRateEnv("MCP_LOG_SAMPLE_RATE", 1),
+	batchSize:  max(intEnv("MCP_LOG_BATCH_SIZE", defaultLogBatchSize), 1),
+	flushEvery: durationEnv("MCP_LOG_FLUSH_INTERVAL", defaultLogFlushInterval),
+	queue:      make(chan logEvent, max(intEnv("MCP_LOG_BUFFER", defaultLogBuffer), 1)),
+	exporters:  configuredExporters(),
+}
+
+// sampleRateEnv parses a rate between 0 and 1.
+func sampleRateEnv(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	r, err := strconv.ParseFloat(v, 64)
+	if err != nil || r < 0 || r > 1 {
+		log.Printf("Invalid %s=%q, using %g", key, v, def)
+		return def
+	}
+	return r
+}
+
+// rateFor is the sample rate for a tool.
+func (w *eventWriter) rateFor(tool string) float64 {
+	if r, ok := serverConfig.LogSampling[tool]; ok {
+		return r
+	}
+	return w.sampleRate
+}
+
+// LogEvent records a tool call asynchronously; it never blocks the caller.
+func LogEvent(ev logEvent) {
+	events.log(ev)
+}
+
+func (w *eventWriter) log(ev logEvent) {
+	ev.SampleRate = 1
+	if ev.Error == "" {
+		ev.SampleRate = w.rateFor(ev.ToolName)
+		if ev.SampleRate < 1 && rand.Float64() >= ev.SampleRate {
+			w.sampledOut.Add(1)
+			return
+		}
+	}
+	w.start.Do(func() { go w.run() })
+	select {
+	case w.queue <- ev:
+	default:
+		if w.dropped.Add(1)%1000 == 1 {
+			log.Printf("Event log queue full (%d events); dropping events", cap(w.queue))
+		}
+	}
+}
+
+// run is the writer goroutine.
+func (w *eventWriter) run() {
+	batch := make([]logEvent, 0, w.batchSize)
+	var tick <-chan time.Time
+	if w.flushEvery > 0 {
+		t := time.NewTicker(w.flushEvery)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case ev := <-w.queue:
+			batch = append(batch, ev)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-tick:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		w.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush prints and exports a batch and inserts it in one transaction.
+func (w *eventWriter) flush(batch []logEvent) {
+	rows := make([]eventRow, 0, len(batch))
+	ready := make([]logEvent, 0, len(batch))
+	for _, ev := range batch {
+		if ev.CommitHash == "" {
+			ev.CommitHash = getGitCommit()
+		}
+		paramsJSON := "{}"
+		if ev.Params != nil {
+			b, err := json.Marshal(ev.Params)
+			if err != nil {
+				log.Printf("Error marshaling params to JSON: %v", err)
+				continue
+			}
+			paramsJSON = string(b)
+		}
+		ev.ParamsHash = paramsHash(paramsJSON)
+
+		if data, err := json.Marshal(ev); err == nil {
+			log.Println(string(data))
+		}
+		rows = append(rows, eventRow{ev, paramsJSON})
+		ready = append(ready, ev)
+	}
+	w.exportEvents(ready)
+	if duckDB == nil {
+		return
+	}
+	if err := insertEvents(rows); err != nil {
+		w.failed.Add(int64(len(rows)))
+		log.Printf("Error logging %d events to DuckDB: %v", len(rows), err)
+		return
+	}
+	w.written.Add(int64(len(rows)))
+}
+
+// Stats reports the writer's counters for db_info.
+func (w *eventWriter) Stats() map[string]any {
+	return map[string]any{
+		"sample_rate":     w.sampleRate,
+		"tool_rates":      serverConfig.LogSampling,
+		"batch_size":      w.batchSize,
+		"flush_interval":  w.flushEvery.String(),
+		"queued":          len(w.queue),
+		"queue_capacity":  cap(w.queue),
+		"written":         w.written.Load(),
+		"dropped":         w.dropped.Load(),
+		"sampled_out":     w.sampledOut.Load(),
+		"failed":          w.failed.Load(),
+		"exporters":       exporterNames(w.exporters),
+		"export_failures": w.exportFailed.Load(),
+	}
+}
+
+func exporterNames(exporters []eventExporter) []string {
+	names := []string{}
+	for _, e := range exporters {
+		names = append(names, e.name())
+	}
+	return names
+}
+
+type eventRow struct {
+	ev         logEvent
+	paramsJSON string
+}
+
+func insertEvents(rows []eventRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return duckWriteTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+			INSERT INTO mcp_events (
+				created_at, session_id, user_id, user_email, tool_name,
+				params, params_hash, generated_query, duration_ms,
+				result_bytes, result_count, client_info, commit_hash, error, sample_rate, result
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, r := range rows {
+			ev := r.ev
+			if _, err := stmt.Exec(
+				ev.Timestamp, nullIfEmpty(ev.SessionID), nullIfEmpty(ev.UserID), nullIfEmpty(ev.UserEmail), ev.ToolName,
+				r.paramsJSON, ev.ParamsHash, nullIfEmpty(ev.GeneratedQuery), ev.DurationMs,
+				ev.ResultBytes, ev.ResultCount, nullIfEmpty(ev.ClientInfo), nullIfEmpty(ev.CommitHash), nullIfEmpty(ev.Error), ev.SampleRate, nullIfEmpty(ev.Result),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// migrateEventLog creates mcp_events, backfills it from the two old log
+// tables and replaces them with views. Rows of the two tables are paired
+// by tool name and time: the AI log row was written just after the query
+// log row for the same call.
+const migrateEventLog = `
+	BEGIN TRANSACTION;
+
+	CREATE SEQUENCE IF NOT EXISTS seq_events;
+
+	CREATE TABLE IF NOT EXISTS mcp_events (
+		id              BIGINT DEFAULT nextval('seq_events'),
+		created_at      TIMESTAMPTZ,
+		session_id      TEXT,
+		user_id         TEXT,
+		user_email      TEXT,
+		tool_name       TEXT,
+		params          JSON,
+		params_hash     TEXT,
+		generated_query TEXT,
+		duration_ms     DOUBLE,
+		result_bytes    BIGINT,
+		result_count    INTEGER,
+		client_info     TEXT,
+		commit_hash     TEXT,
+		error           TEXT
+	);
+
+	INSERT INTO mcp_events (
+		created_at, session_id, user_id, user_email, tool_name,
+		params, params_hash, generated_query, duration_ms,
+		result_count, client_info, commit_hash, error
+	)
+	SELECT
+		created_at,
+		CASE WHEN paired THEN session_id END,
+		CASE WHEN paired THEN NULLIF(user_id, '') END,
+		CASE WHEN paired THEN NULLIF(user_email, '') END,
+		tool_name,
+		params,
+		left(sha256(COALESCE(CAST(params AS VARCHAR), '{}')), 16),
+		CASE WHEN paired THEN NULLIF(generated_query, '') END,
+		duration_ms,
+		result_count,
+		client_info,
+		CASE WHEN paired THEN NULLIF(commit_hash, '') END,
+		CASE WHEN paired THEN NULLIF(error, '') END
+	FROM (
+		SELECT
+			q.created_at, q.tool_name, q.params, q.duration_ms, q.result_count, q.client_info,
+			a.session_id, a.user_id, a.user_email, a.generated_query, a.commit_hash, a.error,
+			a.timestamp IS NOT NULL
+				AND a.timestamp < q.created_at::TIMESTAMP + INTERVAL 5 SECOND AS paired
+		FROM mcp_query_log q
+		ASOF LEFT JOIN mcp_ai_query_log a
+			ON a.tool_name = q.tool_name AND a.timestamp >= q.created_at::TIMESTAMP
+	) j
+	ORDER BY created_at;
+
+	CREATE INDEX IF NOT EXISTS idx_events_created ON mcp_events(created_at);
+	CREATE INDEX IF NOT EXISTS idx_events_tool ON mcp_events(tool_name);
+	CREATE INDEX IF NOT EXISTS idx_events_user ON mcp_events(user_id);
+
+	DROP TABLE mcp_query_log;
+	DROP TABLE mcp_ai_query_log;
+
+	CREATE VIEW mcp_query_log AS
+	SELECT id, tool_name, params, result_count, duration_ms, client_info, created_at
+	FROM mcp_events;
+
+	CREATE VIEW mcp_ai_query_log AS
+	SELECT user_id, user_email, session_id, created_at::TIMESTAMP AS timestamp,
+		tool_name, generated_query, CAST(duration_ms AS BIGINT) AS duration_ms,
+		commit_hash, error
+	FROM mcp_events;
+
+	DELETE FROM schema_version;
+
+	INSERT INTO schema_version(version) VALUES (7);
+
+	COMMIT;
+`
+
+// migrateEventSampling records each row's sample rate; older rows were
+// all kept.
+const migrateEventSampling = `
+	ALTER TABLE mcp_events ADD COLUMN IF NOT EXISTS sample_rate DOUBLE DEFAULT 1;
+
+	DELETE FROM schema_version;
+
+	INSERT INTO schema_version(version) VALUES (8);
+`
+
+// migrateEventResults stores results for replay_query.
+const migrateEventResults = `
+	ALTER TABLE mcp_events ADD COLUMN IF NOT EXISTS result TEXT;
+
+	DELETE FROM schema_version;
+
+	INSERT INTO schema_version(version) VALUES (9);
+`
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Query preview ──────────────────────────────────────────────────────────
+//
+// explain_only: true on a database tool returns the SQL the call would run
+// and Postgres's estimates for it instead of running it, so a heavy request
+// can be checked (and narrowed) before it costs anything. In explain mode
+// queryRows and queryTyped pass each statement to EXPLAIN (FORMAT JSON),
+// which plans without executing, record the plan and return no rows; the
+// handler finishes on empty data and explainQueries replaces its result
+// with what was recorded.
+//
+// Queries that depend on an earlier query's rows (e.g. a lookup of the
+// track a marker belongs to) are not reached and not shown. Calls that the
+// router would send to the simplemap API are refused: only SQL can be
+// previewed.
+
+// explainTools take explain_only.
+var explainTools = map[string]bool{
+	"query_radiation":           true,
+	"nearest_readings":          true,
+	"search_area":               true,
+	"list_tracks":               true,
+	"get_track":                 true,
+	"track_profile":             true,
+	"track_dose_exposure":       true,
+	"dose_contours":             true,
+	"compare_areas":             true,
+	"device_history":            true,
+	"nearest_tracks":            true,
+	"search_tracks_by_location": true,
+	"list_sensors":              true,
+	"sensor_current":            true,
+	"sensor_history":            true,
+	"list_spectra":              true,
+	"get_spectrum":              true,
+	"find_duplicate_uploads":    true,
+	"top_uploaders":             true,
+	"recent_uploads":            true,
+}
+
+// explainSeqScanRows is the estimated row count above which a sequential
+// scan is called out as a warning.
+const explainSeqScanRows = 100000
+
+// withExplainParam adds the explain_only flag to a database tool.
+func withExplainParam() mcp.ToolOption {
+	return mcp.WithBoolean("explain_only",
+		mcp.Description("Do not run the query: return the SQL with its parameters and the database's estimated cost and row counts, to check a heavy request first (default: false)"),
+	)
+}
+
+type explainKey struct{}
+
+// queryPlan is one previewed statement.
+type queryPlan struct {
+	SQL           string   `json:"sql"`
+	Params        []any    `json:"params,omitempty"`
+	EstimatedCost float64  `json:"estimated_cost"`
+	EstimatedRows float64  `json:"estimated_rows"`
+	Plan          []string `json:"plan"`
+	Warnings      []string `json:"warnings,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// queryPreview collects the plans of one explain_only call.
+type queryPreview struct {
+	mu    sync.Mutex
+	plans []queryPlan
+}
+
+func queryPreviewFrom(ctx context.Context) *queryPreview {
+	p, _ := ctx.Value(explainKey{}).(*queryPreview)
+	return p
+}
+
+// explainOnly reports whether the call only previews its queries.
+func explainOnly(ctx context.Context) bool {
+	return queryPreviewFrom(ctx) != nil
+}
+
+// explain plans query without running it and records the result. Planning
+// errors are recorded too, since a query that does not plan would not run.
+func (p *queryPreview) explain(ctx context.Context, query string, args []any) {
+	plan := queryPlan{SQL: strings.Join(strings.Fields(query), " "), Params: args}
+	var raw []byte
+	err := dbPool().QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&raw)
+	var doc []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &doc)
+	}
+	if err != nil || len(doc) == 0 {
+		plan.Error = errString(err)
+		if plan.Error == "" {
+			plan.Error = "empty plan"
+		}
+	} else {
+		root := doc[0].Plan
+		plan.EstimatedCost, plan.EstimatedRows = root.TotalCost, root.PlanRows
+		root.outline(&plan, 0)
+	}
+	p.mu.Lock()
+	p.plans = append(p.plans, plan)
+	p.mu.Unlock()
+}
+
+// planNode is the part of a Postgres JSON plan node shown to the caller.
+type planNode struct {
+	NodeType  string     `json:"Node Type"`
+	Relation  string     `json:"Relation Name"`
+	Index     string     `json:"Index Name"`
+	TotalCost float64    `json:"Total Cost"`
+	PlanRows  float64    `json:"Plan Rows"`
+	Plans     []planNode `json:"Plans"`
+}
+
+// outline appends one indented line per node, and warns about large
+// sequential scans.
+func (n planNode) outline(plan *queryPlan, depth int) {
+	line := strings.Repeat("  ", depth) + n.NodeType
+	if n.Index != "" {
+		line += " using " + n.Index
+	}
+	if n.Relation != "" {
+		line += " on " + n.Relation
+	}
+	plan.Plan = append(plan.Plan, fmt.Sprintf("%s (cost=%.0f rows=%.0f)", line, n.TotalCost, n.PlanRows))
+	if n.NodeType == "Seq Scan" && n.PlanRows > explainSeqScanRows {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("sequential scan of %s (~%.0f rows); narrow the area or time range", n.Relation, n.PlanRows))
+	}
+	for _, child := range n.Plans {
+		child.outline(plan, depth+1)
+	}
+}
+
+// explainQueries is a tool middleware that runs explain_only calls in
+// preview mode and returns the recorded plans as the result.
+func explainQueries(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !explainTools[req.Params.Name] || !req.GetBool("explain_only", false) {
+			return next(ctx, req)
+		}
+		if !dbAvailable() {
+			return mcp.NewToolResultError("explain_only needs the database, which is not available; calls answered by the simplemap API have no SQL to preview"), nil
+		}
+		preview := &queryPreview{}
+		res, err := next(context.WithValue(ctx, explainKey{}, preview), req)
+		if err != nil {
+			return res, err
+		}
+		if len(preview.plans) == 0 {
+			if res != nil && res.IsError {
+				return res, nil // invalid arguments: nothing was planned
+			}
+			return mcp.NewToolResultError("explain_only: this call issued no SQL to preview"), nil
+		}
+		var cost, rows float64
+		for _, p := range preview.plans {
+			cost += p.EstimatedCost
+			rows += p.EstimatedRows
+		}
+		return jsonResult(map[string]any{
+			"tool":                 req.Params.Name,
+			"explain_only":         true,
+			"executed":             false,
+			"queries":              preview.plans,
+			"total_estimated_cost": cost,
+			"total_estimated_rows": rows,
+			"note":                 "Estimates come from the Postgres planner (cost in its arbitrary units; rows are guesses from table statistics). Queries that depend on earlier results are not shown. Call again without explain_only to run it.",
+		})
+	}
+}
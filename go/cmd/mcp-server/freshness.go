@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Data freshness ─────────────────────────────────────────────────────────
+//
+// Tools answering "what is the latest ..." are only as current as the
+// replica they read. routeQuery already moves queryRecent calls to the API
+// when the lag exceeds SOURCE_MAX_REPLICATION_LAG; withDataFreshness tells
+// the caller how current the answer is, so a stale replica is never silent.
+//
+// Only some API endpoints read the primary. Others, such as /api/tracks,
+// are served by this server from the same replica, so an answer through
+// them is as stale as the replica and is reported that way.
+
+// apiIsSelf reports whether SIMPLEMAP_URL points at this server
+// (MCP_BASE_URL), whose API answers from the replica.
+func apiIsSelf() bool {
+	self, err := url.Parse(os.Getenv("MCP_BASE_URL"))
+	if err != nil || self.Host == "" {
+		return false
+	}
+	api, err := url.Parse(client.baseURL)
+	return err == nil && api.Host == self.Host
+}
+
+// dataFreshness builds the "data_freshness" block for a result served by
+// source. apiPrimary says whether the tool's API endpoint reads the primary
+// database rather than this server's replica.
+func dataFreshness(ctx context.Context, source string, apiPrimary bool) map[string]any {
+	block := map[string]any{
+		"source":            source,
+		"threshold_seconds": router.maxLag.Seconds(),
+		"stale":             false,
+	}
+	apiPrimary = apiPrimary && !apiIsSelf()
+	if source == string(sourceAPI) && apiPrimary {
+		block["note"] = "Read through the simplemap API, which serves the primary database."
+		return block
+	}
+
+	lag, ok := router.replicationLag(ctx)
+	if !ok {
+		block["note"] = "Replication lag unknown; results reflect the replica at query time."
+		if source == string(sourceAPI) {
+			block["note"] = "Read through the simplemap API, which answers from a replica whose lag is unknown."
+		}
+		return block
+	}
+	block["replication_lag_seconds"] = lag.Seconds()
+	if router.maxLag > 0 && lag > router.maxLag {
+		block["stale"] = true
+		fallback := ""
+		if apiPrimary {
+			fallback = " and the API fallback was unavailable"
+		}
+		block["note"] = fmt.Sprintf("The database replica is %s behind the primary%s. Readings and uploads from that window are missing; tell the user the data may be out of date.", lag.Round(time.Second), fallback)
+		return block
+	}
+	block["note"] = fmt.Sprintf("Replica is current to within %s.", lag.Round(time.Second))
+	return block
+}
+
+// withDataFreshness adds a data_freshness block to a routed result, using
+// the source recorded in its source_routing block.
+func withDataFreshness(ctx context.Context, res *mcp.CallToolResult, err error, apiPrimary bool) (*mcp.CallToolResult, error) {
+	if err != nil {
+		return res, err
+	}
+	return amendJSONResult(res, func(m map[string]any) {
+		source := string(sourceDatabase)
+		if routing, ok := m["source_routing"].(map[string]any); ok {
+			if s, ok := routing["source"].(string); ok {
+				source = s
+			}
+		}
+		m["data_freshness"] = dataFreshness(ctx, source, apiPrimary)
+	}), nil
+}
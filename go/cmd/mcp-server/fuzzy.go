@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ── Fuzzy name matching ────────────────────────────────────────────────────
+//
+// Users and models type names loosely: "South-Korea", "czechia ", "Sri
+// Lanka" for an entry stored as "srilanka". matchKey folds case, punctuation
+// and spacing so those compare equal; closestMatches ranks the rest by edit
+// distance for "did you mean" suggestions.
+
+// matchKey lowercases s and reduces every run of non-alphanumerics to a
+// single space.
+func matchKey(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		space = true
+	}
+	return b.String()
+}
+
+// compactKey is matchKey without spaces, so "sri lanka" meets "srilanka".
+func compactKey(s string) string {
+	return strings.ReplaceAll(matchKey(s), " ", "")
+}
+
+// closestMatches returns up to n candidates close to input, best first.
+// A candidate qualifies when one contains the other or when the edit
+// distance is at most a third of the longer name.
+func closestMatches(input string, candidates []string, n int) []string {
+	in := compactKey(input)
+	if in == "" {
+		return nil
+	}
+	type scored struct {
+		name string
+		dist int
+	}
+	var hits []scored
+	for _, c := range candidates {
+		ck := compactKey(c)
+		d := levenshtein(in, ck)
+		if strings.Contains(ck, in) || strings.Contains(in, ck) {
+			d = min(d, 1)
+		} else if d*3 > max(len([]rune(in)), len([]rune(ck))) {
+			continue
+		}
+		hits = append(hits, scored{c, d})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].dist != hits[j].dist {
+			return hits[i].dist < hits[j].dist
+		}
+		return hits[i].name < hits[j].name
+	})
+	out := []string{}
+	for i := 0; i < len(hits) && i < n; i++ {
+		out = append(out, hits[i].name)
+	}
+	return out
+}
+
+// levenshtein is the edit distance between a and b, in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
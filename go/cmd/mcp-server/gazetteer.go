@@ -0,0 +1,619 @@
+package main
+
+// ── Gazetteer ──────────────────────────────────────────────────────────────
+//
+// A small offline gazetteer for locate_point (places.go): the countries
+// Safecast data comes from and a few hundred cities with their first-level
+// division (prefecture, state, province) and time zone. Japan is covered
+// prefecture by prefecture, with the municipalities around Fukushima
+// Daiichi; elsewhere capitals, large cities and places with nuclear sites
+// or high natural background. A city without a zone uses its country's.
+
+type gazetteerCountry struct {
+	name string
+	box  string // key in countryBoundingBoxes, "" when there is none
+	tz   string
+}
+
+type gazetteerCity struct {
+	name, admin1, cc string
+	lat, lon         float64
+	tz               string
+}
+
+var gazetteerCountries = map[string]gazetteerCountry{
+	"AE": {"United Arab Emirates", "uae", "Asia/Dubai"},
+	"AF": {"Afghanistan", "afghanistan", "Asia/Kabul"},
+	"AL": {"Albania", "albania", "Europe/Tirane"},
+	"AM": {"Armenia", "armenia", "Asia/Yerevan"},
+	"AO": {"Angola", "", "Africa/Luanda"},
+	"AR": {"Argentina", "argentina", "America/Argentina/Buenos_Aires"},
+	"AT": {"Austria", "austria", "Europe/Vienna"},
+	"AU": {"Australia", "australia", "Australia/Sydney"},
+	"AZ": {"Azerbaijan", "azerbaijan", "Asia/Baku"},
+	"BA": {"Bosnia and Herzegovina", "bosnia", "Europe/Sarajevo"},
+	"BD": {"Bangladesh", "bangladesh", "Asia/Dhaka"},
+	"BE": {"Belgium", "belgium", "Europe/Brussels"},
+	"BG": {"Bulgaria", "bulgaria", "Europe/Sofia"},
+	"BH": {"Bahrain", "bahrain", "Asia/Bahrain"},
+	"BO": {"Bolivia", "bolivia", "America/La_Paz"},
+	"BR": {"Brazil", "brazil", "America/Sao_Paulo"},
+	"BY": {"Belarus", "belarus", "Europe/Minsk"},
+	"CA": {"Canada", "canada", "America/Toronto"},
+	"CD": {"DR Congo", "", "Africa/Kinshasa"},
+	"CH": {"Switzerland", "switzerland", "Europe/Zurich"},
+	"CL": {"Chile", "chile", "America/Santiago"},
+	"CN": {"China", "china", "Asia/Shanghai"},
+	"CO": {"Colombia", "colombia", "America/Bogota"},
+	"CR": {"Costa Rica", "costa rica", "America/Costa_Rica"},
+	"CU": {"Cuba", "cuba", "America/Havana"},
+	"CY": {"Cyprus", "cyprus", "Asia/Nicosia"},
+	"CZ": {"Czech Republic", "czech republic", "Europe/Prague"},
+	"DE": {"Germany", "germany", "Europe/Berlin"},
+	"DK": {"Denmark", "denmark", "Europe/Copenhagen"},
+	"DO": {"Dominican Republic", "dominican republic", "America/Santo_Domingo"},
+	"DZ": {"Algeria", "", "Africa/Algiers"},
+	"EC": {"Ecuador", "ecuador", "America/Guayaquil"},
+	"EE": {"Estonia", "estonia", "Europe/Tallinn"},
+	"EG": {"Egypt", "egypt", "Africa/Cairo"},
+	"ES": {"Spain", "spain", "Europe/Madrid"},
+	"ET": {"Ethiopia", "", "Africa/Addis_Ababa"},
+	"FI": {"Finland", "finland", "Europe/Helsinki"},
+	"FR": {"France", "france", "Europe/Paris"},
+	"GB": {"United Kingdom", "united kingdom", "Europe/London"},
+	"GE": {"Georgia", "georgia", "Asia/Tbilisi"},
+	"GH": {"Ghana", "", "Africa/Accra"},
+	"GL": {"Greenland", "", "America/Nuuk"},
+	"GR": {"Greece", "greece", "Europe/Athens"},
+	"GT": {"Guatemala", "guatemala", "America/Guatemala"},
+	"HK": {"Hong Kong", "", "Asia/Hong_Kong"},
+	"HN": {"Honduras", "honduras", "America/Tegucigalpa"},
+	"HR": {"Croatia", "croatia", "Europe/Zagreb"},
+	"HU": {"Hungary", "hungary", "Europe/Budapest"},
+	"ID": {"Indonesia", "indonesia", "Asia/Jakarta"},
+	"IE": {"Ireland", "ireland", "Europe/Dublin"},
+	"IL": {"Israel", "israel", "Asia/Jerusalem"},
+	"IN": {"India", "india", "Asia/Kolkata"},
+	"IQ": {"Iraq", "iraq", "Asia/Baghdad"},
+	"IR": {"Iran", "iran", "Asia/Tehran"},
+	"IS": {"Iceland", "iceland", "Atlantic/Reykjavik"},
+	"IT": {"Italy", "italy", "Europe/Rome"},
+	"JM": {"Jamaica", "jamaica", "America/Jamaica"},
+	"JO": {"Jordan", "jordan", "Asia/Amman"},
+	"JP": {"Japan", "japan", "Asia/Tokyo"},
+	"KE": {"Kenya", "kenya", "Africa/Nairobi"},
+	"KG": {"Kyrgyzstan", "kyrgyzstan", "Asia/Bishkek"},
+	"KP": {"North Korea", "", "Asia/Pyongyang"},
+	"KR": {"South Korea", "south korea", "Asia/Seoul"},
+	"KW": {"Kuwait", "kuwait", "Asia/Kuwait"},
+	"KZ": {"Kazakhstan", "kazakhstan", "Asia/Almaty"},
+	"LB": {"Lebanon", "lebanon", "Asia/Beirut"},
+	"LK": {"Sri Lanka", "srilanka", "Asia/Colombo"},
+	"LT": {"Lithuania", "lithuania", "Europe/Vilnius"},
+	"LU": {"Luxembourg", "luxembourg", "Europe/Luxembourg"},
+	"LV": {"Latvia", "latvia", "Europe/Riga"},
+	"LY": {"Libya", "", "Africa/Tripoli"},
+	"MA": {"Morocco", "morocco", "Africa/Casablanca"},
+	"MD": {"Moldova", "moldova", "Europe/Chisinau"},
+	"ME": {"Montenegro", "montenegro", "Europe/Podgorica"},
+	"MG": {"Madagascar", "", "Indian/Antananarivo"},
+	"MK": {"North Macedonia", "north macedonia", "Europe/Skopje"},
+	"MN": {"Mongolia", "mongolia", "Asia/Ulaanbaatar"},
+	"MT": {"Malta", "malta", "Europe/Malta"},
+	"MX": {"Mexico", "mexico", "America/Mexico_City"},
+	"MY": {"Malaysia", "malaysia", "Asia/Kuala_Lumpur"},
+	"NA": {"Namibia", "", "Africa/Windhoek"},
+	"NE": {"Niger", "", "Africa/Niamey"},
+	"NG": {"Nigeria", "nigeria", "Africa/Lagos"},
+	"NI": {"Nicaragua", "nicaragua", "America/Managua"},
+	"NL": {"Netherlands", "netherlands", "Europe/Amsterdam"},
+	"NO": {"Norway", "norway", "Europe/Oslo"},
+	"NP": {"Nepal", "nepal", "Asia/Kathmandu"},
+	"NZ": {"New Zealand", "new zealand", "Pacific/Auckland"},
+	"OM": {"Oman", "oman", "Asia/Muscat"},
+	"PA": {"Panama", "panama", "America/Panama"},
+	"PE": {"Peru", "peru", "America/Lima"},
+	"PH": {"Philippines", "philippines", "Asia/Manila"},
+	"PK": {"Pakistan", "pakistan", "Asia/Karachi"},
+	"PL": {"Poland", "poland", "Europe/Warsaw"},
+	"PR": {"Puerto Rico", "puerto rico", "America/Puerto_Rico"},
+	"PT": {"Portugal", "portugal", "Europe/Lisbon"},
+	"PY": {"Paraguay", "paraguay", "America/Asuncion"},
+	"QA": {"Qatar", "qatar", "Asia/Qatar"},
+	"RO": {"Romania", "romania", "Europe/Bucharest"},
+	"RS": {"Serbia", "serbia", "Europe/Belgrade"},
+	"RU": {"Russia", "russia", "Europe/Moscow"},
+	"SA": {"Saudi Arabia", "saudi arabia", "Asia/Riyadh"},
+	"SD": {"Sudan", "", "Africa/Khartoum"},
+	"SE": {"Sweden", "sweden", "Europe/Stockholm"},
+	"SG": {"Singapore", "singapore", "Asia/Singapore"},
+	"SI": {"Slovenia", "slovenia", "Europe/Ljubljana"},
+	"SK": {"Slovakia", "slovakia", "Europe/Bratislava"},
+	"SN": {"Senegal", "", "Africa/Dakar"},
+	"SV": {"El Salvador", "el salvador", "America/El_Salvador"},
+	"SY": {"Syria", "syria", "Asia/Damascus"},
+	"TH": {"Thailand", "thailand", "Asia/Bangkok"},
+	"TJ": {"Tajikistan", "tajikistan", "Asia/Dushanbe"},
+	"TM": {"Turkmenistan", "turkmenistan", "Asia/Ashgabat"},
+	"TN": {"Tunisia", "", "Africa/Tunis"},
+	"TR": {"Turkey", "turkey", "Europe/Istanbul"},
+	"TT": {"Trinidad and Tobago", "trinidad", "America/Port_of_Spain"},
+	"TW": {"Taiwan", "", "Asia/Taipei"},
+	"TZ": {"Tanzania", "", "Africa/Dar_es_Salaam"},
+	"UA": {"Ukraine", "ukraine", "Europe/Kyiv"},
+	"UG": {"Uganda", "", "Africa/Kampala"},
+	"US": {"United States", "united states", "America/New_York"},
+	"UY": {"Uruguay", "uruguay", "America/Montevideo"},
+	"UZ": {"Uzbekistan", "uzbekistan", "Asia/Tashkent"},
+	"VE": {"Venezuela", "venezuela", "America/Caracas"},
+	"VN": {"Vietnam", "vietnam", "Asia/Ho_Chi_Minh"},
+	"YE": {"Yemen", "yemen", "Asia/Aden"},
+	"ZA": {"South Africa", "south africa", "Africa/Johannesburg"},
+	"ZM": {"Zambia", "", "Africa/Lusaka"},
+	"ZW": {"Zimbabwe", "", "Africa/Harare"},
+}
+
+var gazetteerCities = []gazetteerCity{
+	// Japan: prefectural capitals.
+	{"Sapporo", "Hokkaido", "JP", 43.0621, 141.3544, ""},
+	{"Aomori", "Aomori", "JP", 40.8244, 140.7400, ""},
+	{"Morioka", "Iwate", "JP", 39.7036, 141.1527, ""},
+	{"Sendai", "Miyagi", "JP", 38.2682, 140.8694, ""},
+	{"Akita", "Akita", "JP", 39.7186, 140.1024, ""},
+	{"Yamagata", "Yamagata", "JP", 38.2404, 140.3633, ""},
+	{"Fukushima", "Fukushima", "JP", 37.7608, 140.4748, ""},
+	{"Mito", "Ibaraki", "JP", 36.3418, 140.4468, ""},
+	{"Utsunomiya", "Tochigi", "JP", 36.5551, 139.8828, ""},
+	{"Maebashi", "Gunma", "JP", 36.3895, 139.0634, ""},
+	{"Saitama", "Saitama", "JP", 35.8617, 139.6455, ""},
+	{"Chiba", "Chiba", "JP", 35.6073, 140.1063, ""},
+	{"Tokyo", "Tokyo", "JP", 35.6895, 139.6917, ""},
+	{"Yokohama", "Kanagawa", "JP", 35.4437, 139.6380, ""},
+	{"Niigata", "Niigata", "JP", 37.9161, 139.0364, ""},
+	{"Toyama", "Toyama", "JP", 36.6953, 137.2113, ""},
+	{"Kanazawa", "Ishikawa", "JP", 36.5613, 136.6562, ""},
+	{"Fukui", "Fukui", "JP", 36.0641, 136.2196, ""},
+	{"Kofu", "Yamanashi", "JP", 35.6622, 138.5683, ""},
+	{"Nagano", "Nagano", "JP", 36.6485, 138.1942, ""},
+	{"Gifu", "Gifu", "JP", 35.4233, 136.7607, ""},
+	{"Shizuoka", "Shizuoka", "JP", 34.9756, 138.3828, ""},
+	{"Nagoya", "Aichi", "JP", 35.1815, 136.9066, ""},
+	{"Tsu", "Mie", "JP", 34.7186, 136.5056, ""},
+	{"Otsu", "Shiga", "JP", 35.0045, 135.8686, ""},
+	{"Kyoto", "Kyoto", "JP", 35.0116, 135.7681, ""},
+	{"Osaka", "Osaka", "JP", 34.6937, 135.5023, ""},
+	{"Kobe", "Hyogo", "JP", 34.6901, 135.1955, ""},
+	{"Nara", "Nara", "JP", 34.6851, 135.8048, ""},
+	{"Wakayama", "Wakayama", "JP", 34.2260, 135.1675, ""},
+	{"Tottori", "Tottori", "JP", 35.5011, 134.2351, ""},
+	{"Matsue", "Shimane", "JP", 35.4723, 133.0505, ""},
+	{"Okayama", "Okayama", "JP", 34.6551, 133.9195, ""},
+	{"Hiroshima", "Hiroshima", "JP", 34.3853, 132.4553, ""},
+	{"Yamaguchi", "Yamaguchi", "JP", 34.1785, 131.4737, ""},
+	{"Tokushima", "Tokushima", "JP", 34.0703, 134.5548, ""},
+	{"Takamatsu", "Kagawa", "JP", 34.3401, 134.0434, ""},
+	{"Matsuyama", "Ehime", "JP", 33.8392, 132.7657, ""},
+	{"Kochi", "Kochi", "JP", 33.5597, 133.5311, ""},
+	{"Fukuoka", "Fukuoka", "JP", 33.5902, 130.4017, ""},
+	{"Saga", "Saga", "JP", 33.2494, 130.2988, ""},
+	{"Nagasaki", "Nagasaki", "JP", 32.7503, 129.8779, ""},
+	{"Kumamoto", "Kumamoto", "JP", 32.8031, 130.7079, ""},
+	{"Oita", "Oita", "JP", 33.2382, 131.6126, ""},
+	{"Miyazaki", "Miyazaki", "JP", 31.9077, 131.4202, ""},
+	{"Kagoshima", "Kagoshima", "JP", 31.5966, 130.5571, ""},
+	{"Naha", "Okinawa", "JP", 26.2124, 127.6809, ""},
+	// Japan: Fukushima Prefecture and the area around Fukushima Daiichi.
+	{"Koriyama", "Fukushima", "JP", 37.4005, 140.3597, ""},
+	{"Iwaki", "Fukushima", "JP", 37.0505, 140.8877, ""},
+	{"Aizuwakamatsu", "Fukushima", "JP", 37.4948, 139.9298, ""},
+	{"Minamisoma", "Fukushima", "JP", 37.6422, 140.9573, ""},
+	{"Soma", "Fukushima", "JP", 37.7967, 140.9194, ""},
+	{"Date", "Fukushima", "JP", 37.8191, 140.5630, ""},
+	{"Nihonmatsu", "Fukushima", "JP", 37.5849, 140.4310, ""},
+	{"Tamura", "Fukushima", "JP", 37.4334, 140.5753, ""},
+	{"Namie", "Fukushima", "JP", 37.4947, 141.0007, ""},
+	{"Futaba", "Fukushima", "JP", 37.4490, 141.0124, ""},
+	{"Okuma", "Fukushima", "JP", 37.4044, 140.9836, ""},
+	{"Tomioka", "Fukushima", "JP", 37.3339, 141.0089, ""},
+	{"Naraha", "Fukushima", "JP", 37.2817, 140.9969, ""},
+	{"Hirono", "Fukushima", "JP", 37.2142, 140.9950, ""},
+	{"Kawauchi", "Fukushima", "JP", 37.3373, 140.8090, ""},
+	{"Katsurao", "Fukushima", "JP", 37.5043, 140.7647, ""},
+	{"Iitate", "Fukushima", "JP", 37.6786, 140.7351, ""},
+	{"Kawamata", "Fukushima", "JP", 37.6651, 140.5982, ""},
+	{"Shirakawa", "Fukushima", "JP", 37.1263, 140.2109, ""},
+	{"Sukagawa", "Fukushima", "JP", 37.2866, 140.3726, ""},
+	{"Kitakata", "Fukushima", "JP", 37.6510, 139.8747, ""},
+	// Japan: other cities.
+	{"Hakodate", "Hokkaido", "JP", 41.7687, 140.7288, ""},
+	{"Asahikawa", "Hokkaido", "JP", 43.7706, 142.3650, ""},
+	{"Kushiro", "Hokkaido", "JP", 42.9849, 144.3820, ""},
+	{"Hachinohe", "Aomori", "JP", 40.5123, 141.4884, ""},
+	{"Rokkasho", "Aomori", "JP", 40.9672, 141.3745, ""},
+	{"Ishinomaki", "Miyagi", "JP", 38.4345, 141.3029, ""},
+	{"Onagawa", "Miyagi", "JP", 38.4455, 141.4445, ""},
+	{"Hitachi", "Ibaraki", "JP", 36.5991, 140.6515, ""},
+	{"Tokai", "Ibaraki", "JP", 36.4729, 140.5665, ""},
+	{"Tsukuba", "Ibaraki", "JP", 36.0835, 140.0764, ""},
+	{"Kashiwa", "Chiba", "JP", 35.8676, 139.9758, ""},
+	{"Hachioji", "Tokyo", "JP", 35.6664, 139.3160, ""},
+	{"Kawasaki", "Kanagawa", "JP", 35.5308, 139.7029, ""},
+	{"Sagamihara", "Kanagawa", "JP", 35.5714, 139.3733, ""},
+	{"Nagaoka", "Niigata", "JP", 37.4462, 138.8512, ""},
+	{"Kashiwazaki", "Niigata", "JP", 37.3719, 138.5590, ""},
+	{"Tsuruga", "Fukui", "JP", 35.6452, 136.0555, ""},
+	{"Matsumoto", "Nagano", "JP", 36.2380, 137.9720, ""},
+	{"Hamamatsu", "Shizuoka", "JP", 34.7108, 137.7261, ""},
+	{"Omaezaki", "Shizuoka", "JP", 34.6381, 138.1281, ""},
+	{"Toyota", "Aichi", "JP", 35.0824, 137.1563, ""},
+	{"Sakai", "Osaka", "JP", 34.5733, 135.4830, ""},
+	{"Himeji", "Hyogo", "JP", 34.8151, 134.6853, ""},
+	{"Kurashiki", "Okayama", "JP", 34.5850, 133.7722, ""},
+	{"Fukuyama", "Hiroshima", "JP", 34.4859, 133.3623, ""},
+	{"Kitakyushu", "Fukuoka", "JP", 33.8834, 130.8752, ""},
+	{"Sasebo", "Nagasaki", "JP", 33.1799, 129.7151, ""},
+	{"Satsumasendai", "Kagoshima", "JP", 31.8134, 130.3039, ""},
+
+	// East Asia.
+	{"Seoul", "Seoul", "KR", 37.5665, 126.9780, ""},
+	{"Busan", "Busan", "KR", 35.1796, 129.0756, ""},
+	{"Incheon", "Incheon", "KR", 37.4563, 126.7052, ""},
+	{"Daegu", "Daegu", "KR", 35.8714, 128.6014, ""},
+	{"Daejeon", "Daejeon", "KR", 36.3504, 127.3845, ""},
+	{"Gwangju", "Gwangju", "KR", 35.1595, 126.8526, ""},
+	{"Ulsan", "Ulsan", "KR", 35.5384, 129.3114, ""},
+	{"Gyeongju", "North Gyeongsang", "KR", 35.8562, 129.2247, ""},
+	{"Pyongyang", "Pyongyang", "KP", 39.0392, 125.7625, ""},
+	{"Beijing", "Beijing", "CN", 39.9042, 116.4074, ""},
+	{"Shanghai", "Shanghai", "CN", 31.2304, 121.4737, ""},
+	{"Guangzhou", "Guangdong", "CN", 23.1291, 113.2644, ""},
+	{"Shenzhen", "Guangdong", "CN", 22.5431, 114.0579, ""},
+	{"Chengdu", "Sichuan", "CN", 30.5728, 104.0668, ""},
+	{"Wuhan", "Hubei", "CN", 30.5928, 114.3055, ""},
+	{"Xi'an", "Shaanxi", "CN", 34.3416, 108.9398, ""},
+	{"Harbin", "Heilongjiang", "CN", 45.8038, 126.5350, ""},
+	{"Shenyang", "Liaoning", "CN", 41.8057, 123.4315, ""},
+	{"Dalian", "Liaoning", "CN", 38.9140, 121.6147, ""},
+	{"Kunming", "Yunnan", "CN", 25.0389, 102.7183, ""},
+	{"Lhasa", "Tibet", "CN", 29.6520, 91.1721, ""},
+	{"Urumqi", "Xinjiang", "CN", 43.8256, 87.6168, "Asia/Urumqi"},
+	{"Hong Kong", "Hong Kong", "HK", 22.3193, 114.1694, ""},
+	{"Taipei", "Taipei", "TW", 25.0330, 121.5654, ""},
+	{"Kaohsiung", "Kaohsiung", "TW", 22.6273, 120.3014, ""},
+	{"Ulaanbaatar", "Ulaanbaatar", "MN", 47.8864, 106.9057, ""},
+
+	// Russia, Ukraine, Belarus.
+	{"Moscow", "Moscow", "RU", 55.7558, 37.6173, ""},
+	{"Saint Petersburg", "Saint Petersburg", "RU", 59.9311, 30.3609, ""},
+	{"Kazan", "Tatarstan", "RU", 55.7963, 49.1088, ""},
+	{"Samara", "Samara Oblast", "RU", 53.1959, 50.1002, "Europe/Samara"},
+	{"Rostov-on-Don", "Rostov Oblast", "RU", 47.2357, 39.7015, ""},
+	{"Murmansk", "Murmansk Oblast", "RU", 68.9585, 33.0827, ""},
+	{"Kaliningrad", "Kaliningrad Oblast", "RU", 54.7104, 20.4522, "Europe/Kaliningrad"},
+	{"Yekaterinburg", "Sverdlovsk Oblast", "RU", 56.8389, 60.6057, "Asia/Yekaterinburg"},
+	{"Omsk", "Omsk Oblast", "RU", 54.9885, 73.3242, "Asia/Omsk"},
+	{"Novosibirsk", "Novosibirsk Oblast", "RU", 55.0084, 82.9357, "Asia/Novosibirsk"},
+	{"Krasnoyarsk", "Krasnoyarsk Krai", "RU", 56.0153, 92.8932, "Asia/Krasnoyarsk"},
+	{"Irkutsk", "Irkutsk Oblast", "RU", 52.2870, 104.3050, "Asia/Irkutsk"},
+	{"Yakutsk", "Sakha", "RU", 62.0355, 129.6755, "Asia/Yakutsk"},
+	{"Khabarovsk", "Khabarovsk Krai", "RU", 48.4827, 135.0838, "Asia/Vladivostok"},
+	{"Vladivostok", "Primorsky Krai", "RU", 43.1198, 131.8869, "Asia/Vladivostok"},
+	{"Magadan", "Magadan Oblast", "RU", 59.5612, 150.8301, "Asia/Magadan"},
+	{"Petropavlovsk-Kamchatsky", "Kamchatka Krai", "RU", 53.0452, 158.6483, "Asia/Kamchatka"},
+	{"Kyiv", "Kyiv", "UA", 50.4501, 30.5234, ""},
+	{"Chernobyl", "Kyiv Oblast", "UA", 51.2763, 30.2219, ""},
+	{"Pripyat", "Kyiv Oblast", "UA", 51.4045, 30.0542, ""},
+	{"Zhytomyr", "Zhytomyr Oblast", "UA", 50.2547, 28.6587, ""},
+	{"Kharkiv", "Kharkiv Oblast", "UA", 49.9935, 36.2304, ""},
+	{"Lviv", "Lviv Oblast", "UA", 49.8397, 24.0297, ""},
+	{"Odesa", "Odesa Oblast", "UA", 46.4825, 30.7233, ""},
+	{"Dnipro", "Dnipropetrovsk Oblast", "UA", 48.4647, 35.0462, ""},
+	{"Minsk", "Minsk", "BY", 53.9006, 27.5590, ""},
+	{"Gomel", "Gomel Region", "BY", 52.4412, 30.9878, ""},
+
+	// Europe.
+	{"London", "England", "GB", 51.5074, -0.1278, ""},
+	{"Manchester", "England", "GB", 53.4808, -2.2426, ""},
+	{"Seascale", "England", "GB", 54.3960, -3.4840, ""},
+	{"Edinburgh", "Scotland", "GB", 55.9533, -3.1883, ""},
+	{"Cardiff", "Wales", "GB", 51.4816, -3.1791, ""},
+	{"Belfast", "Northern Ireland", "GB", 54.5973, -5.9301, ""},
+	{"Dublin", "Leinster", "IE", 53.3498, -6.2603, ""},
+	{"Cork", "Munster", "IE", 51.8985, -8.4756, ""},
+	{"Paris", "Île-de-France", "FR", 48.8566, 2.3522, ""},
+	{"Lyon", "Auvergne-Rhône-Alpes", "FR", 45.7640, 4.8357, ""},
+	{"Marseille", "Provence-Alpes-Côte d'Azur", "FR", 43.2965, 5.3698, ""},
+	{"Toulouse", "Occitanie", "FR", 43.6047, 1.4442, ""},
+	{"Bordeaux", "Nouvelle-Aquitaine", "FR", 44.8378, -0.5792, ""},
+	{"Lille", "Hauts-de-France", "FR", 50.6292, 3.0573, ""},
+	{"Strasbourg", "Grand Est", "FR", 48.5734, 7.7521, ""},
+	{"Nantes", "Pays de la Loire", "FR", 47.2184, -1.5536, ""},
+	{"Rennes", "Brittany", "FR", 48.1173, -1.6778, ""},
+	{"Cherbourg", "Normandy", "FR", 49.6337, -1.6222, ""},
+	{"Berlin", "Berlin", "DE", 52.5200, 13.4050, ""},
+	{"Hamburg", "Hamburg", "DE", 53.5511, 9.9937, ""},
+	{"Munich", "Bavaria", "DE", 48.1351, 11.5820, ""},
+	{"Cologne", "North Rhine-Westphalia", "DE", 50.9375, 6.9603, ""},
+	{"Frankfurt", "Hesse", "DE", 50.1109, 8.6821, ""},
+	{"Stuttgart", "Baden-Württemberg", "DE", 48.7758, 9.1829, ""},
+	{"Dresden", "Saxony", "DE", 51.0504, 13.7373, ""},
+	{"Leipzig", "Saxony", "DE", 51.3397, 12.3731, ""},
+	{"Hanover", "Lower Saxony", "DE", 52.3759, 9.7320, ""},
+	{"Amsterdam", "North Holland", "NL", 52.3676, 4.9041, ""},
+	{"Rotterdam", "South Holland", "NL", 51.9244, 4.4777, ""},
+	{"Brussels", "Brussels", "BE", 50.8503, 4.3517, ""},
+	{"Antwerp", "Flanders", "BE", 51.2194, 4.4025, ""},
+	{"Luxembourg", "Luxembourg", "LU", 49.6116, 6.1319, ""},
+	{"Zurich", "Zurich", "CH", 47.3769, 8.5417, ""},
+	{"Geneva", "Geneva", "CH", 46.2044, 6.1432, ""},
+	{"Bern", "Bern", "CH", 46.9480, 7.4474, ""},
+	{"Vienna", "Vienna", "AT", 48.2082, 16.3738, ""},
+	{"Graz", "Styria", "AT", 47.0707, 15.4395, ""},
+	{"Rome", "Lazio", "IT", 41.9028, 12.4964, ""},
+	{"Milan", "Lombardy", "IT", 45.4642, 9.1900, ""},
+	{"Turin", "Piedmont", "IT", 45.0703, 7.6869, ""},
+	{"Naples", "Campania", "IT", 40.8518, 14.2681, ""},
+	{"Palermo", "Sicily", "IT", 38.1157, 13.3615, ""},
+	{"Madrid", "Community of Madrid", "ES", 40.4168, -3.7038, ""},
+	{"Barcelona", "Catalonia", "ES", 41.3874, 2.1686, ""},
+	{"Valencia", "Valencian Community", "ES", 39.4699, -0.3763, ""},
+	{"Seville", "Andalusia", "ES", 37.3891, -5.9845, ""},
+	{"Lisbon", "Lisbon", "PT", 38.7223, -9.1393, ""},
+	{"Porto", "Porto", "PT", 41.1579, -8.6291, ""},
+	{"Copenhagen", "Capital Region", "DK", 55.6761, 12.5683, ""},
+	{"Aarhus", "Central Denmark", "DK", 56.1629, 10.2039, ""},
+	{"Oslo", "Oslo", "NO", 59.9139, 10.7522, ""},
+	{"Bergen", "Vestland", "NO", 60.3913, 5.3221, ""},
+	{"Tromsø", "Troms", "NO", 69.6492, 18.9553, ""},
+	{"Stockholm", "Stockholm", "SE", 59.3293, 18.0686, ""},
+	{"Gothenburg", "Västra Götaland", "SE", 57.7089, 11.9746, ""},
+	{"Helsinki", "Uusimaa", "FI", 60.1699, 24.9384, ""},
+	{"Oulu", "North Ostrobothnia", "FI", 65.0121, 25.4651, ""},
+	{"Reykjavik", "Capital Region", "IS", 64.1466, -21.9426, ""},
+	{"Warsaw", "Masovian", "PL", 52.2297, 21.0122, ""},
+	{"Krakow", "Lesser Poland", "PL", 50.0647, 19.9450, ""},
+	{"Gdansk", "Pomeranian", "PL", 54.3520, 18.6466, ""},
+	{"Prague", "Prague", "CZ", 50.0755, 14.4378, ""},
+	{"Brno", "South Moravian", "CZ", 49.1951, 16.6068, ""},
+	{"Bratislava", "Bratislava", "SK", 48.1486, 17.1077, ""},
+	{"Budapest", "Budapest", "HU", 47.4979, 19.0402, ""},
+	{"Bucharest", "Bucharest", "RO", 44.4268, 26.1025, ""},
+	{"Cluj-Napoca", "Cluj", "RO", 46.7712, 23.6236, ""},
+	{"Sofia", "Sofia City", "BG", 42.6977, 23.3219, ""},
+	{"Athens", "Attica", "GR", 37.9838, 23.7275, ""},
+	{"Thessaloniki", "Central Macedonia", "GR", 40.6401, 22.9444, ""},
+	{"Belgrade", "Belgrade", "RS", 44.7866, 20.4489, ""},
+	{"Zagreb", "Zagreb", "HR", 45.8150, 15.9819, ""},
+	{"Ljubljana", "Ljubljana", "SI", 46.0569, 14.5058, ""},
+	{"Sarajevo", "Federation of Bosnia and Herzegovina", "BA", 43.8563, 18.4131, ""},
+	{"Podgorica", "Podgorica", "ME", 42.4304, 19.2594, ""},
+	{"Tirana", "Tirana", "AL", 41.3275, 19.8187, ""},
+	{"Skopje", "Skopje", "MK", 41.9981, 21.4254, ""},
+	{"Chisinau", "Chisinau", "MD", 47.0105, 28.8638, ""},
+	{"Tallinn", "Harju", "EE", 59.4370, 24.7536, ""},
+	{"Riga", "Riga", "LV", 56.9496, 24.1052, ""},
+	{"Vilnius", "Vilnius", "LT", 54.6872, 25.2797, ""},
+	{"Valletta", "Valletta", "MT", 35.8989, 14.5146, ""},
+	{"Nicosia", "Nicosia", "CY", 35.1856, 33.3823, ""},
+	{"Istanbul", "Istanbul", "TR", 41.0082, 28.9784, ""},
+	{"Ankara", "Ankara", "TR", 39.9334, 32.8597, ""},
+	{"Izmir", "Izmir", "TR", 38.4237, 27.1428, ""},
+
+	// Caucasus, Central Asia, Middle East.
+	{"Tbilisi", "Tbilisi", "GE", 41.7151, 44.8271, ""},
+	{"Yerevan", "Yerevan", "AM", 40.1792, 44.4991, ""},
+	{"Baku", "Baku", "AZ", 40.4093, 49.8671, ""},
+	{"Astana", "Astana", "KZ", 51.1694, 71.4491, ""},
+	{"Almaty", "Almaty", "KZ", 43.2220, 76.8512, ""},
+	{"Semey", "Abai Region", "KZ", 50.4111, 80.2275, ""},
+	{"Kurchatov", "Abai Region", "KZ", 50.7567, 78.5406, ""},
+	{"Tashkent", "Tashkent", "UZ", 41.2995, 69.2401, ""},
+	{"Ashgabat", "Ashgabat", "TM", 37.9601, 58.3261, ""},
+	{"Dushanbe", "Dushanbe", "TJ", 38.5598, 68.7870, ""},
+	{"Bishkek", "Bishkek", "KG", 42.8746, 74.5698, ""},
+	{"Kabul", "Kabul", "AF", 34.5553, 69.2075, ""},
+	{"Tehran", "Tehran", "IR", 35.6892, 51.3890, ""},
+	{"Isfahan", "Isfahan", "IR", 32.6546, 51.6680, ""},
+	{"Ramsar", "Mazandaran", "IR", 36.9031, 50.6583, ""},
+	{"Baghdad", "Baghdad", "IQ", 33.3152, 44.3661, ""},
+	{"Damascus", "Damascus", "SY", 33.5138, 36.2765, ""},
+	{"Amman", "Amman", "JO", 31.9454, 35.9284, ""},
+	{"Beirut", "Beirut", "LB", 33.8938, 35.5018, ""},
+	{"Jerusalem", "Jerusalem", "IL", 31.7683, 35.2137, ""},
+	{"Tel Aviv", "Tel Aviv", "IL", 32.0853, 34.7818, ""},
+	{"Kuwait City", "Al Asimah", "KW", 29.3759, 47.9774, ""},
+	{"Manama", "Capital", "BH", 26.2285, 50.5860, ""},
+	{"Doha", "Doha", "QA", 25.2854, 51.5310, ""},
+	{"Abu Dhabi", "Abu Dhabi", "AE", 24.4539, 54.3773, ""},
+	{"Dubai", "Dubai", "AE", 25.2048, 55.2708, ""},
+	{"Riyadh", "Riyadh", "SA", 24.7136, 46.6753, ""},
+	{"Jeddah", "Makkah", "SA", 21.4858, 39.1925, ""},
+	{"Muscat", "Muscat", "OM", 23.5880, 58.3829, ""},
+	{"Sanaa", "Sanaa", "YE", 15.3694, 44.1910, ""},
+
+	// South and Southeast Asia.
+	{"Delhi", "Delhi", "IN", 28.7041, 77.1025, ""},
+	{"Mumbai", "Maharashtra", "IN", 19.0760, 72.8777, ""},
+	{"Kolkata", "West Bengal", "IN", 22.5726, 88.3639, ""},
+	{"Chennai", "Tamil Nadu", "IN", 13.0827, 80.2707, ""},
+	{"Bengaluru", "Karnataka", "IN", 12.9716, 77.5946, ""},
+	{"Hyderabad", "Telangana", "IN", 17.3850, 78.4867, ""},
+	{"Kollam", "Kerala", "IN", 8.8932, 76.6141, ""},
+	{"Islamabad", "Islamabad", "PK", 33.6844, 73.0479, ""},
+	{"Karachi", "Sindh", "PK", 24.8607, 67.0011, ""},
+	{"Lahore", "Punjab", "PK", 31.5204, 74.3587, ""},
+	{"Dhaka", "Dhaka", "BD", 23.8103, 90.4125, ""},
+	{"Kathmandu", "Bagmati", "NP", 27.7172, 85.3240, ""},
+	{"Colombo", "Western Province", "LK", 6.9271, 79.8612, ""},
+	{"Bangkok", "Bangkok", "TH", 13.7563, 100.5018, ""},
+	{"Chiang Mai", "Chiang Mai", "TH", 18.7883, 98.9853, ""},
+	{"Hanoi", "Hanoi", "VN", 21.0278, 105.8342, ""},
+	{"Ho Chi Minh City", "Ho Chi Minh City", "VN", 10.8231, 106.6297, ""},
+	{"Kuala Lumpur", "Kuala Lumpur", "MY", 3.1390, 101.6869, ""},
+	{"Kota Kinabalu", "Sabah", "MY", 5.9804, 116.0735, "Asia/Kuching"},
+	{"Singapore", "Singapore", "SG", 1.3521, 103.8198, ""},
+	{"Jakarta", "Jakarta", "ID", -6.2088, 106.8456, ""},
+	{"Surabaya", "East Java", "ID", -7.2575, 112.7521, ""},
+	{"Denpasar", "Bali", "ID", -8.6705, 115.2126, "Asia/Makassar"},
+	{"Jayapura", "Papua", "ID", -2.5337, 140.7181, "Asia/Jayapura"},
+	{"Manila", "Metro Manila", "PH", 14.5995, 120.9842, ""},
+	{"Cebu", "Central Visayas", "PH", 10.3157, 123.8854, ""},
+	{"Davao", "Davao Region", "PH", 7.1907, 125.4553, ""},
+
+	// Oceania.
+	{"Sydney", "New South Wales", "AU", -33.8688, 151.2093, ""},
+	{"Canberra", "Australian Capital Territory", "AU", -35.2809, 149.1300, ""},
+	{"Melbourne", "Victoria", "AU", -37.8136, 144.9631, "Australia/Melbourne"},
+	{"Brisbane", "Queensland", "AU", -27.4698, 153.0251, "Australia/Brisbane"},
+	{"Adelaide", "South Australia", "AU", -34.9285, 138.6007, "Australia/Adelaide"},
+	{"Perth", "Western Australia", "AU", -31.9505, 115.8605, "Australia/Perth"},
+	{"Darwin", "Northern Territory", "AU", -12.4634, 130.8456, "Australia/Darwin"},
+	{"Alice Springs", "Northern Territory", "AU", -23.6980, 133.8807, "Australia/Darwin"},
+	{"Hobart", "Tasmania", "AU", -42.8821, 147.3272, "Australia/Hobart"},
+	{"Auckland", "Auckland", "NZ", -36.8485, 174.7633, ""},
+	{"Wellington", "Wellington", "NZ", -41.2865, 174.7762, ""},
+	{"Christchurch", "Canterbury", "NZ", -43.5321, 172.6362, ""},
+
+	// North America.
+	{"New York", "New York", "US", 40.7128, -74.0060, ""},
+	{"Boston", "Massachusetts", "US", 42.3601, -71.0589, ""},
+	{"Philadelphia", "Pennsylvania", "US", 39.9526, -75.1652, ""},
+	{"Pittsburgh", "Pennsylvania", "US", 40.4406, -79.9959, ""},
+	{"Washington", "District of Columbia", "US", 38.9072, -77.0369, ""},
+	{"Charlotte", "North Carolina", "US", 35.2271, -80.8431, ""},
+	{"Atlanta", "Georgia", "US", 33.7490, -84.3880, ""},
+	{"Miami", "Florida", "US", 25.7617, -80.1918, ""},
+	{"Detroit", "Michigan", "US", 42.3314, -83.0458, "America/Detroit"},
+	{"Chicago", "Illinois", "US", 41.8781, -87.6298, "America/Chicago"},
+	{"Minneapolis", "Minnesota", "US", 44.9778, -93.2650, "America/Chicago"},
+	{"St. Louis", "Missouri", "US", 38.6270, -90.1994, "America/Chicago"},
+	{"Kansas City", "Missouri", "US", 39.0997, -94.5786, "America/Chicago"},
+	{"Nashville", "Tennessee", "US", 36.1627, -86.7816, "America/Chicago"},
+	{"Oak Ridge", "Tennessee", "US", 36.0104, -84.2696, ""},
+	{"New Orleans", "Louisiana", "US", 29.9511, -90.0715, "America/Chicago"},
+	{"Houston", "Texas", "US", 29.7604, -95.3698, "America/Chicago"},
+	{"Dallas", "Texas", "US", 32.7767, -96.7970, "America/Chicago"},
+	{"Austin", "Texas", "US", 30.2672, -97.7431, "America/Chicago"},
+	{"Oklahoma City", "Oklahoma", "US", 35.4676, -97.5164, "America/Chicago"},
+	{"Bismarck", "North Dakota", "US", 46.8083, -100.7837, "America/Chicago"},
+	{"Denver", "Colorado", "US", 39.7392, -104.9903, "America/Denver"},
+	{"Albuquerque", "New Mexico", "US", 35.0844, -106.6504, "America/Denver"},
+	{"Los Alamos", "New Mexico", "US", 35.8800, -106.3031, "America/Denver"},
+	{"Billings", "Montana", "US", 45.7833, -108.5007, "America/Denver"},
+	{"Salt Lake City", "Utah", "US", 40.7608, -111.8910, "America/Denver"},
+	{"Boise", "Idaho", "US", 43.6150, -116.2023, "America/Boise"},
+	{"Phoenix", "Arizona", "US", 33.4484, -112.0740, "America/Phoenix"},
+	{"Las Vegas", "Nevada", "US", 36.1699, -115.1398, "America/Los_Angeles"},
+	{"Los Angeles", "California", "US", 34.0522, -118.2437, "America/Los_Angeles"},
+	{"San Diego", "California", "US", 32.7157, -117.1611, "America/Los_Angeles"},
+	{"San Francisco", "California", "US", 37.7749, -122.4194, "America/Los_Angeles"},
+	{"Portland", "Oregon", "US", 45.5152, -122.6784, "America/Los_Angeles"},
+	{"Seattle", "Washington", "US", 47.6062, -122.3321, "America/Los_Angeles"},
+	{"Richland", "Washington", "US", 46.2857, -119.2845, "America/Los_Angeles"},
+	{"Anchorage", "Alaska", "US", 61.2181, -149.9003, "America/Anchorage"},
+	{"Fairbanks", "Alaska", "US", 64.8378, -147.7164, "America/Anchorage"},
+	{"Honolulu", "Hawaii", "US", 21.3069, -157.8583, "Pacific/Honolulu"},
+	{"Toronto", "Ontario", "CA", 43.6532, -79.3832, ""},
+	{"Ottawa", "Ontario", "CA", 45.4215, -75.6972, ""},
+	{"Montreal", "Quebec", "CA", 45.5017, -73.5673, ""},
+	{"Quebec City", "Quebec", "CA", 46.8139, -71.2080, ""},
+	{"Halifax", "Nova Scotia", "CA", 44.6488, -63.5752, "America/Halifax"},
+	{"St. John's", "Newfoundland and Labrador", "CA", 47.5615, -52.7126, "America/St_Johns"},
+	{"Winnipeg", "Manitoba", "CA", 49.8951, -97.1384, "America/Winnipeg"},
+	{"Regina", "Saskatchewan", "CA", 50.4452, -104.6189, "America/Regina"},
+	{"Saskatoon", "Saskatchewan", "CA", 52.1332, -106.6700, "America/Regina"},
+	{"Calgary", "Alberta", "CA", 51.0447, -114.0719, "America/Edmonton"},
+	{"Edmonton", "Alberta", "CA", 53.5461, -113.4938, "America/Edmonton"},
+	{"Vancouver", "British Columbia", "CA", 49.2827, -123.1207, "America/Vancouver"},
+	{"Whitehorse", "Yukon", "CA", 60.7212, -135.0568, "America/Whitehorse"},
+	{"Yellowknife", "Northwest Territories", "CA", 62.4540, -114.3718, "America/Yellowknife"},
+	{"Iqaluit", "Nunavut", "CA", 63.7467, -68.5170, "America/Iqaluit"},
+	{"Nuuk", "Sermersooq", "GL", 64.1814, -51.6941, ""},
+	{"Mexico City", "Mexico City", "MX", 19.4326, -99.1332, ""},
+	{"Guadalajara", "Jalisco", "MX", 20.6597, -103.3496, ""},
+	{"Monterrey", "Nuevo León", "MX", 25.6866, -100.3161, "America/Monterrey"},
+	{"Chihuahua", "Chihuahua", "MX", 28.6320, -106.0691, "America/Chihuahua"},
+	{"Hermosillo", "Sonora", "MX", 29.0729, -110.9559, "America/Hermosillo"},
+	{"Tijuana", "Baja California", "MX", 32.5149, -117.0382, "America/Tijuana"},
+	{"Mérida", "Yucatán", "MX", 20.9674, -89.5926, "America/Merida"},
+	{"Cancún", "Quintana Roo", "MX", 21.1619, -86.8515, "America/Cancun"},
+
+	// Central America and the Caribbean.
+	{"Guatemala City", "Guatemala", "GT", 14.6349, -90.5069, ""},
+	{"Tegucigalpa", "Francisco Morazán", "HN", 14.0723, -87.1921, ""},
+	{"San Salvador", "San Salvador", "SV", 13.6929, -89.2182, ""},
+	{"Managua", "Managua", "NI", 12.1150, -86.2362, ""},
+	{"San José", "San José", "CR", 9.9281, -84.0907, ""},
+	{"Panama City", "Panamá", "PA", 8.9824, -79.5199, ""},
+	{"Havana", "Havana", "CU", 23.1136, -82.3666, ""},
+	{"Kingston", "Kingston", "JM", 18.0179, -76.8099, ""},
+	{"Santo Domingo", "Distrito Nacional", "DO", 18.4861, -69.9312, ""},
+	{"San Juan", "San Juan", "PR", 18.4655, -66.1057, ""},
+	{"Port of Spain", "Port of Spain", "TT", 10.6549, -61.5019, ""},
+
+	// South America.
+	{"Bogotá", "Bogotá", "CO", 4.7110, -74.0721, ""},
+	{"Medellín", "Antioquia", "CO", 6.2442, -75.5812, ""},
+	{"Caracas", "Capital District", "VE", 10.4806, -66.9036, ""},
+	{"Quito", "Pichincha", "EC", -0.1807, -78.4678, ""},
+	{"Guayaquil", "Guayas", "EC", -2.1710, -79.9224, ""},
+	{"Lima", "Lima", "PE", -12.0464, -77.0428, ""},
+	{"Cusco", "Cusco", "PE", -13.5320, -71.9675, ""},
+	{"La Paz", "La Paz", "BO", -16.4897, -68.1193, ""},
+	{"Santa Cruz de la Sierra", "Santa Cruz", "BO", -17.8146, -63.1561, ""},
+	{"Santiago", "Santiago Metropolitan", "CL", -33.4489, -70.6693, ""},
+	{"Antofagasta", "Antofagasta", "CL", -23.6509, -70.3975, ""},
+	{"Punta Arenas", "Magallanes", "CL", -53.1638, -70.9171, "America/Punta_Arenas"},
+	{"Buenos Aires", "Buenos Aires", "AR", -34.6037, -58.3816, ""},
+	{"Córdoba", "Córdoba", "AR", -31.4201, -64.1888, "America/Argentina/Cordoba"},
+	{"Mendoza", "Mendoza", "AR", -32.8895, -68.8458, "America/Argentina/Mendoza"},
+	{"Ushuaia", "Tierra del Fuego", "AR", -54.8019, -68.3030, "America/Argentina/Ushuaia"},
+	{"Montevideo", "Montevideo", "UY", -34.9011, -56.1645, ""},
+	{"Asunción", "Asunción", "PY", -25.2637, -57.5759, ""},
+	{"São Paulo", "São Paulo", "BR", -23.5505, -46.6333, ""},
+	{"Rio de Janeiro", "Rio de Janeiro", "BR", -22.9068, -43.1729, ""},
+	{"Guarapari", "Espírito Santo", "BR", -20.6667, -40.4975, ""},
+	{"Brasília", "Federal District", "BR", -15.8267, -47.9218, ""},
+	{"Goiânia", "Goiás", "BR", -16.6869, -49.2648, ""},
+	{"Porto Alegre", "Rio Grande do Sul", "BR", -30.0346, -51.2177, ""},
+	{"Salvador", "Bahia", "BR", -12.9777, -38.5016, "America/Bahia"},
+	{"Recife", "Pernambuco", "BR", -8.0476, -34.8770, "America/Recife"},
+	{"Fortaleza", "Ceará", "BR", -3.7319, -38.5267, "America/Fortaleza"},
+	{"Belém", "Pará", "BR", -1.4558, -48.4902, "America/Belem"},
+	{"Manaus", "Amazonas", "BR", -3.1190, -60.0217, "America/Manaus"},
+
+	// Africa.
+	{"Cairo", "Cairo", "EG", 30.0444, 31.2357, ""},
+	{"Alexandria", "Alexandria", "EG", 31.2001, 29.9187, ""},
+	{"Aswan", "Aswan", "EG", 24.0889, 32.8998, ""},
+	{"Tripoli", "Tripoli", "LY", 32.8872, 13.1913, ""},
+	{"Tunis", "Tunis", "TN", 36.8065, 10.1815, ""},
+	{"Algiers", "Algiers", "DZ", 36.7538, 3.0588, ""},
+	{"Rabat", "Rabat-Salé-Kénitra", "MA", 34.0209, -6.8416, ""},
+	{"Casablanca", "Casablanca-Settat", "MA", 33.5731, -7.5898, ""},
+	{"Marrakesh", "Marrakesh-Safi", "MA", 31.6295, -7.9811, ""},
+	{"Dakar", "Dakar", "SN", 14.7167, -17.4677, ""},
+	{"Niamey", "Niamey", "NE", 13.5116, 2.1254, ""},
+	{"Arlit", "Agadez", "NE", 18.7369, 7.3853, ""},
+	{"Accra", "Greater Accra", "GH", 5.6037, -0.1870, ""},
+	{"Lagos", "Lagos", "NG", 6.5244, 3.3792, ""},
+	{"Abuja", "Federal Capital Territory", "NG", 9.0765, 7.3986, ""},
+	{"Kano", "Kano", "NG", 12.0022, 8.5920, ""},
+	{"Khartoum", "Khartoum", "SD", 15.5007, 32.5599, ""},
+	{"Addis Ababa", "Addis Ababa", "ET", 8.9806, 38.7578, ""},
+	{"Kampala", "Central Region", "UG", 0.3476, 32.5825, ""},
+	{"Nairobi", "Nairobi", "KE", -1.2921, 36.8219, ""},
+	{"Mombasa", "Mombasa", "KE", -4.0435, 39.6682, ""},
+	{"Dar es Salaam", "Dar es Salaam", "TZ", -6.7924, 39.2083, ""},
+	{"Kinshasa", "Kinshasa", "CD", -4.4419, 15.2663, ""},
+	{"Lubumbashi", "Haut-Katanga", "CD", -11.6876, 27.5026, "Africa/Lubumbashi"},
+	{"Luanda", "Luanda", "AO", -8.8390, 13.2894, ""},
+	{"Lusaka", "Lusaka", "ZM", -15.3875, 28.3228, ""},
+	{"Harare", "Harare", "ZW", -17.8252, 31.0335, ""},
+	{"Windhoek", "Khomas", "NA", -22.5609, 17.0658, ""},
+	{"Antananarivo", "Analamanga", "MG", -18.8792, 47.5079, ""},
+	{"Johannesburg", "Gauteng", "ZA", -26.2041, 28.0473, ""},
+	{"Pretoria", "Gauteng", "ZA", -25.7479, 28.2293, ""},
+	{"Durban", "KwaZulu-Natal", "ZA", -29.8587, 31.0218, ""},
+	{"Cape Town", "Western Cape", "ZA", -33.9249, 18.4241, ""},
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ── Geodesy helpers ────────────────────────────────────────────────────────
+
+const earthRadiusM = 6371008.8
+
+// haversineMeters is the great-circle distance between two points.
+func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1, φ2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dφ := (lat2 - lat1) * math.Pi / 180
+	dλ := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dφ/2)*math.Sin(dφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(dλ/2)*math.Sin(dλ/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// initialBearing is the compass bearing in degrees (0 = north, clockwise)
+// from the first point towards the second.
+func initialBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1, φ2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dλ := (lon2 - lon1) * math.Pi / 180
+	y := math.Sin(dλ) * math.Cos(φ2)
+	x := math.Cos(φ1)*math.Sin(φ2) - math.Sin(φ1)*math.Cos(φ2)*math.Cos(dλ)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+var compassPoints = [...]string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// compassPoint names the 8-point direction of a bearing.
+func compassPoint(bearing float64) string {
+	return compassPoints[int(math.Round(bearing/45))%8]
+}
+
+// distancePhrase formats a distance the way people say it: "350 m",
+// "1.2 km", "14 km".
+func distancePhrase(m float64) string {
+	switch {
+	case m < 1000:
+		return fmt.Sprintf("%.0f m", math.Round(m/10)*10)
+	case m < 10000:
+		return fmt.Sprintf("%.1f km", m/1000)
+	default:
+		return fmt.Sprintf("%.0f km", m/1000)
+	}
+}
+
+// relativePosition describes where a point lies from the query point, e.g.
+// "1.2 km NE of the requested point".
+func relativePosition(fromLat, fromLon, lat, lon float64) (distanceM, bearing float64, phrase string) {
+	distanceM = haversineMeters(fromLat, fromLon, lat, lon)
+	if distanceM < 10 {
+		return distanceM, 0, "at the requested point"
+	}
+	bearing = initialBearing(fromLat, fromLon, lat, lon)
+	return distanceM, bearing, fmt.Sprintf("%s %s of the requested point", distancePhrase(distanceM), compassPoint(bearing))
+}
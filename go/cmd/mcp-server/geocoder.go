@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Place names ────────────────────────────────────────────────────────────
+//
+// query_radiation and list_sensors take coordinates, so "radiation in
+// Koriyama" needed the model to guess them first, and its guesses were
+// often kilometres off. place=<name> resolves the name server-side:
+// resolvePlaceName, a tool middleware like resolveSavedRegion, fills in
+// lat/lon (query_radiation) or the bounding box (list_sensors) before the
+// handler runs, and the result says what the name was resolved to.
+//
+// Names are looked up in the offline gazetteer (gazetteer.go) first:
+// cities, optionally qualified with their region or country ("Date,
+// Fukushima"), and countries with a bounding box. When GEOCODER_URL points
+// at a Nominatim-compatible service, names the gazetteer does not know are
+// sent there. Answers, including "not found", are cached for
+// GEOCODER_CACHE_TTL.
+
+// placeParamDescription is shared by the tools that accept place.
+const placeParamDescription = "Place name to search around, resolved by the server (e.g. 'Koriyama', 'Date, Fukushima', 'Japan'). Fills in the coordinates; explicit coordinates take precedence."
+
+// placeBoxKm is the half-width of the box list_sensors searches around a
+// place resolved to a point.
+const placeBoxKm = 25
+
+// geocoderCacheMax bounds the geocoder cache; it is emptied when full.
+const geocoderCacheMax = 10000
+
+// geocodeResult is what a place name resolved to.
+type geocodeResult struct {
+	Name         string
+	Lat, Lon     float64
+	BBox         *[4]float64 // minLat, maxLat, minLon, maxLon; nil for a point
+	Country      string
+	Kind         string // "city" or "country" from the gazetteer, the service's type otherwise
+	Source       string // "gazetteer" or the service's host
+	Alternatives []string
+}
+
+// geocoder resolves place names. ok is false when the name is unknown;
+// err is for a lookup that could not be made.
+type geocoder interface {
+	geocode(ctx context.Context, query string) (res geocodeResult, ok bool, err error)
+}
+
+// placeGeocoder is the geocoder of the place parameter.
+var placeGeocoder = newPlaceGeocoderFromEnv()
+
+func newPlaceGeocoderFromEnv() *cachedGeocoder {
+	chain := geocoderChain{gazetteerGeocoder{}}
+	if base := os.Getenv("GEOCODER_URL"); base != "" {
+		chain = append(chain, newNominatimGeocoder(base))
+	}
+	return &cachedGeocoder{next: chain, ttl: durationEnv("GEOCODER_CACHE_TTL", 24*time.Hour)}
+}
+
+// geocoderChain asks each geocoder in turn until one knows the name.
+type geocoderChain []geocoder
+
+func (c geocoderChain) geocode(ctx context.Context, query string) (geocodeResult, bool, error) {
+	for _, g := range c {
+		res, ok, err := g.geocode(ctx, query)
+		if err != nil || ok {
+			return res, ok, err
+		}
+	}
+	return geocodeResult{}, false, nil
+}
+
+// gazetteerGeocoder looks names up in the offline gazetteer.
+type gazetteerGeocoder struct{}
+
+func (gazetteerGeocoder) geocode(_ context.Context, query string) (geocodeResult, bool, error) {
+	name, qualifier, _ := strings.Cut(query, ",")
+	key, qual := compactKey(name), compactKey(qualifier)
+	if key == "" {
+		return geocodeResult{}, false, nil
+	}
+
+	var hits []*gazetteerCity
+	for i := range gazetteerCities {
+		c := &gazetteerCities[i]
+		if compactKey(c.name) != key {
+			continue
+		}
+		if qual != "" && qual != compactKey(c.admin1) && qual != compactKey(gazetteerCountries[c.cc].name) && qual != compactKey(c.cc) {
+			continue
+		}
+		hits = append(hits, c)
+	}
+	if len(hits) > 0 {
+		c := hits[0]
+		res := geocodeResult{
+			Name:    cityDisplayName(c),
+			Lat:     c.lat,
+			Lon:     c.lon,
+			Country: gazetteerCountries[c.cc].name,
+			Kind:    "city",
+			Source:  "gazetteer",
+		}
+		for _, alt := range hits[1:] {
+			res.Alternatives = append(res.Alternatives, cityDisplayName(alt))
+		}
+		return res, true, nil
+	}
+
+	if qual != "" {
+		return geocodeResult{}, false, nil
+	}
+	country, box, ok := lookupCountry(name)
+	if !ok {
+		return geocodeResult{}, false, nil
+	}
+	for _, c := range gazetteerCountries {
+		if c.box == country {
+			country = c.name
+			break
+		}
+	}
+	return geocodeResult{
+		Name:    country,
+		Lat:     round6((box[0] + box[1]) / 2),
+		Lon:     round6((box[2] + box[3]) / 2),
+		BBox:    &box,
+		Country: country,
+		Kind:    "country",
+		Source:  "gazetteer",
+	}, true, nil
+}
+
+// cityDisplayName is "city, region, country" without repeats.
+func cityDisplayName(c *gazetteerCity) string {
+	parts := []string{c.name}
+	if c.admin1 != "" && c.admin1 != c.name {
+		parts = append(parts, c.admin1)
+	}
+	if country := gazetteerCountries[c.cc].name; country != "" {
+		parts = append(parts, country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// placeNameCandidates are the names suggested when a place is unknown.
+func placeNameCandidates() []string {
+	names := countryNames()
+	for _, c := range gazetteerCities {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// nominatimGeocoder queries a Nominatim-compatible /search endpoint.
+type nominatimGeocoder struct {
+	base   string
+	client *http.Client
+}
+
+func newNominatimGeocoder(base string) *nominatimGeocoder {
+	return &nominatimGeocoder{
+		base:   strings.TrimRight(base, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *nominatimGeocoder) geocode(ctx context.Context, query string) (geocodeResult, bool, error) {
+	q := url.Values{"q": {query}, "format": {"jsonv2"}, "limit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.base+"/search?"+q.Encode(), nil)
+	if err != nil {
+		return geocodeResult{}, false, err
+	}
+	// Nominatim's usage policy asks for an identifying User-Agent.
+	req.Header.Set("User-Agent", serverName+"/"+fullVersion())
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return geocodeResult{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return geocodeResult{}, false, fmt.Errorf("geocoder returned %s", resp.Status)
+	}
+
+	var places []struct {
+		Lat         string   `json:"lat"`
+		Lon         string   `json:"lon"`
+		DisplayName string   `json:"display_name"`
+		Type        string   `json:"type"`
+		AddressType string   `json:"addresstype"`
+		BoundingBox []string `json:"boundingbox"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return geocodeResult{}, false, fmt.Errorf("decoding geocoder response: %w", err)
+	}
+	if len(places) == 0 {
+		return geocodeResult{}, false, nil
+	}
+	p := places[0]
+	lat, errLat := strconv.ParseFloat(p.Lat, 64)
+	lon, errLon := strconv.ParseFloat(p.Lon, 64)
+	if err := errors.Join(errLat, errLon); err != nil {
+		return geocodeResult{}, false, fmt.Errorf("geocoder returned bad coordinates: %w", err)
+	}
+	res := geocodeResult{Name: p.DisplayName, Lat: lat, Lon: lon, Kind: p.AddressType, Source: n.host()}
+	if res.Kind == "" {
+		res.Kind = p.Type
+	}
+	if len(p.BoundingBox) == 4 {
+		var box [4]float64
+		var bad bool
+		for i, s := range p.BoundingBox {
+			v, err := strconv.ParseFloat(s, 64)
+			box[i], bad = v, bad || err != nil
+		}
+		if !bad && (box[1]-box[0] > 0.01 || box[3]-box[2] > 0.01) {
+			res.BBox = &box
+		}
+	}
+	return res, true, nil
+}
+
+func (n *nominatimGeocoder) host() string {
+	if u, err := url.Parse(n.base); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return n.base
+}
+
+// cachedGeocoder remembers the answers of next for ttl, unknown names
+// included. Failed lookups are not cached.
+type cachedGeocoder struct {
+	next geocoder
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]geocodeEntry
+}
+
+type geocodeEntry struct {
+	res geocodeResult
+	ok  bool
+	at  time.Time
+}
+
+// geocode resolves query; cached reports whether the answer was cached.
+func (c *cachedGeocoder) geocode(ctx context.Context, query string) (res geocodeResult, ok, cached bool, err error) {
+	key := matchKey(query)
+	c.mu.Lock()
+	e, hit := c.entries[key]
+	c.mu.Unlock()
+	if hit && time.Since(e.at) < c.ttl {
+		return e.res, e.ok, true, nil
+	}
+
+	res, ok, err = c.next.geocode(ctx, query)
+	if err != nil || c.ttl <= 0 {
+		return res, ok, false, err
+	}
+	c.mu.Lock()
+	if c.entries == nil || len(c.entries) >= geocoderCacheMax {
+		c.entries = map[string]geocodeEntry{}
+	}
+	c.entries[key] = geocodeEntry{res: res, ok: ok, at: time.Now()}
+	c.mu.Unlock()
+	return res, ok, false, nil
+}
+
+// placeTools are the tools that accept place, with how a resolved place
+// fills in their arguments.
+var placeTools = map[string]func(args map[string]any, g geocodeResult){
+	"query_radiation": func(args map[string]any, g geocodeResult) {
+		setMissing(args, map[string]float64{"lat": g.Lat, "lon": g.Lon})
+	},
+	"list_sensors": func(args map[string]any, g geocodeResult) {
+		box := placeBox(g)
+		setMissing(args, map[string]float64{"min_lat": box[0], "max_lat": box[1], "min_lon": box[2], "max_lon": box[3]})
+	},
+}
+
+// setMissing copies the values whose keys args does not have.
+func setMissing(args map[string]any, values map[string]float64) {
+	for k, v := range values {
+		if _, set := args[k]; !set {
+			args[k] = v
+		}
+	}
+}
+
+// placeBox is the bounding box of a place, or placeBoxKm around a point.
+func placeBox(g geocodeResult) [4]float64 {
+	if g.BBox != nil {
+		return *g.BBox
+	}
+	dLat := placeBoxKm / aggregateKmPerDeg
+	dLon := dLat / math.Max(math.Cos(g.Lat*math.Pi/180), 0.01)
+	return [4]float64{
+		round6(math.Max(g.Lat-dLat, -90)), round6(math.Min(g.Lat+dLat, 90)),
+		round6(math.Max(g.Lon-dLon, -180)), round6(math.Min(g.Lon+dLon, 180)),
+	}
+}
+
+// resolvePlaceName is a tool middleware that resolves place=<name>.
+func resolvePlaceName(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		fill := placeTools[req.Params.Name]
+		args, ok := req.Params.Arguments.(map[string]any)
+		query, _ := args["place"].(string)
+		query = strings.Join(strings.Fields(query), " ")
+		if fill == nil || !ok || query == "" {
+			return next(ctx, req)
+		}
+
+		g, found, cached, err := placeGeocoder.geocode(ctx, query)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not resolve place %q: %v. Pass lat and lon instead.", query, err)), nil
+		}
+		if !found {
+			msg := fmt.Sprintf("Place %q was not found.", query)
+			if s := closestMatches(query, placeNameCandidates(), 3); len(s) > 0 {
+				msg += fmt.Sprintf(" Did you mean '%s'?", strings.Join(s, "', '"))
+			}
+			return mcp.NewToolResultError(msg + " Pass coordinates instead."), nil
+		}
+
+		resolved := make(map[string]any, len(args)+4)
+		for k, v := range args {
+			resolved[k] = v
+		}
+		delete(resolved, "place")
+		fill(resolved, g)
+		req.Params.Arguments = resolved
+
+		res, err := next(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			block := map[string]any{
+				"query":  query,
+				"name":   g.Name,
+				"lat":    g.Lat,
+				"lon":    g.Lon,
+				"kind":   g.Kind,
+				"source": g.Source,
+				"cached": cached,
+			}
+			if g.Country != "" {
+				block["country"] = g.Country
+			}
+			if g.BBox != nil {
+				block["bbox"] = g.BBox[:]
+			}
+			if len(g.Alternatives) > 0 {
+				block["alternatives"] = g.Alternatives
+				block["note"] = "The name matches several places; qualify it with the region or country (e.g. 'Date, Fukushima') to pick another."
+			}
+			m["geocoded"] = block
+		}), nil
+	}
+}
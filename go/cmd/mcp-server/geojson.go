@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── GeoJSON output ─────────────────────────────────────────────────────────
+//
+// output_format: "geojson" on query_radiation, search_area and get_track
+// (and output_format=geojson on their REST routes) returns the result as a
+// GeoJSON FeatureCollection that Leaflet, QGIS or geojson.io load as it
+// is: one Point feature per measurement, [lon, lat], with the other fields
+// of the measurement as properties. The fields around the measurements
+// (count, source, notes) stay as foreign members of the collection; a
+// bbox object of the query becomes query_bbox, since bbox is the
+// collection's extent in GeoJSON.
+
+const outputFormatGeoJSON = "geojson"
+
+// geojsonTools are the tools that take output_format.
+var geojsonTools = map[string]bool{
+	"query_radiation": true,
+	"search_area":     true,
+	"get_track":       true,
+}
+
+// withOutputFormatParam adds output_format to a measurement tool.
+func withOutputFormatParam() mcp.ToolOption {
+	return mcp.WithString("output_format",
+		mcp.Description("json (default) or geojson: a GeoJSON FeatureCollection with a Point feature per measurement, for Leaflet or QGIS"),
+		mcp.Enum("json", outputFormatGeoJSON),
+	)
+}
+
+// geojsonResults is a tool middleware that returns the results of calls
+// made with output_format: "geojson" as a FeatureCollection.
+func geojsonResults(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !geojsonTools[req.Params.Name] || !strings.EqualFold(req.GetString("output_format", ""), outputFormatGeoJSON) {
+			return next(ctx, req)
+		}
+		if req.GetBool("compact", false) {
+			return mcp.NewToolResultError("compact and output_format=geojson cannot be combined; choose one"), nil
+		}
+		res, err := next(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		return geojsonResult(res), nil
+	}
+}
+
+// geojsonResult rewrites a measurement result as a FeatureCollection.
+// Results without measurements (errors, explain_only plans) are returned
+// as they are.
+func geojsonResult(res *mcp.CallToolResult) *mcp.CallToolResult {
+	if res == nil || res.IsError || len(res.Content) != 1 {
+		return res
+	}
+	tc, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		return res
+	}
+	dec := json.NewDecoder(strings.NewReader(tc.Text))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil || measurementRows(m) == nil {
+		return res
+	}
+	data, err := json.MarshalIndent(measurementCollection(m), "", "  ")
+	if err != nil {
+		return res
+	}
+	out := mcp.NewToolResultText(string(data))
+	out.Meta = res.Meta
+	return out
+}
+
+// measurementCollection turns a decoded measurement result into a
+// FeatureCollection.
+func measurementCollection(m map[string]any) map[string]any {
+	rows := measurementRows(m)
+	out := make(map[string]any, len(m)+2)
+	for k, v := range m {
+		switch k {
+		case "measurements", "readings":
+		case "bbox":
+			out["query_bbox"] = v
+		default:
+			out[k] = v
+		}
+	}
+
+	features := make([]any, 0, len(rows))
+	minLon, minLat, maxLon, maxLat := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, row := range rows {
+		r, ok := row.(map[string]any)
+		if !ok {
+			continue
+		}
+		if lat, lon, ok := itemLocation(r); ok {
+			minLon, maxLon = math.Min(minLon, lon), math.Max(maxLon, lon)
+			minLat, maxLat = math.Min(minLat, lat), math.Max(maxLat, lat)
+		}
+		features = append(features, measurementFeature(r))
+	}
+	out["type"] = "FeatureCollection"
+	out["features"] = features
+	if !math.IsInf(minLon, 1) {
+		out["bbox"] = []float64{minLon, minLat, maxLon, maxLat}
+	}
+	return out
+}
+
+// measurementFeature turns one measurement into a Point feature; one
+// without a location gets a null geometry.
+func measurementFeature(r map[string]any) map[string]any {
+	props := make(map[string]any, len(r))
+	for k, v := range r {
+		if k != "location" {
+			props[k] = v
+		}
+	}
+	f := map[string]any{
+		"type":       "Feature",
+		"geometry":   nil,
+		"properties": props,
+	}
+	if lat, lon, ok := itemLocation(r); ok {
+		f["geometry"] = map[string]any{"type": "Point", "coordinates": []float64{lon, lat}}
+	}
+	if id, ok := r["id"]; ok && id != nil {
+		f["id"] = id
+	}
+	return f
+}
+
+// ── REST ──
+
+const geojsonContentType = "application/geo+json"
+
+// restOutputFormat reads output_format from a REST request. It writes the
+// error response and returns ok false for an unknown format.
+func restOutputFormat(w http.ResponseWriter, r *http.Request) (geojson, ok bool) {
+	switch strings.ToLower(r.URL.Query().Get("output_format")) {
+	case "", "json":
+		return false, true
+	case outputFormatGeoJSON:
+		return true, true
+	}
+	writeError(w, http.StatusBadRequest, "output_format must be json or geojson")
+	return false, false
+}
+
+// serveMCPResultFormat serves a measurement result as JSON, or as a
+// FeatureCollection when geojson is set.
+func serveMCPResultFormat(w http.ResponseWriter, result *mcp.CallToolResult, err error, geojson bool) {
+	if !geojson {
+		serveMCPResult(w, result, err)
+		return
+	}
+	serveMCPResultAs(w, geojsonResult(result), err, geojsonContentType)
+}
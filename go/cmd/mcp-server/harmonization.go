@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Detector harmonization ─────────────────────────────────────────────────
+//
+// Markers store the dose rate each detector reported, converted with its
+// own Cs-137 calibration. Tubes and scintillators respond differently to
+// the softer spectrum of natural background and fallout, so two detectors
+// side by side disagree, and statistics over a mix of detectors (or a
+// comparison of an area measured with one model against an area measured
+// with another) inherit that bias. harmonize: true on the stats and
+// comparison tools multiplies each reading by the response correction of
+// its detector family, giving reference-detector-equivalent dose rates,
+// and reports which corrections were applied, where they come from and how
+// many readings had none.
+//
+// The reference is the bGeigie's LND 7317 with its Cs-137 calibration,
+// which most of the dataset was measured with; the built-in table only
+// lists the detectors sharing that tube and calibration (factor 1).
+// Published corrections for other detectors are rows of the optional
+// detector_response_corrections table
+// (migrations/add_detector_response_corrections.sql), each with its
+// source, so no factor is applied without a citation.
+
+const harmonizationReference = "bGeigie (LND 7317), Cs-137 calibration at 334 CPM per µSv/h"
+
+// detectorResponseRefresh is how long corrections read from the database
+// are reused.
+const detectorResponseRefresh = 5 * time.Minute
+
+// detectorResponse is the correction for one detector family: stored dose
+// rates are multiplied by Factor to give the reference detector's.
+type detectorResponse struct {
+	Key    string // compactKey prefix of the detector names it covers
+	Tube   string
+	Factor float64
+	Source string
+	Origin string // "built-in" or "database"
+}
+
+// builtinDetectorResponses are the detectors that need no correction.
+var builtinDetectorResponses = []detectorResponse{
+	{Key: "bgeigie", Tube: "LND 7317", Factor: 1, Source: "Reference detector"},
+	{Key: "ngeigie", Tube: "LND 7317", Factor: 1, Source: "Same tube and calibration as the reference"},
+	{Key: "pointcast", Tube: "LND 7318", Factor: 1, Source: "Same pancake tube geometry and calibration (334 CPM per µSv/h) as the reference"},
+}
+
+var responseOverrides struct {
+	mu       sync.Mutex
+	rows     []detectorResponse
+	loadedAt time.Time
+}
+
+// loadResponseOverrides returns the rows of detector_response_corrections,
+// re-read at most every detectorResponseRefresh. Without a database or the
+// table it is empty.
+func loadResponseOverrides() []detectorResponse {
+	responseOverrides.mu.Lock()
+	defer responseOverrides.mu.Unlock()
+	if !dbAvailable() || time.Since(responseOverrides.loadedAt) < detectorResponseRefresh {
+		return responseOverrides.rows
+	}
+	responseOverrides.loadedAt = time.Now()
+
+	// Not the call's context: an explain_only preview should not list this.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := queryRows(ctx, `
+		SELECT detector, factor, COALESCE(tube, '') AS tube, source
+		FROM detector_response_corrections
+		WHERE factor > 0`)
+	if err != nil {
+		if !strings.Contains(err.Error(), "does not exist") {
+			log.Printf("Reading detector_response_corrections: %v", err)
+		}
+		return responseOverrides.rows
+	}
+	loaded := make([]detectorResponse, 0, len(rows))
+	for _, r := range rows {
+		name, _ := r["detector"].(string)
+		factor, ok := toFloat(r["factor"])
+		if key := compactKey(name); key != "" && ok {
+			tube, _ := r["tube"].(string)
+			source, _ := r["source"].(string)
+			loaded = append(loaded, detectorResponse{Key: key, Tube: tube, Factor: factor, Source: source, Origin: "database"})
+		}
+	}
+	responseOverrides.rows = loaded
+	return loaded
+}
+
+// responseTerm is a compact prefix a detector name must start with to take
+// a correction: its key or an alias in the key's family.
+type responseTerm struct {
+	term     string
+	response detectorResponse
+}
+
+// responseTerms are the terms of the corrections in effect, longest first,
+// so the most specific correction wins. Database rows come before
+// built-in entries, and a term is only taken once.
+func responseTerms() []responseTerm {
+	responses := slices.Clone(loadResponseOverrides())
+	for _, b := range builtinDetectorResponses {
+		b.Origin = "built-in"
+		responses = append(responses, b)
+	}
+	var out []responseTerm
+	seen := map[string]bool{}
+	for _, r := range responses {
+		for _, t := range detectorAliases(r.Key) {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, responseTerm{term: t, response: r})
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b responseTerm) int { return len(b.term) - len(a.term) })
+	return out
+}
+
+type harmonizeKey struct{}
+
+// withHarmonizeParam adds the harmonize flag to a stats or comparison tool.
+func withHarmonizeParam() mcp.ToolOption {
+	return mcp.WithBoolean("harmonize",
+		mcp.Description("Correct each reading for the response of its detector family, so dose rates from different detectors are comparable; the result lists the corrections applied with their sources and the readings left uncorrected (default: false)"),
+	)
+}
+
+// harmonizeContext records the request's harmonize flag in ctx.
+func harmonizeContext(ctx context.Context, req mcp.CallToolRequest) context.Context {
+	if !req.GetBool("harmonize", false) {
+		return ctx
+	}
+	return context.WithValue(ctx, harmonizeKey{}, true)
+}
+
+func harmonizeDetectors(ctx context.Context) bool {
+	on, _ := ctx.Value(harmonizeKey{}).(bool)
+	return on
+}
+
+// harmonizer applies the corrections to one set of readings and counts
+// the readings of each detector. A nil harmonizer leaves dose rates alone.
+type harmonizer struct {
+	terms    []responseTerm
+	readings map[string]int // by detector name as stored
+}
+
+// newHarmonizer returns a harmonizer when ctx asks for harmonization, and
+// nil otherwise.
+func newHarmonizer(ctx context.Context) *harmonizer {
+	if !harmonizeDetectors(ctx) {
+		return nil
+	}
+	return &harmonizer{terms: responseTerms(), readings: map[string]int{}}
+}
+
+// response finds the correction for a detector name.
+func (h *harmonizer) response(detector string) (detectorResponse, bool) {
+	key := compactKey(detector)
+	if key == "" {
+		return detectorResponse{}, false
+	}
+	for _, t := range h.terms {
+		if strings.HasPrefix(key, t.term) {
+			return t.response, true
+		}
+	}
+	return detectorResponse{}, false
+}
+
+// factor returns the correction factor for one reading of detector and
+// counts the reading.
+func (h *harmonizer) factor(detector string) float64 {
+	if h == nil {
+		return 1
+	}
+	h.readings[detector]++
+	if r, ok := h.response(detector); ok {
+		return r.Factor
+	}
+	return 1
+}
+
+// doseSQL is the SQL expression of the harmonized dose rate, for DuckDB
+// and Postgres alike: dose scaled by the factor matched on the normalized
+// detector column. Keys are compactKey strings (letters and digits), so
+// they are written into the SQL as they are.
+func (h *harmonizer) doseSQL(dose, detectorCol string) string {
+	if h == nil || !slices.ContainsFunc(h.terms, func(t responseTerm) bool { return t.response.Factor != 1 }) {
+		return dose
+	}
+	norm := fmt.Sprintf("regexp_replace(lower(COALESCE(%s, '')), '[^a-z0-9]+', '', 'g')", detectorCol)
+	whens := make([]string, len(h.terms))
+	for i, t := range h.terms {
+		whens[i] = fmt.Sprintf("WHEN %s LIKE '%s%%' THEN %g", norm, t.term, t.response.Factor)
+	}
+	return fmt.Sprintf("(%s * CASE %s ELSE 1 END)", dose, strings.Join(whens, " "))
+}
+
+// detectorCountsQuery counts the readings per detector in the markers
+// matching where, for the provenance of a harmonized result. Like
+// radiationStatsQuery it is valid Postgres once the postgres_db.public.
+// prefix is dropped.
+func detectorCountsQuery(where string) string {
+	return fmt.Sprintf(`
+		SELECT COALESCE(detector, '') AS detector, COUNT(*) AS n
+		FROM postgres_db.public.markers
+		WHERE %s
+		GROUP BY 1`, where)
+}
+
+// addCounts records the rows of detectorCountsQuery.
+func (h *harmonizer) addCounts(rows []map[string]any) {
+	if h == nil {
+		return
+	}
+	for _, r := range rows {
+		detector, _ := r["detector"].(string)
+		if n, ok := toFloat(r["n"]); ok {
+			h.readings[detector] += int(n)
+		}
+	}
+}
+
+// summary describes the harmonization: the corrections applied with their
+// provenance and the number of readings each covered, and the detectors
+// without a correction.
+func (h *harmonizer) summary() map[string]any {
+	type applied struct {
+		response  detectorResponse
+		detectors []string
+		readings  int
+	}
+	byKey := map[string]*applied{}
+	var uncorrected []map[string]any
+	var correctedN, uncorrectedN int
+	for detector, n := range h.readings {
+		r, ok := h.response(detector)
+		if !ok {
+			name := detector
+			if name == "" {
+				name = "(unknown)"
+			}
+			uncorrected = append(uncorrected, map[string]any{"detector": name, "readings": n})
+			uncorrectedN += n
+			continue
+		}
+		a := byKey[r.Key]
+		if a == nil {
+			a = &applied{response: r}
+			byKey[r.Key] = a
+		}
+		a.detectors = append(a.detectors, detector)
+		a.readings += n
+		correctedN += n
+	}
+
+	corrections := make([]map[string]any, 0, len(byKey))
+	for _, a := range byKey {
+		slices.Sort(a.detectors)
+		c := map[string]any{
+			"detector_family": a.response.Key,
+			"detectors":       a.detectors,
+			"factor":          a.response.Factor,
+			"source":          a.response.Source,
+			"origin":          a.response.Origin,
+			"readings":        a.readings,
+		}
+		if a.response.Tube != "" {
+			c["tube"] = a.response.Tube
+		}
+		corrections = append(corrections, c)
+	}
+	byReadings := func(name string) func(a, b map[string]any) int {
+		return func(a, b map[string]any) int {
+			if n := b["readings"].(int) - a["readings"].(int); n != 0 {
+				return n
+			}
+			return strings.Compare(a[name].(string), b[name].(string))
+		}
+	}
+	slices.SortFunc(corrections, byReadings("detector_family"))
+	slices.SortFunc(uncorrected, byReadings("detector"))
+
+	out := map[string]any{
+		"enabled":              true,
+		"reference":            harmonizationReference,
+		"corrections":          corrections,
+		"corrected_readings":   correctedN,
+		"uncorrected_readings": uncorrectedN,
+		"note":                 "Each dose rate was multiplied by the response factor of its detector family to express it as the reference detector would have measured it. Readings of detectors without a published factor are used as stored.",
+	}
+	if len(uncorrected) > 0 {
+		out["uncorrected"] = uncorrected
+		out["uncorrected_note"] = "No response correction is known for these detectors; they still carry their own detector's bias. Corrections are added as rows of detector_response_corrections, with their source."
+	}
+	return out
+}
+
+// addHarmonization adds the provenance of h to a successful result, with
+// the readings per detector read by counts. Without h the result is
+// returned as it is.
+func addHarmonization(res *mcp.CallToolResult, err error, h *harmonizer, counts func() ([]map[string]any, error)) (*mcp.CallToolResult, error) {
+	if err != nil || h == nil || res == nil || res.IsError {
+		return res, err
+	}
+	rows, err := counts()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Detector count query failed: %v", err)), nil
+	}
+	h.addCounts(rows)
+	return amendJSONResult(res, func(m map[string]any) {
+		m["harmonization"] = h.summary()
+	}), nil
+}
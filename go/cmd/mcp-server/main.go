@@ -12,21 +12,76 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == mockCommand {
+		runSafecastMock(os.Args[2:])
+		return
+	}
+	opts := parseFlags()
+
+	// In stdio mode stdout is the protocol stream; keep everything else off it.
+	transport := os.Getenv("MCP_TRANSPORT")
+	stdio := transport == "stdio"
+	protocolOut := setupLogging(stdio)
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
 
-	log.Println("DEBUG: safecast MCP server binary version 2026-02-18-1")
 	// Create MCP server
+	hooks := &server.Hooks{}
+	hooks.AddAfterListTools(annotateToolCosts)
+	hooks.AddAfterListTools(annotateCacheTTL)
+
 	mcpServer := server.NewMCPServer(
-		"safecast-mcp",
-		"1.0.0",
+		serverName,
+		fullVersion(),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(limiter.limitToolCalls),
+		server.WithToolHandlerMiddleware(enforceResponseBudget),
+		server.WithToolHandlerMiddleware(compactResults),
+		server.WithToolHandlerMiddleware(geojsonResults),
+		server.WithToolHandlerMiddleware(resolveSavedRegion),
+		server.WithToolHandlerMiddleware(resolvePlaceName),
+		server.WithToolHandlerMiddleware(suggestOnEmpty),
+		server.WithToolHandlerMiddleware(markDemoResults),
+		server.WithToolHandlerMiddleware(cacheSessionResults),
+		server.WithToolHandlerMiddleware(attachCitation),
+		server.WithToolHandlerMiddleware(pinDatasetSnapshot),
+		server.WithToolHandlerMiddleware(explainQueries),
 	)
+	registerTools(mcpServer)
+	applyConfiguredLimits(mcpServer)
+
+	switch {
+	case opts.version:
+		printVersion(protocolOut)
+		return
+	case opts.config:
+		printConfigSummary(protocolOut)
+		return
+	case opts.listTools:
+		printTools(protocolOut, mcpServer)
+		return
+	case opts.verifyAudit != "":
+		if !runVerifyAudit(protocolOut, opts.verifyAudit) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Printf("DEBUG: safecast MCP server binary version %s (build %s)", fullVersion(), buildVersion)
+
+	var err error
+	if audit, err = openAuditLog(); err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize database connection
 	if os.Getenv("DATABASE_URL") != "" {
 		if err := initDB(); err != nil {
-			log.Printf("Warning: database connection failed: %v (using REST API fallback)", err)
-		} else {
-			log.Println("Connected to PostgreSQL database")
+			log.Printf("Warning: database connection failed: %v (using REST API fallback, reconnecting in the background)", err)
 		}
+	} else if demoModeRequested() {
+		startDemoMode()
 	} else {
 		log.Println("No DATABASE_URL set, using REST API only")
 	}
@@ -36,46 +91,25 @@ func main() {
 		log.Printf("Warning: failed to initialize DuckDB: %v (analytics features disabled)", err)
 	} else {
 		log.Println("Initialized DuckDB analytics engine")
+		warmUpAnalytics()
+		startSnapshotExporter()
 	}
 
-	// Register tools
-	mcpServer.AddTool(
-		mcp.NewTool("ping",
-			mcp.WithDescription("Health check tool"),
-		),
-		instrument("ping", pingHandler),
-	)
-
-	mcpServer.AddTool(queryRadiationToolDef, instrument("query_radiation", handleQueryRadiation))
-	mcpServer.AddTool(searchAreaToolDef, instrument("search_area", handleSearchArea))
-	mcpServer.AddTool(listTracksToolDef, instrument("list_tracks", handleListTracks))
-	mcpServer.AddTool(getTrackToolDef, instrument("get_track", handleGetTrack))
-	mcpServer.AddTool(deviceHistoryToolDef, instrument("device_history", handleDeviceHistory))
-	mcpServer.AddTool(getSpectrumToolDef, instrument("get_spectrum", handleGetSpectrum))
-	mcpServer.AddTool(listSpectraToolDef, instrument("list_spectra", handleListSpectra))
-	mcpServer.AddTool(radiationInfoToolDef, instrument("radiation_info", handleRadiationInfo))
-	mcpServer.AddTool(dbInfoToolDef, instrument("db_info", handleDBInfo))
-	mcpServer.AddTool(listSensorsToolDef, instrument("list_sensors", handleListSensors))
-	mcpServer.AddTool(sensorCurrentToolDef, instrument("sensor_current", handleSensorCurrent))
-	mcpServer.AddTool(sensorHistoryToolDef, instrument("sensor_history", handleSensorHistory))
-	mcpServer.AddTool(queryAnalyticsToolDef, instrument("query_analytics", handleQueryAnalytics))
-	mcpServer.AddTool(radiationStatsToolDef, instrument("radiation_stats", handleRadiationStats))
-	mcpServer.AddTool(queryDuckDBLogsToolDef, instrument("query_duckdb_logs", handleQueryDuckDBLogs))
-	mcpServer.AddTool(queryExtremeReadingsToolDef, instrument("query_extreme_readings", handleQueryExtremeReadings))
-	mcpServer.AddTool(topUploadersToolDef, instrument("top_uploaders", handleTopUploaders))
-	mcpServer.AddTool(searchTracksLocationToolDef, instrument("search_tracks_by_location", handleSearchTracksByLocation))
-
 	// 🚨 TRANSPORT SWITCH
-	if os.Getenv("MCP_TRANSPORT") == "stdio" {
+	if stdio {
 
 		log.Println("Starting MCP server in stdio mode (Claude Desktop)")
 
 		stdioServer := server.NewStdioServer(mcpServer)
+		stdioServer.SetErrorLogger(log.Default())
+		stdioServer.SetContextFunc(func(ctx context.Context) context.Context {
+			return withTransport(ctx, transportStdio)
+		})
 
 		err := stdioServer.Listen(
 			context.Background(),
 			os.Stdin,
-			os.Stdout,
+			protocolOut,
 		)
 
 		if err != nil {
@@ -87,26 +121,40 @@ func main() {
 
 	// Default: HTTP mode (production)
 
+	startReportScheduler(mcpServer)
+	startUploadWatcher()
+
 	baseURL := os.Getenv("MCP_BASE_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:3333"
 	}
 
-	sseServer := server.NewSSEServer(mcpServer,
-		server.WithBaseURL(baseURL),
-		server.WithStaticBasePath("/mcp"),
-	)
-
-	httpServer := server.NewStreamableHTTPServer(mcpServer,
-		server.WithEndpointPath("/mcp-http"),
-	)
+	if accessRules, err = loadAccessPolicy(); err != nil {
+		log.Fatal(err)
+	}
+	oauth, err := loadOAuthResource(baseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux := newHTTPMux(mcpServer, baseURL, oauth)
 
-	mux := http.NewServeMux()
-	mux.Handle("/mcp-http", httpServer)
-	mux.Handle("/mcp/", sseServer) // SSE server handles /mcp/sse and /mcp/message
+	// Unix socket: same HTTP endpoints, no TCP port. For local integrations
+	// (desktop apps, other daemons on the host).
+	if transport == "unix" {
+		socketPath := envOr("MCP_SOCKET", defaultSocketPath)
+		ln, err := listenUnix(socketPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(socketPath)
 
-	rest := &RESTHandler{}
-	rest.Register(mux)
+		log.Printf("Starting MCP server on unix socket %s", socketPath)
+		log.Println("  Streamable HTTP endpoint: /mcp-http")
+		if err := serveUntilSignal(ln, mux); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	port := os.Getenv("MCP_PORT")
 	if port == "" {
@@ -125,7 +173,105 @@ func main() {
 	if err := http.ListenAndServe(listenAddr, mux); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// newHTTPMux mounts the MCP transports and the REST API. With oauth set the
+// transports require a bearer token (oauth.go).
+func newHTTPMux(mcpServer *server.MCPServer, baseURL string, oauth *oauthResource) *http.ServeMux {
+	sseServer := server.NewSSEServer(mcpServer,
+		server.WithBaseURL(baseURL),
+		server.WithStaticBasePath("/mcp"),
+		server.WithSSEContextFunc(sseTransportContext),
+	)
+
+	httpServer := server.NewStreamableHTTPServer(mcpServer,
+		server.WithEndpointPath("/mcp-http"),
+		server.WithHTTPContextFunc(httpTransportContext),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp-http", guardMCPAccess(oauth.protect(limiter.limitSessions(httpServer))))
+	mux.Handle("/mcp/", guardMCPAccess(oauth.protect(limiter.limitSSEStreams(sseServer)))) // SSE server handles /mcp/sse and /mcp/message
+	if oauth != nil {
+		oauth.register(mux)
+	}
+
+	// Everything else goes through the audit trail (audit.go), which
+	// records the /api/ routes.
+	api := http.NewServeMux()
+	rest := &RESTHandler{server: mcpServer}
+	rest.Register(api)
+	mux.Handle("/", audit.middleware(api))
+	return mux
+}
+
+// registerTools adds every tool to the server. It runs before any database
+// connection is made so --list-tools works without credentials.
+func registerTools(mcpServer *server.MCPServer) {
+	// Register tools
+	mcpServer.AddTool(
+		mcp.NewTool("ping",
+			mcp.WithDescription("Health check tool"),
+		),
+		instrument("ping", pingHandler),
+	)
+
+	mcpServer.AddTool(queryRadiationToolDef, instrument("query_radiation", handleQueryRadiation))
+	mcpServer.AddTool(nearestReadingsToolDef, instrument("nearest_readings", handleNearestReadings))
+	mcpServer.AddTool(searchAreaToolDef, instrument("search_area", handleSearchArea))
+	mcpServer.AddTool(exportCSVToolDef, instrument("export_csv", handleExportCSV))
+	mcpServer.AddTool(listTracksToolDef, instrument("list_tracks", handleListTracks))
+	mcpServer.AddTool(getTrackToolDef, instrument("get_track", handleGetTrack))
+	mcpServer.AddTool(trackProfileToolDef, instrument("track_profile", handleTrackProfile))
+	mcpServer.AddTool(trackDoseExposureToolDef, instrument("track_dose_exposure", handleTrackDoseExposure))
+	mcpServer.AddTool(doseContoursToolDef, instrument("dose_contours", handleDoseContours))
+	mcpServer.AddTool(aggregateAreaToolDef, instrument("aggregate_area", handleAggregateArea))
+	mcpServer.AddTool(compareAreasToolDef, instrument("compare_areas", handleCompareAreas))
+	mcpServer.AddTool(deviceHistoryToolDef, instrument("device_history", handleDeviceHistory))
+	mcpServer.AddTool(getSpectrumToolDef, instrument("get_spectrum", handleGetSpectrum))
+	mcpServer.AddTool(listSpectraToolDef, instrument("list_spectra", handleListSpectra))
+	mcpServer.AddTool(spectrumROIRateToolDef, instrument("spectrum_roi_rate", handleSpectrumROIRate))
+	mcpServer.AddTool(spectrumDoseCheckToolDef, instrument("spectrum_dose_check", handleSpectrumDoseCheck))
+	mcpServer.AddTool(radiationInfoToolDef, instrument("radiation_info", handleRadiationInfo))
+	mcpServer.AddTool(dbInfoToolDef, instrument("db_info", handleDBInfo))
+	mcpServer.AddTool(serviceHealthToolDef, instrument("service_health", handleServiceHealth))
+	mcpServer.AddTool(listSensorsToolDef, instrument("list_sensors", handleListSensors))
+	mcpServer.AddTool(sensorCurrentToolDef, instrument("sensor_current", handleSensorCurrent))
+	mcpServer.AddTool(sensorHistoryToolDef, instrument("sensor_history", handleSensorHistory))
+	mcpServer.AddTool(calibrationDriftToolDef, instrument("calibration_drift", handleCalibrationDrift))
+	mcpServer.AddTool(crossValidateSensorToolDef, instrument("cross_validate_sensor", handleCrossValidateSensor))
+	mcpServer.AddTool(queryAnalyticsToolDef, instrument("query_analytics", handleQueryAnalytics))
+	mcpServer.AddTool(radiationStatsToolDef, instrument("radiation_stats", handleRadiationStats))
+	mcpServer.AddTool(queryDuckDBLogsToolDef, instrument("query_duckdb_logs", handleQueryDuckDBLogs))
+	mcpServer.AddTool(queryExtremeReadingsToolDef, instrument("query_extreme_readings", handleQueryExtremeReadings))
+	mcpServer.AddTool(topUploadersToolDef, instrument("top_uploaders", handleTopUploaders))
+	mcpServer.AddTool(recentUploadsToolDef, instrument("recent_uploads", handleRecentUploads))
+	mcpServer.AddTool(searchTracksLocationToolDef, instrument("search_tracks_by_location", handleSearchTracksByLocation))
+	mcpServer.AddTool(nearestTracksToolDef, instrument("nearest_tracks", handleNearestTracks))
+	mcpServer.AddTool(listSupportedRegionsToolDef, instrument("list_supported_regions", handleListSupportedRegions))
+	mcpServer.AddTool(locatePointToolDef, instrument("locate_point", handleLocatePoint))
+	mcpServer.AddTool(searchSafecastToolDef, instrument("search_safecast", handleSearchSafecast))
+	mcpServer.AddTool(resolveTimerangeToolDef, instrument("resolve_timerange", handleResolveTimerange))
+	mcpServer.AddTool(serverChangelogToolDef, instrument("server_changelog", handleServerChangelog))
+	mcpServer.AddTool(findDuplicateUploadsToolDef, instrument("find_duplicate_uploads", handleFindDuplicateUploads))
+	mcpServer.AddTool(saveRegionToolDef, instrument("save_region", handleSaveRegion))
+	mcpServer.AddTool(saveQueryToolDef, instrument("save_query", handleSaveQuery))
+	mcpServer.AddTool(listSavedToolDef, instrument("list_saved", handleListSaved))
+	mcpServer.AddTool(runSavedQueryToolDef, instrument("run_saved_query", runSavedQueryHandler(mcpServer)))
+	mcpServer.AddTool(latestReportToolDef, instrument("latest_report", handleLatestReport))
+	mcpServer.AddTool(scheduleReportToolDef, instrument("schedule_report", scheduleReportHandler(mcpServer)))
+	mcpServer.AddTool(continueResultToolDef, instrument("continue_result", handleContinueResult))
+	mcpServer.AddTool(clearSessionCacheToolDef, instrument("clear_session_cache", handleClearSessionCache))
+	mcpServer.AddTool(selfTestToolDef, instrument("self_test", selfTestHandler(mcpServer)))
+
+	// Maintainer-only tools; hidden unless someone is allowed to use them.
+	if maintainersConfigured() {
+		mcpServer.AddTool(annotateMeasurementToolDef, instrument("annotate_measurement", handleAnnotateMeasurement))
+		mcpServer.AddTool(manageNotificationsToolDef, instrument("manage_notifications", handleManageNotifications))
+		mcpServer.AddTool(analyticsMaintenanceToolDef, instrument("analytics_maintenance", handleAnalyticsMaintenance))
+		mcpServer.AddTool(replayQueryToolDef, instrument("replay_query", replayQueryHandler(mcpServer)))
 	}
+}
 
 // pingHandler is the health check tool implementation.
 func pingHandler(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -140,53 +286,33 @@ func instrument(
 	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 
 		start := time.Now()
-
-		// Extract user info from MCP request arguments
-		userID := ""
-		userEmail := ""
-
-		if req.Params.Arguments != nil {
-
-			if args, ok := req.Params.Arguments.(map[string]any); ok {
-
-				if v, ok := args["user_id"].(string); ok {
-					userID = v
-				}
-
-				if v, ok := args["user_email"].(string); ok {
-					userEmail = v
-				}
-			}
-		}
+		ctx, prov := withProvenance(ctx)
 
 		// Execute tool
 		res, err := h(ctx, req)
+		if err == nil && name != "annotate_measurement" {
+			res = annotations.attach(res)
+		}
+		if err == nil {
+			res = withCacheTTL(name, res)
+			res = addProvenance(name, res, prov, time.Since(start))
+		}
 
 		duration := time.Since(start)
 
-		// Existing DuckDB analytics log
-		resultCount := 0
-		if res != nil {
-			resultCount = len(res.Content)
-		}
+		args, _ := req.Params.Arguments.(map[string]any)
+		LogEvent(toolCallEvent(ctx, name, args, res, err, duration))
+		audit.recordTool(ctx, name, req, res, err)
 
-		args := map[string]any{}
-		if req.Params.Arguments != nil {
-			if argsMap, ok := req.Params.Arguments.(map[string]any); ok {
-				args = argsMap
-			}
+		failed := err != nil || (res != nil && res.IsError)
+		if isSelfTest(ctx) || isReplay(ctx) {
+			return res, err
 		}
-
-		LogQueryAsync(name, args, resultCount, duration, "claude-client")
-
-		logAISessionWithUser(
-			name,
-			"",
-			duration.Milliseconds(),
-			err,
-			userID,
-			userEmail,
-		)
+		if !failed {
+			toolCosts.observe(name, duration, resultSize(res))
+		}
+		metrics.observe(name, duration, failed)
+		slos.observe(name, duration, failed)
 
 		return res, err
 	}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ── Map links ──────────────────────────────────────────────────────────────
+//
+// Every link into the web map is built here, so answers can cite a clickable
+// location whatever tool produced them. MAP_BASE_URL points the links at
+// another map instance (default https://simplemap.safecast.org); it is
+// separate from SIMPLEMAP_URL, which is the API the server reads from and
+// may be a local mock.
+
+const (
+	defaultMapBaseURL = "https://simplemap.safecast.org"
+	defaultMapZoom    = 15
+)
+
+// mapBaseURL is the map instance links point at, without a trailing slash.
+func mapBaseURL() string {
+	if base := os.Getenv("MAP_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return defaultMapBaseURL
+}
+
+// trackMapURL links to the map view of one track.
+func trackMapURL(trackID string) string {
+	return mapBaseURL() + "/trackid/" + url.PathEscape(trackID)
+}
+
+// pointMapURL links to the map centred on a location.
+func pointMapURL(lat, lon float64, zoom int) string {
+	return fmt.Sprintf("%s/?lat=%s&lon=%s&zoom=%d", mapBaseURL(), mapCoord(lat), mapCoord(lon), zoom)
+}
+
+// bboxMapURL links to the map centred on a bounding box, zoomed so the box
+// roughly fits.
+func bboxMapURL(minLat, maxLat, minLon, maxLon float64) string {
+	span := max(maxLat-minLat, maxLon-minLon)
+	zoom := defaultMapZoom
+	if span > 0 {
+		zoom = min(max(int(math.Floor(math.Log2(360/span))), 2), 17)
+	}
+	return pointMapURL((minLat+maxLat)/2, (minLon+maxLon)/2, zoom)
+}
+
+// mapLinkTemplate is the point link pattern the _ai_hint texts show the
+// model, with placeholders such as "LAT" and "LON" for the coordinates.
+func mapLinkTemplate(lat, lon string) string {
+	return fmt.Sprintf("%s/?lat=%s&lon=%s&zoom=%d", mapBaseURL(), lat, lon, defaultMapZoom)
+}
+
+// mapCoord writes a coordinate with at most 6 decimals (about 0.1 m).
+func mapCoord(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', -1, 64)
+}
+
+// addMapLinks walks a result and gives every object that has a location
+// (location.latitude/longitude or location.lat/lon) a map_url for that
+// point, and every object with a track_id a track_map_url, or a map_url
+// when it has no location. Links already present are kept.
+func addMapLinks(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for _, child := range t {
+			addMapLinks(child)
+		}
+		if lat, lon, ok := itemLocation(t); ok {
+			setLink(t, "map_url", pointMapURL(lat, lon, defaultMapZoom))
+			if id := trackIDOf(t); id != "" {
+				setLink(t, "track_map_url", trackMapURL(id))
+			}
+		} else if id := trackIDOf(t); id != "" {
+			setLink(t, "map_url", trackMapURL(id))
+		}
+	case []map[string]any:
+		for _, child := range t {
+			addMapLinks(child)
+		}
+	case []any:
+		for _, child := range t {
+			addMapLinks(child)
+		}
+	}
+}
+
+func itemLocation(m map[string]any) (lat, lon float64, ok bool) {
+	loc, _ := m["location"].(map[string]any)
+	if loc == nil {
+		if l, isFloats := m["location"].(map[string]float64); isFloats {
+			lat, ok1 := l["lat"]
+			lon, ok2 := l["lon"]
+			return lat, lon, ok1 && ok2 && (lat != 0 || lon != 0)
+		}
+		return 0, 0, false
+	}
+	lat, ok1 := toFloat(loc["latitude"])
+	lon, ok2 := toFloat(loc["longitude"])
+	if !ok1 || !ok2 {
+		lat, ok1 = toFloat(loc["lat"])
+		lon, ok2 = toFloat(loc["lon"])
+	}
+	return lat, lon, ok1 && ok2 && (lat != 0 || lon != 0)
+}
+
+func trackIDOf(m map[string]any) string {
+	switch id := m["track_id"].(type) {
+	case string:
+		return id
+	case *string:
+		if id != nil {
+			return *id
+		}
+	}
+	return ""
+}
+
+func setLink(m map[string]any, key, link string) {
+	if s, ok := m[key].(string); !ok || s == "" {
+		m[key] = link
+	}
+}
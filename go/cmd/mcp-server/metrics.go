@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Prometheus metrics ─────────────────────────────────────────────────────
+//
+// GET /metrics serves per-tool call counts, errors and latency histograms in
+// the Prometheus text format, plus the session, event log, database pool
+// (db_client.go) and SLO state (slo.go). There is no client library dependency: the handful of series
+// is written by hand. When METRICS_TOKEN is set the endpoint needs
+// "Authorization: Bearer <token>".
+
+// latencyBuckets are the histogram upper bounds in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type toolCounters struct {
+	calls   int64
+	errors  int64
+	sum     float64 // seconds
+	buckets []int64 // per latencyBuckets entry, non-cumulative
+}
+
+type metricsRegistry struct {
+	mu    sync.Mutex
+	tools map[string]*toolCounters
+}
+
+var metrics = &metricsRegistry{tools: map[string]*toolCounters{}}
+
+// observe records one tool call. Called from instrument().
+func (m *metricsRegistry) observe(name string, d time.Duration, failed bool) {
+	secs := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.tools[name]
+	if !ok {
+		c = &toolCounters{buckets: make([]int64, len(latencyBuckets))}
+		m.tools[name] = c
+	}
+	c.calls++
+	if failed {
+		c.errors++
+	}
+	c.sum += secs
+	for i, le := range latencyBuckets {
+		if secs <= le {
+			c.buckets[i]++
+			break
+		}
+	}
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	metrics.write(w)
+	writeProcessMetrics(w)
+	dbConn.writeMetrics(w)
+	slos.writeMetrics(w)
+}
+
+func (m *metricsRegistry) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.tools))
+	for name := range m.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP mcp_tool_calls_total Tool calls handled.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_calls_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "mcp_tool_calls_total{tool=%q} %d\n", name, m.tools[name].calls)
+	}
+	fmt.Fprintln(w, "# HELP mcp_tool_errors_total Tool calls that returned an error.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_errors_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "mcp_tool_errors_total{tool=%q} %d\n", name, m.tools[name].errors)
+	}
+	fmt.Fprintln(w, "# HELP mcp_tool_duration_seconds Tool call latency.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_duration_seconds histogram")
+	for _, name := range names {
+		c := m.tools[name]
+		var cum int64
+		for i, le := range latencyBuckets {
+			cum += c.buckets[i]
+			fmt.Fprintf(w, "mcp_tool_duration_seconds_bucket{tool=%q,le=\"%g\"} %d\n", name, le, cum)
+		}
+		fmt.Fprintf(w, "mcp_tool_duration_seconds_bucket{tool=%q,le=\"+Inf\"} %d\n", name, c.calls)
+		fmt.Fprintf(w, "mcp_tool_duration_seconds_sum{tool=%q} %g\n", name, c.sum)
+		fmt.Fprintf(w, "mcp_tool_duration_seconds_count{tool=%q} %d\n", name, c.calls)
+	}
+}
+
+// writeProcessMetrics adds gauges and counters kept elsewhere.
+func writeProcessMetrics(w io.Writer) {
+	l := limiter.Stats()
+	e := events.Stats()
+	fmt.Fprintln(w, "# HELP mcp_active_sessions MCP sessions holding a slot.")
+	fmt.Fprintln(w, "# TYPE mcp_active_sessions gauge")
+	fmt.Fprintf(w, "mcp_active_sessions %v\n", l["active_sessions"])
+	fmt.Fprintln(w, "# HELP mcp_in_flight_tool_calls Tool calls currently running.")
+	fmt.Fprintln(w, "# TYPE mcp_in_flight_tool_calls gauge")
+	fmt.Fprintf(w, "mcp_in_flight_tool_calls %v\n", l["in_flight_tool_calls"])
+	rejected := l["rejected_calls"].(map[string]int64)
+	fmt.Fprintln(w, "# HELP mcp_tool_calls_rejected_total Tool calls that found no free slot within MCP_CALL_QUEUE_TIMEOUT, by limit.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_calls_rejected_total counter")
+	fmt.Fprintf(w, "mcp_tool_calls_rejected_total{limit=\"session\"} %d\n", rejected["session"])
+	fmt.Fprintf(w, "mcp_tool_calls_rejected_total{limit=\"tool\"} %d\n", rejected["tool"])
+	if capped, ok := l["tool_concurrency"].(map[string]any); ok {
+		names := make([]string, 0, len(capped))
+		for name := range capped {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(w, "# HELP mcp_tool_concurrency_in_flight Calls running to a tool with a tool_concurrency cap.")
+		fmt.Fprintln(w, "# TYPE mcp_tool_concurrency_in_flight gauge")
+		for _, name := range names {
+			fmt.Fprintf(w, "mcp_tool_concurrency_in_flight{tool=%q} %d\n", name, capped[name].(map[string]int)["in_flight"])
+		}
+		fmt.Fprintln(w, "# HELP mcp_tool_concurrency_limit The tool_concurrency cap of a tool.")
+		fmt.Fprintln(w, "# TYPE mcp_tool_concurrency_limit gauge")
+		for _, name := range names {
+			fmt.Fprintf(w, "mcp_tool_concurrency_limit{tool=%q} %d\n", name, capped[name].(map[string]int)["max"])
+		}
+	}
+	fmt.Fprintln(w, "# HELP mcp_event_log_dropped_total Tool call events dropped because the queue was full.")
+	fmt.Fprintln(w, "# TYPE mcp_event_log_dropped_total counter")
+	fmt.Fprintf(w, "mcp_event_log_dropped_total %v\n", e["dropped"])
+	d := duckWrites.Stats()
+	fmt.Fprintln(w, "# HELP mcp_duckdb_write_queue_depth DuckDB writes waiting for the writer connection.")
+	fmt.Fprintln(w, "# TYPE mcp_duckdb_write_queue_depth gauge")
+	fmt.Fprintf(w, "mcp_duckdb_write_queue_depth %v\n", d["queued"])
+	fmt.Fprintln(w, "# HELP mcp_duckdb_writes_failed_total DuckDB writes that returned an error.")
+	fmt.Fprintln(w, "# TYPE mcp_duckdb_writes_failed_total counter")
+	fmt.Fprintf(w, "mcp_duckdb_writes_failed_total %v\n", d["failed"])
+	fmt.Fprintln(w, "# HELP mcp_shortlinks_created_total Map shortlinks stored for tool results.")
+	fmt.Fprintln(w, "# TYPE mcp_shortlinks_created_total counter")
+	fmt.Fprintf(w, "mcp_shortlinks_created_total %d\n", shortlinkCounters.created.Load())
+	fmt.Fprintln(w, "# HELP mcp_shortlink_clicks_total Redirects served from /s/{code}.")
+	fmt.Fprintln(w, "# TYPE mcp_shortlink_clicks_total counter")
+	fmt.Fprintf(w, "mcp_shortlink_clicks_total %d\n", shortlinkCounters.clicks.Load())
+	hits, misses, entries := sessionCache.stats()
+	fmt.Fprintln(w, "# HELP mcp_session_cache_hits_total Tool calls answered from the session result cache.")
+	fmt.Fprintln(w, "# TYPE mcp_session_cache_hits_total counter")
+	fmt.Fprintf(w, "mcp_session_cache_hits_total %d\n", hits)
+	fmt.Fprintln(w, "# HELP mcp_session_cache_misses_total Cacheable tool calls not found in the session result cache.")
+	fmt.Fprintln(w, "# TYPE mcp_session_cache_misses_total counter")
+	fmt.Fprintf(w, "mcp_session_cache_misses_total %d\n", misses)
+	fmt.Fprintln(w, "# HELP mcp_session_cache_entries Results held in the session result cache.")
+	fmt.Fprintln(w, "# TYPE mcp_session_cache_entries gauge")
+	fmt.Fprintf(w, "mcp_session_cache_entries %d\n", entries)
+	fmt.Fprintln(w, "# HELP mcp_access_denied_total Requests refused by the IP and Origin allowlists.")
+	fmt.Fprintln(w, "# TYPE mcp_access_denied_total counter")
+	fmt.Fprintf(w, "mcp_access_denied_total{reason=\"ip\"} %d\n", accessDenied.ip.Load())
+	fmt.Fprintf(w, "mcp_access_denied_total{reason=\"origin\"} %d\n", accessDenied.origin.Load())
+	if audit != nil {
+		fmt.Fprintln(w, "# HELP mcp_audit_entries_total Entries appended to the audit trail.")
+		fmt.Fprintln(w, "# TYPE mcp_audit_entries_total counter")
+		fmt.Fprintf(w, "mcp_audit_entries_total %d\n", audit.written.Load())
+		fmt.Fprintln(w, "# HELP mcp_audit_write_failures_total Audit entries that could not be written.")
+		fmt.Fprintln(w, "# TYPE mcp_audit_write_failures_total counter")
+		fmt.Fprintf(w, "mcp_audit_write_failures_total %d\n", audit.failed.Load())
+	}
+	fmt.Fprintln(w, "# HELP mcp_uptime_seconds Seconds since the process started.")
+	fmt.Fprintln(w, "# TYPE mcp_uptime_seconds gauge")
+	fmt.Fprintf(w, "mcp_uptime_seconds %d\n", int(time.Since(startedAt).Seconds()))
+	fmt.Fprintln(w, "# HELP mcp_build_info Build of the running binary.")
+	fmt.Fprintln(w, "# TYPE mcp_build_info gauge")
+	fmt.Fprintf(w, "mcp_build_info{version=%q,commit=%q} 1\n", serverVersion, getGitCommit())
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ── safecast-mock: a local simplemap API ───────────────────────────────────
+//
+//   safecast-mcp safecast-mock [-addr :8081] [-fixtures DIR]
+//
+// serves the simplemap endpoints the client uses (demo_simplemap.go) over
+// HTTP, so the REST-fallback paths can be run locally and in integration
+// tests without reaching simplemap.safecast.org:
+//
+//   SIMPLEMAP_URL=http://localhost:8081 safecast-mcp
+//
+// Responses come from the demo dataset. With -fixtures, a JSON file named
+// after the request path is served instead when it exists: /api/tracks
+// reads DIR/api/tracks.json, /api/track/8eh5m1.json reads
+// DIR/api/track/8eh5m1.json, and /realtime_history?device=ID reads
+// DIR/realtime_history/ID.json. Query parameters are otherwise ignored for
+// fixtures, so a fixture is returned as-is for every bbox or radius.
+
+const mockCommand = "safecast-mock"
+
+func runSafecastMock(args []string) {
+	fs := flag.NewFlagSet(mockCommand, flag.ExitOnError)
+	addr := fs.String("addr", ":8081", "listen address")
+	fixtures := fs.String("fixtures", "", "directory of JSON fixtures served before the demo dataset")
+	fs.Parse(args)
+
+	var h http.Handler = newDemoSimplemap(demoData())
+	if *fixtures != "" {
+		if info, err := os.Stat(*fixtures); err != nil || !info.IsDir() {
+			log.Fatalf("%s: -fixtures %s is not a directory", mockCommand, *fixtures)
+		}
+		h = fixtureHandler(*fixtures, h)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	source := "demo dataset"
+	if *fixtures != "" {
+		source = "fixtures in " + *fixtures + ", then demo dataset"
+	}
+	log.Printf("%s: simplemap API on http://%s (%s)", mockCommand, ln.Addr(), source)
+	if err := serveUntilSignal(ln, logMockRequests(h)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fixturePath maps a request to its file under dir.
+func fixturePath(dir string, r *http.Request) string {
+	p := strings.TrimPrefix(filepath.Clean("/"+r.URL.Path), "/")
+	if p == "realtime_history" {
+		p = filepath.Join(p, filepath.Base(filepath.Clean("/"+r.URL.Query().Get("device"))))
+	}
+	if !strings.HasSuffix(p, ".json") {
+		p += ".json"
+	}
+	return filepath.Join(dir, p)
+}
+
+// fixtureHandler serves a fixture file when one matches the request and
+// passes the request on to next otherwise.
+func fixtureHandler(dir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(fixturePath(dir, r))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Mock-Fixture", "true")
+		w.Write(data)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func logMockRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		source := "demo"
+		if w.Header().Get("X-Mock-Fixture") != "" {
+			source = "fixture"
+		}
+		log.Printf("%s %s → %d (%s, %s)", r.Method, r.URL.RequestURI(), rec.status, source, time.Since(start).Round(time.Microsecond))
+	})
+}
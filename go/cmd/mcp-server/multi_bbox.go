@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Several bounding boxes in one call ─────────────────────────────────────
+//
+// Agents comparing cities used to call search_area or list_sensors once per
+// city. Both take bboxes instead: a list of boxes, each with an optional
+// label, answered on the database by one query that unnests the envelopes
+// and joins each to its own index probe (as nearest_readings does for
+// points), with limit applied per box. Results come back grouped per box
+// under "boxes", in the order given. The simplemap fallback of search_area
+// still fetches the boxes one after the other.
+
+const maxQueryBoxes = 20
+
+// withBBoxesParam adds the bboxes parameter; what names the results.
+func withBBoxesParam(what string) mcp.ToolOption {
+	return mcp.WithArray("bboxes",
+		mcp.Description(fmt.Sprintf("Several bounding boxes at once (e.g. one per city, up to %d), each an object with min_lat, max_lat, min_lon, max_lon and an optional label. Replaces min_lat/max_lat/min_lon/max_lon; %s are returned grouped per box and limit applies to each box.", maxQueryBoxes, what)),
+		mcp.Items(map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"min_lat": map[string]any{"type": "number", "minimum": -90, "maximum": 90},
+				"max_lat": map[string]any{"type": "number", "minimum": -90, "maximum": 90},
+				"min_lon": map[string]any{"type": "number", "minimum": -180, "maximum": 180},
+				"max_lon": map[string]any{"type": "number", "minimum": -180, "maximum": 180},
+				"label":   map[string]any{"type": "string"},
+			},
+			"required": []string{"min_lat", "max_lat", "min_lon", "max_lon"},
+		}),
+		mcp.MinItems(1), mcp.MaxItems(maxQueryBoxes),
+	)
+}
+
+// queryBox is one requested bounding box.
+type queryBox struct {
+	MinLat, MaxLat, MinLon, MaxLon float64
+	Label                          string
+}
+
+// parseQueryBoxes reads the bboxes argument. It refuses to mix it with the
+// single-box arguments, which the saved region middleware also sets.
+func parseQueryBoxes(args map[string]any) ([]queryBox, error) {
+	for _, k := range []string{"min_lat", "max_lat", "min_lon", "max_lon"} {
+		if _, set := args[k]; set {
+			return nil, fmt.Errorf("pass either bboxes or min_lat/max_lat/min_lon/max_lon (or region), not both")
+		}
+	}
+	list, ok := args["bboxes"].([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("bboxes must be a non-empty array of {min_lat, max_lat, min_lon, max_lon, label} objects")
+	}
+	if len(list) > maxQueryBoxes {
+		return nil, fmt.Errorf("at most %d bounding boxes can be searched in one call (got %d)", maxQueryBoxes, len(list))
+	}
+	boxes := make([]queryBox, len(list))
+	for i, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("bboxes[%d] must be an object with min_lat, max_lat, min_lon and max_lon", i)
+		}
+		var v [4]float64
+		for j, k := range []string{"min_lat", "max_lat", "min_lon", "max_lon"} {
+			f, ok := toFloat(obj[k])
+			if !ok {
+				return nil, fmt.Errorf("bboxes[%d] needs a numeric %s", i, k)
+			}
+			v[j] = f
+		}
+		b := queryBox{MinLat: v[0], MaxLat: v[1], MinLon: v[2], MaxLon: v[3]}
+		if b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180 {
+			return nil, fmt.Errorf("bboxes[%d]: latitude must be between -90 and 90 and longitude between -180 and 180", i)
+		}
+		if b.MinLat >= b.MaxLat || b.MinLon >= b.MaxLon {
+			return nil, fmt.Errorf("bboxes[%d]: min_lat must be less than max_lat and min_lon less than max_lon", i)
+		}
+		label, _ := obj["label"].(string)
+		b.Label = strings.TrimSpace(label)
+		boxes[i] = b
+	}
+	return boxes, nil
+}
+
+// boxArrays splits boxes into the min_lat, max_lat, min_lon and max_lon
+// arrays the box queries unnest.
+func boxArrays(boxes []queryBox) []any {
+	cols := make([][]float64, 4)
+	for i := range cols {
+		cols[i] = make([]float64, len(boxes))
+	}
+	for i, b := range boxes {
+		cols[0][i], cols[1][i], cols[2][i], cols[3][i] = b.MinLat, b.MaxLat, b.MinLon, b.MaxLon
+	}
+	return []any{cols[0], cols[1], cols[2], cols[3]}
+}
+
+// unnestBoxesSQL names the boxes b(min_lat, max_lat, min_lon, max_lon, idx)
+// from the first four arguments; idx counts from 1.
+const unnestBoxesSQL = `unnest($1::float8[], $2::float8[], $3::float8[], $4::float8[]) WITH ORDINALITY AS b(min_lat, max_lat, min_lon, max_lon, idx)`
+
+// group is the result entry of box i, before its rows are added.
+func (b queryBox) group(i int) map[string]any {
+	g := map[string]any{
+		"index":   i,
+		"map_url": bboxMapURL(b.MinLat, b.MaxLat, b.MinLon, b.MaxLon),
+		"bbox": map[string]any{
+			"min_lat": b.MinLat,
+			"max_lat": b.MaxLat,
+			"min_lon": b.MinLon,
+			"max_lon": b.MaxLon,
+		},
+	}
+	if b.Label != "" {
+		g["label"] = b.Label
+	}
+	return g
+}
+
+// boxedResult assembles a grouped result; key is the row array of each
+// group and count the rows over all groups.
+func boxedResult(groups []map[string]any, key, source string) map[string]any {
+	count := 0
+	for _, g := range groups {
+		if rows, ok := g[key].([]map[string]any); ok {
+			count += len(rows)
+		}
+	}
+	return map[string]any{
+		"count":              count,
+		"box_count":          len(groups),
+		"source":             source,
+		"boxes":              groups,
+		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) Results are grouped per bounding box under 'boxes', in the order requested; report each box (by its label when it has one) separately. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. State only objective facts and measurements.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+}
+
+// ── search_area ──
+
+func handleSearchAreaBoxes(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	boxes, err := parseQueryBoxes(req.GetArguments())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if req.GetString("cursor", "") != "" || req.GetBool("compact", false) || strings.EqualFold(req.GetString("output_format", ""), outputFormatGeoJSON) {
+		return mcp.NewToolResultError("bboxes cannot be combined with cursor, compact or output_format=geojson; search the boxes one at a time for those"), nil
+	}
+	defLimit, maxLimit := toolLimits("search_area", 100, 10000)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+	window, err := dateWindowFromRequest(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	ctx = rawUnitsContext(ctx, req)
+	ctx = altitudeContext(ctx, req)
+	return routeQuery(ctx, queryHistorical, sourceFuncs{
+		db:  func() (*mcp.CallToolResult, error) { return searchAreaBoxesDB(ctx, boxes, window, limit) },
+		api: func() (*mcp.CallToolResult, error) { return searchAreaBoxesAPI(ctx, boxes, window, limit) },
+	})
+}
+
+// searchAreaBoxesSQL selects the newest markers of every box, up to limit
+// per box. Arguments: the four box arrays (boxArrays), limit, max marker ID
+// (snapshotBound) and the date window (dateWindow.bounds).
+const searchAreaBoxesSQL = `
+	SELECT b.idx AS box_idx, m.id, m.doserate AS value, 'µSv/h' AS unit, m.countrate AS count_rate,
+		to_timestamp(m.date) AS captured_at,
+		m.lat AS latitude, m.lon AS longitude,
+		m.device_id, m.altitude AS height, m.detector,
+		m.trackid, m.has_spectrum,
+		u.internal_user_id, usr.username AS uploader_username, usr.email AS uploader_email
+	FROM ` + unnestBoxesSQL + `
+	CROSS JOIN LATERAL (
+		SELECT id, doserate, countrate, date, lat, lon, device_id, altitude, detector, trackid, has_spectrum
+		FROM markers m
+		WHERE m.geom && ST_MakeEnvelope(b.min_lon, b.min_lat, b.max_lon, b.max_lat, 4326)
+		  AND m.id <= $6
+		  AND m.date >= $7 AND m.date < $8
+		ORDER BY m.date DESC, m.id DESC
+		LIMIT $5
+	) m
+	LEFT JOIN uploads u ON u.track_id = m.trackid
+	LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+	ORDER BY b.idx, m.date DESC, m.id DESC`
+
+// searchAreaBoxesTotalSQL counts the markers of every box.
+const searchAreaBoxesTotalSQL = `
+	SELECT b.idx AS box_idx, (
+		SELECT count(*) FROM markers m
+		WHERE m.geom && ST_MakeEnvelope(b.min_lon, b.min_lat, b.max_lon, b.max_lat, 4326)
+		  AND m.id <= $5
+		  AND m.date >= $6 AND m.date < $7
+	) AS total
+	FROM ` + unnestBoxesSQL
+
+// boxMarkerRow is a marker row with the box it was found in.
+type boxMarkerRow struct {
+	markerRow
+	Box pgtype.Int8
+}
+
+func (r *boxMarkerRow) column(name string) any {
+	if name == "box_idx" {
+		return &r.Box
+	}
+	return r.markerRow.column(name)
+}
+
+func searchAreaBoxesDB(ctx context.Context, boxes []queryBox, window dateWindow, limit int) (*mcp.CallToolResult, error) {
+	args := boxArrays(boxes)
+	bound := snapshotBound(ctx)
+	from, to := window.bounds()
+	rows, err := queryTyped[boxMarkerRow](ctx, searchAreaBoxesSQL, append(args, limit, bound, from, to)...)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	totals, _ := queryRows(ctx, searchAreaBoxesTotalSQL, append(args, bound, from, to)...)
+
+	groups := make([]map[string]any, len(boxes))
+	measurements := make([][]map[string]any, len(boxes))
+	for i, b := range boxes {
+		groups[i] = b.group(i)
+		measurements[i] = []map[string]any{}
+	}
+	for i := range rows {
+		if idx := int(rows[i].Box.Int64) - 1; idx >= 0 && idx < len(boxes) {
+			measurements[idx] = append(measurements[idx], markerMeasurement(ctx, &rows[i].markerRow))
+		}
+	}
+	for _, t := range totals {
+		idx, ok1 := toFloat(t["box_idx"])
+		total, ok2 := toFloat(t["total"])
+		if ok1 && ok2 && int(idx) >= 1 && int(idx) <= len(boxes) {
+			groups[int(idx)-1]["total_available"] = int(total)
+		}
+	}
+	for i, g := range groups {
+		g["count"] = len(measurements[i])
+		g["measurements"] = measurements[i]
+		if normalizeAltitude(ctx) {
+			addAltitudeNormalization(g)
+		}
+	}
+	result := boxedResult(groups, "measurements", "database")
+	window.addTo(result)
+	return measurementResult(ctx, result)
+}
+
+func searchAreaBoxesAPI(ctx context.Context, boxes []queryBox, window dateWindow, limit int) (*mcp.CallToolResult, error) {
+	groups := make([]map[string]any, len(boxes))
+	for i, b := range boxes {
+		markers, err := client.GetMarkers(ctx, b.MinLat, b.MinLon, b.MaxLat, b.MaxLon)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("bboxes[%d]: %v", i, err)), nil
+		}
+		markers = windowMarkers(markers, window)
+		limited := markers[:min(limit, len(markers))]
+		measurements := make([]map[string]any, len(limited))
+		for j, m := range limited {
+			measurements[j] = normalizeGetMarker(m)
+		}
+		g := b.group(i)
+		g["count"] = len(measurements)
+		g["total_in_bbox"] = len(markers)
+		g["measurements"] = measurements
+		if normalizeAltitude(ctx) {
+			addAltitudeNormalization(g)
+		}
+		groups[i] = g
+	}
+	result := boxedResult(groups, "measurements", "api")
+	window.addTo(result)
+	return measurementResult(ctx, result)
+}
+
+// ── list_sensors ──
+
+func handleListSensorsBoxes(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	boxes, err := parseQueryBoxes(req.GetArguments())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sensorType := req.GetString("type", "")
+	defLimit, maxLimit := toolLimits("list_sensors", 50, 1000)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+
+	if dbAvailable() {
+		return listSensorsBoxesDB(ctx, sensorType, boxes, limit)
+	}
+	if demoMode {
+		groups := make([]map[string]any, len(boxes))
+		for i, b := range boxes {
+			sensors := demoSensorsIn(sensorType, b.MinLat, b.MaxLat, b.MinLon, b.MaxLon, limit)
+			groups[i] = b.group(i)
+			groups[i]["count"] = len(sensors)
+			groups[i]["sensors"] = sensors
+		}
+		return jsonResult(boxedResult(groups, "sensors", "demo"))
+	}
+	return mcp.NewToolResultError("Database connection required for list_sensors tool. Please ensure DATABASE_URL is set to access real-time sensor data."), nil
+}
+
+// listSensorsBoxesSQL selects the latest position of every sensor in each
+// box, up to limit per box, from the realtime table. Arguments: the four box
+// arrays (boxArrays), limit, then the type filter's values.
+const listSensorsBoxesSQL = `
+	SELECT b.idx AS box_idx, s.device_id, s.device_name, s.transport, s.latitude, s.longitude, s.last_reading_at
+	FROM ` + unnestBoxesSQL + `
+	CROSS JOIN LATERAL (
+		SELECT rm.device_id,
+			COALESCE(rm.device_name, rm.device_id) AS device_name,
+			COALESCE(rm.transport, '') AS transport,
+			rm.lat AS latitude,
+			rm.lon AS longitude,
+			to_timestamp(rm.measured_at) AS last_reading_at,
+			rm.measured_at
+		FROM %[1]s rm
+		INNER JOIN (
+			SELECT device_id, MAX(measured_at) AS max_measured_at
+			FROM %[1]s
+			WHERE lat >= b.min_lat AND lat <= b.max_lat AND lon >= b.min_lon AND lon <= b.max_lon%[2]s
+			GROUP BY device_id
+		) latest ON rm.device_id = latest.device_id AND rm.measured_at = latest.max_measured_at
+		WHERE rm.lat >= b.min_lat AND rm.lat <= b.max_lat AND rm.lon >= b.min_lon AND rm.lon <= b.max_lon
+		ORDER BY rm.measured_at DESC
+		LIMIT $5
+	) s
+	ORDER BY b.idx, s.measured_at DESC`
+
+func listSensorsBoxesDB(ctx context.Context, sensorType string, boxes []queryBox, limit int) (*mcp.CallToolResult, error) {
+	table, _, err := realtimeSensorTable(ctx)
+	if err != nil {
+		return mcp.NewToolResultError("Could not query database schema: " + err.Error()), nil
+	}
+	if table == "" {
+		return mcp.NewToolResultError("No known real-time sensor data tables found in database."), nil
+	}
+
+	args := append(boxArrays(boxes), limit)
+	typeCond := ""
+	if sensorType != "" {
+		next := len(args) + 1
+		bind := pgBinder(&args, &next)
+		typeCond = fmt.Sprintf("\n\t\t\t\tAND (%s OR %s)", detectorFilter("transport", sensorType, bind), detectorFilter("device_name", sensorType, bind))
+	}
+	rows, err := queryRows(ctx, fmt.Sprintf(listSensorsBoxesSQL, table, typeCond), args...)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error querying %s table: %v", table, err)), nil
+	}
+
+	groups := make([]map[string]any, len(boxes))
+	sensors := make([][]map[string]any, len(boxes))
+	for i, b := range boxes {
+		groups[i] = b.group(i)
+		sensors[i] = []map[string]any{}
+	}
+	for _, r := range rows {
+		idx, ok := toFloat(r["box_idx"])
+		if !ok || int(idx) < 1 || int(idx) > len(boxes) {
+			continue
+		}
+		sensors[int(idx)-1] = append(sensors[int(idx)-1], map[string]any{
+			"device_id":   r["device_id"],
+			"device_name": r["device_name"],
+			"type":        r["transport"],
+			"location": map[string]any{
+				"latitude":  r["latitude"],
+				"longitude": r["longitude"],
+			},
+			"last_reading_at": r["last_reading_at"],
+		})
+	}
+	for i, g := range groups {
+		g["count"] = len(sensors[i])
+		g["sensors"] = sensors[i]
+	}
+	result := boxedResult(groups, "sensors", "database")
+	result["table_used"] = table
+	return jsonResult(result)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// ── Result normalization ───────────────────────────────────────────────────
+//
+// Database and API values reach results as they are: doserate
+// 0.08999999761581421, NaN from a bad sensor row, coordinates to 15
+// decimals. jsonResult passes every result through normalizeResult first:
+//
+//   - NaN and ±Inf become null (encoding/json refuses them outright);
+//   - dose rates keep 4 significant digits, coordinates 6 decimals (about
+//     0.1 m), other fractions 6 significant digits; whole numbers are left
+//     alone so counts and IDs stay exact;
+//   - a "_units" object documents the unit of every known field present.
+
+const (
+	doseSignificantDigits  = 4
+	otherSignificantDigits = 6
+	coordinateDecimals     = 6
+)
+
+var doseFields = map[string]bool{
+	"value": true, "avg_value": true, "min_value": true, "max_value": true,
+	"doserate": true, "dose_rate": true,
+	"usvh": true, "avg_usvh": true, "min_usvh": true, "max_usvh": true,
+	"ground_usvh": true, "cosmic_excess_usvh": true,
+	"spectrum_doserate": true, "reference_usvh": true, "sensor_median": true,
+	"mobile_usvh": true, "sensor_usvh": true,
+}
+
+var coordinateFields = map[string]bool{
+	"latitude": true, "longitude": true, "lat": true, "lon": true,
+	"min_lat": true, "max_lat": true, "min_lon": true, "max_lon": true,
+	"coordinates": true, "bbox": true,
+}
+
+// fieldUnits documents result fields whose unit is not obvious from the
+// name alone. Only fields present in a result are listed in its _units.
+var fieldUnits = map[string]string{
+	"value":                   "dose rate in µSv/h unless the record's unit field says otherwise (CPM = counts per minute)",
+	"avg_value":               "µSv/h",
+	"min_value":               "µSv/h",
+	"max_value":               "µSv/h",
+	"doserate":                "µSv/h",
+	"usvh":                    "µSv/h",
+	"avg_usvh":                "µSv/h",
+	"min_usvh":                "µSv/h",
+	"max_usvh":                "µSv/h",
+	"ground_usvh":             "µSv/h, with the estimated cosmic excess at the reading's altitude removed",
+	"cosmic_excess_usvh":      "µSv/h",
+	"latitude":                "decimal degrees (WGS84)",
+	"longitude":               "decimal degrees (WGS84)",
+	"height":                  "metres (GPS altitude)",
+	"distance_m":              "metres",
+	"radius_m":                "metres",
+	"bearing_deg":             "degrees clockwise from north",
+	"speed_ms":                "metres per second",
+	"count_rate":              "counts per second",
+	"cpm":                     "counts per minute, as the sensor reported them",
+	"cpm_per_usvh":            "counts per minute per µSv/h (the tube's Cs-137 conversion factor)",
+	"spectrum_doserate":       "µSv/h, estimated from the spectrum's count rate",
+	"sensor_median":           "median reading in the sensor's unit (see unit)",
+	"reference_usvh":          "µSv/h, median of bGeigie markers near the sensor",
+	"mobile_usvh":             "µSv/h, median of the bGeigie markers paired with the sensor",
+	"sensor_usvh":             "µSv/h, the sensor's CPM divided by cpm_per_usvh",
+	"energy_min_kev":          "keV",
+	"energy_max_kev":          "keV",
+	"live_time_sec":           "seconds",
+	"real_time_sec":           "seconds",
+	"captured_at":             "UTC timestamp (RFC 3339)",
+	"replication_lag_seconds": "seconds",
+}
+
+// normalizeResult returns a normalized copy of v. Maps and slices are
+// copied, never modified, because some results embed shared static data.
+// With raw set, floats are only checked for NaN and ±Inf, not rounded
+// (raw_units on measurement tools).
+func normalizeResult(v any, raw bool) any {
+	seen := map[string]bool{}
+	out := normalizeValue("", v, seen, raw)
+	if m, ok := out.(map[string]any); ok && len(seen) > 0 {
+		if _, exists := m["_units"]; !exists {
+			m["_units"] = unitsOf(seen)
+		}
+	}
+	return out
+}
+
+// unitsOf is the _units object for the unit fields normalizeValue saw.
+func unitsOf(seen map[string]bool) map[string]string {
+	units := make(map[string]string, len(seen))
+	for k := range seen {
+		units[k] = fieldUnits[k]
+	}
+	return units
+}
+
+func normalizeValue(key string, v any, seen map[string]bool, raw bool) any {
+	if _, ok := fieldUnits[key]; ok && v != nil {
+		seen[key] = true
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, child := range t {
+			out[k] = normalizeValue(k, child, seen, raw)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			out[i] = normalizeValue(key, child, seen, raw)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			out[i] = normalizeValue(key, child, seen, raw)
+		}
+		return out
+	case float64:
+		return normalizeFloat(key, t, raw)
+	case float32:
+		return normalizeFloat(key, float64(t), raw)
+	case *float64:
+		if t == nil {
+			return nil
+		}
+		return normalizeFloat(key, *t, raw)
+	case []float64:
+		out := make([]any, len(t))
+		for i, f := range t {
+			out[i] = normalizeFloat(key, f, raw)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func normalizeFloat(key string, f float64, raw bool) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	if raw || (f == math.Trunc(f) && math.Abs(f) < 1e15) {
+		return f
+	}
+	switch {
+	case coordinateFields[key]:
+		p := math.Pow10(coordinateDecimals)
+		return math.Round(f*p) / p
+	case doseFields[key]:
+		return roundSignificant(f, doseSignificantDigits)
+	default:
+		return roundSignificant(f, otherSignificantDigits)
+	}
+}
+
+// roundSignificant rounds f to n significant digits. Going through
+// strconv gives the shortest decimal, so 0.0899999 becomes 0.09 exactly.
+func roundSignificant(f float64, n int) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', n, 64), 64)
+	return r
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ── Notifiers ──────────────────────────────────────────────────────────────
+//
+// Subscriptions send events to people: each one names a channel (Slack
+// incoming webhook, email or a generic JSON webhook), a target, and which
+// report schedule it follows ("*" for all). Scheduled reports are delivered
+// through them after every run. SLO alerts (slo.go) go to subscriptions
+// following "slo-alerts" and new uploads (upload_watch.go) to those
+// following "new-uploads"; other alerts should likewise build a
+// notification and call notifySubscribers with their own event name.
+//
+// Webhook deliveries are signed with NOTIFY_WEBHOOK_SECRET when it is set:
+// X-Safecast-Signature carries "sha256=" and the hex HMAC-SHA256 of the
+// body, so receivers can check a request came from this server.
+//
+// Email goes through SMTP_HOST/SMTP_PORT (default 587, STARTTLS when the
+// server offers it) with optional SMTP_USERNAME/SMTP_PASSWORD, from
+// SMTP_FROM. Subscriptions are managed by maintainers only (see
+// annotations.go), since they make the server send mail and HTTP requests.
+
+const (
+	channelSlack   = "slack"
+	channelEmail   = "email"
+	channelWebhook = "webhook"
+
+	slackMaxText      = 3500 // stay under Slack's 4000-char section limit
+	notifySendTimeout = 30 * time.Second
+)
+
+// notification is one message to deliver.
+type notification struct {
+	Event    string // "report:<schedule>"
+	Subject  string
+	Markdown string
+	HTML     string
+	URL      string
+	Data     any // structured payload for webhooks, e.g. the new uploads
+}
+
+type notifier interface {
+	Notify(ctx context.Context, n notification) error
+}
+
+type subscription struct {
+	ID        int64     `json:"id"`
+	Channel   string    `json:"channel"`
+	Target    string    `json:"target"`
+	Schedule  string    `json:"schedule"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// redacted hides webhook secrets when subscriptions are listed.
+func (s subscription) redacted() subscription {
+	if s.Channel == channelSlack || s.Channel == channelWebhook {
+		if u, err := url.Parse(s.Target); err == nil {
+			s.Target = u.Scheme + "://" + u.Host + "/…"
+		}
+	}
+	return s
+}
+
+func (s subscription) notifier() (notifier, error) {
+	switch s.Channel {
+	case channelSlack:
+		return slackNotifier{webhookURL: s.Target}, nil
+	case channelEmail:
+		return newSMTPNotifier(strings.Split(s.Target, ","))
+	case channelWebhook:
+		return webhookNotifier{url: s.Target}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel %q", s.Channel)
+	}
+}
+
+// validateSubscriptionTarget checks a target before it is stored.
+func validateSubscriptionTarget(channel, target string) error {
+	switch channel {
+	case channelSlack:
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("slack target must be an https:// incoming webhook URL")
+		}
+	case channelWebhook:
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("webhook target must be an https:// URL")
+		}
+	case channelEmail:
+		if os.Getenv("SMTP_HOST") == "" || os.Getenv("SMTP_FROM") == "" {
+			return fmt.Errorf("email subscriptions need SMTP_HOST and SMTP_FROM to be configured")
+		}
+		for _, addr := range strings.Split(target, ",") {
+			if _, err := mail.ParseAddress(strings.TrimSpace(addr)); err != nil {
+				return fmt.Errorf("invalid email address %q", addr)
+			}
+		}
+	default:
+		return fmt.Errorf("channel must be slack, email or webhook")
+	}
+	return nil
+}
+
+// ── Slack ──────────────────────────────────────────────────────────────────
+
+type slackNotifier struct {
+	webhookURL string
+}
+
+func (s slackNotifier) Notify(ctx context.Context, n notification) error {
+	text := n.Markdown
+	if len(text) > slackMaxText {
+		text = text[:slackMaxText] + "\n…"
+	}
+	if n.URL != "" {
+		text += fmt.Sprintf("\n<%s|Full report>", n.URL)
+	}
+	body, _ := json.Marshal(map[string]any{"text": "*" + n.Subject + "*\n" + text})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The webhook URL is the secret; keep it out of errors and logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ── Webhook ────────────────────────────────────────────────────────────────
+
+type webhookNotifier struct {
+	url string
+}
+
+func (wh webhookNotifier) Notify(ctx context.Context, n notification) error {
+	payload := map[string]any{
+		"event":   n.Event,
+		"subject": n.Subject,
+		"text":    n.Markdown,
+		"sent_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if n.URL != "" {
+		payload["url"] = n.URL
+	}
+	if n.Data != nil {
+		payload["data"] = n.Data
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Safecast-Event", n.Event)
+	if secret := os.Getenv("NOTIFY_WEBHOOK_SECRET"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Safecast-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL may carry a token; keep it out of errors and logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ── SMTP ───────────────────────────────────────────────────────────────────
+
+type smtpNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+func newSMTPNotifier(to []string) (notifier, error) {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM must be set for email notifications")
+	}
+	n := smtpNotifier{addr: host + ":" + envOr("SMTP_PORT", "587"), from: from}
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		n.auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	for _, addr := range to {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			n.to = append(n.to, addr)
+		}
+	}
+	return n, nil
+}
+
+func (s smtpNotifier) Notify(ctx context.Context, n notification) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", n.Markdown + "\n\n" + n.URL + "\n"},
+		{"text/html; charset=utf-8", n.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		_, _ = w.Write([]byte(part.content))
+	}
+	_ = mw.Close()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mimeHeader(n.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	// net/smtp has no context support; run it aside so a hung server
+	// cannot block the caller past its deadline.
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(s.addr, s.auth, s.from, s.to, msg.Bytes()) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// mimeHeader encodes a header value, dropping line breaks that would
+// otherwise start a new header.
+func mimeHeader(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return mime.BEncoding.Encode("utf-8", s)
+}
+
+// ── Subscriptions ──────────────────────────────────────────────────────────
+
+func addSubscription(s subscription) (subscription, error) {
+	if err := requireDuckDB(); err != nil {
+		return s, err
+	}
+	err := duckWriteTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO notification_subscriptions (channel, target, schedule, created_by)
+			VALUES (?, ?, ?, ?)
+			RETURNING id, created_at`, s.Channel, s.Target, s.Schedule, s.CreatedBy).Scan(&s.ID, &s.CreatedAt)
+	})
+	return s, err
+}
+
+func removeSubscription(id int64) error {
+	if err := requireDuckDB(); err != nil {
+		return err
+	}
+	res, err := duckExec(`DELETE FROM notification_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("subscription %d not found", id)
+	}
+	return nil
+}
+
+// listSubscriptions returns subscriptions following schedule ("" = all).
+func listSubscriptions(schedule string) ([]subscription, error) {
+	if err := requireDuckDB(); err != nil {
+		return nil, err
+	}
+	rows, err := duckDB.Query(`
+		SELECT id, channel, target, schedule, created_by, created_at
+		FROM notification_subscriptions
+		WHERE ? = '' OR schedule = ? OR schedule = '*'
+		ORDER BY id`, schedule, schedule)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []subscription{}
+	for rows.Next() {
+		var s subscription
+		if err := rows.Scan(&s.ID, &s.Channel, &s.Target, &s.Schedule, &s.CreatedBy, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// notifySubscribers delivers n to every subscription following schedule.
+// Failures are logged per subscription and do not stop the others.
+func notifySubscribers(ctx context.Context, schedule string, n notification) {
+	subs, err := listSubscriptions(schedule)
+	if err != nil {
+		log.Printf("Notifications for %s: %v", n.Event, err)
+		return
+	}
+	for _, s := range subs {
+		nt, err := s.notifier()
+		if err == nil {
+			sendCtx, cancel := context.WithTimeout(ctx, notifySendTimeout)
+			err = nt.Notify(sendCtx, n)
+			cancel()
+		}
+		if err != nil {
+			log.Printf("Notification %s via %s subscription %d failed: %v", n.Event, s.Channel, s.ID, err)
+			continue
+		}
+		log.Printf("Notification %s sent via %s subscription %d", n.Event, s.Channel, s.ID)
+	}
+}
+
+// reportNotification builds the message for a stored report.
+func reportNotification(r storedReport) notification {
+	link := ""
+	if base := os.Getenv("MCP_BASE_URL"); base != "" {
+		link = fmt.Sprintf("%s/api/reports/%d?format=html", strings.TrimRight(base, "/"), r.ID)
+	}
+	return notification{
+		Event:    "report:" + r.Schedule,
+		Subject:  fmt.Sprintf("%s (%s)", r.Title, r.GeneratedAt.Format("2006-01-02")),
+		Markdown: r.Markdown,
+		HTML:     r.HTML,
+		URL:      link,
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── OAuth protected resource ───────────────────────────────────────────────
+//
+// By default the MCP transports are open and a private deployment relies on
+// network-level security. With MCP_OAUTH_AUTHORIZATION_SERVERS set, the
+// server acts as an OAuth 2.1 protected resource as the MCP authorization
+// spec describes, so clients such as Claude on the web can sign in:
+//
+//   - /.well-known/oauth-protected-resource (RFC 9728) names the resource
+//     and the authorization servers that issue tokens for it;
+//   - /mcp-http and /mcp/ answer requests without a valid bearer token
+//     with 401 and a WWW-Authenticate header pointing at that document,
+//     which is how a client discovers where to sign in.
+//
+// Tokens are checked with the authorization server's introspection
+// endpoint (RFC 7662), so any server that supports it works without
+// sharing signing keys. A token must be active and, when it carries an
+// audience, issued for this resource. Results are cached for
+// oauthIntrospectionCacheTTL (never past the token's expiry) keyed by a
+// hash of the token. The REST API, /health and /metrics stay public.
+//
+//   MCP_OAUTH_AUTHORIZATION_SERVERS  comma-separated issuer URLs (enables OAuth)
+//   MCP_OAUTH_INTROSPECTION_URL      token introspection endpoint (required)
+//   MCP_OAUTH_CLIENT_ID              credentials for the introspection call
+//   MCP_OAUTH_CLIENT_SECRET
+//   MCP_OAUTH_RESOURCE               resource identifier (default MCP_BASE_URL + "/mcp-http")
+//   MCP_OAUTH_SCOPES                 comma-separated scopes advertised to clients
+//
+// Maintainer tools read their token from X-Maintainer-Token when the
+// Authorization header carries the OAuth token (see authenticateMaintainer).
+
+const (
+	oauthMetadataPath          = "/.well-known/oauth-protected-resource"
+	oauthIntrospectionCacheTTL = time.Minute
+	oauthIntrospectionTimeout  = 10 * time.Second
+)
+
+type oauthToken struct {
+	Active   bool   `json:"active"`
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Subject  string `json:"sub,omitempty"`
+	Exp      int64  `json:"exp,omitempty"`
+	Aud      any    `json:"aud,omitempty"` // string or array of strings
+}
+
+type oauthCacheEntry struct {
+	token   oauthToken
+	expires time.Time
+}
+
+type oauthResource struct {
+	resource      string
+	authServers   []string
+	scopes        []string
+	metadataURL   string
+	introspectURL string
+	clientID      string
+	clientSecret  string
+	client        *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]oauthCacheEntry
+}
+
+// loadOAuthResource reads the OAuth settings. It returns nil when OAuth is
+// not configured.
+func loadOAuthResource(baseURL string) (*oauthResource, error) {
+	servers := splitEnvList("MCP_OAUTH_AUTHORIZATION_SERVERS")
+	if len(servers) == 0 {
+		return nil, nil
+	}
+	o := &oauthResource{
+		resource:      envOr("MCP_OAUTH_RESOURCE", strings.TrimRight(baseURL, "/")+"/mcp-http"),
+		authServers:   servers,
+		scopes:        splitEnvList("MCP_OAUTH_SCOPES"),
+		introspectURL: os.Getenv("MCP_OAUTH_INTROSPECTION_URL"),
+		clientID:      os.Getenv("MCP_OAUTH_CLIENT_ID"),
+		clientSecret:  os.Getenv("MCP_OAUTH_CLIENT_SECRET"),
+		client:        &http.Client{Timeout: oauthIntrospectionTimeout},
+		cache:         map[[sha256.Size]byte]oauthCacheEntry{},
+	}
+	if o.introspectURL == "" {
+		return nil, fmt.Errorf("MCP_OAUTH_AUTHORIZATION_SERVERS is set but MCP_OAUTH_INTROSPECTION_URL is not; tokens could not be checked")
+	}
+	u, err := url.Parse(o.resource)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("MCP_OAUTH_RESOURCE %q is not an absolute URL", o.resource)
+	}
+	// RFC 9728 §3.1: the well-known path goes between host and resource path.
+	o.metadataURL = u.Scheme + "://" + u.Host + oauthMetadataPath + strings.TrimRight(u.Path, "/")
+	log.Printf("OAuth enabled for the MCP transports: resource %s, authorization servers %s", o.resource, strings.Join(servers, ", "))
+	return o, nil
+}
+
+// splitEnvList splits a comma-separated environment variable, dropping
+// empty items.
+func splitEnvList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// register mounts the metadata document, both at the root well-known path
+// and at the path-suffixed one RFC 9728 derives from the resource URL.
+func (o *oauthResource) register(mux *http.ServeMux) {
+	mux.HandleFunc(oauthMetadataPath, o.handleMetadata)
+	if u, err := url.Parse(o.metadataURL); err == nil && u.Path != oauthMetadataPath {
+		mux.HandleFunc(u.Path, o.handleMetadata)
+	}
+}
+
+func (o *oauthResource) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	meta := map[string]any{
+		"resource":                 o.resource,
+		"authorization_servers":    o.authServers,
+		"bearer_methods_supported": []string{"header"},
+		"resource_name":            serverName,
+		"resource_documentation":   strings.TrimSuffix(o.resource, "/mcp-http") + "/docs/",
+	}
+	if len(o.scopes) > 0 {
+		meta["scopes_supported"] = o.scopes
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, http.StatusOK, meta)
+}
+
+// protect requires a valid bearer token for next. A nil resource leaves
+// next open.
+func (o *oauthResource) protect(next http.Handler) http.Handler {
+	if o == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r) // CORS preflight carries no credentials
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			o.challenge(w, "", "authorization required")
+			return
+		}
+		info, err := o.introspect(r.Context(), token)
+		if err != nil {
+			log.Printf("OAuth introspection failed: %v", err)
+			w.Header().Set("Retry-After", "5")
+			writeError(w, http.StatusServiceUnavailable, "token could not be verified, try again")
+			return
+		}
+		if !info.Active {
+			o.challenge(w, "invalid_token", "the access token is invalid or expired")
+			return
+		}
+		if !o.audienceOK(info.Aud) {
+			o.challenge(w, "invalid_token", "the access token was not issued for this resource")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), oauthSubjectKey{}, info.Subject)))
+	})
+}
+
+type oauthSubjectKey struct{}
+
+// oauthSubject returns the subject of the token a request was let in with.
+func oauthSubject(ctx context.Context) string {
+	s, _ := ctx.Value(oauthSubjectKey{}).(string)
+	return s
+}
+
+// challenge answers 401 with the WWW-Authenticate header clients use to
+// find the metadata document.
+func (o *oauthResource) challenge(w http.ResponseWriter, code, description string) {
+	value := fmt.Sprintf(`Bearer resource_metadata=%q`, o.metadataURL)
+	if code != "" {
+		value += fmt.Sprintf(`, error=%q, error_description=%q`, code, description)
+	}
+	if len(o.scopes) > 0 {
+		value += fmt.Sprintf(`, scope=%q`, strings.Join(o.scopes, " "))
+	}
+	w.Header().Set("WWW-Authenticate", value)
+	writeError(w, http.StatusUnauthorized, description)
+}
+
+// audienceOK reports whether a token audience includes this resource. A
+// token without an audience is accepted; not every server sets one.
+func (o *oauthResource) audienceOK(aud any) bool {
+	matches := func(s string) bool {
+		return strings.TrimRight(s, "/") == strings.TrimRight(o.resource, "/")
+	}
+	switch a := aud.(type) {
+	case nil:
+		return true
+	case string:
+		return matches(a)
+	case []any:
+		for _, item := range a {
+			if s, ok := item.(string); ok && matches(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// introspect asks the authorization server about token, using the cache.
+func (o *oauthResource) introspect(ctx context.Context, token string) (oauthToken, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	o.mu.Lock()
+	e, ok := o.cache[key]
+	o.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.token, nil
+	}
+
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.introspectURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauthToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return oauthToken{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return oauthToken{}, fmt.Errorf("introspection endpoint returned %s", resp.Status)
+	}
+	var info oauthToken
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return oauthToken{}, fmt.Errorf("introspection response: %w", err)
+	}
+	if info.Active && info.Exp > 0 && time.Unix(info.Exp, 0).Before(now) {
+		info.Active = false
+	}
+
+	expires := now.Add(oauthIntrospectionCacheTTL)
+	if info.Exp > 0 && time.Unix(info.Exp, 0).Before(expires) {
+		expires = time.Unix(info.Exp, 0)
+	}
+	o.mu.Lock()
+	for k, old := range o.cache {
+		if now.After(old.expires) {
+			delete(o.cache, k)
+		}
+	}
+	o.cache[key] = oauthCacheEntry{token: info, expires: expires}
+	o.mu.Unlock()
+	return info, nil
+}
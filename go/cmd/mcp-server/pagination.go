@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Query pagination ───────────────────────────────────────────────────────
+//
+// query_radiation and search_area return at most limit measurements. When
+// more match, the result has has_more and an opaque next_cursor; passing it
+// back as cursor, with the same other arguments, returns the next page.
+// Database cursors are keyset cursors: rows are ordered by date, then ID,
+// newest first, and the cursor holds the date and ID of the last row
+// returned, so a page never re-reads the rows before it. They also hold the
+// dataset snapshot of the first page, which pins the later pages to the
+// same markers (dataset_snapshot.go), so uploads arriving meanwhile cannot
+// shift rows between pages. The simplemap API has no keyset; its cursors
+// hold an offset instead. A cursor only works with the source that issued
+// it.
+//
+// These cursors page through a query. The rp_ cursors of continue_result
+// (response_budget.go) page through one oversized response instead.
+
+const pageCursorPrefix = "pg_"
+
+// withCursorParam adds the cursor parameter to a paginated tool.
+func withCursorParam() mcp.ToolOption {
+	return mcp.WithString("cursor",
+		mcp.Description("next_cursor from the previous page of the same query, to get the following measurements. Keep all other arguments unchanged."),
+	)
+}
+
+// pageCursor is the decoded form of a next_cursor.
+type pageCursor struct {
+	Tool     string `json:"t"`
+	Query    string `json:"q"`           // queryFingerprint of the other arguments
+	Date     int64  `json:"d,omitempty"` // database: last row's date (Unix seconds)
+	ID       int64  `json:"i,omitempty"` // database: last row's marker ID
+	Offset   int    `json:"o,omitempty"` // API: rows returned so far
+	Snapshot string `json:"s,omitempty"` // database: snapshot_id of the first page
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return pageCursorPrefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (c *pageCursor) fromDatabase() bool { return c.ID > 0 }
+
+func decodePageCursor(s string) (*pageCursor, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "rp_") {
+		return nil, fmt.Errorf("cursor %q pages through a truncated response; pass it to continue_result instead", s)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, pageCursorPrefix))
+	var c pageCursor
+	if err != nil || !strings.HasPrefix(s, pageCursorPrefix) || json.Unmarshal(data, &c) != nil || c.Tool == "" {
+		return nil, fmt.Errorf("cursor %q is not valid; copy next_cursor from the previous page", s)
+	}
+	return &c, nil
+}
+
+// queryFingerprint identifies the arguments a cursor was issued for.
+func queryFingerprint(parts ...any) string {
+	h := fnv.New64a()
+	fmt.Fprint(h, parts...)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// pageRequest is the paging state of one call: the query and, after the
+// first page, the cursor it continues from.
+type pageRequest struct {
+	Tool, Query string
+	After       *pageCursor
+}
+
+// newPageRequest checks the request's cursor against the tool and the
+// fingerprint of its other arguments.
+func newPageRequest(req mcp.CallToolRequest, query string) (pageRequest, error) {
+	p := pageRequest{Tool: req.Params.Name, Query: query}
+	s := req.GetString("cursor", "")
+	if s == "" {
+		return p, nil
+	}
+	c, err := decodePageCursor(s)
+	if err != nil {
+		return p, err
+	}
+	if c.Tool != p.Tool || c.Query != query {
+		return p, fmt.Errorf("cursor belongs to a different query; call %s with the same arguments as the page it came from", p.Tool)
+	}
+	p.After = c
+	return p, nil
+}
+
+// sources restricts fns to the source that issued the cursor.
+func (p pageRequest) sources(fns sourceFuncs) sourceFuncs {
+	switch {
+	case p.After == nil:
+		return fns
+	case p.After.fromDatabase():
+		return sourceFuncs{db: fns.db}
+	}
+	return sourceFuncs{api: fns.api}
+}
+
+// keyset returns the date and ID the next database page starts below.
+func (p pageRequest) keyset() (int64, int64) {
+	if p.After == nil {
+		return math.MaxInt64, math.MaxInt64
+	}
+	return p.After.Date, p.After.ID
+}
+
+func (p pageRequest) offset() int {
+	if p.After == nil {
+		return 0
+	}
+	return p.After.Offset
+}
+
+// nextDB is the cursor after a database row.
+func (p pageRequest) nextDB(ctx context.Context, r *markerRow) *pageCursor {
+	c := &pageCursor{Tool: p.Tool, Query: p.Query, Date: r.CapturedAt.Time.Unix(), ID: r.ID.Int64}
+	if pin := snapshotPinFrom(ctx); pin != nil {
+		c.Snapshot = pin.snap.id()
+	}
+	return c
+}
+
+// nextAPI is the cursor after offset API rows.
+func (p pageRequest) nextAPI(offset int) *pageCursor {
+	return &pageCursor{Tool: p.Tool, Query: p.Query, Offset: offset}
+}
+
+// addPagination records in a result whether another page follows. Calls
+// made outside a tool (the /api/gpt routes) have no Tool and get nothing.
+func (p pageRequest) addPagination(result map[string]any, next *pageCursor) {
+	if p.Tool == "" {
+		return
+	}
+	result["has_more"] = next != nil
+	if next != nil {
+		setPageCursor(result, p.Tool, next.encode())
+	}
+}
+
+func setPageCursor(result map[string]any, tool, cursor string) {
+	result["has_more"] = true
+	result["next_cursor"] = cursor
+	result["_pagination_note"] = fmt.Sprintf("More measurements match. For the next page call %s again with the same arguments and cursor=%q.", tool, cursor)
+}
+
+// resumePageCursor restores the query cursor of a result that the response
+// budget truncated (response_budget.go) once continue_result has handed out
+// its last rows.
+func resumePageCursor(m map[string]any, tool, cursor string) {
+	if strings.HasPrefix(cursor, pageCursorPrefix) {
+		setPageCursor(m, tool, cursor)
+	}
+}
+
+// cursorSnapshot returns the snapshot a database cursor pins its pages to.
+func cursorSnapshot(req mcp.CallToolRequest) (datasetSnapshot, bool) {
+	s := req.GetString("cursor", "")
+	if s == "" {
+		return datasetSnapshot{}, false
+	}
+	c, err := decodePageCursor(s)
+	if err != nil || c.Snapshot == "" {
+		return datasetSnapshot{}, false
+	}
+	snap, err := parseSnapshotID(c.Snapshot)
+	return snap, err == nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"sync"
+	_ "time/tzdata" // zone names resolve without zoneinfo on the host
+)
+
+// ── Place lookup ───────────────────────────────────────────────────────────
+//
+// Labels a coordinate with its country, first-level division, nearest city
+// and time zone, offline. With PLACE_BOUNDARIES_PATH pointing at a GeoJSON
+// FeatureCollection of first-level divisions (e.g. Natural Earth
+// admin-1, properties admin, iso_a2 and name), country and division come
+// from a point-in-polygon test. Without it they are those of the nearest
+// gazetteer city (gazetteer.go) among the countries whose bounding box
+// contains the point, which is right away from borders and coasts but
+// only approximate near them. The nearest city and the time zone always
+// come from the gazetteer.
+
+// placeRemoteM is how far the nearest city may be for a point outside every
+// country box to still be given that city's country.
+const placeRemoteM = 250000
+
+// pointPlace is where a point is.
+type pointPlace struct {
+	Country     string
+	CountryCode string
+	Admin1      string
+	City        *gazetteerCity
+	CityM       float64 // distance from the city to the point
+	CityBearing float64 // from the city towards the point
+	TimeZone    string
+	Method      string // "boundary" or "nearest_city"
+	Confidence  string // "high", "medium" or "low"
+}
+
+// label describes the point for people, e.g. "12 km NW of Iwaki,
+// Fukushima, Japan".
+func (p pointPlace) label() string {
+	if p.City == nil {
+		return ""
+	}
+	city := p.City.name
+	if p.City.admin1 != "" && p.City.admin1 != p.City.name {
+		city += ", " + p.City.admin1
+	}
+	if country := gazetteerCountries[p.City.cc].name; country != p.City.name {
+		city += ", " + country
+	}
+	if p.CityM < 2000 {
+		return city
+	}
+	near := fmt.Sprintf("%s %s of %s", distancePhrase(p.CityM), compassPoint(p.CityBearing), city)
+	if p.Country == "" {
+		return "at sea or in a remote area, " + near
+	}
+	return near
+}
+
+// nearestGazetteerCity is the closest city for which keep returns true.
+func nearestGazetteerCity(lat, lon float64, keep func(c *gazetteerCity) bool) (*gazetteerCity, float64) {
+	var best *gazetteerCity
+	bestM := math.Inf(1)
+	for i := range gazetteerCities {
+		c := &gazetteerCities[i]
+		if keep != nil && !keep(c) {
+			continue
+		}
+		if d := haversineMeters(lat, lon, c.lat, c.lon); d < bestM {
+			best, bestM = c, d
+		}
+	}
+	return best, bestM
+}
+
+func inCountryBox(cc string, lat, lon float64) bool {
+	b, ok := countryBoundingBoxes[gazetteerCountries[cc].box]
+	return ok && lat >= b[0] && lat <= b[1] && lon >= b[2] && lon <= b[3]
+}
+
+// locatePoint works out where a point is.
+func locatePoint(lat, lon float64) pointPlace {
+	var p pointPlace
+	city, cityM := nearestGazetteerCity(lat, lon, nil)
+	p.City, p.CityM = city, cityM
+	p.CityBearing = initialBearing(city.lat, city.lon, lat, lon)
+
+	var home *gazetteerCity // nearest city in the point's country, for the zone
+	if b, ok := boundaryAt(lat, lon); ok {
+		p.Country, p.CountryCode, p.Admin1 = b.country, b.cc, b.admin1
+		p.Method, p.Confidence = "boundary", "high"
+		if p.CountryCode != "" {
+			home, _ = nearestGazetteerCity(lat, lon, func(c *gazetteerCity) bool { return c.cc == p.CountryCode })
+		}
+	} else {
+		boxes := map[string]bool{}
+		for cc := range gazetteerCountries {
+			if inCountryBox(cc, lat, lon) {
+				boxes[cc] = true
+			}
+		}
+		// Countries without a box can only be told apart by their cities,
+		// so they always compete.
+		near, nearM := nearestGazetteerCity(lat, lon, func(c *gazetteerCity) bool {
+			return len(boxes) == 0 || boxes[c.cc] || gazetteerCountries[c.cc].box == ""
+		})
+		p.Method, p.Confidence = "nearest_city", "low"
+		if len(boxes) > 0 || nearM <= placeRemoteM {
+			home = near
+			p.Country, p.CountryCode, p.Admin1 = gazetteerCountries[near.cc].name, near.cc, near.admin1
+			if len(boxes) == 1 && boxes[near.cc] && nearM < 100000 {
+				p.Confidence = "medium"
+			}
+		}
+	}
+
+	switch {
+	case home != nil && home.tz != "":
+		p.TimeZone = home.tz
+	case home != nil:
+		p.TimeZone = gazetteerCountries[home.cc].tz
+	case p.CountryCode != "":
+		p.TimeZone = gazetteerCountries[p.CountryCode].tz
+	}
+	return p
+}
+
+// placeLabel is locatePoint's label, for tools that name the places they
+// report.
+func placeLabel(lat, lon float64) string {
+	return locatePoint(lat, lon).label()
+}
+
+// placeFields is the place of a result item: nearest city with its
+// distance in km, region (first-level division), country and ISO code.
+// Empty parts are left out.
+func (p pointPlace) placeFields() map[string]any {
+	f := map[string]any{}
+	if p.City != nil {
+		f["city"] = p.City.name
+		f["city_km"] = math.Round(p.CityM / 1000)
+	}
+	if p.Admin1 != "" {
+		f["region"] = p.Admin1
+	}
+	if p.Country != "" {
+		f["country"] = p.Country
+		f["country_code"] = p.CountryCode
+	}
+	return f
+}
+
+// placeCacheMax bounds the place cache; it is emptied when full.
+const placeCacheMax = 50000
+
+// placeCache holds placeFields by coordinates rounded to 0.01 degrees
+// (about a kilometre), so the markers of a track, which mostly share a
+// handful of cells, are located once per cell.
+var placeCache struct {
+	mu     sync.Mutex
+	fields map[[2]int32]map[string]any
+}
+
+// cachedPlaceFields is placeFields for the cell of a point.
+func cachedPlaceFields(lat, lon float64) map[string]any {
+	key := [2]int32{int32(math.Round(lat * 100)), int32(math.Round(lon * 100))}
+	placeCache.mu.Lock()
+	f, ok := placeCache.fields[key]
+	placeCache.mu.Unlock()
+	if !ok {
+		f = locatePoint(float64(key[0])/100, float64(key[1])/100).placeFields()
+		placeCache.mu.Lock()
+		if placeCache.fields == nil || len(placeCache.fields) >= placeCacheMax {
+			placeCache.fields = map[[2]int32]map[string]any{}
+		}
+		placeCache.fields[key] = f
+		placeCache.mu.Unlock()
+	}
+	return maps.Clone(f)
+}
+
+// addPlaces walks a result like addMapLinks and gives every object with a
+// location (or a track's centroid) a place. An existing place, such as
+// the label nearest_readings gives its points, is kept.
+func addPlaces(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if k != "centroid" {
+				addPlaces(child)
+			}
+		}
+		if _, set := t["place"]; set {
+			return
+		}
+		lat, lon, ok := itemLocation(t)
+		if !ok {
+			lat, lon, ok = itemLocation(map[string]any{"location": t["centroid"]})
+		}
+		if ok {
+			t["place"] = cachedPlaceFields(lat, lon)
+		}
+	case []map[string]any:
+		for _, child := range t {
+			addPlaces(child)
+		}
+	case []any:
+		for _, child := range t {
+			addPlaces(child)
+		}
+	}
+}
+
+// ── Boundaries ─────────────────────────────────────────────────────────────
+
+type boundary struct {
+	country, cc, admin1 string
+	bbox                [4]float64       // min_lat, max_lat, min_lon, max_lon
+	polygons            [][][][2]float64 // polygons → rings → [lon, lat]
+}
+
+var (
+	boundariesOnce sync.Once
+	boundaries     []boundary
+)
+
+// boundaryAt is the division containing a point, when a boundary file is
+// configured.
+func boundaryAt(lat, lon float64) (boundary, bool) {
+	boundariesOnce.Do(loadBoundaries)
+	for _, b := range boundaries {
+		if lat < b.bbox[0] || lat > b.bbox[1] || lon < b.bbox[2] || lon > b.bbox[3] {
+			continue
+		}
+		for _, poly := range b.polygons {
+			if pointInPolygon(lat, lon, poly) {
+				return b, true
+			}
+		}
+	}
+	return boundary{}, false
+}
+
+// pointInPolygon tests a point against a polygon's outer ring and holes by
+// ray casting.
+func pointInPolygon(lat, lon float64, rings [][][2]float64) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			xi, yi := ring[i][0], ring[i][1]
+			xj, yj := ring[j][0], ring[j][1]
+			if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func loadBoundaries() {
+	path := os.Getenv("PLACE_BOUNDARIES_PATH")
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Place boundaries: %v; locating by nearest city", err)
+		return
+	}
+	var fc struct {
+		Features []struct {
+			Properties map[string]any `json:"properties"`
+			Geometry   struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		log.Printf("Place boundaries: %s is not a GeoJSON FeatureCollection: %v", path, err)
+		return
+	}
+	prop := func(props map[string]any, keys ...string) string {
+		for _, k := range keys {
+			if s, ok := props[k].(string); ok && s != "" && s != "-99" {
+				return s
+			}
+		}
+		return ""
+	}
+	for _, f := range fc.Features {
+		b := boundary{
+			country: prop(f.Properties, "admin", "country", "ADMIN", "NAME_0"),
+			cc:      prop(f.Properties, "iso_a2", "ISO_A2", "country_code"),
+			admin1:  prop(f.Properties, "name", "admin1", "NAME_1"),
+		}
+		switch f.Geometry.Type {
+		case "Polygon":
+			var poly [][][2]float64
+			if json.Unmarshal(f.Geometry.Coordinates, &poly) == nil {
+				b.polygons = [][][][2]float64{poly}
+			}
+		case "MultiPolygon":
+			_ = json.Unmarshal(f.Geometry.Coordinates, &b.polygons)
+		}
+		if len(b.polygons) == 0 {
+			continue
+		}
+		b.bbox = [4]float64{90, -90, 180, -180}
+		for _, poly := range b.polygons {
+			if len(poly) == 0 {
+				continue
+			}
+			for _, pt := range poly[0] {
+				b.bbox[0], b.bbox[1] = min(b.bbox[0], pt[1]), max(b.bbox[1], pt[1])
+				b.bbox[2], b.bbox[3] = min(b.bbox[2], pt[0]), max(b.bbox[3], pt[0])
+			}
+		}
+		if b.country == "" && b.cc != "" {
+			b.country = gazetteerCountries[b.cc].name
+		}
+		boundaries = append(boundaries, b)
+	}
+	log.Printf("Place boundaries: %d divisions loaded from %s", len(boundaries), path)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Result provenance ──────────────────────────────────────────────────────
+//
+// Results say where they came from in several ways: a source field whose
+// values differ between tools ("database", "duckdb_postgres_attach",
+// "api"), and source_routing on the routed ones. When someone reports a
+// strange answer, that is not enough to tell which server answered, how
+// long it took or how much data was behind it. instrument gives every JSON
+// result a provenance block (and _meta "safecast/provenance") with:
+//
+//   - source: the source that answered, one of database, duckdb, api,
+//     demo or none, taken from the router or from what the call read
+//   - target: the host, port and database of the replica (as db_info
+//     would report it), the simplemap URL, or the DuckDB file
+//   - duration_ms: time spent in the tool
+//   - rows_read per source, with the number of queries or requests, next
+//     to rows_returned, the items in the result
+//   - cache: whether the session result cache (session_cache.go) answered
+//
+// Rows read are the rows the server received: the database's own scans
+// behind them are visible with explain_only.
+
+// sourceDemo and sourceNone complete the router's sources for provenance.
+const (
+	sourceDemo dataSource = "demo"
+	sourceNone dataSource = "none"
+)
+
+// resultSources maps the source fields of tool results to sources.
+var resultSources = map[string]dataSource{
+	"database":               sourceDatabase,
+	"duckdb_postgres_attach": sourceDuckDB,
+	"parquet_snapshot":       sourceDuckDB,
+	"api":                    sourceAPI,
+	"demo":                   sourceDemo,
+}
+
+type provenanceKey struct{}
+
+// sourceReads counts what one call read from a source.
+type sourceReads struct {
+	Queries int
+	Rows    int
+}
+
+// callProvenance collects the reads of one tool call. Handlers may query
+// from several goroutines, so it is locked.
+type callProvenance struct {
+	mu    sync.Mutex
+	reads map[dataSource]*sourceReads
+}
+
+// withProvenance starts recording the reads of a call.
+func withProvenance(ctx context.Context) (context.Context, *callProvenance) {
+	p := &callProvenance{reads: map[dataSource]*sourceReads{}}
+	return context.WithValue(ctx, provenanceKey{}, p), p
+}
+
+// recordRead counts one query or request to source that returned rows
+// rows. Calls outside a tool call are not recorded.
+func recordRead(ctx context.Context, source dataSource, rows int) {
+	p, _ := ctx.Value(provenanceKey{}).(*callProvenance)
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.reads[source]
+	if r == nil {
+		r = &sourceReads{}
+		p.reads[source] = r
+	}
+	r.Queries++
+	r.Rows += rows
+}
+
+// block is the provenance of a finished call. routed is the source the
+// router chose ("" when the tool does not route), result the decoded JSON
+// result or nil.
+func (p *callProvenance) block(tool string, duration time.Duration, routed string, result map[string]any) map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	source := dataSource(routed)
+	if source == "" {
+		// Without the router, the source is the one the call read; a
+		// call reading several is attributed to the database first.
+		// Reads that are not counted (the demo dataset, snapshots) leave
+		// the result's own source field.
+		source = sourceNone
+		for _, s := range []dataSource{sourceAPI, sourceDuckDB, sourceDatabase} {
+			if p.reads[s] != nil {
+				source = s
+			}
+		}
+		if own, _ := result["source"].(string); source == sourceNone && resultSources[own] != "" {
+			source = resultSources[own]
+		}
+	}
+	if demoMode && source == sourceAPI {
+		source = sourceDemo
+	}
+
+	read := map[string]any{}
+	total := 0
+	for s, r := range p.reads {
+		unit := "queries"
+		if s == sourceAPI {
+			unit = "requests"
+		}
+		read[string(s)] = map[string]any{"rows": r.Rows, unit: r.Queries}
+		total += r.Rows
+	}
+
+	out := map[string]any{
+		"source":      string(source),
+		"duration_ms": duration.Milliseconds(),
+		"rows_read":   total,
+		"cache":       map[string]any{"status": sessionCacheStatus(tool)},
+	}
+	if target := sourceTarget(source); target != "" {
+		out["target"] = target
+	}
+	if len(read) > 0 {
+		out["reads"] = read
+	}
+	if n, ok := resultRowCount(result); ok {
+		out["rows_returned"] = n
+	}
+	return out
+}
+
+// sessionCacheStatus is the cache status of a call that ran: "miss" when
+// the session cache keeps its result for the next identical call, "off"
+// when the tool is not cached. Hits never reach the tool; sessionCacheHit
+// marks them.
+func sessionCacheStatus(tool string) string {
+	if sessionCache.ttl(tool) > 0 {
+		return "miss"
+	}
+	return "off"
+}
+
+// sourceTarget names the server behind a source.
+func sourceTarget(source dataSource) string {
+	switch source {
+	case sourceDatabase:
+		if dbAvailable() {
+			return pgTarget(&dbPool().Config().ConnConfig.Config)
+		}
+	case sourceDuckDB:
+		path := envOr("DUCKDB_PATH", "./analytics.duckdb")
+		if demoMode {
+			return "DuckDB " + path + " (demo markers)"
+		}
+		if dsn := os.Getenv("DATABASE_URL"); dsn != "" && duckDBPostgresAttached() {
+			if cfg, err := pgconn.ParseConfig(dsn); err == nil {
+				return "DuckDB " + path + " attached to " + pgTarget(cfg)
+			}
+		}
+		return "DuckDB " + path
+	case sourceAPI:
+		if u, err := url.Parse(client.baseURL); err == nil {
+			return u.Scheme + "://" + u.Host
+		}
+	case sourceDemo:
+		return "built-in demo dataset"
+	}
+	return ""
+}
+
+// pgTarget is host:port/database of a connection, without credentials.
+func pgTarget(cfg *pgconn.Config) string {
+	return fmt.Sprintf("%s:%d/%s", cfg.Host, cfg.Port, cfg.Database)
+}
+
+// resultRowCount is the number of items a result returns: its count field
+// when it has one, otherwise the length of its longest top-level list.
+func resultRowCount(result map[string]any) (int, bool) {
+	if result == nil {
+		return 0, false
+	}
+	if n, ok := toFloat(result["count"]); ok {
+		return int(n), true
+	}
+	longest, found := 0, false
+	for _, v := range result {
+		if list, ok := v.([]any); ok {
+			longest, found = max(longest, len(list)), true
+		}
+	}
+	return longest, found
+}
+
+// addProvenance records p in a tool result, in _meta for every result
+// and as a provenance field of JSON objects.
+func addProvenance(tool string, res *mcp.CallToolResult, p *callProvenance, duration time.Duration) *mcp.CallToolResult {
+	if res == nil {
+		return res
+	}
+	var block map[string]any
+	amended := amendJSONResult(res, func(m map[string]any) {
+		routed := ""
+		if routing, ok := m["source_routing"].(map[string]any); ok {
+			routed, _ = routing["source"].(string)
+		}
+		block = p.block(tool, duration, routed, m)
+		m["provenance"] = block
+	})
+	if block == nil {
+		block = p.block(tool, duration, "", nil)
+	}
+	fields := map[string]any{}
+	if amended.Meta != nil {
+		for k, v := range amended.Meta.AdditionalFields {
+			fields[k] = v
+		}
+	}
+	fields["safecast/provenance"] = block
+	amended.Meta = mcp.NewMetaFromMap(fields)
+	return amended
+}
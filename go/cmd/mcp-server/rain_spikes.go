@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Rain spikes ────────────────────────────────────────────────────────────
+//
+// Rain washes radon decay products (Pb-214 and Bi-214, half-lives of 27 and
+// 20 minutes) out of the air onto the ground around a fixed sensor. Dose
+// rate then rises within an hour or two of the rain starting and falls back
+// to normal a few hours after it stops. Every storm produces such a spike
+// somewhere, and each one brings the question of whether something
+// happened. sensor_history finds the spikes in the series it returns and
+// labels those with this shape, so they can be explained rather than
+// escalated.
+//
+// A reading is elevated when it exceeds the median of the preceding 24
+// hours by more than 20% and by more than four robust standard deviations
+// (1.4826 × MAD) of those hours, which counting noise alone almost never
+// reaches. Consecutive elevated readings form one spike. It is labelled
+// rain_washout_likely when it rose to its peak within rainSpikeMaxRise, was
+// back to normal within rainSpikeMaxDuration and peaked below
+// rainSpikeMaxRatio times the baseline; washout rarely doubles the dose
+// rate, and it never lasts a day. A lone elevated reading in a series
+// sampled every few minutes is a single_reading, since washout lasts longer
+// than that. Anything else is unexplained. The detector sees only the dose
+// rate: rain at the sensor is inferred from the shape, not observed.
+
+const (
+	rainSpikeMinReadings = 12
+	rainSpikeWindow      = 24 * time.Hour
+	rainSpikeMaxGap      = 3 * time.Hour // longer gaps split a spike
+	rainSpikeMaxRise     = 3 * time.Hour
+	rainSpikeMaxDuration = 8 * time.Hour
+	rainSpikeMaxRatio    = 3.0
+	rainSpikeMinExcess   = 0.2 // fraction of the baseline
+	rainSpikeMinSigmas   = 4
+	rainSpikeMinSampling = 20 * time.Minute // below this, one elevated reading is too brief for washout
+)
+
+func withRainSpikeParam() mcp.ToolOption {
+	return mcp.WithBoolean("flag_rain_spikes",
+		mcp.Description("Detect short spikes in the returned series and label those shaped like radon washout after rain: fast rise, return to normal within hours (default: true)"),
+		mcp.DefaultBool(true),
+	)
+}
+
+type seriesPoint struct {
+	at    time.Time
+	value float64
+	m     map[string]any
+}
+
+// rainSpike is one run of elevated readings.
+type rainSpike struct {
+	start, peak, end time.Time // start: last normal reading before; end: first normal after
+	ended            bool
+	peakValue        float64
+	baseline         float64
+	readings         int
+	halfLife         time.Duration // 0 when the decay could not be measured
+	label, reason    string
+}
+
+// readingTime reads a captured_at value from the database (time.Time) or
+// from the demo data and the API (RFC 3339).
+func readingTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
+
+// medianMAD returns the median and the median absolute deviation of vs,
+// which it reorders.
+func medianMAD(vs []float64) (median, mad float64) {
+	slices.Sort(vs)
+	median = vs[len(vs)/2]
+	if len(vs)%2 == 0 {
+		median = (vs[len(vs)/2-1] + vs[len(vs)/2]) / 2
+	}
+	dev := make([]float64, len(vs))
+	for i, v := range vs {
+		dev[i] = math.Abs(v - median)
+	}
+	slices.Sort(dev)
+	return median, dev[len(dev)/2]
+}
+
+// detectRainSpikes finds the spikes in points, which are in time order.
+func detectRainSpikes(points []seriesPoint) []rainSpike {
+	all := make([]float64, len(points))
+	for i, p := range points {
+		all[i] = p.value
+	}
+	globalMedian, globalMAD := medianMAD(all)
+
+	baseline := make([]float64, len(points))
+	elevated := make([]bool, len(points))
+	from := 0
+	for i, p := range points {
+		for points[from].at.Before(p.at.Add(-rainSpikeWindow)) {
+			from++
+		}
+		median, mad := globalMedian, globalMAD
+		if i-from >= rainSpikeMinReadings/2 {
+			window := make([]float64, 0, i-from)
+			for _, q := range points[from:i] {
+				window = append(window, q.value)
+			}
+			median, mad = medianMAD(window)
+		}
+		baseline[i] = median
+		elevated[i] = p.value > median+max(rainSpikeMinExcess*median, rainSpikeMinSigmas*1.4826*mad)
+	}
+
+	var spikes []rainSpike
+	for i := 0; i < len(points); i++ {
+		if !elevated[i] {
+			continue
+		}
+		j := i
+		for j+1 < len(points) && elevated[j+1] && points[j+1].at.Sub(points[j].at) <= rainSpikeMaxGap {
+			j++
+		}
+		s := rainSpike{start: points[i].at, baseline: baseline[i], readings: j - i + 1}
+		if i > 0 && points[i].at.Sub(points[i-1].at) <= rainSpikeMaxGap {
+			s.start = points[i-1].at
+		}
+		peak := i
+		for k := i; k <= j; k++ {
+			if points[k].value > points[peak].value {
+				peak = k
+			}
+		}
+		s.peak, s.peakValue = points[peak].at, points[peak].value
+		if j+1 < len(points) && !elevated[j+1] && points[j+1].at.Sub(points[j].at) <= rainSpikeMaxGap {
+			s.end, s.ended = points[j+1].at, true
+		}
+		// Excess decays exponentially after the rain; measure it between
+		// the peak and the next reading.
+		if peak+1 < len(points) && (s.ended || peak < j) {
+			e0 := s.peakValue - s.baseline
+			e1 := points[peak+1].value - s.baseline
+			if e1 > 0 && e1 < e0 {
+				dt := points[peak+1].at.Sub(s.peak)
+				s.halfLife = time.Duration(float64(dt) * math.Ln2 / math.Log(e0/e1))
+			}
+		}
+		s.label, s.reason = classifyRainSpike(s)
+		for k := i; k <= j; k++ {
+			points[k].m["spike"] = s.label
+		}
+		spikes = append(spikes, s)
+		i = j
+	}
+	return spikes
+}
+
+func classifyRainSpike(s rainSpike) (label, reason string) {
+	switch {
+	case !s.ended:
+		return "ongoing", "still elevated at the end of the series; query a later end_date to see whether it falls back"
+	case s.peak.Sub(s.start) > rainSpikeMaxRise:
+		return "unexplained", fmt.Sprintf("rose over %s; washout rises within %s of the rain starting", s.peak.Sub(s.start).Round(time.Minute), rainSpikeMaxRise)
+	case s.end.Sub(s.start) > rainSpikeMaxDuration:
+		return "unexplained", fmt.Sprintf("elevated for %s; washout is back to normal within %s", s.end.Sub(s.start).Round(time.Minute), rainSpikeMaxDuration)
+	case s.readings == 1 && s.end.Sub(s.start) < 2*rainSpikeMinSampling:
+		return "single_reading", "one elevated reading between normal ones minutes apart; too brief for washout, more likely a counting fluctuation or glitch"
+	case s.baseline > 0 && s.peakValue > rainSpikeMaxRatio*s.baseline:
+		return "unexplained", fmt.Sprintf("peaked at %.1f times the baseline; washout rarely exceeds %.0f times", s.peakValue/s.baseline, rainSpikeMaxRatio)
+	}
+	return "rain_washout_likely", "fast rise and return to normal within hours, the shape of radon progeny washed out by rain"
+}
+
+// addRainSpikes labels the spikes in the measurements of a sensor_history
+// result and lists them.
+func addRainSpikes(result map[string]any) {
+	ms, _ := result["measurements"].([]map[string]any)
+	var points []seriesPoint
+	for _, m := range ms {
+		at, ok1 := readingTime(m["captured_at"])
+		v, ok2 := toFloat(m["value"])
+		if ok1 && ok2 {
+			points = append(points, seriesPoint{at, v, m})
+		}
+	}
+	if len(points) < rainSpikeMinReadings {
+		result["spike_detection"] = map[string]any{
+			"enabled": true,
+			"note":    fmt.Sprintf("Spike detection needs at least %d readings; widen the date range or raise limit.", rainSpikeMinReadings),
+		}
+		return
+	}
+	slices.SortStableFunc(points, func(a, b seriesPoint) int { return a.at.Compare(b.at) })
+
+	spikes := detectRainSpikes(points)
+	list := make([]map[string]any, len(spikes))
+	washout := 0
+	for i, s := range spikes {
+		entry := map[string]any{
+			"label":          s.label,
+			"reason":         s.reason,
+			"start":          s.start.UTC().Format(time.RFC3339),
+			"peak_at":        s.peak.UTC().Format(time.RFC3339),
+			"peak_value":     s.peakValue,
+			"baseline_value": s.baseline,
+			"readings":       s.readings,
+			"rise_minutes":   math.Round(s.peak.Sub(s.start).Minutes()),
+		}
+		if s.baseline > 0 {
+			entry["peak_ratio"] = roundSignificant(s.peakValue/s.baseline, 3)
+		}
+		if s.ended {
+			entry["end"] = s.end.UTC().Format(time.RFC3339)
+			entry["duration_minutes"] = math.Round(s.end.Sub(s.start).Minutes())
+		}
+		if s.halfLife > 0 {
+			entry["decay_half_life_minutes"] = math.Round(s.halfLife.Minutes())
+		}
+		if s.label == "rain_washout_likely" {
+			washout++
+		}
+		list[i] = entry
+	}
+	result["spikes"] = list
+	result["spike_detection"] = map[string]any{
+		"enabled":             true,
+		"detected":            len(spikes),
+		"rain_washout_likely": washout,
+		"method":              "A reading is elevated when it is more than 20% and more than 4 robust standard deviations above the median of the preceding 24 hours. A run of elevated readings that rose within 3 hours, was back to normal within 8 hours and peaked below 3 times the baseline is labelled rain_washout_likely. Elevated readings carry the label in a spike field.",
+		"note":                "Rain washes short-lived radon decay products out of the air, raising the dose rate for a few hours. Such spikes are natural and expected after rain or snow. Rain is inferred from the shape of the spike, not from weather data; check local weather records to confirm.",
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Raw units ──────────────────────────────────────────────────────────────
+//
+// Measurement tools normalize what they return: dose rates are rounded to
+// 4 significant digits, coordinates to 6 decimals, and realtime sensor rows
+// labelled "cps" are relabelled "cpm" (the feeds report counts per minute
+// under the wrong name). raw_units: true skips all of that. Values come back
+// as stored, each marker also carries its stored count rate, a relabelled
+// unit is kept as stored with the corrected one in normalized_unit, and the
+// result documents the conversions under "raw_units" so nothing is lost.
+
+type rawUnitsKey struct{}
+
+// withRawUnitsParam adds the raw_units flag to a measurement tool.
+func withRawUnitsParam() mcp.ToolOption {
+	return mcp.WithBoolean("raw_units",
+		mcp.Description("Return values exactly as stored (no rounding, original unit labels, marker count rates) plus the conversions normally applied (default: false)"),
+	)
+}
+
+// rawUnitsContext records the request's raw_units flag in ctx, where the
+// source functions of the tool can see it.
+func rawUnitsContext(ctx context.Context, req mcp.CallToolRequest) context.Context {
+	if !req.GetBool("raw_units", false) {
+		return ctx
+	}
+	return context.WithValue(ctx, rawUnitsKey{}, true)
+}
+
+func rawUnits(ctx context.Context) bool {
+	raw, _ := ctx.Value(rawUnitsKey{}).(bool)
+	return raw
+}
+
+// rawUnitsConversions describes what normalization would have done.
+var rawUnitsConversions = map[string]any{
+	"rounding":   "normalized results keep 4 significant digits for dose rates, 6 decimals for coordinates and 6 significant digits for other fractions; here values are unrounded",
+	"doserate":   "markers store the dose rate in µSv/h as converted at upload from the detector's count rate with its calibration factor (e.g. 334 CPM per µSv/h for the LND 7317 in a bGeigie)",
+	"count_rate": "detector count rate stored with the marker, in counts per second; multiply by 60 for CPM",
+	"cpm":        "realtime readings in CPM are divided by the tube's CPM per µSv/h factor (cpm_per_usvh); raw results keep the CPM value and add the dose rate as usvh",
+	"unit":       "realtime Geiger counter feeds label counts per minute as cps; normalized results relabel them cpm, raw results keep the stored label and give the corrected one in normalized_unit",
+}
+
+// measurementResult is jsonResult for measurement tools. It adds map links
+// to every located reading (map_links.go), its place (places.go) and their
+// shortlinks (shortlinks.go) and, with normalize_altitude, the cosmic
+// correction (altitude.go); with raw_units it also skips rounding and adds
+// the conversion notes.
+func measurementResult(ctx context.Context, result map[string]any) (*mcp.CallToolResult, error) {
+	if normalizeAltitude(ctx) {
+		addAltitudeNormalization(result)
+	}
+	addMapLinks(result)
+	addPlaces(result)
+	addShortLinks(result)
+	if !rawUnits(ctx) {
+		return jsonResult(result)
+	}
+	result["raw_units"] = map[string]any{
+		"enabled":     true,
+		"conversions": rawUnitsConversions,
+	}
+	return rawJSONResult(result)
+}
+
+// sensorUnit sets the unit of a realtime sensor reading. Geiger counters
+// report CPM (counts per minute), but the feeds label it CPS; the label is
+// corrected unless raw_units asks for the stored one.
+func sensorUnit(ctx context.Context, reading map[string]any, stored any) {
+	unit := stored
+	if s, ok := stored.(string); ok {
+		unit = strings.ReplaceAll(strings.ReplaceAll(s, "cps", "cpm"), "CPS", "CPM")
+	}
+	reading["unit"] = unit
+	if rawUnits(ctx) {
+		reading["unit"] = stored
+		if unit != stored {
+			reading["normalized_unit"] = unit
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Scheduled reports ──────────────────────────────────────────────────────
+//
+// A report schedule is a list of tool calls run every day, week or month.
+// Each run renders the results as Markdown and HTML and stores them in
+// DuckDB, where /api/reports and the latest_report tool serve them.
+//
+// Two schedules are built in (weekly network summary, monthly country
+// stats); schedule_report adds more from saved queries. A run is due when
+// the schedule has no report yet or its newest report is one interval old,
+// so restarts neither skip nor repeat runs.
+//
+//   REPORTS_ENABLED         "false" turns the scheduler off (HTTP modes only)
+//   REPORT_CHECK_INTERVAL   how often due schedules are checked (default 10m)
+//   REPORT_RETENTION        reports kept per schedule (default 52)
+//   REPORT_COUNTRIES        countries in the monthly country report
+
+const (
+	defaultReportCheckInterval = 10 * time.Minute
+	defaultReportRetention     = 52
+	reportRunTimeout           = 5 * time.Minute
+	reportMaxRows              = 20
+	reportMaxColumns           = 8
+)
+
+var reportIntervals = []string{"daily", "weekly", "monthly"}
+
+// reportStep is one tool call in a report.
+type reportStep struct {
+	Title string         `json:"title"`
+	Tool  string         `json:"tool"`
+	Args  map[string]any `json:"arguments"`
+}
+
+type reportSchedule struct {
+	Name         string   `json:"name"`
+	Title        string   `json:"title"`
+	Every        string   `json:"every"`
+	SavedQueries []string `json:"saved_queries,omitempty"`
+	Builtin      bool     `json:"builtin"`
+	steps        func() []reportStep
+}
+
+// builtinReportSchedules are always present. Steps are built at run time so
+// "this year" and the country list are current.
+func builtinReportSchedules() []reportSchedule {
+	return []reportSchedule{
+		{
+			Name:    "weekly-network-summary",
+			Title:   "Weekly Safecast network summary",
+			Every:   "weekly",
+			Builtin: true,
+			steps: func() []reportStep {
+				return []reportStep{
+					{"Fixed sensors", "list_sensors", map[string]any{"limit": 100}},
+					{"Latest uploads", "list_tracks", map[string]any{"limit": 20}},
+					{"Top uploaders this year", "top_uploaders", map[string]any{"limit": 10, "year": time.Now().UTC().Year()}},
+					{"Dose rate by month", "radiation_stats", map[string]any{"interval": "month"}},
+				}
+			},
+		},
+		{
+			Name:    "monthly-country-stats",
+			Title:   "Monthly country statistics",
+			Every:   "monthly",
+			Builtin: true,
+			steps: func() []reportStep {
+				var steps []reportStep
+				year := time.Now().UTC().Year()
+				for _, c := range strings.Split(envOr("REPORT_COUNTRIES", "Japan,USA,Germany"), ",") {
+					if c = strings.TrimSpace(c); c != "" {
+						steps = append(steps, reportStep{c + ": tracks this year", "search_tracks_by_location", map[string]any{"country": c, "year": year, "limit": 20}})
+					}
+				}
+				return steps
+			},
+		},
+	}
+}
+
+// resolveSteps returns the tool calls for one run; custom schedules are
+// resolved from their saved queries.
+func (s reportSchedule) resolveSteps() ([]reportStep, error) {
+	if s.steps != nil {
+		return s.steps(), nil
+	}
+	steps := make([]reportStep, 0, len(s.SavedQueries))
+	for _, name := range s.SavedQueries {
+		q, err := getSavedQuery(name)
+		if err != nil {
+			return nil, fmt.Errorf("saved query %q: %w", name, err)
+		}
+		title := q.Name
+		if q.Description != "" {
+			title += " — " + q.Description
+		}
+		steps = append(steps, reportStep{title, q.Tool, q.Arguments})
+	}
+	return steps, nil
+}
+
+func nextReportDue(last time.Time, every string) time.Time {
+	switch every {
+	case "daily":
+		return last.AddDate(0, 0, 1)
+	case "monthly":
+		return last.AddDate(0, 1, 0)
+	default:
+		return last.AddDate(0, 0, 7)
+	}
+}
+
+// ── Storage ────────────────────────────────────────────────────────────────
+
+type storedReport struct {
+	ID          int64     `json:"id"`
+	Schedule    string    `json:"schedule"`
+	Title       string    `json:"title"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Markdown    string    `json:"markdown,omitempty"`
+	HTML        string    `json:"-"`
+}
+
+func listReportSchedules() ([]reportSchedule, error) {
+	schedules := builtinReportSchedules()
+	if err := requireDuckDB(); err != nil {
+		return schedules, err
+	}
+	rows, err := duckDB.Query(`SELECT name, title, every, CAST(saved_queries AS VARCHAR) FROM report_schedules ORDER BY name`)
+	if err != nil {
+		return schedules, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s reportSchedule
+		var queries string
+		if err := rows.Scan(&s.Name, &s.Title, &s.Every, &queries); err != nil {
+			return schedules, err
+		}
+		_ = json.Unmarshal([]byte(queries), &s.SavedQueries)
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func saveReportSchedule(s reportSchedule) error {
+	if err := requireDuckDB(); err != nil {
+		return err
+	}
+	queries, _ := json.Marshal(s.SavedQueries)
+	if _, err := duckExec(`DELETE FROM report_schedules WHERE name = ?`, s.Name); err != nil {
+		return err
+	}
+	_, err := duckExec(`INSERT INTO report_schedules (name, title, every, saved_queries) VALUES (?, ?, ?, ?)`,
+		s.Name, s.Title, s.Every, string(queries))
+	return err
+}
+
+func lastReportTime(schedule string) (time.Time, bool) {
+	var t sql.NullTime
+	if err := duckDB.QueryRow(`SELECT MAX(generated_at) FROM reports WHERE schedule = ?`, schedule).Scan(&t); err != nil || !t.Valid {
+		return time.Time{}, false
+	}
+	return t.Time, true
+}
+
+func storeReport(r storedReport) (int64, error) {
+	var id int64
+	err := duckWriteTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO reports (schedule, title, generated_at, markdown, html)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING id`, r.Schedule, r.Title, r.GeneratedAt, r.Markdown, r.HTML).Scan(&id)
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Keep the newest REPORT_RETENTION reports per schedule.
+	_, err = duckExec(`
+		DELETE FROM reports WHERE schedule = ? AND id NOT IN (
+			SELECT id FROM reports WHERE schedule = ? ORDER BY generated_at DESC LIMIT ?)`,
+		r.Schedule, r.Schedule, intEnv("REPORT_RETENTION", defaultReportRetention))
+	return id, err
+}
+
+// listReports returns report metadata, newest first. schedule may be "".
+func listReports(schedule string, limit int) ([]storedReport, error) {
+	if err := requireDuckDB(); err != nil {
+		return nil, err
+	}
+	rows, err := duckDB.Query(`
+		SELECT id, schedule, title, generated_at FROM reports
+		WHERE ? = '' OR schedule = ?
+		ORDER BY generated_at DESC LIMIT ?`, schedule, schedule, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []storedReport{}
+	for rows.Next() {
+		var r storedReport
+		if err := rows.Scan(&r.ID, &r.Schedule, &r.Title, &r.GeneratedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// getReport loads one report by ID, or the newest one for schedule when
+// id is 0.
+func getReport(id int64, schedule string) (storedReport, error) {
+	if err := requireDuckDB(); err != nil {
+		return storedReport{}, err
+	}
+	var r storedReport
+	err := duckDB.QueryRow(`
+		SELECT id, schedule, title, generated_at, markdown, html FROM reports
+		WHERE (? = 0 OR id = ?) AND (? = '' OR schedule = ?)
+		ORDER BY generated_at DESC LIMIT 1`, id, id, schedule, schedule).
+		Scan(&r.ID, &r.Schedule, &r.Title, &r.GeneratedAt, &r.Markdown, &r.HTML)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storedReport{}, errSavedNotFound
+	}
+	return r, err
+}
+
+// ── Rendering ──────────────────────────────────────────────────────────────
+
+type reportTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]string
+	Total   int
+}
+
+type reportSection struct {
+	Title  string
+	Tool   string
+	Args   string
+	Error  string
+	Facts  [][2]string
+	Tables []reportTable
+}
+
+// sectionFromResult turns a tool's JSON result into facts (scalars) and
+// tables (arrays of objects). Keys starting with "_" are model hints and
+// are left out.
+func sectionFromResult(step reportStep, res *mcp.CallToolResult, err error) reportSection {
+	argsJSON, _ := json.Marshal(step.Args)
+	sec := reportSection{Title: step.Title, Tool: step.Tool, Args: string(argsJSON)}
+	if err == nil && res != nil && res.IsError {
+		err = errors.New(sourceFailure(res, nil))
+	}
+	if err != nil {
+		sec.Error = err.Error()
+		return sec
+	}
+
+	var text string
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	var m map[string]any
+	if json.Unmarshal([]byte(text), &m) != nil {
+		sec.Facts = append(sec.Facts, [2]string{"result", text})
+		return sec
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if !strings.HasPrefix(k, "_") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case []any:
+			if t, ok := tableFromRows(k, v); ok {
+				sec.Tables = append(sec.Tables, t)
+			}
+		case map[string]any:
+			for _, sub := range sortedKeys(v) {
+				if s, ok := scalarString(v[sub]); ok {
+					sec.Facts = append(sec.Facts, [2]string{k + "." + sub, s})
+				}
+			}
+		default:
+			if s, ok := scalarString(v); ok {
+				sec.Facts = append(sec.Facts, [2]string{k, s})
+			}
+		}
+	}
+	return sec
+}
+
+func tableFromRows(name string, rows []any) (reportTable, bool) {
+	t := reportTable{Name: name, Total: len(rows)}
+	if len(rows) == 0 {
+		return t, true
+	}
+	seen := map[string]bool{}
+	for _, raw := range rows {
+		row, ok := raw.(map[string]any)
+		if !ok {
+			return t, false
+		}
+		for _, k := range sortedKeys(row) {
+			if _, scalar := scalarString(row[k]); scalar && !seen[k] && len(t.Columns) < reportMaxColumns {
+				seen[k] = true
+				t.Columns = append(t.Columns, k)
+			}
+		}
+	}
+	for i, raw := range rows {
+		if i == reportMaxRows {
+			break
+		}
+		row := raw.(map[string]any)
+		cells := make([]string, len(t.Columns))
+		for j, c := range t.Columns {
+			cells[j], _ = scalarString(row[c])
+		}
+		t.Rows = append(t.Rows, cells)
+	}
+	return t, true
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func scalarString(v any) (string, bool) {
+	switch t := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return t, true
+	case float64:
+		return fmt.Sprintf("%g", t), true
+	case bool:
+		return fmt.Sprint(t), true
+	default:
+		return "", false
+	}
+}
+
+func renderReportMarkdown(title string, at time.Time, sections []reportSection) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\nGenerated %s UTC from Safecast data (CC0).\n", title, at.UTC().Format("2006-01-02 15:04"))
+	cell := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+	}
+	for _, s := range sections {
+		fmt.Fprintf(&b, "\n## %s\n\n_`%s` %s_\n\n", s.Title, s.Tool, s.Args)
+		if s.Error != "" {
+			fmt.Fprintf(&b, "**Error:** %s\n", s.Error)
+			continue
+		}
+		for _, f := range s.Facts {
+			fmt.Fprintf(&b, "- **%s**: %s\n", f[0], cell(f[1]))
+		}
+		for _, t := range s.Tables {
+			fmt.Fprintf(&b, "\n**%s** (%d rows", t.Name, t.Total)
+			if t.Total > len(t.Rows) {
+				fmt.Fprintf(&b, ", first %d shown", len(t.Rows))
+			}
+			b.WriteString(")\n\n")
+			if len(t.Columns) == 0 {
+				continue
+			}
+			b.WriteString("| " + strings.Join(t.Columns, " | ") + " |\n")
+			b.WriteString("|" + strings.Repeat("---|", len(t.Columns)) + "\n")
+			for _, row := range t.Rows {
+				for i := range row {
+					row[i] = cell(row[i])
+				}
+				b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+var reportHTMLTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 960px; margin: 2rem auto; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; margin: 0.5rem 0 1.5rem; font-size: 0.9rem; }
+th, td { border: 1px solid #ccc; padding: 0.25rem 0.5rem; text-align: left; }
+th { background: #f3f3f3; }
+.call { color: #666; font-family: monospace; font-size: 0.85rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}} UTC from Safecast data (CC0).</p>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+<p class="call">{{.Tool}} {{.Args}}</p>
+{{if .Error}}<p class="error">Error: {{.Error}}</p>{{end}}
+{{if .Facts}}<ul>{{range .Facts}}<li><strong>{{index . 0}}</strong>: {{index . 1}}</li>{{end}}</ul>{{end}}
+{{range .Tables}}
+<p><strong>{{.Name}}</strong> ({{.Total}} rows{{if gt .Total (len .Rows)}}, first {{len .Rows}} shown{{end}})</p>
+{{if .Columns}}<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{end}}
+</table>{{end}}
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func renderReportHTML(title string, at time.Time, sections []reportSection) (string, error) {
+	var b bytes.Buffer
+	err := reportHTMLTemplate.Execute(&b, map[string]any{
+		"Title":     title,
+		"Generated": at.UTC().Format("2006-01-02 15:04"),
+		"Sections":  sections,
+	})
+	return b.String(), err
+}
+
+// ── Scheduler ──────────────────────────────────────────────────────────────
+
+type reportScheduler struct {
+	server *server.MCPServer
+}
+
+// startReportScheduler runs due reports in the background. Needs DuckDB.
+func startReportScheduler(s *server.MCPServer) {
+	if duckDB == nil || os.Getenv("REPORTS_ENABLED") == "false" {
+		return
+	}
+	rs := &reportScheduler{server: s}
+	interval := durationEnv("REPORT_CHECK_INTERVAL", defaultReportCheckInterval)
+	if interval <= 0 {
+		return
+	}
+	log.Printf("Report scheduler started (checking every %s)", interval)
+	go func() {
+		rs.runDue()
+		for range time.Tick(interval) {
+			rs.runDue()
+		}
+	}()
+}
+
+func (rs *reportScheduler) runDue() {
+	schedules, err := listReportSchedules()
+	if err != nil {
+		log.Printf("Report scheduler: %v", err)
+	}
+	now := time.Now()
+	for _, s := range schedules {
+		// The built-in reports only query the database; without one they
+		// would be all error sections.
+		if s.Builtin && !dbAvailable() {
+			continue
+		}
+		if last, ok := lastReportTime(s.Name); ok && now.Before(nextReportDue(last, s.Every)) {
+			continue
+		}
+		if _, err := rs.run(context.Background(), s); err != nil {
+			log.Printf("Report %s failed: %v", s.Name, err)
+		}
+	}
+}
+
+// run generates and stores one report. Individual tool failures become
+// error sections; only storage problems fail the run.
+func (rs *reportScheduler) run(ctx context.Context, s reportSchedule) (storedReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, reportRunTimeout)
+	defer cancel()
+
+	steps, err := s.resolveSteps()
+	if err != nil {
+		return storedReport{}, err
+	}
+
+	start := time.Now()
+	sections := make([]reportSection, 0, len(steps))
+	for _, step := range steps {
+		tool := rs.server.GetTool(step.Tool)
+		if tool == nil || unsavableTools[step.Tool] {
+			sections = append(sections, reportSection{Title: step.Title, Tool: step.Tool, Error: "tool not available on this server"})
+			continue
+		}
+		_, args, err := applyRegion(step.Args)
+		if err != nil {
+			sections = append(sections, sectionFromResult(step, nil, err))
+			continue
+		}
+		req := mcp.CallToolRequest{}
+		req.Params.Name = step.Tool
+		req.Params.Arguments = args
+		res, err := tool.Handler(ctx, req)
+		sections = append(sections, sectionFromResult(step, res, err))
+	}
+
+	r := storedReport{Schedule: s.Name, Title: s.Title, GeneratedAt: time.Now().UTC()}
+	r.Markdown = renderReportMarkdown(s.Title, r.GeneratedAt, sections)
+	if r.HTML, err = renderReportHTML(s.Title, r.GeneratedAt, sections); err != nil {
+		return storedReport{}, err
+	}
+	if r.ID, err = storeReport(r); err != nil {
+		return storedReport{}, err
+	}
+	log.Printf("Report %s #%d generated in %s (%d sections)", s.Name, r.ID, time.Since(start).Round(time.Millisecond), len(sections))
+	go notifySubscribers(context.Background(), s.Name, reportNotification(r))
+	return r, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Response size budgets ──────────────────────────────────────────────────
+//
+// A 2 MB get_track result does not fit in a model's context, and a client
+// that silently cuts it leaves the model presenting half a track as the
+// whole. Each transport therefore has a byte budget. enforceResponseBudget,
+// a tool middleware, trims the largest row array of an oversized result
+// until it fits and says so explicitly: truncated, returned, omitted and a
+// next_cursor. The omitted rows are kept in memory for a while and
+// continue_result hands them out page by page.
+//
+// MCP_MAX_RESPONSE_BYTES sets the budget for every transport;
+// MCP_MAX_RESPONSE_BYTES_HTTP, _SSE and _STDIO override it per transport.
+// 0 disables the budget.
+
+const (
+	transportHTTP  = "http"
+	transportSSE   = "sse"
+	transportStdio = "stdio"
+
+	defaultMaxResponseBytes = 256 * 1024
+
+	resultPageTTL     = 15 * time.Minute
+	maxResultPageSets = 200
+)
+
+type transportCtxKey struct{}
+
+func withTransport(ctx context.Context, transport string) context.Context {
+	return context.WithValue(ctx, transportCtxKey{}, transport)
+}
+
+// transportFromContext reports the transport a tool call arrived on.
+// Calls made inside the server (reports, saved queries) have none.
+func transportFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(transportCtxKey{}).(string)
+	return t
+}
+
+// httpTransportContext and sseTransportContext are the transports'
+// context funcs; stdio sets its own in main.
+func httpTransportContext(ctx context.Context, r *http.Request) context.Context {
+	return withAuditCaller(withTransport(ctx, transportHTTP), r)
+}
+
+func sseTransportContext(ctx context.Context, r *http.Request) context.Context {
+	return withAuditCaller(withTransport(ctx, transportSSE), r)
+}
+
+type responseBudgets struct {
+	byTransport map[string]int
+}
+
+var budgets = loadResponseBudgets()
+
+func loadResponseBudgets() *responseBudgets {
+	def := intEnv("MCP_MAX_RESPONSE_BYTES", defaultMaxResponseBytes)
+	b := &responseBudgets{byTransport: map[string]int{}}
+	for _, t := range []string{transportHTTP, transportSSE, transportStdio} {
+		b.byTransport[t] = intEnv("MCP_MAX_RESPONSE_BYTES_"+strings.ToUpper(t), def)
+	}
+	return b
+}
+
+// limit returns the byte budget for a call, 0 meaning unlimited.
+func (b *responseBudgets) limit(ctx context.Context) int {
+	return b.byTransport[transportFromContext(ctx)]
+}
+
+// enforceResponseBudget is a tool middleware that truncates oversized results.
+func enforceResponseBudget(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res, err := next(ctx, req)
+		limit := budgets.limit(ctx)
+		if err != nil || limit <= 0 || resultSize(res) <= limit {
+			return res, err
+		}
+		return truncateResult(req.Params.Name, res, limit), nil
+	}
+}
+
+// truncateResult trims the largest row array in res to fit limit bytes and
+// parks the rest under a cursor. Results without rows to trim are returned
+// unchanged.
+func truncateResult(tool string, res *mcp.CallToolResult, limit int) *mcp.CallToolResult {
+	if res == nil || res.IsError || len(res.Content) != 1 {
+		return res
+	}
+	tc, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		return res
+	}
+	dec := json.NewDecoder(strings.NewReader(tc.Text))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil || m == nil {
+		return res
+	}
+
+	key := largestRowArray(m)
+	if key == "" {
+		return res
+	}
+	rows := m[key].([]any)
+	page, n := fitRows(m, key, rows, limit)
+	if page == nil {
+		return res
+	}
+
+	// A query's own next_cursor (pagination.go) follows the parked rows.
+	resume, _ := m["next_cursor"].(string)
+	cursor := ""
+	if n < len(rows) {
+		cursor = resultPages.park(tool, key, rows[n:], n, resume)
+		delete(page, "_pagination_note")
+	}
+	annotateTruncation(page, key, n, len(rows)-n, cursor)
+	if cursor == "" {
+		resumePageCursor(page, tool, resume)
+	}
+
+	out := mcp.NewToolResultText(encodeBudgeted(page))
+	out.Meta = res.Meta
+	return out
+}
+
+// largestRowArray picks the top-level array with the most encoded bytes.
+func largestRowArray(m map[string]any) string {
+	best, bestSize := "", 0
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rows, ok := m[k].([]any)
+		if !ok || len(rows) < 2 {
+			continue
+		}
+		b, _ := json.Marshal(rows)
+		if len(b) > bestSize {
+			best, bestSize = k, len(b)
+		}
+	}
+	return best
+}
+
+// fitRows finds the most leading rows of m[key] that fit in limit bytes,
+// counting the truncation fields. It returns nil when not even one fits.
+func fitRows(m map[string]any, key string, rows []any, limit int) (map[string]any, int) {
+	page := make(map[string]any, len(m)+5)
+	for k, v := range m {
+		page[k] = v
+	}
+	fits := func(n int) bool {
+		page[key] = rows[:n]
+		annotateTruncation(page, key, n, len(rows)-n, "rp_00000000000000000000000000000000")
+		return len(encodeBudgeted(page)) <= limit
+	}
+	// Largest n in [1, len(rows)] with fits(n); sort.Search wants the
+	// first failure.
+	n := sort.Search(len(rows), func(i int) bool { return !fits(i + 1) })
+	if n == 0 {
+		return nil, 0
+	}
+	page[key] = rows[:n]
+	return page, n
+}
+
+func annotateTruncation(m map[string]any, key string, returned, omitted int, cursor string) {
+	m["truncated"] = omitted > 0
+	m["returned"] = returned
+	m["omitted"] = omitted
+	m["truncated_field"] = key
+	if cursor != "" {
+		m["next_cursor"] = cursor
+		m["_truncation_note"] = fmt.Sprintf("PARTIAL RESULT: %d %s are in this response and %d more were omitted to fit the size budget. Tell the user the answer is partial, or call continue_result with cursor=%q for the next page. Counts and statistics elsewhere in this result may describe the full set.", returned, key, omitted, cursor)
+	} else {
+		delete(m, "next_cursor")
+		delete(m, "_truncation_note")
+	}
+}
+
+func encodeBudgeted(m map[string]any) string {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return string(data)
+}
+
+// ── Parked rows ────────────────────────────────────────────────────────────
+
+type parkedRows struct {
+	tool    string
+	key     string
+	rows    []any
+	offset  int    // rows already returned before these
+	resume  string // the result's query cursor, for after the last row
+	created time.Time
+}
+
+type resultPageStore struct {
+	mu   sync.Mutex
+	sets map[string]*parkedRows
+}
+
+var resultPages = &resultPageStore{sets: map[string]*parkedRows{}}
+
+// park stores rows that follow offset earlier rows and returns a cursor.
+// resume is handed out with the last of them.
+func (s *resultPageStore) park(tool, key string, rows []any, offset int, resume string) string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	cursor := "rp_" + hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	if len(s.sets) >= maxResultPageSets {
+		var oldest string
+		for c, p := range s.sets {
+			if oldest == "" || p.created.Before(s.sets[oldest].created) {
+				oldest = c
+			}
+		}
+		delete(s.sets, oldest)
+	}
+	s.sets[cursor] = &parkedRows{tool: tool, key: key, rows: rows, offset: offset, resume: resume, created: time.Now()}
+	return cursor
+}
+
+// take removes and returns the rows behind cursor.
+func (s *resultPageStore) take(cursor string) (*parkedRows, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	p, ok := s.sets[cursor]
+	delete(s.sets, cursor)
+	return p, ok
+}
+
+func (s *resultPageStore) prune(now time.Time) {
+	for c, p := range s.sets {
+		if now.Sub(p.created) > resultPageTTL {
+			delete(s.sets, c)
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"net/http"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 	httpSwagger "github.com/swaggo/http-swagger"
 	_ "github.com/your-org/safecast-mcp-server/cmd/mcp-server/docs"
 )
@@ -41,30 +42,56 @@ var favicon16 []byte
 //go:embed static/favicon-32x32.png
 var favicon32 []byte
 
-// RESTHandler wires all REST API routes onto a mux.
-type RESTHandler struct{}
+// RESTHandler wires all REST API routes onto a mux. server is the MCP
+// server whose tools /api/tools lists.
+type RESTHandler struct {
+	server *server.MCPServer
+}
 
 // Register attaches all /api/* routes and the /docs/ Swagger UI to mux.
 func (h *RESTHandler) Register(mux *http.ServeMux) {
 	// Historical data
-	mux.HandleFunc("/api/radiation", h.handleRadiation)
-	mux.HandleFunc("/api/area", h.handleArea)
-	mux.HandleFunc("/api/tracks", h.handleTracks)
-	mux.HandleFunc("/api/track/", h.handleTrack)   // /api/track/{id}
-	mux.HandleFunc("/api/device/", h.handleDevice) // /api/device/{id}/history
+	mux.HandleFunc("/api/radiation", cacheFor(ttlHistorical, h.handleRadiation))
+	mux.HandleFunc("/api/area", cacheFor(ttlHistorical, h.handleArea))
+	mux.HandleFunc("/api/tracks", cacheFor(ttlRecent, h.handleTracks))
+	mux.HandleFunc("/api/track/", cacheFor(ttlImmutable, h.handleTrack)) // /api/track/{id} or /api/track/{id}/stream
+	mux.HandleFunc("/api/device/", cacheFor(ttlRecent, h.handleDevice))  // /api/device/{id}/history
+	mux.HandleFunc("/api/export/measurements.csv", cacheFor(ttlHistorical, h.handleMeasurementExport))
 
 	// Real-time sensors
-	mux.HandleFunc("/api/sensors", h.handleSensors)
-	mux.HandleFunc("/api/sensor/", h.handleSensor) // /api/sensor/{id}/current or /history
+	mux.HandleFunc("/api/sensors", cacheFor(ttlRecent, h.handleSensors))
+	mux.HandleFunc("/api/sensors/status", cacheFor(ttlLive, h.handleSensorsStatus))
+	mux.HandleFunc("/api/sensor/", cacheBy(sensorCacheTTL, h.handleSensor)) // /api/sensor/{id}/current or /history
 
 	// Spectroscopy
-	mux.HandleFunc("/api/spectra", h.handleSpectra)
-	mux.HandleFunc("/api/spectrum/", h.handleSpectrum) // /api/spectrum/{marker_id}
+	mux.HandleFunc("/api/spectra", cacheFor(ttlHistorical, h.handleSpectra))
+	mux.HandleFunc("/api/spectra/export", cacheFor(ttlHistorical, h.handleSpectraExport))
+	mux.HandleFunc("/api/spectra/roi", cacheFor(ttlHistorical, h.handleSpectraROI))
+	mux.HandleFunc("/api/spectra/dose-check", cacheFor(ttlHistorical, h.handleSpectraDoseCheck))
+	mux.HandleFunc("/api/spectrum/", cacheFor(ttlImmutable, h.handleSpectrum)) // /api/spectrum/{marker_id}
 
 	// Reference / stats
-	mux.HandleFunc("/api/stats", h.handleStats)
-	mux.HandleFunc("/api/extreme", handleRESTExtremeReadings)
-	mux.HandleFunc("/api/info/", h.handleInfo) // /api/info/{topic}
+	mux.HandleFunc("/api/stats", cacheFor(ttlStats, h.handleStats))
+	mux.HandleFunc("/api/stats/timeseries", cacheFor(ttlStats, h.handleStatsTimeseries))
+	mux.HandleFunc("/api/extreme", cacheFor(ttlStats, handleRESTExtremeReadings))
+	mux.HandleFunc("/api/info/", cacheFor(ttlReference, h.handleInfo)) // /api/info/{topic}
+	mux.HandleFunc("/api/reports", cacheFor(ttlRecent, h.handleReports))
+	mux.HandleFunc("/api/reports/", cacheBy(reportCacheTTL, h.handleReport)) // /api/reports/{id} or /api/reports/latest
+	mux.HandleFunc("/api/tools", cacheFor(ttlRecent, h.handleTools))
+
+	// Liveness, build info and Prometheus metrics
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	// Maintainer-only administration (never cached, MCP_ADMIN_ALLOWED_IPS)
+	mux.HandleFunc("/api/admin/maintenance", guardAdminAccess(h.handleMaintenance))
+	mux.HandleFunc("/api/admin/audit", guardAdminAccess(h.handleAuditExport))
+
+	// Embeddable HTML card for a fixed sensor
+	mux.HandleFunc("/widget/", cacheFor(ttlLive, h.handleWidget)) // /widget/{device_id}
+
+	// Map shortlinks from tool results (never cached: clicks are counted)
+	mux.HandleFunc("/s/", h.handleShortlink) // /s/{code}
 
 	// GPT-optimised compact endpoints (for Custom GPT Actions)
 	h.RegisterGPT(mux)
@@ -167,6 +194,11 @@ func jsonEncode(w io.Writer, v any) error {
 // The tool functions already produce indented JSON, so we write the text content
 // straight through. Tool errors become HTTP 400 responses.
 func serveMCPResult(w http.ResponseWriter, result *mcp.CallToolResult, err error) {
+	serveMCPResultAs(w, result, err, "application/json")
+}
+
+// serveMCPResultAs is serveMCPResult with another content type.
+func serveMCPResultAs(w http.ResponseWriter, result *mcp.CallToolResult, err error, contentType string) {
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -187,7 +219,7 @@ func serveMCPResult(w http.ResponseWriter, result *mcp.CallToolResult, err error
 		writeError(w, http.StatusBadRequest, text)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(http.StatusOK)
 	_, _ = io.WriteString(w, text)
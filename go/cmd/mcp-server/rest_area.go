@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"net/http"
 	"strconv"
 )
@@ -16,6 +17,9 @@ import (
 // @Param       min_lon query  number  true  "Western boundary longitude (-180 to 180)"
 // @Param       max_lon query  number  true  "Eastern boundary longitude (-180 to 180)"
 // @Param       limit   query  integer false "Maximum number of results (1 to 10000)" default(100)
+// @Param       start_date query string false "Only measurements captured on or after this day (YYYY-MM-DD, UTC)"
+// @Param       end_date   query string false "Only measurements captured on or before this day (YYYY-MM-DD, UTC)"
+// @Param       output_format query string false "json, or geojson for a FeatureCollection" Enums(json, geojson) default(json)
 // @Success     200 {object} map[string]interface{} "Measurements with count, bbox, and source"
 // @Failure     400 {object} map[string]string "Invalid or missing parameters"
 // @Router      /area [get]
@@ -75,15 +79,37 @@ func (h *RESTHandler) handleArea(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if limit > 10 {
-		limit = 10
+
+	window, err := parseDateWindow(q.Get)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	geojson, ok := restOutputFormat(w, r)
+	if !ok {
+		return
 	}
 
 	if dbAvailable() {
-		result, err := searchAreaDB(r.Context(), minLat, maxLat, minLon, maxLon, limit)
-		serveMCPResult(w, result, err)
+		ctx := r.Context()
+		if limit > restStreamThreshold {
+			from, to := window.bounds()
+			streamList(w, r, listStream[markerRow]{
+				head:    searchAreaHead(minLat, maxLat, minLon, maxLon, window, searchAreaTotal(ctx, minLat, maxLat, minLon, maxLon, window)),
+				key:     "measurements",
+				convert: func(row *markerRow) map[string]any { return markerMeasurement(ctx, row) },
+				geojson: geojson,
+			}, searchAreaSQL, minLon, minLat, maxLon, maxLat, limit, snapshotBound(ctx), int64(math.MaxInt64), int64(math.MaxInt64), from, to)
+			return
+		}
+		result, err := searchAreaDB(ctx, minLat, maxLat, minLon, maxLon, window, limit, pageRequest{})
+		serveMCPResultFormat(w, result, err, geojson)
 	} else {
-		result, err := searchAreaAPI(r.Context(), minLat, maxLat, minLon, maxLon, limit)
-		serveMCPResult(w, result, err)
+		// Hard cap for the API fallback, which builds the whole response
+		// in memory (Custom GPT Actions use the compact /api/gpt/* routes)
+		limit = min(limit, 10)
+		result, err := searchAreaAPI(r.Context(), minLat, maxLat, minLon, maxLon, window, limit, pageRequest{})
+		serveMCPResultFormat(w, result, err, geojson)
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -46,12 +47,13 @@ func (h *RESTHandler) handleDevice(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	limit := 200
+	defLimit, maxLimit := toolLimits("device_history", 200, 10000)
+	limit := defLimit
 	if s := q.Get("limit"); s != "" {
 		var err error
 		limit, err = strconv.Atoi(s)
-		if err != nil || limit < 1 || limit > 10000 {
-			writeError(w, http.StatusBadRequest, "limit must be between 1 and 10000")
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 			return
 		}
 	}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Measurement CSV export ─────────────────────────────────────────────────
+//
+// query_radiation and search_area return at most 10000 rows, cut further by
+// the response budget, and a JSON result of tens of thousands of
+// measurements is no use to a model anyway. Researchers who want every
+// marker of an area get /api/export/measurements.csv instead: the matched
+// markers streamed as CSV straight from the database cursor, in chunked
+// transfer encoding, so neither the server nor the client holds the whole
+// file. The export_csv tool (tool_export_csv.go) checks a query and hands
+// out the download URL.
+//
+// Once the status line is out a failure can no longer be reported as an
+// error response, so the rows written and whether the export completed are
+// sent as the X-Export-Rows and X-Export-Complete trailers.
+
+const measurementExportPath = "/api/export/measurements.csv"
+
+var measurementExportColumns = []string{
+	"id", "captured_at", "lat", "lon", "doserate_usvh", "count_rate_cpm",
+	"altitude", "device_id", "detector", "track_id", "has_spectrum",
+}
+
+// measurementExportFilter is the query of an export. It needs an area:
+// a bounding box or a point and radius.
+type measurementExportFilter struct {
+	hasBBox                        bool
+	minLat, maxLat, minLon, maxLon float64
+	hasRadius                      bool
+	lat, lon, radiusM              float64
+	start, end                     time.Time // end is exclusive
+	detector                       string
+	snapshot                       *datasetSnapshot
+	limit                          int
+}
+
+// where renders the filter's conditions, binding through bind.
+func (f measurementExportFilter) where(bind func(any) string) string {
+	conds := []string{}
+	if f.hasBBox {
+		conds = append(conds, fmt.Sprintf("m.geom && ST_MakeEnvelope(%s, %s, %s, %s, 4326)",
+			bind(f.minLon), bind(f.minLat), bind(f.maxLon), bind(f.maxLat)))
+	}
+	if f.hasRadius {
+		// Same bbox pre-filter and ST_DWithin refinement as queryRadiationSQL.
+		lat, lon, r := bind(f.lat), bind(f.lon), bind(f.radiusM)
+		conds = append(conds,
+			fmt.Sprintf("m.geom && ST_Expand(ST_SetSRID(ST_MakePoint(%[2]s, %[1]s), 4326), %[3]s / 111000.0)", lat, lon, r),
+			fmt.Sprintf("ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint(%[2]s, %[1]s), 4326)::geography, %[3]s)", lat, lon, r))
+	}
+	if !f.start.IsZero() {
+		conds = append(conds, "m.date >= "+bind(f.start.Unix()))
+	}
+	if !f.end.IsZero() {
+		conds = append(conds, "m.date < "+bind(f.end.Unix()))
+	}
+	if f.detector != "" {
+		conds = append(conds, detectorFilter("m.detector", f.detector, bind))
+	}
+	bound := int64(math.MaxInt64)
+	if f.snapshot != nil {
+		bound = f.snapshot.MaxMarkerID
+	}
+	conds = append(conds, "m.id <= "+bind(bound))
+	return strings.Join(conds, "\n\t\t  AND ")
+}
+
+// sql selects the exported rows, oldest first.
+func (f measurementExportFilter) sql() (string, []any) {
+	var args []any
+	idx := 1
+	query := `SELECT m.id, to_timestamp(m.date) AS captured_at, m.lat, m.lon,
+			m.doserate, m.countrate, m.altitude, m.device_id, m.detector,
+			m.trackid, m.has_spectrum
+		FROM markers m
+		WHERE ` + f.where(pgBinder(&args, &idx)) + `
+		ORDER BY m.date, m.id`
+	query += " LIMIT " + pgBinder(&args, &idx)(f.limit)
+	return query, args
+}
+
+// countSQL counts the rows the export will contain, stopping at the limit.
+func (f measurementExportFilter) countSQL() (string, []any) {
+	var args []any
+	idx := 1
+	query := `SELECT count(*) AS total FROM (
+		SELECT 1 FROM markers m
+		WHERE ` + f.where(pgBinder(&args, &idx))
+	query += "\n\t\tLIMIT " + pgBinder(&args, &idx)(f.limit) + "\n\t) t"
+	return query, args
+}
+
+// describe echoes the filter in the export_csv result.
+func (f measurementExportFilter) describe() map[string]any {
+	d := map[string]any{"limit": f.limit}
+	if f.hasBBox {
+		d["bbox"] = map[string]any{"min_lat": f.minLat, "max_lat": f.maxLat, "min_lon": f.minLon, "max_lon": f.maxLon}
+	}
+	if f.hasRadius {
+		d["point"] = map[string]any{"lat": f.lat, "lon": f.lon, "radius_m": f.radiusM}
+	}
+	if !f.start.IsZero() {
+		d["start_date"] = f.start.Format("2006-01-02")
+	}
+	if !f.end.IsZero() {
+		d["end_date"] = f.end.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	if f.detector != "" {
+		d["detector"] = f.detector
+		d["detector_matches"] = detectorAliases(f.detector)
+	}
+	return d
+}
+
+// parseMeasurementExportFilter reads an export query. The export_csv tool
+// passes its arguments through here as well, so both accept the same.
+func parseMeasurementExportFilter(q url.Values) (measurementExportFilter, error) {
+	get := func(key string) string { return strings.TrimSpace(q.Get(key)) }
+	num := func(key string) (float64, bool, error) {
+		s := get(key)
+		if s == "" {
+			return 0, false, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, true, fmt.Errorf("%s must be a number", key)
+		}
+		return v, true, nil
+	}
+
+	f := measurementExportFilter{detector: get("detector")}
+	var box [4]float64
+	set := 0
+	for i, k := range []string{"min_lat", "max_lat", "min_lon", "max_lon"} {
+		v, ok, err := num(k)
+		if err != nil {
+			return f, err
+		}
+		if ok {
+			box[i] = v
+			set++
+		}
+	}
+	lat, hasLat, err := num("lat")
+	if err != nil {
+		return f, err
+	}
+	lon, hasLon, err := num("lon")
+	if err != nil {
+		return f, err
+	}
+	radius, hasRadius, err := num("radius_m")
+	if err != nil {
+		return f, err
+	}
+
+	switch {
+	case set > 0 && (hasLat || hasLon):
+		return f, fmt.Errorf("pass either min_lat/max_lat/min_lon/max_lon or lat/lon/radius_m, not both")
+	case set > 0 && set < 4:
+		return f, fmt.Errorf("all four bbox parameters (min_lat, max_lat, min_lon, max_lon) must be provided together")
+	case set == 4:
+		f.hasBBox = true
+		f.minLat, f.maxLat, f.minLon, f.maxLon = box[0], box[1], box[2], box[3]
+		if f.minLat < -90 || f.maxLat > 90 || f.minLon < -180 || f.maxLon > 180 {
+			return f, fmt.Errorf("latitude must be between -90 and 90 and longitude between -180 and 180")
+		}
+		if f.minLat >= f.maxLat || f.minLon >= f.maxLon {
+			return f, fmt.Errorf("min_lat must be less than max_lat and min_lon less than max_lon")
+		}
+	case hasLat && hasLon:
+		if !hasRadius {
+			radius = 1500
+		}
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return f, fmt.Errorf("latitude must be between -90 and 90 and longitude between -180 and 180")
+		}
+		if radius < 25 || radius > 50000 {
+			return f, fmt.Errorf("radius_m must be between 25 and 50000 meters")
+		}
+		f.hasRadius, f.lat, f.lon, f.radiusM = true, lat, lon, radius
+	case hasLat || hasLon:
+		return f, fmt.Errorf("lat and lon must be provided together")
+	default:
+		return f, fmt.Errorf("an export needs an area: min_lat/max_lat/min_lon/max_lon or lat/lon (with radius_m)")
+	}
+
+	for _, d := range []struct {
+		key  string
+		dst  *time.Time
+		days int
+	}{{"start_date", &f.start, 0}, {"end_date", &f.end, 1}} {
+		if v := get(d.key); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be in YYYY-MM-DD format", d.key)
+			}
+			*d.dst = t.AddDate(0, 0, d.days)
+		}
+	}
+	if !f.start.IsZero() && !f.end.IsZero() && !f.start.Before(f.end) {
+		return f, fmt.Errorf("start_date must not be after end_date")
+	}
+
+	if v := get("snapshot_id"); v != "" {
+		snap, err := parseSnapshotID(v)
+		if err != nil {
+			return f, err
+		}
+		f.snapshot = &snap
+	}
+
+	defLimit, maxLimit := toolLimits("export_csv", 100000, 1000000)
+	f.limit = defLimit
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		f.limit = n
+	}
+	return f, nil
+}
+
+// handleMeasurementExport handles GET /api/export/measurements.csv
+//
+// @Summary     Export measurements as CSV
+// @Description Streams every measurement in a bounding box or within a radius of a point as CSV, oldest first, without the row limits of /api/radiation and /api/area. The number of rows written and whether the export completed follow as the X-Export-Rows and X-Export-Complete trailers. Requires database connection.
+// @Tags        historical
+// @Produce     text/csv
+// @Param       min_lat     query  number  false "Southern boundary latitude (requires all 4 bbox params)"
+// @Param       max_lat     query  number  false "Northern boundary latitude (requires all 4 bbox params)"
+// @Param       min_lon     query  number  false "Western boundary longitude (requires all 4 bbox params)"
+// @Param       max_lon     query  number  false "Eastern boundary longitude (requires all 4 bbox params)"
+// @Param       lat         query  number  false "Latitude of the centre point (instead of a bbox)"
+// @Param       lon         query  number  false "Longitude of the centre point (instead of a bbox)"
+// @Param       radius_m    query  number  false "Radius around the point in meters (25 to 50000)" default(1500)
+// @Param       start_date  query  string  false "Only measurements on or after this date (YYYY-MM-DD)"
+// @Param       end_date    query  string  false "Only measurements on or before this date (YYYY-MM-DD)"
+// @Param       detector    query  string  false "Filter by detector name (partial match, aliases included)"
+// @Param       snapshot_id query  string  false "Only markers that existed at this dataset snapshot"
+// @Param       limit       query  integer false "Maximum number of rows (1 to 1000000)" default(100000)
+// @Success     200 {file} file "CSV file"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Failure     503 {object} map[string]string "Database unavailable"
+// @Router      /export/measurements.csv [get]
+func (h *RESTHandler) handleMeasurementExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !dbAvailable() {
+		writeError(w, http.StatusServiceUnavailable, "database connection required for measurement export")
+		return
+	}
+	f, err := parseMeasurementExportFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query, args := f.sql()
+	rows, err := dbPool().Query(r.Context(), query, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	filename := "safecast-measurements-" + time.Now().UTC().Format("20060102") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Trailer", "X-Export-Rows, X-Export-Complete")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write(measurementExportColumns)
+	record := make([]string, len(measurementExportColumns))
+	n := 0
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			log.Printf("Measurement export: %v", err)
+			break
+		}
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return // client went away
+		}
+		if n++; n%restStreamFlushRows == 0 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	cw.Flush()
+	complete := rows.Err() == nil
+	if !complete {
+		log.Printf("Measurement export failed after %d rows: %v", n, rows.Err())
+	}
+	w.Header().Set("X-Export-Rows", strconv.Itoa(n))
+	w.Header().Set("X-Export-Complete", strconv.FormatBool(complete))
+}
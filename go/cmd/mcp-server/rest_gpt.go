@@ -9,13 +9,16 @@ import (
 )
 
 // GPT-friendly compact types — short field names to minimize response size.
+// Also returned by the measurement tools with compact: true (compact.go).
 type gptItem struct {
-	USvH float64 `json:"usvh"`
-	At   string  `json:"at"`
-	Lat  float64 `json:"lat"`
-	Lon  float64 `json:"lon"`
-	Det  string  `json:"det,omitempty"`
-	Dist float64 `json:"dist_m,omitempty"`
+	USvH *float64 `json:"usvh,omitempty"`
+	Val  *float64 `json:"v,omitempty"` // value in another unit than µSv/h
+	Unit string   `json:"u,omitempty"`
+	At   string   `json:"at"`
+	Lat  float64  `json:"lat"`
+	Lon  float64  `json:"lon"`
+	Det  string   `json:"det,omitempty"`
+	Dist float64  `json:"dist_m,omitempty"`
 }
 
 type gptResp struct {
@@ -28,9 +31,9 @@ type gptResp struct {
 // RegisterGPT wires /api/gpt/* routes — compact endpoints for ChatGPT Custom GPT Actions.
 // All routes are hard-capped at 5 results and return non-indented JSON.
 func (h *RESTHandler) RegisterGPT(mux *http.ServeMux) {
-	mux.HandleFunc("/api/gpt/radiation", h.handleGPTRadiation)
-	mux.HandleFunc("/api/gpt/area", h.handleGPTArea)
-	mux.HandleFunc("/api/gpt/stats", h.handleGPTStats)
+	mux.HandleFunc("/api/gpt/radiation", cacheFor(ttlHistorical, h.handleGPTRadiation))
+	mux.HandleFunc("/api/gpt/area", cacheFor(ttlHistorical, h.handleGPTArea))
+	mux.HandleFunc("/api/gpt/stats", cacheFor(ttlStats, h.handleGPTStats))
 }
 
 func (h *RESTHandler) handleGPTRadiation(w http.ResponseWriter, r *http.Request) {
@@ -58,9 +61,9 @@ func (h *RESTHandler) handleGPTRadiation(w http.ResponseWriter, r *http.Request)
 
 	var result *mcp.CallToolResult
 	if dbAvailable() {
-		result, _ = queryRadiationDB(r.Context(), lat, lon, radiusM, 5)
+		result, _ = queryRadiationDB(r.Context(), lat, lon, radiusM,dateWindow{}, 5, pageRequest{})
 	} else {
-		result, _ = queryRadiationAPI(r.Context(), lat, lon, radiusM, 5)
+		result, _ = queryRadiationAPI(r.Context(), lat, lon, radiusM,dateWindow{}, 5, pageRequest{})
 	}
 
 	writeGPT(w, result)
@@ -83,9 +86,9 @@ func (h *RESTHandler) handleGPTArea(w http.ResponseWriter, r *http.Request) {
 
 	var result *mcp.CallToolResult
 	if dbAvailable() {
-		result, _ = searchAreaDB(r.Context(), minLat, maxLat, minLon, maxLon, 5)
+		result, _ = searchAreaDB(r.Context(), minLat, maxLat, minLon, maxLon,dateWindow{}, 5, pageRequest{})
 	} else {
-		result, _ = searchAreaAPI(r.Context(), minLat, maxLat, minLon, maxLon, 5)
+		result, _ = searchAreaAPI(r.Context(), minLat, maxLat, minLon, maxLon,dateWindow{}, 5, pageRequest{})
 	}
 
 	writeGPT(w, result)
@@ -120,25 +123,7 @@ func writeGPT(w http.ResponseWriter, result *mcp.CallToolResult) {
 	}
 
 	// Parse the full verbose result
-	type loc struct {
-		Lat float64 `json:"latitude"`
-		Lon float64 `json:"longitude"`
-	}
-	type fullItem struct {
-		Value      *float64 `json:"value"`
-		CapturedAt string   `json:"captured_at"`
-		Location   *loc     `json:"location"`
-		DistanceM  float64  `json:"distance_m"`
-		Detector   string   `json:"detector"`
-	}
-	type fullResult struct {
-		Count        int        `json:"count"`
-		TotalAvail   int        `json:"total_available"`
-		Source       string     `json:"source"`
-		Measurements []fullItem `json:"measurements"`
-	}
-
-	var full fullResult
+	var full map[string]any
 	if err := json.Unmarshal([]byte(raw), &full); err != nil {
 		// Fall back to raw if we can't parse
 		w.WriteHeader(http.StatusOK)
@@ -146,27 +131,5 @@ func writeGPT(w http.ResponseWriter, result *mcp.CallToolResult) {
 		return
 	}
 
-	items := make([]gptItem, 0, len(full.Measurements))
-	for _, m := range full.Measurements {
-		item := gptItem{
-			At:   m.CapturedAt,
-			Det:  m.Detector,
-			Dist: m.DistanceM,
-		}
-		if m.Value != nil {
-			item.USvH = *m.Value
-		}
-		if m.Location != nil {
-			item.Lat = m.Location.Lat
-			item.Lon = m.Location.Lon
-		}
-		items = append(items, item)
-	}
-
-	_ = json.NewEncoder(w).Encode(gptResp{
-		Count: full.Count,
-		Total: full.TotalAvail,
-		Src:   full.Source,
-		Items: items,
-	})
+	_ = json.NewEncoder(w).Encode(compactMeasurements(full))
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleMaintenance handles GET and POST /api/admin/maintenance
+//
+// @Summary     Run or inspect analytics storage maintenance
+// @Description Maintainers only. POST starts a maintenance run in the background (checkpoint, vacuum, reindex, compact) and returns 202; GET returns the running or last run with per-step progress.
+// @Tags        admin
+// @Produce     json
+// @Param       tasks query string false "Comma-separated tasks for POST, or all" default(all)
+// @Success     200 {object} map[string]interface{} "Running or last run"
+// @Success     202 {object} map[string]interface{} "Run started"
+// @Failure     401 {object} map[string]string "Missing or invalid maintainer token"
+// @Failure     409 {object} map[string]string "A run is already in progress"
+// @Router      /admin/maintenance [get]
+// @Router      /admin/maintenance [post]
+func (h *RESTHandler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	maintainer, err := authenticateMaintainer(r.Header)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		run, ok := maintenanceStatus()
+		if !ok {
+			writeJSON(w, http.StatusOK, map[string]any{"status": "idle"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"run": run})
+
+	case http.MethodPost:
+		tasks, err := parseMaintenanceTasks(r.URL.Query().Get("tasks"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		run, err := startMaintenance(tasks, maintainer)
+		if err != nil {
+			status := http.StatusConflict
+			if duckDB == nil {
+				status = http.StatusServiceUnavailable
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		go runMaintenance(run, func(int, int, string) {})
+		started, _ := maintenanceStatus()
+		writeJSON(w, http.StatusAccepted, map[string]any{"run": started})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"net/http"
 	"strconv"
 )
@@ -15,6 +16,9 @@ import (
 // @Param       lon      query  number  true  "Longitude in decimal degrees (-180 to 180)"
 // @Param       radius_m query  number  false "Search radius in meters (25 to 50000)" default(1500)
 // @Param       limit    query  integer false "Maximum number of results (1 to 10000)" default(25)
+// @Param       start_date query string false "Only measurements captured on or after this day (YYYY-MM-DD, UTC)"
+// @Param       end_date   query string false "Only measurements captured on or before this day (YYYY-MM-DD, UTC)"
+// @Param       output_format query string false "json, or geojson for a FeatureCollection" Enums(json, geojson) default(json)
 // @Success     200 {object} map[string]interface{} "Radiation measurements with count, source, and query metadata"
 // @Failure     400 {object} map[string]string "Invalid parameters"
 // @Router      /radiation [get]
@@ -59,16 +63,37 @@ func (h *RESTHandler) handleRadiation(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	// Hard cap for API consumers (e.g. Custom GPT) that can't handle large responses
-	if limit > 10 {
-		limit = 10
+
+	window, err := parseDateWindow(q.Get)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	geojson, ok := restOutputFormat(w, r)
+	if !ok {
+		return
 	}
 
 	if dbAvailable() {
-		result, err := queryRadiationDB(r.Context(), lat, lon, radiusM, limit)
-		serveMCPResult(w, result, err)
+		ctx := r.Context()
+		if limit > restStreamThreshold {
+			from, to := window.bounds()
+			streamList(w, r, listStream[markerRow]{
+				head:    queryRadiationHead(lat, lon, radiusM, window, queryRadiationTotal(ctx, lat, lon, radiusM, window)),
+				key:     "measurements",
+				convert: func(row *markerRow) map[string]any { return markerMeasurement(ctx, row) },
+				geojson: geojson,
+			}, queryRadiationSQL, lat, lon, radiusM, limit, snapshotBound(ctx), int64(math.MaxInt64), int64(math.MaxInt64), from, to)
+			return
+		}
+		result, err := queryRadiationDB(ctx, lat, lon, radiusM, window, limit, pageRequest{})
+		serveMCPResultFormat(w, result, err, geojson)
 	} else {
-		result, err := queryRadiationAPI(r.Context(), lat, lon, radiusM, limit)
-		serveMCPResult(w, result, err)
+		// Hard cap for the API fallback, which builds the whole response
+		// in memory (Custom GPT Actions use the compact /api/gpt/* routes)
+		limit = min(limit, 10)
+		result, err := queryRadiationAPI(r.Context(), lat, lon, radiusM, window, limit, pageRequest{})
+		serveMCPResultFormat(w, result, err, geojson)
 	}
 }
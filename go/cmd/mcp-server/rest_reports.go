@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleReports handles GET /api/reports
+//
+// @Summary     List scheduled reports
+// @Description Lists generated reports (weekly network summary, monthly country statistics and custom schedules), newest first, together with the configured schedules.
+// @Tags        reference
+// @Produce     json
+// @Param       schedule query string  false "Only reports from this schedule"
+// @Param       limit    query integer false "Maximum number of reports (1 to 500)" default(50)
+// @Success     200 {object} map[string]interface{} "Schedules and report metadata"
+// @Failure     503 {object} map[string]string "Report storage unavailable"
+// @Router      /reports [get]
+func (h *RESTHandler) handleReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > 500 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+	}
+
+	reports, err := listReports(r.URL.Query().Get("schedule"), limit)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	schedules, _ := listReportSchedules()
+
+	items := make([]map[string]any, len(reports))
+	for i, rep := range reports {
+		base := "/api/reports/" + strconv.FormatInt(rep.ID, 10)
+		items[i] = map[string]any{
+			"id":           rep.ID,
+			"schedule":     rep.Schedule,
+			"title":        rep.Title,
+			"generated_at": rep.GeneratedAt,
+			"html_url":     base + "?format=html",
+			"markdown_url": base + "?format=md",
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schedules": schedules,
+		"count":     len(items),
+		"reports":   items,
+	})
+}
+
+// handleReport handles GET /api/reports/{id} and /api/reports/latest
+//
+// @Summary     Get a scheduled report
+// @Description Returns one generated report as JSON (with the Markdown body), raw Markdown, or a standalone HTML page. Use "latest" as the ID, optionally with schedule, for the newest report.
+// @Tags        reference
+// @Produce     json
+// @Produce     html
+// @Produce     plain
+// @Param       id       path  string true  "Report ID, or 'latest'"
+// @Param       schedule query string false "With id=latest: newest report of this schedule"
+// @Param       format   query string false "Response format" Enums(json, md, html) default(json)
+// @Success     200 {object} map[string]interface{} "The report"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Failure     404 {object} map[string]string "Report not found"
+// @Router      /reports/{id} [get]
+func (h *RESTHandler) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var id int64
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/reports/")
+	if idStr != "latest" {
+		var err error
+		id, err = strconv.ParseInt(idStr, 10, 64)
+		if err != nil || id < 1 {
+			writeError(w, http.StatusBadRequest, "id must be a positive integer or 'latest'")
+			return
+		}
+	}
+
+	rep, err := getReport(id, r.URL.Query().Get("schedule"))
+	if errors.Is(err, errSavedNotFound) {
+		writeError(w, http.StatusNotFound, "report not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, rep)
+	case "md", "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = w.Write([]byte(rep.Markdown))
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(rep.HTML))
+	default:
+		writeError(w, http.StatusBadRequest, "format must be one of: json, md, html")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -74,12 +75,13 @@ func (h *RESTHandler) handleSensors(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	limit := 50
+	defLimit, maxLimit := toolLimits("list_sensors", 50, 1000)
+	limit := defLimit
 	if s := q.Get("limit"); s != "" {
 		var err error
 		limit, err = strconv.Atoi(s)
-		if err != nil || limit < 1 || limit > 1000 {
-			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 			return
 		}
 	}
@@ -98,6 +100,7 @@ func (h *RESTHandler) handleSensors(w http.ResponseWriter, r *http.Request) {
 // @Param       start_date query   string  false "Start date for history (YYYY-MM-DD) — required for /history"
 // @Param       end_date   query   string  false "End date for history (YYYY-MM-DD, default: today)"
 // @Param       limit      query   integer false "Maximum number of results (1 to 1000)" default(25)
+// @Param       flag_rain_spikes query boolean false "History only: label spikes shaped like radon washout after rain" default(true)
 // @Success     200 {object} map[string]interface{} "Sensor readings"
 // @Failure     400 {object} map[string]string "Invalid parameters"
 // @Failure     503 {object} map[string]string "Database unavailable"
@@ -131,12 +134,13 @@ func (h *RESTHandler) handleSensor(w http.ResponseWriter, r *http.Request) {
 
 	switch action {
 	case "current", "":
-		limit := 25
+		defLimit, maxLimit := toolLimits("sensor_current", 25, 1000)
+		limit := defLimit
 		if s := q.Get("limit"); s != "" {
 			var err error
 			limit, err = strconv.Atoi(s)
-			if err != nil || limit < 1 || limit > 1000 {
-				writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			if err != nil || limit < 1 || limit > maxLimit {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 				return
 			}
 		}
@@ -169,16 +173,18 @@ func (h *RESTHandler) handleSensor(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		limit := 200
+		defLimit, maxLimit := toolLimits("sensor_history", 200, 10000)
+		limit := defLimit
 		if s := q.Get("limit"); s != "" {
 			limit, err = strconv.Atoi(s)
-			if err != nil || limit < 1 || limit > 10000 {
-				writeError(w, http.StatusBadRequest, "limit must be between 1 and 10000")
+			if err != nil || limit < 1 || limit > maxLimit {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 				return
 			}
 		}
 
-		result, err := sensorHistoryDB(r.Context(), deviceID, startDate, endDate, limit)
+		spikes := q.Get("flag_rain_spikes") != "false"
+		result, err := sensorHistoryDB(r.Context(), deviceID, startDate, endDate, limit, spikes)
 		serveMCPResult(w, result, err)
 
 	default:
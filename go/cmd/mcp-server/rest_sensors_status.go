@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ── Fleet status ───────────────────────────────────────────────────────────
+//
+// /api/sensors/status lists every fixed sensor with its latest reading, the
+// reading's age and a status, in one response small enough for the hardware
+// team's fleet dashboard to poll every minute. A sensor is "ok" while its
+// latest reading is younger than SENSOR_LATE_AFTER, "late" until
+// SENSOR_OFFLINE_AFTER and "offline" after that. A current reading of zero
+// or less makes it "suspect": a working Geiger tube always counts
+// background. Battery levels are included where the realtime table stores
+// them.
+
+const (
+	defaultSensorLateAfter    = time.Hour
+	defaultSensorOfflineAfter = 24 * time.Hour
+)
+
+var sensorStatuses = []string{"ok", "late", "offline", "suspect"}
+
+// sensorBatteryColumns are the column names a battery level may be stored
+// under in realtime_measurements, most specific first.
+var sensorBatteryColumns = []string{"battery_voltage", "battery_level", "battery"}
+
+// fleetSensor is one sensor's latest reading.
+type fleetSensor struct {
+	ID, Name, Type string
+	Value          float64
+	Unit           string
+	At             time.Time
+	Lat, Lon       float64
+	Battery        any // nil when not stored
+}
+
+// sensorStatus classifies a sensor by the age and value of its latest
+// reading.
+func sensorStatus(age, late, offline time.Duration, value float64) string {
+	switch {
+	case age > offline:
+		return "offline"
+	case age > late:
+		return "late"
+	case value <= 0 || math.IsNaN(value):
+		return "suspect"
+	}
+	return "ok"
+}
+
+// handleSensorsStatus handles GET /api/sensors/status
+//
+// @Summary     Fleet status of all fixed sensors
+// @Description Every fixed sensor with its latest value, the value's age, battery level where stored, and a status: ok, late (no reading for SENSOR_LATE_AFTER, default 1 h), offline (none for SENSOR_OFFLINE_AFTER, default 24 h) or suspect (a current reading of zero or less). Meant to be polled once a minute.
+// @Tags        realtime
+// @Produce     json
+// @Param       type   query string false "Filter by sensor type (e.g. Pointcast, Solarcast, bGeigieZen)"
+// @Param       status query string false "Only sensors with this status" Enums(ok, late, offline, suspect)
+// @Success     200 {object} map[string]interface{} "Sensors with status"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Failure     503 {object} map[string]string "Database unavailable"
+// @Router      /sensors/status [get]
+func (h *RESTHandler) handleSensorsStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	sensorType := strings.TrimSpace(q.Get("type"))
+	want := q.Get("status")
+	if want != "" && !slices.Contains(sensorStatuses, want) {
+		writeError(w, http.StatusBadRequest, "status must be one of: "+strings.Join(sensorStatuses, ", "))
+		return
+	}
+
+	var sensors []fleetSensor
+	var source string
+	var err error
+	switch {
+	case dbAvailable():
+		sensors, err = fleetSensorsDB(r.Context(), sensorType)
+		source = "database"
+	case demoMode:
+		sensors, source = fleetSensorsDemo(sensorType), "demo"
+	default:
+		writeError(w, http.StatusServiceUnavailable, "database connection required for sensor data")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	late := durationEnv("SENSOR_LATE_AFTER", defaultSensorLateAfter)
+	offline := durationEnv("SENSOR_OFFLINE_AFTER", defaultSensorOfflineAfter)
+	now := time.Now().UTC()
+	counts := map[string]int{}
+	for _, s := range sensorStatuses {
+		counts[s] = 0
+	}
+	list := make([]map[string]any, 0, len(sensors))
+	for _, s := range sensors {
+		age := now.Sub(s.At)
+		status := sensorStatus(age, late, offline, s.Value)
+		counts[status]++
+		if want != "" && status != want {
+			continue
+		}
+		entry := map[string]any{
+			"id":     s.ID,
+			"name":   s.Name,
+			"type":   s.Type,
+			"value":  roundSignificant(s.Value, doseSignificantDigits),
+			"at":     s.At.Format(time.RFC3339),
+			"age_s":  int64(max(age, 0).Seconds()),
+			"status": status,
+			"lat":    math.Round(s.Lat*1e6) / 1e6,
+			"lon":    math.Round(s.Lon*1e6) / 1e6,
+		}
+		sensorUnit(r.Context(), entry, s.Unit)
+		if s.Battery != nil {
+			entry["battery"] = s.Battery
+		}
+		list = append(list, entry)
+	}
+	slices.SortStableFunc(list, func(a, b map[string]any) int {
+		return strings.Compare(a["id"].(string), b["id"].(string))
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"generated_at": now.Format(time.RFC3339),
+		"source":       source,
+		"count":        len(list),
+		"total":        len(sensors),
+		"by_status":    counts,
+		"thresholds": map[string]any{
+			"late_after_s":    late.Seconds(),
+			"offline_after_s": offline.Seconds(),
+		},
+		"sensors": list,
+	})
+}
+
+// fleetSensorsDB reads the latest reading of every sensor.
+func fleetSensorsDB(ctx context.Context, sensorType string) ([]fleetSensor, error) {
+	battery := "NULL"
+	cols, err := queryRows(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_name = 'realtime_measurements'`)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sensorBatteryColumns {
+		if slices.ContainsFunc(cols, func(c map[string]any) bool { return c["column_name"] == name }) {
+			battery = name
+			break
+		}
+	}
+
+	var args []any
+	next := 1
+	bind := pgBinder(&args, &next)
+	filter := ""
+	if sensorType != "" {
+		filter = fmt.Sprintf("AND (%s OR %s)", detectorFilter("transport", sensorType, bind), detectorFilter("device_name", sensorType, bind))
+	}
+	rows, err := queryRows(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (device_id)
+			device_id,
+			COALESCE(device_name, device_id) AS device_name,
+			COALESCE(transport, '') AS transport,
+			value,
+			COALESCE(unit, 'µSv/h') AS unit,
+			measured_at,
+			lat, lon,
+			%s AS battery
+		FROM realtime_measurements
+		WHERE to_timestamp(measured_at) <= NOW() %s
+		ORDER BY device_id, measured_at DESC`, battery, filter), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors := make([]fleetSensor, 0, len(rows))
+	for _, r := range rows {
+		s := fleetSensor{Battery: r["battery"]}
+		s.ID = fmt.Sprint(r["device_id"])
+		s.Name, _ = r["device_name"].(string)
+		s.Type, _ = r["transport"].(string)
+		s.Unit, _ = r["unit"].(string)
+		s.Value, _ = toFloat(r["value"])
+		s.Lat, _ = toFloat(r["lat"])
+		s.Lon, _ = toFloat(r["lon"])
+		at, _ := toFloat(r["measured_at"])
+		s.At = time.Unix(int64(at), 0).UTC()
+		sensors = append(sensors, s)
+	}
+	return sensors, nil
+}
+
+// fleetSensorsDemo is fleetSensorsDB on the demo dataset.
+func fleetSensorsDemo(sensorType string) []fleetSensor {
+	var sensors []fleetSensor
+	for _, s := range demoData().Sensors {
+		if sensorType != "" && !demoDetectorMatch(s.Transport, sensorType) && !demoDetectorMatch(s.Name, sensorType) {
+			continue
+		}
+		if len(s.Readings) == 0 {
+			continue
+		}
+		latest := s.Readings[len(s.Readings)-1]
+		sensors = append(sensors, fleetSensor{
+			ID: s.ID, Name: s.Name, Type: s.Transport,
+			Value: latest.Value, Unit: "µSv/h",
+			At:  time.Unix(latest.At, 0).UTC(),
+			Lat: s.Lat, Lon: s.Lon,
+		})
+	}
+	return sensors
+}
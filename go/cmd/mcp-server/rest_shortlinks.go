@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// handleShortlink serves GET /s/{code}: a redirect to the map view behind a
+// short_url (shortlinks.go). Never cached, so every click is counted.
+func (h *RESTHandler) handleShortlink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	code := strings.TrimPrefix(r.URL.Path, "/s/")
+	if code == "" || strings.Contains(code, "/") {
+		writeError(w, http.StatusNotFound, "shortlink not found")
+		return
+	}
+	target, ok := resolveShortlink(code, r.Referer())
+	if !ok {
+		writeError(w, http.StatusNotFound, "shortlink not found")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
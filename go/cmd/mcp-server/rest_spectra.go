@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 // handleSpectra handles GET /api/spectra
@@ -84,12 +87,13 @@ func (h *RESTHandler) handleSpectra(w http.ResponseWriter, r *http.Request) {
 	deviceModel := q.Get("device_model")
 	trackID := q.Get("track_id")
 
-	limit := 50
+	defLimit, maxLimit := toolLimits("list_spectra", 50, 500)
+	limit := defLimit
 	if s := q.Get("limit"); s != "" {
 		var err error
 		limit, err = strconv.Atoi(s)
-		if err != nil || limit < 1 || limit > 500 {
-			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 			return
 		}
 	}
@@ -135,3 +139,122 @@ func (h *RESTHandler) handleSpectrum(w http.ResponseWriter, r *http.Request) {
 		serveMCPResult(w, result, err)
 	}
 }
+
+// handleSpectraROI handles GET /api/spectra/roi
+//
+// @Summary     Net count rates in isotope energy windows
+// @Description Net count rates (counts per second above the continuum) in the Cs-137, I-131, K-40 and Cs-134 energy windows, for the spectrum at a marker or every spectrum on a track in time order. Same as the spectrum_roi_rate tool.
+// @Tags        spectroscopy
+// @Produce     json
+// @Param       marker_id query integer false "Marker with a spectrum (one of marker_id or track_id)"
+// @Param       track_id  query string  false "Track whose spectra to analyse (one of marker_id or track_id)"
+// @Param       windows   query string  false "Comma-separated windows: cs137, i131, k40, cs134" default(cs137,i131,k40)
+// @Param       limit     query integer false "Maximum number of spectra for a track (1 to 500)" default(100)
+// @Success     200 {object} map[string]interface{} "Window rates per spectrum"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Router      /spectra/roi [get]
+func (h *RESTHandler) handleSpectraROI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	args := map[string]any{}
+	if v := q.Get("marker_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			writeError(w, http.StatusBadRequest, "marker_id must be a positive integer")
+			return
+		}
+		args["marker_id"] = float64(id)
+	}
+	if v := q.Get("track_id"); v != "" {
+		args["track_id"] = v
+	}
+	if v := q.Get("windows"); v != "" {
+		var windows []any
+		for _, k := range strings.Split(v, ",") {
+			windows = append(windows, strings.TrimSpace(k))
+		}
+		args["windows"] = windows
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "limit must be an integer")
+			return
+		}
+		args["limit"] = float64(n)
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "spectrum_roi_rate"
+	req.Params.Arguments = args
+	result, err := handleSpectrumROIRate(r.Context(), req)
+	serveMCPResult(w, result, err)
+}
+
+// handleSpectraDoseCheck handles GET /api/spectra/dose-check
+//
+// @Summary     Check spectra against their marker dose rates
+// @Description Compares each spectrum's count rate, divided by the detector's sensitivity, with the dose rate recorded at its marker, flags spectra that disagree by more than the tolerance factor and summarizes per device model. Same as the spectrum_dose_check tool.
+// @Tags        spectroscopy
+// @Produce     json
+// @Param       marker_id    query integer false "Check only the spectrum at this marker"
+// @Param       track_id     query string  false "Only spectra on this track"
+// @Param       device_model query string  false "Only spectra from this detector model (partial match)"
+// @Param       tolerance    query number  false "Flag factor (1.1 to 10)" default(2)
+// @Param       sensitivity  query number  false "Detector sensitivity in cps per µSv/h, overriding the built-in value"
+// @Param       only_flagged query boolean false "Return only flagged spectra" default(false)
+// @Param       limit        query integer false "Maximum number of spectra to check (1 to 500)" default(100)
+// @Success     200 {object} map[string]interface{} "Per-spectrum checks and per-model summary"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Router      /spectra/dose-check [get]
+func (h *RESTHandler) handleSpectraDoseCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	args := map[string]any{}
+	if v := q.Get("marker_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			writeError(w, http.StatusBadRequest, "marker_id must be a positive integer")
+			return
+		}
+		args["marker_id"] = float64(id)
+	}
+	for _, key := range []string{"track_id", "device_model"} {
+		if v := q.Get(key); v != "" {
+			args[key] = v
+		}
+	}
+	for param, key := range map[string]string{"tolerance": "tolerance", "sensitivity": "sensitivity_cps_per_usvh"} {
+		if v := q.Get(param); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, param+" must be a number")
+				return
+			}
+			args[key] = f
+		}
+	}
+	if v := q.Get("only_flagged"); v != "" {
+		args["only_flagged"] = v == "true" || v == "1"
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "limit must be an integer")
+			return
+		}
+		args["limit"] = float64(n)
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "spectrum_dose_check"
+	req.Params.Arguments = args
+	result, err := handleSpectrumDoseCheck(r.Context(), req)
+	serveMCPResult(w, result, err)
+}
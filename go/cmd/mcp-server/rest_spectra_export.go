@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Spectra metadata export ────────────────────────────────────────────────
+//
+// Researchers cataloguing detector deployments want every spectrum's
+// metadata, not 50 rows per list_spectra call. /api/spectra/export streams
+// it as CSV straight from Postgres, or writes Parquet through DuckDB's
+// postgres_query (the filtering runs in Postgres either way). Channel data
+// and uploader e-mail addresses are never exported.
+
+var spectraExportColumns = []string{
+	"id", "marker_id", "channel_count", "energy_min_kev", "energy_max_kev",
+	"live_time_sec", "real_time_sec", "device_model", "calibration",
+	"source_format", "filename", "created_at",
+	"doserate", "lat", "lon", "captured_at", "track_id",
+}
+
+const spectraExportSelect = `SELECT s.id, s.marker_id, s.channel_count, s.energy_min_kev, s.energy_max_kev,
+		s.live_time_sec, s.real_time_sec, s.device_model, s.calibration::text AS calibration,
+		s.source_format, s.filename, s.created_at,
+		m.doserate, m.lat, m.lon, to_timestamp(m.date) AS captured_at,
+		m.trackid AS track_id
+	FROM spectra s
+	JOIN markers m ON m.id = s.marker_id
+	WHERE 1=1`
+
+// spectraExportFilter holds the optional filters of an export.
+type spectraExportFilter struct {
+	hasBBox                        bool
+	minLat, maxLat, minLon, maxLon float64
+	sourceFormat, deviceModel      string
+	trackID                        string
+	since, until                   time.Time
+	limit                          int
+}
+
+// sql renders the filter as a full query. With literal=false it uses $n
+// placeholders and returns the arguments; with literal=true the values are
+// inlined for postgres_query, which takes no parameters.
+func (f spectraExportFilter) sql(literal bool) (string, []any) {
+	var sb strings.Builder
+	sb.WriteString(spectraExportSelect)
+	var args []any
+	arg := func(v any) string {
+		if literal {
+			switch t := v.(type) {
+			case string:
+				return pgQuote(t)
+			case time.Time:
+				return pgQuote(t.UTC().Format(time.RFC3339))
+			default:
+				return fmt.Sprint(t)
+			}
+		}
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.hasBBox {
+		fmt.Fprintf(&sb, " AND m.geom && ST_MakeEnvelope(%s, %s, %s, %s, 4326)",
+			arg(f.minLon), arg(f.minLat), arg(f.maxLon), arg(f.maxLat))
+	}
+	if f.sourceFormat != "" {
+		fmt.Fprintf(&sb, " AND s.source_format = %s", arg(f.sourceFormat))
+	}
+	if f.deviceModel != "" {
+		sb.WriteString(" AND " + detectorFilter("s.device_model", f.deviceModel, arg))
+	}
+	if f.trackID != "" {
+		fmt.Fprintf(&sb, " AND m.trackid = %s", arg(f.trackID))
+	}
+	if !f.since.IsZero() {
+		fmt.Fprintf(&sb, " AND s.created_at >= %s::timestamptz", arg(f.since))
+	}
+	if !f.until.IsZero() {
+		fmt.Fprintf(&sb, " AND s.created_at < %s::timestamptz", arg(f.until))
+	}
+	sb.WriteString(" ORDER BY s.id")
+	if f.limit > 0 {
+		fmt.Fprintf(&sb, " LIMIT %s", arg(f.limit))
+	}
+	return sb.String(), args
+}
+
+// pgQuote quotes a string as a SQL literal.
+func pgQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// handleSpectraExport handles GET /api/spectra/export
+//
+// @Summary     Export spectrum metadata in bulk
+// @Description Exports the metadata of every spectroscopy record matching the filters (no channel data) as CSV, or as Parquet when DuckDB is attached to the database. Requires database connection.
+// @Tags        spectroscopy
+// @Produce     text/csv
+// @Produce     application/vnd.apache.parquet
+// @Param       format        query  string  false "csv or parquet" default(csv)
+// @Param       min_lat       query  number  false "Southern boundary latitude (requires all 4 bbox params)"
+// @Param       max_lat       query  number  false "Northern boundary latitude (requires all 4 bbox params)"
+// @Param       min_lon       query  number  false "Western boundary longitude (requires all 4 bbox params)"
+// @Param       max_lon       query  number  false "Eastern boundary longitude (requires all 4 bbox params)"
+// @Param       source_format query  string  false "Filter by file format (e.g. spe, csv)"
+// @Param       device_model  query  string  false "Filter by detector model name (partial match)"
+// @Param       track_id      query  string  false "Filter by track identifier"
+// @Param       since         query  string  false "Only records uploaded on or after this date (YYYY-MM-DD)"
+// @Param       until         query  string  false "Only records uploaded before this date (YYYY-MM-DD)"
+// @Param       limit         query  integer false "Maximum number of rows (default: all)"
+// @Success     200 {file} file "CSV or Parquet file"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Failure     503 {object} map[string]string "Database unavailable"
+// @Router      /spectra/export [get]
+func (h *RESTHandler) handleSpectraExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !dbAvailable() {
+		writeError(w, http.StatusServiceUnavailable, "database connection required for spectroscopy data")
+		return
+	}
+
+	q := r.URL.Query()
+	f, err := parseSpectraExportFilter(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := "safecast-spectra-" + time.Now().UTC().Format("20060102")
+	switch q.Get("format") {
+	case "", "csv":
+		exportSpectraCSV(w, r, f, name+".csv")
+	case "parquet":
+		exportSpectraParquet(w, r, f, name+".parquet")
+	default:
+		writeError(w, http.StatusBadRequest, "format must be csv or parquet")
+	}
+}
+
+func parseSpectraExportFilter(q url.Values) (spectraExportFilter, error) {
+	get := q.Get
+	f := spectraExportFilter{
+		sourceFormat: get("source_format"),
+		deviceModel:  get("device_model"),
+		trackID:      get("track_id"),
+	}
+
+	bbox := []string{get("min_lat"), get("max_lat"), get("min_lon"), get("max_lon")}
+	set := 0
+	for _, v := range bbox {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 0 {
+		if set < 4 {
+			return f, fmt.Errorf("all four bbox parameters (min_lat, max_lat, min_lon, max_lon) must be provided together")
+		}
+		vals := make([]float64, 4)
+		for i, v := range bbox {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return f, fmt.Errorf("bbox parameters must be numbers")
+			}
+			vals[i] = n
+		}
+		f.hasBBox = true
+		f.minLat, f.maxLat, f.minLon, f.maxLon = vals[0], vals[1], vals[2], vals[3]
+		if f.minLat < -90 || f.maxLat > 90 || f.minLon < -180 || f.maxLon > 180 {
+			return f, fmt.Errorf("latitude must be between -90 and 90 and longitude between -180 and 180")
+		}
+		if f.minLat >= f.maxLat || f.minLon >= f.maxLon {
+			return f, fmt.Errorf("min_lat must be less than max_lat and min_lon less than max_lon")
+		}
+	}
+
+	for _, d := range []struct {
+		key string
+		dst *time.Time
+	}{{"since", &f.since}, {"until", &f.until}} {
+		if v := get(d.key); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be in YYYY-MM-DD format", d.key)
+			}
+			*d.dst = t
+		}
+	}
+
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return f, fmt.Errorf("limit must be a positive integer")
+		}
+		f.limit = n
+	}
+	return f, nil
+}
+
+// exportSpectraCSV streams rows from Postgres without buffering the result.
+func exportSpectraCSV(w http.ResponseWriter, r *http.Request, f spectraExportFilter, filename string) {
+	query, args := f.sql(false)
+	rows, err := dbPool().Query(r.Context(), query, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write(spectraExportColumns)
+	record := make([]string, len(spectraExportColumns))
+	n := 0
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			log.Printf("Spectra export: %v", err)
+			break
+		}
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return // client went away
+		}
+		if n++; n%1000 == 0 {
+			cw.Flush()
+		}
+	}
+	cw.Flush()
+	if err := rows.Err(); err != nil {
+		// Headers are gone; all that is left is to log and cut the file short.
+		log.Printf("Spectra export failed after %d rows: %v", n, err)
+	}
+}
+
+func csvValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return t.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// exportSpectraParquet has DuckDB run the query in Postgres and write
+// Parquet to a temporary file, which is then served.
+func exportSpectraParquet(w http.ResponseWriter, r *http.Request, f spectraExportFilter, filename string) {
+	if !duckDBPostgresAttached() {
+		writeError(w, http.StatusServiceUnavailable, "Parquet export needs DuckDB attached to the database; use format=csv")
+		return
+	}
+	tmp, err := os.CreateTemp("", "spectra-*.parquet")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	path := tmp.Name()
+	tmp.Close()
+	defer os.Remove(path)
+
+	query, _ := f.sql(true)
+	copyStmt := fmt.Sprintf("COPY (SELECT * FROM postgres_query('postgres_db', %s)) TO %s (FORMAT PARQUET)",
+		pgQuote(query), pgQuote(path))
+	if _, err := duckDB.ExecContext(r.Context(), copyStmt); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("parquet export failed: %v", err))
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_, _ = io.Copy(w, file)
+}
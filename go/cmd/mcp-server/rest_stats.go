@@ -13,6 +13,7 @@ import (
 // @Tags        reference
 // @Produce     json
 // @Param       interval query string false "Aggregation interval: year, month, or overall" Enums(year, month, overall) default(year)
+// @Param       harmonize query bool false "Correct dose rates for detector response (see harmonization in the response)"
 // @Success     200 {object} map[string]interface{} "Statistics data with interval and source metadata"
 // @Failure     400 {object} map[string]string "Invalid interval value"
 // @Failure     503 {object} map[string]string "Analytics engine unavailable"
@@ -37,7 +38,11 @@ func (h *RESTHandler) handleStats(w http.ResponseWriter, r *http.Request) {
 
 	// Construct a minimal MCP request and reuse the existing handler.
 	req := mcp.CallToolRequest{}
-	req.Params.Arguments = map[string]any{"interval": interval}
+	args := map[string]any{"interval": interval}
+	if v := r.URL.Query().Get("harmonize"); v != "" {
+		args["harmonize"] = v == "true" || v == "1"
+	}
+	req.Params.Arguments = args
 	result, err := handleRadiationStats(r.Context(), req)
 	serveMCPResult(w, result, err)
 }
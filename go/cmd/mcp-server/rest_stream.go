@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ── Streaming list responses ───────────────────────────────────────────────
+//
+// REST list endpoints normally build the tool result and write it in one
+// piece, so a large /api/area call held every row as a map, then the whole
+// response as JSON text, at the same time. Above restStreamThreshold rows
+// the database paths write the response while reading it instead: the
+// fixed fields first, then the array one element at a time, then the
+// fields only known at the end ("count", "_units", "complete"). A row is
+// read from the cursor only after the previous one was written, so a slow
+// client slows the query down instead of rows piling up in memory; the
+// response is flushed every restStreamFlushRows elements.
+//
+// The server has no write timeout, so a client that stops reading would
+// hold the cursor, and its pool connection, forever. Every element
+// renews a write deadline of REST_STREAM_WRITE_TIMEOUT; when a write
+// misses it the query is cancelled and the connection goes back to the
+// pool.
+//
+// The status line goes out before the first row. If the query fails
+// halfway the array ends early, "error" says why and "complete" is false.
+
+const (
+	restStreamThreshold = 2000
+	restStreamFlushRows = 500
+
+	defaultRestStreamWriteTimeout = 30 * time.Second
+)
+
+var restStreamWriteTimeout = durationEnv("REST_STREAM_WRITE_TIMEOUT", defaultRestStreamWriteTimeout)
+
+// listStream describes one streamed list response of T rows.
+type listStream[T any] struct {
+	head    map[string]any          // fields written before the rows
+	key     string                  // name of the row array
+	convert func(*T) map[string]any // database row → element
+	tail    func() map[string]any   // optional fields written after the rows
+	geojson bool                    // write a FeatureCollection (geojson.go)
+}
+
+// streamList runs query and streams the response described by s.
+func streamList[T any, P scannable[T]](w http.ResponseWriter, r *http.Request, s listStream[T], query string, args ...any) {
+	ctx, cancel := context.WithCancel(r.Context())
+	rows, err := dbPool().Query(ctx, query, args...)
+	if err != nil {
+		cancel()
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer func() {
+		cancel() // before Close, which would otherwise read the rest of the rows
+		rows.Close()
+	}()
+	rc := http.NewResponseController(w)
+	defer rc.SetWriteDeadline(time.Time{}) // the connection may serve another request
+
+	key, contentType := s.key, "application/json"
+	if s.geojson {
+		key, contentType = "features", geojsonContentType
+		s.head["type"] = "FeatureCollection"
+		if b, ok := s.head["bbox"]; ok {
+			delete(s.head, "bbox")
+			s.head["query_bbox"] = b
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	out := &jsonObjectWriter{w: bufio.NewWriterSize(w, 64<<10)}
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if out.w.Flush() == nil && flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	seen := map[string]bool{}
+	out.fields(normalizeResult(s.head, false).(map[string]any))
+	out.openArray(key)
+
+	var row T
+	dest := scanTargets[T, P](&row, rows.FieldDescriptions())
+	count := 0
+	for rows.Next() {
+		rc.SetWriteDeadline(time.Now().Add(restStreamWriteTimeout))
+		if err := rows.Scan(dest...); err != nil {
+			break // pgx records scan errors in rows.Err()
+		}
+		elem := s.convert(&row)
+		addMapLinks(elem)
+		addPlaces(elem)
+		if s.geojson {
+			elem = measurementFeature(elem)
+		}
+		if !out.element(normalizeValue("", elem, seen, false)) {
+			return // client went away or stopped reading
+		}
+		count++
+		if count%restStreamFlushRows == 0 {
+			flush()
+		}
+	}
+	rc.SetWriteDeadline(time.Now().Add(restStreamWriteTimeout))
+	out.closeArray()
+
+	tail := map[string]any{"count": count, "streamed": true}
+	if s.tail != nil {
+		for k, v := range s.tail() {
+			tail[k] = v
+		}
+	}
+	tail["complete"] = rows.Err() == nil
+	if err := rows.Err(); err != nil {
+		tail["error"] = err.Error()
+	}
+	if len(seen) > 0 {
+		tail["_units"] = unitsOf(seen)
+	}
+	out.fields(tail)
+	out.end()
+	flush()
+}
+
+// jsonObjectWriter writes one JSON object field by field, indented like
+// jsonResult. Write errors stop all further output.
+type jsonObjectWriter struct {
+	w       *bufio.Writer
+	started bool
+	items   int
+	err     error
+}
+
+func (o *jsonObjectWriter) write(s string) {
+	if o.err == nil {
+		_, o.err = o.w.WriteString(s)
+	}
+}
+
+func (o *jsonObjectWriter) value(v any, prefix string) {
+	data, err := json.MarshalIndent(v, prefix, "  ")
+	if err != nil {
+		data = []byte("null")
+	}
+	o.write(string(data))
+}
+
+func (o *jsonObjectWriter) key(name string) {
+	if !o.started {
+		o.write("{\n")
+		o.started = true
+	} else {
+		o.write(",\n")
+	}
+	o.write("  ")
+	o.value(name, "")
+	o.write(": ")
+}
+
+// fields writes m's fields in key order.
+func (o *jsonObjectWriter) fields(m map[string]any) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		o.key(k)
+		o.value(m[k], "  ")
+	}
+}
+
+func (o *jsonObjectWriter) openArray(name string) {
+	o.key(name)
+	o.write("[")
+	o.items = 0
+}
+
+// element appends v to the open array and reports whether writing works.
+func (o *jsonObjectWriter) element(v any) bool {
+	if o.items > 0 {
+		o.write(",")
+	}
+	o.write("\n    ")
+	o.value(v, "    ")
+	o.items++
+	return o.err == nil
+}
+
+func (o *jsonObjectWriter) closeArray() {
+	if o.items > 0 {
+		o.write("\n  ")
+	}
+	o.write("]")
+}
+
+func (o *jsonObjectWriter) end() {
+	if !o.started {
+		o.write("{")
+	}
+	o.write("\n}\n")
+}
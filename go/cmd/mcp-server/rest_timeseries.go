@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ── Per-country time series ────────────────────────────────────────────────
+//
+// Measurement counts and average dose rate per day, week or month for one
+// country, as needed for the annual report. The series is rolled up from
+// the per-day aggregates in the country_daily snapshot (analytics_snapshot.go)
+// and computed live from the attached replica only while no snapshot has
+// been exported yet. Countries are the bounding boxes of
+// list_supported_regions.
+
+var timeseriesIntervals = []string{"day", "week", "month"}
+
+// timeseriesCountries lists one name per distinct bounding box, so aliases
+// such as "usa" and "united states" are aggregated once.
+func timeseriesCountries() []string {
+	var out []string
+	for _, name := range countryNames() {
+		if canonicalCountry(name) == name {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// canonicalCountry is the alphabetically first name sharing name's box.
+func canonicalCountry(name string) string {
+	bbox := countryBoundingBoxes[name]
+	for _, other := range countryNames() {
+		if countryBoundingBoxes[other] == bbox {
+			return other
+		}
+	}
+	return name
+}
+
+// countryDailyQuery aggregates markers per country and UTC day. It is
+// written for DuckDB against postgres_db.
+func countryDailyQuery(countries []string) string {
+	boxes := make([]string, len(countries))
+	for i, c := range countries {
+		b := countryBoundingBoxes[c]
+		boxes[i] = fmt.Sprintf("(%s, %g, %g, %g, %g)", pgQuote(c), b[0], b[1], b[2], b[3])
+	}
+	return `
+		SELECT c.country,
+		       CAST(to_timestamp(m.date)::TIMESTAMP AS DATE) AS day,
+		       COUNT(*) AS count,
+		       AVG(m.doserate) AS avg_value
+		FROM postgres_db.public.markers m
+		JOIN (VALUES ` + strings.Join(boxes, ", ") + `) AS c(country, min_lat, max_lat, min_lon, max_lon)
+		  ON m.lat BETWEEN c.min_lat AND c.max_lat AND m.lon BETWEEN c.min_lon AND c.max_lon
+		WHERE m.doserate > 0 AND m.doserate < 1000
+		GROUP BY 1, 2`
+}
+
+// handleStatsTimeseries handles GET /api/stats/timeseries
+//
+// @Summary     Per-country measurement time series
+// @Description Returns the number of measurements and the average dose rate per day, week or month inside a country's bounding box, from the materialized daily aggregates. Powered by DuckDB.
+// @Tags        reference
+// @Produce     json
+// @Param       country  query string true  "Country name as listed by list_supported_regions, e.g. japan"
+// @Param       interval query string false "Bucket size" Enums(day, week, month) default(day)
+// @Param       since    query string false "First day to include (YYYY-MM-DD)"
+// @Param       until    query string false "Last day to include (YYYY-MM-DD)"
+// @Success     200 {object} map[string]interface{} "Series of {date, count, avg_usvh} buckets"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Failure     503 {object} map[string]string "No snapshot and no database attached"
+// @Router      /stats/timeseries [get]
+func (h *RESTHandler) handleStatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+
+	if q.Get("country") == "" {
+		writeError(w, http.StatusBadRequest, "country is required")
+		return
+	}
+	country, _, ok := lookupCountry(q.Get("country"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, unknownCountryError(q.Get("country")))
+		return
+	}
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	if !slices.Contains(timeseriesIntervals, interval) {
+		writeError(w, http.StatusBadRequest, "interval must be one of: "+strings.Join(timeseriesIntervals, ", "))
+		return
+	}
+	var since, until time.Time
+	for _, d := range []struct {
+		key string
+		dst *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := q.Get(d.key); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, d.key+" must be in YYYY-MM-DD format")
+				return
+			}
+			*d.dst = t
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		writeError(w, http.StatusBadRequest, "until must not be before since")
+		return
+	}
+	if duckDB == nil {
+		writeError(w, http.StatusServiceUnavailable, "the analytics engine is not initialized")
+		return
+	}
+
+	key := canonicalCountry(country)
+	out := map[string]any{
+		"country":  country,
+		"interval": interval,
+		"note":     "Counts cover the country's bounding box, which includes parts of neighbouring countries and the sea. Days are UTC.",
+	}
+	var daily string
+	if table, takenAt, ok := snapshotSource(countryDailySnapshotFile); ok {
+		daily = table
+		out["source"] = "parquet_snapshot"
+		out["snapshot"] = snapshotInfo(takenAt, "Rolled up from the last daily aggregate snapshot; uploads since then are not included.")
+	} else if duckDBPostgresAttached() {
+		daily = "(" + countryDailyQuery([]string{key}) + ")"
+		out["source"] = "duckdb_postgres_attach"
+	} else {
+		writeError(w, http.StatusServiceUnavailable, "no daily aggregate snapshot has been exported yet and the database is not attached")
+		return
+	}
+
+	conds := []string{"country = " + pgQuote(key)}
+	if !since.IsZero() {
+		conds = append(conds, "day >= "+pgQuote(since.Format("2006-01-02"))+"::DATE")
+	}
+	if !until.IsZero() {
+		conds = append(conds, "day <= "+pgQuote(until.Format("2006-01-02"))+"::DATE")
+	}
+	rows, err := duckDB.QueryContext(r.Context(), `
+		SELECT strftime(DATE_TRUNC('`+interval+`', day), '%Y-%m-%d') AS period,
+		       CAST(SUM(count) AS BIGINT) AS count,
+		       SUM(avg_value * count) / SUM(count) AS avg_value
+		FROM `+daily+` AS d
+		WHERE `+strings.Join(conds, " AND ")+`
+		GROUP BY 1
+		ORDER BY 1`)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("time series query failed: %v", err))
+		return
+	}
+	defer rows.Close()
+
+	series := []map[string]any{}
+	var total int64
+	for rows.Next() {
+		var period string
+		var count int64
+		var avg float64
+		if err := rows.Scan(&period, &count, &avg); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		total += count
+		series = append(series, map[string]any{
+			"date":     period,
+			"count":    count,
+			"avg_usvh": math.Round(avg*10000) / 10000,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	out["points"] = len(series)
+	out["total_measurements"] = total
+	out["series"] = series
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Tool catalog ───────────────────────────────────────────────────────────
+//
+// /api/tools lists the registered MCP tools as tools/list describes them
+// (input schema, annotations, cost and cache _meta), each with the REST
+// routes that answer the same question, so REST-only integrations can see
+// what the server offers and notice when it changes.
+
+// restEndpoint is a REST route that serves a tool's data.
+type restEndpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Note   string `json:"note,omitempty"`
+}
+
+// toolRESTEquivalents maps tool names to their REST routes. Tools missing
+// here have no REST counterpart and are listed with an empty rest array.
+var toolRESTEquivalents = map[string][]restEndpoint{
+	"ping":            {{Method: "GET", Path: "/health"}},
+	"query_radiation": {{Method: "GET", Path: "/api/radiation"}, {Method: "GET", Path: "/api/gpt/radiation", Note: "compact, for Custom GPT Actions"}},
+	"search_area":     {{Method: "GET", Path: "/api/area"}, {Method: "GET", Path: "/api/gpt/area", Note: "compact, for Custom GPT Actions"}},
+	"list_tracks":     {{Method: "GET", Path: "/api/tracks"}},
+	"export_csv":      {{Method: "GET", Path: "/api/export/measurements.csv", Note: "the CSV itself, streamed"}},
+	"get_track": {
+		{Method: "GET", Path: "/api/track/{id}"},
+		{Method: "GET", Path: "/api/track/{id}/stream", Note: "NDJSON in time order, with playback pacing"},
+		{Method: "GET", Path: "/api/track/{id}/thumb.png", Note: "route thumbnail"},
+	},
+	"track_profile":          {{Method: "GET", Path: "/api/track/{id}/profile.svg", Note: "chart only"}},
+	"device_history":         {{Method: "GET", Path: "/api/device/{id}/history"}},
+	"list_sensors":           {{Method: "GET", Path: "/api/sensors"}, {Method: "GET", Path: "/api/sensors/status", Note: "every sensor with latest value, age and status"}},
+	"sensor_current":         {{Method: "GET", Path: "/api/sensor/{id}/current"}, {Method: "GET", Path: "/widget/{device_id}", Note: "embeddable HTML card"}},
+	"sensor_history":         {{Method: "GET", Path: "/api/sensor/{id}/history"}},
+	"list_spectra":           {{Method: "GET", Path: "/api/spectra"}, {Method: "GET", Path: "/api/spectra/export", Note: "all matches as CSV or Parquet"}},
+	"get_spectrum":           {{Method: "GET", Path: "/api/spectrum/{marker_id}"}},
+	"spectrum_roi_rate":      {{Method: "GET", Path: "/api/spectra/roi"}},
+	"spectrum_dose_check":    {{Method: "GET", Path: "/api/spectra/dose-check"}},
+	"radiation_stats":        {{Method: "GET", Path: "/api/stats"}, {Method: "GET", Path: "/api/gpt/stats", Note: "compact, for Custom GPT Actions"}},
+	"query_extreme_readings": {{Method: "GET", Path: "/api/extreme"}},
+	"radiation_info":         {{Method: "GET", Path: "/api/info/{topic}"}},
+	"latest_report":          {{Method: "GET", Path: "/api/reports/{id}", Note: "latest as the id returns the newest"}, {Method: "GET", Path: "/api/reports"}},
+	"analytics_maintenance":  {{Method: "GET, POST", Path: "/api/admin/maintenance", Note: "maintainers only"}},
+}
+
+// handleTools handles GET /api/tools
+//
+// @Summary     List the MCP tools
+// @Description Lists every MCP tool the server has registered, with its JSON input schema, annotations, cost and cache metadata as returned by tools/list, and the REST routes that serve the same data.
+// @Tags        reference
+// @Produce     json
+// @Success     200 {object} map[string]interface{} "Tool catalog"
+// @Router      /tools [get]
+func (h *RESTHandler) handleTools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	registered := h.server.ListTools()
+	list := &mcp.ListToolsResult{Tools: make([]mcp.Tool, 0, len(registered))}
+	for _, st := range registered {
+		list.Tools = append(list.Tools, st.Tool)
+	}
+	sort.Slice(list.Tools, func(i, j int) bool { return list.Tools[i].Name < list.Tools[j].Name })
+	// The same hooks as tools/list, so both describe the tools alike.
+	annotateToolCosts(r.Context(), nil, nil, list)
+	annotateCacheTTL(r.Context(), nil, nil, list)
+
+	tools := make([]map[string]any, 0, len(list.Tools))
+	withREST := 0
+	for _, t := range list.Tools {
+		data, err := json.Marshal(t)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		var entry map[string]any
+		if err := json.Unmarshal(data, &entry); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rest := toolRESTEquivalents[t.Name]
+		if rest == nil {
+			rest = []restEndpoint{}
+		} else {
+			withREST++
+		}
+		entry["rest"] = rest
+		tools = append(tools, entry)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"count":          len(tools),
+		"with_rest":      withREST,
+		"server_version": fullVersion(),
+		"mcp_endpoints": map[string]string{
+			"streamable_http": "/mcp-http",
+			"sse":             "/mcp/sse",
+		},
+		"tools": tools,
+	})
+}
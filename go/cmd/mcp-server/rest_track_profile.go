@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ── Track profile chart ────────────────────────────────────────────────────
+//
+// /api/track/{id}/profile.svg draws the track_profile bins as a standalone
+// SVG (bin average as a line, bin maximum as a faint line) with the peak marked,
+// so the chart_url in track_profile results can be shown as an image.
+
+const (
+	profileChartWidth  = 800
+	profileChartHeight = 320
+	profileChartLeft   = 56 // room for the dose axis labels
+	profileChartBottom = 36 // room for the distance axis labels
+	profileChartPad    = 12
+)
+
+type profileChartView struct {
+	TrackID       string
+	Width, Height int
+	Left, Right   int
+	Top, Bottom   int
+	Avg, Max      string
+	PeakX, PeakY  string
+	PeakLabel     string
+	YTicks        []profileChartTick
+	XTicks        []profileChartTick
+}
+
+type profileChartTick struct {
+	Pos   string
+	Label string
+}
+
+var profileChartTemplate = template.Must(template.New("profile").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" font-family="sans-serif" font-size="11" role="img" aria-label="Dose rate along track {{.TrackID}}">
+<rect width="100%" height="100%" fill="#fff"/>
+<text x="{{.Left}}" y="14" font-size="13" font-weight="600" fill="#1d2733">Track {{.TrackID}}: dose rate along the drive</text>
+{{range .YTicks}}<line x1="{{$.Left}}" x2="{{$.Right}}" y1="{{.Pos}}" y2="{{.Pos}}" stroke="#e3e7ec"/><text x="{{$.Left}}" dx="-6" y="{{.Pos}}" dy="4" text-anchor="end" fill="#555">{{.Label}}</text>
+{{end}}{{range .XTicks}}<text x="{{.Pos}}" y="{{$.Bottom}}" dy="16" text-anchor="middle" fill="#555">{{.Label}}</text>
+{{end}}<line x1="{{.Left}}" x2="{{.Right}}" y1="{{.Bottom}}" y2="{{.Bottom}}" stroke="#999"/>
+<polyline points="{{.Max}}" fill="none" stroke="#e8590c" stroke-opacity=".3" stroke-width="1"/>
+<polyline points="{{.Avg}}" fill="none" stroke="#e8590c" stroke-width="1.8"/>
+<circle cx="{{.PeakX}}" cy="{{.PeakY}}" r="4" fill="#c92a2a"/>
+<text x="{{.PeakX}}" y="{{.PeakY}}" dx="6" dy="-6" fill="#c92a2a">{{.PeakLabel}}</text>
+<text x="{{.Right}}" y="{{.Height}}" dy="-4" text-anchor="end" fill="#555">km along track · µSv/h · Safecast CC0</text>
+</svg>
+`))
+
+// handleTrackProfileChart serves GET /api/track/{id}/profile.svg.
+func (h *RESTHandler) handleTrackProfileChart(w http.ResponseWriter, r *http.Request, trackID string) {
+	points := defaultTrackProfilePoints
+	if s := r.URL.Query().Get("points"); s != "" {
+		var err error
+		points, err = strconv.Atoi(s)
+		if err != nil || points < 10 || points > maxTrackProfilePoints {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("points must be between 10 and %d", maxTrackProfilePoints))
+			return
+		}
+	}
+	p, err := buildTrackProfile(r.Context(), trackID, points)
+	if err != nil {
+		writeError(w, trackProfileErrorStatus(err), err.Error())
+		return
+	}
+
+	v := profileChartView{
+		TrackID: trackID,
+		Width:   profileChartWidth,
+		Height:  profileChartHeight,
+		Left:    profileChartLeft,
+		Right:   profileChartWidth - profileChartPad,
+		Top:     profileChartPad + 14,
+		Bottom:  profileChartHeight - profileChartBottom,
+	}
+	hi := p.Peak.dose * 1.05
+	if hi <= 0 {
+		hi = 1
+	}
+	totalKm := max(p.TotalKm, 0.001)
+	x := func(km float64) float64 {
+		return float64(v.Left) + km/totalKm*float64(v.Right-v.Left)
+	}
+	y := func(dose float64) float64 {
+		return float64(v.Bottom) - dose/hi*float64(v.Bottom-v.Top)
+	}
+
+	var avg, mx strings.Builder
+	for i, b := range p.Bins {
+		if i > 0 {
+			avg.WriteByte(' ')
+			mx.WriteByte(' ')
+		}
+		fmt.Fprintf(&avg, "%.1f,%.1f", x(b.DistanceKm), y(b.AvgUSvH))
+		fmt.Fprintf(&mx, "%.1f,%.1f", x(b.DistanceKm), y(b.MaxUSvH))
+	}
+	v.Avg, v.Max = avg.String(), mx.String()
+	v.PeakX = fmt.Sprintf("%.1f", x(p.Peak.distM/1000))
+	v.PeakY = fmt.Sprintf("%.1f", y(p.Peak.dose))
+	v.PeakLabel = fmt.Sprintf("peak %.3g µSv/h at %.2f km", p.Peak.dose, p.Peak.distM/1000)
+	for i := 0; i <= 4; i++ {
+		d := hi * float64(i) / 4
+		v.YTicks = append(v.YTicks, profileChartTick{fmt.Sprintf("%.1f", y(d)), fmt.Sprintf("%.3g", d)})
+		km := p.TotalKm * float64(i) / 4
+		v.XTicks = append(v.XTicks, profileChartTick{fmt.Sprintf("%.1f", x(km)), fmt.Sprintf("%.3g km", km)})
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := profileChartTemplate.Execute(w, v); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
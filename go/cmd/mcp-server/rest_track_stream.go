@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Track playback stream ──────────────────────────────────────────────────
+//
+// /api/track/{id}/stream emits a track as NDJSON in time order so web
+// clients can animate a survey drive as it arrives instead of loading the
+// whole track first. From the database the markers are read in keyset
+// chunks; the simplemap API only returns whole tracks, so that path sorts
+// in memory and then streams.
+//
+// Lines have a "type": one "header", then "marker" lines, then "end" (or
+// "error" if the stream breaks). Each marker carries offset_s, its time
+// since the first marker, and delay_ms, how long a player should wait
+// before showing it at the requested speed. The server does not sleep.
+
+const (
+	trackStreamChunk       = 1000
+	defaultPlaybackSpeed   = 60.0 // one minute of driving per second
+	defaultPlaybackMaxGapS = 2.0  // cap pauses (stops, lost GPS) at 2 s
+)
+
+type trackStreamMarker struct {
+	Type       string  `json:"type"`
+	Seq        int     `json:"seq"`
+	ID         any     `json:"id"`
+	Value      any     `json:"value"`
+	Unit       string  `json:"unit"`
+	CapturedAt string  `json:"captured_at"`
+	Lat        any     `json:"lat"`
+	Lon        any     `json:"lon"`
+	Height     any     `json:"height,omitempty"`
+	OffsetS    float64 `json:"offset_s"`
+	DelayMs    int64   `json:"delay_ms"`
+}
+
+type trackStreamer struct {
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	flusher http.Flusher
+	speed   float64
+	maxGapS float64
+	seq     int
+	first   time.Time
+	prev    time.Time
+}
+
+func (s *trackStreamer) emit(v any) error {
+	return s.enc.Encode(v)
+}
+
+func (s *trackStreamer) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// marker emits one marker with its pacing fields.
+func (s *trackStreamer) marker(at time.Time, id, value, lat, lon, height any) error {
+	if s.seq == 0 {
+		s.first, s.prev = at, at
+	}
+	gap := at.Sub(s.prev).Seconds() / s.speed
+	delay := math.Max(0, math.Min(gap, s.maxGapS))
+	s.prev = at
+	s.seq++
+	return s.emit(trackStreamMarker{
+		Type:       "marker",
+		Seq:        s.seq,
+		ID:         id,
+		Value:      value,
+		Unit:       "µSv/h",
+		CapturedAt: at.UTC().Format(time.RFC3339),
+		Lat:        lat,
+		Lon:        lon,
+		Height:     height,
+		OffsetS:    at.Sub(s.first).Seconds(),
+		DelayMs:    int64(delay * 1000),
+	})
+}
+
+// handleTrackStream handles GET /api/track/{id}/stream
+//
+// @Summary     Stream a track for playback
+// @Description Streams the markers of a bGeigie track as NDJSON in time order: a header line, one line per marker with offset_s and delay_ms pacing fields, then an end line. Intended for animated playback in web clients.
+// @Tags        historical
+// @Produce     application/x-ndjson
+// @Param       id        path   string  true  "Track identifier (e.g. 8eh5m1)"
+// @Param       speed     query  number  false "Playback speed multiplier used for delay_ms" default(60)
+// @Param       max_gap_s query  number  false "Longest pause in playback seconds; longer gaps are shortened" default(2)
+// @Success     200 {string} string "NDJSON stream"
+// @Failure     400 {object} map[string]string "Invalid parameters"
+// @Router      /track/{id}/stream [get]
+func (h *RESTHandler) handleTrackStream(w http.ResponseWriter, r *http.Request, trackID string) {
+	q := r.URL.Query()
+	speed := defaultPlaybackSpeed
+	if v := q.Get("speed"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100000 {
+			writeError(w, http.StatusBadRequest, "speed must be between 0 and 100000")
+			return
+		}
+		speed = f
+	}
+	maxGapS := defaultPlaybackMaxGapS
+	if v := q.Get("max_gap_s"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 3600 {
+			writeError(w, http.StatusBadRequest, "max_gap_s must be between 0 and 3600")
+			return
+		}
+		maxGapS = f
+	}
+
+	s := &trackStreamer{w: w, enc: json.NewEncoder(w), speed: speed, maxGapS: maxGapS}
+	s.flusher, _ = w.(http.Flusher)
+
+	var err error
+	if dbAvailable() {
+		err = streamTrackDB(r.Context(), s, trackID)
+	} else {
+		err = streamTrackAPI(r.Context(), s, trackID)
+	}
+	if err != nil {
+		if !s.started() {
+			status := http.StatusBadGateway
+			if strings.Contains(err.Error(), "not found") {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		_ = s.emit(map[string]any{"type": "error", "error": err.Error()})
+	} else {
+		_ = s.emit(map[string]any{
+			"type":       "end",
+			"count":      s.seq,
+			"duration_s": s.prev.Sub(s.first).Seconds(),
+		})
+	}
+	s.flush()
+}
+
+// started reports whether the header has gone out.
+func (s *trackStreamer) started() bool {
+	return s.w.Header().Get("Content-Type") == "application/x-ndjson"
+}
+
+func (s *trackStreamer) header(trackID string, total any, source string) error {
+	s.w.Header().Set("Content-Type", "application/x-ndjson")
+	s.w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.w.WriteHeader(http.StatusOK)
+	return s.emit(map[string]any{
+		"type":      "header",
+		"track_id":  trackID,
+		"total":     total,
+		"source":    source,
+		"speed":     s.speed,
+		"max_gap_s": s.maxGapS,
+		"map_url":   trackMapURL(trackID),
+		"note":      "Wait delay_ms before showing each marker to play the drive back at the given speed. offset_s is real time since the first marker.",
+	})
+}
+
+func streamTrackDB(ctx context.Context, s *trackStreamer, trackID string) error {
+	countRow, err := queryRow(ctx, `SELECT count(*) AS total FROM markers WHERE trackid = $1`, trackID)
+	if err != nil {
+		return err
+	}
+	total, _ := toFloat(countRow["total"])
+	if total == 0 {
+		return fmt.Errorf("track %s not found", trackID)
+	}
+	if err := s.header(trackID, int64(total), "database"); err != nil {
+		return err
+	}
+
+	// Keyset pagination on (date, id) keeps each chunk an index range scan.
+	lastDate, lastID := int64(math.MinInt64), int64(math.MinInt64)
+	for {
+		rows, err := queryRows(ctx, `
+			SELECT id, date, doserate AS value, lat, lon, altitude AS height
+			FROM markers
+			WHERE trackid = $1 AND (date, id) > ($2, $3)
+			ORDER BY date, id
+			LIMIT $4`, trackID, lastDate, lastID, trackStreamChunk)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			date, _ := toFloat(row["date"])
+			id, _ := toFloat(row["id"])
+			lastDate, lastID = int64(date), int64(id)
+			if err := s.marker(time.Unix(lastDate, 0), row["id"], row["value"], row["lat"], row["lon"], row["height"]); err != nil {
+				return err
+			}
+		}
+		s.flush()
+		if len(rows) < trackStreamChunk {
+			return nil
+		}
+	}
+}
+
+func streamTrackAPI(ctx context.Context, s *trackStreamer, trackID string) error {
+	resp, err := client.GetTrackData(ctx, trackID, 0, 0)
+	if err != nil {
+		return err
+	}
+	raw, _ := resp["markers"].([]any)
+
+	type timed struct {
+		at time.Time
+		m  map[string]any
+	}
+	markers := make([]timed, 0, len(raw))
+	for _, r := range raw {
+		m, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		ts, _ := m["timeUTC"].(string)
+		at, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			continue
+		}
+		markers = append(markers, timed{at, m})
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].at.Before(markers[j].at) })
+
+	if err := s.header(trackID, len(markers), "api"); err != nil {
+		return err
+	}
+	for i, t := range markers {
+		if err := s.marker(t.at, t.m["id"], t.m["doseRateMicroSvH"], t.m["lat"], t.m["lon"], t.m["altitudeM"]); err != nil {
+			return err
+		}
+		if (i+1)%trackStreamChunk == 0 {
+			s.flush()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ── Track thumbnails ───────────────────────────────────────────────────────
+//
+// /api/track/{id}/thumb.png draws a track's route as a small PNG, each
+// segment coloured by its dose rate, so list_tracks results and the
+// dashboard can preview a drive without loading the map. The markers are
+// the ones track_profile uses; GPS jumps are left out so one bad fix does
+// not shrink the route to a dot. Rendered thumbnails are kept in memory,
+// since a track does not change once it is processed.
+
+const (
+	trackThumbWidth   = 240
+	trackThumbHeight  = 160
+	trackThumbPad     = 8
+	trackThumbEntries = 500 // cached thumbnails, about 2 KB each
+)
+
+// trackThumbStops is the dose colour scale, from blue at background
+// levels to dark red, interpolated on log10(µSv/h).
+var trackThumbStops = []struct {
+	usvh float64
+	c    color.RGBA
+}{
+	{0.03, color.RGBA{0x31, 0x36, 0x95, 0xff}},
+	{0.1, color.RGBA{0x45, 0x75, 0xb4, 0xff}},
+	{0.2, color.RGBA{0x74, 0xad, 0xd1, 0xff}},
+	{0.5, color.RGBA{0xfe, 0xe0, 0x90, 0xff}},
+	{1, color.RGBA{0xfd, 0xae, 0x61, 0xff}},
+	{5, color.RGBA{0xf4, 0x6d, 0x43, 0xff}},
+	{20, color.RGBA{0xd7, 0x30, 0x27, 0xff}},
+	{100, color.RGBA{0xa5, 0x00, 0x26, 0xff}},
+}
+
+var trackThumbs = struct {
+	sync.Mutex
+	png   map[string][]byte
+	order []string // oldest first
+}{png: map[string][]byte{}}
+
+// trackThumbURL is where the thumbnail of a track is served.
+func trackThumbURL(trackID string) string {
+	return serverURL("/api/track/" + url.PathEscape(trackID) + "/thumb.png")
+}
+
+// handleTrackThumb serves GET /api/track/{id}/thumb.png.
+func (h *RESTHandler) handleTrackThumb(w http.ResponseWriter, r *http.Request, trackID string) {
+	trackThumbs.Lock()
+	img, ok := trackThumbs.png[trackID]
+	trackThumbs.Unlock()
+
+	if !ok {
+		p, err := buildTrackProfile(r.Context(), trackID, defaultTrackProfilePoints)
+		if err != nil {
+			writeError(w, trackProfileErrorStatus(err), err.Error())
+			return
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, renderTrackThumb(p.Markers)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		img = buf.Bytes()
+
+		trackThumbs.Lock()
+		if _, dup := trackThumbs.png[trackID]; !dup {
+			if len(trackThumbs.order) >= trackThumbEntries {
+				delete(trackThumbs.png, trackThumbs.order[0])
+				trackThumbs.order = trackThumbs.order[1:]
+			}
+			trackThumbs.png[trackID] = img
+			trackThumbs.order = append(trackThumbs.order, trackID)
+		}
+		trackThumbs.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(img)
+}
+
+// renderTrackThumb draws markers, in time order, as a route fitted into
+// the thumbnail with the aspect ratio of the ground kept.
+func renderTrackThumb(markers []profileMarker) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, trackThumbWidth, trackThumbHeight))
+	bg := color.RGBA{0xf4, 0xf6, 0xf8, 0xff}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = bg.R, bg.G, bg.B, bg.A
+	}
+
+	route := make([]profileMarker, 0, len(markers))
+	for _, m := range markers {
+		if n := len(route); n > 0 {
+			prev := route[n-1]
+			d := haversineMeters(prev.lat, prev.lon, m.lat, m.lon)
+			if dt := m.CapturedAt.Sub(prev.CapturedAt).Seconds(); dt > 0 && d/dt > trackProfileMaxSpeed {
+				continue
+			}
+		}
+		route = append(route, m)
+	}
+
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, m := range route {
+		minLat, maxLat = min(minLat, m.lat), max(maxLat, m.lat)
+		minLon, maxLon = min(minLon, m.lon), max(maxLon, m.lon)
+	}
+	kx := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX, spanY := (maxLon-minLon)*kx, maxLat-minLat
+	scale := math.Min(
+		float64(trackThumbWidth-2*trackThumbPad)/math.Max(spanX, 1e-9),
+		float64(trackThumbHeight-2*trackThumbPad)/math.Max(spanY, 1e-9),
+	)
+	offX := (float64(trackThumbWidth) - spanX*scale) / 2
+	offY := (float64(trackThumbHeight) - spanY*scale) / 2
+	project := func(m profileMarker) (float64, float64) {
+		return offX + (m.lon-minLon)*kx*scale, offY + (maxLat-m.lat)*scale
+	}
+
+	// Hotter segments are drawn last so they stay visible where the route
+	// doubles back.
+	type segment struct {
+		x0, y0, x1, y1 float64
+		dose           float64
+	}
+	segs := make([]segment, 0, len(route))
+	for i := 1; i < len(route); i++ {
+		x0, y0 := project(route[i-1])
+		x1, y1 := project(route[i])
+		segs = append(segs, segment{x0, y0, x1, y1, math.Max(route[i-1].dose, route[i].dose)})
+	}
+	sort.SliceStable(segs, func(i, j int) bool { return segs[i].dose < segs[j].dose })
+	for _, s := range segs {
+		drawThumbLine(img, s.x0, s.y0, s.x1, s.y1, doseColor(s.dose))
+	}
+	if len(segs) == 0 && len(route) > 0 {
+		x, y := project(route[0])
+		drawThumbLine(img, x, y, x, y, doseColor(route[0].dose))
+	}
+	return img
+}
+
+// drawThumbLine draws a line 3 px wide.
+func drawThumbLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA) {
+	steps := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0)))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Round(x0 + (x1-x0)*t))
+		y := int(math.Round(y0 + (y1-y0)*t))
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+}
+
+// doseColor places a dose rate on trackThumbStops.
+func doseColor(usvh float64) color.RGBA {
+	stops := trackThumbStops
+	if usvh <= stops[0].usvh || math.IsNaN(usvh) {
+		return stops[0].c
+	}
+	for i := 1; i < len(stops); i++ {
+		if usvh > stops[i].usvh {
+			continue
+		}
+		lo, hi := stops[i-1], stops[i]
+		t := math.Log10(usvh/lo.usvh) / math.Log10(hi.usvh/lo.usvh)
+		mix := func(a, b uint8) uint8 { return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t)) }
+		return color.RGBA{mix(lo.c.R, hi.c.R), mix(lo.c.G, hi.c.G), mix(lo.c.B, hi.c.B), 0xff}
+	}
+	return stops[len(stops)-1].c
+}
+
+// trackProfileErrorStatus maps a buildTrackProfile error to an HTTP status.
+func trackProfileErrorStatus(err error) int {
+	if msg := err.Error(); strings.Contains(msg, "404") || strings.Contains(msg, "not found") || strings.Contains(msg, "usable measurements") {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,12 +10,18 @@ import (
 // handleTracks handles GET /api/tracks
 //
 // @Summary     Browse bGeigie measurement tracks
-// @Description Lists bGeigie Import tracks (bulk radiation measurement drives). Each track represents measurements from a single bGeigie session. Can filter by year, month, and detector/device name.
+// @Description Lists bGeigie Import tracks (bulk radiation measurement drives). Each track represents measurements from a single bGeigie session. Can filter by year, month, detector/device name, area (country or bounding box) and the highest dose rate recorded.
 // @Tags        historical
 // @Produce     json
 // @Param       year     query  integer false "Filter by year (2000–2100)"
 // @Param       month    query  integer false "Filter by month (1–12, requires year)"
 // @Param       detector query  string  false "Filter by detector/device name (e.g., 'bGeigieZen', 'bGeigie', 'Pointcast'). Partial match supported."
+// @Param       country  query  string  false "Only tracks passing through this country's bounding box"
+// @Param       min_lat  query  number  false "Only tracks passing through this bounding box (with max_lat, min_lon, max_lon)"
+// @Param       max_lat  query  number  false "Bounding box northern latitude"
+// @Param       min_lon  query  number  false "Bounding box western longitude"
+// @Param       max_lon  query  number  false "Bounding box eastern longitude"
+// @Param       min_max_dose_usvh query number false "Only tracks whose highest reading reached this dose rate (µSv/h)"
 // @Param       limit    query  integer false "Maximum number of results (1 to 50000)" default(50)
 // @Success     200 {object} map[string]interface{} "Track list with count and filter metadata"
 // @Failure     400 {object} map[string]string "Invalid parameters"
@@ -50,29 +57,60 @@ func (h *RESTHandler) handleTracks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	limit := 50
+	defLimit, maxLimit := toolLimits("list_tracks", 50, 50000)
+	limit := defLimit
 	if s := q.Get("limit"); s != "" {
 		var err error
 		limit, err = strconv.Atoi(s)
-		if err != nil || limit < 1 || limit > 50000 {
-			writeError(w, http.StatusBadRequest, "limit must be between 1 and 50000")
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 			return
 		}
 	}
 
 	detector := q.Get("detector")
 
+	minMaxDose := 0.0
+	if s := q.Get("min_max_dose_usvh"); s != "" {
+		var err error
+		minMaxDose, err = strconv.ParseFloat(s, 64)
+		if err != nil || minMaxDose < 0 {
+			writeError(w, http.StatusBadRequest, "min_max_dose_usvh must be a non-negative number")
+			return
+		}
+	}
+
+	var badCoord error
+	area, err := parseTrackArea(q.Get("country"), func(key string) (float64, bool) {
+		s := q.Get(key)
+		if s == "" {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			badCoord = fmt.Errorf("%s must be a number", key)
+		}
+		return v, true
+	})
+	if badCoord != nil {
+		err = badCoord
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// DB is always preferred — calling listTracksAPI would call simplemap.safecast.org/api/tracks
 	// which is this server itself, causing infinite recursion.
 	if dbAvailable() {
-		result, err := listTracksDB(r.Context(), year, month, detector, "", limit)
+		result, err := listTracksDB(r.Context(), year, month, detector, "", area, minMaxDose, limit)
 		serveMCPResult(w, result, err)
 		return
 	}
 
-	// No DB: detector filter impossible without DB
-	if detector != "" {
-		writeError(w, http.StatusServiceUnavailable, "Detector filtering requires database access")
+	// No DB: detector, area and dose filters impossible without DB
+	if detector != "" || area != nil || minMaxDose > 0 {
+		writeError(w, http.StatusServiceUnavailable, "Detector, area and dose filtering require database access")
 		return
 	}
 
@@ -91,6 +129,7 @@ func (h *RESTHandler) handleTracks(w http.ResponseWriter, r *http.Request) {
 // @Param       from  query   integer false "Start marker ID for filtering"
 // @Param       to    query   integer false "End marker ID for filtering"
 // @Param       limit query   integer false "Maximum number of results (1 to 10000)" default(200)
+// @Param       output_format query string false "json, or geojson for a FeatureCollection" Enums(json, geojson) default(json)
 // @Success     200 {object} map[string]interface{} "Measurements for the track"
 // @Failure     400 {object} map[string]string "Invalid parameters"
 // @Router      /track/{id} [get]
@@ -100,12 +139,25 @@ func (h *RESTHandler) handleTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract track ID from path: /api/track/{id}
+	// Extract track ID from path: /api/track/{id}, /api/track/{id}/stream,
+	// /api/track/{id}/profile.svg or /api/track/{id}/thumb.png
 	trackID := strings.TrimPrefix(r.URL.Path, "/api/track/")
 	if trackID == "" {
 		writeError(w, http.StatusBadRequest, "track id is required in path: /api/track/{id}")
 		return
 	}
+	if id, ok := strings.CutSuffix(trackID, "/stream"); ok && id != "" {
+		h.handleTrackStream(w, r, id)
+		return
+	}
+	if id, ok := strings.CutSuffix(trackID, "/profile.svg"); ok && id != "" {
+		h.handleTrackProfileChart(w, r, id)
+		return
+	}
+	if id, ok := strings.CutSuffix(trackID, "/thumb.png"); ok && id != "" {
+		h.handleTrackThumb(w, r, id)
+		return
+	}
 
 	q := r.URL.Query()
 
@@ -129,21 +181,50 @@ func (h *RESTHandler) handleTrack(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	limit := 200
+	defLimit, maxLimit := toolLimits("get_track", 200, 10000)
+	limit := defLimit
 	if s := q.Get("limit"); s != "" {
 		var err error
 		limit, err = strconv.Atoi(s)
-		if err != nil || limit < 1 || limit > 10000 {
-			writeError(w, http.StatusBadRequest, "limit must be between 1 and 10000")
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
 			return
 		}
 	}
 
+	geojson, ok := restOutputFormat(w, r)
+	if !ok {
+		return
+	}
+
 	if dbAvailable() {
-		result, err := getTrackDB(r.Context(), trackID, fromID, toID, limit)
-		serveMCPResult(w, result, err)
+		ctx := r.Context()
+		if limit > restStreamThreshold {
+			var uploader map[string]any
+			query, args := getTrackSQL(trackID, fromID, toID, limit, snapshotBound(ctx))
+			streamList(w, r, listStream[markerRow]{
+				head: getTrackHead(trackID, fromID, toID, getTrackTotal(ctx, trackID)),
+				key:  "measurements",
+				convert: func(row *markerRow) map[string]any {
+					if uploader == nil {
+						uploader = row.uploader()
+					}
+					return trackMeasurement(ctx, row)
+				},
+				tail: func() map[string]any {
+					if uploader == nil {
+						return nil
+					}
+					return map[string]any{"uploader": uploader}
+				},
+				geojson: geojson,
+			}, query, args...)
+			return
+		}
+		result, err := getTrackDB(ctx, trackID, fromID, toID, limit)
+		serveMCPResultFormat(w, result, err, geojson)
 	} else {
 		result, err := getTrackAPI(r.Context(), trackID, fromID, toID, limit)
-		serveMCPResult(w, result, err)
+		serveMCPResultFormat(w, result, err, geojson)
 	}
 }
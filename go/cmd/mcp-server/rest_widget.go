@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Embeddable widget ──────────────────────────────────────────────────────
+//
+// /widget/{device_id} serves a self-contained HTML card (latest reading,
+// 24 h sparkline, age) meant to be iframed on safecast.org and volunteer
+// blogs. Data comes from the sensor_current and sensor_history handlers, so
+// source routing applies as it does for MCP clients. Everything is inline:
+// the sparkline is SVG rendered here and the only script keeps the age
+// current and reloads the card every few minutes.
+
+const (
+	widgetHistoryWindow = 24 * time.Hour
+	widgetHistoryLimit  = 1000
+	widgetReloadSeconds = 300
+	widgetWidth         = 280
+	widgetSparkHeight   = 48
+)
+
+type widgetReading struct {
+	DeviceID   string      `json:"device_id"`
+	DeviceName string      `json:"device_name"`
+	Value      json.Number `json:"value"`
+	Unit       string      `json:"unit"`
+	CapturedAt time.Time   `json:"captured_at"`
+}
+
+type widgetPoint struct {
+	t time.Time
+	v float64
+}
+
+type widgetView struct {
+	DeviceID   string
+	Name       string
+	Value      string
+	Unit       string
+	CapturedAt time.Time
+	Stale      bool
+	Spark      string // SVG polyline points
+	SparkRange string
+	Dark       bool
+	Reload     int
+	Width      int
+	Height     int
+	MapURL     string
+}
+
+var widgetTemplate = template.Must(template.New("widget").Parse(`<!DOCTYPE html>
+<html lang="en"><head><meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}} — Safecast</title>
+<style>
+body{margin:0;font:14px/1.35 -apple-system,"Segoe UI",Roboto,sans-serif;background:transparent}
+.card{box-sizing:border-box;width:{{.Width}}px;padding:12px 14px;border-radius:8px;border:1px solid #d8dde3;background:#fff;color:#1d2733}
+.dark .card{background:#1d2733;color:#e8edf2;border-color:#3a4756}
+.name{font-weight:600;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+.value{font-size:28px;font-weight:700;margin:4px 0 0}
+.unit{font-size:14px;font-weight:400;opacity:.75}
+.age{font-size:12px;opacity:.75}
+.stale{color:#b54708}
+svg{display:block;margin:8px 0 2px}
+polyline{fill:none;stroke:#e8590c;stroke-width:1.5}
+.foot{display:flex;justify-content:space-between;font-size:11px;opacity:.7}
+a{color:inherit}
+</style></head>
+<body{{if .Dark}} class="dark"{{end}}><div class="card">
+<div class="name" title="{{.DeviceID}}">{{.Name}}</div>
+{{if .Value}}<div class="value">{{.Value}} <span class="unit">{{.Unit}}</span></div>
+<div class="age{{if .Stale}} stale{{end}}" data-ts="{{.CapturedAt.Unix}}">{{.CapturedAt.Format "2006-01-02 15:04 UTC"}}</div>
+{{else}}<div class="age stale">No recent reading</div>{{end}}
+{{if .Spark}}<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img" aria-label="Readings over the last 24 hours"><polyline points="{{.Spark}}"/></svg>
+<div class="foot"><span>24 h: {{.SparkRange}}</span></div>{{end}}
+<div class="foot"><a href="{{.MapURL}}" target="_blank" rel="noopener">Safecast</a><span>CC0</span></div>
+</div>
+<script>
+(function(){
+  var el=document.querySelector('[data-ts]');
+  function age(){
+    if(!el)return;
+    var s=Math.max(0,Math.floor(Date.now()/1000)-Number(el.dataset.ts));
+    var t=s<90?s+' s':s<5400?Math.round(s/60)+' min':s<172800?Math.round(s/3600)+' h':Math.round(s/86400)+' days';
+    el.textContent=t+' ago';
+  }
+  age();setInterval(age,30000);
+  setTimeout(function(){location.reload()},{{.Reload}}*1000);
+})();
+</script>
+</body></html>
+`))
+
+// handleWidget serves GET /widget/{device_id}. ?theme=dark switches colours.
+func (h *RESTHandler) handleWidget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	deviceID := strings.TrimPrefix(r.URL.Path, "/widget/")
+	if deviceID == "" || strings.Contains(deviceID, "/") {
+		writeError(w, http.StatusBadRequest, "device id is required in path: /widget/{device_id}")
+		return
+	}
+
+	reading, err := widgetCurrent(r.Context(), deviceID)
+	if err != nil {
+		status := http.StatusBadGateway
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	view := widgetView{
+		DeviceID: deviceID,
+		Name:     deviceID,
+		Dark:     r.URL.Query().Get("theme") == "dark",
+		Reload:   widgetReloadSeconds,
+		Width:    widgetWidth,
+		Height:   widgetSparkHeight,
+		MapURL:   mapBaseURL() + "/",
+	}
+	if reading != nil {
+		if reading.DeviceName != "" {
+			view.Name = reading.DeviceName
+		}
+		view.Value = reading.Value.String()
+		view.Unit = reading.Unit
+		view.CapturedAt = reading.CapturedAt.UTC()
+		view.Stale = time.Since(reading.CapturedAt) > 24*time.Hour
+	}
+	view.Spark, view.SparkRange = sparkline(widgetHistory(r.Context(), deviceID), widgetWidth, widgetSparkHeight)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Meant to be framed anywhere; nothing else may load.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; script-src 'unsafe-inline'; frame-ancestors *")
+	if err := widgetTemplate.Execute(w, view); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// widgetCurrent returns the latest reading, or nil when the device has none.
+func widgetCurrent(ctx context.Context, deviceID string) (*widgetReading, error) {
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "sensor_current"
+	req.Params.Arguments = map[string]any{"device_id": deviceID, "limit": 1}
+	res, err := handleSensorCurrent(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Readings []widgetReading `json:"readings"`
+	}
+	if err := decodeToolResult(res, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Readings) == 0 {
+		return nil, nil
+	}
+	return &out.Readings[0], nil
+}
+
+// widgetHistory returns the last 24 h of readings, oldest first. The
+// sparkline is decoration, so failures just leave it out.
+func widgetHistory(ctx context.Context, deviceID string) []widgetPoint {
+	since := time.Now().Add(-widgetHistoryWindow)
+	var points []widgetPoint
+
+	if dbAvailable() {
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "sensor_history"
+		req.Params.Arguments = map[string]any{
+			"device_id":  deviceID,
+			"start_date": since.UTC().Format("2006-01-02"),
+			"limit":      widgetHistoryLimit,
+		}
+		res, err := handleSensorHistory(ctx, req)
+		var out struct {
+			Measurements []widgetReading `json:"measurements"`
+		}
+		if err != nil || decodeToolResult(res, &out) != nil {
+			return nil
+		}
+		for _, m := range out.Measurements {
+			if v, err := m.Value.Float64(); err == nil && m.CapturedAt.After(since) {
+				points = append(points, widgetPoint{m.CapturedAt, v})
+			}
+		}
+	} else {
+		resp, err := client.GetRealtimeHistory(ctx, deviceID)
+		if err != nil {
+			return nil
+		}
+		series, _ := resp["series"].(map[string]any)
+		doseRate, _ := series["doseRate"].([]any)
+		for _, raw := range doseRate {
+			pt, _ := raw.(map[string]any)
+			ts, ok1 := toFloat(pt["time"])
+			v, ok2 := toFloat(pt["value"])
+			t := time.Unix(int64(ts), 0)
+			if ok1 && ok2 && t.After(since) && t.Before(time.Now()) {
+				points = append(points, widgetPoint{t, v})
+			}
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].t.Before(points[j].t) })
+	return points
+}
+
+// sparkline scales points into an SVG polyline and describes the range.
+func sparkline(points []widgetPoint, width, height int) (string, string) {
+	if len(points) < 2 {
+		return "", ""
+	}
+	lo, hi := points[0].v, points[0].v
+	for _, p := range points {
+		lo, hi = min(lo, p.v), max(hi, p.v)
+	}
+	t0 := points[0].t
+	span := points[len(points)-1].t.Sub(t0).Seconds()
+	if span <= 0 {
+		return "", ""
+	}
+	const pad = 2.0
+	var sb strings.Builder
+	for i, p := range points {
+		x := p.t.Sub(t0).Seconds() / span * float64(width)
+		y := float64(height) / 2
+		if hi > lo {
+			y = pad + (hi-p.v)/(hi-lo)*(float64(height)-2*pad)
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%.1f,%.1f", x, y)
+	}
+	return sb.String(), fmt.Sprintf("%.3g – %.3g", lo, hi)
+}
+
+// decodeToolResult unmarshals a JSON tool result into v. Tool errors are
+// returned as errors.
+func decodeToolResult(res *mcp.CallToolResult, v any) error {
+	if res == nil || len(res.Content) == 0 {
+		return fmt.Errorf("empty result")
+	}
+	tc, ok := mcp.AsTextContent(res.Content[0])
+	if !ok {
+		return fmt.Errorf("unexpected result content")
+	}
+	if res.IsError {
+		return fmt.Errorf("%s", tc.Text)
+	}
+	return json.Unmarshal([]byte(tc.Text), v)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Saved regions and queries ──────────────────────────────────────────────
+//
+// Recurring analyses ("Fukushima coastal corridor", "weekly Tokyo sensors")
+// should not need coordinates restated in every conversation. save_region
+// stores a named bounding box or polygon and save_query a tool call with
+// its arguments; both live in DuckDB and are shared by every client.
+//
+// Tools with a bounding box accept region=<name>. resolveSavedRegion, a tool
+// middleware, expands it into min_lat/max_lat/min_lon/max_lon before the
+// handler runs, so handlers need no changes. Polygons are applied as their
+// bounding box, except by tools with spatial filters (spatial.go), which
+// apply the polygon itself; the result says which.
+
+// regionParamDescription is shared by every tool that accepts region.
+const regionParamDescription = "Name of a region saved with save_region (e.g. 'Fukushima coastal corridor'). Fills in min_lat, max_lat, min_lon and max_lon; explicit coordinates take precedence."
+
+const maxSavedNameLength = 100
+
+type savedRegion struct {
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind"` // "bbox" or "polygon"
+	MinLat      float64     `json:"min_lat"`
+	MaxLat      float64     `json:"max_lat"`
+	MinLon      float64     `json:"min_lon"`
+	MaxLon      float64     `json:"max_lon"`
+	Polygon     [][]float64 `json:"polygon,omitempty"` // GeoJSON order: [lon, lat]
+	Description string      `json:"description,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+type savedQuery struct {
+	Name        string         `json:"name"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments"`
+	Description string         `json:"description,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+var errSavedNotFound = errors.New("not found")
+
+func normalizeSavedName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	switch {
+	case name == "":
+		return "", fmt.Errorf("name is required")
+	case len(name) > maxSavedNameLength:
+		return "", fmt.Errorf("name must be at most %d characters", maxSavedNameLength)
+	}
+	return name, nil
+}
+
+// polygonBounds validates a closed or open ring of [lon, lat] pairs and
+// returns its bounding box.
+func polygonBounds(ring [][]float64) (minLat, maxLat, minLon, maxLon float64, err error) {
+	if len(ring) < 3 {
+		return 0, 0, 0, 0, fmt.Errorf("polygon needs at least 3 points")
+	}
+	minLat, maxLat, minLon, maxLon = math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for i, pt := range ring {
+		if len(pt) != 2 {
+			return 0, 0, 0, 0, fmt.Errorf("polygon point %d must be [lon, lat]", i)
+		}
+		lon, lat := pt[0], pt[1]
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return 0, 0, 0, 0, fmt.Errorf("polygon point %d is out of range (expected [lon, lat])", i)
+		}
+		minLat, maxLat = math.Min(minLat, lat), math.Max(maxLat, lat)
+		minLon, maxLon = math.Min(minLon, lon), math.Max(maxLon, lon)
+	}
+	return minLat, maxLat, minLon, maxLon, nil
+}
+
+func requireDuckDB() error {
+	if duckDB == nil {
+		return fmt.Errorf("saved regions and queries need DuckDB, which is not initialized")
+	}
+	return nil
+}
+
+func saveRegion(r savedRegion, replace bool) error {
+	if err := requireDuckDB(); err != nil {
+		return err
+	}
+	var polygon any
+	if len(r.Polygon) > 0 {
+		b, _ := json.Marshal(r.Polygon)
+		polygon = string(b)
+	}
+	if replace {
+		if _, err := duckExec(`DELETE FROM saved_regions WHERE lower(name) = lower(?)`, r.Name); err != nil {
+			return err
+		}
+	} else if _, err := getSavedRegion(r.Name); err == nil {
+		return fmt.Errorf("a region named %q already exists (pass replace=true to overwrite it)", r.Name)
+	}
+	_, err := duckExec(`
+		INSERT INTO saved_regions (name, kind, min_lat, max_lat, min_lon, max_lon, polygon, description)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Name, r.Kind, r.MinLat, r.MaxLat, r.MinLon, r.MaxLon, polygon, r.Description)
+	return err
+}
+
+func getSavedRegion(name string) (savedRegion, error) {
+	if err := requireDuckDB(); err != nil {
+		return savedRegion{}, err
+	}
+	var r savedRegion
+	var polygon, description sql.NullString
+	err := duckDB.QueryRow(`
+		SELECT name, kind, min_lat, max_lat, min_lon, max_lon, polygon, description, created_at
+		FROM saved_regions WHERE lower(name) = lower(?)`, name).
+		Scan(&r.Name, &r.Kind, &r.MinLat, &r.MaxLat, &r.MinLon, &r.MaxLon, &polygon, &description, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return savedRegion{}, errSavedNotFound
+	}
+	if err != nil {
+		return savedRegion{}, err
+	}
+	if polygon.Valid {
+		_ = json.Unmarshal([]byte(polygon.String), &r.Polygon)
+	}
+	r.Description = description.String
+	return r, nil
+}
+
+func listSavedRegions() ([]savedRegion, error) {
+	if err := requireDuckDB(); err != nil {
+		return nil, err
+	}
+	rows, err := duckDB.Query(`
+		SELECT name, kind, min_lat, max_lat, min_lon, max_lon, description, created_at
+		FROM saved_regions ORDER BY lower(name)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []savedRegion{}
+	for rows.Next() {
+		var r savedRegion
+		var description sql.NullString
+		if err := rows.Scan(&r.Name, &r.Kind, &r.MinLat, &r.MaxLat, &r.MinLon, &r.MaxLon, &description, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Description = description.String
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func saveQuery(q savedQuery, replace bool) error {
+	if err := requireDuckDB(); err != nil {
+		return err
+	}
+	args, err := json.Marshal(q.Arguments)
+	if err != nil {
+		return err
+	}
+	if replace {
+		if _, err := duckExec(`DELETE FROM saved_queries WHERE lower(name) = lower(?)`, q.Name); err != nil {
+			return err
+		}
+	} else if _, err := getSavedQuery(q.Name); err == nil {
+		return fmt.Errorf("a query named %q already exists (pass replace=true to overwrite it)", q.Name)
+	}
+	_, err = duckExec(`
+		INSERT INTO saved_queries (name, tool, arguments, description)
+		VALUES (?, ?, ?, ?)`, q.Name, q.Tool, string(args), q.Description)
+	return err
+}
+
+func getSavedQuery(name string) (savedQuery, error) {
+	if err := requireDuckDB(); err != nil {
+		return savedQuery{}, err
+	}
+	var q savedQuery
+	var args string
+	var description sql.NullString
+	err := duckDB.QueryRow(`
+		SELECT name, tool, CAST(arguments AS VARCHAR), description, created_at
+		FROM saved_queries WHERE lower(name) = lower(?)`, name).
+		Scan(&q.Name, &q.Tool, &args, &description, &q.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return savedQuery{}, errSavedNotFound
+	}
+	if err != nil {
+		return savedQuery{}, err
+	}
+	if err := json.Unmarshal([]byte(args), &q.Arguments); err != nil {
+		return savedQuery{}, fmt.Errorf("stored arguments are not valid JSON: %w", err)
+	}
+	q.Description = description.String
+	return q, nil
+}
+
+func listSavedQueries() ([]savedQuery, error) {
+	if err := requireDuckDB(); err != nil {
+		return nil, err
+	}
+	rows, err := duckDB.Query(`
+		SELECT name, tool, CAST(arguments AS VARCHAR), description, created_at
+		FROM saved_queries ORDER BY lower(name)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []savedQuery{}
+	for rows.Next() {
+		var q savedQuery
+		var args string
+		var description sql.NullString
+		if err := rows.Scan(&q.Name, &q.Tool, &args, &description, &q.CreatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(args), &q.Arguments)
+		q.Description = description.String
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
+
+// applyRegion fills the bounding box arguments from a saved region, plus
+// polygon for polygon regions (used by the spatial-capable tools). It
+// returns the region (nil when args has no region) and the new arguments.
+func applyRegion(args map[string]any) (*savedRegion, map[string]any, error) {
+	name, _ := args["region"].(string)
+	if strings.TrimSpace(name) == "" {
+		return nil, args, nil
+	}
+	r, err := getSavedRegion(name)
+	if errors.Is(err, errSavedNotFound) {
+		return nil, nil, fmt.Errorf("no saved region named %q; call list_saved to see the available regions", name)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load region %q: %v", name, err)
+	}
+
+	out := make(map[string]any, len(args)+4)
+	for k, v := range args {
+		out[k] = v
+	}
+	delete(out, "region")
+	for k, v := range map[string]float64{"min_lat": r.MinLat, "max_lat": r.MaxLat, "min_lon": r.MinLon, "max_lon": r.MaxLon} {
+		if _, set := out[k]; !set {
+			out[k] = v
+		}
+	}
+	if _, set := out["polygon"]; !set && r.Kind == "polygon" {
+		out["polygon"] = r.Polygon
+	}
+	return &r, out, nil
+}
+
+// resolveSavedRegion is a tool middleware that expands region=<name>.
+func resolveSavedRegion(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, ok := req.Params.Arguments.(map[string]any)
+		if !ok {
+			return next(ctx, req)
+		}
+		region, resolved, err := applyRegion(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if region == nil {
+			return next(ctx, req)
+		}
+		req.Params.Arguments = resolved
+
+		res, err := next(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			applied := map[string]any{
+				"name": region.Name,
+				"kind": region.Kind,
+				"bbox": []float64{region.MinLat, region.MaxLat, region.MinLon, region.MaxLon},
+			}
+			if _, exact := m["spatial_filter"]; region.Kind == "polygon" && !exact {
+				applied["note"] = "Polygon region applied as its bounding box; results near the edges may fall outside the polygon."
+			}
+			m["region"] = applied
+		}), nil
+	}
+}
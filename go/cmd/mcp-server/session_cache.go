@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Session result cache ───────────────────────────────────────────────────
+//
+// Agents often ask for the same thing twice in one conversation: a
+// list_sensors for a box, then the same list again before sensor_current.
+// Within an MCP session, results of cacheable tools (toolCacheTTL) are kept
+// for the tool's lifetime, capped at MCP_SESSION_CACHE_TTL, and an identical
+// call (same tool, same arguments) is answered from the cache with
+// session_cache.hit in the result. clear_session_cache drops entries
+// explicitly; an annotation clears every session, since annotations show
+// up in read results. Sessions never see each other's entries.
+//
+//   MCP_SESSION_CACHE_TTL      longest an entry is kept (default 10m, 0 = off)
+//   MCP_SESSION_CACHE_ENTRIES  entries per session, oldest evicted (default 64)
+
+const (
+	defaultSessionCacheTTL     = 10 * time.Minute
+	defaultSessionCacheEntries = 64
+)
+
+type sessionCacheEntry struct {
+	tool    string
+	res     *mcp.CallToolResult
+	stored  time.Time
+	expires time.Time
+}
+
+type sessionResultCache struct {
+	maxTTL     time.Duration // 0 = disabled
+	maxEntries int
+
+	mu       sync.Mutex
+	sessions map[string]map[string]*sessionCacheEntry // session → key → entry
+	hits     int64
+	misses   int64
+}
+
+var sessionCache = &sessionResultCache{
+	maxTTL:     durationEnv("MCP_SESSION_CACHE_TTL", defaultSessionCacheTTL),
+	maxEntries: max(intEnv("MCP_SESSION_CACHE_ENTRIES", defaultSessionCacheEntries), 1),
+	sessions:   map[string]map[string]*sessionCacheEntry{},
+}
+
+// sessionIDOf names the session of a call; stdio has a single unnamed one.
+func sessionIDOf(ctx context.Context) string {
+	if s := server.ClientSessionFromContext(ctx); s != nil && s.SessionID() != "" {
+		return s.SessionID()
+	}
+	return "local"
+}
+
+// sessionCacheKey identifies a call. encoding/json sorts map keys, so the
+// same arguments in any order give the same key.
+func sessionCacheKey(tool string, args any) (string, bool) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return tool + "\x00" + string(data), true
+}
+
+func (c *sessionResultCache) ttl(tool string) time.Duration {
+	return min(toolCacheTTL[tool], c.maxTTL)
+}
+
+func (c *sessionResultCache) get(sid, key string, now time.Time) (*sessionCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.sessions[sid][key]
+	if ok && now.After(e.expires) {
+		delete(c.sessions[sid], key)
+		ok = false
+	}
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return e, ok
+}
+
+func (c *sessionResultCache) put(sid, key, tool string, res *mcp.CallToolResult, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(now)
+	entries := c.sessions[sid]
+	if entries == nil {
+		entries = map[string]*sessionCacheEntry{}
+		c.sessions[sid] = entries
+	}
+	if _, ok := entries[key]; !ok && len(entries) >= c.maxEntries {
+		var oldest string
+		for k, e := range entries {
+			if oldest == "" || e.stored.Before(entries[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(entries, oldest)
+	}
+	entries[key] = &sessionCacheEntry{tool: tool, res: res, stored: now, expires: now.Add(c.ttl(tool))}
+}
+
+// prune drops expired entries and empty sessions. Caller holds mu.
+func (c *sessionResultCache) prune(now time.Time) {
+	for sid, entries := range c.sessions {
+		for k, e := range entries {
+			if now.After(e.expires) {
+				delete(entries, k)
+			}
+		}
+		if len(entries) == 0 {
+			delete(c.sessions, sid)
+		}
+	}
+}
+
+// clear drops the entries of one session, or of every session when sid is
+// empty, limited to tool when it is set. It returns how many were dropped.
+func (c *sessionResultCache) clear(sid, tool string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for s, entries := range c.sessions {
+		if sid != "" && s != sid {
+			continue
+		}
+		for k, e := range entries {
+			if tool == "" || e.tool == tool {
+				delete(entries, k)
+				n++
+			}
+		}
+		if len(entries) == 0 {
+			delete(c.sessions, s)
+		}
+	}
+	return n
+}
+
+// count returns the live entries of a session.
+func (c *sessionResultCache) count(sid string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(time.Now())
+	return len(c.sessions[sid])
+}
+
+func (c *sessionResultCache) stats() (hits, misses int64, entries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.sessions {
+		entries += len(e)
+	}
+	return c.hits, c.misses, entries
+}
+
+// cacheSessionResults is a tool middleware serving repeated calls from the
+// session cache. It sits inside enforceResponseBudget, so a cached result is
+// still paged per call and continue_result cursors are never reused.
+func cacheSessionResults(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := req.Params.Name
+		if sessionCache.ttl(tool) <= 0 || isSelfTest(ctx) || isReplay(ctx) {
+			res, err := next(ctx, req)
+			if tool == "annotate_measurement" && err == nil && res != nil && !res.IsError {
+				sessionCache.clear("", "")
+			}
+			return res, err
+		}
+		key, ok := sessionCacheKey(tool, req.Params.Arguments)
+		if !ok {
+			return next(ctx, req)
+		}
+		sid := sessionIDOf(ctx)
+		now := time.Now()
+		if e, hit := sessionCache.get(sid, key, now); hit {
+			return sessionCacheHit(e, now), nil
+		}
+		res, err := next(ctx, req)
+		if err == nil && res != nil && !res.IsError {
+			sessionCache.put(sid, key, tool, res, now)
+		}
+		return res, err
+	}
+}
+
+// sessionCacheHit returns a cached result marked as such, in the JSON for
+// the model and in _meta for clients. The cached result is left untouched.
+func sessionCacheHit(e *sessionCacheEntry, now time.Time) *mcp.CallToolResult {
+	info := map[string]any{
+		"hit":         true,
+		"cached_at":   e.stored.UTC().Format(time.RFC3339),
+		"age_seconds": int(now.Sub(e.stored).Seconds()),
+		"note":        "Same call earlier in this session; call clear_session_cache to fetch fresh data.",
+	}
+	hit := map[string]any{"status": "hit", "age_seconds": info["age_seconds"]}
+	res := amendJSONResult(e.res, func(m map[string]any) {
+		m["session_cache"] = info
+		if p, ok := m["provenance"].(map[string]any); ok {
+			p["cache"] = hit
+		}
+	})
+	if res == e.res {
+		copied := *e.res
+		res = &copied
+	}
+	fields := map[string]any{}
+	if e.res.Meta != nil {
+		for k, v := range e.res.Meta.AdditionalFields {
+			fields[k] = v
+		}
+	}
+	fields["safecast/session_cache"] = info
+	if p, ok := fields["safecast/provenance"].(map[string]any); ok {
+		// The cached result's own block is shared; amend a copy.
+		p = maps.Clone(p)
+		p["cache"] = hit
+		fields["safecast/provenance"] = p
+	}
+	res.Meta = mcp.NewMetaFromMap(fields)
+	return res
+}
+
+var clearSessionCacheToolDef = mcp.NewTool("clear_session_cache",
+	mcp.WithDescription("Drop results cached for this session so the next identical tool call fetches fresh data. Repeated calls with the same arguments are otherwise answered from the cache (marked session_cache.hit) for up to the tool's cache lifetime."),
+	mcp.WithString("tool",
+		mcp.Description("Only drop cached results of this tool (default: all tools)"),
+	),
+	mcp.WithReadOnlyHintAnnotation(false),
+	mcp.WithIdempotentHintAnnotation(true),
+)
+
+func handleClearSessionCache(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tool := req.GetString("tool", "")
+	sid := sessionIDOf(ctx)
+	cleared := sessionCache.clear(sid, tool)
+
+	result := map[string]any{
+		"cleared":   cleared,
+		"remaining": sessionCache.count(sid),
+		"_ai_hint":  "Cached results for this session were dropped. Re-run the tool call to get fresh data.",
+	}
+	if tool != "" {
+		result["tool"] = tool
+	}
+	return jsonResult(result)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ── Shortlinks ─────────────────────────────────────────────────────────────
+//
+// Map URLs with coordinates are long, and chat UIs wrap or truncate them.
+// When DuckDB is available and MCP_BASE_URL is set (a short link is only
+// useful as an absolute URL), results with map links also get a short_url,
+// /s/{code}, stored in the DuckDB shortlinks table. The code is a hash of
+// the target, so the same view always gets the same code and repeated
+// results add no rows. GET /s/{code} redirects and records the click in
+// shortlink_clicks for the analytics dashboard. Both tables are insert-only:
+// DuckDB turns an UPDATE of an indexed row into a delete and insert that
+// can trip the primary key, so click counts are never kept in place.
+
+const (
+	shortlinkCodeLen      = 8   // base62, about 47 bits
+	shortlinkMaxPerResult = 500 // links shortened per result, in walk order
+)
+
+// migrateShortlinks creates the shortlink tables (schema version 10).
+const migrateShortlinks = `
+	CREATE TABLE IF NOT EXISTS shortlinks (
+		code TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS shortlink_clicks (
+		code TEXT,
+		clicked_at TIMESTAMPTZ DEFAULT now(),
+		referer TEXT
+	);
+
+	DELETE FROM schema_version;
+
+	INSERT INTO schema_version(version) VALUES (10);
+`
+
+var shortlinkCounters struct {
+	created atomic.Int64
+	clicks  atomic.Int64
+}
+
+func shortlinksEnabled() bool {
+	return duckDB != nil && os.Getenv("MCP_BASE_URL") != ""
+}
+
+// shortlinkCode derives the code for a target URL.
+func shortlinkCode(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	code := new(big.Int).SetBytes(sum[:]).Text(62)
+	return code[:shortlinkCodeLen]
+}
+
+// shortenURLs stores the targets and returns their short URLs. Only links
+// into the map are accepted, so /s/ cannot redirect anywhere else.
+func shortenURLs(targets []string) map[string]string {
+	if !shortlinksEnabled() || len(targets) == 0 {
+		return nil
+	}
+	base := mapBaseURL() + "/"
+	out := make(map[string]string, len(targets))
+	var values []string
+	var args []any
+	for _, t := range targets {
+		if _, done := out[t]; done || !strings.HasPrefix(t, base) {
+			continue
+		}
+		code := shortlinkCode(t)
+		out[t] = serverURL("/s/" + code)
+		values = append(values, "(?, ?)")
+		args = append(args, code, t)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	res, err := duckExec("INSERT INTO shortlinks (code, url) VALUES "+strings.Join(values, ", ")+" ON CONFLICT DO NOTHING", args...)
+	if err != nil {
+		log.Printf("shortlinks: %v", err)
+		return nil
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		shortlinkCounters.created.Add(n)
+	}
+	return out
+}
+
+// addShortLinks gives every object in a result that has a map_url a
+// short_url for it (see addMapLinks).
+func addShortLinks(v any) {
+	if !shortlinksEnabled() {
+		return
+	}
+	var items []map[string]any
+	var targets []string
+	var walk func(v any)
+	walk = func(v any) {
+		if len(items) == shortlinkMaxPerResult {
+			return
+		}
+		switch t := v.(type) {
+		case map[string]any:
+			if u, ok := t["map_url"].(string); ok && u != "" {
+				items = append(items, t)
+				targets = append(targets, u)
+			}
+			for k, child := range t {
+				if k != "map_url" {
+					walk(child)
+				}
+			}
+		case []map[string]any:
+			for _, child := range t {
+				walk(child)
+			}
+		case []any:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	short := shortenURLs(targets)
+	for i, item := range items {
+		if s, ok := short[targets[i]]; ok {
+			item["short_url"] = s
+		}
+	}
+}
+
+// resolveShortlink returns the target of code and records the click.
+func resolveShortlink(code, referer string) (string, bool) {
+	if duckDB == nil {
+		return "", false
+	}
+	var target string
+	if err := duckDB.QueryRow(`SELECT url FROM shortlinks WHERE code = ?`, code).Scan(&target); err != nil {
+		return "", false
+	}
+	if _, err := duckExec(`INSERT INTO shortlink_clicks (code, referer) VALUES (?, ?)`, code, nilIfEmpty(referer)); err != nil {
+		log.Printf("shortlinks: recording click: %v", err)
+	}
+	shortlinkCounters.clicks.Add(1)
+	return target, true
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ── Per-tool SLOs ──────────────────────────────────────────────────────────
+//
+// Each tool has a latency objective (P95 over MCP_SLO_WINDOW) and an error
+// rate objective. The defaults follow the tool's expected latency class in
+// tool_cost.go; the config file overrides them per tool, with "*" applying
+// to every tool not listed:
+//
+//	"slos": {
+//	  "*":               {"error_rate": 0.02},
+//	  "query_radiation": {"p95_ms": 2000}
+//	}
+//
+// Once a minute every tool with at least MCP_SLO_MIN_CALLS calls in the
+// window is checked. A tool going over an objective counts one breach, is
+// logged and is sent to notification subscriptions following
+// sloAlertSchedule; recovering is logged and sent the same way. The state
+// is in /metrics and the service_health tool.
+
+const (
+	defaultSLOWindow    = 5 * time.Minute
+	defaultSLOMinCalls  = 20
+	defaultSLOErrorRate = 0.05
+	sloEvalInterval     = time.Minute
+	sloMaxSamples       = 2000 // per tool, within the window
+
+	sloAlertSchedule = "slo-alerts"
+)
+
+// defaultSLOLatency is the P95 objective per expected latency class.
+var defaultSLOLatency = map[string]time.Duration{
+	latencyFast:   time.Second,
+	latencyMedium: 5 * time.Second,
+	latencySlow:   30 * time.Second,
+}
+
+type sloConfig struct {
+	P95Ms     float64 `json:"p95_ms,omitempty"`
+	ErrorRate float64 `json:"error_rate,omitempty"`
+}
+
+// sloFor returns the objectives for tool.
+func sloFor(tool string) (p95 time.Duration, errorRate float64) {
+	exp, ok := expectedToolCosts[tool]
+	if !ok {
+		exp = toolCost{latencyMedium, sizeMedium}
+	}
+	p95, errorRate = defaultSLOLatency[exp.Latency], defaultSLOErrorRate
+	for _, key := range []string{"*", tool} {
+		c, ok := serverConfig.SLOs[key]
+		if !ok {
+			continue
+		}
+		if c.P95Ms > 0 {
+			p95 = time.Duration(c.P95Ms * float64(time.Millisecond))
+		}
+		if c.ErrorRate > 0 {
+			errorRate = c.ErrorRate
+		}
+	}
+	return p95, errorRate
+}
+
+type sloSample struct {
+	at     time.Time
+	d      time.Duration
+	failed bool
+}
+
+// sloState is one tool's result from the last check.
+type sloState struct {
+	latencyBreaches  int64
+	errorBreaches    int64
+	latencyBreaching bool
+	errorBreaching   bool
+	since            time.Time // when the current breach started
+}
+
+type sloTracker struct {
+	window   time.Duration
+	minCalls int
+
+	mu      sync.Mutex
+	samples map[string][]sloSample
+	state   map[string]*sloState
+}
+
+var slos = newSLOTrackerFromEnv()
+
+func newSLOTrackerFromEnv() *sloTracker {
+	t := &sloTracker{
+		window:   durationEnv("MCP_SLO_WINDOW", defaultSLOWindow),
+		minCalls: intEnv("MCP_SLO_MIN_CALLS", defaultSLOMinCalls),
+		samples:  map[string][]sloSample{},
+		state:    map[string]*sloState{},
+	}
+	go t.run()
+	return t
+}
+
+// observe records one call. Called from instrument().
+func (t *sloTracker) observe(name string, d time.Duration, failed bool) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.trim(name, now)
+	if len(s) >= sloMaxSamples {
+		s = s[1:]
+	}
+	t.samples[name] = append(s, sloSample{now, d, failed})
+}
+
+// trim drops samples older than the window. Caller holds mu.
+func (t *sloTracker) trim(name string, now time.Time) []sloSample {
+	s := t.samples[name]
+	i := 0
+	for i < len(s) && now.Sub(s[i].at) > t.window {
+		i++
+	}
+	if i > 0 {
+		s = append(s[:0:0], s[i:]...)
+		t.samples[name] = s
+	}
+	return s
+}
+
+// sloStatus is one tool's current standing.
+type sloStatus struct {
+	Tool             string  `json:"tool"`
+	Calls            int     `json:"calls"`
+	P95Ms            int64   `json:"p95_ms"`
+	ErrorRate        float64 `json:"error_rate"`
+	TargetP95Ms      int64   `json:"slo_p95_ms"`
+	TargetErrorRate  float64 `json:"slo_error_rate"`
+	Status           string  `json:"status"` // ok, breaching, insufficient_data
+	LatencyBreaching bool    `json:"latency_breaching,omitempty"`
+	ErrorBreaching   bool    `json:"error_breaching,omitempty"`
+	BreachingSince   string  `json:"breaching_since,omitempty"`
+	LatencyBreaches  int64   `json:"latency_breaches"`
+	ErrorBreaches    int64   `json:"error_breaches"`
+}
+
+// snapshot measures every tool seen in the window, plus tools that have
+// breached before, sorted by name. Breach flags are from the last check.
+func (t *sloTracker) snapshot() []sloStatus {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := map[string]bool{}
+	for name := range t.samples {
+		names[name] = true
+	}
+	for name := range t.state {
+		names[name] = true
+	}
+	out := make([]sloStatus, 0, len(names))
+	for name := range names {
+		s := t.trim(name, now)
+		target, targetErr := sloFor(name)
+		st := sloStatus{
+			Tool:            name,
+			Calls:           len(s),
+			TargetP95Ms:     target.Milliseconds(),
+			TargetErrorRate: targetErr,
+			Status:          "ok",
+		}
+		if len(s) > 0 {
+			p95, errRate := windowStats(s)
+			st.P95Ms, st.ErrorRate = p95.Milliseconds(), math.Round(errRate*1e4)/1e4
+		}
+		if len(s) < t.minCalls {
+			st.Status = "insufficient_data"
+		}
+		if state := t.state[name]; state != nil {
+			st.LatencyBreaching, st.ErrorBreaching = state.latencyBreaching, state.errorBreaching
+			st.LatencyBreaches, st.ErrorBreaches = state.latencyBreaches, state.errorBreaches
+			if state.latencyBreaching || state.errorBreaching {
+				st.Status = "breaching"
+				st.BreachingSince = state.since.UTC().Format(time.RFC3339)
+			}
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tool < out[j].Tool })
+	return out
+}
+
+// windowStats returns the P95 latency and error rate of samples.
+func windowStats(s []sloSample) (time.Duration, float64) {
+	durations := make([]time.Duration, len(s))
+	failed := 0
+	for i, x := range s {
+		durations[i] = x.d
+		if x.failed {
+			failed++
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	idx := (len(durations)*95+99)/100 - 1
+	return durations[max(idx, 0)], float64(failed) / float64(len(s))
+}
+
+func (t *sloTracker) run() {
+	for range time.Tick(sloEvalInterval) {
+		t.evaluate()
+	}
+}
+
+// evaluate checks every tool against its objectives and alerts on changes.
+func (t *sloTracker) evaluate() {
+	var alerts []notification
+	for _, st := range t.snapshot() {
+		// Too few calls to judge; a tool with none at all has recovered.
+		if st.Calls > 0 && st.Calls < t.minCalls {
+			continue
+		}
+		latency := st.P95Ms > st.TargetP95Ms
+		errs := st.ErrorRate > st.TargetErrorRate
+
+		t.mu.Lock()
+		state := t.state[st.Tool]
+		if state == nil {
+			if !latency && !errs {
+				t.mu.Unlock()
+				continue
+			}
+			state = &sloState{}
+			t.state[st.Tool] = state
+		}
+		was := state.latencyBreaching || state.errorBreaching
+		if latency && !state.latencyBreaching {
+			state.latencyBreaches++
+			alerts = append(alerts, sloAlert(st, "latency", true, t.window))
+		} else if !latency && state.latencyBreaching {
+			alerts = append(alerts, sloAlert(st, "latency", false, t.window))
+		}
+		if errs && !state.errorBreaching {
+			state.errorBreaches++
+			alerts = append(alerts, sloAlert(st, "error rate", true, t.window))
+		} else if !errs && state.errorBreaching {
+			alerts = append(alerts, sloAlert(st, "error rate", false, t.window))
+		}
+		state.latencyBreaching, state.errorBreaching = latency, errs
+		if (latency || errs) && !was {
+			state.since = time.Now()
+		}
+		t.mu.Unlock()
+	}
+
+	for _, n := range alerts {
+		log.Printf("%s: %s", n.Subject, n.Markdown)
+		if duckDB != nil {
+			go notifySubscribers(context.Background(), sloAlertSchedule, n)
+		}
+	}
+}
+
+func sloAlert(st sloStatus, kind string, breached bool, window time.Duration) notification {
+	var detail string
+	if kind == "latency" {
+		detail = fmt.Sprintf("P95 latency %d ms against an objective of %d ms", st.P95Ms, st.TargetP95Ms)
+	} else {
+		detail = fmt.Sprintf("Error rate %.1f%% against an objective of %.1f%%", st.ErrorRate*100, st.TargetErrorRate*100)
+	}
+	verb := "breached"
+	if !breached {
+		verb = "recovered"
+	}
+	return notification{
+		Event:    "slo:" + st.Tool,
+		Subject:  fmt.Sprintf("SLO %s: %s %s", verb, st.Tool, kind),
+		Markdown: fmt.Sprintf("%s over the last %s (%d calls).", detail, window, st.Calls),
+	}
+}
+
+// writeMetrics adds the SLO series to /metrics.
+func (t *sloTracker) writeMetrics(w io.Writer) {
+	statuses := t.snapshot()
+	gauge := func(name, help string, value func(sloStatus) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, st := range statuses {
+			fmt.Fprintf(w, "%s{tool=%q} %g\n", name, st.Tool, value(st))
+		}
+	}
+	gauge("mcp_tool_slo_latency_p95_seconds", "P95 latency over the SLO window.",
+		func(st sloStatus) float64 { return float64(st.P95Ms) / 1000 })
+	gauge("mcp_tool_slo_latency_objective_seconds", "P95 latency objective.",
+		func(st sloStatus) float64 { return float64(st.TargetP95Ms) / 1000 })
+	gauge("mcp_tool_slo_error_ratio", "Error rate over the SLO window.",
+		func(st sloStatus) float64 { return st.ErrorRate })
+	gauge("mcp_tool_slo_error_ratio_objective", "Error rate objective.",
+		func(st sloStatus) float64 { return st.TargetErrorRate })
+
+	fmt.Fprintln(w, "# HELP mcp_tool_slo_breaching 1 while the objective is breached at the last check.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_slo_breaching gauge")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "mcp_tool_slo_breaching{tool=%q,slo=\"latency\"} %d\n", st.Tool, boolInt(st.LatencyBreaching))
+		fmt.Fprintf(w, "mcp_tool_slo_breaching{tool=%q,slo=\"errors\"} %d\n", st.Tool, boolInt(st.ErrorBreaching))
+	}
+	fmt.Fprintln(w, "# HELP mcp_tool_slo_breaches_total Times the objective went into breach.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_slo_breaches_total counter")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "mcp_tool_slo_breaches_total{tool=%q,slo=\"latency\"} %d\n", st.Tool, st.LatencyBreaches)
+		fmt.Fprintf(w, "mcp_tool_slo_breaches_total{tool=%q,slo=\"errors\"} %d\n", st.Tool, st.ErrorBreaches)
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
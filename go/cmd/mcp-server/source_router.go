@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Source routing ─────────────────────────────────────────────────────────
+//
+// Most tools can answer from the Postgres replica, the simplemap REST API,
+// or (for scans and rollups) DuckDB with Postgres attached. Instead of every
+// handler hard-coding "DB if available else API", handlers describe what
+// kind of query they run and hand routeQuery one function per source they
+// support. The router picks a source from:
+//
+//   - availability (DATABASE_URL set, DuckDB attached)
+//   - health: a source that failed several calls in a row is skipped for a
+//     short cool-down
+//   - freshness: for queryRecent, a replica lagging more than
+//     SOURCE_MAX_REPLICATION_LAG loses to the API, which reads the primary
+//
+// If the chosen source fails, the next candidate is tried. Every result gets
+// a "source_routing" block explaining the choice.
+
+type dataSource string
+
+const (
+	sourceDatabase dataSource = "database"
+	sourceAPI      dataSource = "api"
+	sourceDuckDB   dataSource = "duckdb"
+)
+
+type queryKind int
+
+const (
+	queryRecent     queryKind = iota // latest readings, new uploads: must be current
+	queryHistorical                  // older data: replica lag does not matter
+	queryAggregate                   // scans and rollups: prefer DuckDB
+)
+
+// sourceFuncs holds one implementation per source; nil means unsupported.
+type sourceFuncs struct {
+	db     func() (*mcp.CallToolResult, error)
+	api    func() (*mcp.CallToolResult, error)
+	duckdb func() (*mcp.CallToolResult, error)
+}
+
+const (
+	defaultMaxReplicationLag = 5 * time.Minute
+	lagCheckInterval         = 30 * time.Second
+	sourceFailureThreshold   = 3
+	sourceCoolDown           = 30 * time.Second
+)
+
+type sourceHealth struct {
+	failures  int
+	downUntil time.Time
+	lastErr   string
+}
+
+type sourceRouter struct {
+	maxLag time.Duration
+
+	mu         sync.Mutex
+	lag        time.Duration
+	lagKnown   bool
+	lagChecked time.Time
+	health     map[dataSource]*sourceHealth
+}
+
+var router = &sourceRouter{
+	maxLag: durationEnv("SOURCE_MAX_REPLICATION_LAG", defaultMaxReplicationLag),
+	health: map[dataSource]*sourceHealth{},
+}
+
+// replicationLag returns the replica's replay lag, probing Postgres at most
+// every lagCheckInterval. ok is false when the lag is unknown.
+func (r *sourceRouter) replicationLag(ctx context.Context) (time.Duration, bool) {
+	r.mu.Lock()
+	if time.Since(r.lagChecked) < lagCheckInterval {
+		lag, ok := r.lag, r.lagKnown
+		r.mu.Unlock()
+		return lag, ok
+	}
+	r.lagChecked = time.Now()
+	r.mu.Unlock()
+
+	lag, ok := time.Duration(0), false
+	if dbAvailable() {
+		probeCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		row, err := queryRow(probeCtx, `
+			SELECT CASE WHEN pg_is_in_recovery()
+				THEN COALESCE(EXTRACT(EPOCH FROM (now() - pg_last_xact_replay_timestamp())), 0)
+				ELSE 0 END::float8 AS lag_seconds`)
+		cancel()
+		if err == nil {
+			if secs, isFloat := row["lag_seconds"].(float64); isFloat {
+				lag, ok = time.Duration(secs*float64(time.Second)), true
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.lag, r.lagKnown = lag, ok
+	r.mu.Unlock()
+	return lag, ok
+}
+
+func (r *sourceRouter) healthy(s dataSource) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.health[s]
+	return !ok || time.Now().After(h.downUntil)
+}
+
+func (r *sourceRouter) record(s dataSource, failure string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.health[s]
+	if !ok {
+		h = &sourceHealth{}
+		r.health[s] = h
+	}
+	if failure == "" {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	h.lastErr = failure
+	if h.failures >= sourceFailureThreshold {
+		h.downUntil = time.Now().Add(sourceCoolDown)
+		log.Printf("source router: %s failed %d times in a row, skipping it for %s (%s)", s, h.failures, sourceCoolDown, failure)
+	}
+}
+
+// Stats reports source health for db_info.
+func (r *sourceRouter) Stats() map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[string]any{
+		"max_replication_lag_seconds": r.maxLag.Seconds(),
+	}
+	if r.lagKnown {
+		out["replication_lag_seconds"] = r.lag.Seconds()
+	}
+	for s, h := range r.health {
+		entry := map[string]any{"consecutive_failures": h.failures}
+		if time.Now().Before(h.downUntil) {
+			entry["skipped_until"] = h.downUntil.UTC().Format(time.RFC3339)
+			entry["last_error"] = h.lastErr
+		}
+		out[string(s)] = entry
+	}
+	return out
+}
+
+type routeCandidate struct {
+	source dataSource
+	run    func() (*mcp.CallToolResult, error)
+	reason string
+}
+
+// candidates orders the available sources for a query, best first.
+func (r *sourceRouter) candidates(ctx context.Context, kind queryKind, fns sourceFuncs, info map[string]any) []routeCandidate {
+	dbOK := fns.db != nil && dbAvailable() && r.healthy(sourceDatabase)
+	apiOK := fns.api != nil && r.healthy(sourceAPI)
+	duckOK := fns.duckdb != nil && duckDBPostgresAttached() && r.healthy(sourceDuckDB)
+	if snapshotRequested(ctx) || explainOnly(ctx) {
+		// Only the database queries can be pinned (dataset_snapshot.go)
+		// or previewed (explain.go).
+		apiOK, duckOK = false, false
+	}
+
+	dbReason := "database available"
+	if kind == queryRecent && dbOK {
+		if lag, ok := r.replicationLag(ctx); ok {
+			info["replication_lag_seconds"] = lag.Seconds()
+			if lag > r.maxLag && apiOK {
+				reason := fmt.Sprintf("replica is %s behind (limit %s); the API reads the primary", lag.Round(time.Second), r.maxLag)
+				return []routeCandidate{
+					{sourceAPI, fns.api, reason},
+					{sourceDatabase, fns.db, "API failed; using lagging replica"},
+				}
+			}
+			dbReason = fmt.Sprintf("replica current (lag %s)", lag.Round(time.Second))
+		}
+	}
+
+	var out []routeCandidate
+	if kind == queryAggregate && duckOK {
+		out = append(out, routeCandidate{sourceDuckDB, fns.duckdb, "aggregate query; DuckDB with Postgres attached"})
+	}
+	if dbOK {
+		out = append(out, routeCandidate{sourceDatabase, fns.db, dbReason})
+	}
+	if duckOK && kind != queryAggregate {
+		out = append(out, routeCandidate{sourceDuckDB, fns.duckdb, "database unavailable; DuckDB with Postgres attached"})
+	}
+	if apiOK {
+		reason := "no database connection"
+		switch {
+		case fns.db == nil:
+			reason = "only the API serves this query"
+		case dbAvailable():
+			reason = "database recently failing"
+		}
+		out = append(out, routeCandidate{sourceAPI, fns.api, reason})
+	}
+	return out
+}
+
+// routeQuery runs a query against the best available source, falling back
+// to the next one on failure, and annotates the result with the decision.
+func routeQuery(ctx context.Context, kind queryKind, fns sourceFuncs) (*mcp.CallToolResult, error) {
+	info := map[string]any{}
+	cands := router.candidates(ctx, kind, fns, info)
+	if len(cands) == 0 {
+		if snapshotRequested(ctx) {
+			return mcp.NewToolResultError("snapshot_id needs the database, which is not available or failing; the simplemap API cannot be pinned to a snapshot."), nil
+		}
+		return mcp.NewToolResultError("No data source available for this query (database not configured or failing, and no API fallback)."), nil
+	}
+
+	var fallbacks []map[string]any
+	var res *mcp.CallToolResult
+	var err error
+	for i, c := range cands {
+		res, err = c.run()
+		failure := sourceFailure(res, err)
+		if !explainOnly(ctx) { // previews return no rows; that says nothing about health
+			router.record(c.source, failure)
+		}
+		if failure == "" || i == len(cands)-1 {
+			info["source"] = string(c.source)
+			info["reason"] = c.reason
+			if c.source != sourceDatabase {
+				snapshotNotApplied(ctx)
+			}
+			break
+		}
+		log.Printf("source router: %s failed (%s), trying %s", c.source, failure, cands[i+1].source)
+		fallbacks = append(fallbacks, map[string]any{"source": string(c.source), "error": failure})
+	}
+	if len(fallbacks) > 0 {
+		info["failed_sources"] = fallbacks
+	}
+	if err != nil {
+		return res, err
+	}
+	return amendJSONResult(res, func(m map[string]any) {
+		m["source_routing"] = info
+	}), nil
+}
+
+// sourceFailure describes why a source call failed, or "" on success. A
+// tool error saying something was not found is an answer, not a failure.
+func sourceFailure(res *mcp.CallToolResult, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	if res == nil || !res.IsError {
+		return ""
+	}
+	var text string
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	if strings.Contains(strings.ToLower(text), "not found") {
+		return ""
+	}
+	if text == "" {
+		text = "tool error"
+	}
+	return text
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Spatial filters for analytics ──────────────────────────────────────────
+//
+// radiation_stats and query_extreme_readings can be limited to a polygon
+// (given directly, from a saved polygon region, or a country's bounding
+// box) and/or to a radius around a point. On the DuckDB path this uses the
+// spatial extension loaded by initDuckDB, so the attached Postgres replica
+// only has to stream rows inside the bounding box; the plain Postgres path
+// uses the equivalent PostGIS functions.
+
+// withSpatialParams adds the spatial filter parameters to a tool.
+func withSpatialParams() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		for _, opt := range spatialParams {
+			opt(t)
+		}
+	}
+}
+
+var spatialParams = []mcp.ToolOption{
+	mcp.WithString("polygon",
+		mcp.Description("Limit to a polygon: JSON array of [lon, lat] points (GeoJSON order). A saved polygon region passed as region=<name> is applied exactly."),
+	),
+	mcp.WithString("country",
+		mcp.Description("Limit to a country's bounding box (names as in list_supported_regions)"),
+	),
+	mcp.WithNumber("near_lat", mcp.Description("Latitude of a point to filter around (use with near_lon and radius_m)"), mcp.Min(-90), mcp.Max(90)),
+	mcp.WithNumber("near_lon", mcp.Description("Longitude of a point to filter around"), mcp.Min(-180), mcp.Max(180)),
+	mcp.WithNumber("radius_m", mcp.Description("Distance from near_lat/near_lon in meters (max 500000)"), mcp.Min(1), mcp.Max(500000)),
+}
+
+type spatialFilter struct {
+	Polygon [][]float64 // [lon, lat] ring
+	Country string
+	HasNear bool
+	NearLat float64
+	NearLon float64
+	RadiusM float64
+}
+
+// spatialFilterFromRequest reads the spatialParams. The polygon may arrive
+// as a JSON string (from a client) or as an array (from a saved region).
+func spatialFilterFromRequest(req mcp.CallToolRequest) (spatialFilter, error) {
+	var f spatialFilter
+	args := req.GetArguments()
+
+	if raw, ok := args["polygon"]; ok && raw != nil && raw != "" {
+		data, _ := json.Marshal(raw)
+		if s, ok := raw.(string); ok {
+			data = []byte(s)
+		}
+		if err := json.Unmarshal(data, &f.Polygon); err != nil {
+			return f, fmt.Errorf("polygon must be a JSON array of [lon, lat] points")
+		}
+		if _, _, _, _, err := polygonBounds(f.Polygon); err != nil {
+			return f, err
+		}
+	}
+
+	if c := strings.TrimSpace(req.GetString("country", "")); c != "" {
+		if f.Polygon != nil {
+			return f, fmt.Errorf("pass either polygon or country, not both")
+		}
+		name, b, ok := lookupCountry(c)
+		if !ok {
+			return f, fmt.Errorf("%s", unknownCountryError(c))
+		}
+		f.Country = name
+		f.Polygon = [][]float64{{b[2], b[0]}, {b[3], b[0]}, {b[3], b[1]}, {b[2], b[1]}}
+	}
+
+	_, hasLat := args["near_lat"]
+	_, hasLon := args["near_lon"]
+	_, hasRadius := args["radius_m"]
+	if hasLat || hasLon || hasRadius {
+		if !(hasLat && hasLon && hasRadius) {
+			return f, fmt.Errorf("near_lat, near_lon and radius_m must be given together")
+		}
+		f.HasNear = true
+		f.NearLat = req.GetFloat("near_lat", 0)
+		f.NearLon = req.GetFloat("near_lon", 0)
+		f.RadiusM = req.GetFloat("radius_m", 0)
+		if f.NearLat < -90 || f.NearLat > 90 || f.NearLon < -180 || f.NearLon > 180 {
+			return f, fmt.Errorf("near_lat must be between -90 and 90 and near_lon between -180 and 180")
+		}
+		if f.RadiusM <= 0 || f.RadiusM > 500000 {
+			return f, fmt.Errorf("radius_m must be between 1 and 500000")
+		}
+	}
+	return f, nil
+}
+
+func (f spatialFilter) empty() bool {
+	return f.Polygon == nil && !f.HasNear
+}
+
+// needsSpatial reports whether the filter goes beyond a plain bounding box.
+// A country is only a box, so it works without the spatial extension.
+func (f spatialFilter) needsSpatial() bool {
+	return (f.Polygon != nil && f.Country == "") || f.HasNear
+}
+
+// bboxSQL is a plain lat/lon pre-filter that the postgres scanner can push
+// down, so only candidate rows leave the replica.
+func (f spatialFilter) bboxSQL() []string {
+	var conds []string
+	if f.Polygon != nil {
+		minLat, maxLat, minLon, maxLon, _ := polygonBounds(f.Polygon)
+		conds = append(conds, fmt.Sprintf("lat BETWEEN %.6f AND %.6f AND lon BETWEEN %.6f AND %.6f", minLat, maxLat, minLon, maxLon))
+	}
+	if f.HasNear {
+		// One degree of latitude is ~111 km; widen longitude by latitude.
+		dLat := f.RadiusM / 111000
+		dLon := 180.0
+		if c := math.Cos(f.NearLat * math.Pi / 180); c > 0.01 {
+			dLon = min(dLat/c, 180)
+		}
+		conds = append(conds, fmt.Sprintf("lat BETWEEN %.6f AND %.6f AND lon BETWEEN %.6f AND %.6f",
+			f.NearLat-dLat, f.NearLat+dLat, f.NearLon-dLon, f.NearLon+dLon))
+	}
+	return conds
+}
+
+// duckdbSQL returns WHERE conditions for DuckDB with the spatial extension.
+// ST_Distance_Sphere expects points in [lat, lon] axis order, unlike
+// ST_Contains, which works in the polygon's [lon, lat] plane.
+func (f spatialFilter) duckdbSQL() []string {
+	conds := f.bboxSQL()
+	if f.Polygon != nil && f.Country == "" {
+		conds = append(conds, fmt.Sprintf("ST_Contains(ST_GeomFromText('%s'), ST_Point(lon, lat))", polygonWKT(f.Polygon)))
+	}
+	if f.HasNear {
+		conds = append(conds, fmt.Sprintf("ST_Distance_Sphere(ST_Point(lat, lon), ST_Point(%.6f, %.6f)) <= %.1f", f.NearLat, f.NearLon, f.RadiusM))
+	}
+	return conds
+}
+
+// postgisSQL returns the same conditions for Postgres with PostGIS.
+func (f spatialFilter) postgisSQL() []string {
+	conds := f.bboxSQL()
+	if f.Polygon != nil && f.Country == "" {
+		conds = append(conds, fmt.Sprintf("ST_Contains(ST_GeomFromText('%s', 4326), ST_SetSRID(ST_MakePoint(lon, lat), 4326))", polygonWKT(f.Polygon)))
+	}
+	if f.HasNear {
+		conds = append(conds, fmt.Sprintf("ST_DWithin(ST_MakePoint(lon, lat)::geography, ST_MakePoint(%.6f, %.6f)::geography, %.1f)", f.NearLon, f.NearLat, f.RadiusM))
+	}
+	return conds
+}
+
+// describe reports the applied filter in results.
+func (f spatialFilter) describe() map[string]any {
+	if f.empty() {
+		return nil
+	}
+	d := map[string]any{}
+	switch {
+	case f.Country != "":
+		d["country"] = f.Country
+		d["note"] = "Country filter uses the country's bounding box, which includes parts of neighbouring countries."
+	case f.Polygon != nil:
+		d["polygon"] = f.Polygon
+	}
+	if f.HasNear {
+		d["near"] = map[string]any{"lat": f.NearLat, "lon": f.NearLon, "radius_m": f.RadiusM}
+	}
+	return d
+}
+
+// polygonWKT renders a [lon, lat] ring as a closed WKT polygon.
+func polygonWKT(ring [][]float64) string {
+	pts := make([]string, 0, len(ring)+1)
+	for _, p := range ring {
+		pts = append(pts, fmt.Sprintf("%.6f %.6f", p[0], p[1]))
+	}
+	if first, last := ring[0], ring[len(ring)-1]; first[0] != last[0] || first[1] != last[1] {
+		pts = append(pts, pts[0])
+	}
+	return "POLYGON((" + strings.Join(pts, ", ") + "))"
+}
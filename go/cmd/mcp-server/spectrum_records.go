@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Spectrum records ───────────────────────────────────────────────────────
+//
+// The spectrum analysis tools (spectrum_roi_rate, spectrum_dose_check) read
+// spectra with their channel counts and the marker they were recorded at
+// from the database, the demo dataset or, for a single marker, the
+// simplemap API, and work out channel energies from the calibration.
+
+// spectrumRecord is a spectrum with the marker it was recorded at.
+type spectrumRecord struct {
+	SpectrumID   int64
+	MarkerID     int64
+	TrackID      string
+	DeviceModel  string
+	Channels     []float64
+	EnergyMinKeV float64
+	EnergyMaxKeV float64
+	LiveTimeSec  float64
+	Calibration  any
+
+	HasMarker  bool // the fields below are known
+	DoseRate   float64
+	Lat, Lon   float64
+	CapturedAt time.Time
+}
+
+const spectrumRecordSelect = `SELECT s.id, s.marker_id, s.channels, s.energy_min_kev, s.energy_max_kev,
+		s.live_time_sec, s.device_model, s.calibration,
+		m.doserate, m.lat, m.lon, to_timestamp(m.date) AS captured_at, m.trackid
+	FROM spectra s
+	JOIN markers m ON m.id = s.marker_id`
+
+// spectrumQuery selects spectra: the one at MarkerID, or those matching
+// TrackID and DeviceModel (either may be empty).
+type spectrumQuery struct {
+	MarkerID    int
+	TrackID     string
+	DeviceModel string
+}
+
+// loadSpectra reads up to limit spectra matching q: a track's in time
+// order, others newest first. truncated reports that there are more.
+func loadSpectra(ctx context.Context, q spectrumQuery, limit int) (spectra []spectrumRecord, source string, truncated bool, err error) {
+	switch {
+	case dbAvailable():
+		var args []any
+		next := 1
+		bind := pgBinder(&args, &next)
+		var where []string
+		if q.MarkerID > 0 {
+			where = append(where, "s.marker_id = "+bind(q.MarkerID))
+		}
+		if q.TrackID != "" {
+			where = append(where, "m.trackid = "+bind(q.TrackID))
+		}
+		if q.DeviceModel != "" {
+			where = append(where, detectorFilter("s.device_model", q.DeviceModel, bind))
+		}
+		query := spectrumRecordSelect
+		if len(where) > 0 {
+			query += " WHERE " + strings.Join(where, " AND ")
+		}
+		order := "m.date DESC, s.id DESC"
+		if q.TrackID != "" {
+			order = "m.date, s.id"
+		}
+		query += fmt.Sprintf(" ORDER BY %s LIMIT %s", order, bind(limit+1))
+		rows, err := queryRows(ctx, query, args...)
+		if err != nil {
+			return nil, "", false, err
+		}
+		for _, r := range rows {
+			spectra = append(spectra, spectrumRecordFromRow(r))
+		}
+		source = "database"
+
+	case demoMode:
+		d := demoData()
+		for _, sp := range d.Spectra {
+			m, _ := d.marker(sp.MarkerID)
+			if (q.MarkerID > 0 && sp.MarkerID != int64(q.MarkerID)) || (q.TrackID != "" && m.TrackID != q.TrackID) ||
+				(q.DeviceModel != "" && !demoDetectorMatch(sp.DeviceModel, q.DeviceModel)) {
+				continue
+			}
+			counts := make([]float64, len(sp.Channels))
+			for i, c := range sp.Channels {
+				counts[i] = float64(c)
+			}
+			spectra = append(spectra, spectrumRecord{
+				SpectrumID: sp.ID, MarkerID: sp.MarkerID, TrackID: m.TrackID, DeviceModel: sp.DeviceModel,
+				Channels: counts, EnergyMinKeV: sp.EnergyMinKeV, EnergyMaxKeV: sp.EnergyMaxKeV,
+				LiveTimeSec: sp.LiveTimeSec, Calibration: sp.Calibration,
+				HasMarker: true, DoseRate: m.DoseRate, Lat: m.Lat, Lon: m.Lon,
+				CapturedAt: time.Unix(m.Date, 0).UTC(),
+			})
+		}
+		slices.SortStableFunc(spectra, func(a, b spectrumRecord) int {
+			if q.TrackID != "" {
+				return a.CapturedAt.Compare(b.CapturedAt)
+			}
+			return b.CapturedAt.Compare(a.CapturedAt)
+		})
+		source = "demo"
+
+	case q.MarkerID > 0 && q.TrackID == "" && q.DeviceModel == "":
+		sp, err := client.GetSpectrum(ctx, q.MarkerID)
+		if err != nil {
+			if isNotFound(err) {
+				return nil, "api", false, nil
+			}
+			return nil, "", false, err
+		}
+		spectra = []spectrumRecord{spectrumRecordFromAPI(q.MarkerID, sp)}
+		source = "api"
+
+	default:
+		return nil, "", false, fmt.Errorf("searching spectra by track or device model requires a database connection (the simplemap API serves spectra by marker only)")
+	}
+
+	if len(spectra) > limit {
+		spectra, truncated = spectra[:limit], true
+	}
+	return spectra, source, truncated, nil
+}
+
+func spectrumRecordFromRow(r map[string]any) spectrumRecord {
+	s := spectrumRecord{Calibration: r["calibration"], HasMarker: true}
+	id, _ := toFloat(r["id"])
+	marker, _ := toFloat(r["marker_id"])
+	s.SpectrumID, s.MarkerID = int64(id), int64(marker)
+	s.TrackID, _ = r["trackid"].(string)
+	s.DeviceModel, _ = r["device_model"].(string)
+	s.Channels = spectrumCounts(r["channels"])
+	s.EnergyMinKeV, _ = toFloat(r["energy_min_kev"])
+	s.EnergyMaxKeV, _ = toFloat(r["energy_max_kev"])
+	s.LiveTimeSec, _ = toFloat(r["live_time_sec"])
+	s.DoseRate, _ = toFloat(r["doserate"])
+	s.Lat, _ = toFloat(r["lat"])
+	s.Lon, _ = toFloat(r["lon"])
+	s.CapturedAt, _ = r["captured_at"].(time.Time)
+	return s
+}
+
+func spectrumRecordFromAPI(markerID int, sp map[string]any) spectrumRecord {
+	s := spectrumRecord{MarkerID: int64(markerID), Calibration: sp["calibration"]}
+	s.DeviceModel, _ = sp["deviceModel"].(string)
+	s.Channels = spectrumCounts(sp["channels"])
+	s.EnergyMinKeV, _ = toFloat(sp["energyMinKeV"])
+	s.EnergyMaxKeV, _ = toFloat(sp["energyMaxKeV"])
+	s.LiveTimeSec, _ = toFloat(sp["liveTimeSec"])
+	return s
+}
+
+// summary is the identifying part of a spectrum's result entry.
+func (s spectrumRecord) summary() map[string]any {
+	out := map[string]any{
+		"marker_id":     s.MarkerID,
+		"device_model":  s.DeviceModel,
+		"channel_count": len(s.Channels),
+		"live_time_sec": s.LiveTimeSec,
+	}
+	if s.SpectrumID > 0 {
+		out["spectrum_id"] = s.SpectrumID
+	}
+	if s.HasMarker {
+		out["track_id"] = s.TrackID
+		out["doserate"] = s.DoseRate
+		out["latitude"] = s.Lat
+		out["longitude"] = s.Lon
+		if !s.CapturedAt.IsZero() {
+			out["captured_at"] = s.CapturedAt.UTC().Format(time.RFC3339)
+		}
+	}
+	return out
+}
+
+// channelEnergies returns the centre energy of every channel in keV, from
+// the polynomial calibration (a0 + a1·ch + a2·ch²) when there is a usable
+// one and from a linear spread over the energy range otherwise. The second
+// value names which was used.
+func (s spectrumRecord) channelEnergies() ([]float64, string) {
+	n := len(s.Channels)
+	if n == 0 {
+		return nil, "none"
+	}
+	if a, ok := calibrationCoefficients(s.Calibration); ok {
+		energies := make([]float64, n)
+		monotonic := true
+		for c := range energies {
+			x := float64(c) + 0.5
+			energies[c] = a[0] + a[1]*x + a[2]*x*x
+			if c > 0 && energies[c] <= energies[c-1] {
+				monotonic = false
+				break
+			}
+		}
+		if monotonic {
+			return energies, "polynomial"
+		}
+	}
+	if s.EnergyMaxKeV > s.EnergyMinKeV {
+		energies := make([]float64, n)
+		step := (s.EnergyMaxKeV - s.EnergyMinKeV) / float64(n)
+		for c := range energies {
+			energies[c] = s.EnergyMinKeV + (float64(c)+0.5)*step
+		}
+		return energies, "energy_range"
+	}
+	return nil, "none"
+}
+
+// calibrationCoefficients reads a0, a1, a2 from a calibration object
+// ({"a0":…, "a1":…, "a2":…} or {"coefficients": […]}), stored as JSON
+// or decoded.
+func calibrationCoefficients(v any) ([3]float64, bool) {
+	var a [3]float64
+	if s, ok := v.(string); ok {
+		var m map[string]any
+		if json.Unmarshal([]byte(s), &m) != nil {
+			return a, false
+		}
+		v = m
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return a, false
+	}
+	if list, ok := m["coefficients"].([]any); ok {
+		for i := 0; i < len(list) && i < 3; i++ {
+			a[i], _ = toFloat(list[i])
+		}
+	} else {
+		for i := range a {
+			a[i], _ = toFloat(m["a"+strconv.Itoa(i)])
+		}
+	}
+	return a, a[1] > 0
+}
+
+// spectrumCounts converts stored channel data to counts: a JSON or
+// Postgres array, as text or decoded.
+func spectrumCounts(v any) []float64 {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "{") { // Postgres array literal
+			s = "[" + strings.Trim(s, "{}") + "]"
+		}
+		var counts []float64
+		if json.Unmarshal([]byte(s), &counts) != nil {
+			return nil
+		}
+		return counts
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var counts []float64
+	if json.Unmarshal(data, &counts) != nil {
+		return nil
+	}
+	return counts
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── Suggestions for empty results ──────────────────────────────────────────
+//
+// An empty measurements array leaves a model little to say beyond "no data
+// here". suggestOnEmpty, a tool middleware, adds a "suggestions" block to
+// empty results of the area tools instead: the nearest measurement outside
+// the queried area with a ready call that covers it, the closest dates with
+// data in the area when the query had a time filter, and other tools that
+// may answer the question. Every suggestion carries the tool and arguments
+// to call, so the next step needs no guessing.
+
+const suggestionTimeout = 5 * time.Second
+
+// suggestionSearchRadiiM are the rings searched for the nearest marker
+// when only the simplemap API is available.
+var suggestionSearchRadiiM = []float64{10000, 50000, 200000}
+
+// emptySuggestionTools are the tools whose empty results get suggestions.
+var emptySuggestionTools = map[string]bool{
+	"query_radiation":           true,
+	"search_area":               true,
+	"list_tracks":               true,
+	"search_tracks_by_location": true,
+	"nearest_tracks":            true,
+	"query_extreme_readings":    true,
+}
+
+// emptyQuery is the area and period of a call that found nothing.
+type emptyQuery struct {
+	tool     string
+	args     map[string]any
+	hasArea  bool
+	lat, lon float64    // centre of the area
+	radiusM  float64    // point queries
+	box      [4]float64 // min_lat, max_lat, min_lon, max_lon
+	from, to time.Time  // time filter; to is exclusive
+	byMonth  bool       // the period was given as year/month
+}
+
+// suggestOnEmpty is a tool middleware that adds suggestions to empty
+// results of emptySuggestionTools.
+func suggestOnEmpty(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res, err := next(ctx, req)
+		if err != nil || !emptySuggestionTools[req.Params.Name] || !emptyResult(res) {
+			return res, err
+		}
+		q := parseEmptyQuery(req.Params.Name, req.GetArguments())
+		sctx, cancel := context.WithTimeout(ctx, suggestionTimeout)
+		defer cancel()
+		suggestions := emptySuggestions(sctx, q)
+		return amendJSONResult(res, func(m map[string]any) {
+			m["suggestions"] = normalizeValue("suggestions", suggestions, map[string]bool{}, false)
+			if hint, ok := m["_ai_hint"].(string); ok {
+				m["_ai_hint"] = hint + " **EMPTY RESULT**: nothing matched. The suggestions object lists the nearest area and dates with data and other tools, each with the call to make; offer or make one of them instead of only reporting no data."
+			}
+		}), nil
+	}
+}
+
+// emptyResult reports whether res is a successful result with count 0.
+func emptyResult(res *mcp.CallToolResult) bool {
+	if res == nil || res.IsError || len(res.Content) != 1 {
+		return false
+	}
+	tc, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		return false
+	}
+	var head struct {
+		Count *int `json:"count"`
+	}
+	return json.Unmarshal([]byte(tc.Text), &head) == nil && head.Count != nil && *head.Count == 0
+}
+
+// parseEmptyQuery reads the area and period from the call's arguments.
+// Arguments were validated by the tool, which found nothing with them.
+func parseEmptyQuery(tool string, args map[string]any) emptyQuery {
+	q := emptyQuery{tool: tool, args: args}
+	num := func(key string) (float64, bool) { return toFloat(args[key]) }
+
+	if lat, ok1 := num("lat"); ok1 {
+		if lon, ok2 := num("lon"); ok2 {
+			q.radiusM, _ = num("radius_m")
+			if q.radiusM <= 0 {
+				q.radiusM = 1500
+			}
+			dLat := q.radiusM / 111000
+			dLon := dLat / max(math.Cos(lat*math.Pi/180), 0.01)
+			q.hasArea, q.lat, q.lon = true, lat, lon
+			q.box = [4]float64{lat - dLat, lat + dLat, lon - dLon, lon + dLon}
+		}
+	}
+	if !q.hasArea {
+		if area, err := parseTrackArea(args["country"], num); err == nil && area != nil {
+			q.hasArea, q.box = true, area.Box
+			q.lat, q.lon = (area.Box[0]+area.Box[1])/2, (area.Box[2]+area.Box[3])/2
+		}
+	}
+
+	if start, err := time.Parse("2006-01-02", stringArg(args, "start_date")); err == nil {
+		q.from = start
+	}
+	if end, err := time.Parse("2006-01-02", stringArg(args, "end_date")); err == nil {
+		q.to = end.AddDate(0, 0, 1)
+	}
+	if year, ok := num("year"); ok && year > 0 {
+		q.from = time.Date(int(year), 1, 1, 0, 0, 0, 0, time.UTC)
+		q.to = q.from.AddDate(1, 0, 0)
+		if month, ok := num("month"); ok && month >= 1 && month <= 12 {
+			q.from = time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			q.to = q.from.AddDate(0, 1, 0)
+			q.byMonth = true
+		}
+	}
+	return q
+}
+
+func stringArg(args map[string]any, key string) string {
+	s, _ := args[key].(string)
+	return strings.TrimSpace(s)
+}
+
+func (q emptyQuery) hasPeriod() bool { return !q.from.IsZero() || !q.to.IsZero() }
+
+func (q emptyQuery) contains(lat, lon float64) bool {
+	if q.radiusM > 0 {
+		return haversineMeters(q.lat, q.lon, lat, lon) <= q.radiusM
+	}
+	return lat >= q.box[0] && lat <= q.box[1] && lon >= q.box[2] && lon <= q.box[3]
+}
+
+func (q emptyQuery) inPeriod(t time.Time) bool {
+	return (q.from.IsZero() || !t.Before(q.from)) && (q.to.IsZero() || t.Before(q.to))
+}
+
+// emptySuggestions assembles the suggestions block.
+func emptySuggestions(ctx context.Context, q emptyQuery) map[string]any {
+	s := map[string]any{"alternative_tools": alternativeTools(q)}
+	if !q.hasArea {
+		s["note"] = "The query had no area, so no nearby data could be suggested."
+		return s
+	}
+
+	lat, lon, at, err := nearestMarker(ctx, q)
+	switch {
+	case err != nil:
+		s["nearest_area_note"] = "Could not search for nearby data: " + err.Error()
+	case at.IsZero():
+		s["nearest_area_note"] = "No measurements found near the queried area."
+	case q.contains(lat, lon):
+		s["nearest_area_note"] = "The queried area has measurements; the other filters of the query excluded them."
+	default:
+		s["nearest_area"] = nearestAreaSuggestion(q, lat, lon, at)
+	}
+
+	if q.hasPeriod() {
+		before, after, err := nearestDates(ctx, q)
+		switch {
+		case err != nil:
+			s["nearest_time_range_note"] = "Could not search for other dates: " + err.Error()
+		case before.IsZero() && after.IsZero():
+			s["nearest_time_range_note"] = "The queried area has no measurements at any other time."
+		default:
+			tr := map[string]any{}
+			if !before.IsZero() {
+				tr["before"] = periodSuggestion(q, before)
+			}
+			if !after.IsZero() {
+				tr["after"] = periodSuggestion(q, after)
+			}
+			s["nearest_time_range"] = tr
+		}
+	}
+	return s
+}
+
+// nearestMarker finds the measurement nearest to the centre of the area.
+// A zero time means none was found.
+func nearestMarker(ctx context.Context, q emptyQuery) (lat, lon float64, at time.Time, err error) {
+	if dbAvailable() {
+		row, err := queryRow(ctx, `
+			SELECT lat, lon, date
+			FROM markers
+			ORDER BY geom <-> ST_SetSRID(ST_MakePoint($2, $1), 4326)
+			LIMIT 1`, q.lat, q.lon)
+		if err != nil || row == nil {
+			return 0, 0, time.Time{}, err
+		}
+		lat, _ = toFloat(row["lat"])
+		lon, _ = toFloat(row["lon"])
+		date, _ := toFloat(row["date"])
+		return lat, lon, time.Unix(int64(date), 0).UTC(), nil
+	}
+
+	for _, r := range suggestionSearchRadiiM {
+		dLat := r / 111000
+		dLon := dLat / max(math.Cos(q.lat*math.Pi/180), 0.01)
+		markers, err := client.GetMarkers(ctx, q.lat-dLat, q.lon-dLon, q.lat+dLat, q.lon+dLon)
+		if err != nil {
+			return 0, 0, time.Time{}, err
+		}
+		best := math.Inf(1)
+		for _, m := range markers {
+			mLat, ok1 := toFloat(m["lat"])
+			mLon, ok2 := toFloat(m["lon"])
+			date, ok3 := toFloat(m["date"])
+			if !ok1 || !ok2 || !ok3 {
+				continue
+			}
+			if d := haversineMeters(q.lat, q.lon, mLat, mLon); d <= r && d < best {
+				best = d
+				lat, lon, at = mLat, mLon, time.Unix(int64(date), 0).UTC()
+			}
+		}
+		if !at.IsZero() {
+			break
+		}
+	}
+	return lat, lon, at, nil
+}
+
+// nearestDates finds the last measurement in the area before the period
+// and the first after it.
+func nearestDates(ctx context.Context, q emptyQuery) (before, after time.Time, err error) {
+	if dbAvailable() {
+		var from, to int64 = math.MinInt64, math.MaxInt64
+		if !q.from.IsZero() {
+			from = q.from.Unix()
+		}
+		if !q.to.IsZero() {
+			to = q.to.Unix()
+		}
+		row, err := queryRow(ctx, `
+			SELECT
+				(SELECT max(date) FROM markers
+				 WHERE geom && ST_MakeEnvelope($1, $2, $3, $4, 4326) AND date < $5) AS before,
+				(SELECT min(date) FROM markers
+				 WHERE geom && ST_MakeEnvelope($1, $2, $3, $4, 4326) AND date >= $6) AS after`,
+			q.box[2], q.box[0], q.box[3], q.box[1], from, to)
+		if err != nil || row == nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if b, ok := toFloat(row["before"]); ok {
+			before = time.Unix(int64(b), 0).UTC()
+		}
+		if a, ok := toFloat(row["after"]); ok {
+			after = time.Unix(int64(a), 0).UTC()
+		}
+		return before, after, nil
+	}
+
+	markers, err := client.GetMarkers(ctx, q.box[0], q.box[2], q.box[1], q.box[3])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	for _, m := range markers {
+		date, ok := toFloat(m["date"])
+		mLat, ok1 := toFloat(m["lat"])
+		mLon, ok2 := toFloat(m["lon"])
+		if !ok || !ok1 || !ok2 || !q.contains(mLat, mLon) {
+			continue
+		}
+		t := time.Unix(int64(date), 0).UTC()
+		switch {
+		case q.inPeriod(t):
+		case t.Before(q.from) && t.After(before):
+			before = t
+		case !q.to.IsZero() && !t.Before(q.to) && (after.IsZero() || t.Before(after)):
+			after = t
+		}
+	}
+	return before, after, nil
+}
+
+// nearestAreaSuggestion is the nearest data outside the area with a call
+// of the same tool that reaches it: a larger radius for point queries when
+// the tool allows it, otherwise the same area moved onto the data.
+func nearestAreaSuggestion(q emptyQuery, lat, lon float64, at time.Time) map[string]any {
+	dist, bearing, phrase := relativePosition(q.lat, q.lon, lat, lon)
+	if q.radiusM == 0 {
+		phrase = strings.Replace(phrase, "the requested point", "the centre of the queried area", 1)
+	}
+	args := maps.Clone(q.args)
+	if q.radiusM > 0 {
+		if radius := math.Ceil(dist*1.1/100) * 100; radius <= 50000 {
+			args["radius_m"] = radius
+		} else {
+			args["lat"], args["lon"] = lat, lon
+		}
+	} else {
+		halfLat, halfLon := (q.box[1]-q.box[0])/2, (q.box[3]-q.box[2])/2
+		delete(args, "country")
+		args["min_lat"], args["max_lat"] = math.Max(lat-halfLat, -90), math.Min(lat+halfLat, 90)
+		args["min_lon"], args["max_lon"] = math.Max(lon-halfLon, -180), math.Min(lon+halfLon, 180)
+	}
+	return map[string]any{
+		"location":    map[string]any{"latitude": lat, "longitude": lon},
+		"place":       placeLabel(lat, lon),
+		"distance_m":  math.Round(dist),
+		"direction":   compassPoint(bearing),
+		"description": phrase,
+		"captured_at": at.Format(time.RFC3339),
+		"map_url":     pointMapURL(lat, lon, defaultMapZoom),
+		"call":        map[string]any{"tool": q.tool, "arguments": args},
+	}
+}
+
+// periodSuggestion is a date with data and a call of the same tool whose
+// period covers it.
+func periodSuggestion(q emptyQuery, t time.Time) map[string]any {
+	args := maps.Clone(q.args)
+	if _, ok := args["year"]; ok {
+		args["year"] = t.Year()
+		if q.byMonth {
+			args["month"] = int(t.Month())
+		}
+	} else {
+		days := 30
+		if !q.from.IsZero() && !q.to.IsZero() {
+			days = max(int(q.to.Sub(q.from).Hours()/24), 1)
+		}
+		day := t.Truncate(24 * time.Hour)
+		args["start_date"] = day.AddDate(0, 0, -(days-1)/2).Format("2006-01-02")
+		args["end_date"] = day.AddDate(0, 0, days/2).Format("2006-01-02")
+	}
+	return map[string]any{
+		"captured_at": t.Format(time.RFC3339),
+		"call":        map[string]any{"tool": q.tool, "arguments": args},
+	}
+}
+
+// alternativeTools lists other tools that may answer the question, with
+// the arguments to call them with.
+func alternativeTools(q emptyQuery) []map[string]any {
+	alt := func(tool, reason string, args map[string]any) map[string]any {
+		return map[string]any{"tool": tool, "reason": reason, "arguments": args}
+	}
+	box := map[string]any{"min_lat": q.box[0], "max_lat": q.box[1], "min_lon": q.box[2], "max_lon": q.box[3]}
+	around := map[string]any{"min_lat": q.lat - 0.5, "max_lat": q.lat + 0.5, "min_lon": q.lon - 0.5, "max_lon": q.lon + 0.5}
+
+	out := []map[string]any{}
+	if q.hasArea {
+		switch q.tool {
+		case "query_radiation", "nearest_tracks", "search_area":
+			out = append(out,
+				alt("nearest_readings", "nearest mobile measurement and fixed sensor within 50 km, however far from the area",
+					map[string]any{"points": []map[string]any{{"lat": q.lat, "lon": q.lon}}, "radius_m": 50000}),
+				alt("sensor_current", "real-time fixed sensors around the area, which mobile surveys may have missed", around),
+			)
+		}
+		switch q.tool {
+		case "query_radiation", "search_area":
+			out = append(out, alt("nearest_tracks", "survey drives that passed near the area",
+				map[string]any{"lat": q.lat, "lon": q.lon, "radius_m": 50000}))
+		case "list_tracks":
+			out = append(out, alt("search_tracks_by_location", "tracks by the markers they recorded in the area rather than their start point", box))
+		case "search_tracks_by_location", "nearest_tracks":
+			out = append(out, alt("query_radiation", "individual measurements around the centre of the area",
+				map[string]any{"lat": q.lat, "lon": q.lon, "radius_m": 50000}))
+		case "query_extreme_readings":
+			out = append(out, alt("search_area", "all measurements in the area, without the extreme-value filters", box))
+		}
+		if place := locatePoint(q.lat, q.lon); place.City != nil {
+			out = append(out, alt("search_safecast", "tracks, sensors and uploaders named after the nearest town",
+				map[string]any{"query": place.City.name}))
+		}
+	}
+	if !q.hasArea && dbAvailable() {
+		out = append(out, alt("recent_uploads", "the newest uploads, to see which periods have data", map[string]any{"limit": 20}))
+	}
+	return out
+}
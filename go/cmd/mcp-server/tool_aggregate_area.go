@@ -0,0 +1,462 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── aggregate_area ─────────────────────────────────────────────────────────
+//
+// Heatmap summaries. A city holds tens of thousands of markers, far more
+// than fit in a tool result or an LLM's reading, so the markers in the box
+// are bucketed in the database into square cells (the dose_contours grid)
+// or hexagons, and only the count, average and maximum dose rate of each
+// measured cell come back.
+//
+// Hexagons are a pointy-top hex grid laid over the box in kilometres
+// (equirectangular around the box's middle latitude), anchored at its
+// south-west corner. They are not H3 cells: neither the database nor DuckDB
+// loads the h3 extension, and a grid of the requested size per call also
+// keeps cells the same size however large the box is.
+
+const (
+	defaultAggregateGrid = 20
+	maxAggregateGrid     = 60
+	aggregateKmPerDeg    = 111.32
+)
+
+var aggregateAreaToolDef = mcp.NewTool("aggregate_area",
+	mcp.WithDescription("Summarize the measurements in a bounding box on a grid, for heatmaps and \"where is it highest\" questions over areas with too many markers to list. Measurements are bucketed into square cells or hexagons and each measured cell returns its centre, measurement count, average and maximum dose rate in µSv/h; empty cells are left out. Use format: geojson for polygons a map library can draw directly. Use start_date/end_date to summarize one period, since dose rates change over the years. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
+	mcp.WithNumber("min_lat",
+		mcp.Description("Southern boundary latitude (required unless region is given)"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("max_lat",
+		mcp.Description("Northern boundary latitude (required unless region is given)"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("min_lon",
+		mcp.Description("Western boundary longitude (required unless region is given)"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("max_lon",
+		mcp.Description("Eastern boundary longitude (required unless region is given)"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithString("cell_shape",
+		mcp.Description("square (default) for a lat/lon grid, or hex for hexagons, which have no diagonal neighbours and suit heatmaps of roads better"),
+		mcp.Enum("square", "hex"),
+	),
+	mcp.WithNumber("grid_size",
+		mcp.Description("Number of cells along the longer side of the box (default: 20, max: 60)"),
+		mcp.Min(2), mcp.Max(maxAggregateGrid),
+		mcp.DefaultNumber(defaultAggregateGrid),
+	),
+	mcp.WithNumber("cell_km",
+		mcp.Description("Cell width in kilometres, instead of grid_size (side of a square, or distance between opposite sides of a hexagon). Must give at most 60 cells along the longer side of the box."),
+		mcp.Min(0.01),
+	),
+	mcp.WithString("format",
+		mcp.Description("cells (default) for a list of cell summaries, or geojson for a FeatureCollection with one polygon per cell"),
+		mcp.Enum("cells", "geojson"),
+	),
+	withDateWindowParams(),
+	withSnapshotParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// aggregateGrid is the cell layout of one aggregate_area call. Square cells
+// are the contourGrid's, indexed (column, row) from the south-west corner;
+// hexagons are indexed by axial coordinates (q, r) with radius hexKm.
+type aggregateGrid struct {
+	contourGrid
+	hex      bool
+	hexKm    float64 // centre to corner
+	kmPerLon float64
+}
+
+// newAggregateGrid lays out the cells: cellKm, when positive, is the cell
+// width, otherwise size cells span the longer side of the box.
+func newAggregateGrid(minLat, maxLat, minLon, maxLon float64, hex bool, size int, cellKm float64) (aggregateGrid, error) {
+	kmPerLon := aggregateKmPerDeg * math.Cos((minLat+maxLat)/2*math.Pi/180)
+	longKm := max((maxLat-minLat)*aggregateKmPerDeg, (maxLon-minLon)*kmPerLon)
+	if cellKm > 0 {
+		n := math.Ceil(longKm / cellKm)
+		if n > maxAggregateGrid {
+			return aggregateGrid{}, fmt.Errorf("cell_km %.3g gives %d cells along the longer side of the box; use at least %.3g km or a smaller area", cellKm, int(n), roundSignificant(longKm/maxAggregateGrid, 2))
+		}
+		size = max(2, int(n))
+	}
+	g := aggregateGrid{hex: hex, kmPerLon: kmPerLon}
+	if !hex {
+		g.contourGrid = newContourGrid(minLat, maxLat, minLon, maxLon, size)
+		return g, nil
+	}
+	g.contourGrid = contourGrid{minLat: minLat, maxLat: maxLat, minLon: minLon, maxLon: maxLon}
+	width := longKm / float64(size)
+	if cellKm > 0 {
+		width = cellKm
+	}
+	g.hexKm = width / math.Sqrt(3)
+	return g, nil
+}
+
+// shape is the cell_shape the grid was built for.
+func (g aggregateGrid) shape() string {
+	if g.hex {
+		return "hex"
+	}
+	return "square"
+}
+
+// cellKm2 is the ground area of one cell.
+func (g aggregateGrid) cellKm2() float64 {
+	if g.hex {
+		return 3 * math.Sqrt(3) / 2 * g.hexKm * g.hexKm
+	}
+	return g.contourGrid.cellKm2()
+}
+
+// cellOf returns the cell holding a point.
+func (g aggregateGrid) cellOf(lat, lon float64) (int, int) {
+	if !g.hex {
+		// floor() can put a marker on the far edge into the next cell.
+		return min(int(math.Floor((lon-g.minLon)/g.dLon)), g.cols-1), min(int(math.Floor((lat-g.minLat)/g.dLat)), g.rows-1)
+	}
+	x := (lon - g.minLon) * g.kmPerLon
+	y := (lat - g.minLat) * aggregateKmPerDeg
+	qf := (math.Sqrt(3)/3*x - y/3) / g.hexKm
+	rf := (2.0 / 3 * y) / g.hexKm
+	sf := -qf - rf
+	q, r, s := math.Round(qf), math.Round(rf), math.Round(sf)
+	dq, dr, ds := math.Abs(q-qf), math.Abs(r-rf), math.Abs(s-sf)
+	switch {
+	case dq > dr && dq > ds:
+		q = -r - s
+	case dr > ds:
+		r = -q - s
+	}
+	return int(q), int(r)
+}
+
+// centre returns the [lat, lon] centre of a cell.
+func (g aggregateGrid) centre(a, b int) (float64, float64) {
+	if !g.hex {
+		return g.minLat + (float64(b)+0.5)*g.dLat, g.minLon + (float64(a)+0.5)*g.dLon
+	}
+	x := g.hexKm * math.Sqrt(3) * (float64(a) + float64(b)/2)
+	y := g.hexKm * 1.5 * float64(b)
+	return g.minLat + y/aggregateKmPerDeg, g.minLon + x/g.kmPerLon
+}
+
+// ring is the closed outline of a cell as GeoJSON [lon, lat] positions.
+func (g aggregateGrid) ring(a, b int) [][]float64 {
+	if !g.hex {
+		s, w := g.minLat+float64(b)*g.dLat, g.minLon+float64(a)*g.dLon
+		n, e := s+g.dLat, w+g.dLon
+		return [][]float64{{round6(w), round6(s)}, {round6(e), round6(s)}, {round6(e), round6(n)}, {round6(w), round6(n)}, {round6(w), round6(s)}}
+	}
+	lat, lon := g.centre(a, b)
+	ring := make([][]float64, 7)
+	for i := range 6 {
+		angle := (60*float64(i) - 30) * math.Pi / 180
+		ring[i] = []float64{
+			round6(lon + g.hexKm*math.Cos(angle)/g.kmPerLon),
+			round6(lat + g.hexKm*math.Sin(angle)/aggregateKmPerDeg),
+		}
+	}
+	ring[6] = ring[0]
+	return ring
+}
+
+// cellsQuery buckets the markers into cells with their count, average and
+// maximum. Like contourCellsQuery it is written for DuckDB against
+// postgres_db and is valid Postgres once the postgres_db.public. prefix is
+// dropped; conds are extra WHERE conditions. Hexagons are found with cube
+// rounding of the fractional axial coordinates, as cellOf does.
+func (g aggregateGrid) cellsQuery(conds []string) string {
+	where := append([]string{
+		"doserate > 0 AND doserate < 1000",
+		fmt.Sprintf("lat >= %.8f AND lat < %.8f AND lon >= %.8f AND lon < %.8f", g.minLat, g.maxLat, g.minLon, g.maxLon),
+	}, conds...)
+	if !g.hex {
+		return fmt.Sprintf(`
+		SELECT
+			LEAST(CAST(floor((lon - %.8f) / %.12f) AS BIGINT), %d) AS a,
+			LEAST(CAST(floor((lat - %.8f) / %.12f) AS BIGINT), %d) AS b,
+			COUNT(*) AS n,
+			AVG(doserate) AS avg_value,
+			MAX(doserate) AS max_value
+		FROM postgres_db.public.markers
+		WHERE %s
+		GROUP BY 1, 2`,
+			g.minLon, g.dLon, g.cols-1, g.minLat, g.dLat, g.rows-1, strings.Join(where, " AND "))
+	}
+	x := fmt.Sprintf("((lon - %.8f) * %.8f)", g.minLon, g.kmPerLon)
+	y := fmt.Sprintf("((lat - %.8f) * %.8f)", g.minLat, aggregateKmPerDeg)
+	return fmt.Sprintf(`
+		SELECT a, b, COUNT(*) AS n, AVG(d) AS avg_value, MAX(d) AS max_value
+		FROM (
+			SELECT
+				CAST(CASE WHEN dq > dr AND dq > ds THEN -rr - rs ELSE rq END AS BIGINT) AS a,
+				CAST(CASE WHEN dq > dr AND dq > ds THEN rr WHEN dr > ds THEN -rq - rs ELSE rr END AS BIGINT) AS b,
+				d
+			FROM (
+				SELECT rq, rr, rs, abs(rq - qf) AS dq, abs(rr - rf) AS dr, abs(rs + qf + rf) AS ds, d
+				FROM (
+					SELECT round(qf) AS rq, round(rf) AS rr, round(-qf - rf) AS rs, qf, rf, d
+					FROM (
+						SELECT (%.12f * %s - %s / 3.0) / %.12f AS qf, (2.0 / 3.0 * %s) / %.12f AS rf, doserate AS d
+						FROM postgres_db.public.markers
+						WHERE %s
+					) AS projected
+				) AS rounded
+			) AS deltas
+		) AS cells
+		GROUP BY 1, 2`,
+		math.Sqrt(3)/3, x, y, g.hexKm, y, g.hexKm, strings.Join(where, " AND "))
+}
+
+// aggregateCell is one measured cell.
+type aggregateCell struct {
+	a, b     int
+	n        int
+	avg, max float64
+}
+
+func aggregateCellsFromRows(rows []map[string]any) []aggregateCell {
+	cells := make([]aggregateCell, 0, len(rows))
+	for _, r := range rows {
+		a, ok1 := toFloat(r["a"])
+		b, ok2 := toFloat(r["b"])
+		avg, ok3 := toFloat(r["avg_value"])
+		mx, ok4 := toFloat(r["max_value"])
+		n, _ := toFloat(r["n"])
+		if ok1 && ok2 && ok3 && ok4 {
+			cells = append(cells, aggregateCell{int(a), int(b), int(n), avg, mx})
+		}
+	}
+	return cells
+}
+
+// aggregateCellsFromMarkers buckets /get_markers results in memory, with
+// the same filters as cellsQuery.
+func aggregateCellsFromMarkers(g aggregateGrid, markers []map[string]any, window dateWindow) []aggregateCell {
+	byCell := map[[2]int]*aggregateCell{}
+	for _, m := range markers {
+		dose, ok1 := toFloat(m["doseRate"])
+		lat, ok2 := toFloat(m["lat"])
+		lon, ok3 := toFloat(m["lon"])
+		if !ok1 || !ok2 || !ok3 || dose <= 0 || dose >= 1000 {
+			continue
+		}
+		if lat < g.minLat || lat >= g.maxLat || lon < g.minLon || lon >= g.maxLon {
+			continue
+		}
+		if !window.empty() {
+			if date, ok := toFloat(m["date"]); !ok || !window.containsUnix(date) {
+				continue
+			}
+		}
+		a, b := g.cellOf(lat, lon)
+		c := byCell[[2]int{a, b}]
+		if c == nil {
+			c = &aggregateCell{a: a, b: b}
+			byCell[[2]int{a, b}] = c
+		}
+		c.n++
+		c.avg += dose // the sum until the end
+		c.max = max(c.max, dose)
+	}
+	cells := make([]aggregateCell, 0, len(byCell))
+	for _, c := range byCell {
+		c.avg /= float64(c.n)
+		cells = append(cells, *c)
+	}
+	return cells
+}
+
+func handleAggregateArea(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var bbox [4]float64
+	for i, name := range []string{"min_lat", "max_lat", "min_lon", "max_lon"} {
+		v, err := req.RequireFloat(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		bbox[i] = v
+	}
+	minLat, maxLat, minLon, maxLon := bbox[0], bbox[1], bbox[2], bbox[3]
+	if minLat < -90 || maxLat > 90 || minLon < -180 || maxLon > 180 {
+		return mcp.NewToolResultError("Latitude must be between -90 and 90 and longitude between -180 and 180"), nil
+	}
+	if minLat >= maxLat {
+		return mcp.NewToolResultError("min_lat must be less than max_lat"), nil
+	}
+	if minLon >= maxLon {
+		return mcp.NewToolResultError("min_lon must be less than max_lon"), nil
+	}
+	if maxLat-minLat > 10 || maxLon-minLon > 10 {
+		return mcp.NewToolResultError("The area is too large to aggregate: keep the box within 10 degrees on each side"), nil
+	}
+
+	shape := req.GetString("cell_shape", "square")
+	if shape != "square" && shape != "hex" {
+		return mcp.NewToolResultError("cell_shape must be square or hex"), nil
+	}
+	format := req.GetString("format", "cells")
+	if format != "cells" && format != "geojson" {
+		return mcp.NewToolResultError("format must be cells or geojson"), nil
+	}
+	size := req.GetInt("grid_size", defaultAggregateGrid)
+	if size < 2 || size > maxAggregateGrid {
+		return mcp.NewToolResultError(fmt.Sprintf("grid_size must be between 2 and %d", maxAggregateGrid)), nil
+	}
+	cellKm := req.GetFloat("cell_km", 0)
+	if cellKm < 0 {
+		return mcp.NewToolResultError("cell_km must be positive"), nil
+	}
+	window, err := dateWindowFromRequest(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	g, err := newAggregateGrid(minLat, maxLat, minLon, maxLon, shape == "hex", size, cellKm)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var timeConds []string
+	if !window.start.IsZero() {
+		timeConds = append(timeConds, fmt.Sprintf("date >= %d", window.start.Unix()))
+	}
+	if !window.end.IsZero() {
+		timeConds = append(timeConds, fmt.Sprintf("date < %d", window.end.Unix()))
+	}
+	build := func(cells []aggregateCell, source string) (*mcp.CallToolResult, error) {
+		return aggregateAreaResult(g, cells, format, source, window)
+	}
+	return routeQuery(ctx, queryAggregate, sourceFuncs{
+		duckdb: func() (*mcp.CallToolResult, error) {
+			rows, err := duckQueryRows(ctx, g.cellsQuery(timeConds))
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Aggregation query failed: %v", err)), nil
+			}
+			return build(aggregateCellsFromRows(rows), "duckdb_postgres_attach")
+		},
+		db: func() (*mcp.CallToolResult, error) {
+			conds := append([]string{
+				fmt.Sprintf("geom && ST_MakeEnvelope(%.8f, %.8f, %.8f, %.8f, 4326)", minLon, minLat, maxLon, maxLat),
+				fmt.Sprintf("id <= %d", snapshotBound(ctx)),
+			}, timeConds...)
+			rows, err := queryRows(ctx, strings.ReplaceAll(g.cellsQuery(conds), "postgres_db.public.", ""))
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Aggregation query failed: %v", err)), nil
+			}
+			return build(aggregateCellsFromRows(rows), "database")
+		},
+		api: func() (*mcp.CallToolResult, error) {
+			markers, err := client.GetMarkers(ctx, minLat, minLon, maxLat, maxLon)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return build(aggregateCellsFromMarkers(g, markers, window), "api")
+		},
+	})
+}
+
+func aggregateAreaResult(g aggregateGrid, cells []aggregateCell, format, source string, window dateWindow) (*mcp.CallToolResult, error) {
+	if len(cells) == 0 {
+		return mcp.NewToolResultError("No measurements found in the area for the selected period"), nil
+	}
+	// Highest average first, so the hotspots lead a truncated reading.
+	slices.SortFunc(cells, func(x, y aggregateCell) int {
+		if c := cmp.Compare(y.avg, x.avg); c != 0 {
+			return c
+		}
+		if x.b != y.b {
+			return x.b - y.b
+		}
+		return x.a - y.a
+	})
+
+	aName, bName := "col", "row"
+	if g.hex {
+		aName, bName = "q", "r"
+	}
+	markers := 0
+	minAvg, maxAvg, maxDose := math.Inf(1), math.Inf(-1), math.Inf(-1)
+	out := make([]map[string]any, len(cells))
+	for i, c := range cells {
+		markers += c.n
+		minAvg, maxAvg, maxDose = min(minAvg, c.avg), max(maxAvg, c.avg), max(maxDose, c.max)
+		lat, lon := g.centre(c.a, c.b)
+		props := map[string]any{
+			aName:      c.a,
+			bName:      c.b,
+			"lat":      round6(lat),
+			"lon":      round6(lon),
+			"count":    c.n,
+			"avg_usvh": roundSignificant(c.avg, 4),
+			"max_usvh": roundSignificant(c.max, 4),
+		}
+		if format == "geojson" {
+			out[i] = map[string]any{
+				"type":       "Feature",
+				"geometry":   map[string]any{"type": "Polygon", "coordinates": [][][]float64{g.ring(c.a, c.b)}},
+				"properties": props,
+			}
+		} else {
+			out[i] = props
+		}
+	}
+
+	grid := map[string]any{
+		"cell_shape":     g.shape(),
+		"cell_km2":       roundSignificant(g.cellKm2(), 3),
+		"cells_measured": len(cells),
+		"markers":        markers,
+	}
+	if g.hex {
+		grid["cell_km"] = roundSignificant(g.hexKm*math.Sqrt(3), 3)
+		grid["indexing"] = "Axial hex coordinates q, r from the south-west corner of the box; pointy-top hexagons on a kilometre grid, not H3 cells"
+	} else {
+		grid["cols"] = g.cols
+		grid["rows"] = g.rows
+		grid["cell_km"] = roundSignificant(math.Sqrt(g.cellKm2()), 3)
+		grid["indexing"] = "col counts cells east and row cells north from the south-west corner of the box"
+	}
+
+	result := map[string]any{
+		"bbox": map[string]any{
+			"min_lat": g.minLat,
+			"max_lat": g.maxLat,
+			"min_lon": g.minLon,
+			"max_lon": g.maxLon,
+		},
+		"map_url":            bboxMapURL(g.minLat, g.maxLat, g.minLon, g.maxLon),
+		"grid":               grid,
+		"cell_averages":      map[string]any{"min_usvh": roundSignificant(minAvg, 4), "max_usvh": roundSignificant(maxAvg, 4)},
+		"max_usvh":           roundSignificant(maxDose, 4),
+		"source":             source,
+		"method":             "Marker dose rates bucketed per cell; count, mean and maximum of each cell with at least one measurement. Cells are ordered by average dose rate, highest first.",
+		"_ai_hint":           "Cells without measurements are left out, not zero: most measurements follow roads, so empty cells mean unmeasured, not clean. A cell's avg_usvh with a small count rests on few readings. max_usvh is a single reading and may be a brief spike. For a heatmap, colour cells by avg_usvh and place them at lat/lon (or draw the geojson polygons). Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if format == "geojson" {
+		result["type"] = "FeatureCollection"
+		result["features"] = out
+	} else {
+		result["cells"] = out
+	}
+	window.addTo(result)
+	return jsonResult(result)
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -20,6 +24,9 @@ var radiationStatsToolDef = mcp.NewTool("radiation_stats",
 		mcp.Enum("year", "month", "overall"),
 		mcp.DefaultString("year"),
 	),
+	withSpatialParams(),
+	withHarmonizeParam(),
+	withCitationParam(),
 )
 
 // Handlers
@@ -31,10 +38,10 @@ func handleQueryAnalytics(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 
 	// Execute query
 	rows, err := duckDB.Query(`
-		SELECT tool_name, COUNT(*) as count,
+		SELECT tool_name, CAST(round(SUM(1 / sample_rate)) AS BIGINT) as count,
                AVG(duration_ms) as avg_ms,
                MAX(duration_ms) as max_ms
-		FROM mcp_query_log
+		FROM mcp_events
 		GROUP BY tool_name
 		ORDER BY count DESC
 	`)
@@ -68,12 +75,73 @@ func handleQueryAnalytics(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 }
 
 func handleRadiationStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if duckDB == nil {
-		return mcp.NewToolResultError("DuckDB analytics engine is not initialized"), nil
+	ctx = harmonizeContext(ctx, req)
+	interval := req.GetString("interval", "year")
+	spatial, err := spatialFilterFromRequest(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	interval := req.GetString("interval", "year")
+	// DuckDB scans the attached replica far faster than row-by-row pgx, so it
+	// is preferred; plain Postgres runs the same SQL when DuckDB is missing.
+	// Without the spatial extension, spatial filters run on PostGIS instead.
+	// Harmonized statistics scale each dose rate by its detector's factor
+	// and count the readings per detector for the provenance.
+	fns := sourceFuncs{
+		duckdb: func() (*mcp.CallToolResult, error) {
+			h := newHarmonizer(ctx)
+			conds := spatial.duckdbSQL()
+			res, err := radiationStatsDuckDB(ctx, interval, radiationStatsDoseQuery(interval, conds, h.doseSQL("doserate", "detector")), spatial.empty() && h == nil)
+			return addHarmonization(res, err, h, func() ([]map[string]any, error) {
+				return duckQueryRows(ctx, radiationStatsDetectorsQuery(interval, conds))
+			})
+		},
+		db: func() (*mcp.CallToolResult, error) {
+			h := newHarmonizer(ctx)
+			conds := spatial.postgisSQL()
+			res, err := radiationStatsDB(ctx, interval, radiationStatsDoseQuery(interval, conds, h.doseSQL("doserate", "detector")))
+			return addHarmonization(res, err, h, func() ([]map[string]any, error) {
+				return queryRows(ctx, strings.ReplaceAll(radiationStatsDetectorsQuery(interval, conds), "postgres_db.public.", ""))
+			})
+		},
+	}
+	if spatial.needsSpatial() && !duckDBSpatial() {
+		fns.duckdb = nil
+	}
+	// Without the attach, the last snapshot beats a full scan of the
+	// replica, and is all there is when the replica is down too. It holds
+	// unharmonized statistics only.
+	if !duckDBPostgresAttached() && spatial.empty() && !harmonizeDetectors(ctx) {
+		if res, ok := radiationStatsSnapshot(interval); ok {
+			return res, nil
+		}
+	}
+	res, err := routeQuery(ctx, queryAggregate, fns)
+	if err != nil || spatial.empty() {
+		return res, err
+	}
+	return amendJSONResult(res, func(m map[string]any) {
+		m["spatial_filter"] = spatial.describe()
+	}), nil
+}
 
+// radiationStatsQuery is written for DuckDB against postgres_db; it is also
+// valid Postgres once the postgres_db.public. prefix is dropped. conds are
+// extra WHERE conditions, such as a spatial filter.
+func radiationStatsQuery(interval string, conds []string) string {
+	return radiationStatsDoseQuery(interval, conds, "doserate")
+}
+
+// radiationStatsWhere is the WHERE clause of radiationStatsQuery, without
+// the one-year window of interval month.
+func radiationStatsWhere(conds []string) string {
+	return strings.Join(append([]string{"doserate > 0 AND doserate < 1000"}, conds...), " AND ")
+}
+
+// radiationStatsDoseQuery is radiationStatsQuery with dose as the dose
+// rate expression, such as a harmonized one (harmonization.go).
+func radiationStatsDoseQuery(interval string, conds []string, dose string) string {
+	where := radiationStatsWhere(conds)
 	var query string
 	switch interval {
 	case "year":
@@ -83,10 +151,10 @@ func handleRadiationStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 			SELECT
 				EXTRACT(YEAR FROM to_timestamp(date)::TIMESTAMP) AS year,
 				COUNT(*) AS count,
-				AVG(doserate) AS avg_value,
-				MAX(doserate) AS max_value
+				AVG(` + dose + `) AS avg_value,
+				MAX(` + dose + `) AS max_value
 			FROM postgres_db.public.markers
-			WHERE doserate > 0 AND doserate < 1000
+			WHERE ` + where + `
 			GROUP BY 1
 			ORDER BY 1 DESC
 			LIMIT 20
@@ -96,9 +164,9 @@ func handleRadiationStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 			SELECT
 				DATE_TRUNC('month', to_timestamp(date)::TIMESTAMP) AS month,
 				COUNT(*) AS count,
-				AVG(doserate) AS avg_value
+				AVG(` + dose + `) AS avg_value
 			FROM postgres_db.public.markers
-			WHERE doserate > 0 AND doserate < 1000
+			WHERE ` + where + `
 			  AND date > CAST(EXTRACT(EPOCH FROM (now() - INTERVAL '1 year')) AS BIGINT)
 			GROUP BY 1
 			ORDER BY 1 DESC
@@ -107,19 +175,89 @@ func handleRadiationStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 		query = `
 			SELECT
 				COUNT(*) AS count,
-				AVG(doserate) AS avg_value,
-				MAX(doserate) AS max_value
+				AVG(` + dose + `) AS avg_value,
+				MAX(` + dose + `) AS max_value
 			FROM postgres_db.public.markers
-			WHERE doserate > 0 AND doserate < 1000
+			WHERE ` + where + `
 		`
 	}
+	return query
+}
+
+// radiationStatsDetectorsQuery counts the readings per detector behind a
+// radiationStatsQuery.
+func radiationStatsDetectorsQuery(interval string, conds []string) string {
+	if interval == "month" {
+		conds = append(slices.Clone(conds), "date > CAST(EXTRACT(EPOCH FROM (now() - INTERVAL '1 year')) AS BIGINT)")
+	}
+	return detectorCountsQuery(radiationStatsWhere(conds))
+}
+
+func radiationStatsDB(ctx context.Context, interval, query string) (*mcp.CallToolResult, error) {
+	rows, err := queryRows(ctx, strings.ReplaceAll(query, "postgres_db.public.", ""))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Statistics query failed: %v", err)), nil
+	}
+	return radiationStatsResult(interval, rows, "database")
+}
 
+// radiationStatsDuckDB runs query in DuckDB. Unfiltered queries have fixed
+// text and reuse a prepared statement (see analytics_warmup.go).
+func radiationStatsDuckDB(ctx context.Context, interval, query string, prepared bool) (*mcp.CallToolResult, error) {
 	// Execute against DuckDB which proxies to Postgres
-	rows, err := duckDB.Query(query)
+	var rows *sql.Rows
+	var err error
+	if prepared {
+		rows, err = duckPreparedQuery(query)
+	} else {
+		rows, err = duckDB.Query(query)
+	}
+	var results []map[string]any
+	if err == nil {
+		results, err = scanDuckRows(rows)
+		recordRead(ctx, sourceDuckDB, len(results))
+	}
 	if err != nil {
 		// Provide helpful error if table doesn't exist (e.g. schema mismatch)
 		return mcp.NewToolResultError(fmt.Sprintf("Analytics query failed (check if postgres is attached): %v", err)), nil
 	}
+	return radiationStatsResult(interval, results, "duckdb_postgres_attach")
+}
+
+// radiationStatsSnapshot serves the last exported snapshot (see
+// analytics_snapshot.go). ok is false when there is none.
+func radiationStatsSnapshot(interval string) (*mcp.CallToolResult, bool) {
+	table, takenAt, ok := snapshotSource(statsSnapshotFile(interval))
+	if !ok {
+		return nil, false
+	}
+	results, err := duckQueryRows(context.Background(), "SELECT * FROM "+table)
+	if err != nil {
+		log.Printf("radiation_stats snapshot: %v", err)
+		return nil, false
+	}
+	res, err := radiationStatsResult(interval, results, "parquet_snapshot")
+	if err != nil {
+		return nil, false
+	}
+	return amendJSONResult(res, func(m map[string]any) {
+		m["snapshot"] = snapshotInfo(takenAt, "The database is not attached to the analytics engine; these statistics come from the last snapshot and do not include newer uploads.")
+	}), true
+}
+
+// duckQueryRows runs a DuckDB query and returns generic rows.
+func duckQueryRows(ctx context.Context, query string) ([]map[string]any, error) {
+	rows, err := duckDB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	results, err := scanDuckRows(rows)
+	recordRead(ctx, sourceDuckDB, len(results))
+	return results, err
+}
+
+// scanDuckRows reads and closes rows.
+func scanDuckRows(rows *sql.Rows) ([]map[string]any, error) {
 	defer rows.Close()
 
 	// Generic scanner for results
@@ -135,7 +273,7 @@ func handleRadiationStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 		}
 
 		if err := rows.Scan(columnPointers...); err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return nil, err
 		}
 
 		row := make(map[string]any)
@@ -150,11 +288,14 @@ func handleRadiationStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 		}
 		results = append(results, row)
 	}
+	return results, rows.Err()
+}
 
+func radiationStatsResult(interval string, results []map[string]any, source string) (*mcp.CallToolResult, error) {
 	return jsonResult(map[string]any{
 		"interval":           interval,
 		"data":               results,
-		"source":             "duckdb_postgres_attach",
+		"source":             source,
 		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	})
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var analyticsMaintenanceToolDef = mcp.NewTool("analytics_maintenance",
+	mcp.WithDescription("MAINTAINERS ONLY (requires a maintainer bearer token). Tidy up the server's analytics storage: checkpoint the DuckDB WAL, VACUUM ANALYZE local tables, rebuild indexes and recompress the Parquet snapshots. Runs one task after another and sends progress notifications when the request carries a progress token. action=status shows the running or last run."),
+	mcp.WithString("action",
+		mcp.Description("run or status (default: status)"),
+		mcp.Enum("run", "status"),
+		mcp.DefaultString("status"),
+	),
+	mcp.WithString("tasks",
+		mcp.Description("Comma-separated tasks for run: checkpoint, vacuum, reindex, compact, or all (default: all)"),
+		mcp.DefaultString("all"),
+	),
+	mcp.WithDestructiveHintAnnotation(false),
+)
+
+func handleAnalyticsMaintenance(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	maintainer, err := authenticateMaintainer(req.Header)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if req.GetString("action", "status") != "run" {
+		run, ok := maintenanceStatus()
+		if !ok {
+			return jsonResult(map[string]any{"status": "idle", "note": "No maintenance has run since the server started."})
+		}
+		return jsonResult(map[string]any{"run": run})
+	}
+
+	tasks, err := parseMaintenanceTasks(req.GetString("tasks", "all"))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	run, err := startMaintenance(tasks, maintainer)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	runMaintenance(run, progressNotifier(ctx, req))
+
+	final, _ := maintenanceStatus()
+	return jsonResult(map[string]any{"run": final})
+}
+
+// progressNotifier sends notifications/progress for the request when the
+// client asked for them with a progress token, and does nothing otherwise.
+func progressNotifier(ctx context.Context, req mcp.CallToolRequest) func(done, total int, message string) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		return func(int, int, string) {}
+	}
+	token := req.Params.Meta.ProgressToken
+	return func(done, total int, message string) {
+		// The client may have gone away; the run carries on regardless.
+		_ = srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      done,
+			"total":         total,
+			"message":       message,
+		})
+	}
+}
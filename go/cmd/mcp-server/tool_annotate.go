@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var annotateMeasurementToolDef = mcp.NewTool("annotate_measurement",
+	mcp.WithDescription("MAINTAINERS ONLY (requires a maintainer bearer token). Attach a note and flag to a marker, track or device, e.g. 'known Ra-226 check source' or 'GPS error'. Annotations are shown automatically in the results of every read tool that returns the annotated data, so repeated questions about the same false alarm get authoritative context. Pass remove_id instead to delete a wrong annotation."),
+	mcp.WithString("target_type",
+		mcp.Description("What is being annotated: 'marker' (a single measurement ID), 'track' (a bGeigie track ID) or 'device' (a device ID)"),
+		mcp.Enum(annotationMarker, annotationTrack, annotationDevice),
+	),
+	mcp.WithString("target_id",
+		mcp.Description("Marker ID, track ID (e.g. 8eh5m1) or device ID (e.g. pointcast:10042)"),
+	),
+	mcp.WithString("flag",
+		mcp.Description("Category of the annotation (default: other)"),
+		mcp.Enum(annotationFlags...),
+		mcp.DefaultString("other"),
+	),
+	mcp.WithString("note",
+		mcp.Description("The note shown to users, e.g. 'Known Ra-226 check source placed next to the detector during a demo'"),
+		mcp.MaxLength(1000),
+	),
+	mcp.WithNumber("remove_id",
+		mcp.Description("Delete the annotation with this ID instead of adding one"),
+		mcp.Min(1),
+	),
+	mcp.WithDestructiveHintAnnotation(false),
+)
+
+func handleAnnotateMeasurement(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	author, err := authenticateMaintainer(req.Header)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if removeID := req.GetInt("remove_id", 0); removeID > 0 {
+		a, err := annotations.remove(int64(removeID))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not remove annotation: %v", err)), nil
+		}
+		log.Printf("Annotation %d on %s %s removed by %s", a.ID, a.TargetType, a.TargetID, author)
+		return jsonResult(map[string]any{
+			"removed":    a,
+			"removed_by": author,
+		})
+	}
+
+	targetType := req.GetString("target_type", "")
+	targetID := strings.TrimSpace(req.GetString("target_id", ""))
+	flag := req.GetString("flag", "other")
+	note := strings.TrimSpace(req.GetString("note", ""))
+
+	switch targetType {
+	case annotationMarker, annotationTrack, annotationDevice:
+	default:
+		return mcp.NewToolResultError("target_type must be one of: marker, track, device"), nil
+	}
+	if targetID == "" {
+		return mcp.NewToolResultError("target_id is required"), nil
+	}
+	if !slices.Contains(annotationFlags, flag) {
+		return mcp.NewToolResultError("flag must be one of: " + strings.Join(annotationFlags, ", ")), nil
+	}
+	if note == "" {
+		return mcp.NewToolResultError("note is required"), nil
+	}
+	if len(note) > 1000 {
+		return mcp.NewToolResultError("note must be at most 1000 characters"), nil
+	}
+
+	a, err := annotations.add(targetType, targetID, flag, note, author)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not save annotation: %v", err)), nil
+	}
+	log.Printf("Annotation %d on %s %s added by %s (%s)", a.ID, targetType, targetID, author, flag)
+
+	return jsonResult(map[string]any{
+		"annotation":     a,
+		"all_for_target": annotations.lookup(targetType, targetID),
+		"_ai_hint":       "The annotation is saved and will appear under 'maintainer_annotations' in read tool results that include this " + targetType + ". Confirm the annotation ID to the maintainer; it is needed to remove the annotation later.",
+	})
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── calibration_drift ──────────────────────────────────────────────────────
+//
+// Geiger tubes and their electronics age, and a fixed sensor that slowly
+// reads higher or lower than the ground around it needs recalibrating. Its
+// own readings cannot show that, because the background changes too
+// (cesium decays, rain washes it out), so each period's median reading is
+// divided by the median dose rate of the bGeigie markers measured within
+// radius_m in the same period. The ratio absorbs the sensor's unit (CPM or
+// µSv/h) and its mounting height; drift is the trend of the ratio, a
+// least-squares fit of its logarithm against time, in percent per year.
+
+const (
+	defaultDriftRadiusM     = 1000
+	defaultDriftYears       = 3
+	defaultDriftThreshold   = 5.0 // percent per year
+	defaultDriftMinMarkers  = 10
+	minDriftPeriods         = 3
+	driftSignificanceFactor = 2 // drift must exceed this many standard errors
+)
+
+var driftStatuses = []string{"stable", "reading_high", "reading_low", "insufficient_data"}
+
+var calibrationDriftToolDef = mcp.NewTool("calibration_drift",
+	mcp.WithDescription("Calibration QA for fixed sensors (Pointcast, Solarcast, bGeigieZen): compares each sensor's readings with bGeigie measurements taken by other devices within radius_m, period by period, and estimates how fast the ratio drifts in percent per year. A sensor whose ratio rises is increasingly reading high against its surroundings (reading_high), one whose ratio falls is reading low. Give a device_id for one sensor, or a type to check the fleet, worst first. Needs periods with enough nearby bGeigie markers; sensors without them are reported as insufficient_data. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("device_id",
+		mcp.Description("Check only this sensor, e.g. 'pointcast:10004'"),
+	),
+	mcp.WithString("type",
+		mcp.Description("Only sensors of this type, e.g. 'Pointcast' (partial match, aliases included)"),
+	),
+	mcp.WithNumber("radius_m",
+		mcp.Description("Distance from the sensor within which bGeigie markers count as co-located (default: 1000, max: 10000)"),
+		mcp.Min(100), mcp.Max(10000),
+		mcp.DefaultNumber(defaultDriftRadiusM),
+	),
+	mcp.WithString("period",
+		mcp.Description("Length of the periods compared (default: quarter)"),
+		mcp.Enum("month", "quarter", "year"),
+		mcp.DefaultString("quarter"),
+	),
+	mcp.WithString("start_date",
+		mcp.Description("Start date in YYYY-MM-DD format (default: 3 years before end_date)"),
+	),
+	mcp.WithString("end_date",
+		mcp.Description("End date in YYYY-MM-DD format (default: today)"),
+	),
+	mcp.WithNumber("min_markers",
+		mcp.Description("Nearby bGeigie markers a period needs to be compared (default: 10)"),
+		mcp.Min(1), mcp.Max(10000),
+		mcp.DefaultNumber(defaultDriftMinMarkers),
+	),
+	mcp.WithNumber("threshold_pct_per_year",
+		mcp.Description("Drift in percent per year above which a sensor is flagged, if it is also statistically significant (default: 5)"),
+		mcp.Min(0.5), mcp.Max(100),
+		mcp.DefaultNumber(defaultDriftThreshold),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of sensors to check (default: 20, max: 200)"),
+		mcp.Min(1), mcp.Max(200),
+		mcp.DefaultNumber(20),
+	),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// driftPeriod is one period of a sensor compared with its surroundings.
+type driftPeriod struct {
+	Start      time.Time
+	Sensor     float64 // median reading, in the sensor's unit
+	Readings   int
+	Reference  float64 // median marker dose rate, µSv/h
+	Markers    int
+	RefDevices int
+}
+
+func (p driftPeriod) ratio() float64 { return p.Sensor / p.Reference }
+
+func handleCalibrationDrift(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !dbAvailable() {
+		return mcp.NewToolResultError("Database connection required for calibration_drift: it compares realtime sensor readings with the markers table"), nil
+	}
+
+	deviceID := strings.TrimSpace(req.GetString("device_id", ""))
+	sensorType := strings.TrimSpace(req.GetString("type", ""))
+	radiusM := req.GetFloat("radius_m", defaultDriftRadiusM)
+	period := req.GetString("period", "quarter")
+	minMarkers := req.GetInt("min_markers", defaultDriftMinMarkers)
+	threshold := req.GetFloat("threshold_pct_per_year", defaultDriftThreshold)
+	defLimit, maxLimit := toolLimits("calibration_drift", 20, 200)
+	limit := req.GetInt("limit", defLimit)
+
+	if radiusM < 100 || radiusM > 10000 {
+		return mcp.NewToolResultError("radius_m must be between 100 and 10000"), nil
+	}
+	if !slices.Contains([]string{"month", "quarter", "year"}, period) {
+		return mcp.NewToolResultError("period must be one of: month, quarter, year"), nil
+	}
+	if minMarkers < 1 {
+		return mcp.NewToolResultError("min_markers must be at least 1"), nil
+	}
+	if threshold < 0.5 || threshold > 100 {
+		return mcp.NewToolResultError("threshold_pct_per_year must be between 0.5 and 100"), nil
+	}
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("limit must be between 1 and %d", maxLimit)), nil
+	}
+
+	endDate := time.Now().UTC().Truncate(24 * time.Hour)
+	if s := req.GetString("end_date", ""); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return mcp.NewToolResultError("end_date must be in YYYY-MM-DD format"), nil
+		}
+		endDate = d
+	}
+	startDate := endDate.AddDate(-defaultDriftYears, 0, 0)
+	if s := req.GetString("start_date", ""); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return mcp.NewToolResultError("start_date must be in YYYY-MM-DD format"), nil
+		}
+		startDate = d
+	}
+	if !endDate.After(startDate) {
+		return mcp.NewToolResultError("end_date must be after start_date"), nil
+	}
+	endExclusive := endDate.AddDate(0, 0, 1)
+
+	sensors, err := fleetSensorsDB(ctx, sensorType)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not list sensors: %v", err)), nil
+	}
+	if deviceID != "" {
+		sensors = slices.DeleteFunc(sensors, func(s fleetSensor) bool { return s.ID != deviceID })
+		if len(sensors) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Device %s not found among the realtime sensors; use list_sensors to find device IDs", deviceID)), nil
+		}
+	}
+	total := len(sensors)
+	if len(sensors) > limit {
+		sensors = sensors[:limit]
+	}
+
+	statuses := map[string]int{}
+	for _, s := range driftStatuses {
+		statuses[s] = 0
+	}
+	devices := make([]map[string]any, 0, len(sensors))
+	for _, s := range sensors {
+		periods, err := driftPeriodsDB(ctx, s, radiusM, period, startDate, endExclusive, minMarkers)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Drift query for %s failed: %v", s.ID, err)), nil
+		}
+		entry := driftEstimate(periods, threshold)
+		entry["device_id"] = s.ID
+		entry["device_name"] = s.Name
+		entry["type"] = s.Type
+		entry["location"] = map[string]any{"latitude": s.Lat, "longitude": s.Lon}
+		sensorUnit(ctx, entry, s.Unit)
+		statuses[entry["status"].(string)]++
+		devices = append(devices, entry)
+	}
+	// Flagged sensors first, then by the size of their drift.
+	slices.SortStableFunc(devices, func(a, b map[string]any) int {
+		rank := func(m map[string]any) (int, float64) {
+			d, _ := toFloat(m["drift_pct_per_year"])
+			switch m["status"] {
+			case "reading_high", "reading_low":
+				return 0, -math.Abs(d)
+			case "stable":
+				return 1, -math.Abs(d)
+			}
+			return 2, 0
+		}
+		ra, da := rank(a)
+		rb, db := rank(b)
+		if ra != rb {
+			return ra - rb
+		}
+		switch {
+		case da < db:
+			return -1
+		case da > db:
+			return 1
+		}
+		return 0
+	})
+
+	result := map[string]any{
+		"count":     len(devices),
+		"source":    "database",
+		"by_status": statuses,
+		"devices":   devices,
+		"query": map[string]any{
+			"period":                 period,
+			"radius_m":               radiusM,
+			"start_date":             startDate.Format("2006-01-02"),
+			"end_date":               endDate.Format("2006-01-02"),
+			"min_markers":            minMarkers,
+			"threshold_pct_per_year": threshold,
+		},
+		"method":             fmt.Sprintf("For each %s, the sensor's median reading is divided by the median dose rate of bGeigie markers within %g m in the same %s; periods with fewer than %d markers are skipped. Drift is a least-squares fit of the logarithm of that ratio against time, in percent per year, with its standard error. A sensor is flagged reading_high or reading_low when the drift exceeds %g%% per year and %d standard errors, and needs at least %d compared periods.", period, radiusM, period, minMarkers, threshold, driftSignificanceFactor, minDriftPeriods),
+		"_ai_hint":           "The ratio itself is not a calibration error: a sensor on a roof or wall reads differently from bGeigies on the road, and its unit may be CPM. Only its trend matters. A drift can also come from a change around the sensor (construction, decontamination) or in where the bGeigies drive, so treat a flag as a reason to inspect the sensor, not as proof. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if deviceID != "" {
+		result["query"].(map[string]any)["device_id"] = deviceID
+	}
+	if sensorType != "" {
+		result["query"].(map[string]any)["type"] = sensorType
+	}
+	if total > len(devices) {
+		result["truncated"] = true
+		result["total_sensors"] = total
+		result["note"] = fmt.Sprintf("Only %d of %d sensors were checked; raise limit or filter by type for more.", len(devices), total)
+	}
+	if statuses["insufficient_data"] > 0 {
+		result["insufficient_data_note"] = fmt.Sprintf("Sensors with fewer than %d periods having %d nearby markers cannot be assessed; try a larger radius_m, a longer period or an earlier start_date.", minDriftPeriods, minMarkers)
+	}
+	return measurementResult(ctx, result)
+}
+
+// driftPeriodsDB compares a sensor with the markers around it, period by
+// period, keeping the periods with at least minMarkers markers.
+func driftPeriodsDB(ctx context.Context, s fleetSensor, radiusM float64, period string, start, end time.Time, minMarkers int) ([]driftPeriod, error) {
+	readings, err := queryRows(ctx, `
+		SELECT date_trunc($2, to_timestamp(measured_at)) AS period,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY value) AS median,
+			count(*) AS n
+		FROM realtime_measurements
+		WHERE device_id = $1
+			AND value > 0
+			AND measured_at >= $3
+			AND measured_at < $4
+			AND to_timestamp(measured_at) <= NOW()
+		GROUP BY 1`, s.ID, period, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, err
+	}
+	if len(readings) == 0 {
+		return nil, nil
+	}
+	// Same bbox pre-filter and ST_DWithin refinement as queryRadiationSQL.
+	markers, err := queryRows(ctx, `
+		SELECT date_trunc($4, to_timestamp(m.date)) AS period,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY m.doserate) AS median,
+			count(*) AS n,
+			count(DISTINCT m.device_id) AS devices
+		FROM markers m
+		WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint($2, $1), 4326), $3 / 111000.0)
+			AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+			AND m.doserate > 0
+			AND m.date >= $5
+			AND m.date < $6
+			AND m.id <= $7
+		GROUP BY 1`, s.Lat, s.Lon, radiusM, period, start.Unix(), end.Unix(), snapshotBound(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	reference := map[time.Time]map[string]any{}
+	for _, r := range markers {
+		if t, ok := r["period"].(time.Time); ok {
+			reference[t.UTC()] = r
+		}
+	}
+	var periods []driftPeriod
+	for _, r := range readings {
+		t, ok := r["period"].(time.Time)
+		if !ok {
+			continue
+		}
+		ref, ok := reference[t.UTC()]
+		if !ok {
+			continue
+		}
+		p := driftPeriod{Start: t.UTC()}
+		p.Sensor, _ = toFloat(r["median"])
+		p.Reference, _ = toFloat(ref["median"])
+		n, _ := toFloat(r["n"])
+		m, _ := toFloat(ref["n"])
+		d, _ := toFloat(ref["devices"])
+		p.Readings, p.Markers, p.RefDevices = int(n), int(m), int(d)
+		if p.Markers < minMarkers || p.Sensor <= 0 || p.Reference <= 0 {
+			continue
+		}
+		periods = append(periods, p)
+	}
+	slices.SortFunc(periods, func(a, b driftPeriod) int { return a.Start.Compare(b.Start) })
+	return periods, nil
+}
+
+// driftEstimate fits the trend of the sensor-to-reference ratio and
+// classifies it.
+func driftEstimate(periods []driftPeriod, threshold float64) map[string]any {
+	list := make([]map[string]any, len(periods))
+	for i, p := range periods {
+		list[i] = map[string]any{
+			"period_start":      p.Start.Format("2006-01-02"),
+			"sensor_median":     roundSignificant(p.Sensor, doseSignificantDigits),
+			"readings":          p.Readings,
+			"reference_usvh":    roundSignificant(p.Reference, doseSignificantDigits),
+			"reference_markers": p.Markers,
+			"reference_devices": p.RefDevices,
+			"ratio":             roundSignificant(p.ratio(), 4),
+		}
+	}
+	entry := map[string]any{"periods_compared": len(periods), "periods": list}
+	if len(periods) < minDriftPeriods {
+		entry["status"] = "insufficient_data"
+		return entry
+	}
+
+	// Least squares of ln(ratio) against years since the first period.
+	n := float64(len(periods))
+	xs := make([]float64, len(periods))
+	ys := make([]float64, len(periods))
+	var mx, my float64
+	for i, p := range periods {
+		xs[i] = p.Start.Sub(periods[0].Start).Hours() / (24 * 365.25)
+		ys[i] = math.Log(p.ratio())
+		mx += xs[i] / n
+		my += ys[i] / n
+	}
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+	}
+	slope := sxy / sxx
+	var ssr float64
+	for i := range xs {
+		r := ys[i] - (my + slope*(xs[i]-mx))
+		ssr += r * r
+	}
+	stderr := math.Sqrt(ssr / (n - 2) / sxx)
+	span := xs[len(xs)-1]
+
+	drift := (math.Exp(slope) - 1) * 100
+	entry["drift_pct_per_year"] = math.Round(drift*10) / 10
+	entry["drift_uncertainty_pct"] = math.Round(math.Exp(slope)*stderr*1000) / 10
+	entry["total_change_pct"] = math.Round((math.Exp(slope*span)-1)*1000) / 10
+	entry["years_covered"] = math.Round(span*10) / 10
+	entry["first_period"] = periods[0].Start.Format("2006-01-02")
+	entry["last_period"] = periods[len(periods)-1].Start.Format("2006-01-02")
+
+	status := "stable"
+	if math.Abs(drift) > threshold && math.Abs(slope) > driftSignificanceFactor*stderr {
+		status = "reading_high"
+		if drift < 0 {
+			status = "reading_low"
+		}
+	}
+	entry["status"] = status
+	return entry
+}
@@ -0,0 +1,727 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── compare_areas ──────────────────────────────────────────────────────────
+//
+// "Is it higher here than there?" and "has it come down since 2012?" are
+// answered by eye from two averages far too often. compare_areas draws the
+// dose rates of two areas (or one area in two time windows) and tests the
+// difference: Mann-Whitney U on the values, which assumes nothing about
+// their distribution, and Welch's t-test on their logarithms, since dose
+// rates are close to log-normal. Each comes with an effect size, because
+// with thousands of readings a trivial difference is still "significant".
+//
+// Consecutive markers of a drive are a few metres apart and strongly
+// correlated, so counting each as an independent sample would overstate
+// the evidence. By default the samples are averages over cells of cell_m
+// metres; sample=markers uses the raw markers.
+
+const (
+	defaultCompareCellM   = 100.0
+	defaultCompareSamples = 5000
+	maxCompareSamples     = 20000
+	minCompareSamples     = 5
+)
+
+const compareDetectorCaveat = "Different detectors and mounting heights in the two areas can shift the dose rates by 10-20%."
+
+var compareAreasToolDef = mcp.NewTool("compare_areas",
+	mcp.WithDescription("Statistically compare the dose rates of two areas, or of one area in two time windows. Returns the distribution of each (median, mean, geometric mean, percentiles), a Mann-Whitney U test and a Welch t-test on log dose rates with p-values and effect sizes, and plain-language interpretation fields. Area B defaults to area A's box and time window, so giving only b_start_date/b_end_date compares periods. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	withCompareAreaParams("a", "A", true),
+	withCompareAreaParams("b", "B", false),
+	mcp.WithString("sample",
+		mcp.Description("Sampling unit: 'cells' averages markers over cell_m cells first, so the many markers of one drive past a spot count once; 'markers' uses every marker (default: cells)"),
+		mcp.Enum("cells", "markers"),
+		mcp.DefaultString("cells"),
+	),
+	mcp.WithNumber("cell_m",
+		mcp.Description("Cell size in metres for sample=cells (default: 100)"),
+		mcp.Min(10), mcp.Max(10000),
+		mcp.DefaultNumber(defaultCompareCellM),
+	),
+	mcp.WithNumber("max_samples",
+		mcp.Description("Maximum samples drawn per area (default: 5000, max: 20000)"),
+		mcp.Min(minCompareSamples), mcp.Max(maxCompareSamples),
+		mcp.DefaultNumber(defaultCompareSamples),
+	),
+	mcp.WithNumber("alpha",
+		mcp.Description("Significance level for the interpretation (default: 0.05)"),
+		mcp.Min(0.0001), mcp.Max(0.2),
+		mcp.DefaultNumber(0.05),
+	),
+	withHarmonizeParam(),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// withCompareAreaParams adds the box, saved region and time window of one
+// area, with the given parameter prefix.
+func withCompareAreaParams(prefix, label string, first bool) mcp.ToolOption {
+	fallback := ""
+	if !first {
+		fallback = " (default: area A's)"
+	}
+	opts := []mcp.ToolOption{
+		mcp.WithString(prefix+"_region",
+			mcp.Description(fmt.Sprintf("Name of a saved region for area %s; fills in its bounding box", label)),
+		),
+	}
+	for _, p := range []struct{ name, desc string }{
+		{"min_lat", "Southern boundary latitude"},
+		{"max_lat", "Northern boundary latitude"},
+		{"min_lon", "Western boundary longitude"},
+		{"max_lon", "Eastern boundary longitude"},
+	} {
+		opts = append(opts, mcp.WithNumber(prefix+"_"+p.name,
+			mcp.Description(fmt.Sprintf("%s of area %s%s", p.desc, label, fallback)),
+		))
+	}
+	opts = append(opts,
+		mcp.WithString(prefix+"_start_date",
+			mcp.Description(fmt.Sprintf("Only measurements of area %s captured on or after this day (YYYY-MM-DD, UTC)%s", label, fallback)),
+		),
+		mcp.WithString(prefix+"_end_date",
+			mcp.Description(fmt.Sprintf("Only measurements of area %s captured on or before this day (YYYY-MM-DD, UTC)%s", label, fallback)),
+		),
+	)
+	return func(t *mcp.Tool) {
+		for _, opt := range opts {
+			opt(t)
+		}
+	}
+}
+
+// compareArea is one side of a comparison.
+type compareArea struct {
+	label                          string
+	region                         string
+	minLat, maxLat, minLon, maxLon float64
+	start, end                     time.Time
+}
+
+// readCompareArea reads the parameters of one area. Missing values are
+// taken from fallback (area A) when it is given.
+func readCompareArea(req mcp.CallToolRequest, prefix string, fallback *compareArea) (compareArea, error) {
+	a := compareArea{label: strings.ToUpper(prefix)}
+	if fallback != nil {
+		a = *fallback
+		a.label = strings.ToUpper(prefix)
+	}
+	args := req.GetArguments()
+	if name := req.GetString(prefix+"_region", ""); name != "" {
+		r, _, err := applyRegion(map[string]any{"region": name})
+		if err != nil {
+			return a, err
+		}
+		a.region = r.Name
+		a.minLat, a.maxLat, a.minLon, a.maxLon = r.MinLat, r.MaxLat, r.MinLon, r.MaxLon
+	}
+	box := 0
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{{"min_lat", &a.minLat}, {"max_lat", &a.maxLat}, {"min_lon", &a.minLon}, {"max_lon", &a.maxLon}} {
+		if _, ok := args[prefix+"_"+p.name]; ok {
+			*p.dst = req.GetFloat(prefix+"_"+p.name, 0)
+			box++
+		}
+	}
+	if box > 0 && req.GetString(prefix+"_region", "") == "" {
+		a.region = ""
+	}
+	if fallback == nil && box < 4 && a.region == "" {
+		return a, fmt.Errorf("area A needs a_region or all of a_min_lat, a_max_lat, a_min_lon and a_max_lon")
+	}
+	if a.minLat < -90 || a.maxLat > 90 || a.minLon < -180 || a.maxLon > 180 || a.minLat >= a.maxLat || a.minLon >= a.maxLon {
+		return a, fmt.Errorf("area %s: the bounding box must have min_lat < max_lat and min_lon < max_lon within valid coordinates", a.label)
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"start_date", &a.start}, {"end_date", &a.end}} {
+		if v := req.GetString(prefix+"_"+p.name, ""); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return a, fmt.Errorf("%s_%s must be in YYYY-MM-DD format", prefix, p.name)
+			}
+			*p.dst = t
+		}
+	}
+	if !a.start.IsZero() && !a.end.IsZero() && a.end.Before(a.start) {
+		return a, fmt.Errorf("%s_end_date must not be before %s_start_date", prefix, prefix)
+	}
+	return a, nil
+}
+
+// conds are the WHERE conditions selecting the area's markers, for DuckDB
+// and Postgres alike.
+func (a compareArea) conds() []string {
+	conds := []string{
+		"doserate > 0 AND doserate < 1000",
+		fmt.Sprintf("lat BETWEEN %.8f AND %.8f AND lon BETWEEN %.8f AND %.8f", a.minLat, a.maxLat, a.minLon, a.maxLon),
+	}
+	if !a.start.IsZero() {
+		conds = append(conds, fmt.Sprintf("date >= %d", a.start.Unix()))
+	}
+	if !a.end.IsZero() {
+		conds = append(conds, fmt.Sprintf("date < %d", a.end.AddDate(0, 0, 1).Unix()))
+	}
+	return conds
+}
+
+// cellSize is the cell of cellM metres in degrees at the area's latitude.
+func (a compareArea) cellSize(cellM float64) (dLat, dLon float64) {
+	dLat = cellM / 111320
+	dLon = dLat / max(math.Cos((a.minLat+a.maxLat)/2*math.Pi/180), 0.01)
+	return dLat, dLon
+}
+
+func (a compareArea) describe() map[string]any {
+	d := map[string]any{
+		"bbox": map[string]any{"min_lat": a.minLat, "max_lat": a.maxLat, "min_lon": a.minLon, "max_lon": a.maxLon},
+	}
+	if a.region != "" {
+		d["region"] = a.region
+	}
+	if !a.start.IsZero() || !a.end.IsZero() {
+		window := map[string]any{}
+		if !a.start.IsZero() {
+			window["start_date"] = a.start.Format("2006-01-02")
+		}
+		if !a.end.IsZero() {
+			window["end_date"] = a.end.Format("2006-01-02")
+		}
+		d["time_window"] = window
+	}
+	return d
+}
+
+// compareSamplesQuery draws the samples of an area. It is written for
+// DuckDB against postgres_db and is valid Postgres once the
+// postgres_db.public. prefix is dropped (as radiationStatsQuery). With h
+// the dose rates are harmonized. Both dialects have random(), so an area
+// with more samples than limit yields a random subset rather than the rows
+// the scan returns first; one row past limit tells compareAreasResult the
+// cap was hit.
+func compareSamplesQuery(a compareArea, cells bool, cellM float64, limit int, extra []string, h *harmonizer) string {
+	where := strings.Join(append(a.conds(), extra...), " AND ")
+	dose := h.doseSQL("doserate", "detector")
+	if !cells {
+		return fmt.Sprintf(`
+			SELECT %s AS v
+			FROM postgres_db.public.markers
+			WHERE %s
+			ORDER BY random()
+			LIMIT %d`, dose, where, limit+1)
+	}
+	dLat, dLon := a.cellSize(cellM)
+	return fmt.Sprintf(`
+		SELECT AVG(%s) AS v
+		FROM postgres_db.public.markers
+		WHERE %s
+		GROUP BY floor(lat / %.12f), floor(lon / %.12f)
+		ORDER BY random()
+		LIMIT %d`, dose, where, dLat, dLon, limit+1)
+}
+
+// compareDetectorsQuery counts the readings of an area per detector, for
+// the provenance of a harmonized comparison.
+func compareDetectorsQuery(a compareArea, extra []string) string {
+	return detectorCountsQuery(strings.Join(append(a.conds(), extra...), " AND "))
+}
+
+func samplesFromRows(rows []map[string]any) []float64 {
+	vs := make([]float64, 0, len(rows))
+	for _, r := range rows {
+		if v, ok := toFloat(r["v"]); ok {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// samplesFromMarkers draws the samples of an area from /get_markers
+// results, with the same filters and random subset as compareSamplesQuery.
+func samplesFromMarkers(a compareArea, markers []map[string]any, cells bool, cellM float64, limit int, h *harmonizer) []float64 {
+	dLat, dLon := a.cellSize(cellM)
+	type sum struct {
+		total float64
+		n     int
+	}
+	sums := map[[2]int64]*sum{}
+	var vs []float64
+	for _, m := range markers {
+		dose, ok1 := toFloat(m["doseRate"])
+		lat, ok2 := toFloat(m["lat"])
+		lon, ok3 := toFloat(m["lon"])
+		if !ok1 || !ok2 || !ok3 || dose <= 0 || dose >= 1000 {
+			continue
+		}
+		if lat < a.minLat || lat > a.maxLat || lon < a.minLon || lon > a.maxLon {
+			continue
+		}
+		if !a.start.IsZero() || !a.end.IsZero() {
+			date, ok := toFloat(m["date"])
+			if !ok || (!a.start.IsZero() && date < float64(a.start.Unix())) ||
+				(!a.end.IsZero() && date >= float64(a.end.AddDate(0, 0, 1).Unix())) {
+				continue
+			}
+		}
+		detector, _ := m["detector"].(string)
+		dose *= h.factor(detector)
+		if !cells {
+			vs = append(vs, dose)
+			continue
+		}
+		key := [2]int64{int64(math.Floor(lat / dLat)), int64(math.Floor(lon / dLon))}
+		s := sums[key]
+		if s == nil {
+			s = &sum{}
+			sums[key] = s
+		}
+		s.total += dose
+		s.n++
+	}
+	for _, s := range sums {
+		vs = append(vs, s.total/float64(s.n))
+	}
+	if len(vs) > limit {
+		rand.Shuffle(len(vs), func(i, j int) { vs[i], vs[j] = vs[j], vs[i] })
+		vs = vs[:limit+1]
+	}
+	return vs
+}
+
+func handleCompareAreas(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = harmonizeContext(ctx, req)
+	a, err := readCompareArea(req, "a", nil)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	b, err := readCompareArea(req, "b", &a)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if a.minLat == b.minLat && a.maxLat == b.maxLat && a.minLon == b.minLon && a.maxLon == b.maxLon &&
+		a.start.Equal(b.start) && a.end.Equal(b.end) {
+		return mcp.NewToolResultError("Areas A and B are the same box and time window; give area B its own box (b_*) or time window (b_start_date/b_end_date)"), nil
+	}
+	sample := req.GetString("sample", "cells")
+	if sample != "cells" && sample != "markers" {
+		return mcp.NewToolResultError("sample must be 'cells' or 'markers'"), nil
+	}
+	cells := sample == "cells"
+	cellM := req.GetFloat("cell_m", defaultCompareCellM)
+	if cellM < 10 || cellM > 10000 {
+		return mcp.NewToolResultError("cell_m must be between 10 and 10000"), nil
+	}
+	limit := req.GetInt("max_samples", defaultCompareSamples)
+	if limit < minCompareSamples || limit > maxCompareSamples {
+		return mcp.NewToolResultError(fmt.Sprintf("max_samples must be between %d and %d", minCompareSamples, maxCompareSamples)), nil
+	}
+	alpha := req.GetFloat("alpha", 0.05)
+	if alpha <= 0 || alpha > 0.2 {
+		return mcp.NewToolResultError("alpha must be between 0.0001 and 0.2"), nil
+	}
+
+	// One harmonizer per area, so the provenance counts each separately;
+	// the source functions reset them, since the router may try several.
+	var hs [2]*harmonizer
+	resetHarmonizers := func() {
+		hs[0], hs[1] = newHarmonizer(ctx), newHarmonizer(ctx)
+	}
+	build := func(va, vb []float64, source string) (*mcp.CallToolResult, error) {
+		res, err := compareAreasResult(a, b, va, vb, sample, cellM, limit, alpha, source)
+		if err != nil || hs[0] == nil {
+			return res, err
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			m["harmonization"] = map[string]any{
+				"area_a": hs[0].summary(),
+				"area_b": hs[1].summary(),
+			}
+			caveats, _ := m["caveats"].([]any)
+			for i, c := range caveats {
+				if c == compareDetectorCaveat {
+					caveats[i] = "Dose rates were harmonized to the reference detector where a correction is known (see harmonization); mounting heights and uncorrected detectors can still shift them."
+				}
+			}
+		}), nil
+	}
+	return routeQuery(ctx, queryAggregate, sourceFuncs{
+		duckdb: func() (*mcp.CallToolResult, error) {
+			resetHarmonizers()
+			var vs [2][]float64
+			extra := []string{fmt.Sprintf("id <= %d", snapshotBound(ctx))}
+			for i, area := range []compareArea{a, b} {
+				rows, err := duckQueryRows(ctx, compareSamplesQuery(area, cells, cellM, limit, extra, hs[i]))
+				if err == nil && hs[i] != nil {
+					var counts []map[string]any
+					if counts, err = duckQueryRows(ctx, compareDetectorsQuery(area, extra)); err == nil {
+						hs[i].addCounts(counts)
+					}
+				}
+				if err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("Comparison query failed: %v", err)), nil
+				}
+				vs[i] = samplesFromRows(rows)
+			}
+			return build(vs[0], vs[1], "duckdb_postgres_attach")
+		},
+		db: func() (*mcp.CallToolResult, error) {
+			resetHarmonizers()
+			var vs [2][]float64
+			for i, area := range []compareArea{a, b} {
+				extra := []string{
+					fmt.Sprintf("geom && ST_MakeEnvelope(%.8f, %.8f, %.8f, %.8f, 4326)", area.minLon, area.minLat, area.maxLon, area.maxLat),
+					fmt.Sprintf("id <= %d", snapshotBound(ctx)),
+				}
+				query := strings.ReplaceAll(compareSamplesQuery(area, cells, cellM, limit, extra, hs[i]), "postgres_db.public.", "")
+				rows, err := queryRows(ctx, query)
+				if err == nil && hs[i] != nil {
+					var counts []map[string]any
+					if counts, err = queryRows(ctx, strings.ReplaceAll(compareDetectorsQuery(area, extra), "postgres_db.public.", "")); err == nil {
+						hs[i].addCounts(counts)
+					}
+				}
+				if err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("Comparison query failed: %v", err)), nil
+				}
+				vs[i] = samplesFromRows(rows)
+			}
+			return build(vs[0], vs[1], "database")
+		},
+		api: func() (*mcp.CallToolResult, error) {
+			resetHarmonizers()
+			var vs [2][]float64
+			for i, area := range []compareArea{a, b} {
+				markers, err := client.GetMarkers(ctx, area.minLat, area.minLon, area.maxLat, area.maxLon)
+				if err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				vs[i] = samplesFromMarkers(area, markers, cells, cellM, limit, hs[i])
+			}
+			return build(vs[0], vs[1], "api")
+		},
+	})
+}
+
+// compareAreasResult tests va against vb. The samples may hold one value
+// past limit, which marks an area whose samples were capped.
+func compareAreasResult(a, b compareArea, va, vb []float64, sample string, cellM float64, limit int, alpha float64, source string) (*mcp.CallToolResult, error) {
+	limitedA, limitedB := len(va) > limit, len(vb) > limit
+	va, vb = va[:min(len(va), limit)], vb[:min(len(vb), limit)]
+	for _, s := range []struct {
+		area compareArea
+		vs   []float64
+	}{{a, va}, {b, vb}} {
+		if len(s.vs) < minCompareSamples {
+			return mcp.NewToolResultError(fmt.Sprintf("Area %s has %d samples; at least %d are needed for a comparison. Widen the box or time window, or use sample=markers.", s.area.label, len(s.vs), minCompareSamples)), nil
+		}
+	}
+	sa, sb := describeSamples(va), describeSamples(vb)
+	mw := mannWhitney(va, vb)
+	wt := welchLogT(va, vb)
+
+	medA, medB := sa["median_usvh"].(float64), sb["median_usvh"].(float64)
+	ratio := medB / medA
+	mwEffect := effectLabel(math.Abs(mw.rankBiserial), 0.1, 0.3, 0.5)
+	dEffect := effectLabel(math.Abs(wt.cohensD), 0.2, 0.5, 0.8)
+	significant := mw.p < alpha
+
+	direction := "higher"
+	if medB < medA {
+		direction = "lower"
+	}
+	var summary string
+	switch {
+	case !significant:
+		summary = fmt.Sprintf("No statistically significant difference: the median of area B (%.3g µSv/h) and area A (%.3g µSv/h) differ by a factor of %.2f, which chance variation can explain (Mann-Whitney %s, alpha %g).", medB, medA, ratio, formatP(mw.p), alpha)
+	default:
+		summary = fmt.Sprintf("Area B is significantly %s than area A: median %.3g vs %.3g µSv/h, a factor of %.2f (Mann-Whitney %s). The effect is %s: a sample from B is higher than one from A %.0f%% of the time.", direction, medB, medA, ratio, formatP(mw.p), mwEffect, mw.probBHigher*100)
+	}
+	if significant && mwEffect == "negligible" {
+		summary += " With this many samples even a negligible difference is significant; it has no practical importance."
+	}
+	annual := (medB - medA) * 24 * 365 / 1000
+
+	sideA, sideB := a.describe(), b.describe()
+	sideA["statistics"], sideB["statistics"] = sa, sb
+	sideA["sample_limited"], sideB["sample_limited"] = limitedA, limitedB
+
+	sampling := map[string]any{"unit": sample}
+	if sample == "cells" {
+		sampling["cell_m"] = cellM
+		sampling["note"] = fmt.Sprintf("Each sample is the average dose rate of one %g m cell, so repeated drives past the same spot count once.", cellM)
+	} else {
+		sampling["note"] = "Each sample is one marker. Neighbouring markers of a drive are correlated, so p-values are optimistic; prefer sample=cells."
+	}
+	sampling["max_samples"] = limit
+	if limitedA || limitedB {
+		sampling["limit_note"] = fmt.Sprintf("An area marked sample_limited has more than %d samples; a random subset of %d was tested, so repeated calls can differ slightly.", limit, limit)
+	}
+
+	result := map[string]any{
+		"area_a":   sideA,
+		"area_b":   sideB,
+		"sampling": sampling,
+		"tests": map[string]any{
+			"mann_whitney_u": map[string]any{
+				"u":                    mw.u,
+				"z":                    roundSignificant(mw.z, 4),
+				"p_value":              mw.p,
+				"rank_biserial":        roundSignificant(mw.rankBiserial, 3),
+				"probability_b_higher": roundSignificant(mw.probBHigher, 3),
+				"effect_size":          mwEffect,
+				"note":                 "Two-sided, normal approximation with tie correction. rank_biserial runs from -1 (B always lower) to 1 (B always higher).",
+			},
+			"welch_t_log": map[string]any{
+				"t":           roundSignificant(wt.t, 4),
+				"df":          roundSignificant(wt.df, 4),
+				"p_value":     wt.p,
+				"cohens_d":    roundSignificant(wt.cohensD, 3),
+				"effect_size": dEffect,
+				"note":        "Two-sided Welch t-test on natural logarithms of the dose rates, i.e. a comparison of geometric means. cohens_d uses the pooled standard deviation of the logs.",
+			},
+		},
+		"interpretation": map[string]any{
+			"summary":                    summary,
+			"significant":                significant,
+			"alpha":                      alpha,
+			"direction":                  direction,
+			"ratio_of_medians":           roundSignificant(ratio, 3),
+			"difference_of_medians_usvh": roundSignificant(medB-medA, 4),
+			"effect_size":                mwEffect,
+			"annual_dose_difference_msv": roundSignificant(annual, 3),
+			"annual_dose_note":           "Difference of the medians over a full year (8,760 hours) outdoors, in mSv; time indoors lowers it.",
+		},
+		"caveats": []string{
+			"Drives follow roads, so the samples describe the measured routes rather than the whole area.",
+			compareDetectorCaveat,
+			"A significant result says the difference is unlikely to be chance in these samples; it does not identify its cause.",
+		},
+		"source":             source,
+		"_ai_hint":           "Lead with interpretation.summary. Report the p-value together with the effect size and the ratio of medians; a significant p-value with a negligible effect means no practical difference. Mention the caveats when drawing conclusions about contamination. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	return jsonResult(result)
+}
+
+// describeSamples summarizes the dose rates of one area.
+func describeSamples(vs []float64) map[string]any {
+	sorted := slices.Clone(vs)
+	slices.Sort(sorted)
+	var sum, logSum float64
+	for _, v := range sorted {
+		sum += v
+		logSum += math.Log(v)
+	}
+	n := float64(len(sorted))
+	return map[string]any{
+		"samples":             len(sorted),
+		"median_usvh":         quantileSorted(sorted, 0.5),
+		"mean_usvh":           sum / n,
+		"geometric_mean_usvh": math.Exp(logSum / n),
+		"p10_usvh":            quantileSorted(sorted, 0.1),
+		"p90_usvh":            quantileSorted(sorted, 0.9),
+		"min_usvh":            sorted[0],
+		"max_usvh":            sorted[len(sorted)-1],
+	}
+}
+
+// quantileSorted interpolates the q quantile of sorted values.
+func quantileSorted(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+type mannWhitneyResult struct {
+	u, z, p                   float64
+	rankBiserial, probBHigher float64
+}
+
+// mannWhitney runs the two-sided Mann-Whitney U test of b against a, with
+// mid-ranks for ties and the tie-corrected normal approximation.
+func mannWhitney(a, b []float64) mannWhitneyResult {
+	type obs struct {
+		v   float64
+		inB bool
+	}
+	all := make([]obs, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, obs{v, false})
+	}
+	for _, v := range b {
+		all = append(all, obs{v, true})
+	}
+	slices.SortFunc(all, func(x, y obs) int {
+		switch {
+		case x.v < y.v:
+			return -1
+		case x.v > y.v:
+			return 1
+		}
+		return 0
+	})
+
+	var rankB, tieSum float64
+	for i := 0; i < len(all); {
+		j := i
+		for j+1 < len(all) && all[j+1].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			if all[k].inB {
+				rankB += rank
+			}
+		}
+		t := float64(j - i + 1)
+		tieSum += t*t*t - t
+		i = j + 1
+	}
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	n := n1 + n2
+	uB := rankB - n2*(n2+1)/2
+	mu := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - tieSum/(n*(n-1))))
+	res := mannWhitneyResult{
+		u:            uB,
+		probBHigher:  uB / (n1 * n2),
+		rankBiserial: 2*uB/(n1*n2) - 1,
+		p:            1,
+	}
+	if sigma > 0 {
+		diff := math.Abs(uB-mu) - 0.5 // continuity correction
+		res.z = math.Copysign(max(diff, 0)/sigma, uB-mu)
+		res.p = math.Erfc(math.Abs(res.z) / math.Sqrt2)
+	}
+	return res
+}
+
+type welchResult struct {
+	t, df, p, cohensD float64
+}
+
+// welchLogT runs a two-sided Welch t-test of log(b) against log(a).
+func welchLogT(a, b []float64) welchResult {
+	meanVar := func(vs []float64) (mean, variance float64) {
+		for _, v := range vs {
+			mean += math.Log(v)
+		}
+		mean /= float64(len(vs))
+		for _, v := range vs {
+			d := math.Log(v) - mean
+			variance += d * d
+		}
+		return mean, variance / float64(len(vs)-1)
+	}
+	ma, va := meanVar(a)
+	mb, vb := meanVar(b)
+	n1, n2 := float64(len(a)), float64(len(b))
+
+	res := welchResult{p: 1}
+	pooled := math.Sqrt(((n1-1)*va + (n2-1)*vb) / (n1 + n2 - 2))
+	if pooled > 0 {
+		res.cohensD = (mb - ma) / pooled
+	}
+	se2 := va/n1 + vb/n2
+	if se2 == 0 {
+		return res
+	}
+	res.t = (mb - ma) / math.Sqrt(se2)
+	res.df = se2 * se2 / ((va/n1)*(va/n1)/(n1-1) + (vb/n2)*(vb/n2)/(n2-1))
+	res.p = regIncBeta(res.df/(res.df+res.t*res.t), res.df/2, 0.5)
+	return res
+}
+
+// regIncBeta is the regularized incomplete beta function I_x(a, b), by the
+// continued fraction of Numerical Recipes (betacf). I_{df/(df+t²)}(df/2,
+// 1/2) is the two-sided p-value of Student's t.
+func regIncBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a + b)
+	lb, _ := math.Lgamma(a)
+	lc, _ := math.Lgamma(b)
+	front := math.Exp(la - lb - lc + a*math.Log(x) + b*math.Log(1-x))
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaCF(1-x, b, a)/b
+	}
+	return front * betaCF(x, a, b) / a
+}
+
+func betaCF(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		for _, num := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < 1e-12 {
+			break
+		}
+	}
+	return h
+}
+
+// effectLabel names an effect size by the conventional thresholds.
+func effectLabel(v, small, medium, large float64) string {
+	switch {
+	case v < small:
+		return "negligible"
+	case v < medium:
+		return "small"
+	case v < large:
+		return "medium"
+	}
+	return "large"
+}
+
+// formatP writes a p-value for a sentence.
+func formatP(p float64) string {
+	if p < 0.001 {
+		return "p < 0.001"
+	}
+	return fmt.Sprintf("p = %.3f", p)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var continueResultToolDef = mcp.NewTool("continue_result",
+	mcp.WithDescription("Fetch the next page of a result that was truncated to fit the response size budget. Pass the next_cursor from the truncated result. Cursors expire after 15 minutes and can be used once."),
+	mcp.WithString("cursor",
+		mcp.Description("The next_cursor value from a truncated result"),
+		mcp.Required(),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleContinueResult(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	cursor := strings.TrimSpace(req.GetString("cursor", ""))
+	p, ok := resultPages.take(cursor)
+	if !ok {
+		return mcp.NewToolResultError("Unknown or expired cursor. Re-run the original tool call with a smaller limit or narrower filters."), nil
+	}
+
+	m := map[string]any{
+		"tool":     p.tool,
+		p.key:      p.rows,
+		"offset":   p.offset,
+		"_ai_hint": fmt.Sprintf("Continuation of a truncated %s result, starting at row %d.", p.tool, p.offset+1),
+	}
+	limit := budgets.limit(ctx)
+	if limit <= 0 {
+		annotateTruncation(m, p.key, len(p.rows), 0, "")
+		resumePageCursor(m, p.tool, p.resume)
+		return jsonResult(m)
+	}
+
+	page, n := fitRows(m, p.key, p.rows, limit)
+	if page == nil {
+		return mcp.NewToolResultError("A single row is larger than the response size budget."), nil
+	}
+	next := ""
+	if n < len(p.rows) {
+		next = resultPages.park(p.tool, p.key, p.rows[n:], p.offset+n, p.resume)
+	}
+	annotateTruncation(page, p.key, n, len(p.rows)-n, next)
+	if next == "" {
+		resumePageCursor(page, p.tool, p.resume)
+	}
+	return mcp.NewToolResultText(encodeBudgeted(page)), nil
+}
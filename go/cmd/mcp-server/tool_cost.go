@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── Tool cost hints ────────────────────────────────────────────────────────
+//
+// Every tool advertises a latency class and a result-size class so a model
+// can prefer the cheap option (radiation_stats over pulling raw rows, say).
+// The classes start from the expectations below and switch to observed
+// medians once a tool has been called often enough. They are attached to
+// tools/list in two places: the tool's _meta under "safecast/cost", and a
+// one-line "Cost:" suffix on the description for clients that ignore _meta.
+
+const (
+	latencyFast   = "fast"   // typically under 500 ms
+	latencyMedium = "medium" // 0.5–3 s
+	latencySlow   = "slow"   // over 3 s
+
+	sizeSmall  = "small"  // under 4 KB
+	sizeMedium = "medium" // 4–64 KB
+	sizeLarge  = "large"  // over 64 KB
+)
+
+// costSampleSize is how many recent calls feed the observed medians, and
+// costMinSamples how many are needed before they replace the expectation.
+const (
+	costSampleSize = 100
+	costMinSamples = 10
+)
+
+type toolCost struct {
+	Latency string
+	Size    string
+}
+
+// expectedToolCosts is the starting point before any calls are observed.
+var expectedToolCosts = map[string]toolCost{
+	"ping":                      {latencyFast, sizeSmall},
+	"query_radiation":           {latencyMedium, sizeMedium},
+	"nearest_readings":          {latencyMedium, sizeMedium},
+	"search_area":               {latencyMedium, sizeLarge},
+	"export_csv":                {latencyMedium, sizeSmall},
+	"list_tracks":               {latencyMedium, sizeMedium},
+	"get_track":                 {latencySlow, sizeLarge},
+	"track_profile":             {latencySlow, sizeMedium},
+	"track_dose_exposure":       {latencySlow, sizeSmall},
+	"dose_contours":             {latencySlow, sizeLarge},
+	"aggregate_area":            {latencySlow, sizeLarge},
+	"compare_areas":             {latencySlow, sizeSmall},
+	"device_history":            {latencyMedium, sizeLarge},
+	"get_spectrum":              {latencyFast, sizeLarge},
+	"list_spectra":              {latencyMedium, sizeMedium},
+	"spectrum_roi_rate":         {latencyMedium, sizeMedium},
+	"spectrum_dose_check":       {latencyMedium, sizeMedium},
+	"radiation_info":            {latencyFast, sizeSmall},
+	"list_supported_regions":    {latencyFast, sizeSmall},
+	"locate_point":              {latencyFast, sizeSmall},
+	"search_safecast":           {latencyMedium, sizeSmall},
+	"resolve_timerange":         {latencyFast, sizeSmall},
+	"server_changelog":          {latencyFast, sizeSmall},
+	"db_info":                   {latencyFast, sizeSmall},
+	"service_health":            {latencyFast, sizeSmall},
+	"list_sensors":              {latencyMedium, sizeMedium},
+	"sensor_current":            {latencyFast, sizeSmall},
+	"sensor_history":            {latencyMedium, sizeLarge},
+	"calibration_drift":         {latencySlow, sizeMedium},
+	"cross_validate_sensor":     {latencySlow, sizeMedium},
+	"query_analytics":           {latencySlow, sizeMedium},
+	"radiation_stats":           {latencySlow, sizeSmall},
+	"query_duckdb_logs":         {latencyFast, sizeMedium},
+	"query_extreme_readings":    {latencySlow, sizeSmall},
+	"top_uploaders":             {latencySlow, sizeSmall},
+	"recent_uploads":            {latencyFast, sizeMedium},
+	"search_tracks_by_location": {latencySlow, sizeMedium},
+	"nearest_tracks":            {latencyMedium, sizeMedium},
+	"find_duplicate_uploads":    {latencySlow, sizeMedium},
+	"annotate_measurement":      {latencyFast, sizeSmall},
+	"save_region":               {latencyFast, sizeSmall},
+	"save_query":                {latencyFast, sizeSmall},
+	"list_saved":                {latencyFast, sizeSmall},
+	"run_saved_query":           {latencyMedium, sizeMedium},
+	"latest_report":             {latencyFast, sizeMedium},
+	"schedule_report":           {latencyMedium, sizeSmall},
+	"manage_notifications":      {latencyFast, sizeSmall},
+	"analytics_maintenance":     {latencySlow, sizeSmall},
+	"continue_result":           {latencyFast, sizeLarge},
+	"clear_session_cache":       {latencyFast, sizeSmall},
+	"self_test":                 {latencySlow, sizeMedium},
+	"replay_query":              {latencySlow, sizeMedium},
+}
+
+func latencyClass(d time.Duration) string {
+	switch {
+	case d < 500*time.Millisecond:
+		return latencyFast
+	case d < 3*time.Second:
+		return latencyMedium
+	default:
+		return latencySlow
+	}
+}
+
+func sizeClass(bytes int) string {
+	switch {
+	case bytes < 4*1024:
+		return sizeSmall
+	case bytes < 64*1024:
+		return sizeMedium
+	default:
+		return sizeLarge
+	}
+}
+
+// costSamples is a ring of recent (duration, size) observations for a tool.
+type costSamples struct {
+	durations []time.Duration
+	sizes     []int
+	next      int
+	total     int64
+}
+
+type toolCostTracker struct {
+	mu    sync.Mutex
+	tools map[string]*costSamples
+}
+
+var toolCosts = &toolCostTracker{tools: map[string]*costSamples{}}
+
+// observe records one call. Called from instrument().
+func (t *toolCostTracker) observe(name string, d time.Duration, resultBytes int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.tools[name]
+	if !ok {
+		s = &costSamples{}
+		t.tools[name] = s
+	}
+	if len(s.durations) < costSampleSize {
+		s.durations = append(s.durations, d)
+		s.sizes = append(s.sizes, resultBytes)
+	} else {
+		s.durations[s.next] = d
+		s.sizes[s.next] = resultBytes
+	}
+	s.next = (s.next + 1) % costSampleSize
+	s.total++
+}
+
+// costInfo is what gets published for one tool.
+type costInfo struct {
+	toolCost
+	Source       string // "observed" or "expected"
+	Calls        int64
+	MedianMs     int64
+	MedianBytes  int
+	HaveObserved bool
+}
+
+func (t *toolCostTracker) info(name string) costInfo {
+	exp, ok := expectedToolCosts[name]
+	if !ok {
+		exp = toolCost{latencyMedium, sizeMedium}
+	}
+	ci := costInfo{toolCost: exp, Source: "expected"}
+
+	t.mu.Lock()
+	s, ok := t.tools[name]
+	var durations []time.Duration
+	var sizes []int
+	if ok {
+		durations = append(durations, s.durations...)
+		sizes = append(sizes, s.sizes...)
+		ci.Calls = s.total
+	}
+	t.mu.Unlock()
+
+	if len(durations) < costMinSamples {
+		return ci
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	sort.Ints(sizes)
+	medD, medS := durations[len(durations)/2], sizes[len(sizes)/2]
+
+	ci.toolCost = toolCost{latencyClass(medD), sizeClass(medS)}
+	ci.Source = "observed"
+	ci.MedianMs = medD.Milliseconds()
+	ci.MedianBytes = medS
+	ci.HaveObserved = true
+	return ci
+}
+
+// meta renders the "safecast/cost" _meta entry.
+func (ci costInfo) meta() map[string]any {
+	m := map[string]any{
+		"latency":     ci.Latency,
+		"result_size": ci.Size,
+		"source":      ci.Source,
+	}
+	if ci.HaveObserved {
+		m["observed_calls"] = ci.Calls
+		m["median_ms"] = ci.MedianMs
+		m["median_bytes"] = ci.MedianBytes
+	}
+	return m
+}
+
+func (ci costInfo) descriptionSuffix() string {
+	return fmt.Sprintf("\n\nCost: latency %s, result size %s (%s).", ci.Latency, ci.Size, ci.Source)
+}
+
+// annotateToolCosts is an AfterListTools hook. The result holds copies of
+// the registered tools, so editing them here does not touch the registry.
+func annotateToolCosts(ctx context.Context, id any, req *mcp.ListToolsRequest, result *mcp.ListToolsResult) {
+	for i := range result.Tools {
+		tool := &result.Tools[i]
+		ci := toolCosts.info(tool.Name)
+
+		fields := map[string]any{}
+		if tool.Meta != nil {
+			for k, v := range tool.Meta.AdditionalFields {
+				fields[k] = v
+			}
+		}
+		fields["safecast/cost"] = ci.meta()
+		tool.Meta = mcp.NewMetaFromMap(fields)
+		tool.Description += ci.descriptionSuffix()
+	}
+}
+
+// resultSize is the number of bytes of text a tool returned.
+func resultSize(res *mcp.CallToolResult) int {
+	if res == nil {
+		return 0
+	}
+	n := 0
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			n += len(tc.Text)
+		}
+	}
+	return n
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── cross_validate_sensor ──────────────────────────────────────────────────
+//
+// Fixed sensors and bGeigie drives measure the same place in two different
+// ways: one mounted on a building around the clock, the other at 1 m above
+// the road for a few seconds. Where a drive passes a sensor, each marker
+// within radius_m is paired with the sensor reading closest in time, within
+// the time tolerance. Markers of one drive past the sensor are strongly
+// correlated, so the agreement statistics are computed over passes (the
+// medians of each track's pairs), not over individual markers.
+
+const (
+	defaultCrossRadiusM        = 500
+	defaultCrossToleranceMin   = 60
+	maxCrossValidationMarkers  = 20000
+	minCrossValidationPasses   = 3
+	crossAgreementBand         = 1.2 // median ratio within 1/1.2 to 1.2
+	crossLimitsOfAgreementMult = 1.96
+)
+
+var crossValidateSensorToolDef = mcp.NewTool("cross_validate_sensor",
+	mcp.WithDescription("Cross-validate a fixed sensor (Pointcast, Solarcast, bGeigieZen) against bGeigie drives that passed it: pairs every track point within radius_m of the sensor with the sensor reading closest in time (within time_tolerance_minutes) and quantifies agreement per pass and overall — ratio of sensor to mobile values, mean difference with Bland-Altman limits of agreement, and correlation. Sensors reporting CPM are compared through an implied CPM per µSv/h factor unless cpm_per_usvh is given. Use calibration_drift for trends over years. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("device_id",
+		mcp.Description("Fixed sensor to check, e.g. 'pointcast:10004' (from list_sensors)"),
+		mcp.Required(),
+	),
+	mcp.WithNumber("radius_m",
+		mcp.Description("Distance from the sensor within which track points are compared (default: 500, max: 5000)"),
+		mcp.Min(10), mcp.Max(5000),
+		mcp.DefaultNumber(defaultCrossRadiusM),
+	),
+	mcp.WithNumber("time_tolerance_minutes",
+		mcp.Description("Largest time difference between a track point and the sensor reading it is paired with (default: 60, max: 1440)"),
+		mcp.Min(1), mcp.Max(1440),
+		mcp.DefaultNumber(defaultCrossToleranceMin),
+	),
+	mcp.WithString("start_date",
+		mcp.Description("Only track points from this date on, YYYY-MM-DD"),
+	),
+	mcp.WithString("end_date",
+		mcp.Description("Only track points up to this date, YYYY-MM-DD (default: today)"),
+	),
+	mcp.WithNumber("cpm_per_usvh",
+		mcp.Description("Conversion factor of the sensor's tube, for sensors reporting CPM (e.g. 334 for the LND 7317); without it CPM sensors are compared by ratio only"),
+		mcp.Min(1), mcp.Max(10000),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of passes to list, newest first; statistics use all (default: 50, max: 500)"),
+		mcp.Min(1), mcp.Max(500),
+		mcp.DefaultNumber(50),
+	),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// crossPass is one drive past the sensor.
+type crossPass struct {
+	TrackID      string
+	DeviceID     string
+	Detector     string
+	First, Last  time.Time
+	Mobile       []float64 // µSv/h
+	Sensor       []float64 // in the sensor's unit
+	MinDistanceM float64
+	MaxLagS      float64
+}
+
+func handleCrossValidateSensor(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !dbAvailable() {
+		return mcp.NewToolResultError("Database connection required for cross_validate_sensor: it pairs the markers table with realtime sensor readings"), nil
+	}
+	deviceID, err := req.RequireString("device_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	deviceID = strings.TrimSpace(deviceID)
+	radiusM := req.GetFloat("radius_m", defaultCrossRadiusM)
+	tolerance := req.GetFloat("time_tolerance_minutes", defaultCrossToleranceMin)
+	factor := req.GetFloat("cpm_per_usvh", 0)
+	defLimit, maxLimit := toolLimits("cross_validate_sensor", 50, 500)
+	limit := req.GetInt("limit", defLimit)
+
+	if radiusM < 10 || radiusM > 5000 {
+		return mcp.NewToolResultError("radius_m must be between 10 and 5000"), nil
+	}
+	if tolerance < 1 || tolerance > 1440 {
+		return mcp.NewToolResultError("time_tolerance_minutes must be between 1 and 1440"), nil
+	}
+	if factor < 0 {
+		return mcp.NewToolResultError("cpm_per_usvh must be positive"), nil
+	}
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("limit must be between 1 and %d", maxLimit)), nil
+	}
+	var start, end time.Time
+	if s := req.GetString("start_date", ""); s != "" {
+		if start, err = time.Parse("2006-01-02", s); err != nil {
+			return mcp.NewToolResultError("start_date must be in YYYY-MM-DD format"), nil
+		}
+	}
+	end = time.Now().UTC()
+	if s := req.GetString("end_date", ""); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return mcp.NewToolResultError("end_date must be in YYYY-MM-DD format"), nil
+		}
+		end = d.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return mcp.NewToolResultError("end_date must be after start_date"), nil
+	}
+
+	sensor, err := queryRow(ctx, `
+		SELECT device_id,
+			COALESCE(device_name, device_id) AS device_name,
+			COALESCE(transport, '') AS transport,
+			COALESCE(unit, 'µSv/h') AS unit,
+			lat, lon
+		FROM realtime_measurements
+		WHERE device_id = $1 AND to_timestamp(measured_at) <= NOW()
+		ORDER BY measured_at DESC
+		LIMIT 1`, deviceID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not look up device %s: %v", deviceID, err)), nil
+	}
+	if sensor == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Device %s not found among the realtime sensors; use list_sensors to find device IDs", deviceID)), nil
+	}
+	lat, _ := toFloat(sensor["lat"])
+	lon, _ := toFloat(sensor["lon"])
+	unit, _ := sensor["unit"].(string)
+	cpm := strings.Contains(strings.ToLower(unit), "cp")
+
+	passes, truncated, err := crossValidationPasses(ctx, deviceID, lat, lon, radiusM, tolerance*60, start, end)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Pairing query failed: %v", err)), nil
+	}
+
+	// Sensor values in µSv/h; not ok for a CPM sensor without a factor.
+	toUSvh := func(v float64) (float64, bool) {
+		switch {
+		case !cpm:
+			return v, true
+		case factor > 0:
+			return v / factor, true
+		}
+		return 0, false
+	}
+
+	pairs := 0
+	var mobile, sensorVals []float64 // per pass medians
+	list := make([]map[string]any, 0, len(passes))
+	for _, p := range passes {
+		pairs += len(p.Mobile)
+		m, _ := medianMAD(slices.Clone(p.Mobile))
+		s, _ := medianMAD(slices.Clone(p.Sensor))
+		mobile = append(mobile, m)
+		sensorVals = append(sensorVals, s)
+		entry := map[string]any{
+			"track_id":       p.TrackID,
+			"device_id":      p.DeviceID,
+			"detector":       p.Detector,
+			"first_at":       p.First.Format(time.RFC3339),
+			"last_at":        p.Last.Format(time.RFC3339),
+			"pairs":          len(p.Mobile),
+			"mobile_usvh":    roundSignificant(m, doseSignificantDigits),
+			"sensor_median":  roundSignificant(s, doseSignificantDigits),
+			"ratio":          roundSignificant(s/m, 3),
+			"min_distance_m": math.Round(p.MinDistanceM),
+			"max_lag_min":    math.Round(p.MaxLagS/6) / 10,
+		}
+		if v, ok := toUSvh(s); ok && cpm {
+			entry["sensor_usvh"] = roundSignificant(v, doseSignificantDigits)
+		}
+		list = append(list, entry)
+	}
+	slices.Reverse(list) // newest first
+
+	agreement := crossAgreement(mobile, sensorVals, toUSvh)
+	result := map[string]any{
+		"sensor": map[string]any{
+			"device_id":   deviceID,
+			"device_name": sensor["device_name"],
+			"type":        sensor["transport"],
+			"location":    map[string]any{"latitude": lat, "longitude": lon},
+		},
+		"source":             "database",
+		"passes":             len(passes),
+		"pairs":              pairs,
+		"agreement":          agreement,
+		"count":              min(len(list), limit),
+		"pass_list":          list[:min(len(list), limit)],
+		"parameters":         map[string]any{"radius_m": radiusM, "time_tolerance_minutes": tolerance},
+		"method":             fmt.Sprintf("Each track point within %g m of the sensor is paired with the sensor reading closest in time within %g minutes. A pass is one track's pairs; its values are the medians of those pairs. Agreement is computed over passes: the median ratio of sensor to mobile values, the mean difference with Bland-Altman limits of agreement (mean ± %.2f standard deviations), and the Pearson correlation. The sensor agrees when the median ratio is within a factor of %g.", radiusM, tolerance, crossLimitsOfAgreementMult, crossAgreementBand),
+		"_ai_hint":           "A fixed sensor is mounted on a building, often above ground, while bGeigies measure 1 m above the road, so ratios of 0.7-1.3 are common without any fault. Report the agreement verdict with the number of passes behind it. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	sensorUnit(ctx, result["sensor"].(map[string]any), unit)
+	if cpm && factor > 0 {
+		result["parameters"].(map[string]any)["cpm_per_usvh"] = factor
+	}
+	if !start.IsZero() {
+		result["parameters"].(map[string]any)["start_date"] = start.Format("2006-01-02")
+	}
+	if truncated {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("Only the newest %d track points were paired; narrow the dates for a complete comparison.", maxCrossValidationMarkers)
+	}
+	if len(passes) == 0 {
+		result["note"] = "No bGeigie track passed the sensor while it was reporting. Try a larger radius_m or time_tolerance_minutes."
+	}
+	return measurementResult(ctx, result)
+}
+
+// crossValidationPasses pairs the markers around a sensor with its readings
+// and groups them by track, oldest pass first. It reports whether the
+// marker cap was reached.
+func crossValidationPasses(ctx context.Context, deviceID string, lat, lon, radiusM, toleranceS float64, start, end time.Time) ([]crossPass, bool, error) {
+	// Same bbox pre-filter and ST_DWithin refinement as queryRadiationSQL;
+	// the lateral subquery finds the closest reading in time per marker.
+	rows, err := queryRows(ctx, `
+		SELECT m.trackid, m.device_id, m.detector, m.doserate, m.date,
+			ST_Distance(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) AS distance_m,
+			r.value AS sensor_value, r.measured_at AS sensor_at
+		FROM markers m
+		CROSS JOIN LATERAL (
+			SELECT value, measured_at
+			FROM realtime_measurements
+			WHERE device_id = $4
+				AND value > 0
+				AND measured_at BETWEEN m.date - $5 AND m.date + $5
+			ORDER BY abs(measured_at - m.date)
+			LIMIT 1
+		) r
+		WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint($2, $1), 4326), $3 / 111000.0)
+			AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+			AND m.doserate > 0
+			AND m.date >= $6
+			AND m.date < $7
+			AND m.id <= $8
+		ORDER BY m.date DESC
+		LIMIT $9`,
+		lat, lon, radiusM, deviceID, int64(toleranceS), start.Unix(), end.Unix(), snapshotBound(ctx), maxCrossValidationMarkers+1)
+	if err != nil {
+		return nil, false, err
+	}
+	truncated := len(rows) > maxCrossValidationMarkers
+	if truncated {
+		rows = rows[:maxCrossValidationMarkers]
+	}
+
+	byTrack := map[string]*crossPass{}
+	var passes []*crossPass
+	for i := len(rows) - 1; i >= 0; i-- {
+		r := rows[i]
+		track := fmt.Sprint(r["trackid"])
+		p, ok := byTrack[track]
+		if !ok {
+			p = &crossPass{TrackID: track, MinDistanceM: math.Inf(1)}
+			p.DeviceID = fmt.Sprint(r["device_id"])
+			p.Detector, _ = r["detector"].(string)
+			byTrack[track] = p
+			passes = append(passes, p)
+		}
+		dose, _ := toFloat(r["doserate"])
+		value, _ := toFloat(r["sensor_value"])
+		date, _ := toFloat(r["date"])
+		at, _ := toFloat(r["sensor_at"])
+		dist, _ := toFloat(r["distance_m"])
+		t := time.Unix(int64(date), 0).UTC()
+		if p.First.IsZero() {
+			p.First = t
+		}
+		p.Last = t
+		p.Mobile = append(p.Mobile, dose)
+		p.Sensor = append(p.Sensor, value)
+		p.MinDistanceM = math.Min(p.MinDistanceM, dist)
+		p.MaxLagS = math.Max(p.MaxLagS, math.Abs(at-date))
+	}
+	out := make([]crossPass, len(passes))
+	for i, p := range passes {
+		out[i] = *p
+	}
+	return out, truncated, nil
+}
+
+// crossAgreement summarizes the per-pass medians. toUSvh converts a sensor
+// value; for CPM sensors without a factor the result is an implied factor.
+func crossAgreement(mobile, sensor []float64, toUSvh func(float64) (float64, bool)) map[string]any {
+	out := map[string]any{"passes": len(mobile)}
+	if len(mobile) < minCrossValidationPasses {
+		out["verdict"] = "insufficient_data"
+		out["note"] = fmt.Sprintf("At least %d passes are needed to judge agreement.", minCrossValidationPasses)
+		if len(mobile) == 0 {
+			return out
+		}
+	}
+	if len(mobile) >= minCrossValidationPasses {
+		out["pearson_r"] = roundSignificant(pearson(mobile, sensor), 3)
+	}
+
+	converted := make([]float64, 0, len(sensor))
+	for _, v := range sensor {
+		if u, ok := toUSvh(v); ok {
+			converted = append(converted, u)
+		}
+	}
+	if len(converted) < len(sensor) {
+		// CPM without a conversion factor: the ratio is the factor the
+		// sensor effectively has relative to the bGeigies.
+		implied := make([]float64, len(sensor))
+		for i := range sensor {
+			implied[i] = sensor[i] / mobile[i]
+		}
+		med, mad := medianMAD(implied)
+		out["implied_cpm_per_usvh"] = roundSignificant(med, 3)
+		out["implied_cpm_per_usvh_mad"] = roundSignificant(mad, 2)
+		out["unit_note"] = "The sensor reports CPM; pass cpm_per_usvh to compare dose rates. implied_cpm_per_usvh is the factor that would make it agree with the bGeigies."
+		if _, ok := out["verdict"]; !ok {
+			out["verdict"] = "not_comparable"
+		}
+		return out
+	}
+
+	ratios := make([]float64, len(mobile))
+	diffs := make([]float64, len(mobile))
+	var sum float64
+	within := 0
+	for i := range mobile {
+		ratios[i] = converted[i] / mobile[i]
+		diffs[i] = converted[i] - mobile[i]
+		sum += diffs[i]
+		if ratios[i] >= 1/crossAgreementBand && ratios[i] <= crossAgreementBand {
+			within++
+		}
+	}
+	mean := sum / float64(len(diffs))
+	var ss float64
+	for _, d := range diffs {
+		ss += (d - mean) * (d - mean)
+	}
+	medRatio, _ := medianMAD(ratios)
+	out["median_ratio"] = roundSignificant(medRatio, 3)
+	out["mean_difference_usvh"] = roundSignificant(mean, doseSignificantDigits)
+	out["within_band_share"] = math.Round(float64(within)/float64(len(mobile))*100) / 100
+	if len(diffs) > 1 {
+		sd := math.Sqrt(ss / float64(len(diffs)-1))
+		out["limits_of_agreement_usvh"] = []float64{
+			roundSignificant(mean-crossLimitsOfAgreementMult*sd, doseSignificantDigits),
+			roundSignificant(mean+crossLimitsOfAgreementMult*sd, doseSignificantDigits),
+		}
+	}
+	if _, ok := out["verdict"]; ok {
+		return out
+	}
+	switch {
+	case medRatio > crossAgreementBand:
+		out["verdict"] = "sensor_high"
+	case medRatio < 1/crossAgreementBand:
+		out["verdict"] = "sensor_low"
+	default:
+		out["verdict"] = "agree"
+	}
+	return out
+}
+
+// pearson is the correlation coefficient of two equally long series, or 0
+// when either is constant.
+func pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i] / n
+		my += ys[i] / n
+	}
+	var sxy, sxx, syy float64
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
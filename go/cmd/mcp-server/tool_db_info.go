@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -12,8 +13,20 @@ var dbInfoToolDef = mcp.NewTool("db_info",
 )
 
 func handleDBInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if demoMode {
+		return mcp.NewToolResultText("No database connection (DEMO_MODE: serving the built-in synthetic dataset)"), nil
+	}
 	if !dbAvailable() {
-		return mcp.NewToolResultText("No database connection (using REST API fallback)"), nil
+		if os.Getenv("DATABASE_URL") == "" {
+			return mcp.NewToolResultText("No database connection (using REST API fallback)"), nil
+		}
+		return jsonResult(map[string]any{
+			"status":   "disconnected",
+			"database": dbConn.Stats(),
+			"sources":  router.Stats(),
+			"build":    buildInfo(),
+			"_ai_hint": "The database is configured but not reachable; tools answer from the REST API until the server reconnects on its own. last_error says why and next_attempt_seconds when the next attempt is.",
+		})
 	}
 
 	// Get basic connection info
@@ -72,6 +85,10 @@ func handleDBInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 		}
 	}
 
+	// Whether track area searches and dose filters read the cached tables
+	info["track_geometries_cache"] = trackGeometries.Load()
+	info["track_dose_stats_cache"] = trackDoseStats.Load()
+
 	// Get table counts for context
 	countsRow, err := queryRow(ctx, "SELECT count(*) AS total FROM uploads")
 	if err == nil && countsRow != nil {
@@ -94,8 +111,15 @@ func handleDBInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 	}
 
 	return jsonResult(map[string]any{
-		"status":     "connected",
-		"connection": info,
+		"status":      "connected",
+		"connection":  info,
+		"database":    dbConn.Stats(),
+		"concurrency": limiter.Stats(),
+		"sources":     router.Stats(),
+		"analytics":   analyticsStats(),
+		"event_log":   events.Stats(),
+		"duckdb":      duckWrites.Stats(),
+		"build":       buildInfo(),
 		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	})
@@ -2,14 +2,15 @@ package main
 
 import (
 	"context"
-	"strings"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 var deviceHistoryToolDef = mcp.NewTool("device_history",
-	mcp.WithDescription("Get historical measurements from MOBILE bGeigie survey devices (type=geigiecast, IDs like geigiecast:62007). Use this tool ONLY for mobile bGeigie devices. DO NOT use for fixed sensors — for bGeigieZen (geigiecast-zen), Pointcast, Solarcast, Notehub/Radnote (note:dev:...), nGeigie, or device-tcp, use sensor_current instead. Radiation values are in CPM (counts per minute, NOT counts per second). Always present radiation values in µSv/h by converting from CPM using detector-specific factors. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
+	mcp.WithDescription("Get historical measurements from MOBILE bGeigie survey devices (type=geigiecast, IDs like geigiecast:62007). Use this tool ONLY for mobile bGeigie devices. DO NOT use for fixed sensors — for bGeigieZen (geigiecast-zen), Pointcast, Solarcast, Notehub/Radnote (note:dev:...), nGeigie, or device-tcp, use sensor_current instead. Radiation values are in µSv/h: fixed-sensor readings in CPM (counts per minute) are converted on the server with the tube's factor, keeping the count rate in cpm, so do not convert again. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
 	mcp.WithString("device_id",
 		mcp.Description("Device identifier"),
 		mcp.Required(),
@@ -24,6 +25,15 @@ var deviceHistoryToolDef = mcp.NewTool("device_history",
 		mcp.Min(1), mcp.Max(10000),
 		mcp.DefaultNumber(200),
 	),
+	mcp.WithString("interval",
+		mcp.Description("Aggregate the whole period per hour, day or week (count, mean, min and max in µSv/h, UTC) instead of returning raw measurements, which limit cuts to the newest. Every interval is listed, empty ones with count 0; limit caps the number of intervals."),
+		mcp.Enum(deviceHistoryIntervals...),
+	),
+	withRawUnitsParam(),
+	withAltitudeParam(),
+	withExplainParam(),
+	withCompactParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -34,19 +44,31 @@ func handleDeviceHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 	}
 
 	days := req.GetInt("days", 30)
-	limit := req.GetInt("limit", 200)
+	defLimit, maxLimit := toolLimits("device_history", 200, 10000)
+	limit := req.GetInt("limit", defLimit)
 
 	if days < 1 || days > 365 {
 		return mcp.NewToolResultError("days must be between 1 and 365"), nil
 	}
-	if limit < 1 || limit > 10000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 10000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
-	if dbAvailable() {
-		return deviceHistoryDB(ctx, deviceIDStr, days, limit)
+	ctx = rawUnitsContext(ctx, req)
+	ctx = altitudeContext(ctx, req)
+	if interval := req.GetString("interval", ""); interval != "" {
+		if err := checkIntervalCount(interval, days, limit); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return routeQuery(ctx, queryRecent, sourceFuncs{
+			db:  func() (*mcp.CallToolResult, error) { return deviceHistoryIntervalsDB(ctx, deviceIDStr, days, interval) },
+			api: func() (*mcp.CallToolResult, error) { return deviceHistoryIntervalsAPI(ctx, deviceIDStr, days, interval) },
+		})
 	}
-	return deviceHistoryAPI(ctx, deviceIDStr, days, limit)
+	return routeQuery(ctx, queryRecent, sourceFuncs{
+		db:  func() (*mcp.CallToolResult, error) { return deviceHistoryDB(ctx, deviceIDStr, days, limit) },
+		api: func() (*mcp.CallToolResult, error) { return deviceHistoryAPI(ctx, deviceIDStr, days, limit) },
+	})
 }
 
 func deviceHistoryDB(ctx context.Context, deviceID string, days, limit int) (*mcp.CallToolResult, error) {
@@ -56,7 +78,7 @@ func deviceHistoryDB(ctx context.Context, deviceID string, days, limit int) (*mc
 	// Query both markers table and realtime_measurements table
 	// First, try markers table (bGeigie imports)
 	markersQuery := `
-		SELECT m.id, m.doserate AS value, 'µSv/h' AS unit,
+		SELECT m.id, m.doserate AS value, 'µSv/h' AS unit, m.countrate AS count_rate,
 			to_timestamp(m.date) AS captured_at,
 			m.lat AS latitude, m.lon AS longitude,
 			m.altitude AS height, m.detector, m.trackid::text AS track_id,
@@ -170,21 +192,17 @@ func deviceHistoryDB(ctx context.Context, deviceID string, days, limit int) (*mc
 			}
 		}
 
+		if rawUnits(ctx) {
+			measurement["count_rate"] = r["count_rate"]
+		}
 		allMeasurements = append(allMeasurements, measurement)
 	}
 	
 	// Process realtime results
 	for _, r := range realtimeRows {
-		// Fix incorrect unit: Geiger counters report in CPM (counts per minute), not CPS
-		unit := r["unit"]
-		if unitStr, ok := unit.(string); ok {
-			unit = strings.ReplaceAll(strings.ReplaceAll(unitStr, "cps", "cpm"), "CPS", "CPM")
-		}
-
 		measurement := map[string]any{
 			"id":    r["id"],
 			"value": r["value"],
-			"unit":  unit,
 			"captured_at": r["captured_at"],
 			"location": map[string]any{
 				"latitude":  r["latitude"],
@@ -195,6 +213,8 @@ func deviceHistoryDB(ctx context.Context, deviceID string, days, limit int) (*mc
 			"type":     r["transport"],
 			"source":   "realtime_sensor",
 		}
+		sensorUnit(ctx, measurement, r["unit"])
+		convertSensorCPM(ctx, measurement, deviceID)
 		allMeasurements = append(allMeasurements, measurement)
 	}
 
@@ -236,6 +256,19 @@ func deviceHistoryDB(ctx context.Context, deviceID string, days, limit int) (*mc
 	if len(measurements) > limit {
 		measurements = measurements[:limit]
 	}
+	for _, m := range measurements {
+		m["provenance"] = "database"
+	}
+
+	// Fill gaps in the replica's series from the API, which reads the primary
+	source := "database"
+	var merge map[string]any
+	if router.healthy(sourceAPI) && !snapshotRequested(ctx) && !explainOnly(ctx) {
+		measurements, merge = mergeDeviceHistoryAPI(ctx, deviceID, startDate, measurements, limit)
+		if n, _ := merge["api_points_added"].(int); n > 0 {
+			source = "database+api"
+		}
+	}
 
 	capturedAfter := startDate.Format("2006-01-02") + " 00:00"
 	capturedBefore := now.Format("2006-01-02") + " 23:59"
@@ -250,13 +283,17 @@ func deviceHistoryDB(ctx context.Context, deviceID string, days, limit int) (*mc
 			"end_date":   capturedBefore,
 		},
 		"count":        len(measurements),
-		"source":       "database",
+		"source":       source,
 		"measurements": measurements,
 		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	if merge != nil {
+		result["merge"] = merge
+	}
+	addHistoryCoverage(result, measurements, startDate, days, limit)
 
-	return jsonResult(result)
+	return measurementResult(ctx, result)
 }
 
 func deviceHistoryAPI(ctx context.Context, deviceIDStr string, days, limit int) (*mcp.CallToolResult, error) {
@@ -269,30 +306,7 @@ func deviceHistoryAPI(ctx context.Context, deviceIDStr string, days, limit int)
 	startDate := now.AddDate(0, 0, -days)
 	capturedAfter := startDate.Format("2006-01-02") + " 00:00"
 	capturedBefore := now.Format("2006-01-02") + " 23:59"
-	startUnix := startDate.Unix()
-
-	// Extract dose rate time series and filter by date range
-	var measurements []map[string]any
-	if series, ok := resp["series"].(map[string]any); ok {
-		if doseRate, ok := series["doseRate"].([]any); ok {
-			for _, raw := range doseRate {
-				pt, ok := raw.(map[string]any)
-				if !ok {
-					continue
-				}
-				ts, ok := toFloat(pt["time"])
-				if !ok || int64(ts) < startUnix {
-					continue
-				}
-				t := time.Unix(int64(ts), 0).UTC()
-				measurements = append(measurements, map[string]any{
-					"value":       pt["value"],
-					"unit":        "µSv/h",
-					"captured_at": t.Format(time.RFC3339),
-				})
-			}
-		}
-	}
+	measurements := deviceHistoryAPIPoints(resp, startDate)
 
 	totalAvailable := len(measurements)
 	if limit > len(measurements) {
@@ -325,6 +339,8 @@ func deviceHistoryAPI(ctx context.Context, deviceIDStr string, days, limit int)
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
 
+	addHistoryCoverage(result, measurements, startDate, days, limit)
+
 	if ranges, ok := resp["ranges"].(map[string]any); ok {
 		if dr, ok := ranges["doseRate"].(map[string]any); ok {
 			result["statistics"] = map[string]any{
@@ -335,5 +351,101 @@ func deviceHistoryAPI(ctx context.Context, deviceIDStr string, days, limit int)
 		}
 	}
 
-	return jsonResult(result)
+	return measurementResult(ctx, result)
+}
+
+// deviceHistoryAPIPoints extracts the dose rate series of a
+// /realtime_history response from start on, in the shape of the database's
+// realtime rows.
+func deviceHistoryAPIPoints(resp map[string]any, start time.Time) []map[string]any {
+	var points []map[string]any
+	series, _ := resp["series"].(map[string]any)
+	doseRate, _ := series["doseRate"].([]any)
+	for _, raw := range doseRate {
+		pt, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		ts, ok := toFloat(pt["time"])
+		if !ok || int64(ts) < start.Unix() {
+			continue
+		}
+		points = append(points, map[string]any{
+			"value":       pt["value"],
+			"unit":        "µSv/h",
+			"captured_at": time.Unix(int64(ts), 0).UTC().Format(time.RFC3339),
+			"source":      "realtime_sensor",
+			"provenance":  "api",
+		})
+	}
+	// The API sends the series oldest first; results are newest first.
+	slices.Reverse(points)
+	return points
+}
+
+// deviceHistoryDedupWindow is how close an API point must be to a database
+// reading to be taken as the same reading.
+const deviceHistoryDedupWindow = 30 * time.Second
+
+// mergeDeviceHistoryAPI adds the API's points for the device to the
+// database rows, newest first. Database rows win: an API point within
+// deviceHistoryDedupWindow of one is dropped as a duplicate, the rest fill
+// the gaps. The second return value describes the merge for the result.
+func mergeDeviceHistoryAPI(ctx context.Context, deviceID string, start time.Time, dbRows []map[string]any, limit int) ([]map[string]any, map[string]any) {
+	resp, err := client.GetRealtimeHistory(ctx, deviceID)
+	if err != nil {
+		if isNotFound(err) {
+			return dbRows, map[string]any{"api": "no realtime history for this device"}
+		}
+		router.record(sourceAPI, err.Error())
+		return dbRows, map[string]any{"api": "unavailable: " + err.Error()}
+	}
+	router.record(sourceAPI, "")
+
+	apiPoints := deviceHistoryAPIPoints(resp, start)
+	merged, added := mergeSeries(dbRows, apiPoints, deviceHistoryDedupWindow)
+	slices.SortStableFunc(merged, func(a, b map[string]any) int {
+		ta, _ := readingTime(a["captured_at"])
+		tb, _ := readingTime(b["captured_at"])
+		return tb.Compare(ta)
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged, map[string]any{
+		"database_points":  len(dbRows),
+		"api_points":       len(apiPoints),
+		"api_points_added": added,
+		"api_duplicates":   len(apiPoints) - added,
+		"dedup_window_s":   deviceHistoryDedupWindow.Seconds(),
+		"note":             "Database rows are kept; API points within the dedup window of one are dropped as duplicates and the rest fill gaps. Each measurement's provenance says where it came from.",
+	}
+}
+
+// mergeSeries returns primary plus the points of secondary that are not
+// within window of a primary point, and how many of those were added.
+func mergeSeries(primary, secondary []map[string]any, window time.Duration) ([]map[string]any, int) {
+	times := make([]time.Time, 0, len(primary))
+	for _, r := range primary {
+		if t, ok := readingTime(r["captured_at"]); ok {
+			times = append(times, t)
+		}
+	}
+	slices.SortFunc(times, time.Time.Compare)
+
+	merged := slices.Clone(primary)
+	added := 0
+	for _, p := range secondary {
+		t, ok := readingTime(p["captured_at"])
+		if !ok {
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(times, t, time.Time.Compare)
+		dup := (i < len(times) && times[i].Sub(t) <= window) || (i > 0 && t.Sub(times[i-1]) <= window)
+		if !dup {
+			merged = append(merged, p)
+			added++
+		}
+	}
+	return merged, added
 }
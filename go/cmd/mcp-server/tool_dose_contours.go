@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── dose_contours ──────────────────────────────────────────────────────────
+//
+// Isolines of dose rate over an area, like the Fukushima plume maps. The
+// markers in the box are averaged onto a grid in the database (one row per
+// cell leaves the server, not one per marker). Cells without measurements
+// within fill_cells of a measured cell get an inverse-distance-weighted
+// value from those neighbours; cells further away stay empty, so no lines
+// are drawn across areas nobody measured. Marching squares over the cell
+// centres then traces one MultiLineString per requested level, returned as
+// a GeoJSON FeatureCollection.
+
+const (
+	defaultContourGrid      = 60
+	maxContourGrid          = 200
+	defaultContourFillCells = 2
+	maxContourFillCells     = 10
+	maxContourVertices      = 20000
+)
+
+var defaultContourLevels = []float64{0.1, 0.2, 0.5, 1, 2, 5, 10}
+
+var doseContoursToolDef = mcp.NewTool("dose_contours",
+	mcp.WithDescription("Generate dose rate contour lines (isolines) for an area as GeoJSON. Measurements in the bounding box are averaged onto a grid, gaps next to measured cells are interpolated, and a line is traced for each requested µSv/h level, e.g. to draw a plume map of the Fukushima area. Returns a GeoJSON FeatureCollection with one MultiLineString feature per level, the approximate area at or above each level, and grid statistics. Use start_date/end_date to map one period, since dose rates change over the years. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
+	mcp.WithNumber("min_lat",
+		mcp.Description("Southern boundary latitude (required unless region is given)"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("max_lat",
+		mcp.Description("Northern boundary latitude (required unless region is given)"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("min_lon",
+		mcp.Description("Western boundary longitude (required unless region is given)"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("max_lon",
+		mcp.Description("Eastern boundary longitude (required unless region is given)"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithArray("levels",
+		mcp.Description("Dose rate levels in µSv/h to draw lines at (default: [0.1, 0.2, 0.5, 1, 2, 5, 10])"),
+		mcp.WithNumberItems(),
+	),
+	mcp.WithNumber("grid_size",
+		mcp.Description("Number of grid cells along the longer side of the box (default: 60, max: 200). Finer grids follow roads more closely but leave more gaps."),
+		mcp.Min(4), mcp.Max(maxContourGrid),
+		mcp.DefaultNumber(defaultContourGrid),
+	),
+	mcp.WithNumber("fill_cells",
+		mcp.Description("Interpolate empty cells from measured cells up to this many cells away (default: 2, 0 disables interpolation)"),
+		mcp.Min(0), mcp.Max(maxContourFillCells),
+		mcp.DefaultNumber(defaultContourFillCells),
+	),
+	mcp.WithString("start_date",
+		mcp.Description("Only measurements captured on or after this day (YYYY-MM-DD, UTC)"),
+	),
+	mcp.WithString("end_date",
+		mcp.Description("Only measurements captured on or before this day (YYYY-MM-DD, UTC)"),
+	),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// contourGrid is the cell layout over the bounding box. Cell (0, 0) is the
+// south-west corner.
+type contourGrid struct {
+	minLat, maxLat, minLon, maxLon float64
+	cols, rows                     int
+	dLat, dLon                     float64
+}
+
+// newContourGrid puts size cells along the longer side of the box,
+// measured in kilometres, so cells are roughly square on the ground.
+func newContourGrid(minLat, maxLat, minLon, maxLon float64, size int) contourGrid {
+	g := contourGrid{minLat: minLat, maxLat: maxLat, minLon: minLon, maxLon: maxLon}
+	latKm := (maxLat - minLat) * 111.32
+	lonKm := (maxLon - minLon) * 111.32 * math.Cos((minLat+maxLat)/2*math.Pi/180)
+	g.cols, g.rows = size, size
+	if lonKm > latKm {
+		g.rows = max(2, int(math.Round(float64(size)*latKm/lonKm)))
+	} else {
+		g.cols = max(2, int(math.Round(float64(size)*lonKm/latKm)))
+	}
+	g.dLat = (maxLat - minLat) / float64(g.rows)
+	g.dLon = (maxLon - minLon) / float64(g.cols)
+	return g
+}
+
+// cellKm2 is the approximate ground area of one cell.
+func (g contourGrid) cellKm2() float64 {
+	return g.dLat * 111.32 * g.dLon * 111.32 * math.Cos((g.minLat+g.maxLat)/2*math.Pi/180)
+}
+
+// point converts fractional cell-centre coordinates to [lon, lat].
+func (g contourGrid) point(x, y float64) []float64 {
+	return []float64{round6(g.minLon + (x+0.5)*g.dLon), round6(g.minLat + (y+0.5)*g.dLat)}
+}
+
+// contourCellsQuery averages the markers of each grid cell. It is written
+// for DuckDB against postgres_db and is valid Postgres once the
+// postgres_db.public. prefix is dropped (as radiationStatsQuery). conds are
+// extra WHERE conditions.
+func contourCellsQuery(g contourGrid, conds []string) string {
+	where := append([]string{
+		"doserate > 0 AND doserate < 1000",
+		fmt.Sprintf("lat >= %.8f AND lat < %.8f AND lon >= %.8f AND lon < %.8f", g.minLat, g.maxLat, g.minLon, g.maxLon),
+	}, conds...)
+	return fmt.Sprintf(`
+		SELECT
+			CAST(floor((lon - %.8f) / %.12f) AS BIGINT) AS gx,
+			CAST(floor((lat - %.8f) / %.12f) AS BIGINT) AS gy,
+			AVG(doserate) AS avg_value,
+			COUNT(*) AS markers
+		FROM postgres_db.public.markers
+		WHERE %s
+		GROUP BY 1, 2`,
+		g.minLon, g.dLon, g.minLat, g.dLat, strings.Join(where, " AND "))
+}
+
+// contourCell is one grid cell's average of the markers in it.
+type contourCell struct {
+	x, y    int
+	avg     float64
+	markers int
+}
+
+func contourCellsFromRows(rows []map[string]any) []contourCell {
+	cells := make([]contourCell, 0, len(rows))
+	for _, r := range rows {
+		x, ok1 := toFloat(r["gx"])
+		y, ok2 := toFloat(r["gy"])
+		avg, ok3 := toFloat(r["avg_value"])
+		n, _ := toFloat(r["markers"])
+		if ok1 && ok2 && ok3 {
+			cells = append(cells, contourCell{int(x), int(y), avg, int(n)})
+		}
+	}
+	return cells
+}
+
+func handleDoseContours(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var bbox [4]float64
+	for i, name := range []string{"min_lat", "max_lat", "min_lon", "max_lon"} {
+		v, err := req.RequireFloat(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		bbox[i] = v
+	}
+	minLat, maxLat, minLon, maxLon := bbox[0], bbox[1], bbox[2], bbox[3]
+	if minLat < -90 || maxLat > 90 || minLon < -180 || maxLon > 180 {
+		return mcp.NewToolResultError("Latitude must be between -90 and 90 and longitude between -180 and 180"), nil
+	}
+	if minLat >= maxLat {
+		return mcp.NewToolResultError("min_lat must be less than max_lat"), nil
+	}
+	if minLon >= maxLon {
+		return mcp.NewToolResultError("min_lon must be less than max_lon"), nil
+	}
+	if maxLat-minLat > 10 || maxLon-minLon > 10 {
+		return mcp.NewToolResultError("The area is too large for contours: keep the box within 10 degrees on each side"), nil
+	}
+
+	levels := req.GetFloatSlice("levels", defaultContourLevels)
+	if len(levels) == 0 || len(levels) > 20 {
+		return mcp.NewToolResultError("levels must list between 1 and 20 dose rates in µSv/h"), nil
+	}
+	levels = slices.Clone(levels)
+	slices.Sort(levels)
+	levels = slices.Compact(levels)
+	if levels[0] <= 0 {
+		return mcp.NewToolResultError("levels must be positive dose rates in µSv/h"), nil
+	}
+	size := req.GetInt("grid_size", defaultContourGrid)
+	if size < 4 || size > maxContourGrid {
+		return mcp.NewToolResultError(fmt.Sprintf("grid_size must be between 4 and %d", maxContourGrid)), nil
+	}
+	fill := req.GetInt("fill_cells", defaultContourFillCells)
+	if fill < 0 || fill > maxContourFillCells {
+		return mcp.NewToolResultError(fmt.Sprintf("fill_cells must be between 0 and %d", maxContourFillCells)), nil
+	}
+
+	// Time window; end_date includes the whole day
+	var startDate, endDate time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"start_date", &startDate}, {"end_date", &endDate}} {
+		if v := req.GetString(p.name, ""); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return mcp.NewToolResultError(p.name + " must be in YYYY-MM-DD format"), nil
+			}
+			*p.dst = t
+		}
+	}
+	if !startDate.IsZero() && !endDate.IsZero() && endDate.Before(startDate) {
+		return mcp.NewToolResultError("end_date must not be before start_date"), nil
+	}
+	var timeConds []string
+	if !startDate.IsZero() {
+		timeConds = append(timeConds, fmt.Sprintf("date >= %d", startDate.Unix()))
+	}
+	if !endDate.IsZero() {
+		timeConds = append(timeConds, fmt.Sprintf("date < %d", endDate.AddDate(0, 0, 1).Unix()))
+	}
+
+	g := newContourGrid(minLat, maxLat, minLon, maxLon, size)
+	build := func(cells []contourCell, source string) (*mcp.CallToolResult, error) {
+		return doseContoursResult(g, cells, levels, fill, source, startDate, endDate)
+	}
+	return routeQuery(ctx, queryAggregate, sourceFuncs{
+		duckdb: func() (*mcp.CallToolResult, error) {
+			rows, err := duckQueryRows(ctx, contourCellsQuery(g, timeConds))
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Contour query failed: %v", err)), nil
+			}
+			return build(contourCellsFromRows(rows), "duckdb_postgres_attach")
+		},
+		db: func() (*mcp.CallToolResult, error) {
+			conds := append([]string{
+				fmt.Sprintf("geom && ST_MakeEnvelope(%.8f, %.8f, %.8f, %.8f, 4326)", minLon, minLat, maxLon, maxLat),
+				fmt.Sprintf("id <= %d", snapshotBound(ctx)),
+			}, timeConds...)
+			rows, err := queryRows(ctx, strings.ReplaceAll(contourCellsQuery(g, conds), "postgres_db.public.", ""))
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Contour query failed: %v", err)), nil
+			}
+			return build(contourCellsFromRows(rows), "database")
+		},
+		api: func() (*mcp.CallToolResult, error) {
+			markers, err := client.GetMarkers(ctx, minLat, minLon, maxLat, maxLon)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return build(contourCellsFromMarkers(g, markers, startDate, endDate), "api")
+		},
+	})
+}
+
+// contourCellsFromMarkers grids /get_markers results in memory, with the
+// same filters as contourCellsQuery.
+func contourCellsFromMarkers(g contourGrid, markers []map[string]any, startDate, endDate time.Time) []contourCell {
+	type sum struct {
+		total float64
+		n     int
+	}
+	sums := map[[2]int]*sum{}
+	for _, m := range markers {
+		dose, ok1 := toFloat(m["doseRate"])
+		lat, ok2 := toFloat(m["lat"])
+		lon, ok3 := toFloat(m["lon"])
+		if !ok1 || !ok2 || !ok3 || dose <= 0 || dose >= 1000 {
+			continue
+		}
+		if lat < g.minLat || lat >= g.maxLat || lon < g.minLon || lon >= g.maxLon {
+			continue
+		}
+		if !startDate.IsZero() || !endDate.IsZero() {
+			date, ok := toFloat(m["date"])
+			if !ok || (!startDate.IsZero() && date < float64(startDate.Unix())) ||
+				(!endDate.IsZero() && date >= float64(endDate.AddDate(0, 0, 1).Unix())) {
+				continue
+			}
+		}
+		key := [2]int{int((lon - g.minLon) / g.dLon), int((lat - g.minLat) / g.dLat)}
+		s := sums[key]
+		if s == nil {
+			s = &sum{}
+			sums[key] = s
+		}
+		s.total += dose
+		s.n++
+	}
+	cells := make([]contourCell, 0, len(sums))
+	for k, s := range sums {
+		cells = append(cells, contourCell{k[0], k[1], s.total / float64(s.n), s.n})
+	}
+	return cells
+}
+
+// contourField places the cell averages on the grid and interpolates the
+// empty cells within fill cells of a measured one (inverse distance
+// squared). Cells left without a value are NaN.
+func contourField(g contourGrid, cells []contourCell, fill int) (field [][]float64, measured, filled int) {
+	field = make([][]float64, g.rows)
+	for y := range field {
+		field[y] = make([]float64, g.cols)
+		for x := range field[y] {
+			field[y][x] = math.NaN()
+		}
+	}
+	for _, c := range cells {
+		// floor() can put a marker on the far edge into the next cell.
+		x, y := min(max(c.x, 0), g.cols-1), min(max(c.y, 0), g.rows-1)
+		field[y][x] = c.avg
+		measured++
+	}
+	if fill == 0 {
+		return field, measured, 0
+	}
+
+	out := make([][]float64, g.rows)
+	for y := range out {
+		out[y] = slices.Clone(field[y])
+		for x := range out[y] {
+			if !math.IsNaN(field[y][x]) {
+				continue
+			}
+			var num, den float64
+			for dy := -fill; dy <= fill; dy++ {
+				for dx := -fill; dx <= fill; dx++ {
+					ny, nx := y+dy, x+dx
+					if ny < 0 || ny >= g.rows || nx < 0 || nx >= g.cols || math.IsNaN(field[ny][nx]) {
+						continue
+					}
+					d2 := float64(dx*dx + dy*dy)
+					if d2 > float64(fill*fill) {
+						continue
+					}
+					num += field[ny][nx] / d2
+					den += 1 / d2
+				}
+			}
+			if den > 0 {
+				out[y][x] = num / den
+				filled++
+			}
+		}
+	}
+	return out, measured, filled
+}
+
+// contourLines traces the isolines of field at level with marching squares
+// over the cell centres and joins the segments into lines, in fractional
+// cell coordinates. Squares with an empty corner are skipped.
+func contourLines(field [][]float64, level float64) [][][2]float64 {
+	rows := len(field)
+	if rows < 2 {
+		return nil
+	}
+	cols := len(field[0])
+
+	// Segment endpoints are crossings on the edges between cell centres.
+	// Edge keys: 2*(y*cols+x) for the edge from (x, y) to (x+1, y),
+	// 2*(y*cols+x)+1 for the edge from (x, y) to (x, y+1).
+	crossings := map[int][2]float64{}
+	crossing := func(key int) int {
+		if _, ok := crossings[key]; ok {
+			return key
+		}
+		i := key / 2
+		x, y := i%cols, i/cols
+		x1, y1 := x+1, y
+		if key%2 == 1 {
+			x1, y1 = x, y+1
+		}
+		v0, v1 := field[y][x], field[y1][x1]
+		t := (level - v0) / (v1 - v0)
+		crossings[key] = [2]float64{float64(x) + t*float64(x1-x), float64(y) + t*float64(y1-y)}
+		return key
+	}
+
+	var segs [][2]int
+	for y := 0; y < rows-1; y++ {
+		for x := 0; x < cols-1; x++ {
+			bl, br, tr, tl := field[y][x], field[y][x+1], field[y+1][x+1], field[y+1][x]
+			if math.IsNaN(bl) || math.IsNaN(br) || math.IsNaN(tr) || math.IsNaN(tl) {
+				continue
+			}
+			c := 0
+			for bit, v := range []float64{bl, br, tr, tl} {
+				if v >= level {
+					c |= 1 << bit
+				}
+			}
+			bottom, top := 2*(y*cols+x), 2*((y+1)*cols+x)
+			left, right := 2*(y*cols+x)+1, 2*(y*cols+x+1)+1
+			add := func(a, b int) { segs = append(segs, [2]int{crossing(a), crossing(b)}) }
+			centreAbove := (bl+br+tr+tl)/4 >= level
+			switch c {
+			case 1, 14:
+				add(left, bottom)
+			case 2, 13:
+				add(bottom, right)
+			case 3, 12:
+				add(left, right)
+			case 4, 11:
+				add(right, top)
+			case 6, 9:
+				add(bottom, top)
+			case 7, 8:
+				add(left, top)
+			case 5: // bl and tr above
+				if centreAbove {
+					add(left, top)
+					add(bottom, right)
+				} else {
+					add(left, bottom)
+					add(right, top)
+				}
+			case 10: // br and tl above
+				if centreAbove {
+					add(left, bottom)
+					add(right, top)
+				} else {
+					add(left, top)
+					add(bottom, right)
+				}
+			}
+		}
+	}
+
+	// Each crossing joins at most two segments; walk the chains.
+	at := map[int][]int{}
+	for i, s := range segs {
+		at[s[0]] = append(at[s[0]], i)
+		at[s[1]] = append(at[s[1]], i)
+	}
+	used := make([]bool, len(segs))
+	next := func(key int) (int, bool) {
+		for _, i := range at[key] {
+			if !used[i] {
+				used[i] = true
+				if segs[i][0] == key {
+					return segs[i][1], true
+				}
+				return segs[i][0], true
+			}
+		}
+		return 0, false
+	}
+	var lines [][][2]float64
+	for i, s := range segs {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		keys := []int{s[0], s[1]}
+		for k, ok := next(keys[len(keys)-1]); ok; k, ok = next(k) {
+			keys = append(keys, k)
+		}
+		var back []int
+		for k, ok := next(keys[0]); ok; k, ok = next(k) {
+			back = append(back, k)
+		}
+		slices.Reverse(back)
+		keys = append(back, keys...)
+
+		line := make([][2]float64, len(keys))
+		for j, k := range keys {
+			line[j] = crossings[k]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func doseContoursResult(g contourGrid, cells []contourCell, levels []float64, fill int, source string, startDate, endDate time.Time) (*mcp.CallToolResult, error) {
+	if len(cells) == 0 {
+		return mcp.NewToolResultError("No measurements found in the area for the selected period"), nil
+	}
+	field, measured, filled := contourField(g, cells, fill)
+
+	markers := 0
+	minAvg, maxAvg := math.Inf(1), math.Inf(-1)
+	for _, c := range cells {
+		markers += c.markers
+		minAvg, maxAvg = min(minAvg, c.avg), max(maxAvg, c.avg)
+	}
+
+	type levelLines struct {
+		level float64
+		lines [][][2]float64
+	}
+	var traced []levelLines
+	vertices := 0
+	for _, level := range levels {
+		lines := contourLines(field, level)
+		for _, l := range lines {
+			vertices += len(l)
+		}
+		traced = append(traced, levelLines{level, lines})
+	}
+
+	// Over the vertex budget, drop the shortest lines (small islands of
+	// one or two cells) first.
+	dropped := 0
+	if vertices > maxContourVertices {
+		type ref struct{ level, line, n int }
+		var refs []ref
+		for i, t := range traced {
+			for j, l := range t.lines {
+				refs = append(refs, ref{i, j, len(l)})
+			}
+		}
+		slices.SortFunc(refs, func(a, b ref) int { return a.n - b.n })
+		drop := map[[2]int]bool{}
+		for _, r := range refs {
+			if vertices <= maxContourVertices {
+				break
+			}
+			drop[[2]int{r.level, r.line}] = true
+			vertices -= r.n
+			dropped++
+		}
+		for i := range traced {
+			kept := traced[i].lines[:0]
+			for j, l := range traced[i].lines {
+				if !drop[[2]int{i, j}] {
+					kept = append(kept, l)
+				}
+			}
+			traced[i].lines = kept
+		}
+	}
+
+	cellKm2 := g.cellKm2()
+	features := make([]map[string]any, 0, len(traced))
+	var empty []float64
+	for _, t := range traced {
+		above := 0
+		for _, row := range field {
+			for _, v := range row {
+				if v >= t.level {
+					above++
+				}
+			}
+		}
+		if len(t.lines) == 0 {
+			empty = append(empty, t.level)
+			continue
+		}
+		coords := make([][][]float64, len(t.lines))
+		for i, l := range t.lines {
+			coords[i] = make([][]float64, len(l))
+			for j, p := range l {
+				coords[i][j] = g.point(p[0], p[1])
+			}
+		}
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"geometry": map[string]any{
+				"type":        "MultiLineString",
+				"coordinates": coords,
+			},
+			"properties": map[string]any{
+				"level_usvh":     t.level,
+				"lines":          len(t.lines),
+				"area_above_km2": roundSignificant(float64(above)*cellKm2, 3),
+				"cells_above":    above,
+			},
+		})
+	}
+
+	result := map[string]any{
+		"type":     "FeatureCollection",
+		"features": features,
+		"levels":   levels,
+		"bbox": map[string]any{
+			"min_lat": g.minLat,
+			"max_lat": g.maxLat,
+			"min_lon": g.minLon,
+			"max_lon": g.maxLon,
+		},
+		"map_url": bboxMapURL(g.minLat, g.maxLat, g.minLon, g.maxLon),
+		"grid": map[string]any{
+			"cols":           g.cols,
+			"rows":           g.rows,
+			"cell_km":        roundSignificant(math.Sqrt(cellKm2), 3),
+			"cells_measured": measured,
+			"cells_filled":   filled,
+			"cells_empty":    g.cols*g.rows - measured - filled,
+			"fill_cells":     fill,
+			"markers":        markers,
+		},
+		"cell_averages":      map[string]any{"min_usvh": minAvg, "max_usvh": maxAvg},
+		"source":             source,
+		"method":             "Marker dose rates averaged per grid cell; empty cells within fill_cells of measured cells interpolated by inverse distance squared; isolines traced with marching squares between cell centres. Coordinates are [lon, lat] (GeoJSON order).",
+		"_ai_hint":           "The result is a GeoJSON FeatureCollection: pass type and features to a map library as they are. Contours follow the measured routes (mostly roads) and are interpolated only a few cells from them, so lines stop where measurements stop; do not describe unmeasured areas. area_above_km2 counts measured and interpolated cells only. Dose rates are cell averages, so single hotspots above the top level may exist inside lower contours. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if len(empty) > 0 {
+		result["levels_without_contours"] = empty
+	}
+	if dropped > 0 {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("%d of the shortest lines were dropped to keep the response under %d vertices; use a coarser grid_size, a smaller area or fewer levels for the full detail.", dropped, maxContourVertices)
+	}
+	if !startDate.IsZero() || !endDate.IsZero() {
+		window := map[string]any{}
+		if !startDate.IsZero() {
+			window["start_date"] = startDate.Format("2006-01-02")
+		}
+		if !endDate.IsZero() {
+			window["end_date"] = endDate.Format("2006-01-02")
+		}
+		result["time_window"] = window
+	}
+	return jsonResult(result)
+}
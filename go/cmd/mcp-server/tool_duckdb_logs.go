@@ -11,12 +11,12 @@ import (
 var queryDuckDBLogsToolDef = mcp.NewTool(
 	"query_duckdb_logs",
 	mcp.WithDescription(
-		"Query MCP AI logs stored in DuckDB. Supports simple SQL SELECT queries.",
+		"Query MCP tool call logs stored in DuckDB. Supports simple SQL SELECT queries. One row per call in mcp_events: created_at, session_id, user_id, user_email, tool_name, params, params_hash, generated_query, duration_ms, result_bytes, result_count, client_info, commit_hash, error, sample_rate, result (the result text, when under MCP_LOG_RESULT_MAX_BYTES).",
 	),
 	mcp.WithString(
 		"query",
 		mcp.Required(),
-		mcp.Description("SQL SELECT query to execute against mcp_ai_query_log"),
+		mcp.Description("SQL SELECT query to execute against mcp_events (mcp_query_log and mcp_ai_query_log remain as views over it)"),
 	),
 )
 
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var exportCSVToolDef = mcp.NewTool("export_csv",
+	mcp.WithDescription("Prepare a CSV download of every measurement in a bounding box or within a radius of a point, for result sets of tens of thousands of rows that do not fit in a tool result. Returns a download_url for /api/export/measurements.csv and the number of rows the file will contain; the rows themselves are not returned here. Give the user the download_url rather than trying to fetch it. The URL is pinned to the current dataset snapshot, so it keeps returning the same rows. Requires the database."),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
+	mcp.WithNumber("min_lat",
+		mcp.Description("Southern boundary latitude (set all four of min_lat, max_lat, min_lon, max_lon, or lat and lon instead)"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("max_lat",
+		mcp.Description("Northern boundary latitude"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("min_lon",
+		mcp.Description("Western boundary longitude"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("max_lon",
+		mcp.Description("Eastern boundary longitude"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("lat",
+		mcp.Description("Latitude of the centre point, instead of a bounding box"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("lon",
+		mcp.Description("Longitude of the centre point, instead of a bounding box"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("radius_m",
+		mcp.Description("Radius around the point in meters (default: 1500, max: 50000)"),
+		mcp.Min(25), mcp.Max(50000),
+	),
+	mcp.WithString("start_date",
+		mcp.Description("Only measurements on or after this date (YYYY-MM-DD)"),
+	),
+	mcp.WithString("end_date",
+		mcp.Description("Only measurements on or before this date (YYYY-MM-DD)"),
+	),
+	mcp.WithString("detector",
+		mcp.Description("Filter by detector name. Partial match; case, spaces and hyphens are ignored and known aliases are included."),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of rows in the file (default: 100000, max: 1000000)"),
+		mcp.Min(1), mcp.Max(1000000),
+	),
+	withSnapshotParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// exportArgs are the export_csv arguments that become query parameters of
+// the download URL.
+var exportArgs = []string{
+	"min_lat", "max_lat", "min_lon", "max_lon", "lat", "lon", "radius_m",
+	"start_date", "end_date", "detector", "limit",
+}
+
+func handleExportCSV(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !dbAvailable() {
+		return mcp.NewToolResultError("CSV export requires database access"), nil
+	}
+
+	// The arguments go through the REST route's parser, so the tool accepts
+	// exactly what the download URL will.
+	args, _ := req.Params.Arguments.(map[string]any)
+	q := url.Values{}
+	for _, k := range exportArgs {
+		switch v := args[k].(type) {
+		case nil:
+		case string:
+			if v != "" {
+				q.Set(k, v)
+			}
+		default:
+			if f, ok := toFloat(v); ok {
+				q.Set(k, strconv.FormatFloat(f, 'f', -1, 64))
+			} else {
+				q.Set(k, fmt.Sprint(v))
+			}
+		}
+	}
+	if pin := snapshotPinFrom(ctx); pin != nil {
+		q.Set("snapshot_id", pin.snap.id())
+	}
+	f, err := parseMeasurementExportFilter(q)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	query, qargs := f.countSQL()
+	row, err := queryRow(ctx, query, qargs...)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	rows, _ := toFloat(row["total"])
+	snapshotBound(ctx) // the count and the URL both apply the pin
+
+	result := map[string]any{
+		"download_url": serverURL(measurementExportPath + "?" + q.Encode()),
+		"format":       "text/csv",
+		"rows":         int(rows),
+		"columns":      measurementExportColumns,
+		"filters":      f.describe(),
+		"note":         "The file is streamed by the server as it is read, oldest measurement first. doserate_usvh is in µSv/h and count_rate_cpm in counts per minute.",
+		"_ai_hint":     "Give the user the download_url as a link. Do not fetch it or summarize its contents as if they had been read; the rows are not part of this result.",
+	}
+	if int(rows) >= f.limit {
+		result["truncated"] = true
+		result["limit_note"] = fmt.Sprintf("%d or more measurements match and the file stops at limit. Narrow the area or dates, or raise limit, for the rest.", f.limit)
+	}
+	return jsonResult(result)
+}
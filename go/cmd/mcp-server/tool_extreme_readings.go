@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -12,14 +14,29 @@ import (
 // Tool Definition
 
 var queryExtremeReadingsToolDef = mcp.NewTool("query_extreme_readings",
-	mcp.WithDescription("Find the highest or lowest radiation readings in the database with full location details. Use this to identify extreme measurements globally or within a specific region. Supports excluding anomalous devices or geographic areas."),
+	mcp.WithDescription("Find the highest or lowest radiation readings in the database with full location details. Use this to identify extreme measurements globally or within a specific region. Supports excluding anomalous devices or geographic areas. mode='percentile' returns readings beyond a percentile of the selected area instead of the absolute extremes, which are dominated by a few known anomalies; use it with a region for 'unusually high' questions."),
 	mcp.WithString("direction",
 		mcp.Description("'highest' for maximum readings or 'lowest' for minimum readings"),
 		mcp.Enum("highest", "lowest"),
 		mcp.DefaultString("highest"),
 	),
+	mcp.WithString("mode",
+		mcp.Description("'absolute' (the most extreme readings) or 'percentile' (the most recent readings above the given percentile, or below 100 minus it for direction='lowest', of all readings matching the filters)"),
+		mcp.Enum("absolute", "percentile"),
+		mcp.DefaultString("absolute"),
+	),
+	mcp.WithNumber("percentile",
+		mcp.Description("Percentile threshold for mode='percentile' (default: 99.9)"),
+		mcp.Min(50), mcp.Max(99.999),
+		mcp.DefaultNumber(99.9),
+	),
 	mcp.WithNumber("limit",
-		mcp.Description("Number of readings to return (1-100)"),
+		mcp.Description("Number of readings to return (default: 10, max: 100)"),
+		mcp.Min(1), mcp.Max(100),
+		mcp.DefaultNumber(10),
+	),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
 	),
 	mcp.WithNumber("min_lat",
 		mcp.Description("Southern boundary for optional geographic filter"),
@@ -33,14 +50,92 @@ var queryExtremeReadingsToolDef = mcp.NewTool("query_extreme_readings",
 	mcp.WithNumber("max_lon",
 		mcp.Description("Eastern boundary for optional geographic filter"),
 	),
+	mcp.WithString("start_date",
+		mcp.Description("Only readings captured on or after this day (YYYY-MM-DD, UTC)"),
+	),
+	mcp.WithString("end_date",
+		mcp.Description("Only readings captured on or before this day (YYYY-MM-DD, UTC)"),
+	),
 	mcp.WithArray("exclude_devices",
 		mcp.Description("Array of device IDs to exclude from results (e.g., ['bGeigie-2113', 'bGeigie-456'] to filter out anomalous sources)"),
 	),
 	mcp.WithString("exclude_areas",
 		mcp.Description("JSON array of geographic bounding boxes to exclude. Format: [{\"min_lat\":51.8,\"max_lat\":52.0,\"min_lon\":-8.6,\"max_lon\":-8.3}] to exclude Cork, Ireland. Can specify multiple areas to exclude."),
 	),
+	withSpatialParams(),
+	withSnapshotParam(),
+	withCompactParam(),
+	withCitationParam(),
 )
 
+// extremeBaseCondition drops zero and implausible readings.
+const extremeBaseCondition = "doserate > 0 AND doserate < 10000"
+
+// extremeReadingsQuery selects readings from table ordered for direction.
+// The limit is the query's only parameter.
+func extremeReadingsQuery(table, where, direction string) string {
+	orderDir := "DESC"
+	if direction == "lowest" {
+		orderDir = "ASC"
+	}
+	return fmt.Sprintf(`
+		SELECT
+			id,
+			doserate,
+			lat,
+			lon,
+			device_id,
+			to_timestamp(date)::TIMESTAMP AS captured_at,
+			trackid,
+			detector
+		FROM %s
+		WHERE %s
+		ORDER BY doserate %s
+		LIMIT ?
+	`, table, where, orderDir)
+}
+
+// extremePercentileQuery selects the readings of table beyond a quantile of
+// the filtered set, newest first, with the threshold, the median, the size
+// of the set and the number of readings beyond the threshold. Its
+// parameters are the quantile (0-1) and the limit. approx_quantile keeps
+// this to one pass over the markers.
+func extremePercentileQuery(table, where, direction string) string {
+	cmp := ">"
+	if direction == "lowest" {
+		cmp = "<"
+	}
+	return fmt.Sprintf(`
+		WITH filtered AS (
+			SELECT id, doserate, lat, lon, device_id, date, trackid, detector
+			FROM %s
+			WHERE %s
+		), stats AS (
+			SELECT approx_quantile(doserate, CAST(? AS FLOAT)) AS threshold,
+				approx_quantile(doserate, 0.5::FLOAT) AS median,
+				count(*) AS total
+			FROM filtered
+		)
+		SELECT
+			f.id,
+			f.doserate,
+			f.lat,
+			f.lon,
+			f.device_id,
+			to_timestamp(f.date)::TIMESTAMP AS captured_at,
+			f.trackid,
+			f.detector,
+			s.threshold,
+			s.median,
+			s.total,
+			count(*) OVER () AS beyond
+		FROM filtered f, stats s
+		WHERE f.doserate %s s.threshold
+		ORDER BY f.date DESC
+		LIMIT ?
+	`, table, where, cmp)
+}
+
 // Handler
 
 func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -49,15 +144,18 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 	}
 
 	direction := req.GetString("direction", "highest")
-	limit := req.GetInt("limit", 10)
-	if limit < 1 || limit > 100 {
-		limit = 10
+	mode := req.GetString("mode", "absolute")
+	if mode != "absolute" && mode != "percentile" {
+		return mcp.NewToolResultError("mode must be 'absolute' or 'percentile'"), nil
 	}
-
-	// Build query
-	orderDir := "DESC"
-	if direction == "lowest" {
-		orderDir = "ASC"
+	percentile := req.GetFloat("percentile", 99.9)
+	if percentile < 50 || percentile > 99.999 {
+		return mcp.NewToolResultError("percentile must be between 50 and 99.999"), nil
+	}
+	defLimit, maxLimit := toolLimits("query_extreme_readings", 10, 100)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		limit = defLimit
 	}
 
 	// Check for geographic filter
@@ -71,6 +169,24 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 		hasGeoFilter = true
 	}
 
+	// Time window; end_date includes the whole day
+	var startDate, endDate time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"start_date", &startDate}, {"end_date", &endDate}} {
+		if v := req.GetString(p.name, ""); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return mcp.NewToolResultError(p.name + " must be in YYYY-MM-DD format"), nil
+			}
+			*p.dst = t
+		}
+	}
+	if !startDate.IsZero() && !endDate.IsZero() && endDate.Before(startDate) {
+		return mcp.NewToolResultError("end_date must not be before start_date"), nil
+	}
+
 	// Parse exclusion parameters
 	excludeDevices := req.GetStringSlice("exclude_devices", []string{})
 
@@ -87,9 +203,40 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 		}
 	}
 
+	spatial, err := spatialFilterFromRequest(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if spatial.needsSpatial() && !duckDBSpatial() {
+		return mcp.NewToolResultError("polygon and distance filters need the DuckDB spatial extension, which is not loaded; use min_lat/max_lat/min_lon/max_lon instead"), nil
+	}
+
+	// Without the attach, read the exported snapshot (see
+	// analytics_snapshot.go), which holds only the most extreme readings.
+	table, source := "postgres_db.public.markers", "duckdb_postgres_attach"
+	var snapshot map[string]any
+	if !duckDBPostgresAttached() {
+		if mode == "percentile" {
+			return mcp.NewToolResultError("mode='percentile' needs the full markers table, but the database is not attached to the analytics engine; use mode='absolute'"), nil
+		}
+		snapTable, takenAt, ok := snapshotSource(extremeSnapshotFile)
+		if !ok {
+			return mcp.NewToolResultError("The database is not attached to the analytics engine and no snapshot has been exported yet"), nil
+		}
+		table, source = snapTable, "parquet_snapshot"
+		snapshot = snapshotInfo(takenAt, fmt.Sprintf("The database is not attached to the analytics engine; these readings come from the last snapshot of the %d highest and %d lowest readings. Newer uploads are missing, and filtered queries only search that set.", snapshotExtremeRows, snapshotExtremeRows))
+	}
+
 	// Build WHERE clause with exclusions
 	var whereConditions []string
-	whereConditions = append(whereConditions, "doserate > 0 AND doserate < 10000")
+	whereConditions = append(whereConditions, extremeBaseCondition)
+	whereConditions = append(whereConditions, spatial.duckdbSQL()...)
+
+	// Pin to the dataset snapshot (dataset_snapshot.go); the Parquet export
+	// has its own moment in time and is not pinned.
+	if snapshot == nil && snapshotPinFrom(ctx) != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("id <= %d", snapshotBound(ctx)))
+	}
 
 	// Add geographic filter
 	if hasGeoFilter {
@@ -99,6 +246,14 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 		))
 	}
 
+	// Add time window
+	if !startDate.IsZero() {
+		whereConditions = append(whereConditions, fmt.Sprintf("date >= %d", startDate.Unix()))
+	}
+	if !endDate.IsZero() {
+		whereConditions = append(whereConditions, fmt.Sprintf("date < %d", endDate.AddDate(0, 0, 1).Unix()))
+	}
+
 	// Add device exclusions
 	if len(excludeDevices) > 0 {
 		deviceList := make([]string, len(excludeDevices))
@@ -118,30 +273,29 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 		))
 	}
 
-	query := fmt.Sprintf(`
-		SELECT
-			id,
-			doserate,
-			lat,
-			lon,
-			device_id,
-			to_timestamp(date)::TIMESTAMP AS captured_at,
-			trackid,
-			detector
-		FROM postgres_db.public.markers
-		WHERE %s
-		ORDER BY doserate %s
-		LIMIT %d
-	`, strings.Join(whereConditions, " AND "), orderDir, limit)
-
-	// Execute query
-	rows, err := duckDB.Query(query)
+	// Execute query; the unfiltered shape reuses its prepared statement
+	var rows *sql.Rows
+	where := strings.Join(whereConditions, " AND ")
+	quantile := percentile / 100
+	if direction == "lowest" {
+		quantile = 1 - quantile
+	}
+	switch {
+	case mode == "percentile":
+		rows, err = duckDB.Query(extremePercentileQuery(table, where, direction), quantile, limit)
+	case len(whereConditions) == 1 && snapshot == nil:
+		rows, err = duckPreparedQuery(extremeReadingsQuery(table, where, direction), limit)
+	default:
+		rows, err = duckDB.Query(extremeReadingsQuery(table, where, direction), limit)
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Query failed: %v", err)), nil
 	}
 	defer rows.Close()
 
 	var results []map[string]any
+	var threshold, median float64
+	var total, beyond int64
 	for rows.Next() {
 		var id int64
 		var doserate float64
@@ -149,7 +303,11 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 		var deviceID, trackID, detector *string
 		var capturedAt any
 
-		if err := rows.Scan(&id, &doserate, &lat, &lon, &deviceID, &capturedAt, &trackID, &detector); err != nil {
+		dest := []any{&id, &doserate, &lat, &lon, &deviceID, &capturedAt, &trackID, &detector}
+		if mode == "percentile" {
+			dest = append(dest, &threshold, &median, &total, &beyond)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			continue
 		}
 
@@ -172,16 +330,55 @@ func handleQueryExtremeReadings(ctx context.Context, req mcp.CallToolRequest) (*
 		if detector != nil {
 			result["detector"] = *detector
 		}
+		if mode == "percentile" && median > 0 {
+			result["times_median"] = doserate / median
+		}
 
 		results = append(results, result)
 	}
+	recordRead(ctx, sourceDuckDB, len(results))
 
-	return jsonResult(map[string]any{
+	response := map[string]any{
 		"direction":          direction,
+		"mode":               mode,
 		"readings":           results,
 		"count":              len(results),
-		"source":             "duckdb_postgres_attach",
-		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements. (3) Make location coordinates clickable links to the map: https://simplemap.safecast.org/?lat=LAT&lon=LON&zoom=15",
+		"source":             source,
+		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements. (3) Make location coordinates clickable links to the map: "+mapLinkTemplate("LAT", "LON"),
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
-	})
+	}
+	if hasGeoFilter {
+		response["map_url"] = bboxMapURL(minLat, maxLat, minLon, maxLon)
+	}
+	if d := spatial.describe(); d != nil {
+		response["spatial_filter"] = d
+	}
+	if !startDate.IsZero() || !endDate.IsZero() {
+		window := map[string]any{}
+		if !startDate.IsZero() {
+			window["start_date"] = startDate.Format("2006-01-02")
+		}
+		if !endDate.IsZero() {
+			window["end_date"] = endDate.Format("2006-01-02")
+		}
+		response["time_window"] = window
+	}
+	if mode == "percentile" {
+		pct := map[string]any{
+			"percentile": percentile,
+			"order":      "newest first",
+		}
+		if len(results) > 0 {
+			pct["threshold_usvh"] = threshold
+			pct["median_usvh"] = median
+			pct["readings_considered"] = total
+			pct["readings_beyond_threshold"] = beyond
+		}
+		response["percentile"] = pct
+		response["_ai_hint"] = response["_ai_hint"].(string) + " (4) mode='percentile': these are the most recent readings beyond the stated percentile of all readings matching the filters, not the most extreme ones; threshold_usvh is that percentile (approximate), and times_median compares each reading with the median of the same set. Describe them as statistically unusual for the area, not as the highest ever recorded."
+	}
+	if snapshot != nil {
+		response["snapshot"] = snapshot
+	}
+	return measurementResult(ctx, response)
 }
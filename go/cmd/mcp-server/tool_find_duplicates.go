@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var findDuplicateUploadsToolDef = mcp.NewTool("find_duplicate_uploads",
+	mcp.WithDescription("Find bGeigie uploads that are probably the same drive uploaded more than once, to help curators clean up the uploads table. Checks three signals: filenames that match after ignoring case, extension and copy suffixes like ' (1)'; identical file sizes from the same detector; and tracks whose markers overlap (same timestamp and position). Returns merge candidates with a suggested upload to keep (the oldest). Read-only: nothing is merged or deleted. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("method",
+		mcp.Description("Which signal to check: 'filename', 'size', 'markers', or 'all' (default). 'markers' compares measurement points and is the slowest."),
+		mcp.Enum("all", "filename", "size", "markers"),
+		mcp.DefaultString("all"),
+	),
+	mcp.WithNumber("days",
+		mcp.Description("Only consider uploads created in the last N days (default: 90, max: 3650)"),
+		mcp.Min(1), mcp.Max(3650),
+		mcp.DefaultNumber(90),
+	),
+	mcp.WithNumber("min_overlap",
+		mcp.Description("For the markers check: minimum fraction of the smaller track's markers that must also appear in the other track (default: 0.5)"),
+		mcp.Min(0.01), mcp.Max(1),
+		mcp.DefaultNumber(0.5),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of candidate groups per check (default: 50, max: 500)"),
+		mcp.Min(1), mcp.Max(500),
+		mcp.DefaultNumber(50),
+	),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleFindDuplicateUploads(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !dbAvailable() {
+		return mcp.NewToolResultError("Database connection required for duplicate upload detection"), nil
+	}
+
+	method := req.GetString("method", "all")
+	days := req.GetInt("days", 90)
+	minOverlap := req.GetFloat("min_overlap", 0.5)
+	defLimit, maxLimit := toolLimits("find_duplicate_uploads", 50, 500)
+	limit := req.GetInt("limit", defLimit)
+
+	switch method {
+	case "all", "filename", "size", "markers":
+	default:
+		return mcp.NewToolResultError("method must be one of: all, filename, size, markers"), nil
+	}
+	if days < 1 || days > 3650 {
+		return mcp.NewToolResultError("days must be between 1 and 3650"), nil
+	}
+	if minOverlap <= 0 || minOverlap > 1 {
+		return mcp.NewToolResultError("min_overlap must be greater than 0 and at most 1"), nil
+	}
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("limit must be between 1 and %d", maxLimit)), nil
+	}
+
+	candidates := map[string]any{}
+	total := 0
+
+	if method == "all" || method == "filename" {
+		groups, err := duplicateUploadGroups(ctx, duplicateFilenameQuery, days, limit)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Filename check failed: %v", err)), nil
+		}
+		candidates["same_filename"] = groups
+		total += len(groups)
+	}
+
+	if method == "all" || method == "size" {
+		groups, err := duplicateUploadGroups(ctx, duplicateSizeQuery, days, limit)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("File size check failed: %v", err)), nil
+		}
+		candidates["same_size_and_detector"] = groups
+		total += len(groups)
+	}
+
+	if method == "all" || method == "markers" {
+		pairs, err := overlappingMarkerUploads(ctx, days, minOverlap, limit)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Marker overlap check failed: %v", err)), nil
+		}
+		candidates["overlapping_markers"] = pairs
+		total += len(pairs)
+	}
+
+	result := map[string]any{
+		"method":             method,
+		"days":               days,
+		"candidate_count":    total,
+		"candidates":         candidates,
+		"source":             "database",
+		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) These are CANDIDATES for a human curator, not confirmed duplicates. A shared filename or size alone is weak evidence; overlapping markers are strong evidence. Say which signal matched for each group. (2) 'keep' is the oldest upload in the group and 'merge' lists the others; this is a suggestion only, the tool changes nothing. (3) Present results in a markdown table with upload IDs, track IDs, filenames and uploaders. (4) State objective facts only - no personal pronouns (I, we, you), exclamations, or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	return jsonResult(result)
+}
+
+// duplicateUploadColumns is the per-upload object inside each group.
+const duplicateUploadColumns = `json_build_object(
+				'upload_id', c.id,
+				'track_id', c.track_id,
+				'filename', c.filename,
+				'file_size', c.file_size,
+				'detector', c.detector,
+				'username', c.username,
+				'created_at', c.created_at
+			) ORDER BY c.created_at, c.id`
+
+// Both grouping queries take $1 = days and $2 = limit and return one row per
+// group with match_key, upload_count and uploads (oldest first).
+var duplicateFilenameQuery = `
+	WITH c AS (
+		SELECT u.id, u.track_id, u.filename, u.file_size, u.detector, u.created_at,
+			COALESCE(usr.username, u.username) AS username,
+			lower(regexp_replace(
+				regexp_replace(u.filename, '\.[A-Za-z0-9]+$', ''),
+				'[\s_-]*(\(\d+\)|copy)$', '', 'i')) AS match_key
+		FROM uploads u
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+		WHERE u.filename IS NOT NULL AND u.filename <> ''
+		  AND u.created_at >= NOW() - make_interval(days => $1)
+	)
+	SELECT c.match_key, COUNT(*) AS upload_count,
+		json_agg(` + duplicateUploadColumns + `) AS uploads
+	FROM c
+	GROUP BY c.match_key
+	HAVING COUNT(*) > 1
+	ORDER BY COUNT(*) DESC, c.match_key
+	LIMIT $2`
+
+var duplicateSizeQuery = `
+	WITH c AS (
+		SELECT u.id, u.track_id, u.filename, u.file_size, u.detector, u.created_at,
+			COALESCE(usr.username, u.username) AS username,
+			u.file_size::text || ' bytes, ' || COALESCE(u.detector, 'unknown detector') AS match_key
+		FROM uploads u
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+		WHERE u.file_size > 0
+		  AND u.created_at >= NOW() - make_interval(days => $1)
+	)
+	SELECT c.match_key, COUNT(*) AS upload_count,
+		json_agg(` + duplicateUploadColumns + `) AS uploads
+	FROM c
+	GROUP BY c.match_key
+	HAVING COUNT(*) > 1
+	ORDER BY COUNT(*) DESC, c.match_key
+	LIMIT $2`
+
+func duplicateUploadGroups(ctx context.Context, query string, days, limit int) ([]map[string]any, error) {
+	rows, err := queryRows(ctx, query, days, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]map[string]any, 0, len(rows))
+	for _, r := range rows {
+		uploads, _ := r["uploads"].([]any)
+		group := map[string]any{
+			"match_key":    r["match_key"],
+			"upload_count": r["upload_count"],
+			"uploads":      uploads,
+		}
+		if len(uploads) > 0 {
+			var merge []any
+			for _, u := range uploads[1:] {
+				if m, ok := u.(map[string]any); ok {
+					merge = append(merge, m["upload_id"])
+				}
+			}
+			if first, ok := uploads[0].(map[string]any); ok {
+				group["keep"] = first["upload_id"]
+			}
+			group["merge"] = merge
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// overlappingMarkerUploads pairs recent uploads whose tracks cover the same
+// time span and counts markers recorded at the same instant and position in
+// both. Pairs are capped before the expensive count so a busy window cannot
+// turn into an unbounded self-join on markers.
+func overlappingMarkerUploads(ctx context.Context, days int, minOverlap float64, limit int) ([]map[string]any, error) {
+	query := `
+		WITH recent AS (
+			SELECT u.id, u.track_id, u.filename, u.created_at
+			FROM uploads u
+			WHERE u.track_id IS NOT NULL AND u.track_id <> ''
+			  AND u.created_at >= NOW() - make_interval(days => $1)
+		),
+		spans AS (
+			SELECT r.id, r.track_id, r.filename, r.created_at,
+				MIN(m.date) AS first_date, MAX(m.date) AS last_date, COUNT(*) AS markers
+			FROM recent r
+			JOIN markers m ON m.trackid = r.track_id
+			GROUP BY r.id, r.track_id, r.filename, r.created_at
+		),
+		pairs AS (
+			SELECT a.id AS keep_id, a.track_id AS keep_track, a.filename AS keep_filename, a.markers AS keep_markers,
+				b.id AS merge_id, b.track_id AS merge_track, b.filename AS merge_filename, b.markers AS merge_markers
+			FROM spans a
+			JOIN spans b ON (a.created_at, a.id) < (b.created_at, b.id)
+				AND a.track_id <> b.track_id
+				AND a.first_date <= b.last_date AND b.first_date <= a.last_date
+			LIMIT $3 * 4
+		),
+		scored AS (
+			SELECT p.*,
+				(SELECT COUNT(*)
+				 FROM markers ma
+				 JOIN markers mb ON mb.trackid = p.merge_track
+					AND mb.date = ma.date AND mb.lat = ma.lat AND mb.lon = ma.lon
+				 WHERE ma.trackid = p.keep_track) AS shared_markers
+			FROM pairs p
+		)
+		SELECT *, shared_markers::float8 / GREATEST(LEAST(keep_markers, merge_markers), 1) AS overlap_ratio
+		FROM scored
+		WHERE shared_markers::float8 / GREATEST(LEAST(keep_markers, merge_markers), 1) >= $2
+		ORDER BY shared_markers DESC
+		LIMIT $3`
+
+	rows, err := queryRows(ctx, query, days, minOverlap, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]map[string]any, 0, len(rows))
+	for _, r := range rows {
+		pairs = append(pairs, map[string]any{
+			"shared_markers": r["shared_markers"],
+			"overlap_ratio":  r["overlap_ratio"],
+			"keep": map[string]any{
+				"upload_id": r["keep_id"],
+				"track_id":  r["keep_track"],
+				"filename":  r["keep_filename"],
+				"markers":   r["keep_markers"],
+				"map_url":   trackMapURL(fmt.Sprint(r["keep_track"])),
+			},
+			"merge": map[string]any{
+				"upload_id": r["merge_id"],
+				"track_id":  r["merge_track"],
+				"filename":  r["merge_filename"],
+				"markers":   r["merge_markers"],
+				"map_url":   trackMapURL(fmt.Sprint(r["merge_track"])),
+			},
+		})
+	}
+	return pairs, nil
+}
@@ -13,6 +13,8 @@ var getSpectrumToolDef = mcp.NewTool("get_spectrum",
 		mcp.Min(1),
 		mcp.Required(),
 	),
+	withExplainParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -25,10 +27,10 @@ func handleGetSpectrum(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 		return mcp.NewToolResultError("marker_id must be a positive number"), nil
 	}
 
-	if dbAvailable() {
-		return getSpectrumDB(ctx, markerID)
-	}
-	return getSpectrumAPI(ctx, markerID)
+	return routeQuery(ctx, queryHistorical, sourceFuncs{
+		db:  func() (*mcp.CallToolResult, error) { return getSpectrumDB(ctx, markerID) },
+		api: func() (*mcp.CallToolResult, error) { return getSpectrumAPI(ctx, markerID) },
+	})
 }
 
 func getSpectrumDB(ctx context.Context, markerID int) (*mcp.CallToolResult, error) {
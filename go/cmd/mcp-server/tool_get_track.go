@@ -8,7 +8,7 @@ import (
 )
 
 var getTrackToolDef = mcp.NewTool("get_track",
-	mcp.WithDescription("Retrieve all radiation measurements recorded during a specific track/journey. Use list_tracks to find available track IDs first. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use https://simplemap.safecast.org as the base URL — NEVER use api.safecast.org, which does not host track data. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
+	mcp.WithDescription("Retrieve all radiation measurements recorded during a specific track/journey. Use list_tracks to find available track IDs first. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use "+mapBaseURL()+" as the base URL — NEVER use api.safecast.org, which does not host track data. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
 	mcp.WithString("track_id",
 		mcp.Description("Track identifier (bGeigie import ID or track ID)"),
 		mcp.Required(),
@@ -24,6 +24,13 @@ var getTrackToolDef = mcp.NewTool("get_track",
 		mcp.Min(1), mcp.Max(10000),
 		mcp.DefaultNumber(200),
 	),
+	withRawUnitsParam(),
+	withAltitudeParam(),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCompactParam(),
+	withOutputFormatParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -33,23 +40,28 @@ func handleGetTrack(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	limit := req.GetInt("limit", 200)
-	if limit < 1 || limit > 10000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 10000"), nil
+	defLimit, maxLimit := toolLimits("get_track", 200, 10000)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
 	fromID := req.GetInt("from", 0)
 	toID := req.GetInt("to", 0)
 
-	if dbAvailable() {
-		return getTrackDB(ctx, trackIDStr, fromID, toID, limit)
-	}
-	return getTrackAPI(ctx, trackIDStr, fromID, toID, limit)
+	ctx = rawUnitsContext(ctx, req)
+	ctx = altitudeContext(ctx, req)
+	return routeQuery(ctx, queryHistorical, sourceFuncs{
+		db:  func() (*mcp.CallToolResult, error) { return getTrackDB(ctx, trackIDStr, fromID, toID, limit) },
+		api: func() (*mcp.CallToolResult, error) { return getTrackAPI(ctx, trackIDStr, fromID, toID, limit) },
+	})
 }
 
-func getTrackDB(ctx context.Context, trackID string, fromID, toID, limit int) (*mcp.CallToolResult, error) {
+// getTrackSQL selects the markers of a track in time order, optionally
+// between two marker IDs, up to maxID (snapshotBound).
+func getTrackSQL(trackID string, fromID, toID, limit int, maxID int64) (string, []any) {
 	query := `
-		SELECT m.id, m.doserate AS value, 'µSv/h' AS unit,
+		SELECT m.id, m.doserate AS value, 'µSv/h' AS unit, m.countrate AS count_rate,
 			to_timestamp(m.date) AS captured_at,
 			m.lat AS latitude, m.lon AS longitude,
 			m.device_id, m.altitude AS height, m.detector,
@@ -58,10 +70,10 @@ func getTrackDB(ctx context.Context, trackID string, fromID, toID, limit int) (*
 		FROM markers m
 		LEFT JOIN uploads u ON u.track_id = m.trackid
 		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
-		WHERE m.trackid = $1`
+		WHERE m.trackid = $1 AND m.id <= $2`
 
-	args := []any{trackID}
-	argIdx := 2
+	args := []any{trackID, maxID}
+	argIdx := 3
 
 	if fromID != 0 {
 		query += fmt.Sprintf(" AND id >= $%d", argIdx)
@@ -77,14 +89,39 @@ func getTrackDB(ctx context.Context, trackID string, fromID, toID, limit int) (*
 	query += " ORDER BY date ASC"
 	query += fmt.Sprintf(" LIMIT $%d", argIdx)
 	args = append(args, limit)
+	return query, args
+}
 
-	rows, err := queryRows(ctx, query, args...)
+func getTrackDB(ctx context.Context, trackID string, fromID, toID, limit int) (*mcp.CallToolResult, error) {
+	query, args := getTrackSQL(trackID, fromID, toID, limit, snapshotBound(ctx))
+	rows, err := queryTyped[markerRow](ctx, query, args...)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	// Get total count for this track
-	countRow, _ := queryRow(ctx, `SELECT count(*) AS total FROM markers WHERE trackid = $1`, trackID)
+	measurements := make([]map[string]any, len(rows))
+	for i := range rows {
+		measurements[i] = trackMeasurement(ctx, &rows[i])
+	}
+
+	result := getTrackHead(trackID, fromID, toID, getTrackTotal(ctx, trackID))
+	result["count"] = len(measurements)
+	result["measurements"] = measurements
+
+	// Add uploader information if available (all rows of a track have the
+	// same uploader)
+	if len(rows) > 0 {
+		if uploader := rows[0].uploader(); uploader != nil {
+			result["uploader"] = uploader
+		}
+	}
+
+	return measurementResult(ctx, result)
+}
+
+// getTrackTotal counts the markers of a track.
+func getTrackTotal(ctx context.Context, trackID string) int {
+	countRow, _ := queryRow(ctx, `SELECT count(*) AS total FROM markers WHERE trackid = $1 AND id <= $2`, trackID, snapshotBound(ctx))
 	total := 0
 	if countRow != nil {
 		if t, ok := countRow["total"]; ok {
@@ -96,54 +133,43 @@ func getTrackDB(ctx context.Context, trackID string, fromID, toID, limit int) (*
 			}
 		}
 	}
+	return total
+}
 
-	measurements := make([]map[string]any, len(rows))
-	var uploaderUsername, uploaderEmail any
-	for i, r := range rows {
-		measurements[i] = map[string]any{
-			"id":    r["id"],
-			"value": r["value"],
-			"unit":  r["unit"],
-			"captured_at": r["captured_at"],
-			"location": map[string]any{
-				"latitude":  r["latitude"],
-				"longitude": r["longitude"],
-			},
-			"device_id":   r["device_id"],
-			"height":      r["height"],
-			"detector":    r["detector"],
-			"has_spectrum": r["has_spectrum"],
-		}
-
-		// Store uploader info from first row (all rows for same track have same uploader)
-		if i == 0 {
-			uploaderUsername = r["uploader_username"]
-			uploaderEmail = r["uploader_email"]
-		}
-	}
-
-	result := map[string]any{
+// getTrackHead is a database get_track result without its rows.
+func getTrackHead(trackID string, fromID, toID, total int) map[string]any {
+	return map[string]any{
 		"track_id":        trackID,
-		"map_url":         "https://simplemap.safecast.org/trackid/" + trackID,
-		"count":           len(measurements),
+		"map_url":         trackMapURL(trackID),
 		"total_available": total,
 		"source":          "database",
 		"from_marker":     nilIfZero(fromID),
 		"to_marker":       nilIfZero(toID),
-		"measurements":    measurements,
 		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+}
 
-	// Add uploader information if available
-	if uploaderUsername != nil && uploaderUsername != "" {
-		result["uploader"] = map[string]any{
-			"username": uploaderUsername,
-			"email":    uploaderEmail,
-		}
+// trackMeasurement turns a row of getTrackSQL into a measurement.
+func trackMeasurement(ctx context.Context, r *markerRow) map[string]any {
+	measurement := map[string]any{
+		"id":          nullable(r.ID),
+		"value":       nullable(r.Value),
+		"unit":        nullable(r.Unit),
+		"captured_at": nullable(r.CapturedAt),
+		"location": map[string]any{
+			"latitude":  nullable(r.Latitude),
+			"longitude": nullable(r.Longitude),
+		},
+		"device_id":    nullable(r.DeviceID),
+		"height":       nullable(r.Height),
+		"detector":     nullable(r.Detector),
+		"has_spectrum": nullable(r.HasSpectrum),
 	}
-
-	return jsonResult(result)
+	if rawUnits(ctx) {
+		measurement["count_rate"] = nullable(r.CountRate)
+	}
+	return measurement
 }
 
 func getTrackAPI(ctx context.Context, trackIDStr string, fromID, toID, limit int) (*mcp.CallToolResult, error) {
@@ -172,7 +198,7 @@ func getTrackAPI(ctx context.Context, trackIDStr string, fromID, toID, limit int
 			"track_id":     resp["trackID"],
 			"marker_count": resp["markerCount"],
 			"track_index":  resp["trackIndex"],
-			"map_url":      "https://simplemap.safecast.org/trackid/" + resp["trackID"].(string),
+			"map_url":      trackMapURL(resp["trackID"].(string)),
 		},
 		"count":           len(normalized),
 		"total_available": totalAvailable,
@@ -184,5 +210,5 @@ func getTrackAPI(ctx context.Context, trackIDStr string, fromID, toID, limit int
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
 
-	return jsonResult(result)
+	return measurementResult(ctx, result)
 }
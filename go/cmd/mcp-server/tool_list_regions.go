@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var listSupportedRegionsToolDef = mcp.NewTool("list_supported_regions",
+	mcp.WithDescription("List the country names accepted by search_tracks_by_location's country parameter, with the bounding box each one uses, plus the names of saved regions usable as region=<name>. Pass search to find the closest supported names for a country that was not recognised."),
+	mcp.WithString("search",
+		mcp.Description("Optional country name to look up, e.g. 'Korea' or 'Czechia'. Returns the exact match or the closest supported names."),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleListSupportedRegions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	names := countryNames()
+	search := strings.TrimSpace(req.GetString("search", ""))
+	hint := "Pass one of these names as country to search_tracks_by_location. For other areas use a saved region (region=<name>) or explicit min_lat, max_lat, min_lon, max_lon."
+	if search != "" {
+		if name, _, ok := lookupCountry(search); ok {
+			names = []string{name}
+			hint = fmt.Sprintf("%q is supported as %q.", search, name)
+		} else {
+			names = closestMatches(search, names, 5)
+			hint = fmt.Sprintf("%q is not a supported country name. The closest supported names are listed; if none fits, use explicit bounding box coordinates.", search)
+		}
+	}
+
+	countries := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		bbox := countryBoundingBoxes[name]
+		countries = append(countries, map[string]any{
+			"name":    name,
+			"min_lat": bbox[0],
+			"max_lat": bbox[1],
+			"min_lon": bbox[2],
+			"max_lon": bbox[3],
+		})
+	}
+
+	result := map[string]any{
+		"count":              len(countries),
+		"countries":          countries,
+		"_ai_hint":           hint,
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if search != "" {
+		result["search"] = search
+	}
+
+	// Saved regions live in DuckDB; list their names when it is available.
+	if regions, err := listSavedRegions(); err == nil {
+		saved := make([]string, 0, len(regions))
+		for _, r := range regions {
+			saved = append(saved, r.Name)
+		}
+		result["saved_regions"] = saved
+	}
+	return jsonResult(result)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -10,7 +11,13 @@ import (
 var listSensorsToolDef = mcp.NewTool("list_sensors",
 	mcp.WithDescription("Discover active fixed sensors (Pointcast, Solarcast, bGeigieZen, Notehub/Radnote, nGeigie, etc.) by location or type, returning device IDs, locations, status, and last reading timestamp. Use for sensor discovery and metadata only — this tool does NOT return radiation readings. When the user wants actual radiation values, use sensor_current instead. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
 	mcp.WithString("type",
-		mcp.Description("Filter by sensor type (e.g., 'Pointcast', 'Solarcast', 'bGeigieZen', etc.)"),
+		mcp.Description("Filter by sensor type (e.g., 'Pointcast', 'Solarcast', 'bGeigieZen', etc.). Matches transport or device name; case, spaces and hyphens are ignored and known aliases are included."),
+	),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
+	mcp.WithString("place",
+		mcp.Description(placeParamDescription+" A city searches about 25 km around it, a country its bounding box."),
 	),
 	mcp.WithNumber("min_lat",
 		mcp.Description("Southern boundary for geographic filter"),
@@ -28,35 +35,47 @@ var listSensorsToolDef = mcp.NewTool("list_sensors",
 		mcp.Description("Eastern boundary for geographic filter"),
 		mcp.Min(-180), mcp.Max(180),
 	),
+	withBBoxesParam("sensors"),
 	mcp.WithNumber("limit",
 		mcp.Description("Maximum number of sensors to return (default: 50, max: 1000)"),
 		mcp.Min(1), mcp.Max(1000),
 		mcp.DefaultNumber(50),
 	),
+	withExplainParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
 func handleListSensors(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if req.GetArguments()["bboxes"] != nil {
+		return handleListSensorsBoxes(ctx, req) // multi_bbox.go
+	}
 	sensorType := req.GetString("type", "")
 	minLat := req.GetFloat("min_lat", -90)
 	maxLat := req.GetFloat("max_lat", 90)
 	minLon := req.GetFloat("min_lon", -180)
 	maxLon := req.GetFloat("max_lon", 180)
-	limit := req.GetInt("limit", 50)
+	defLimit, maxLimit := toolLimits("list_sensors", 50, 1000)
+	limit := req.GetInt("limit", defLimit)
 
-	if limit < 1 || limit > 1000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 1000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
 	if dbAvailable() {
 		return listSensorsDB(ctx, sensorType, minLat, maxLat, minLon, maxLon, limit)
 	}
+	if demoMode {
+		return listSensorsDemo(sensorType, minLat, maxLat, minLon, maxLon, limit)
+	}
 	
 	// Fallback to API if database not available
 	return mcp.NewToolResultError("Database connection required for list_sensors tool. Please ensure DATABASE_URL is set to access real-time sensor data."), nil
 }
 
-func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLon, maxLon float64, limit int) (*mcp.CallToolResult, error) {
+// realtimeSensorTable returns the table holding real-time sensor data, or
+// "" when there is none, and the public tables it chose from.
+func realtimeSensorTable(ctx context.Context) (string, []string, error) {
 	// Check what tables are available in the database
 	tablesQuery := `
 		SELECT table_name 
@@ -67,7 +86,7 @@ func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLo
 	
 	tableRows, err := queryRows(ctx, tablesQuery)
 	if err != nil {
-		return mcp.NewToolResultError("Could not query database schema: " + err.Error()), nil
+		return "", nil, err
 	}
 	
 	// Look for tables that might contain real-time sensor data
@@ -85,6 +104,14 @@ func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLo
 			}
 		}
 	}
+	return realtimeTable, availableTables, nil
+}
+
+func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLon, maxLon float64, limit int) (*mcp.CallToolResult, error) {
+	realtimeTable, availableTables, err := realtimeSensorTable(ctx)
+	if err != nil {
+		return mcp.NewToolResultError("Could not query database schema: " + err.Error()), nil
+	}
 	
 	if realtimeTable == "" {
 		// If no real-time table found, return available tables for debugging
@@ -104,6 +131,9 @@ func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLo
 		// Filter by sensor type
 		// FIXED: Get the actual latest reading per device, not grouped by lat/lon
 		// which causes stale data when sensors move or have multiple positions
+		args = []interface{}{minLat, maxLat, minLon, maxLon}
+		next := len(args) + 1
+		bind := pgBinder(&args, &next)
 		query = fmt.Sprintf(`
 			SELECT
 				rm.device_id,
@@ -117,14 +147,15 @@ func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLo
 				SELECT device_id, MAX(measured_at) as max_measured_at
 				FROM %s
 				WHERE lat >= $1 AND lat <= $2 AND lon >= $3 AND lon <= $4
-					AND (COALESCE(transport, '') ILIKE $5 OR COALESCE(device_name, '') ILIKE $5)
+					AND (%s OR %s)
 				GROUP BY device_id
 			) latest ON rm.device_id = latest.device_id AND rm.measured_at = latest.max_measured_at
 			WHERE rm.lat >= $1 AND rm.lat <= $2 AND rm.lon >= $3 AND rm.lon <= $4
 			ORDER BY rm.measured_at DESC
-			LIMIT $6`, realtimeTable, realtimeTable)
-
-		args = []interface{}{minLat, maxLat, minLon, maxLon, "%" + sensorType + "%", limit}
+			LIMIT %s`, realtimeTable, realtimeTable,
+			detectorFilter("transport", sensorType, bind),
+			detectorFilter("device_name", sensorType, bind),
+			bind(limit))
 	} else {
 		// No filter by type
 		// FIXED: Get the actual latest reading per device, not grouped by lat/lon
@@ -168,6 +199,7 @@ func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLo
 			"last_reading_at": r["last_reading_at"],
 		}
 	}
+	addPlaces(sensors)
 
 	result := map[string]any{
 		"count":   len(sensors),
@@ -175,9 +207,52 @@ func listSensorsDB(ctx context.Context, sensorType string, minLat, maxLat, minLo
 		"sensors": sensors,
 		"table_used": realtimeTable,
 		"available_tables": availableTables,
-		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every device_id MUST be a clickable map link: [device_id](https://simplemap.safecast.org/?lat=LATITUDE&lon=LONGITUDE&zoom=15) using the actual lat/lon from the location field. Never show plain device IDs without a link.",
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every device_id MUST be a clickable map link: [device_id]("+mapLinkTemplate("LATITUDE", "LONGITUDE")+") using the actual lat/lon from the location field. Never show plain device IDs without a link.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+
+	return jsonResult(result)
+}
+
+// listSensorsDemo answers from the demo dataset (demo_data.go).
+func listSensorsDemo(sensorType string, minLat, maxLat, minLon, maxLon float64, limit int) (*mcp.CallToolResult, error) {
+	sensors := demoSensorsIn(sensorType, minLat, maxLat, minLon, maxLon, limit)
+	addPlaces(sensors)
+
+	result := map[string]any{
+		"count":   len(sensors),
+		"source":  "demo",
+		"sensors": sensors,
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every device_id MUST be a clickable map link: [device_id]("+mapLinkTemplate("LATITUDE", "LONGITUDE")+") using the actual lat/lon from the location field. Never show plain device IDs without a link.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
 
 	return jsonResult(result)
-}
\ No newline at end of file
+}
+
+// demoSensorsIn lists the demo sensors in a bounding box.
+func demoSensorsIn(sensorType string, minLat, maxLat, minLon, maxLon float64, limit int) []map[string]any {
+	sensors := []map[string]any{}
+	for _, s := range demoData().Sensors {
+		if s.Lat < minLat || s.Lat > maxLat || s.Lon < minLon || s.Lon > maxLon {
+			continue
+		}
+		if sensorType != "" && !demoDetectorMatch(s.Transport, sensorType) && !demoDetectorMatch(s.Name, sensorType) {
+			continue
+		}
+		if len(sensors) == limit {
+			break
+		}
+		sensors = append(sensors, map[string]any{
+			"device_id":   s.ID,
+			"device_name": s.Name,
+			"type":        s.Transport,
+			"location": map[string]any{
+				"latitude":  s.Lat,
+				"longitude": s.Lon,
+			},
+			"last_reading_at": time.Unix(s.Readings[len(s.Readings)-1].At, 0).UTC().Format(time.RFC3339),
+		})
+	}
+	return sensors
+}
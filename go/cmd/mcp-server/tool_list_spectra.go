@@ -3,12 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 var listSpectraToolDef = mcp.NewTool("list_spectra",
 	mcp.WithDescription("Browse gamma spectroscopy records. Returns metadata without channel data. Use get_spectrum with a marker_id from results to fetch full channel data. Can filter by track ID, geographic bounds, file format, or device model. Call with no filters to get all spectra. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
 	mcp.WithNumber("min_lat",
 		mcp.Description("Southern boundary latitude (requires all 4 bbox params)"),
 		mcp.Min(-90), mcp.Max(90),
@@ -29,7 +33,7 @@ var listSpectraToolDef = mcp.NewTool("list_spectra",
 		mcp.Description("Filter by spectrum file format (e.g., 'spe', 'csv')"),
 	),
 	mcp.WithString("device_model",
-		mcp.Description("Filter by detector/device model name"),
+		mcp.Description("Filter by detector/device model name. Partial match; case, spaces and hyphens are ignored and known aliases are included."),
 	),
 	mcp.WithString("track_id",
 		mcp.Description("Filter by track identifier (e.g., '8eh5m1', '8ZnI7f')"),
@@ -39,11 +43,13 @@ var listSpectraToolDef = mcp.NewTool("list_spectra",
 		mcp.Min(1), mcp.Max(500),
 		mcp.DefaultNumber(50),
 	),
+	withExplainParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
 func handleListSpectra(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if !dbAvailable() {
+	if !dbAvailable() && !demoMode {
 		return mcp.NewToolResultError("list_spectra requires a database connection (no REST API fallback available)"), nil
 	}
 
@@ -84,11 +90,15 @@ func handleListSpectra(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallT
 	sourceFormat := req.GetString("source_format", "")
 	deviceModel := req.GetString("device_model", "")
 	trackID := req.GetString("track_id", "")
-	limit := req.GetInt("limit", 50)
-	if limit < 1 || limit > 500 {
-		return mcp.NewToolResultError("Limit must be between 1 and 500"), nil
+	defLimit, maxLimit := toolLimits("list_spectra", 50, 500)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
+	if !dbAvailable() {
+		return listSpectraDemo(hasBBox, minLat, maxLat, minLon, maxLon, sourceFormat, deviceModel, trackID, limit)
+	}
 	return listSpectraDB(ctx, hasBBox, minLat, maxLat, minLon, maxLon, sourceFormat, deviceModel, trackID, limit)
 }
 
@@ -141,13 +151,8 @@ func listSpectraDB(ctx context.Context, hasBBox bool, minLat, maxLat, minLon, ma
 	}
 
 	if deviceModel != "" {
-		baseSelect += fmt.Sprintf(" AND s.device_model ILIKE $%d", argIdx)
-		args = append(args, "%"+deviceModel+"%")
-		argIdx++
-
-		countBase += fmt.Sprintf(" AND s.device_model ILIKE $%d", countArgIdx)
-		countArgs = append(countArgs, "%"+deviceModel+"%")
-		countArgIdx++
+		baseSelect += " AND " + detectorFilter("s.device_model", deviceModel, pgBinder(&args, &argIdx))
+		countBase += " AND " + detectorFilter("s.device_model", deviceModel, pgBinder(&countArgs, &countArgIdx))
 	}
 
 	if trackID != "" {
@@ -231,6 +236,7 @@ func listSpectraDB(ctx context.Context, hasBBox bool, minLat, maxLat, minLon, ma
 	}
 	if deviceModel != "" {
 		filters["device_model"] = deviceModel
+		filters["device_model_matches"] = detectorAliases(deviceModel)
 	}
 	if trackID != "" {
 		filters["track_id"] = trackID
@@ -248,3 +254,78 @@ func listSpectraDB(ctx context.Context, hasBBox bool, minLat, maxLat, minLon, ma
 
 	return jsonResult(result)
 }
+
+// listSpectraDemo answers from the demo dataset (demo_data.go).
+func listSpectraDemo(hasBBox bool, minLat, maxLat, minLon, maxLon float64, sourceFormat, deviceModel, trackID string, limit int) (*mcp.CallToolResult, error) {
+	d := demoData()
+	spectra := []map[string]any{}
+	total := 0
+	for _, sp := range d.Spectra {
+		m, _ := d.marker(sp.MarkerID)
+		if hasBBox && (m.Lat < minLat || m.Lat > maxLat || m.Lon < minLon || m.Lon > maxLon) {
+			continue
+		}
+		if (sourceFormat != "" && sp.SourceFormat != sourceFormat) ||
+			(deviceModel != "" && !demoDetectorMatch(sp.DeviceModel, deviceModel)) ||
+			(trackID != "" && m.TrackID != trackID) {
+			continue
+		}
+		total++
+		if len(spectra) == limit {
+			continue
+		}
+		spectra = append(spectra, map[string]any{
+			"spectrum_id":   sp.ID,
+			"marker_id":     sp.MarkerID,
+			"filename":      sp.Filename,
+			"source_format": sp.SourceFormat,
+			"device_model":  sp.DeviceModel,
+			"channel_count": len(sp.Channels),
+			"energy_range": map[string]any{
+				"min_kev": sp.EnergyMinKeV,
+				"max_kev": sp.EnergyMaxKeV,
+			},
+			"live_time_sec": sp.LiveTimeSec,
+			"real_time_sec": sp.RealTimeSec,
+			"calibration":   sp.Calibration,
+			"created_at":    sp.CreatedAt.Format(time.RFC3339),
+			"marker": map[string]any{
+				"doserate":    m.DoseRate,
+				"latitude":    m.Lat,
+				"longitude":   m.Lon,
+				"captured_at": time.Unix(m.Date, 0).UTC().Format(time.RFC3339),
+				"track_id":    m.TrackID,
+			},
+		})
+	}
+
+	filters := map[string]any{}
+	if hasBBox {
+		filters["bbox"] = map[string]any{
+			"min_lat": minLat, "max_lat": maxLat,
+			"min_lon": minLon, "max_lon": maxLon,
+		}
+	}
+	if sourceFormat != "" {
+		filters["source_format"] = sourceFormat
+	}
+	if deviceModel != "" {
+		filters["device_model"] = deviceModel
+		filters["device_model_matches"] = detectorAliases(deviceModel)
+	}
+	if trackID != "" {
+		filters["track_id"] = trackID
+	}
+
+	result := map[string]any{
+		"count":           len(spectra),
+		"total_available": total,
+		"source":          "demo",
+		"filters":         filters,
+		"spectra":         spectra,
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+
+	return jsonResult(result)
+}
@@ -2,15 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 var listTracksToolDef = mcp.NewTool("list_tracks",
-	mcp.WithDescription("Browse bGeigie Import tracks (bulk radiation measurement drives). Can filter by year, month, and detector/device name. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use https://simplemap.safecast.org as the base URL — NEVER use api.safecast.org, which does not host track data."),
+	mcp.WithDescription("Browse bGeigie Import tracks (bulk radiation measurement drives). Can filter by year, month, detector/device name, uploader, area (bounding box, country or saved region) and the highest dose rate recorded in one call. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use "+mapBaseURL()+" as the base URL — NEVER use api.safecast.org, which does not host track data."),
 	mcp.WithNumber("year",
 		mcp.Description("Filter by year (e.g., 2024)"),
 		mcp.Min(2000), mcp.Max(2100),
@@ -20,16 +22,44 @@ var listTracksToolDef = mcp.NewTool("list_tracks",
 		mcp.Min(1), mcp.Max(12),
 	),
 	mcp.WithString("detector",
-		mcp.Description("Filter by detector/device name (e.g., 'bGeigieZen', 'bGeigie', 'Pointcast'). Partial match supported."),
+		mcp.Description("Filter by detector/device name (e.g., 'bGeigieZen', 'bGeigie', 'Pointcast'). Partial match; case, spaces and hyphens are ignored and known aliases are included ('bgeigie-zen' also finds 'BGeigie Zen')."),
 	),
 	mcp.WithString("username",
 		mcp.Description("Filter by uploader username. Partial match supported."),
 	),
+	mcp.WithString("country",
+		mcp.Description("Only tracks passing through this country's bounding box (names as in list_supported_regions)"),
+	),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
+	mcp.WithNumber("min_lat",
+		mcp.Description("Only tracks passing through this bounding box: southern latitude (set all four of min_lat, max_lat, min_lon, max_lon)"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("max_lat",
+		mcp.Description("Bounding box northern latitude"),
+		mcp.Min(-90), mcp.Max(90),
+	),
+	mcp.WithNumber("min_lon",
+		mcp.Description("Bounding box western longitude"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("max_lon",
+		mcp.Description("Bounding box eastern longitude"),
+		mcp.Min(-180), mcp.Max(180),
+	),
+	mcp.WithNumber("min_max_dose_usvh",
+		mcp.Description("Only tracks whose highest reading reached this dose rate in µSv/h (e.g. 1 for drives where anything exceeded 1 µSv/h). Each track's max_dose_usvh is included in the results."),
+		mcp.Min(0),
+	),
 	mcp.WithNumber("limit",
 		mcp.Description("Maximum number of results to return (default: 50, max: 50000)"),
 		mcp.Min(1), mcp.Max(50000),
 		mcp.DefaultNumber(50),
 	),
+	withExplainParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -38,7 +68,8 @@ func handleListTracks(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 	month := req.GetInt("month", 0)
 	detector := req.GetString("detector", "")
 	username := req.GetString("username", "")
-	limit := req.GetInt("limit", 50)
+	defLimit, maxLimit := toolLimits("list_tracks", 50, 50000)
+	limit := req.GetInt("limit", defLimit)
 
 	if month != 0 && year == 0 {
 		return mcp.NewToolResultError("Month filter requires year parameter"), nil
@@ -49,37 +80,132 @@ func handleListTracks(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 	if month != 0 && (month < 1 || month > 12) {
 		return mcp.NewToolResultError("Month must be between 1 and 12"), nil
 	}
-	if limit < 1 || limit > 50000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 50000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
-
-	// DB is always preferred — the API fallback calls simplemap.safecast.org/api/tracks
-	// which is this server itself, causing infinite recursion.
-	if dbAvailable() {
-		return listTracksDB(ctx, year, month, detector, username, limit)
+	args, _ := req.Params.Arguments.(map[string]any)
+	minMaxDose, _ := toFloat(args["min_max_dose_usvh"])
+	if minMaxDose < 0 {
+		return mcp.NewToolResultError("min_max_dose_usvh must not be negative"), nil
+	}
+	area, err := parseTrackArea(args["country"], func(key string) (float64, bool) {
+		v, ok := toFloat(args[key])
+		return v, ok
+	})
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	// DB unavailable and filters require it
-	if detector != "" || username != "" {
-		return mcp.NewToolResultError("Detector/username filtering requires database access"), nil
+	dbOnly := detector != "" || username != "" || area != nil || minMaxDose > 0
+	if !dbAvailable() && dbOnly {
+		return mcp.NewToolResultError("Detector/username/area/dose filtering requires database access"), nil
+	}
+
+	// The API fallback calls simplemap.safecast.org/api/tracks, which is this
+	// server itself. That is safe: the REST handler always answers from the
+	// database when one is configured, so the call cannot loop. It reads the
+	// same replica, though, so a lagging replica is no reason to use it:
+	// routed as historical, and data_freshness reports the lag.
+	fns := sourceFuncs{
+		db: func() (*mcp.CallToolResult, error) {
+			return listTracksDB(ctx, year, month, detector, username, area, minMaxDose, limit)
+		},
+	}
+	if !dbOnly {
+		fns.api = func() (*mcp.CallToolResult, error) { return listTracksAPI(ctx, year, month, limit) }
+	}
+	res, err := routeQuery(ctx, queryHistorical, fns)
+	return withDataFreshness(ctx, res, err, false)
+}
+
+// trackArea limits a track listing to tracks whose geometry intersects a
+// bounding box. Box is min_lat, max_lat, min_lon, max_lon, as in
+// countryBoundingBoxes.
+type trackArea struct {
+	Country string
+	Box     [4]float64
+}
+
+// parseTrackArea reads a country name or the four bounding box values.
+// It returns nil when neither is given.
+func parseTrackArea(country any, get func(key string) (float64, bool)) (*trackArea, error) {
+	keys := []string{"min_lat", "max_lat", "min_lon", "max_lon"}
+	var box [4]float64
+	set := 0
+	for i, k := range keys {
+		if v, ok := get(k); ok {
+			box[i] = v
+			set++
+		}
+	}
+	name, _ := country.(string)
+	name = strings.TrimSpace(name)
+	switch {
+	case name != "" && set > 0:
+		return nil, fmt.Errorf("pass either country or min_lat/max_lat/min_lon/max_lon, not both")
+	case name != "":
+		c, bbox, ok := lookupCountry(name)
+		if !ok {
+			return nil, errors.New(unknownCountryError(name))
+		}
+		return &trackArea{Country: c, Box: bbox}, nil
+	case set == 0:
+		return nil, nil
+	case set < 4:
+		return nil, fmt.Errorf("an area filter needs all four of min_lat, max_lat, min_lon and max_lon")
 	}
+	if box[0] < -90 || box[1] > 90 || box[2] < -180 || box[3] > 180 {
+		return nil, fmt.Errorf("latitude must be between -90 and 90 and longitude between -180 and 180")
+	}
+	if box[0] >= box[1] || box[2] >= box[3] {
+		return nil, fmt.Errorf("min_lat must be less than max_lat and min_lon less than max_lon")
+	}
+	return &trackArea{Box: box}, nil
+}
 
-	// No DB: fall back to the upstream simplemap API for older years only.
-	// This path only works correctly when the server is not the simplemap backend itself.
-	return listTracksAPI(ctx, year, month, limit)
+// sql returns the join and condition for the area, binding the box.
+func (a *trackArea) sql(args *[]any, argIdx *int) (join, cond string) {
+	i := *argIdx
+	*args = append(*args, a.Box[2], a.Box[0], a.Box[3], a.Box[1])
+	*argIdx += 4
+	return trackGeometrySQL(), fmt.Sprintf("m.geom && ST_MakeEnvelope($%d, $%d, $%d, $%d, 4326)", i, i+1, i+2, i+3)
 }
 
-func listTracksDB(ctx context.Context, year, month int, detector, username string, limit int) (*mcp.CallToolResult, error) {
+func (a *trackArea) describe() any {
+	if a == nil {
+		return nil
+	}
+	d := map[string]any{"min_lat": a.Box[0], "max_lat": a.Box[1], "min_lon": a.Box[2], "max_lon": a.Box[3]}
+	if a.Country != "" {
+		d["country"] = a.Country
+	}
+	return d
+}
+
+// listTracksDB lists uploads from the database. minMaxDose, when above
+// zero, keeps the tracks whose highest reading reached it, from
+// track_dose_stats when that table exists.
+func listTracksDB(ctx context.Context, year, month int, detector, username string, area *trackArea, minMaxDose float64, limit int) (*mcp.CallToolResult, error) {
+	doseSelect, doseJoin := "", ""
+	if minMaxDose > 0 {
+		doseSelect, doseJoin = ", s.max_dose_usvh", "\n\t\t"+trackDoseStatsSQL()
+	}
 	query := `SELECT u.id, u.filename, u.file_type, u.track_id, u.file_size,
 			u.created_at, u.source, u.source_id, u.recording_date,
 			u.detector, u.username,
-			u.internal_user_id, usr.username AS internal_username, usr.email AS uploader_email
+			u.internal_user_id, usr.username AS internal_username, usr.email AS uploader_email` + doseSelect + `
 		FROM uploads u
-		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
-		WHERE 1=1`
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text` + doseJoin
 
 	args := []any{}
 	argIdx := 1
+	if area != nil {
+		join, cond := area.sql(&args, &argIdx)
+		query += "\n\t\t" + join + "\n\t\tWHERE " + cond
+	} else {
+		query += "\n\t\tWHERE 1=1"
+	}
 
 	if year != 0 {
 		startDate := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -98,9 +224,7 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 	}
 
 	if detector != "" {
-		query += fmt.Sprintf(" AND detector ILIKE $%d", argIdx)
-		args = append(args, "%"+detector+"%")
-		argIdx++
+		query += " AND " + detectorFilter("detector", detector, pgBinder(&args, &argIdx))
 	}
 
 	if username != "" {
@@ -109,6 +233,12 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 		argIdx++
 	}
 
+	if minMaxDose > 0 {
+		query += fmt.Sprintf(" AND s.max_dose_usvh >= $%d", argIdx)
+		args = append(args, minMaxDose)
+		argIdx++
+	}
+
 	query += " ORDER BY recording_date DESC"
 	query += fmt.Sprintf(" LIMIT $%d", argIdx)
 	args = append(args, limit)
@@ -120,10 +250,15 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 
 	// Get total count (with same filters)
 	countQuery := `SELECT count(*) AS total FROM uploads u
-		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
-		WHERE 1=1`
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text` + doseJoin
 	countArgs := []any{}
 	countArgIdx := 1
+	if area != nil {
+		join, cond := area.sql(&countArgs, &countArgIdx)
+		countQuery += "\n\t\t" + join + "\n\t\tWHERE " + cond
+	} else {
+		countQuery += "\n\t\tWHERE 1=1"
+	}
 	if year != 0 {
 		startDate := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
 		endDate := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -140,13 +275,16 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 		countArgIdx += 2
 	}
 	if detector != "" {
-		countQuery += fmt.Sprintf(" AND detector ILIKE $%d", countArgIdx)
-		countArgs = append(countArgs, "%"+detector+"%")
-		countArgIdx++
+		countQuery += " AND " + detectorFilter("detector", detector, pgBinder(&countArgs, &countArgIdx))
 	}
 	if username != "" {
 		countQuery += fmt.Sprintf(" AND (u.username ILIKE $%d OR usr.username ILIKE $%d OR usr.email ILIKE $%d)", countArgIdx, countArgIdx, countArgIdx)
 		countArgs = append(countArgs, "%"+username+"%")
+		countArgIdx++
+	}
+	if minMaxDose > 0 {
+		countQuery += fmt.Sprintf(" AND s.max_dose_usvh >= $%d", countArgIdx)
+		countArgs = append(countArgs, minMaxDose)
 	}
 	countRow, _ := queryRow(ctx, countQuery, countArgs...)
 	total := 0
@@ -173,9 +311,14 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 			"created_at":     r["created_at"],
 		}
 
+		if minMaxDose > 0 {
+			track["max_dose_usvh"] = r["max_dose_usvh"]
+		}
+
 		// Add map URL for track view
 		if trackID, ok := r["track_id"].(string); ok && trackID != "" {
-			track["map_url"] = "https://simplemap.safecast.org/trackid/" + trackID
+			track["map_url"] = trackMapURL(trackID)
+			track["thumbnail_url"] = trackThumbURL(trackID)
 		}
 
 		// Prefer internal username over external username
@@ -192,6 +335,7 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 
 		tracks[i] = track
 	}
+	tracks, merged := mergeTracks(nil, tracks)
 
 	result := map[string]any{
 		"count":           len(tracks),
@@ -201,13 +345,20 @@ func listTracksDB(ctx context.Context, year, month int, detector, username strin
 			"year":     nilIfZero(year),
 			"month":    nilIfZero(month),
 			"detector": nilIfEmpty(detector),
+			"detector_matches": detectorAliases(detector),
 			"username": nilIfEmpty(username),
+			"area":     area.describe(),
+			"min_max_dose_usvh": nilIfZeroFloat(minMaxDose),
 		},
 		"tracks":             tracks,
 		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	if merged > 0 {
+		result["duplicate_uploads_merged"] = merged
+	}
 
+	addShortLinks(result)
 	return jsonResult(result)
 }
 
@@ -254,6 +405,13 @@ func listTracksAPI(ctx context.Context, year, month, limit int) (*mcp.CallToolRe
 			"api_url":      t["apiURL"],
 		})
 	}
+	for _, t := range tracks {
+		if id, ok := t["track_id"].(string); ok && id != "" {
+			t["thumbnail_url"] = trackThumbURL(id)
+		}
+	}
+	addMapLinks(tracks)
+	tracks, _ = mergeTracks(nil, tracks)
 
 	result := map[string]any{
 		"count":           len(tracks),
@@ -268,6 +426,7 @@ func listTracksAPI(ctx context.Context, year, month, limit int) (*mcp.CallToolRe
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
 
+	addShortLinks(result)
 	return jsonResult(result)
 }
 
@@ -278,6 +437,13 @@ func nilIfZero(v int) any {
 	return v
 }
 
+func nilIfZeroFloat(v float64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
 func nilIfEmpty(v string) any {
 	if v == "" {
 		return nil
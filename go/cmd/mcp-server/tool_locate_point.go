@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── locate_point ───────────────────────────────────────────────────────────
+//
+// Names the place of a coordinate for agents grounding an answer: country,
+// first-level division, nearest city and time zone with the local time.
+// The lookup itself is in places.go, where other tools use it too.
+
+var locatePointToolDef = mcp.NewTool("locate_point",
+	mcp.WithDescription("Find where a coordinate is: country, first-level division (prefecture, state, province), nearest city with distance and direction, and time zone with the current local time. Works offline. Use it to name the place of a measurement, sensor or track before describing it, and to convert timestamps to local time. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithNumber("lat",
+		mcp.Description("Latitude (-90 to 90)"),
+		mcp.Min(-90), mcp.Max(90),
+		mcp.Required(),
+	),
+	mcp.WithNumber("lon",
+		mcp.Description("Longitude (-180 to 180)"),
+		mcp.Min(-180), mcp.Max(180),
+		mcp.Required(),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleLocatePoint(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lat, err := req.RequireFloat("lat")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	lon, err := req.RequireFloat("lon")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if lat < -90 || lat > 90 {
+		return mcp.NewToolResultError("Latitude must be between -90 and 90"), nil
+	}
+	if lon < -180 || lon > 180 {
+		return mcp.NewToolResultError("Longitude must be between -180 and 180"), nil
+	}
+
+	p := locatePoint(lat, lon)
+	result := map[string]any{
+		"location":   map[string]any{"latitude": lat, "longitude": lon},
+		"map_url":    pointMapURL(lat, lon, defaultMapZoom),
+		"label":      p.label(),
+		"method":     p.Method,
+		"confidence": p.Confidence,
+		"nearest_city": map[string]any{
+			"name":         p.City.name,
+			"admin1":       p.City.admin1,
+			"country":      gazetteerCountries[p.City.cc].name,
+			"country_code": p.City.cc,
+			"location":     map[string]any{"latitude": p.City.lat, "longitude": p.City.lon},
+			"distance_m":   math.Round(p.CityM),
+		},
+		"_ai_hint":           "Use label to name the place. country and admin1 come from the nearest gazetteer city unless method is 'boundary'; with confidence 'low' (near borders or coasts, or far from any listed city) say the country is approximate. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if p.Country != "" {
+		result["country"] = map[string]any{"name": p.Country, "code": p.CountryCode}
+	} else {
+		result["country"] = nil
+		result["note"] = "The point is outside every country in the gazetteer and far from any listed city, most likely at sea."
+	}
+	if p.Admin1 != "" {
+		result["admin1"] = p.Admin1
+	}
+	if p.TimeZone != "" {
+		tz := map[string]any{"name": p.TimeZone}
+		if loc, err := time.LoadLocation(p.TimeZone); err == nil {
+			now := time.Now().In(loc)
+			_, offset := now.Zone()
+			minutes := offset % 3600 / 60
+			if minutes < 0 {
+				minutes = -minutes
+			}
+			tz["utc_offset"] = fmt.Sprintf("%+03d:%02d", offset/3600, minutes)
+			tz["local_time"] = now.Format(time.RFC3339)
+		}
+		result["timezone"] = tz
+	}
+	if p.Method == "nearest_city" {
+		result["method_note"] = "Country and first-level division are those of the nearest listed city within the countries whose bounding box contains the point; near borders and coasts they can be wrong. Set PLACE_BOUNDARIES_PATH to a GeoJSON of administrative boundaries for exact results."
+	}
+	return jsonResult(result)
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── nearest_readings ───────────────────────────────────────────────────────
+//
+// "Check radiation at all these schools" would otherwise take two tool
+// calls per address. nearest_readings takes a list of points and returns,
+// for each, the nearest mobile measurement and the nearest fixed sensor
+// with its latest reading, in one call. On the database every point's
+// nearest marker comes from one query (a KNN lateral join on the spatial
+// index); the simplemap fallback fetches the markers around each point in
+// turn. Sensors need the realtime_measurements table, or the demo data.
+
+const (
+	defaultNearestRadiusM = 5000.0
+	maxNearestPoints      = 100
+)
+
+var nearestReadingsToolDef = mcp.NewTool("nearest_readings",
+	mcp.WithDescription("For each of a list of points (e.g. schools or addresses, up to 100), find the nearest mobile measurement and the nearest real-time fixed sensor with its latest reading, with distance and direction from the point. Use for 'check radiation at all these places' instead of calling query_radiation and sensor_current once per place. Geocode addresses to coordinates first. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithArray("points",
+		mcp.Description("Points to look up, each an object with lat, lon and an optional label (e.g. the school's name)"),
+		mcp.Items(map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"lat":   map[string]any{"type": "number", "minimum": -90, "maximum": 90},
+				"lon":   map[string]any{"type": "number", "minimum": -180, "maximum": 180},
+				"label": map[string]any{"type": "string"},
+			},
+			"required": []string{"lat", "lon"},
+		}),
+		mcp.MinItems(1), mcp.MaxItems(maxNearestPoints),
+		mcp.Required(),
+	),
+	mcp.WithNumber("radius_m",
+		mcp.Description("Search radius around each point in metres; points with nothing closer get no match (default: 5000, max: 50000)"),
+		mcp.Min(25), mcp.Max(50000),
+		mcp.DefaultNumber(defaultNearestRadiusM),
+	),
+	mcp.WithString("start_date",
+		mcp.Description("Only consider mobile measurements captured on or after this day (YYYY-MM-DD, UTC), to skip old surveys"),
+	),
+	mcp.WithString("include",
+		mcp.Description("What to look up: 'both', 'measurements' or 'sensors' (default: both)"),
+		mcp.Enum("both", "measurements", "sensors"),
+		mcp.DefaultString("both"),
+	),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// nearestPoint is one requested point.
+type nearestPoint struct {
+	lat, lon float64
+	label    string
+}
+
+// parseNearestPoints reads the points argument.
+func parseNearestPoints(raw any) ([]nearestPoint, error) {
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("points must be a non-empty array of {lat, lon, label} objects")
+	}
+	if len(list) > maxNearestPoints {
+		return nil, fmt.Errorf("at most %d points can be looked up in one call (got %d)", maxNearestPoints, len(list))
+	}
+	points := make([]nearestPoint, len(list))
+	for i, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("points[%d] must be an object with lat and lon", i)
+		}
+		lat, ok1 := toFloat(obj["lat"])
+		lon, ok2 := toFloat(obj["lon"])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("points[%d] needs numeric lat and lon", i)
+		}
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("points[%d]: latitude must be between -90 and 90 and longitude between -180 and 180", i)
+		}
+		label, _ := obj["label"].(string)
+		points[i] = nearestPoint{lat, lon, strings.TrimSpace(label)}
+	}
+	return points, nil
+}
+
+func handleNearestReadings(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	points, err := parseNearestPoints(req.GetArguments()["points"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	radiusM := req.GetFloat("radius_m", defaultNearestRadiusM)
+	if radiusM < 25 || radiusM > 50000 {
+		return mcp.NewToolResultError("Radius must be between 25 and 50000 meters"), nil
+	}
+	var since time.Time
+	if v := req.GetString("start_date", ""); v != "" {
+		if since, err = time.Parse("2006-01-02", v); err != nil {
+			return mcp.NewToolResultError("start_date must be in YYYY-MM-DD format"), nil
+		}
+	}
+	include := req.GetString("include", "both")
+	wantMarkers := include == "both" || include == "measurements"
+	wantSensors := include == "both" || include == "sensors"
+	if !wantMarkers && !wantSensors {
+		return mcp.NewToolResultError("include must be 'both', 'measurements' or 'sensors'"), nil
+	}
+
+	build := func(markers []map[string]any, source string) (*mcp.CallToolResult, error) {
+		var sensors []map[string]any
+		sensorNote := ""
+		if wantSensors {
+			sensors, sensorNote = nearestSensors(ctx, points, radiusM)
+		}
+		return nearestReadingsResult(points, radiusM, since, markers, wantMarkers, sensors, wantSensors, sensorNote, source)
+	}
+	if !wantMarkers {
+		return build(nil, "")
+	}
+	return routeQuery(ctx, queryHistorical, sourceFuncs{
+		db: func() (*mcp.CallToolResult, error) {
+			markers, err := nearestMarkersDB(ctx, points, radiusM, since)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Nearest measurement query failed: %v", err)), nil
+			}
+			return build(markers, "database")
+		},
+		api: func() (*mcp.CallToolResult, error) {
+			markers, err := nearestMarkersAPI(ctx, points, radiusM, since)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return build(markers, "api")
+		},
+	})
+}
+
+// nearestMarkersSQL finds the nearest marker to each point within a radius.
+// Arguments: latitudes, longitudes, radius_m, max marker ID
+// (snapshotBound), earliest date (Unix seconds). The lateral subquery
+// orders by <-> so each point is one index probe, after the same bbox
+// pre-filter and ST_DWithin refinement as queryRadiationSQL.
+const nearestMarkersSQL = `
+	SELECT p.idx, m.id, m.doserate AS value, to_timestamp(m.date) AS captured_at,
+		m.lat AS latitude, m.lon AS longitude, m.detector, m.trackid,
+		ST_Distance(m.geom::geography, ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326)::geography) AS distance_m
+	FROM unnest($1::float8[], $2::float8[]) WITH ORDINALITY AS p(lat, lon, idx)
+	CROSS JOIN LATERAL (
+		SELECT id, doserate, date, lat, lon, detector, trackid, geom
+		FROM markers m
+		WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326), $3 / 111000.0)
+		  AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326)::geography, $3)
+		  AND m.id <= $4
+		  AND m.date >= $5
+		ORDER BY m.geom <-> ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326)
+		LIMIT 1
+	) m`
+
+// nearestMarkersDB returns the nearest marker of each point, or nil for
+// points with none in range.
+func nearestMarkersDB(ctx context.Context, points []nearestPoint, radiusM float64, since time.Time) ([]map[string]any, error) {
+	lats := make([]float64, len(points))
+	lons := make([]float64, len(points))
+	for i, p := range points {
+		lats[i], lons[i] = p.lat, p.lon
+	}
+	var sinceUnix int64
+	if !since.IsZero() {
+		sinceUnix = since.Unix()
+	}
+	rows, err := queryRows(ctx, nearestMarkersSQL, lats, lons, radiusM, snapshotBound(ctx), sinceUnix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]any, len(points))
+	for _, r := range rows {
+		idx, ok := toFloat(r["idx"])
+		if !ok || idx < 1 || int(idx) > len(points) {
+			continue
+		}
+		out[int(idx)-1] = map[string]any{
+			"id":          r["id"],
+			"value":       r["value"],
+			"unit":        "µSv/h",
+			"captured_at": r["captured_at"],
+			"location":    map[string]any{"latitude": r["latitude"], "longitude": r["longitude"]},
+			"detector":    r["detector"],
+			"track_id":    r["trackid"],
+		}
+	}
+	return out, nil
+}
+
+// nearestMarkersAPI fetches the markers around each point from
+// /get_markers and keeps the nearest.
+func nearestMarkersAPI(ctx context.Context, points []nearestPoint, radiusM float64, since time.Time) ([]map[string]any, error) {
+	out := make([]map[string]any, len(points))
+	for i, p := range points {
+		dLat := radiusM / 111000
+		dLon := dLat / max(math.Cos(p.lat*math.Pi/180), 0.01)
+		markers, err := client.GetMarkers(ctx, p.lat-dLat, p.lon-dLon, p.lat+dLat, p.lon+dLon)
+		if err != nil {
+			return nil, err
+		}
+		best := math.Inf(1)
+		for _, m := range markers {
+			lat, ok1 := toFloat(m["lat"])
+			lon, ok2 := toFloat(m["lon"])
+			if !ok1 || !ok2 {
+				continue
+			}
+			if !since.IsZero() {
+				if date, ok := toFloat(m["date"]); !ok || date < float64(since.Unix()) {
+					continue
+				}
+			}
+			if d := haversineMeters(p.lat, p.lon, lat, lon); d <= radiusM && d < best {
+				best = d
+				out[i] = normalizeGetMarker(m)
+			}
+		}
+	}
+	return out, nil
+}
+
+// nearestSensorsSQL is the latest reading of every sensor in a box.
+// Arguments: min_lat, max_lat, min_lon, max_lon.
+const nearestSensorsSQL = `
+	SELECT DISTINCT ON (device_id)
+		device_id,
+		COALESCE(device_name, device_id) AS device_name,
+		COALESCE(transport, '') AS transport,
+		value,
+		COALESCE(unit, 'µSv/h') AS unit,
+		to_timestamp(measured_at) AS captured_at,
+		lat AS latitude,
+		lon AS longitude
+	FROM realtime_measurements
+	WHERE lat BETWEEN $1 AND $2 AND lon BETWEEN $3 AND $4
+	  AND to_timestamp(measured_at) <= NOW()
+	ORDER BY device_id, measured_at DESC`
+
+// nearestSensors returns the nearest fixed sensor of each point with its
+// latest reading, or a note saying why sensors could not be looked up.
+func nearestSensors(ctx context.Context, points []nearestPoint, radiusM float64) ([]map[string]any, string) {
+	var candidates []map[string]any
+	switch {
+	case dbAvailable():
+		minLat, maxLat, minLon, maxLon := 90.0, -90.0, 180.0, -180.0
+		for _, p := range points {
+			dLat := radiusM / 111000
+			dLon := dLat / max(math.Cos(p.lat*math.Pi/180), 0.01)
+			minLat, maxLat = min(minLat, p.lat-dLat), max(maxLat, p.lat+dLat)
+			minLon, maxLon = min(minLon, p.lon-dLon), max(maxLon, p.lon+dLon)
+		}
+		rows, err := queryRows(ctx, nearestSensorsSQL, minLat, maxLat, minLon, maxLon)
+		if err != nil {
+			return nil, fmt.Sprintf("Sensor lookup failed: %v", err)
+		}
+		for _, r := range rows {
+			candidates = append(candidates, map[string]any{
+				"device_id":   r["device_id"],
+				"device_name": r["device_name"],
+				"type":        r["transport"],
+				"value":       r["value"],
+				"unit":        r["unit"],
+				"captured_at": r["captured_at"],
+				"location":    map[string]any{"latitude": r["latitude"], "longitude": r["longitude"]},
+			})
+		}
+	case demoMode:
+		for _, s := range demoData().Sensors {
+			last := s.Readings[len(s.Readings)-1]
+			candidates = append(candidates, map[string]any{
+				"device_id":   s.ID,
+				"device_name": s.Name,
+				"type":        s.Transport,
+				"value":       last.Value,
+				"unit":        "µSv/h",
+				"captured_at": time.Unix(last.At, 0).UTC().Format(time.RFC3339),
+				"location":    map[string]any{"latitude": s.Lat, "longitude": s.Lon},
+			})
+		}
+	default:
+		return nil, "Nearest fixed sensors need the database (realtime_measurements); the simplemap API cannot list sensors by location."
+	}
+
+	out := make([]map[string]any, len(points))
+	for i, p := range points {
+		best := math.Inf(1)
+		for _, c := range candidates {
+			loc := c["location"].(map[string]any)
+			lat, ok1 := toFloat(loc["latitude"])
+			lon, ok2 := toFloat(loc["longitude"])
+			if !ok1 || !ok2 {
+				continue
+			}
+			if d := haversineMeters(p.lat, p.lon, lat, lon); d <= radiusM && d < best {
+				best = d
+				out[i] = c
+			}
+		}
+	}
+	return out, ""
+}
+
+// placeRelative adds the distance and direction of a match from its point.
+// The match is copied, since one sensor can be nearest to several points.
+func placeRelative(match map[string]any, p nearestPoint) map[string]any {
+	out := make(map[string]any, len(match)+4)
+	for k, v := range match {
+		out[k] = v
+	}
+	loc, _ := match["location"].(map[string]any)
+	lat, ok1 := toFloat(loc["latitude"])
+	lon, ok2 := toFloat(loc["longitude"])
+	if !ok1 || !ok2 {
+		return out
+	}
+	dist, bearing, phrase := relativePosition(p.lat, p.lon, lat, lon)
+	out["distance_m"] = math.Round(dist)
+	out["relative_position"] = phrase
+	if dist >= 10 {
+		out["direction"] = compassPoint(bearing)
+	}
+	out["map_url"] = pointMapURL(lat, lon, 17)
+	return out
+}
+
+func nearestReadingsResult(points []nearestPoint, radiusM float64, since time.Time, markers []map[string]any, wantMarkers bool, sensors []map[string]any, wantSensors bool, sensorNote, source string) (*mcp.CallToolResult, error) {
+	results := make([]map[string]any, len(points))
+	withMarker, withSensor := 0, 0
+	highest, highestValue := -1, math.Inf(-1)
+	for i, p := range points {
+		entry := map[string]any{
+			"index":    i,
+			"location": map[string]any{"latitude": p.lat, "longitude": p.lon},
+			"map_url":  pointMapURL(p.lat, p.lon, defaultMapZoom),
+			"place":    placeLabel(p.lat, p.lon),
+		}
+		if p.label != "" {
+			entry["label"] = p.label
+		}
+		if wantMarkers {
+			if i < len(markers) && markers[i] != nil {
+				entry["nearest_measurement"] = placeRelative(markers[i], p)
+				withMarker++
+				if v, ok := toFloat(markers[i]["value"]); ok && v > highestValue {
+					highest, highestValue = i, v
+				}
+			} else {
+				entry["nearest_measurement"] = nil
+			}
+		}
+		if wantSensors && sensorNote == "" {
+			if i < len(sensors) && sensors[i] != nil {
+				entry["nearest_sensor"] = placeRelative(sensors[i], p)
+				withSensor++
+			} else {
+				entry["nearest_sensor"] = nil
+			}
+		}
+		results[i] = entry
+	}
+
+	summary := map[string]any{"points": len(points)}
+	if wantMarkers {
+		summary["with_measurement"] = withMarker
+	}
+	if wantSensors && sensorNote == "" {
+		summary["with_sensor"] = withSensor
+	}
+	if highest >= 0 {
+		h := map[string]any{"index": highest, "value": highestValue, "unit": "µSv/h"}
+		if points[highest].label != "" {
+			h["label"] = points[highest].label
+		}
+		summary["highest_measurement"] = h
+	}
+
+	query := map[string]any{"radius_m": radiusM}
+	if !since.IsZero() {
+		query["start_date"] = since.Format("2006-01-02")
+	}
+	result := map[string]any{
+		"query":              query,
+		"summary":            summary,
+		"results":            results,
+		"_ai_hint":           "Present the results as a markdown table with one row per point (label or coordinates), the nearest measurement's value, date and relative_position, and the nearest sensor's latest value and captured_at, each linked with its map_url. A null nearest_measurement or nearest_sensor means nothing within radius_m; say so rather than leaving the cell blank. Mobile measurements can be years old: always show captured_at. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if source != "" {
+		result["source"] = source
+	}
+	if sensorNote != "" {
+		result["sensor_note"] = sensorNote
+	}
+	return jsonResult(result)
+}
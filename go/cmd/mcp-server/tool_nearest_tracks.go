@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── nearest_tracks ─────────────────────────────────────────────────────────
+//
+// Survey tracks that pass within radius_m of a point. Unlike
+// search_tracks_by_location, which matches tracks whose markers fall in a
+// bounding box, this measures the real distance from the point to each
+// track's closest marker, so "who has driven past here?" gets tracks sorted
+// by how close they came. The geometry index is hit with a bounding box
+// widened by 1/cos(lat) for longitude before ST_DWithin refines it.
+
+var nearestTracksToolDef = mcp.NewTool("nearest_tracks",
+	mcp.WithDescription("Find bGeigie survey tracks that passed within a given distance of a location, ordered by how close they came and then by how recent they are. Each track has its closest distance, when it passed, how many of its measurements are within the radius and their average and maximum dose rate. Use get_track or track_profile with the returned track_id for details. Requires the database. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use "+mapBaseURL()+" as the base URL."),
+	mcp.WithNumber("lat",
+		mcp.Description("Latitude of the location"),
+		mcp.Min(-90), mcp.Max(90),
+		mcp.Required(),
+	),
+	mcp.WithNumber("lon",
+		mcp.Description("Longitude of the location"),
+		mcp.Min(-180), mcp.Max(180),
+		mcp.Required(),
+	),
+	mcp.WithNumber("radius_m",
+		mcp.Description("Maximum distance from the location to the track in meters (default: 500, max: 50000)"),
+		mcp.Min(10), mcp.Max(50000),
+		mcp.DefaultNumber(500),
+	),
+	mcp.WithString("order",
+		mcp.Description("'distance' (closest first, newest first among equal distances) or 'recent' (newest first)"),
+		mcp.Enum("distance", "recent"),
+		mcp.DefaultString("distance"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of tracks to return (default: 20, max: 200)"),
+		mcp.Min(1), mcp.Max(200),
+		mcp.DefaultNumber(20),
+	),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleNearestTracks(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lat, err := req.RequireFloat("lat")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	lon, err := req.RequireFloat("lon")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return mcp.NewToolResultError("lat must be between -90 and 90 and lon between -180 and 180"), nil
+	}
+	radiusM := req.GetFloat("radius_m", 500)
+	if radiusM < 10 || radiusM > 50000 {
+		return mcp.NewToolResultError("radius_m must be between 10 and 50000"), nil
+	}
+	order := req.GetString("order", "distance")
+	if order != "distance" && order != "recent" {
+		return mcp.NewToolResultError("order must be 'distance' or 'recent'"), nil
+	}
+	defLimit, maxLimit := toolLimits("nearest_tracks", 20, 200)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+
+	if !dbAvailable() {
+		return mcp.NewToolResultError("Database connection required for nearest track search"), nil
+	}
+	return nearestTracksDB(ctx, lat, lon, radiusM, order, limit)
+}
+
+func nearestTracksDB(ctx context.Context, lat, lon, radiusM float64, order string, limit int) (*mcp.CallToolResult, error) {
+	orderBy := "n.distance_m ASC, n.last_pass DESC"
+	if order == "recent" {
+		orderBy = "n.last_pass DESC, n.distance_m ASC"
+	}
+	query := `
+		WITH near AS (
+			SELECT m.trackid,
+				MIN(ST_Distance(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography)) AS distance_m,
+				COUNT(*) AS markers_within,
+				AVG(m.doserate) AS avg_value,
+				MAX(m.doserate) AS max_value,
+				MIN(m.date) AS first_pass,
+				MAX(m.date) AS last_pass
+			FROM markers m
+			WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint($2, $1), 4326),
+			                          $3 / (111000.0 * GREATEST(cos(radians($1)), 0.01)), $3 / 111000.0)
+			  AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+			  AND m.trackid IS NOT NULL AND m.trackid <> ''
+			  AND m.id <= $5
+			GROUP BY m.trackid
+		)
+		SELECT n.trackid, n.distance_m, n.markers_within, n.avg_value, n.max_value,
+			to_timestamp(n.first_pass) AS first_pass_at, to_timestamp(n.last_pass) AS last_pass_at,
+			u.filename, u.detector, u.recording_date,
+			u.username, usr.username AS internal_username
+		FROM near n
+		LEFT JOIN uploads u ON u.track_id = n.trackid
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+		ORDER BY ` + orderBy + `
+		LIMIT $4`
+
+	rows, err := queryRows(ctx, query, lat, lon, radiusM, limit, snapshotBound(ctx))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	tracks := make([]map[string]any, len(rows))
+	for i, r := range rows {
+		track := map[string]any{
+			"track_id":       r["trackid"],
+			"distance_m":     r["distance_m"],
+			"markers_within": r["markers_within"],
+			"avg_value":      r["avg_value"],
+			"max_value":      r["max_value"],
+			"unit":           "µSv/h",
+			"passed_from":    r["first_pass_at"],
+			"passed_until":   r["last_pass_at"],
+			"filename":       r["filename"],
+			"detector":       r["detector"],
+			"recording_date": r["recording_date"],
+		}
+		if trackID, ok := r["trackid"].(string); ok {
+			track["map_url"] = trackMapURL(trackID)
+		}
+		if u, ok := r["internal_username"]; ok && u != nil && u != "" {
+			track["username"] = u
+		} else if u, ok := r["username"]; ok && u != nil && u != "" {
+			track["username"] = u
+		}
+		tracks[i] = track
+	}
+	tracks, _ = mergeTracks(nil, tracks)
+	addShortLinks(tracks)
+
+	return jsonResult(map[string]any{
+		"count":  len(tracks),
+		"source": "database",
+		"query": map[string]any{
+			"lat":      lat,
+			"lon":      lon,
+			"radius_m": radiusM,
+			"order":    order,
+		},
+		"tracks":             tracks,
+		"_ai_hint":           "distance_m is the distance from the requested point to the closest measurement of each track; markers_within, avg_value and max_value (µSv/h) cover only the measurements inside radius_m, and passed_from/passed_until are when the track was inside it. Link each track as [track_id](map_url). Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	})
+}
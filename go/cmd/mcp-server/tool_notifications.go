@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var manageNotificationsToolDef = mcp.NewTool("manage_notifications",
+	mcp.WithDescription("MAINTAINERS ONLY (requires a maintainer bearer token). Subscribe a Slack incoming webhook, email addresses or a JSON webhook to scheduled reports, SLO alerts (schedule 'slo-alerts') or new uploads (schedule 'new-uploads'), so each new report, alert or upload is delivered to people without a separate consumer service. Actions: add, list, remove, test (sends a test message to one subscription)."),
+	mcp.WithString("action",
+		mcp.Description("What to do (default: list)"),
+		mcp.Enum("add", "list", "remove", "test"),
+		mcp.DefaultString("list"),
+	),
+	mcp.WithString("channel",
+		mcp.Description("Delivery channel for add: 'slack' (incoming webhook), 'email' (needs SMTP_HOST and SMTP_FROM on the server) or 'webhook' (JSON POST to any https URL)"),
+		mcp.Enum(channelSlack, channelEmail, channelWebhook),
+	),
+	mcp.WithString("target",
+		mcp.Description("For add: the Slack webhook URL (https://hooks.slack.com/services/...), comma-separated email addresses, or the https URL that receives webhook POSTs"),
+	),
+	mcp.WithString("schedule",
+		mcp.Description("Report schedule to follow, e.g. 'weekly-network-summary', 'slo-alerts' for tool latency/error alerts, or 'new-uploads' for newly ingested uploads; '*' follows everything (default: *)"),
+		mcp.DefaultString("*"),
+	),
+	mcp.WithNumber("id",
+		mcp.Description("Subscription ID for remove and test"),
+		mcp.Min(1),
+	),
+	mcp.WithDestructiveHintAnnotation(false),
+)
+
+func handleManageNotifications(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	maintainer, err := authenticateMaintainer(req.Header)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	switch action := req.GetString("action", "list"); action {
+	case "list":
+		subs, err := listSubscriptions("")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not list subscriptions: %v", err)), nil
+		}
+		for i := range subs {
+			subs[i] = subs[i].redacted()
+		}
+		return jsonResult(map[string]any{
+			"count":         len(subs),
+			"subscriptions": subs,
+		})
+
+	case "add":
+		channel := req.GetString("channel", "")
+		target := strings.TrimSpace(req.GetString("target", ""))
+		schedule := strings.TrimSpace(req.GetString("schedule", "*"))
+		if err := validateSubscriptionTarget(channel, target); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if schedule != "*" && schedule != sloAlertSchedule && schedule != newUploadsSchedule {
+			if err := checkReportSchedule(schedule); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		sub, err := addSubscription(subscription{Channel: channel, Target: target, Schedule: schedule, CreatedBy: maintainer})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not add subscription: %v", err)), nil
+		}
+		log.Printf("Notification subscription %d (%s, schedule %s) added by %s", sub.ID, channel, schedule, maintainer)
+		return jsonResult(map[string]any{
+			"added":    sub.redacted(),
+			"_ai_hint": fmt.Sprintf("Subscription added. Use action=test id=%d to check delivery.", sub.ID),
+		})
+
+	case "remove", "test":
+		id := int64(req.GetInt("id", 0))
+		if id <= 0 {
+			return mcp.NewToolResultError("id is required for " + action), nil
+		}
+		if action == "remove" {
+			if err := removeSubscription(id); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Could not remove subscription: %v", err)), nil
+			}
+			log.Printf("Notification subscription %d removed by %s", id, maintainer)
+			return jsonResult(map[string]any{"removed": id})
+		}
+		return testSubscription(ctx, id, maintainer)
+
+	default:
+		return mcp.NewToolResultError("action must be one of: add, list, remove, test"), nil
+	}
+}
+
+func checkReportSchedule(name string) error {
+	schedules, err := listReportSchedules()
+	if err != nil {
+		return fmt.Errorf("could not list report schedules: %v", err)
+	}
+	names := make([]string, 0, len(schedules))
+	for _, s := range schedules {
+		if s.Name == name {
+			return nil
+		}
+		names = append(names, s.Name)
+	}
+	return fmt.Errorf("unknown report schedule %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+func testSubscription(ctx context.Context, id int64, maintainer string) (*mcp.CallToolResult, error) {
+	subs, err := listSubscriptions("")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not load subscriptions: %v", err)), nil
+	}
+	for _, s := range subs {
+		if s.ID != id {
+			continue
+		}
+		nt, err := s.notifier()
+		if err == nil {
+			sendCtx, cancel := context.WithTimeout(ctx, notifySendTimeout)
+			err = nt.Notify(sendCtx, notification{
+				Event:    "test",
+				Subject:  "Safecast MCP test notification",
+				Markdown: fmt.Sprintf("Test message requested by %s for subscription %d (schedule %s).", maintainer, s.ID, s.Schedule),
+			})
+			cancel()
+		}
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Delivery via %s failed: %v", s.Channel, err)), nil
+		}
+		return jsonResult(map[string]any{"sent": true, "subscription": s.redacted()})
+	}
+	return mcp.NewToolResultError(fmt.Sprintf("subscription %d not found", id)), nil
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"slices"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -9,14 +12,15 @@ import (
 var queryRadiationToolDef = mcp.NewTool("query_radiation",
 	mcp.WithDescription("Find radiation measurements near a geographic location. Returns measurements within a specified radius of the given coordinates. For villages and rural areas use a radius of at least 25000-50000m to account for geocoding imprecision. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
 	mcp.WithNumber("lat",
-		mcp.Description("Latitude (-90 to 90)"),
+		mcp.Description("Latitude (-90 to 90); required unless place is given"),
 		mcp.Min(-90), mcp.Max(90),
-		mcp.Required(),
 	),
 	mcp.WithNumber("lon",
-		mcp.Description("Longitude (-180 to 180)"),
+		mcp.Description("Longitude (-180 to 180); required unless place is given"),
 		mcp.Min(-180), mcp.Max(180),
-		mcp.Required(),
+	),
+	mcp.WithString("place",
+		mcp.Description(placeParamDescription),
 	),
 	mcp.WithNumber("radius_m",
 		mcp.Description("Search radius in meters (default: 1500, max: 50000)"),
@@ -28,20 +32,27 @@ var queryRadiationToolDef = mcp.NewTool("query_radiation",
 		mcp.Min(1), mcp.Max(10000),
 		mcp.DefaultNumber(25),
 	),
+	withDateWindowParams(),
+	withCursorParam(),
+	withRawUnitsParam(),
+	withAltitudeParam(),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCompactParam(),
+	withOutputFormatParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
 func handleQueryRadiation(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	lat, err := req.RequireFloat("lat")
-	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
-	}
-	lon, err := req.RequireFloat("lon")
-	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+	lat, errLat := req.RequireFloat("lat")
+	lon, errLon := req.RequireFloat("lon")
+	if errLat != nil || errLon != nil {
+		return mcp.NewToolResultError("Provide lat and lon, or a place name"), nil
 	}
 	radiusM := req.GetFloat("radius_m", 1500)
-	limit := req.GetInt("limit", 25)
+	defLimit, maxLimit := toolLimits("query_radiation", 25, 10000)
+	limit := req.GetInt("limit", defLimit)
 
 	if lat < -90 || lat > 90 {
 		return mcp.NewToolResultError("Latitude must be between -90 and 90"), nil
@@ -52,57 +63,136 @@ func handleQueryRadiation(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 	if radiusM < 25 || radiusM > 50000 {
 		return mcp.NewToolResultError("Radius must be between 25 and 50000 meters"), nil
 	}
-	if limit < 1 || limit > 10000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 10000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+	window, err := dateWindowFromRequest(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	page, err := newPageRequest(req, queryFingerprint(lat, lon, radiusM, limit, window.start, window.end))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	ctx = rawUnitsContext(ctx, req)
+	ctx = altitudeContext(ctx, req)
+	res, err := routeQuery(ctx, queryRecent, page.sources(sourceFuncs{
+		db:  func() (*mcp.CallToolResult, error) { return queryRadiationDB(ctx, lat, lon, radiusM, window, limit, page) },
+		api: func() (*mcp.CallToolResult, error) { return queryRadiationAPI(ctx, lat, lon, radiusM, window, limit, page) },
+	}))
+	if err != nil {
+		return res, err
 	}
+	return amendJSONResult(res, func(m map[string]any) { addRelativePositions(m, lat, lon) }), nil
+}
 
-	if dbAvailable() {
-		return queryRadiationDB(ctx, lat, lon, radiusM, limit)
+// addRelativePositions gives every measurement its distance, bearing and a
+// phrase like "1.2 km NE of the requested point", so answers to "near me"
+// questions do not depend on the model doing spherical trigonometry.
+func addRelativePositions(m map[string]any, lat, lon float64) {
+	measurements, _ := m["measurements"].([]any)
+	for _, raw := range measurements {
+		meas, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		loc, _ := meas["location"].(map[string]any)
+		mLat, ok1 := toFloat(loc["latitude"])
+		mLon, ok2 := toFloat(loc["longitude"])
+		if !ok1 || !ok2 {
+			continue
+		}
+		dist, bearing, phrase := relativePosition(lat, lon, mLat, mLon)
+		if _, ok := toFloat(meas["distance_m"]); !ok {
+			meas["distance_m"] = math.Round(dist)
+		}
+		meas["relative_position"] = phrase
+		if dist >= 10 {
+			meas["bearing_deg"] = math.Round(bearing)
+			meas["direction"] = compassPoint(bearing)
+		}
+	}
+	if hint, ok := m["_ai_hint"].(string); ok && len(measurements) > 0 {
+		m["_ai_hint"] = hint + " (4) **POSITION**: Each measurement has relative_position (e.g. '1.2 km NE of the requested point'), bearing_deg and direction computed from the queried coordinates. Use relative_position when describing where a reading was taken relative to the user."
 	}
-	return queryRadiationAPI(ctx, lat, lon, radiusM, limit)
 }
 
-func queryRadiationDB(ctx context.Context, lat, lon, radiusM float64, limit int) (*mcp.CallToolResult, error) {
-	// Use a bounding box pre-filter (&&) to hit the geometry spatial index first,
-	// then refine with ST_DWithin on geography for precise meter-based distance.
-	// Without the bbox filter, the geography cast bypasses the index → full table scan → timeout.
-	//
-	// PERFORMANCE: Use a subquery to filter and sort BEFORE joining to uploads/users.
-	// This limits the join to only N rows instead of joining 90k+ rows then sorting.
-	query := `
-		WITH top_markers AS (
-			SELECT m.id, m.doserate, m.date, m.lat, m.lon,
-				m.device_id, m.altitude, m.detector, m.trackid, m.has_spectrum, m.geom
-			FROM markers m
-			WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint($2, $1), 4326), $3 / 111000.0)
-			  AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
-			ORDER BY m.date DESC
-			LIMIT $4
-		)
-		SELECT m.id, m.doserate AS value, 'µSv/h' AS unit,
-			to_timestamp(m.date) AS captured_at,
-			m.lat AS latitude, m.lon AS longitude,
-			m.device_id, m.altitude AS height, m.detector,
-			m.trackid, m.has_spectrum,
-			ST_Distance(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) AS distance_m,
-			u.internal_user_id, usr.username AS uploader_username, usr.email AS uploader_email
-		FROM top_markers m
-		LEFT JOIN uploads u ON u.track_id = m.trackid
-		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
-		ORDER BY m.date DESC`
-
-	rows, err := queryRows(ctx, query, lat, lon, radiusM, limit)
+// queryRadiationSQL selects the markers within a radius of a point,
+// newest first. Arguments: lat, lon, radius_m, limit, max marker ID
+// (snapshotBound), the date and ID of the previous page's last row
+// (pageRequest.keyset), and the date window (dateWindow.bounds).
+//
+// A bounding box pre-filter (&&) hits the geometry spatial index first, then
+// ST_DWithin on geography refines with a precise distance in metres.
+// Without the bbox filter, the geography cast bypasses the index → full
+// table scan → timeout.
+//
+// PERFORMANCE: the subquery filters and sorts BEFORE joining to
+// uploads/users, so the join only sees N rows instead of 90k+.
+const queryRadiationSQL = `
+	WITH top_markers AS (
+		SELECT m.id, m.doserate, m.countrate, m.date, m.lat, m.lon,
+			m.device_id, m.altitude, m.detector, m.trackid, m.has_spectrum, m.geom
+		FROM markers m
+		WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint($2, $1), 4326), $3 / 111000.0)
+		  AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+		  AND m.id <= $5
+		  AND (m.date, m.id) < ($6, $7)
+		  AND m.date >= $8 AND m.date < $9
+		ORDER BY m.date DESC, m.id DESC
+		LIMIT $4
+	)
+	SELECT m.id, m.doserate AS value, 'µSv/h' AS unit, m.countrate AS count_rate,
+		to_timestamp(m.date) AS captured_at,
+		m.lat AS latitude, m.lon AS longitude,
+		m.device_id, m.altitude AS height, m.detector,
+		m.trackid, m.has_spectrum,
+		ST_Distance(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) AS distance_m,
+		u.internal_user_id, usr.username AS uploader_username, usr.email AS uploader_email
+	FROM top_markers m
+	LEFT JOIN uploads u ON u.track_id = m.trackid
+	LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+	ORDER BY m.date DESC, m.id DESC`
+
+func queryRadiationDB(ctx context.Context, lat, lon, radiusM float64, window dateWindow, limit int, page pageRequest) (*mcp.CallToolResult, error) {
+	afterDate, afterID := page.keyset()
+	from, to := window.bounds()
+	rows, err := queryTyped[markerRow](ctx, queryRadiationSQL, lat, lon, radiusM, limit+1, snapshotBound(ctx), afterDate, afterID, from, to)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	var next *pageCursor
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next = page.nextDB(ctx, &rows[limit-1])
+	}
+
+	measurements := make([]map[string]any, len(rows))
+	for i := range rows {
+		measurements[i] = markerMeasurement(ctx, &rows[i])
+	}
+
+	result := queryRadiationHead(lat, lon, radiusM, window, queryRadiationTotal(ctx, lat, lon, radiusM, window))
+	result["count"] = len(measurements)
+	result["measurements"] = measurements
+	page.addPagination(result, next)
+	return measurementResult(ctx, result)
+}
 
-	// Get total count (with same bbox pre-filter for performance)
+// queryRadiationTotal counts all markers within the radius and window
+// (with the same bbox pre-filter for performance).
+func queryRadiationTotal(ctx context.Context, lat, lon, radiusM float64, window dateWindow) int {
+	from, to := window.bounds()
 	countRow, _ := queryRow(ctx, `
 		SELECT count(*) AS total
 		FROM markers m
 		WHERE m.geom && ST_Expand(ST_SetSRID(ST_MakePoint($2, $1), 4326), $3 / 111000.0)
-		  AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)`,
-		lat, lon, radiusM)
+		  AND ST_DWithin(m.geom::geography, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+		  AND m.id <= $4
+		  AND m.date >= $5 AND m.date < $6`,
+		lat, lon, radiusM, snapshotBound(ctx), from, to)
 	total := 0
 	if countRow != nil {
 		if t, ok := countRow["total"]; ok {
@@ -114,48 +204,22 @@ func queryRadiationDB(ctx context.Context, lat, lon, radiusM float64, limit int)
 			}
 		}
 	}
+	return total
+}
 
-	measurements := make([]map[string]any, len(rows))
-	for i, r := range rows {
-		measurement := map[string]any{
-			"id":    r["id"],
-			"value": r["value"],
-			"unit":  r["unit"],
-			"captured_at": r["captured_at"],
-			"location": map[string]any{
-				"latitude":  r["latitude"],
-				"longitude": r["longitude"],
-			},
-			"device_id":    r["device_id"],
-			"height":       r["height"],
-			"detector":     r["detector"],
-			"track_id":     r["trackid"],
-			"has_spectrum":  r["has_spectrum"],
-			"distance_m":   r["distance_m"],
-		}
-
-		// Add uploader information if available
-		if uploaderUsername, ok := r["uploader_username"]; ok && uploaderUsername != nil && uploaderUsername != "" {
-			measurement["uploader"] = map[string]any{
-				"username": uploaderUsername,
-				"email":    r["uploader_email"],
-			}
-		}
-
-		measurements[i] = measurement
-	}
-
-	result := map[string]any{
-		"count":           len(measurements),
+// queryRadiationHead is a database query_radiation result without its rows.
+func queryRadiationHead(lat, lon, radiusM float64, window dateWindow, total int) map[string]any {
+	head := map[string]any{
 		"total_available": total,
 		"source":          "database",
+		"map_url":         pointMapURL(lat, lon, defaultMapZoom),
 		"query": map[string]any{
 			"lat":      lat,
 			"lon":      lon,
 			"radius_m": radiusM,
+			"place":    placeLabel(lat, lon),
 		},
-		"measurements": measurements,
-		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every location (lat/lon pair) MUST be a clickable map link: [lat°N, lon°E](https://simplemap.safecast.org/?lat=LAT&lon=LON&zoom=15). Never show plain coordinates without a link.",
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every location (lat/lon pair) MUST be a clickable map link: [lat°N, lon°E]("+mapLinkTemplate("LAT", "LON")+"). Never show plain coordinates without a link.",
 		"_next_step": map[string]any{
 			"instruction": "REQUIRED: This result contains ONLY historical mobile survey data. You MUST immediately call sensor_current using the bounding box below to check for real-time fixed sensors. Do NOT report 'no real-time data' until sensor_current has been called.",
 			"call_sensor_current_with": map[string]any{
@@ -167,17 +231,39 @@ func queryRadiationDB(ctx context.Context, lat, lon, radiusM float64, limit int)
 		},
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
-
-	return jsonResult(result)
+	window.addTo(head)
+	return head
 }
 
-func queryRadiationAPI(ctx context.Context, lat, lon, radiusM float64, limit int) (*mcp.CallToolResult, error) {
-	resp, err := client.GetLatestNearby(ctx, lat, lon, radiusM, limit)
+// queryRadiationAPIWindowFetch is how many of the newest markers the API
+// fallback asks for when it has to filter them by a date window.
+const queryRadiationAPIWindowFetch = 1000
+
+func queryRadiationAPI(ctx context.Context, lat, lon, radiusM float64, window dateWindow, limit int, page pageRequest) (*mcp.CallToolResult, error) {
+	offset := page.offset()
+	fetch := offset + limit + 1
+	if !window.empty() {
+		fetch = max(fetch, queryRadiationAPIWindowFetch)
+	}
+	resp, err := client.GetLatestNearby(ctx, lat, lon, radiusM, fetch)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	markers, _ := resp["markers"].([]any)
+	fetched := len(markers)
+	if !window.empty() {
+		markers = slices.DeleteFunc(markers, func(raw any) bool {
+			m, _ := raw.(map[string]any)
+			return !window.contains(m["timeUTC"])
+		})
+	}
+	markers = markers[min(offset, len(markers)):]
+	var next *pageCursor
+	if len(markers) > limit {
+		markers = markers[:limit]
+		next = page.nextAPI(offset + limit)
+	}
 	normalized := make([]map[string]any, 0, len(markers))
 	for _, raw := range markers {
 		if m, ok := raw.(map[string]any); ok {
@@ -188,13 +274,15 @@ func queryRadiationAPI(ctx context.Context, lat, lon, radiusM float64, limit int
 	result := map[string]any{
 		"count":  len(normalized),
 		"source": "api",
+		"map_url": pointMapURL(lat, lon, defaultMapZoom),
 		"query": map[string]any{
 			"lat":      lat,
 			"lon":      lon,
 			"radius_m": radiusM,
+			"place":    placeLabel(lat, lon),
 		},
 		"measurements": normalized,
-		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every location (lat/lon pair) MUST be a clickable map link: [lat°N, lon°E](https://simplemap.safecast.org/?lat=LAT&lon=LON&zoom=15). Never show plain coordinates without a link.",
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements. (3) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every location (lat/lon pair) MUST be a clickable map link: [lat°N, lon°E]("+mapLinkTemplate("LAT", "LON")+"). Never show plain coordinates without a link.",
 		"_next_step": map[string]any{
 			"instruction": "REQUIRED: This result contains ONLY historical mobile survey data. You MUST immediately call sensor_current using the bounding box below to check for real-time fixed sensors. Do NOT report 'no real-time data' until sensor_current has been called.",
 			"call_sensor_current_with": map[string]any{
@@ -206,6 +294,11 @@ func queryRadiationAPI(ctx context.Context, lat, lon, radiusM float64, limit int
 		},
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	window.addTo(result)
+	if !window.empty() && fetched >= fetch {
+		result["time_window_note"] = fmt.Sprintf("The simplemap API only returns the newest markers near a point; the window was applied to the newest %d, so older measurements in it may be missing. Database access has no such limit.", fetched)
+	}
+	page.addPagination(result, next)
 
-	return jsonResult(result)
+	return measurementResult(ctx, result)
 }
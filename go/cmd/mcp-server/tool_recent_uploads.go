@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── recent_uploads ─────────────────────────────────────────────────────────
+//
+// Lists uploads in ID order after a since_id cursor, so a monitor that
+// keeps the returned next_since_id sees every upload exactly once. The same
+// uploads are pushed to "new-uploads" notification subscriptions as they
+// arrive (upload_watch.go).
+
+var recentUploadsToolDef = mcp.NewTool("recent_uploads",
+	mcp.WithDescription("List bGeigie uploads added to Safecast after a cursor, oldest first, so community monitors can react to new surveys. Pass the next_since_id of the previous call as since_id to get only uploads that arrived since; without since_id the newest uploads are returned. Each upload has its filename, detector, uploader, recording date, whether it has been processed into a track, and map and thumbnail links for processed ones. Requires the database. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithNumber("since_id",
+		mcp.Description("Return uploads with an ID above this cursor (next_since_id of an earlier call). Omit for the newest uploads"),
+		mcp.Min(0),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of uploads (default: 20, max: 200)"),
+		mcp.Min(1), mcp.Max(200),
+		mcp.DefaultNumber(20),
+	),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleRecentUploads(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !dbAvailable() {
+		return mcp.NewToolResultError("Database connection required for recent uploads query"), nil
+	}
+
+	defLimit, maxLimit := toolLimits("recent_uploads", 20, 200)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+	sinceID := int64(req.GetFloat("since_id", 0))
+	if sinceID < 0 {
+		return mcp.NewToolResultError("since_id must be 0 or more"), nil
+	}
+
+	uploads, err := recentUploads(ctx, sinceID, limit)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	next := sinceID
+	if len(uploads) > 0 {
+		next = uploadID(uploads[len(uploads)-1])
+	}
+	query := map[string]any{"limit": limit}
+	if sinceID > 0 {
+		query["since_id"] = sinceID
+	}
+	result := map[string]any{
+		"count":              len(uploads),
+		"uploads":            uploads,
+		"next_since_id":      next,
+		"has_more":           sinceID > 0 && len(uploads) == limit,
+		"query":              query,
+		"source":             "database",
+		"_ai_hint":           "Uploads are in ID order, oldest first. To check again later, call recent_uploads with since_id set to next_since_id; has_more means more uploads are waiting right away. Uploads with processed false have not been turned into a track yet, so they have no map link. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	return jsonResult(result)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── replay_query ───────────────────────────────────────────────────────────
+//
+// Re-runs a logged tool call (an mcp_events row) with its original
+// arguments against the running code and compares the two results, so a
+// performance or correctness fix can be checked against real user queries.
+// The original result is only available when it was under
+// MCP_LOG_RESULT_MAX_BYTES at the time; otherwise just sizes and errors are
+// compared. Only read-only tools are replayed. Keys that change on every
+// call (routing, freshness) are left out of the comparison.
+
+var replayQueryToolDef = mcp.NewTool("replay_query",
+	mcp.WithDescription("MAINTAINERS ONLY (requires a maintainer bearer token). Re-run a logged tool call by its event log id (mcp_events.id, see query_duckdb_logs) with the original arguments against the current server code, and list the differences from the logged result: timing, size, errors and changed JSON fields. Only read-only tools can be replayed."),
+	mcp.WithNumber("event_id",
+		mcp.Required(),
+		mcp.Description("id of the mcp_events row to replay"),
+	),
+	mcp.WithString("ignore",
+		mcp.Description("Extra comma-separated JSON keys to leave out of the comparison, at any depth (e.g. 'captured_at,distance_m')"),
+	),
+	mcp.WithNumber("max_differences",
+		mcp.Description("Maximum number of differences to list (default: 50, max: 500)"),
+		mcp.Min(1), mcp.Max(500),
+		mcp.DefaultNumber(50),
+	),
+	mcp.WithNumber("timeout_seconds",
+		mcp.Description("Time allowed for the replayed call (default: 60, max: 300)"),
+		mcp.Min(1), mcp.Max(300),
+		mcp.DefaultNumber(60),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// replayIgnoredKeys differ between any two runs of the same call.
+var replayIgnoredKeys = []string{"source_routing", "data_freshness", "_demo_mode", "took_ms", "duration_ms", "generated_at"}
+
+type replayCtxKey struct{}
+
+// isReplay reports whether ctx belongs to a replay_query call.
+func isReplay(ctx context.Context) bool {
+	return ctx.Value(replayCtxKey{}) != nil
+}
+
+type loggedCall struct {
+	ID          int64          `json:"id"`
+	Tool        string         `json:"tool"`
+	Params      map[string]any `json:"params"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CommitHash  string         `json:"commit_hash,omitempty"`
+	DurationMs  float64        `json:"duration_ms"`
+	ResultBytes int64          `json:"result_bytes"`
+	Error       string         `json:"error,omitempty"`
+	result      string
+}
+
+func loadLoggedCall(id int64) (*loggedCall, error) {
+	var c loggedCall
+	var params string
+	var commit, errText, result sql.NullString
+	err := duckDB.QueryRow(`
+		SELECT id, tool_name, COALESCE(CAST(params AS VARCHAR), '{}'), created_at, commit_hash,
+		       duration_ms, COALESCE(result_bytes, 0), error, result
+		FROM mcp_events WHERE id = ?`, id).Scan(
+		&c.ID, &c.Tool, &params, &c.CreatedAt, &commit, &c.DurationMs, &c.ResultBytes, &errText, &result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no logged call with id %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(params), &c.Params); err != nil {
+		return nil, fmt.Errorf("logged params are not valid JSON: %v", err)
+	}
+	c.CommitHash, c.Error, c.result = commit.String, errText.String, result.String
+	return &c, nil
+}
+
+func replayQueryHandler(s *server.MCPServer) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		maintainer, err := authenticateMaintainer(req.Header)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if duckDB == nil {
+			return mcp.NewToolResultError("replay_query needs the DuckDB event log, which is not initialized"), nil
+		}
+		id := int64(req.GetFloat("event_id", 0))
+		call, err := loadLoggedCall(id)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		tool := s.GetTool(call.Tool)
+		switch {
+		case tool == nil:
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s is not registered on this server", call.Tool)), nil
+		case call.Tool == "replay_query" || call.Tool == "self_test":
+			return mcp.NewToolResultError(fmt.Sprintf("%s calls cannot be replayed", call.Tool)), nil
+		case tool.Tool.Annotations.ReadOnlyHint == nil || !*tool.Tool.Annotations.ReadOnlyHint:
+			return mcp.NewToolResultError(fmt.Sprintf("%s is not read-only; replaying it could change data", call.Tool)), nil
+		}
+
+		ignore := map[string]bool{}
+		for _, k := range replayIgnoredKeys {
+			ignore[k] = true
+		}
+		for _, k := range strings.Split(req.GetString("ignore", ""), ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				ignore[k] = true
+			}
+		}
+		maxDiffs := min(max(req.GetInt("max_differences", 50), 1), 500)
+		timeout := time.Duration(req.GetFloat("timeout_seconds", 60) * float64(time.Second))
+		timeout = min(max(timeout, time.Second), 300*time.Second)
+
+		callCtx, cancel := context.WithTimeout(context.WithValue(ctx, replayCtxKey{}, true), timeout)
+		defer cancel()
+		inner := mcp.CallToolRequest{}
+		inner.Params.Name = call.Tool
+		inner.Params.Arguments = call.Params
+		inner.Header = req.Header
+
+		start := time.Now()
+		res, callErr := tool.Handler(callCtx, inner)
+		took := time.Since(start)
+
+		replay := map[string]any{
+			"commit_hash": nilIfEmpty(getGitCommit()),
+			"duration_ms": float64(took.Microseconds()) / 1000,
+		}
+		var newText string
+		switch {
+		case callErr != nil:
+			replay["error"] = callErr.Error()
+		case res != nil:
+			newText = resultText(res)
+			replay["result_bytes"] = resultSize(res)
+			if res.IsError {
+				replay["error"] = newText
+			}
+		}
+
+		comparison := map[string]any{
+			"original_result_stored": call.result != "",
+			// Original duration over replay duration: above 1 is faster now.
+			"speedup": math.Round(call.DurationMs/max(float64(took.Microseconds())/1000, 0.001)*100) / 100,
+		}
+		if call.result == "" {
+			comparison["note"] = "The original result was not stored (over MCP_LOG_RESULT_MAX_BYTES, or logged before results were kept); only errors and sizes can be compared."
+		} else {
+			diffs, total := diffResults(call.result, newText, ignore, maxDiffs)
+			comparison["identical"] = total == 0
+			comparison["difference_count"] = total
+			comparison["differences"] = diffs
+			if total > len(diffs) {
+				comparison["differences_truncated"] = true
+			}
+		}
+		comparison["ignored_keys"] = slices.Sorted(maps.Keys(ignore))
+		log.Printf("replay_query: %s replayed event %d (%s) in %s", maintainer, call.ID, call.Tool, took.Round(time.Millisecond))
+
+		hint := "Report whether the replayed result matches the original and summarize the differences and the timing change. Differences in time-relative fields can be expected when the original call is old."
+		return jsonResult(map[string]any{
+			"original":   call,
+			"replay":     replay,
+			"comparison": comparison,
+			"_ai_hint":   hint,
+		})
+	}
+}
+
+// resultDiff is one difference between two JSON documents.
+type resultDiff struct {
+	Path   string `json:"path"`
+	Change string `json:"change"` // added, removed, changed
+	Old    any    `json:"old,omitempty"`
+	New    any    `json:"new,omitempty"`
+}
+
+// diffResults compares two tool result texts. Results that are not JSON
+// (plain text answers) are compared as whole strings.
+func diffResults(oldText, newText string, ignore map[string]bool, limit int) ([]resultDiff, int) {
+	var a, b any
+	errA := json.Unmarshal([]byte(oldText), &a)
+	errB := json.Unmarshal([]byte(newText), &b)
+	if errA != nil || errB != nil {
+		if oldText == newText {
+			return nil, 0
+		}
+		return []resultDiff{{Path: "$", Change: "changed", Old: clipValue(oldText), New: clipValue(newText)}}, 1
+	}
+	diffs := []resultDiff{}
+	total := 0
+	diffJSON("$", a, b, ignore, func(d resultDiff) {
+		total++
+		if len(diffs) < limit {
+			diffs = append(diffs, d)
+		}
+	})
+	return diffs, total
+}
+
+func diffJSON(path string, a, b any, ignore map[string]bool, emit func(resultDiff)) {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for k := range av {
+			keys[k] = true
+		}
+		for k := range bv {
+			keys[k] = true
+		}
+		for _, k := range slices.Sorted(maps.Keys(keys)) {
+			if ignore[k] {
+				continue
+			}
+			p := path + "." + k
+			x, inA := av[k]
+			y, inB := bv[k]
+			switch {
+			case !inB:
+				emit(resultDiff{Path: p, Change: "removed", Old: clipValue(x)})
+			case !inA:
+				emit(resultDiff{Path: p, Change: "added", New: clipValue(y)})
+			default:
+				diffJSON(p, x, y, ignore, emit)
+			}
+		}
+		return
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(bv):
+				emit(resultDiff{Path: p, Change: "removed", Old: clipValue(av[i])})
+			case i >= len(av):
+				emit(resultDiff{Path: p, Change: "added", New: clipValue(bv[i])})
+			default:
+				diffJSON(p, av[i], bv[i], ignore, emit)
+			}
+		}
+		return
+	}
+	if fmt.Sprint(a) != fmt.Sprint(b) || fmt.Sprintf("%T", a) != fmt.Sprintf("%T", b) {
+		emit(resultDiff{Path: path, Change: "changed", Old: clipValue(a), New: clipValue(b)})
+	}
+}
+
+// clipValue keeps large subtrees from swamping the diff listing.
+func clipValue(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil || len(data) <= 200 {
+		return v
+	}
+	return string(data[:200]) + "…"
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var latestReportToolDef = mcp.NewTool("latest_report",
+	mcp.WithDescription("Get the most recent scheduled report (Markdown), e.g. the weekly network summary or monthly country statistics. Call without arguments to list the available schedules and recent reports. Reports are also browsable at /api/reports."),
+	mcp.WithString("schedule",
+		mcp.Description("Report schedule name, e.g. 'weekly-network-summary' or 'monthly-country-stats'"),
+	),
+	mcp.WithNumber("report_id",
+		mcp.Description("Fetch a specific earlier report by ID instead of the latest"),
+		mcp.Min(1),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+var scheduleReportToolDef = mcp.NewTool("schedule_report",
+	mcp.WithDescription("Create or replace a scheduled report that runs saved queries (see save_query) every day, week or month and stores the result as Markdown/HTML at /api/reports. Pass run_now=true to generate the first report immediately."),
+	mcp.WithString("name",
+		mcp.Description("Schedule name: lowercase letters, digits and dashes, e.g. 'fukushima-weekly'"),
+		mcp.Required(),
+	),
+	mcp.WithString("title",
+		mcp.Description("Report title, e.g. 'Fukushima coastal corridor — weekly'"),
+		mcp.Required(),
+	),
+	mcp.WithString("saved_queries",
+		mcp.Description("Comma-separated names of saved queries, one report section each, in order"),
+		mcp.Required(),
+	),
+	mcp.WithString("every",
+		mcp.Description("How often to run: daily, weekly or monthly (default: weekly)"),
+		mcp.Enum(reportIntervals...),
+		mcp.DefaultString("weekly"),
+	),
+	mcp.WithBoolean("run_now",
+		mcp.Description("Generate a report immediately (default: false)"),
+	),
+)
+
+var reportNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+func handleLatestReport(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schedule := req.GetString("schedule", "")
+	id := int64(req.GetInt("report_id", 0))
+
+	if schedule == "" && id == 0 {
+		schedules, err := listReportSchedules()
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not list report schedules: %v", err)), nil
+		}
+		recent, err := listReports("", 20)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not list reports: %v", err)), nil
+		}
+		return jsonResult(map[string]any{
+			"schedules":      schedules,
+			"recent_reports": recent,
+			"_ai_hint":       "Call latest_report with a schedule name to read its newest report.",
+		})
+	}
+
+	r, err := getReport(id, schedule)
+	if errors.Is(err, errSavedNotFound) {
+		return mcp.NewToolResultError("No report found yet. Reports are generated on their schedule; call latest_report without arguments to see what exists."), nil
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not load report: %v", err)), nil
+	}
+	return jsonResult(map[string]any{
+		"report":             r,
+		"html_url":           fmt.Sprintf("/api/reports/%d?format=html", r.ID),
+		"_ai_hint":           "The 'markdown' field is the full report. Summarise it for the user, keep the generation date, and link the HTML version. State objective facts only - no personal pronouns (I, we, you), exclamations, or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	})
+}
+
+// scheduleReportHandler needs the server to run the report immediately.
+func scheduleReportHandler(s *server.MCPServer) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := req.GetString("name", "")
+		title := strings.TrimSpace(req.GetString("title", ""))
+		every := req.GetString("every", "weekly")
+
+		if !reportNameRe.MatchString(name) {
+			return mcp.NewToolResultError("name must be 1-64 lowercase letters, digits or dashes"), nil
+		}
+		for _, b := range builtinReportSchedules() {
+			if b.Name == name {
+				return mcp.NewToolResultError(fmt.Sprintf("%s is a built-in schedule and cannot be replaced", name)), nil
+			}
+		}
+		if title == "" {
+			return mcp.NewToolResultError("title is required"), nil
+		}
+		if !slices.Contains(reportIntervals, every) {
+			return mcp.NewToolResultError("every must be one of: daily, weekly, monthly"), nil
+		}
+
+		sched := reportSchedule{Name: name, Title: title, Every: every}
+		for _, q := range strings.Split(req.GetString("saved_queries", ""), ",") {
+			if q = strings.TrimSpace(q); q == "" {
+				continue
+			}
+			if _, err := getSavedQuery(q); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Saved query %q: %v", q, err)), nil
+			}
+			sched.SavedQueries = append(sched.SavedQueries, q)
+		}
+		if len(sched.SavedQueries) == 0 {
+			return mcp.NewToolResultError("saved_queries must name at least one saved query"), nil
+		}
+
+		if err := saveReportSchedule(sched); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not save schedule: %v", err)), nil
+		}
+		result := map[string]any{"schedule": sched}
+
+		if req.GetBool("run_now", false) {
+			r, err := (&reportScheduler{server: s}).run(ctx, sched)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Schedule saved, but the first run failed: %v", err)), nil
+			}
+			result["report_id"] = r.ID
+			result["html_url"] = fmt.Sprintf("/api/reports/%d?format=html", r.ID)
+		}
+		return jsonResult(result)
+	}
+}
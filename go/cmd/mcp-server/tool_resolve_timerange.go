@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var resolveTimerangeToolDef = mcp.NewTool("resolve_timerange",
+	mcp.WithDescription("Convert a relative or named time expression into a concrete date range, so date arithmetic is done by the server rather than the model. Understands phrases like 'last week', 'this month', 'past 30 days', 'yesterday', 'March 2011', '2019', 'since the 2011 accident', 'between 2012 and 2014'. Call this before tools that take dates (sensor_history start_date/end_date, list_tracks year/month) whenever the user's wording is relative."),
+	mcp.WithString("expression",
+		mcp.Description("The time expression as the user wrote it, e.g. 'last week' or 'since Fukushima'"),
+		mcp.Required(),
+	),
+	mcp.WithString("timezone",
+		mcp.Description("IANA timezone the user is in, e.g. 'Asia/Tokyo' (default: UTC). Calendar boundaries such as 'today' and 'this month' follow this zone."),
+	),
+	mcp.WithString("now",
+		mcp.Description("Reference time in RFC 3339 (default: the current time). Set it to reproduce an earlier answer."),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// namedEvents are dates that "since the accident" style phrases refer to.
+// Keys are matched as substrings of the expression.
+var namedEvents = []struct {
+	keys []string
+	name string
+	date string
+}{
+	{[]string{"2011 accident", "fukushima", "tohoku", "great east japan earthquake", "3.11"}, "Fukushima Daiichi accident", "2011-03-11"},
+	{[]string{"chernobyl", "1986 accident"}, "Chernobyl accident", "1986-04-26"},
+	{[]string{"three mile island", "1979 accident"}, "Three Mile Island accident", "1979-03-28"},
+	{[]string{"safecast started", "safecast began", "start of safecast"}, "first Safecast measurements", "2011-04-24"},
+}
+
+var numberWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"fourteen": 14, "thirty": 30, "ninety": 90,
+}
+
+var (
+	rangeRe     = regexp.MustCompile(`^(?:between|from)\s+(.+?)\s+(?:and|to|until|through)\s+(.+)$`)
+	sinceRe     = regexp.MustCompile(`^(?:since|after|starting(?: from)?)\s+(.+)$`)
+	rollingRe   = regexp.MustCompile(`^(?:last|past|previous)\s+(?:(\w+)\s+)?(hour|day|week|month|year)s?$`)
+	agoRe       = regexp.MustCompile(`^(\w+)\s+(day|week|month|year)s?\s+ago$`)
+	calendarRe  = regexp.MustCompile(`^(this|current|last|previous)\s+(week|month|year)$`)
+	dayRe       = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	monthRe     = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	yearRe      = regexp.MustCompile(`^(?:in\s+)?(\d{4})$`)
+	monthNameRe = regexp.MustCompile(`^(?:in\s+)?([a-z]+)\s+(\d{4})$`)
+)
+
+// timeRange is a resolved expression: [Start, End) in the user's zone.
+type timeRange struct {
+	Start, End time.Time
+	Meaning    string
+}
+
+func handleResolveTimerange(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	expr := req.GetString("expression", "")
+	if strings.TrimSpace(expr) == "" {
+		return mcp.NewToolResultError("expression is required"), nil
+	}
+	tzName := req.GetString("timezone", "UTC")
+	loc, err := time.LoadLocation(tzName)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown timezone %q; use an IANA name such as 'Asia/Tokyo' or 'Europe/Berlin'", tzName)), nil
+	}
+	now := time.Now()
+	if s := req.GetString("now", ""); s != "" {
+		if now, err = time.Parse(time.RFC3339, s); err != nil {
+			return mcp.NewToolResultError("now must be an RFC 3339 timestamp, e.g. 2024-05-01T12:00:00Z"), nil
+		}
+	}
+	now = now.In(loc)
+
+	r, err := resolveTimeExpression(expr, now)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	lastDay := r.End.Add(-time.Nanosecond)
+	result := map[string]any{
+		"expression":          expr,
+		"timezone":            loc.String(),
+		"reference_time":      now.Format(time.RFC3339),
+		"interpretation":      r.Meaning,
+		"start":               r.Start.UTC().Format(time.RFC3339),
+		"end":                 r.End.UTC().Format(time.RFC3339),
+		"start_local":         r.Start.Format(time.RFC3339),
+		"end_local":           r.End.Format(time.RFC3339),
+		"start_date":          r.Start.Format("2006-01-02"),
+		"end_date":            lastDay.Format("2006-01-02"),
+		"days":                int(r.End.Sub(r.Start).Hours()/24 + 0.5),
+		"suggested_arguments": suggestedDateArguments(r),
+		"_ai_hint":            "start/end are UTC and end is exclusive. start_date/end_date are inclusive calendar dates in the requested timezone, which is the form sensor_history expects. State the interpretation to the user when the expression was ambiguous.",
+	}
+	return jsonResult(result)
+}
+
+// suggestedDateArguments maps a range onto the date parameters of other
+// tools.
+func suggestedDateArguments(r timeRange) map[string]any {
+	lastDay := r.End.Add(-time.Nanosecond)
+	out := map[string]any{
+		"sensor_history": map[string]any{
+			"start_date": r.Start.Format("2006-01-02"),
+			"end_date":   lastDay.Format("2006-01-02"),
+		},
+	}
+	// list_tracks and search_tracks_by_location filter by one year or month.
+	if r.Start.Year() == lastDay.Year() {
+		args := map[string]any{"year": r.Start.Year()}
+		if r.Start.Month() == lastDay.Month() {
+			args["month"] = int(r.Start.Month())
+		}
+		out["list_tracks"] = args
+	}
+	return out
+}
+
+// resolveTimeExpression turns expr into a range relative to now, whose
+// location defines calendar boundaries.
+func resolveTimeExpression(expr string, now time.Time) (timeRange, error) {
+	e := strings.ToLower(strings.Join(strings.Fields(expr), " "))
+	e = strings.TrimSuffix(strings.TrimPrefix(e, "the "), ".")
+	loc := now.Location()
+	day := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc) }
+	today := day(now)
+
+	if m := rangeRe.FindStringSubmatch(e); m != nil {
+		from, err := resolveTimeExpression(m[1], now)
+		if err != nil {
+			return timeRange{}, err
+		}
+		to, err := resolveTimeExpression(m[2], now)
+		if err != nil {
+			return timeRange{}, err
+		}
+		if !to.End.After(from.Start) {
+			return timeRange{}, fmt.Errorf("%q ends before it starts", expr)
+		}
+		return timeRange{from.Start, to.End, fmt.Sprintf("from %s through %s", from.Meaning, to.Meaning)}, nil
+	}
+	if m := sinceRe.FindStringSubmatch(e); m != nil {
+		from, err := resolveTimeExpression(m[1], now)
+		if err != nil {
+			return timeRange{}, err
+		}
+		if from.Start.After(now) {
+			return timeRange{}, fmt.Errorf("%q starts in the future", expr)
+		}
+		return timeRange{from.Start, now, fmt.Sprintf("since %s, up to now", from.Meaning)}, nil
+	}
+
+	switch e {
+	case "today":
+		return timeRange{today, today.AddDate(0, 0, 1), "today"}, nil
+	case "yesterday":
+		return timeRange{today.AddDate(0, 0, -1), today, "yesterday"}, nil
+	case "now", "right now", "currently":
+		return timeRange{now.Add(-time.Hour), now, "the last hour"}, nil
+	}
+
+	if m := calendarRe.FindStringSubmatch(e); m != nil {
+		previous := m[1] == "last" || m[1] == "previous"
+		var start time.Time
+		var end func(time.Time) time.Time
+		switch m[2] {
+		case "week": // ISO weeks start on Monday
+			start = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+			end = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+			if previous {
+				start = start.AddDate(0, 0, -7)
+			}
+		case "month":
+			start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+			end = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+			if previous {
+				start = start.AddDate(0, -1, 0)
+			}
+		case "year":
+			start = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, loc)
+			end = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+			if previous {
+				start = start.AddDate(-1, 0, 0)
+			}
+		}
+		stop := end(start)
+		meaning := fmt.Sprintf("the previous calendar %s", m[2])
+		if !previous {
+			meaning = fmt.Sprintf("the current calendar %s so far", m[2])
+			stop = now
+		}
+		return timeRange{start, stop, meaning}, nil
+	}
+
+	if m := rollingRe.FindStringSubmatch(e); m != nil {
+		n, ok := 1, true // "past week"
+		if m[1] != "" {
+			n, ok = parseCount(m[1])
+		}
+		if !ok {
+			return timeRange{}, fmt.Errorf("cannot read the number in %q", expr)
+		}
+		var start time.Time
+		switch m[2] {
+		case "hour":
+			start = now.Add(-time.Duration(n) * time.Hour)
+		case "day":
+			start = now.AddDate(0, 0, -n)
+		case "week":
+			start = now.AddDate(0, 0, -7*n)
+		case "month":
+			start = now.AddDate(0, -n, 0)
+		case "year":
+			start = now.AddDate(-n, 0, 0)
+		}
+		return timeRange{start, now, fmt.Sprintf("the %d %s(s) up to now (rolling)", n, m[2])}, nil
+	}
+	if m := agoRe.FindStringSubmatch(e); m != nil {
+		n, ok := parseCount(m[1])
+		if !ok {
+			return timeRange{}, fmt.Errorf("cannot read the number in %q", expr)
+		}
+		var d time.Time
+		switch m[2] {
+		case "day":
+			d = today.AddDate(0, 0, -n)
+		case "week":
+			d = today.AddDate(0, 0, -7*n)
+		case "month":
+			d = today.AddDate(0, -n, 0)
+		case "year":
+			d = today.AddDate(-n, 0, 0)
+		}
+		return timeRange{d, d.AddDate(0, 0, 1), d.Format("2006-01-02")}, nil
+	}
+
+	for _, ev := range namedEvents {
+		for _, k := range ev.keys {
+			if strings.Contains(e, k) {
+				d, _ := time.ParseInLocation("2006-01-02", ev.date, loc)
+				return timeRange{d, d.AddDate(0, 0, 1), fmt.Sprintf("the %s (%s)", ev.name, ev.date)}, nil
+			}
+		}
+	}
+
+	if dayRe.MatchString(e) {
+		if d, err := time.ParseInLocation("2006-01-02", e, loc); err == nil {
+			return timeRange{d, d.AddDate(0, 0, 1), e}, nil
+		}
+	}
+	if monthRe.MatchString(e) {
+		if d, err := time.ParseInLocation("2006-01", e, loc); err == nil {
+			return timeRange{d, d.AddDate(0, 1, 0), d.Format("January 2006")}, nil
+		}
+	}
+	if m := monthNameRe.FindStringSubmatch(e); m != nil {
+		for mo := time.January; mo <= time.December; mo++ {
+			name := strings.ToLower(mo.String())
+			if m[1] == name || (len(m[1]) >= 3 && strings.HasPrefix(name, m[1])) {
+				y, _ := strconv.Atoi(m[2])
+				d := time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+				return timeRange{d, d.AddDate(0, 1, 0), d.Format("January 2006")}, nil
+			}
+		}
+	}
+	if m := yearRe.FindStringSubmatch(e); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		d := time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+		return timeRange{d, d.AddDate(1, 0, 0), "the year " + m[1]}, nil
+	}
+
+	return timeRange{}, fmt.Errorf("could not interpret %q. Try forms like 'last week', 'past 30 days', 'this month', 'March 2011', '2019-06-01', 'since 2015' or 'between 2012 and 2014'", expr)
+}
+
+func parseCount(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n, true
+	}
+	n, ok := numberWords[s]
+	return n, ok
+}
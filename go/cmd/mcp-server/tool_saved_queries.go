@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var saveRegionToolDef = mcp.NewTool("save_region",
+	mcp.WithDescription("Save a named geographic region (bounding box or polygon) so later calls can pass region=<name> instead of coordinates. Works with search_area, sensor_current, list_sensors, list_spectra, query_extreme_readings and search_tracks_by_location. Regions are shared by all users of this server."),
+	mcp.WithString("name",
+		mcp.Description("Region name, e.g. 'Fukushima coastal corridor'. Case-insensitive when referenced."),
+		mcp.Required(),
+	),
+	mcp.WithNumber("min_lat", mcp.Description("Southern boundary latitude (bounding box regions)"), mcp.Min(-90), mcp.Max(90)),
+	mcp.WithNumber("max_lat", mcp.Description("Northern boundary latitude (bounding box regions)"), mcp.Min(-90), mcp.Max(90)),
+	mcp.WithNumber("min_lon", mcp.Description("Western boundary longitude (bounding box regions)"), mcp.Min(-180), mcp.Max(180)),
+	mcp.WithNumber("max_lon", mcp.Description("Eastern boundary longitude (bounding box regions)"), mcp.Min(-180), mcp.Max(180)),
+	mcp.WithString("polygon",
+		mcp.Description("Polygon instead of a bounding box: JSON array of [lon, lat] points (GeoJSON order), e.g. [[140.9,37.3],[141.1,37.3],[141.1,37.6],[140.9,37.6]]"),
+	),
+	mcp.WithString("description", mcp.Description("What the region covers and why it was saved")),
+	mcp.WithBoolean("replace", mcp.Description("Overwrite an existing region with the same name (default: false)")),
+)
+
+var saveQueryToolDef = mcp.NewTool("save_query",
+	mcp.WithDescription("Save a named tool call (tool name plus arguments) so a recurring analysis can be re-run with run_saved_query. Arguments may reference a saved region with region=<name>. Saved queries are shared by all users of this server."),
+	mcp.WithString("name",
+		mcp.Description("Query name, e.g. 'Fukushima coastal sensors'"),
+		mcp.Required(),
+	),
+	mcp.WithString("tool",
+		mcp.Description("Name of the tool to call, e.g. 'sensor_current'"),
+		mcp.Required(),
+	),
+	mcp.WithString("arguments",
+		mcp.Description("Tool arguments as a JSON object, e.g. {\"region\": \"Fukushima coastal corridor\", \"limit\": 50}"),
+	),
+	mcp.WithString("description", mcp.Description("What the query answers")),
+	mcp.WithBoolean("replace", mcp.Description("Overwrite an existing query with the same name (default: false)")),
+)
+
+var listSavedToolDef = mcp.NewTool("list_saved",
+	mcp.WithDescription("List saved regions and saved queries. Use the names with region=<name> or run_saved_query."),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+var runSavedQueryToolDef = mcp.NewTool("run_saved_query",
+	mcp.WithDescription("Run a query saved with save_query and return the tool's result. Optional overrides replace individual saved arguments for this run only."),
+	mcp.WithString("name",
+		mcp.Description("Name of the saved query"),
+		mcp.Required(),
+	),
+	mcp.WithString("overrides",
+		mcp.Description("JSON object of arguments to change for this run, e.g. {\"limit\": 10}"),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// unsavableTools cannot be the target of a saved query: they write, or
+// they would let saved queries call each other.
+var unsavableTools = map[string]bool{
+	"annotate_measurement": true,
+	"save_region":          true,
+	"save_query":           true,
+	"run_saved_query":      true,
+	"schedule_report":      true,
+	"manage_notifications": true,
+	"continue_result":      true,
+}
+
+func handleSaveRegion(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := normalizeSavedName(req.GetString("name", ""))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	r := savedRegion{Name: name, Description: strings.TrimSpace(req.GetString("description", ""))}
+	if p := strings.TrimSpace(req.GetString("polygon", "")); p != "" {
+		if err := json.Unmarshal([]byte(p), &r.Polygon); err != nil {
+			return mcp.NewToolResultError("polygon must be a JSON array of [lon, lat] points"), nil
+		}
+		r.MinLat, r.MaxLat, r.MinLon, r.MaxLon, err = polygonBounds(r.Polygon)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		r.Kind = "polygon"
+	} else {
+		minLat, err1 := req.RequireFloat("min_lat")
+		maxLat, err2 := req.RequireFloat("max_lat")
+		minLon, err3 := req.RequireFloat("min_lon")
+		maxLon, err4 := req.RequireFloat("max_lon")
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return mcp.NewToolResultError("Provide either polygon or all four of min_lat, max_lat, min_lon, max_lon"), nil
+		}
+		if minLat < -90 || maxLat > 90 || minLon < -180 || maxLon > 180 {
+			return mcp.NewToolResultError("Latitude must be between -90 and 90 and longitude between -180 and 180"), nil
+		}
+		if minLat >= maxLat || minLon >= maxLon {
+			return mcp.NewToolResultError("min_lat must be less than max_lat and min_lon less than max_lon"), nil
+		}
+		r.MinLat, r.MaxLat, r.MinLon, r.MaxLon = minLat, maxLat, minLon, maxLon
+		r.Kind = "bbox"
+	}
+
+	if err := saveRegion(r, req.GetBool("replace", false)); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not save region: %v", err)), nil
+	}
+	// User-chosen names go into hints quoted with %q only: clients such as
+	// web-chat neutralise the quoted spans of a hint like any other data.
+	return jsonResult(map[string]any{
+		"saved":    r,
+		"_ai_hint": fmt.Sprintf("Region saved. Pass region=%q to search_area, sensor_current, list_sensors, list_spectra, query_extreme_readings or search_tracks_by_location instead of coordinates.", r.Name),
+	})
+}
+
+func handleSaveQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := normalizeSavedName(req.GetString("name", ""))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	tool := strings.TrimSpace(req.GetString("tool", ""))
+	if tool == "" {
+		return mcp.NewToolResultError("tool is required"), nil
+	}
+	if unsavableTools[tool] {
+		return mcp.NewToolResultError(fmt.Sprintf("%s cannot be saved as a query", tool)), nil
+	}
+
+	q := savedQuery{
+		Name:        name,
+		Tool:        tool,
+		Arguments:   map[string]any{},
+		Description: strings.TrimSpace(req.GetString("description", "")),
+	}
+	if a := strings.TrimSpace(req.GetString("arguments", "")); a != "" {
+		if err := json.Unmarshal([]byte(a), &q.Arguments); err != nil {
+			return mcp.NewToolResultError("arguments must be a JSON object"), nil
+		}
+	}
+
+	if err := saveQuery(q, req.GetBool("replace", false)); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not save query: %v", err)), nil
+	}
+	return jsonResult(map[string]any{
+		"saved":    q,
+		"_ai_hint": fmt.Sprintf("Query saved. Run it later with run_saved_query name=%q.", q.Name),
+	})
+}
+
+func handleListSaved(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	regions, err := listSavedRegions()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not list regions: %v", err)), nil
+	}
+	queries, err := listSavedQueries()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not list queries: %v", err)), nil
+	}
+	return jsonResult(map[string]any{
+		"regions":  regions,
+		"queries":  queries,
+		"_ai_hint": "Use a region with region=<name> on tools that take a bounding box, and a query with run_saved_query name=<name>.",
+	})
+}
+
+// runSavedQueryHandler needs the server to look up the saved tool.
+func runSavedQueryHandler(s *server.MCPServer) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := req.GetString("name", "")
+		q, err := getSavedQuery(name)
+		if errors.Is(err, errSavedNotFound) {
+			return mcp.NewToolResultError(fmt.Sprintf("No saved query named %q; call list_saved to see the available queries", name)), nil
+		}
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Could not load query: %v", err)), nil
+		}
+
+		args := q.Arguments
+		if o := strings.TrimSpace(req.GetString("overrides", "")); o != "" {
+			var overrides map[string]any
+			if err := json.Unmarshal([]byte(o), &overrides); err != nil {
+				return mcp.NewToolResultError("overrides must be a JSON object"), nil
+			}
+			for k, v := range overrides {
+				args[k] = v
+			}
+		}
+
+		tool := s.GetTool(q.Tool)
+		if tool == nil || unsavableTools[q.Tool] {
+			return mcp.NewToolResultError(fmt.Sprintf("Saved query %q calls %s, which is not available on this server", q.Name, q.Tool)), nil
+		}
+
+		// Handlers are called directly, so expand region here as the
+		// middleware would.
+		region, args, err := applyRegion(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		inner := req
+		inner.Params.Name = q.Tool
+		inner.Params.Arguments = args
+		res, err := tool.Handler(ctx, inner)
+		if err != nil {
+			return res, err
+		}
+		return amendJSONResult(res, func(m map[string]any) {
+			run := map[string]any{"name": q.Name, "tool": q.Tool, "arguments": args}
+			if region != nil {
+				run["region"] = region.Name
+			}
+			m["saved_query"] = run
+		}), nil
+	}
+}
@@ -2,41 +2,56 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 var searchAreaToolDef = mcp.NewTool("search_area",
 	mcp.WithDescription("Find radiation measurements within a geographic bounding box. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
 	mcp.WithNumber("min_lat",
-		mcp.Description("Southern boundary latitude"),
+		mcp.Description("Southern boundary latitude (required unless region or bboxes is given)"),
 		mcp.Min(-90), mcp.Max(90),
-		mcp.Required(),
 	),
 	mcp.WithNumber("max_lat",
-		mcp.Description("Northern boundary latitude"),
+		mcp.Description("Northern boundary latitude (required unless region or bboxes is given)"),
 		mcp.Min(-90), mcp.Max(90),
-		mcp.Required(),
 	),
 	mcp.WithNumber("min_lon",
-		mcp.Description("Western boundary longitude"),
+		mcp.Description("Western boundary longitude (required unless region or bboxes is given)"),
 		mcp.Min(-180), mcp.Max(180),
-		mcp.Required(),
 	),
 	mcp.WithNumber("max_lon",
-		mcp.Description("Eastern boundary longitude"),
+		mcp.Description("Eastern boundary longitude (required unless region or bboxes is given)"),
 		mcp.Min(-180), mcp.Max(180),
-		mcp.Required(),
 	),
+	withBBoxesParam("measurements"),
 	mcp.WithNumber("limit",
 		mcp.Description("Maximum number of results to return (default: 100, max: 10000)"),
 		mcp.Min(1), mcp.Max(10000),
 		mcp.DefaultNumber(100),
 	),
+	withDateWindowParams(),
+	withCursorParam(),
+	withRawUnitsParam(),
+	withAltitudeParam(),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCompactParam(),
+	withOutputFormatParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
 func handleSearchArea(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if req.GetArguments()["bboxes"] != nil {
+		return handleSearchAreaBoxes(ctx, req) // multi_bbox.go
+	}
 	minLat, err := req.RequireFloat("min_lat")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
@@ -53,7 +68,8 @@ func handleSearchArea(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	limit := req.GetInt("limit", 100)
+	defLimit, maxLimit := toolLimits("search_area", 100, 10000)
+	limit := req.GetInt("limit", defLimit)
 
 	if minLat < -90 || minLat > 90 || maxLat < -90 || maxLat > 90 {
 		return mcp.NewToolResultError("Latitude must be between -90 and 90"), nil
@@ -67,41 +83,83 @@ func handleSearchArea(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 	if minLon >= maxLon {
 		return mcp.NewToolResultError("min_lon must be less than max_lon"), nil
 	}
-	if limit < 1 || limit > 10000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 10000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+	window, err := dateWindowFromRequest(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	if dbAvailable() {
-		return searchAreaDB(ctx, minLat, maxLat, minLon, maxLon, limit)
+	page, err := newPageRequest(req, queryFingerprint(minLat, maxLat, minLon, maxLon, limit, window.start, window.end))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
-	return searchAreaAPI(ctx, minLat, maxLat, minLon, maxLon, limit)
+
+	ctx = rawUnitsContext(ctx, req)
+	ctx = altitudeContext(ctx, req)
+	return routeQuery(ctx, queryHistorical, page.sources(sourceFuncs{
+		db:  func() (*mcp.CallToolResult, error) { return searchAreaDB(ctx, minLat, maxLat, minLon, maxLon, window, limit, page) },
+		api: func() (*mcp.CallToolResult, error) { return searchAreaAPI(ctx, minLat, maxLat, minLon, maxLon, window, limit, page) },
+	}))
 }
 
-func searchAreaDB(ctx context.Context, minLat, maxLat, minLon, maxLon float64, limit int) (*mcp.CallToolResult, error) {
-	query := `
-		SELECT m.id, m.doserate AS value, 'µSv/h' AS unit,
-			to_timestamp(m.date) AS captured_at,
-			m.lat AS latitude, m.lon AS longitude,
-			m.device_id, m.altitude AS height, m.detector,
-			m.trackid, m.has_spectrum,
-			u.internal_user_id, usr.username AS uploader_username, usr.email AS uploader_email
-		FROM markers m
-		LEFT JOIN uploads u ON u.track_id = m.trackid
-		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
-		WHERE m.geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)
-		ORDER BY m.date DESC
-		LIMIT $5`
+// searchAreaSQL selects the markers in a bounding box, newest first.
+// Arguments: min_lon, min_lat, max_lon, max_lat, limit, max marker ID
+// (snapshotBound), the date and ID of the previous page's last row
+// (pageRequest.keyset), and the date window (dateWindow.bounds).
+const searchAreaSQL = `
+	SELECT m.id, m.doserate AS value, 'µSv/h' AS unit, m.countrate AS count_rate,
+		to_timestamp(m.date) AS captured_at,
+		m.lat AS latitude, m.lon AS longitude,
+		m.device_id, m.altitude AS height, m.detector,
+		m.trackid, m.has_spectrum,
+		u.internal_user_id, usr.username AS uploader_username, usr.email AS uploader_email
+	FROM markers m
+	LEFT JOIN uploads u ON u.track_id = m.trackid
+	LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+	WHERE m.geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+	  AND m.id <= $6
+	  AND (m.date, m.id) < ($7, $8)
+	  AND m.date >= $9 AND m.date < $10
+	ORDER BY m.date DESC, m.id DESC
+	LIMIT $5`
 
-	rows, err := queryRows(ctx, query, minLon, minLat, maxLon, maxLat, limit)
+func searchAreaDB(ctx context.Context, minLat, maxLat, minLon, maxLon float64, window dateWindow, limit int, page pageRequest) (*mcp.CallToolResult, error) {
+	afterDate, afterID := page.keyset()
+	from, to := window.bounds()
+	rows, err := queryTyped[markerRow](ctx, searchAreaSQL, minLon, minLat, maxLon, maxLat, limit+1, snapshotBound(ctx), afterDate, afterID, from, to)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	var next *pageCursor
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next = page.nextDB(ctx, &rows[limit-1])
+	}
+
+	measurements := make([]map[string]any, len(rows))
+	for i := range rows {
+		measurements[i] = markerMeasurement(ctx, &rows[i])
+	}
+
+	result := searchAreaHead(minLat, maxLat, minLon, maxLon, window, searchAreaTotal(ctx, minLat, maxLat, minLon, maxLon, window))
+	result["count"] = len(measurements)
+	result["measurements"] = measurements
+	page.addPagination(result, next)
+	return measurementResult(ctx, result)
+}
 
+// searchAreaTotal counts all markers in the box and window.
+func searchAreaTotal(ctx context.Context, minLat, maxLat, minLon, maxLon float64, window dateWindow) int {
+	from, to := window.bounds()
 	countRow, _ := queryRow(ctx, `
 		SELECT count(*) AS total
 		FROM markers m
-		WHERE m.geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)`,
-		minLon, minLat, maxLon, maxLat)
+		WHERE m.geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+		  AND m.id <= $5
+		  AND m.date >= $6 AND m.date < $7`,
+		minLon, minLat, maxLon, maxLat, snapshotBound(ctx), from, to)
 	total := 0
 	if countRow != nil {
 		if t, ok := countRow["total"]; ok {
@@ -113,64 +171,74 @@ func searchAreaDB(ctx context.Context, minLat, maxLat, minLon, maxLon float64, l
 			}
 		}
 	}
+	return total
+}
 
-	measurements := make([]map[string]any, len(rows))
-	for i, r := range rows {
-		measurement := map[string]any{
-			"id":    r["id"],
-			"value": r["value"],
-			"unit":  r["unit"],
-			"captured_at": r["captured_at"],
-			"location": map[string]any{
-				"latitude":  r["latitude"],
-				"longitude": r["longitude"],
-			},
-			"device_id":   r["device_id"],
-			"height":      r["height"],
-			"detector":    r["detector"],
-			"track_id":    r["trackid"],
-			"has_spectrum": r["has_spectrum"],
-		}
-
-		// Add uploader information if available
-		if uploaderUsername, ok := r["uploader_username"]; ok && uploaderUsername != nil && uploaderUsername != "" {
-			measurement["uploader"] = map[string]any{
-				"username": uploaderUsername,
-				"email":    r["uploader_email"],
-			}
-		}
-
-		measurements[i] = measurement
-	}
-
-	result := map[string]any{
-		"count":           len(measurements),
+// searchAreaHead is a database search_area result without its rows.
+func searchAreaHead(minLat, maxLat, minLon, maxLon float64, window dateWindow, total int) map[string]any {
+	head := map[string]any{
 		"total_available": total,
 		"source":          "database",
+		"map_url":         bboxMapURL(minLat, maxLat, minLon, maxLon),
 		"bbox": map[string]any{
 			"min_lat": minLat,
 			"max_lat": maxLat,
 			"min_lon": minLon,
 			"max_lon": maxLon,
 		},
-		"measurements": measurements,
 		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	window.addTo(head)
+	return head
+}
 
-	return jsonResult(result)
+// markerMeasurement turns a marker row of searchAreaSQL or
+// queryRadiationSQL into a measurement.
+func markerMeasurement(ctx context.Context, r *markerRow) map[string]any {
+	measurement := map[string]any{
+		"id":          nullable(r.ID),
+		"value":       nullable(r.Value),
+		"unit":        nullable(r.Unit),
+		"captured_at": nullable(r.CapturedAt),
+		"location": map[string]any{
+			"latitude":  nullable(r.Latitude),
+			"longitude": nullable(r.Longitude),
+		},
+		"device_id":    nullable(r.DeviceID),
+		"height":       nullable(r.Height),
+		"detector":     nullable(r.Detector),
+		"track_id":     nullable(r.TrackID),
+		"has_spectrum": nullable(r.HasSpectrum),
+	}
+	if r.DistanceM.Valid {
+		measurement["distance_m"] = r.DistanceM.Float64
+	}
+
+	// Add uploader information if available
+	if uploader := r.uploader(); uploader != nil {
+		measurement["uploader"] = uploader
+	}
+
+	if rawUnits(ctx) {
+		measurement["count_rate"] = nullable(r.CountRate)
+	}
+	return measurement
 }
 
-func searchAreaAPI(ctx context.Context, minLat, maxLat, minLon, maxLon float64, limit int) (*mcp.CallToolResult, error) {
+func searchAreaAPI(ctx context.Context, minLat, maxLat, minLon, maxLon float64, window dateWindow, limit int, page pageRequest) (*mcp.CallToolResult, error) {
 	markers, err := client.GetMarkers(ctx, minLat, minLon, maxLat, maxLon)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	markers = windowMarkers(markers, window)
 
-	if limit > len(markers) {
-		limit = len(markers)
+	offset := min(page.offset(), len(markers))
+	limited := markers[offset:min(offset+limit, len(markers))]
+	var next *pageCursor
+	if offset+limit < len(markers) {
+		next = page.nextAPI(offset + limit)
 	}
-	limited := markers[:limit]
 
 	normalized := make([]map[string]any, len(limited))
 	for i, m := range limited {
@@ -181,6 +249,7 @@ func searchAreaAPI(ctx context.Context, minLat, maxLat, minLon, maxLon float64,
 		"count":         len(normalized),
 		"total_in_bbox": len(markers),
 		"source":        "api",
+		"map_url": bboxMapURL(minLat, maxLat, minLon, maxLon),
 		"bbox": map[string]any{
 			"min_lat": minLat,
 			"max_lat": maxLat,
@@ -191,8 +260,21 @@ func searchAreaAPI(ctx context.Context, minLat, maxLat, minLon, maxLon float64,
 		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The .unit. field indicates measurement units - CPM means .counts per minute. NOT .counts per second.. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I.ll, I.m, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: .Latest reading: X CPM at location Y. NOT .I found a reading of X CPM. or .Perfect! The sensor shows..... State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	window.addTo(result)
+	page.addPagination(result, next)
 
-	return jsonResult(result)
+	return measurementResult(ctx, result)
+}
+
+// windowMarkers keeps the /get_markers markers captured in window.
+func windowMarkers(markers []map[string]any, window dateWindow) []map[string]any {
+	if window.empty() {
+		return markers
+	}
+	return slices.DeleteFunc(markers, func(m map[string]any) bool {
+		date, ok := toFloat(m["date"])
+		return !ok || !window.containsUnix(date)
+	})
 }
 
 func toFloat(v any) (float64, bool) {
@@ -201,6 +283,11 @@ func toFloat(v any) (float64, bool) {
 		return n, true
 	case int:
 		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
 	default:
 		return 0, false
 	}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var searchSafecastToolDef = mcp.NewTool("search_safecast",
+	mcp.WithDescription("Search tracks, fixed sensors, uploaders and reference topics in one call. Use this when a question names something (a device, a file, a person, a detector, a concept) but it is unclear which tool covers it. Each hit is typed and says which tool to call next for details. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("query",
+		mcp.Description("Text to search for, e.g. 'pointcast 100', 'bGeigieZen', 'fukushima.log', 'isotopes'. Partial match, case-insensitive."),
+		mcp.Required(),
+	),
+	mcp.WithString("types",
+		mcp.Description("Comma-separated result types to search: tracks, sensors, uploaders, topics (default: all)"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum results per type (default: 10, max: 50)"),
+		mcp.Min(1), mcp.Max(50),
+		mcp.DefaultNumber(10),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+var searchTypes = []string{"tracks", "sensors", "uploaders", "topics"}
+
+// searchHit is one typed result. Next names the tool (and arguments) that
+// returns the full record.
+type searchHit struct {
+	Type    string         `json:"type"`
+	ID      string         `json:"id"`
+	Title   string         `json:"title"`
+	Snippet string         `json:"snippet,omitempty"`
+	Next    map[string]any `json:"next_tool"`
+}
+
+func handleSearchSafecast(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := strings.TrimSpace(req.GetString("query", ""))
+	if len([]rune(query)) < 2 {
+		return mcp.NewToolResultError("query must be at least 2 characters"), nil
+	}
+	defLimit, maxLimit := toolLimits("search_safecast", 10, 50)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+
+	types := searchTypes
+	if t := strings.TrimSpace(req.GetString("types", "")); t != "" {
+		types = nil
+		for _, name := range strings.Split(t, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !slices.Contains(searchTypes, name) {
+				return mcp.NewToolResultError(fmt.Sprintf("Unknown type %q. Valid types: %s", name, strings.Join(searchTypes, ", "))), nil
+			}
+			types = append(types, name)
+		}
+	}
+
+	hits := []searchHit{}
+	counts := map[string]int{}
+	unavailable := map[string]string{}
+	for _, t := range types {
+		var found []searchHit
+		var err error
+		switch t {
+		case "topics":
+			found = searchTopics(query, limit)
+		default:
+			if !dbAvailable() {
+				unavailable[t] = "database connection required"
+				continue
+			}
+			switch t {
+			case "tracks":
+				found, err = searchTracks(ctx, query, limit)
+			case "sensors":
+				found, err = searchSensors(ctx, query, limit)
+			case "uploaders":
+				found, err = searchUploaders(ctx, query, limit)
+			}
+		}
+		if err != nil {
+			unavailable[t] = err.Error()
+			continue
+		}
+		counts[t] = len(found)
+		hits = append(hits, found...)
+	}
+
+	result := map[string]any{
+		"query":              query,
+		"count":              len(hits),
+		"counts":             counts,
+		"results":            hits,
+		"_ai_hint":           "Results are grouped by type. For details on a hit, call the tool named in its next_tool with the given arguments. An empty result means nothing matched; try a shorter or differently spelled query.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if len(unavailable) > 0 {
+		result["unavailable"] = unavailable
+	}
+	return jsonResult(result)
+}
+
+func searchTracks(ctx context.Context, query string, limit int) ([]searchHit, error) {
+	args := []any{"%" + query + "%"}
+	next := 2
+	sql := fmt.Sprintf(`
+		SELECT track_id, filename, detector, recording_date
+		FROM uploads
+		WHERE track_id IS NOT NULL AND track_id <> ''
+			AND (filename ILIKE $1 OR track_id ILIKE $1 OR %s)
+		ORDER BY recording_date DESC NULLS LAST
+		LIMIT %s`, detectorFilter("detector", query, pgBinder(&args, &next)), pgBinder(&args, &next)(limit))
+	rows, err := queryRows(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("track search failed: %w", err)
+	}
+	hits := make([]searchHit, 0, len(rows))
+	for _, r := range rows {
+		id := fmt.Sprint(r["track_id"])
+		snippet := fmt.Sprint(r["detector"])
+		if d, ok := r["recording_date"]; ok && d != nil {
+			snippet += fmt.Sprintf(", recorded %v", d)
+		}
+		hits = append(hits, searchHit{
+			Type:    "track",
+			ID:      id,
+			Title:   fmt.Sprint(r["filename"]),
+			Snippet: snippet,
+			Next:    map[string]any{"name": "get_track", "arguments": map[string]any{"track_id": id}},
+		})
+	}
+	return hits, nil
+}
+
+func searchSensors(ctx context.Context, query string, limit int) ([]searchHit, error) {
+	args := []any{"%" + query + "%"}
+	next := 2
+	sql := fmt.Sprintf(`
+		SELECT device_id, device_name, transport, last_seen FROM (
+			SELECT DISTINCT ON (device_id)
+				device_id, COALESCE(device_name, device_id) AS device_name,
+				COALESCE(transport, '') AS transport,
+				to_timestamp(measured_at) AS last_seen
+			FROM realtime_measurements
+			WHERE device_id ILIKE $1 OR device_name ILIKE $1 OR %s
+			ORDER BY device_id, measured_at DESC
+		) d
+		ORDER BY last_seen DESC
+		LIMIT %s`, detectorFilter("transport", query, pgBinder(&args, &next)), pgBinder(&args, &next)(limit))
+	rows, err := queryRows(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sensor search failed: %w", err)
+	}
+	hits := make([]searchHit, 0, len(rows))
+	for _, r := range rows {
+		id := fmt.Sprint(r["device_id"])
+		hits = append(hits, searchHit{
+			Type:    "sensor",
+			ID:      id,
+			Title:   fmt.Sprint(r["device_name"]),
+			Snippet: fmt.Sprintf("%v, last reading %v", r["transport"], r["last_seen"]),
+			Next:    map[string]any{"name": "sensor_current", "arguments": map[string]any{"device_id": id}},
+		})
+	}
+	return hits, nil
+}
+
+// searchUploaders matches usernames only; e-mail addresses are neither
+// searched nor returned.
+func searchUploaders(ctx context.Context, query string, limit int) ([]searchHit, error) {
+	rows, err := queryRows(ctx, `
+		SELECT COALESCE(usr.username, u.username) AS username,
+			COUNT(*) AS upload_count,
+			MAX(u.recording_date) AS last_upload
+		FROM uploads u
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+		WHERE COALESCE(usr.username, u.username) ILIKE $1
+		GROUP BY 1
+		ORDER BY upload_count DESC
+		LIMIT $2`, "%"+query+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("uploader search failed: %w", err)
+	}
+	hits := make([]searchHit, 0, len(rows))
+	for _, r := range rows {
+		name := fmt.Sprint(r["username"])
+		hits = append(hits, searchHit{
+			Type:    "uploader",
+			ID:      name,
+			Title:   name,
+			Snippet: fmt.Sprintf("%v uploads, latest %v", r["upload_count"], r["last_upload"]),
+			Next:    map[string]any{"name": "list_tracks", "arguments": map[string]any{"username": name}},
+		})
+	}
+	return hits, nil
+}
+
+// searchTopics matches reference topic names and content. Topics whose
+// name matches rank first.
+func searchTopics(query string, limit int) []searchHit {
+	q := strings.ToLower(query)
+	topics := append([]string(nil), validTopics...)
+	sort.SliceStable(topics, func(i, j int) bool {
+		return strings.Contains(topics[i], q) && !strings.Contains(topics[j], q)
+	})
+	hits := []searchHit{}
+	for _, topic := range topics {
+		if len(hits) == limit {
+			break
+		}
+		content := referenceData[topic]
+		title := topic
+		if line, _, _ := strings.Cut(content, "\n"); strings.HasPrefix(line, "# ") {
+			title = strings.TrimPrefix(line, "# ")
+		}
+		snippet, ok := matchingLine(content, q)
+		if !ok && !strings.Contains(topic, q) && !strings.Contains(strings.ToLower(title), q) {
+			continue
+		}
+		hits = append(hits, searchHit{
+			Type:    "topic",
+			ID:      topic,
+			Title:   title,
+			Snippet: snippet,
+			Next:    map[string]any{"name": "radiation_info", "arguments": map[string]any{"topic": topic}},
+		})
+	}
+	return hits
+}
+
+// matchingLine returns the first line of text containing q (lowercase),
+// trimmed of markdown decoration.
+func matchingLine(text, q string) (string, bool) {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.Contains(strings.ToLower(line), q) {
+			return strings.Trim(strings.TrimSpace(line), "#*- "), true
+		}
+	}
+	return "", false
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -123,10 +125,48 @@ var countryBoundingBoxes = map[string][4]float64{
 	"yemen":             {12.113, 18.999, 42.532, 54.530},
 }
 
+// countryNames lists the supported country names, sorted.
+func countryNames() []string {
+	names := make([]string, 0, len(countryBoundingBoxes))
+	for name := range countryBoundingBoxes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupCountry finds a country's bounding box, ignoring case, punctuation
+// and spacing ("South-Korea", "sri lanka").
+func lookupCountry(name string) (string, [4]float64, bool) {
+	if bbox, ok := countryBoundingBoxes[toLower(name)]; ok {
+		return toLower(name), bbox, true
+	}
+	key := compactKey(name)
+	for country, bbox := range countryBoundingBoxes {
+		if compactKey(country) == key {
+			return country, bbox, true
+		}
+	}
+	return "", [4]float64{}, false
+}
+
+// unknownCountryError explains a miss and suggests close names.
+func unknownCountryError(country string) string {
+	msg := fmt.Sprintf("Country '%s' is not in the supported list.", country)
+	if s := closestMatches(country, countryNames(), 3); len(s) > 0 {
+		msg += fmt.Sprintf(" Did you mean '%s'?", strings.Join(s, "', '"))
+	}
+	return msg + " Call list_supported_regions for all supported names, or use min_lat, max_lat, min_lon, max_lon instead."
+}
+
 var searchTracksLocationToolDef = mcp.NewTool("search_tracks_by_location",
-	mcp.WithDescription("Find bGeigie measurement tracks by country name or geographic bounding box. This tool searches for radiation measurement journeys (tracks) that were recorded within a specified geographic area. Use country name for convenient searching, or provide bounding box coordinates for precise control. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use https://simplemap.safecast.org as the base URL."),
+	mcp.WithDescription("Find bGeigie measurement tracks by country name or geographic bounding box. This tool searches for radiation measurement journeys (tracks) that were recorded within a specified geographic area. Use country name for convenient searching, or provide bounding box coordinates for precise control. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. When referencing or linking to track data, ALWAYS use "+mapBaseURL()+" as the base URL."),
 	mcp.WithString("country",
-		mcp.Description("Country name to search for (e.g., 'South Africa', 'Japan', 'Germany'). Case-insensitive. Uses predefined bounding boxes."),
+		mcp.Description("Country name to search for (e.g., 'south africa', 'japan', 'germany'). Case-insensitive. Uses predefined bounding boxes; call list_supported_regions for the list."),
+		mcp.Enum(countryNames()...),
+	),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
 	),
 	mcp.WithNumber("min_lat",
 		mcp.Description("Southern boundary latitude (use with country for custom area, or alone for precise control)"),
@@ -157,6 +197,8 @@ var searchTracksLocationToolDef = mcp.NewTool("search_tracks_by_location",
 		mcp.Min(1), mcp.Max(50000),
 		mcp.DefaultNumber(50),
 	),
+	withExplainParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -168,7 +210,8 @@ func handleSearchTracksByLocation(ctx context.Context, req mcp.CallToolRequest)
 	maxLon := req.GetFloat("max_lon", 180.0)
 	year := req.GetInt("year", 0)
 	month := req.GetInt("month", 0)
-	limit := req.GetInt("limit", 50)
+	defLimit, maxLimit := toolLimits("search_tracks_by_location", 50, 50000)
+	limit := req.GetInt("limit", defLimit)
 
 	// Validate month/year
 	if month != 0 && year == 0 {
@@ -180,16 +223,17 @@ func handleSearchTracksByLocation(ctx context.Context, req mcp.CallToolRequest)
 	if month != 0 && (month < 1 || month > 12) {
 		return mcp.NewToolResultError("Month must be between 1 and 12"), nil
 	}
-	if limit < 1 || limit > 50000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 50000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
 	// If country is provided, use its predefined bounding box
 	if country != "" {
-		bbox, found := countryBoundingBoxes[toLower(country)]
+		name, bbox, found := lookupCountry(country)
 		if !found {
-			return mcp.NewToolResultError(fmt.Sprintf("Country '%s' not found in predefined list. Please use min_lat, max_lat, min_lon, max_lon parameters instead.", country)), nil
+			return mcp.NewToolResultError(unknownCountryError(country)), nil
 		}
+		country = name
 		minLat, maxLat, minLon, maxLon = bbox[0], bbox[1], bbox[2], bbox[3]
 	}
 
@@ -220,15 +264,11 @@ func searchTracksByLocationDB(ctx context.Context, country string, minLat, maxLa
 			u.created_at, u.source, u.source_id, u.recording_date,
 			u.detector, u.username,
 			u.internal_user_id, usr.username AS internal_username, usr.email AS uploader_email,
-			ST_X(ST_Centroid(m.geom)) AS centroid_lon,
-			ST_Y(ST_Centroid(m.geom)) AS centroid_lat
+			ST_X(m.centroid) AS centroid_lon,
+			ST_Y(m.centroid) AS centroid_lat
 		FROM uploads u
 		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
-		LEFT JOIN LATERAL (
-			SELECT ST_Collect(geom) AS geom
-			FROM markers
-			WHERE markers.trackid = u.track_id
-		) m ON true
+		` + trackGeometrySQL() + `
 		WHERE m.geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)`
 
 	args := []any{minLon, minLat, maxLon, maxLat}
@@ -263,11 +303,7 @@ func searchTracksByLocationDB(ctx context.Context, country string, minLat, maxLa
 	countQuery := `
 		SELECT count(*) AS total
 		FROM uploads u
-		LEFT JOIN LATERAL (
-			SELECT ST_Collect(geom) AS geom
-			FROM markers
-			WHERE markers.trackid = u.track_id
-		) m ON true
+		` + trackGeometrySQL() + `
 		WHERE m.geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)`
 	countArgs := []any{minLon, minLat, maxLon, maxLat}
 	countRow, _ := queryRow(ctx, countQuery, countArgs...)
@@ -297,7 +333,7 @@ func searchTracksByLocationDB(ctx context.Context, country string, minLat, maxLa
 
 		// Add map URL for track view
 		if trackID, ok := r["track_id"].(string); ok && trackID != "" {
-			track["map_url"] = "https://simplemap.safecast.org/trackid/" + trackID
+			track["map_url"] = trackMapURL(trackID)
 		}
 
 		// Add location info if available
@@ -323,6 +359,8 @@ func searchTracksByLocationDB(ctx context.Context, country string, minLat, maxLa
 
 		tracks[i] = track
 	}
+	tracks, merged := mergeTracks(nil, tracks)
+	addPlaces(tracks)
 
 	searchArea := country
 	if searchArea == "" {
@@ -348,7 +386,11 @@ func searchTracksByLocationDB(ctx context.Context, country string, minLat, maxLa
 		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	if merged > 0 {
+		result["duplicate_uploads_merged"] = merged
+	}
 
+	addShortLinks(result)
 	return jsonResult(result)
 }
 
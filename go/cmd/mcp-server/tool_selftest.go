@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── self_test ──────────────────────────────────────────────────────────────
+//
+// Calls every registered read-only tool once with a small, known-safe query
+// (a central Tokyo bounding box, a known track) and reports pass/fail and
+// latency per tool, so a release can be checked end to end from any MCP
+// client. Tools that need an ID (sensor history, spectrum) take it from an
+// earlier list call. Self-test calls are left out of the cost hints,
+// /metrics and SLOs so they do not distort them.
+
+const defaultSelfTestTrackID = "8eh5m1"
+
+var selfTestToolDef = mcp.NewTool("self_test",
+	mcp.WithDescription("Check this MCP server end to end: calls each read-only tool once with a small canned query (central Tokyo, a known track) and reports pass/fail and latency per tool. Tools that change data are skipped. Takes from a few seconds up to a minute. Use when asked whether the server works, not for radiation questions."),
+	mcp.WithString("tools",
+		mcp.Description("Comma-separated tool names to test (default: all that have a canned query)"),
+	),
+	mcp.WithNumber("timeout_seconds",
+		mcp.Description("Time allowed per tool call (default: 30, max: 120)"),
+		mcp.Min(1),
+		mcp.Max(120),
+		mcp.DefaultNumber(30),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// selfTestBBox is central Tokyo: dense bGeigie coverage and several fixed
+// sensors, but small enough for every query to stay fast.
+var selfTestBBox = map[string]any{"min_lat": 35.60, "max_lat": 35.75, "min_lon": 139.60, "max_lon": 139.80}
+
+type selfTestCase struct {
+	tool string
+	// args returns the arguments, or nil when an ID this case needs was not
+	// found by an earlier case.
+	args func(found map[string]any) map[string]any
+	// discover names a list in the result and a field of its first element
+	// to remember for later cases.
+	discover [2]string
+}
+
+func withBBox(extra map[string]any) map[string]any {
+	out := map[string]any{}
+	for k, v := range selfTestBBox {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
+
+func fixedArgs(args map[string]any) func(map[string]any) map[string]any {
+	return func(map[string]any) map[string]any { return args }
+}
+
+func foundArgs(key string, build func(v any) map[string]any) func(map[string]any) map[string]any {
+	return func(found map[string]any) map[string]any {
+		v, ok := found[key]
+		if !ok {
+			return nil
+		}
+		return build(v)
+	}
+}
+
+func selfTestCases() []selfTestCase {
+	trackID := envOr("SELF_TEST_TRACK_ID", defaultSelfTestTrackID)
+	today := time.Now().UTC()
+	return []selfTestCase{
+		{tool: "ping", args: fixedArgs(map[string]any{})},
+		{tool: "query_radiation", args: fixedArgs(map[string]any{"lat": 35.6812, "lon": 139.7671, "radius_m": 2000, "limit": 5})},
+		{tool: "nearest_readings", args: fixedArgs(map[string]any{"points": []any{
+			map[string]any{"lat": 35.6812, "lon": 139.7671, "label": "Tokyo Station"},
+			map[string]any{"lat": 35.6586, "lon": 139.7454, "label": "Tokyo Tower"},
+		}})},
+		{tool: "search_area", args: fixedArgs(withBBox(map[string]any{"limit": 5}))},
+		{tool: "export_csv", args: fixedArgs(withBBox(map[string]any{"limit": 5}))},
+		{tool: "list_tracks", args: fixedArgs(map[string]any{"limit": 5})},
+		{tool: "get_track", args: fixedArgs(map[string]any{"track_id": trackID, "limit": 5})},
+		{tool: "track_profile", args: fixedArgs(map[string]any{"track_id": trackID, "points": 20})},
+		{tool: "track_dose_exposure", args: fixedArgs(map[string]any{"track_id": trackID})},
+		{tool: "search_tracks_by_location", args: fixedArgs(withBBox(map[string]any{"limit": 5}))},
+		{tool: "nearest_tracks", args: fixedArgs(map[string]any{"lat": 35.6812, "lon": 139.7671, "radius_m": 1000, "limit": 5})},
+		{tool: "list_sensors", args: fixedArgs(withBBox(map[string]any{"limit": 5})), discover: [2]string{"sensors", "device_id"}},
+		{tool: "sensor_current", args: fixedArgs(withBBox(map[string]any{"limit": 5}))},
+		{tool: "sensor_history", args: foundArgs("device_id", func(v any) map[string]any {
+			return map[string]any{
+				"device_id":  fmt.Sprint(v),
+				"start_date": today.AddDate(0, 0, -7).Format("2006-01-02"),
+				"end_date":   today.Format("2006-01-02"),
+			}
+		})},
+		{tool: "calibration_drift", args: foundArgs("device_id", func(v any) map[string]any {
+			return map[string]any{"device_id": fmt.Sprint(v), "period": "year"}
+		})},
+		{tool: "cross_validate_sensor", args: foundArgs("device_id", func(v any) map[string]any {
+			return map[string]any{"device_id": fmt.Sprint(v), "limit": 5}
+		})},
+		{tool: "device_history", args: foundArgs("device_id", func(v any) map[string]any {
+			return map[string]any{"device_id": fmt.Sprint(v), "days": 7, "limit": 5}
+		})},
+		{tool: "list_spectra", args: fixedArgs(map[string]any{"limit": 5}), discover: [2]string{"spectra", "marker_id"}},
+		{tool: "get_spectrum", args: foundArgs("marker_id", func(v any) map[string]any {
+			return map[string]any{"marker_id": v}
+		})},
+		{tool: "spectrum_roi_rate", args: foundArgs("marker_id", func(v any) map[string]any {
+			return map[string]any{"marker_id": v}
+		})},
+		{tool: "spectrum_dose_check", args: foundArgs("marker_id", func(v any) map[string]any {
+			return map[string]any{"marker_id": v}
+		})},
+		{tool: "radiation_info", args: fixedArgs(map[string]any{"topic": "units"})},
+		{tool: "list_supported_regions", args: fixedArgs(map[string]any{"search": "Japan"})},
+		{tool: "locate_point", args: fixedArgs(map[string]any{"lat": 37.4213, "lon": 141.0328})},
+		{tool: "search_safecast", args: fixedArgs(map[string]any{"query": "bGeigie"})},
+		{tool: "resolve_timerange", args: fixedArgs(map[string]any{"expression": "last week"})},
+		{tool: "server_changelog", args: fixedArgs(map[string]any{"limit": 1})},
+		{tool: "radiation_stats", args: fixedArgs(map[string]any{"interval": "year"})},
+		{tool: "query_extreme_readings", args: fixedArgs(withBBox(map[string]any{"limit": 3}))},
+		{tool: "dose_contours", args: fixedArgs(withBBox(map[string]any{"grid_size": 20}))},
+		{tool: "aggregate_area", args: fixedArgs(withBBox(map[string]any{"grid_size": 10}))},
+		{tool: "compare_areas", args: fixedArgs(map[string]any{
+			"a_min_lat": 35.60, "a_max_lat": 35.75, "a_min_lon": 139.60, "a_max_lon": 139.70,
+			"b_min_lon": 139.70, "b_max_lon": 139.80,
+		})},
+		{tool: "top_uploaders", args: fixedArgs(map[string]any{"limit": 5})},
+		{tool: "recent_uploads", args: fixedArgs(map[string]any{"limit": 5})},
+		{tool: "find_duplicate_uploads", args: fixedArgs(map[string]any{"days": 7, "limit": 5})},
+		{tool: "query_analytics", args: fixedArgs(map[string]any{})},
+		{tool: "query_duckdb_logs", args: fixedArgs(map[string]any{"query": "SELECT count(*) AS calls FROM mcp_events"})},
+		{tool: "list_saved", args: fixedArgs(map[string]any{})},
+		{tool: "latest_report", args: fixedArgs(map[string]any{})},
+		{tool: "db_info", args: fixedArgs(map[string]any{})},
+		{tool: "service_health", args: fixedArgs(map[string]any{})},
+	}
+}
+
+// selfTestSkipped explains why registered tools have no canned query.
+var selfTestSkipped = map[string]string{
+	"save_region":           "writes data",
+	"save_query":            "writes data",
+	"schedule_report":       "writes data",
+	"annotate_measurement":  "writes data",
+	"manage_notifications":  "writes data",
+	"analytics_maintenance": "changes storage",
+	"replay_query":          "needs an event log id",
+	"run_saved_query":       "depends on saved queries",
+	"continue_result":       "needs a cursor from a truncated result",
+	"clear_session_cache":   "changes session state",
+	"self_test":             "is this tool",
+}
+
+type selfTestResult struct {
+	Tool        string `json:"tool"`
+	Status      string `json:"status"` // pass, fail, skipped
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+	ResultBytes int    `json:"result_bytes,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+type selfTestCtxKey struct{}
+
+// isSelfTest reports whether ctx belongs to a self_test call.
+func isSelfTest(ctx context.Context) bool {
+	return ctx.Value(selfTestCtxKey{}) != nil
+}
+
+var selfTestMu sync.Mutex
+
+func selfTestHandler(s *server.MCPServer) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !selfTestMu.TryLock() {
+			return mcp.NewToolResultError("A self test is already running; try again when it has finished."), nil
+		}
+		defer selfTestMu.Unlock()
+
+		timeout := time.Duration(req.GetFloat("timeout_seconds", 30) * float64(time.Second))
+		timeout = min(max(timeout, time.Second), 120*time.Second)
+		only := map[string]bool{}
+		for _, t := range strings.Split(req.GetString("tools", ""), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				only[t] = true
+			}
+		}
+
+		ctx = context.WithValue(ctx, selfTestCtxKey{}, true)
+		progress := progressNotifier(ctx, req)
+		cases := selfTestCases()
+		found := map[string]any{}
+		tested := map[string]bool{}
+		var results []selfTestResult
+		start := time.Now()
+
+		for i, c := range cases {
+			tested[c.tool] = true
+			tool := s.GetTool(c.tool)
+			args := c.args(found)
+			if len(only) > 0 && !only[c.tool] {
+				// Unselected list calls still run when later cases need
+				// the IDs they find; they are not reported.
+				if c.discover[0] != "" && tool != nil && args != nil {
+					runSelfTestCase(ctx, tool.Handler, c, args, timeout, found)
+				}
+				continue
+			}
+			progress(i, len(cases), c.tool)
+			r := selfTestResult{Tool: c.tool}
+			switch {
+			case tool == nil:
+				r.Status, r.Reason = "skipped", "not registered on this server"
+			case args == nil:
+				r.Status, r.Reason = "skipped", "no ID found by an earlier list call"
+			default:
+				r = runSelfTestCase(ctx, tool.Handler, c, args, timeout, found)
+			}
+			results = append(results, r)
+		}
+		var untested []string
+		for name := range s.ListTools() {
+			if !tested[name] && (len(only) == 0 || only[name]) {
+				untested = append(untested, name)
+			}
+		}
+		sort.Strings(untested)
+		for _, name := range untested {
+			reason, ok := selfTestSkipped[name]
+			if !ok {
+				reason = "no canned query"
+			}
+			results = append(results, selfTestResult{Tool: name, Status: "skipped", Reason: reason})
+		}
+		progress(len(cases), len(cases), "finished")
+
+		counts := map[string]int{"pass": 0, "fail": 0, "skipped": 0}
+		var failed []string
+		for _, r := range results {
+			counts[r.Status]++
+			if r.Status == "fail" {
+				failed = append(failed, r.Tool)
+			}
+		}
+		status := "pass"
+		hint := "All tested tools answered."
+		if len(failed) > 0 {
+			status = "fail"
+			hint = fmt.Sprintf("Failing tools: %s. Report them with their errors.", strings.Join(failed, ", "))
+		}
+		log.Printf("Self test: %d passed, %d failed, %d skipped in %s", counts["pass"], counts["fail"], counts["skipped"], time.Since(start).Round(time.Millisecond))
+
+		return jsonResult(map[string]any{
+			"status":      status,
+			"version":     fullVersion(),
+			"passed":      counts["pass"],
+			"failed":      counts["fail"],
+			"skipped":     counts["skipped"],
+			"duration_ms": time.Since(start).Milliseconds(),
+			"results":     results,
+			"_ai_hint":    hint + " Skipped tools were not called; a skip is not a failure.",
+		})
+	}
+}
+
+// runSelfTestCase calls one tool and records IDs for later cases.
+func runSelfTestCase(
+	ctx context.Context,
+	handler server.ToolHandlerFunc,
+	c selfTestCase,
+	args map[string]any,
+	timeout time.Duration,
+	found map[string]any,
+) selfTestResult {
+	r := selfTestResult{Tool: c.tool}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	inner := mcp.CallToolRequest{}
+	inner.Params.Name = c.tool
+	inner.Params.Arguments = args
+
+	t := time.Now()
+	res, err := handler(callCtx, inner)
+	r.DurationMs = time.Since(t).Milliseconds()
+	r.ResultBytes = resultSize(res)
+
+	switch {
+	case err != nil:
+		r.Status, r.Error = "fail", err.Error()
+	case res == nil:
+		r.Status, r.Error = "fail", "no result"
+	case res.IsError:
+		r.Status, r.Error = "fail", resultText(res)
+		if len(r.Error) > 300 {
+			r.Error = r.Error[:300] + "…"
+		}
+	default:
+		r.Status = "pass"
+		if c.discover[0] != "" {
+			if v, ok := firstListField(res, c.discover[0], c.discover[1]); ok {
+				found[c.discover[1]] = v
+			}
+		}
+	}
+	return r
+}
+
+// firstListField returns field from the first element of list in a JSON
+// tool result.
+func firstListField(res *mcp.CallToolResult, list, field string) (any, bool) {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(resultText(res)), &m); err != nil {
+		return nil, false
+	}
+	items, _ := m[list].([]any)
+	if len(items) == 0 {
+		return nil, false
+	}
+	first, _ := items[0].(map[string]any)
+	v, ok := first[field]
+	return v, ok && v != nil
+}
+
+// resultText joins the text content of a tool result.
+func resultText(res *mcp.CallToolResult) string {
+	var text string
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	return text
+}
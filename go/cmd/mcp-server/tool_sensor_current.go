@@ -3,16 +3,19 @@ package main
 import (
 	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 var sensorCurrentToolDef = mcp.NewTool("sensor_current",
-	mcp.WithDescription("Get the MOST RECENT readings from REAL-TIME fixed sensors. USE THIS TOOL for: bGeigieZen (type=geigiecast-zen, IDs like geigiecast-zen:65002), Pointcast (type=pointcast, IDs like pointcast:10042), Solarcast (type=solarcast), Notehub/Radnote (type=notehub, IDs like note:dev:867648049123019), nGeigie (type=ngeigie, IDs like ngeigie:101), device-tcp (IDs like safecast:3474557222). Use when users ask about 'current', 'latest', 'live', 'now', or 'real-time' data, OR to look up a specific fixed sensor by device ID. When searching by location, always call this tool AND query_radiation together to cover both fixed and mobile sources. Use a LARGE bounding box (at least ±0.5 degrees, ~50km) for villages and rural areas to account for geocoding imprecision. DO NOT use device_history for any of these fixed sensor types — device_history is ONLY for mobile bGeigie (type=geigiecast). DO NOT use query_radiation for current data. CPM = counts per minute (convert to µSv/h using ~0.0069 for LND 7318). Always report the captured_at timestamp. Present data objectively without personal pronouns."),
+	mcp.WithDescription("Get the MOST RECENT readings from REAL-TIME fixed sensors. USE THIS TOOL for: bGeigieZen (type=geigiecast-zen, IDs like geigiecast-zen:65002), Pointcast (type=pointcast, IDs like pointcast:10042), Solarcast (type=solarcast), Notehub/Radnote (type=notehub, IDs like note:dev:867648049123019), nGeigie (type=ngeigie, IDs like ngeigie:101), device-tcp (IDs like safecast:3474557222). Use when users ask about 'current', 'latest', 'live', 'now', or 'real-time' data, OR to look up a specific fixed sensor by device ID. When searching by location, always call this tool AND query_radiation together to cover both fixed and mobile sources. Use a LARGE bounding box (at least ±0.5 degrees, ~50km) for villages and rural areas to account for geocoding imprecision. DO NOT use device_history for any of these fixed sensor types — device_history is ONLY for mobile bGeigie (type=geigiecast). DO NOT use query_radiation for current data. Readings in CPM (counts per minute) are converted to µSv/h on the server with the tube's factor; the count rate is kept in cpm and the factor in cpm_per_usvh. Always report the captured_at timestamp. Present data objectively without personal pronouns."),
 	mcp.WithString("device_id",
 		mcp.Description("Specific device ID to get latest reading from"),
 	),
+	mcp.WithString("region",
+		mcp.Description(regionParamDescription),
+	),
 	mcp.WithNumber("min_lat",
 		mcp.Description("Southern boundary for geographic filter"),
 		mcp.Min(-90), mcp.Max(90),
@@ -34,6 +37,10 @@ var sensorCurrentToolDef = mcp.NewTool("sensor_current",
 		mcp.Min(1), mcp.Max(1000),
 		mcp.DefaultNumber(25),
 	),
+	withRawUnitsParam(),
+	withExplainParam(),
+	withCompactParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -43,18 +50,78 @@ func handleSensorCurrent(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 	maxLat := req.GetFloat("max_lat", 90)
 	minLon := req.GetFloat("min_lon", -180)
 	maxLon := req.GetFloat("max_lon", 180)
-	limit := req.GetInt("limit", 25)
+	defLimit, maxLimit := toolLimits("sensor_current", 25, 1000)
+	limit := req.GetInt("limit", defLimit)
 
-	if limit < 1 || limit > 1000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 1000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
-	if dbAvailable() {
-		return sensorCurrentDB(ctx, deviceID, minLat, maxLat, minLon, maxLon, limit)
+	// The API can only look up a single device, so area searches need the
+	// database; a device lookup falls back to the API when the replica lags.
+	fns := sourceFuncs{
+		db: func() (*mcp.CallToolResult, error) {
+			return sensorCurrentDB(ctx, deviceID, minLat, maxLat, minLon, maxLon, limit)
+		},
 	}
-	
-	// Fallback to API if database not available
-	return mcp.NewToolResultError("Database connection required for sensor_current tool. Please ensure DATABASE_URL is set to access real-time sensor data."), nil
+	if deviceID != "" {
+		fns.api = func() (*mcp.CallToolResult, error) { return sensorCurrentAPI(ctx, deviceID) }
+	} else if !dbAvailable() {
+		return mcp.NewToolResultError("Database connection required for sensor_current area searches. Pass device_id to look up a single sensor, or ensure DATABASE_URL is set."), nil
+	}
+	ctx = rawUnitsContext(ctx, req)
+	res, err := routeQuery(ctx, queryRecent, fns)
+	return withDataFreshness(ctx, res, err, true) // /realtime_history is simplemap's own
+}
+
+func sensorCurrentAPI(ctx context.Context, deviceID string) (*mcp.CallToolResult, error) {
+	resp, err := client.GetRealtimeHistory(ctx, deviceID)
+	if err != nil {
+		if isNotFound(err) {
+			return mcp.NewToolResultError(fmt.Sprintf("Device %s not found", deviceID)), nil
+		}
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// The series is not guaranteed to be sorted; take the newest point that
+	// is not in the future (sensor clock errors).
+	var latest map[string]any
+	var latestTS float64
+	now := float64(time.Now().Unix())
+	if series, ok := resp["series"].(map[string]any); ok {
+		if doseRate, ok := series["doseRate"].([]any); ok {
+			for _, raw := range doseRate {
+				pt, ok := raw.(map[string]any)
+				if !ok {
+					continue
+				}
+				ts, ok := toFloat(pt["time"])
+				if !ok || ts > now || ts <= latestTS {
+					continue
+				}
+				latest, latestTS = pt, ts
+			}
+		}
+	}
+
+	readings := []map[string]any{}
+	if latest != nil {
+		readings = append(readings, map[string]any{
+			"device_id":   deviceID,
+			"value":       latest["value"],
+			"unit":        "µSv/h",
+			"captured_at": time.Unix(int64(latestTS), 0).UTC().Format(time.RFC3339),
+		})
+	}
+
+	result := map[string]any{
+		"count":              len(readings),
+		"source":             "api",
+		"readings":           readings,
+		"_ai_hint":           "CRITICAL INSTRUCTIONS: (1) **REAL-TIME DATA**: This is the most recent reading the simplemap API has for this device. The API does not return the sensor location; do not invent one. Always report the 'captured_at' timestamp in UTC - if it is more than 24 hours old, mention this and suggest checking whether the sensor is still active. (2) **PRESENTATION**: State objective facts only - no personal pronouns (I, we, you), exclamations, or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	return measurementResult(ctx, result)
 }
 
 func sensorCurrentDB(ctx context.Context, deviceID string, minLat, maxLat, minLon, maxLon float64, limit int) (*mcp.CallToolResult, error) {
@@ -149,32 +216,14 @@ func sensorCurrentDB(ctx context.Context, deviceID string, minLat, maxLat, minLo
 		args = []interface{}{minLat, maxLat, minLon, maxLon, limit}
 	}
 
-	rows, err := queryRows(ctx, query, args...)
+	rows, err := queryTyped[sensorRow](ctx, query, args...)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Error querying %s table: %v", realtimeTable, err)), nil
 	}
 
 	readings := make([]map[string]any, len(rows))
-	for i, r := range rows {
-		// Fix incorrect unit: Geiger counters report in CPM (counts per minute), not CPS
-		unit := r["unit"]
-		if unitStr, ok := unit.(string); ok {
-			unit = strings.ReplaceAll(strings.ReplaceAll(unitStr, "cps", "cpm"), "CPS", "CPM")
-		}
-
-		readings[i] = map[string]any{
-			"id":          r["id"],
-			"device_id":   r["device_id"],
-			"device_name": r["device_name"],
-			"value":       r["value"],
-			"unit":        unit,
-			"captured_at": r["captured_at"],
-			"location": map[string]any{
-				"latitude":  r["latitude"],
-				"longitude": r["longitude"],
-			},
-			"type": r["transport"],
-		}
+	for i := range rows {
+		readings[i] = sensorReading(ctx, &rows[i])
 	}
 
 	result := map[string]any{
@@ -183,9 +232,28 @@ func sensorCurrentDB(ctx context.Context, deviceID string, minLat, maxLat, minLo
 		"readings": readings,
 		"table_used": realtimeTable,
 		"available_tables": availableTables,
-		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) **REAL-TIME DATA**: This tool returns the MOST RECENT readings from fixed sensors. Readings with future timestamps (sensor clock errors) are automatically filtered out. Always check the 'captured_at' timestamp and report it to the user - if the data is more than 24 hours old, mention this to the user and suggest checking if the sensor is still active. (2) **UNITS**: CPM means 'counts per minute' NOT 'counts per second'. Always convert to µSv/h using detector-specific factors (LND 7318: ~0.0069 µSv/h per CPM). (3) **TOOL SELECTION**: For latest sensor data, use 'sensor_current'. For historical trends, use 'sensor_history'. For mobile measurements, use 'device_history'. Do NOT use 'query_radiation' for current sensor data as it searches the historical markers table. (4) **PRESENTATION**: State objective facts only - no personal pronouns (I, we, you), exclamations, or conversational phrases. (5) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every device_id MUST be a clickable map link using the format [device_id](https://simplemap.safecast.org/?lat=LATITUDE&lon=LONGITUDE&zoom=15) substituting the actual latitude and longitude from the location field. Example: [geigiecast-zen:65002](https://simplemap.safecast.org/?lat=34.48265&lon=136.16314&zoom=15). Never show plain device IDs without a link. Timestamps MUST be shown in UTC.",
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) **REAL-TIME DATA**: This tool returns the MOST RECENT readings from fixed sensors. Readings with future timestamps (sensor clock errors) are automatically filtered out. Always check the 'captured_at' timestamp and report it to the user - if the data is more than 24 hours old, mention this to the user and suggest checking if the sensor is still active. (2) **UNITS**: Readings in CPM are already converted to µSv/h in 'value'; 'cpm' holds the original counts per minute and 'cpm_per_usvh' the factor used. Do not convert again. A reading with a 'conversion_note' is still in CPM (counts per minute, NOT counts per second). (3) **TOOL SELECTION**: For latest sensor data, use 'sensor_current'. For historical trends, use 'sensor_history'. For mobile measurements, use 'device_history'. Do NOT use 'query_radiation' for current sensor data as it searches the historical markers table. (4) **PRESENTATION**: State objective facts only - no personal pronouns (I, we, you), exclamations, or conversational phrases. (5) **FORMATTING — REQUIRED**: Always present results in a markdown table. Every device_id MUST be a clickable map link using the format [device_id]("+mapLinkTemplate("LATITUDE", "LONGITUDE")+") substituting the actual latitude and longitude from the location field. Example: [geigiecast-zen:65002]("+pointMapURL(34.48265, 136.16314, defaultMapZoom)+"). Never show plain device IDs without a link. Timestamps MUST be shown in UTC.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
 
-	return jsonResult(result)
-}
\ No newline at end of file
+	return measurementResult(ctx, result)
+}
+
+// sensorReading turns a row of a real-time sensor table into a reading.
+func sensorReading(ctx context.Context, r *sensorRow) map[string]any {
+	reading := map[string]any{
+		"id":          nullable(r.ID),
+		"device_id":   nullable(r.DeviceID),
+		"device_name": nullable(r.DeviceName),
+		"value":       nullable(r.Value),
+		"captured_at": nullable(r.CapturedAt),
+		"location": map[string]any{
+			"latitude":  nullable(r.Latitude),
+			"longitude": nullable(r.Longitude),
+		},
+		"type": nullable(r.Transport),
+	}
+	sensorUnit(ctx, reading, nullable(r.Unit))
+	convertSensorCPM(ctx, reading, "")
+	return reading
+}
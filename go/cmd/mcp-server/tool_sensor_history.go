@@ -3,14 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 var sensorHistoryToolDef = mcp.NewTool("sensor_history",
-	mcp.WithDescription("Pull time-series data from REAL-TIME fixed sensors (Pointcast, Solarcast, bGeigieZen, etc.) over a date range. Use this tool for historical time-series from fixed sensors. NOT for mobile bGeigie devices - use device_history for those. The 'unit' field indicates the measurement unit - CPM means 'counts per minute' (NOT counts per second). Always present radiation values in µSv/h by converting from CPM using detector-specific factors. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
+	mcp.WithDescription("Pull time-series data from REAL-TIME fixed sensors (Pointcast, Solarcast, bGeigieZen, etc.) over a date range. Use this tool for historical time-series from fixed sensors. NOT for mobile bGeigie devices - use device_history for those. Readings in CPM (counts per minute) are converted to µSv/h on the server with the tube's factor; the count rate is kept in cpm and the factor in cpm_per_usvh, so do not convert again. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool. CRITICAL: Present all findings in an objective, scientific manner without using personal pronouns (I, we, I'll, you) or conversational language (Perfect!, Great!). Format as factual statements only."),
 	mcp.WithString("device_id",
 		mcp.Description("Device identifier to get historical data from"),
 		mcp.Required(),
@@ -27,6 +26,11 @@ var sensorHistoryToolDef = mcp.NewTool("sensor_history",
 		mcp.Min(1), mcp.Max(10000),
 		mcp.DefaultNumber(200),
 	),
+	withRawUnitsParam(),
+	withRainSpikeParam(),
+	withExplainParam(),
+	withCompactParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -46,10 +50,11 @@ func handleSensorHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 		endDateStr = time.Now().Format("2006-01-02")
 	}
 
-	limit := req.GetInt("limit", 200)
+	defLimit, maxLimit := toolLimits("sensor_history", 200, 10000)
+	limit := req.GetInt("limit", defLimit)
 
-	if limit < 1 || limit > 10000 {
-		return mcp.NewToolResultError("Limit must be between 1 and 10000"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 
 	// Parse dates
@@ -67,15 +72,21 @@ func handleSensorHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.Cal
 		return mcp.NewToolResultError("end_date must be after start_date"), nil
 	}
 
+	spikes := req.GetBool("flag_rain_spikes", true)
+
+	ctx = rawUnitsContext(ctx, req)
 	if dbAvailable() {
-		return sensorHistoryDB(ctx, deviceID, startDate, endDate, limit)
+		return sensorHistoryDB(ctx, deviceID, startDate, endDate, limit, spikes)
+	}
+	if demoMode {
+		return sensorHistoryDemo(ctx, deviceID, startDate, endDate, limit, spikes)
 	}
 	
 	// Fallback to API if database not available
 	return mcp.NewToolResultError("Database connection required for sensor_history tool. Please ensure DATABASE_URL is set to access real-time sensor data."), nil
 }
 
-func sensorHistoryDB(ctx context.Context, deviceID string, startDate, endDate time.Time, limit int) (*mcp.CallToolResult, error) {
+func sensorHistoryDB(ctx context.Context, deviceID string, startDate, endDate time.Time, limit int, spikes bool) (*mcp.CallToolResult, error) {
 	// Check what tables are available in the database
 	tablesQuery := `
 		SELECT table_name 
@@ -138,32 +149,14 @@ func sensorHistoryDB(ctx context.Context, deviceID string, startDate, endDate ti
 	startUnix := startDate.Unix()
 	endUnix := endDate.Unix()
 
-	rows, err := queryRows(ctx, query, deviceID, startUnix, endUnix, limit)
+	rows, err := queryTyped[sensorRow](ctx, query, deviceID, startUnix, endUnix, limit)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Error querying %s table: %v", realtimeTable, err)), nil
 	}
 
 	measurements := make([]map[string]any, len(rows))
-	for i, r := range rows {
-		// Fix incorrect unit: Geiger counters report in CPM (counts per minute), not CPS
-		unit := r["unit"]
-		if unitStr, ok := unit.(string); ok {
-			unit = strings.ReplaceAll(strings.ReplaceAll(unitStr, "cps", "cpm"), "CPS", "CPM")
-		}
-
-		measurements[i] = map[string]any{
-			"id":          r["id"],
-			"device_id":   r["device_id"],
-			"device_name": r["device_name"],
-			"value":       r["value"],
-			"unit":        unit,
-			"captured_at": r["captured_at"],
-			"location": map[string]any{
-				"latitude":  r["latitude"],
-				"longitude": r["longitude"],
-			},
-			"type":   r["transport"],
-		}
+	for i := range rows {
+		measurements[i] = sensorReading(ctx, &rows[i])
 	}
 
 	capturedAfter := startDate.Format("2006-01-02") + " 00:00"
@@ -185,6 +178,61 @@ func sensorHistoryDB(ctx context.Context, deviceID string, startDate, endDate ti
 		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements.",
 		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
 	}
+	if spikes {
+		addRainSpikes(result)
+	}
+
+	return measurementResult(ctx, result)
+}
+
+// sensorHistoryDemo answers from the demo dataset (demo_data.go).
+func sensorHistoryDemo(ctx context.Context, deviceID string, startDate, endDate time.Time, limit int, spikes bool) (*mcp.CallToolResult, error) {
+	sensor, ok := demoData().sensor(deviceID)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Device %s not found", deviceID)), nil
+	}
+
+	measurements := []map[string]any{}
+	endOfDay := endDate.AddDate(0, 0, 1).Unix()
+	for _, r := range sensor.Readings {
+		if r.At < startDate.Unix() || r.At >= endOfDay {
+			continue
+		}
+		if len(measurements) == limit {
+			break
+		}
+		measurements = append(measurements, map[string]any{
+			"id":          r.ID,
+			"device_id":   sensor.ID,
+			"device_name": sensor.Name,
+			"value":       r.Value,
+			"unit":        "µSv/h",
+			"captured_at": time.Unix(r.At, 0).UTC().Format(time.RFC3339),
+			"location": map[string]any{
+				"latitude":  sensor.Lat,
+				"longitude": sensor.Lon,
+			},
+			"type": sensor.Transport,
+		})
+	}
+
+	result := map[string]any{
+		"device": map[string]any{
+			"id": deviceID,
+		},
+		"period": map[string]any{
+			"start_date": startDate.Format("2006-01-02") + " 00:00",
+			"end_date":   endDate.Format("2006-01-02") + " 23:59",
+		},
+		"count":        len(measurements),
+		"source":       "demo",
+		"measurements": measurements,
+		"_ai_hint": "CRITICAL INSTRUCTIONS: (1) The 'unit' field indicates measurement units - CPM means 'counts per minute' NOT 'counts per second'. Always interpret and report CPM values as counts per minute. (2) Present all data in a purely scientific, factual manner. NEVER use personal pronouns (I, we, I'll, I'm, you, your), exclamations (!, Perfect, Great, Excellent), or conversational phrases. Format responses as objective statements: 'Latest reading: X CPM at location Y' NOT 'I found a reading of X CPM' or 'Perfect! The sensor shows...'. State only objective facts and measurements.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if spikes {
+		addRainSpikes(result)
+	}
 
-	return jsonResult(result)
-}
\ No newline at end of file
+	return measurementResult(ctx, result)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ── server_changelog ───────────────────────────────────────────────────────
+//
+// Serves the release notes in CHANGELOG.md, embedded at build time, so a
+// client that stays connected across a deploy can find out which tools and
+// limits changed without reading the repository. The file's format is
+// described at its top; lines that do not fit it are skipped.
+
+//go:embed CHANGELOG.md
+var changelogMarkdown string
+
+var changelogKinds = []string{"added", "changed", "fixed", "deprecated", "removed"}
+
+type changelogEntry struct {
+	Kind  string   `json:"kind"`
+	Text  string   `json:"text"`
+	Tools []string `json:"tools,omitempty"`
+}
+
+type changelogRelease struct {
+	Version string           `json:"version"`
+	Date    string           `json:"date,omitempty"`
+	Entries []changelogEntry `json:"entries"`
+}
+
+var (
+	changelogOnce     sync.Once
+	changelogReleases []changelogRelease
+
+	changelogHeadingRe = regexp.MustCompile(`^##\s+v?(\d+(?:\.\d+)*)\s*(?:[—–-]\s*(\d{4}-\d{2}-\d{2}))?\s*$`)
+	changelogEntryRe   = regexp.MustCompile(`^-\s+([a-z]+):\s+(.+)$`)
+	changelogCodeRe    = regexp.MustCompile("`([a-z_]+)`")
+	changelogVersionRe = regexp.MustCompile(`^\d+(\.\d+)*$`)
+)
+
+// releases parses the embedded changelog once, newest release first.
+func releases() []changelogRelease {
+	changelogOnce.Do(func() {
+		for _, line := range strings.Split(changelogMarkdown, "\n") {
+			line = strings.TrimSpace(line)
+			if m := changelogHeadingRe.FindStringSubmatch(line); m != nil {
+				changelogReleases = append(changelogReleases, changelogRelease{Version: m[1], Date: m[2]})
+				continue
+			}
+			m := changelogEntryRe.FindStringSubmatch(line)
+			if m == nil || len(changelogReleases) == 0 || !slices.Contains(changelogKinds, m[1]) {
+				continue
+			}
+			e := changelogEntry{Kind: m[1], Text: m[2]}
+			for _, c := range changelogCodeRe.FindAllStringSubmatch(m[2], -1) {
+				if _, isTool := expectedToolCosts[c[1]]; isTool && !slices.Contains(e.Tools, c[1]) {
+					e.Tools = append(e.Tools, c[1])
+				}
+			}
+			r := &changelogReleases[len(changelogReleases)-1]
+			r.Entries = append(r.Entries, e)
+		}
+	})
+	return changelogReleases
+}
+
+// compareVersions compares dotted version numbers; missing parts are 0.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+var serverChangelogToolDef = mcp.NewTool("server_changelog",
+	mcp.WithDescription("Get the release notes of this MCP server: new tools, new parameters, changed limits and behaviour, newest release first. Call it when a tool seems to be missing or behaves differently than expected, or pass since_version with the version seen earlier in the session to learn what changed after a deploy; then call tools/list again to pick up new tools."),
+	mcp.WithString("since_version",
+		mcp.Description("Only releases newer than this version, e.g. '1.0.0'"),
+	),
+	mcp.WithString("tool",
+		mcp.Description("Only entries about this tool, e.g. 'list_tracks'"),
+	),
+	mcp.WithString("kind",
+		mcp.Description("Only entries of this kind"),
+		mcp.Enum(changelogKinds...),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of releases (default: 3, max: 50)"),
+		mcp.Min(1), mcp.Max(50),
+		mcp.DefaultNumber(3),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleServerChangelog(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	since := strings.TrimPrefix(strings.TrimSpace(req.GetString("since_version", "")), "v")
+	tool := strings.TrimSpace(req.GetString("tool", ""))
+	kind := req.GetString("kind", "")
+	limit := req.GetInt("limit", 3)
+	if limit < 1 || limit > 50 {
+		return mcp.NewToolResultError("Limit must be between 1 and 50"), nil
+	}
+	if since != "" && !changelogVersionRe.MatchString(since) {
+		return mcp.NewToolResultError(fmt.Sprintf("since_version %q is not a version number like 1.0.0", since)), nil
+	}
+	if kind != "" && !slices.Contains(changelogKinds, kind) {
+		return mcp.NewToolResultError("kind must be one of: " + strings.Join(changelogKinds, ", ")), nil
+	}
+	if tool != "" {
+		if _, ok := expectedToolCosts[tool]; !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("unknown tool %q", tool)), nil
+		}
+	}
+
+	var registered map[string]*server.ServerTool
+	if s := server.ServerFromContext(ctx); s != nil {
+		registered = s.ListTools()
+	}
+
+	out := []map[string]any{}
+	entries := 0
+	for _, r := range releases() {
+		if since != "" && compareVersions(r.Version, since) <= 0 {
+			break
+		}
+		if len(out) == limit {
+			break
+		}
+		list := []changelogEntry{}
+		for _, e := range r.Entries {
+			if (kind == "" || e.Kind == kind) && (tool == "" || slices.Contains(e.Tools, tool)) {
+				list = append(list, e)
+			}
+		}
+		if len(list) == 0 && (tool != "" || kind != "") {
+			continue
+		}
+		entries += len(list)
+		rel := map[string]any{
+			"version": r.Version,
+			"entries": list,
+			"current": r.Version == serverVersion,
+		}
+		if r.Date != "" {
+			rel["date"] = r.Date
+		}
+		out = append(out, rel)
+	}
+
+	result := map[string]any{
+		"server_version": fullVersion(),
+		"count":          len(out),
+		"entries":        entries,
+		"releases":       out,
+		"_ai_hint":       "Each entry has a kind (added, changed, fixed, deprecated, removed) and the tools it concerns. After a deploy, call tools/list again to see new tools and parameters. Present findings objectively, without personal pronouns or conversational phrases.",
+	}
+	if len(out) == 0 && since != "" {
+		result["note"] = fmt.Sprintf("No releases after %s; the server runs %s.", since, serverVersion)
+	}
+	if registered != nil {
+		var missing []string
+		for _, r := range out {
+			for _, e := range r["entries"].([]changelogEntry) {
+				for _, t := range e.Tools {
+					if _, ok := registered[t]; !ok && e.Kind != "removed" && !slices.Contains(missing, t) {
+						missing = append(missing, t)
+					}
+				}
+			}
+		}
+		if len(missing) > 0 {
+			result["not_registered"] = missing
+			result["not_registered_note"] = "These tools are in the release notes but not offered by this server, usually because they are maintainer-only and no maintainers are configured."
+		}
+	}
+	return jsonResult(result)
+}
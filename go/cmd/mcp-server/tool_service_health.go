@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var serviceHealthToolDef = mcp.NewTool("service_health",
+	mcp.WithDescription("Report this MCP server's own health: per-tool P95 latency and error rate over the recent window, the objective (SLO) for each, which tools are currently breaching, and how often each has breached. Use it to check whether slow or failing answers are a server problem. Not about radiation data."),
+	mcp.WithString("tool",
+		mcp.Description("Only report this tool"),
+	),
+	mcp.WithBoolean("only_breaching",
+		mcp.Description("Only list tools currently breaching an objective (default: false)"),
+	),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleServiceHealth(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	only := strings.TrimSpace(req.GetString("tool", ""))
+	onlyBreaching := req.GetBool("only_breaching", false)
+
+	all := slos.snapshot()
+	tools := make([]sloStatus, 0, len(all))
+	var breaching []string
+	for _, st := range all {
+		if st.Status == "breaching" {
+			breaching = append(breaching, st.Tool)
+		}
+		if (only != "" && st.Tool != only) || (onlyBreaching && st.Status != "breaching") {
+			continue
+		}
+		tools = append(tools, st)
+	}
+	if only != "" && len(tools) == 0 && !onlyBreaching {
+		return mcp.NewToolResultError(fmt.Sprintf("No calls to %q in the last %s", only, slos.window)), nil
+	}
+
+	status := "ok"
+	hint := "All tools with enough recent calls are within their objectives."
+	if len(breaching) > 0 {
+		status = "degraded"
+		hint = fmt.Sprintf("Tools breaching an objective: %s. Answers from them may be slow or fail; say so when using them.", strings.Join(breaching, ", "))
+	}
+	return jsonResult(map[string]any{
+		"status":    status,
+		"breaching": breaching,
+		"window":    slos.window.String(),
+		"min_calls": slos.minCalls,
+		"tools":     tools,
+		"version":   fullVersion(),
+		"sources":   router.Stats(),
+		"_ai_hint":  hint + " Tools marked insufficient_data have too few calls in the window to judge.",
+	})
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── spectrum_dose_check ────────────────────────────────────────────────────
+//
+// A spectrometer and the dose rate recorded with its spectrum should agree:
+// the spectrum's count rate divided by the detector's sensitivity is a dose
+// rate estimate of its own. When the two disagree by more than a factor,
+// either the spectrum or the marker is off, and a device model whose
+// spectra disagree with their markers as a rule is probably mis-calibrated.
+// Sensitivities are the manufacturers' Cs-137 figures; background spectra
+// are softer than Cs-137, to which small scintillators respond more, so the
+// estimate runs somewhat high and the default tolerance is a factor of 2.
+
+// spectrumDoseThresholdKeV is the lowest energy counted; below it the
+// spectrum is mostly electronic noise and X-ray fluorescence.
+const spectrumDoseThresholdKeV = 30
+
+const defaultDoseCheckTolerance = 2.0
+
+// detectorSensitivities are count rates per µSv/h at Cs-137 by device
+// model, in compactKey form, most specific first; a model matches the
+// first key it starts with.
+var detectorSensitivities = []struct {
+	key  string
+	cps  float64
+	name string
+}{
+	{"radiacode110", 77, "RadiaCode-110 (CsI(Tl) 10×10×30 mm)"},
+	{"radiacode103g", 40, "RadiaCode-103G (GAGG 10×10×10 mm)"},
+	{"rc103g", 40, "RadiaCode-103G (GAGG 10×10×10 mm)"},
+	{"radiacode", 30, "RadiaCode-101/102/103 (CsI(Tl) 10×10×10 mm)"},
+	{"rc10", 30, "RadiaCode-101/102/103 (CsI(Tl) 10×10×10 mm)"},
+}
+
+// detectorSensitivity returns the Cs-137 sensitivity of a device model in
+// counts per second per µSv/h, and the detector it was taken from.
+func detectorSensitivity(model string) (float64, string, bool) {
+	key := compactKey(model)
+	for _, d := range detectorSensitivities {
+		if key != "" && strings.HasPrefix(key, d.key) {
+			return d.cps, d.name, true
+		}
+	}
+	return 0, "", false
+}
+
+var doseCheckStatuses = []string{"consistent", "marker_high", "marker_low", "unknown_detector", "no_marker_doserate", "no_live_time"}
+
+var spectrumDoseCheckToolDef = mcp.NewTool("spectrum_dose_check",
+	mcp.WithDescription("Check spectra against the dose rate recorded at their marker: the spectrum's count rate above 30 keV divided by the detector's sensitivity (counts per second per µSv/h at Cs-137) gives an independent dose rate estimate, and spectra whose marker dose rate differs from it by more than the tolerance factor are flagged. Summarizes per device model, so models whose spectra disagree as a rule (likely mis-calibrated) stand out. Give a marker_id for one spectrum, or filter by track_id and/or device_model; without filters the most recent spectra are checked. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithNumber("marker_id",
+		mcp.Description("Check only the spectrum at this marker"),
+		mcp.Min(1),
+	),
+	mcp.WithString("track_id",
+		mcp.Description("Only spectra on this track, e.g. '8eh5m1'"),
+	),
+	mcp.WithString("device_model",
+		mcp.Description("Only spectra from this detector model. Partial match; case, spaces and hyphens are ignored and known aliases are included."),
+	),
+	mcp.WithNumber("tolerance",
+		mcp.Description("Flag spectra whose marker dose rate is more than this factor above or below the spectrum estimate (default: 2)"),
+		mcp.Min(1.1), mcp.Max(10),
+		mcp.DefaultNumber(defaultDoseCheckTolerance),
+	),
+	mcp.WithNumber("sensitivity_cps_per_usvh",
+		mcp.Description("Detector sensitivity in counts per second per µSv/h to use instead of the built-in value for the device model; required for models without one"),
+		mcp.Min(0.1),
+	),
+	mcp.WithBoolean("only_flagged",
+		mcp.Description("Return only the spectra flagged marker_high or marker_low; the summary still covers all (default: false)"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of spectra to check (default: 100, max: 500)"),
+		mcp.Min(1), mcp.Max(500),
+		mcp.DefaultNumber(100),
+	),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleSpectrumDoseCheck(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	q := spectrumQuery{
+		MarkerID:    req.GetInt("marker_id", 0),
+		TrackID:     strings.TrimSpace(req.GetString("track_id", "")),
+		DeviceModel: strings.TrimSpace(req.GetString("device_model", "")),
+	}
+	if q.MarkerID > 0 && (q.TrackID != "" || q.DeviceModel != "") {
+		return mcp.NewToolResultError("marker_id selects one spectrum; do not combine it with track_id or device_model"), nil
+	}
+	tolerance := req.GetFloat("tolerance", defaultDoseCheckTolerance)
+	if tolerance < 1.1 || tolerance > 10 {
+		return mcp.NewToolResultError("tolerance must be between 1.1 and 10"), nil
+	}
+	sensitivity := req.GetFloat("sensitivity_cps_per_usvh", 0)
+	if sensitivity < 0 {
+		return mcp.NewToolResultError("sensitivity_cps_per_usvh must be positive"), nil
+	}
+	onlyFlagged := req.GetBool("only_flagged", false)
+	defLimit, maxLimit := toolLimits("spectrum_dose_check", 100, 500)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+
+	spectra, source, truncated, err := loadSpectra(ctx, q, limit)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if q.MarkerID > 0 && len(spectra) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No spectrum recorded at marker %d; use list_spectra to find markers with spectra", q.MarkerID)), nil
+	}
+
+	type modelStats struct {
+		spectra, checked, flagged int
+		ratios                    []float64
+	}
+	byModel := map[string]*modelStats{}
+	var models []string
+	statuses := map[string]int{}
+	for _, s := range doseCheckStatuses {
+		statuses[s] = 0
+	}
+	results := []map[string]any{}
+	for _, s := range spectra {
+		entry, status, ratio := doseCheck(s, sensitivity, tolerance)
+		statuses[status]++
+		st, ok := byModel[s.DeviceModel]
+		if !ok {
+			st = &modelStats{}
+			byModel[s.DeviceModel] = st
+			models = append(models, s.DeviceModel)
+		}
+		st.spectra++
+		if ratio > 0 {
+			st.checked++
+			st.ratios = append(st.ratios, ratio)
+		}
+		flagged := status == "marker_high" || status == "marker_low"
+		if flagged {
+			st.flagged++
+		}
+		if !onlyFlagged || flagged {
+			results = append(results, entry)
+		}
+	}
+
+	summary := make([]map[string]any, 0, len(models))
+	for _, model := range models {
+		st := byModel[model]
+		entry := map[string]any{
+			"device_model": model,
+			"spectra":      st.spectra,
+			"checked":      st.checked,
+			"flagged":      st.flagged,
+		}
+		if len(st.ratios) > 0 {
+			slices.Sort(st.ratios)
+			median := st.ratios[len(st.ratios)/2]
+			if len(st.ratios)%2 == 0 {
+				median = (st.ratios[len(st.ratios)/2-1] + median) / 2
+			}
+			entry["median_ratio"] = roundSignificant(median, 3)
+			entry["likely_miscalibrated"] = st.checked >= 3 && (median > tolerance || median < 1/tolerance)
+		}
+		summary = append(summary, entry)
+	}
+
+	result := map[string]any{
+		"count":              len(results),
+		"checked":            len(spectra),
+		"source":             source,
+		"tolerance":          tolerance,
+		"by_status":          statuses,
+		"by_model":           summary,
+		"spectra":            results,
+		"method":             fmt.Sprintf("Counts above %d keV per second of live time, divided by the detector's count rate per µSv/h at Cs-137, give the spectrum's dose rate estimate. ratio is the marker's recorded dose rate over that estimate: marker_high above the tolerance, marker_low below its inverse. Background spectra are softer than Cs-137, so estimates tend to run high and ratios slightly below 1 are normal. A device model is likely_miscalibrated when its median ratio over at least 3 spectra is outside the tolerance.", spectrumDoseThresholdKeV),
+		"_ai_hint":           "A flagged spectrum means the spectrum and its marker disagree, not which one is wrong; a model flagged as a rule points to its calibration. Estimates rest on the manufacturers' sensitivity figures. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	filters := map[string]any{}
+	if q.MarkerID > 0 {
+		filters["marker_id"] = q.MarkerID
+	}
+	if q.TrackID != "" {
+		filters["track_id"] = q.TrackID
+	}
+	if q.DeviceModel != "" {
+		filters["device_model"] = q.DeviceModel
+		filters["device_model_matches"] = detectorAliases(q.DeviceModel)
+	}
+	result["filters"] = filters
+	if truncated {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("Only %d spectra were checked; raise limit or narrow the filters for more.", limit)
+	}
+	if statuses["unknown_detector"] > 0 {
+		result["unknown_detector_note"] = "No built-in sensitivity for some device models; pass sensitivity_cps_per_usvh to check them."
+	}
+	if len(spectra) == 0 {
+		result["note"] = "No spectra match the filters. Use list_spectra to find spectra."
+	}
+	return jsonResult(result)
+}
+
+// doseCheck compares one spectrum with its marker. ratio is 0 when the
+// comparison could not be made.
+func doseCheck(s spectrumRecord, sensitivity, tolerance float64) (map[string]any, string, float64) {
+	entry := s.summary()
+	var counts float64
+	energies, calibration := s.channelEnergies()
+	for i, c := range s.Channels {
+		if energies == nil || energies[i] >= spectrumDoseThresholdKeV {
+			counts += c
+		}
+	}
+	entry["energy_calibration"] = calibration
+
+	if sensitivity > 0 {
+		entry["sensitivity_cps_per_usvh"] = sensitivity
+		entry["sensitivity_source"] = "parameter"
+	} else if cps, name, ok := detectorSensitivity(s.DeviceModel); ok {
+		sensitivity = cps
+		entry["sensitivity_cps_per_usvh"] = cps
+		entry["sensitivity_source"] = name
+	}
+
+	switch {
+	case s.LiveTimeSec <= 0:
+		entry["status"] = "no_live_time"
+		return entry, "no_live_time", 0
+	case sensitivity <= 0:
+		entry["status"] = "unknown_detector"
+		return entry, "unknown_detector", 0
+	}
+	cps := counts / s.LiveTimeSec
+	estimate := cps / sensitivity
+	entry["count_rate"] = roundSignificant(cps, 4)
+	entry["spectrum_doserate"] = roundSignificant(estimate, doseSignificantDigits)
+	if !s.HasMarker || s.DoseRate <= 0 || estimate <= 0 {
+		entry["status"] = "no_marker_doserate"
+		return entry, "no_marker_doserate", 0
+	}
+
+	ratio := s.DoseRate / estimate
+	status := "consistent"
+	switch {
+	case ratio > tolerance:
+		status = "marker_high"
+	case ratio < 1/tolerance:
+		status = "marker_low"
+	}
+	entry["ratio"] = roundSignificant(ratio, 3)
+	entry["status"] = status
+	if status != "consistent" {
+		entry["discrepancy"] = fmt.Sprintf("marker %g µSv/h is %.1f× the spectrum's %g µSv/h",
+			roundSignificant(s.DoseRate, doseSignificantDigits), ratio, roundSignificant(estimate, doseSignificantDigits))
+	}
+	return entry, status, ratio
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── spectrum_roi_rate ──────────────────────────────────────────────────────
+//
+// Net count rates in fixed energy windows (regions of interest) around the
+// gamma lines of the isotopes people screen for, so "is there Cs-137 in
+// these spectra, and how much" can be answered, scripted and charted
+// without fitting peaks. The continuum under a line is estimated from two
+// side bands of half the window's width just below and above it and
+// interpolated linearly (the usual trapezoid background). The windows are
+// wide enough for the ~7% resolution of NaI and CsI scintillators; sharper
+// detectors simply have the peak well inside them.
+
+// roiWindow is an energy window around one gamma line.
+type roiWindow struct {
+	Key     string
+	Isotope string
+	LineKeV float64
+	Lo, Hi  float64
+	Note    string
+}
+
+var roiWindows = []roiWindow{
+	{Key: "cs137", Isotope: "Cs-137", LineKeV: 662, Lo: 615, Hi: 710},
+	{Key: "i131", Isotope: "I-131", LineKeV: 364, Lo: 330, Hi: 400, Note: "half-life 8 days: only present within weeks of a release"},
+	{Key: "k40", Isotope: "K-40", LineKeV: 1461, Lo: 1370, Hi: 1550, Note: "natural, present in soil and building materials"},
+	{Key: "cs134", Isotope: "Cs-134", LineKeV: 796, Lo: 750, Hi: 845, Note: "its 605 keV line falls in the Cs-137 window"},
+}
+
+var defaultROIWindows = []string{"cs137", "i131", "k40"}
+
+// roiDetectionSigma is the significance above which a window counts as a
+// detection.
+const roiDetectionSigma = 3
+
+func roiWindowKeys() []string {
+	keys := make([]string, len(roiWindows))
+	for i, w := range roiWindows {
+		keys[i] = w.Key
+	}
+	return keys
+}
+
+var spectrumROIRateToolDef = mcp.NewTool("spectrum_roi_rate",
+	mcp.WithDescription("Net count rates (counts per second above the continuum) in named energy windows for isotope screening: Cs-137 (662 keV), I-131 (364 keV), K-40 (1461 keV) and Cs-134 (796 keV). Give a marker_id for one spectrum, or a track_id for every spectrum on a drive in time order, ready to chart. Each window reports gross, background and net rate with its uncertainty and whether the line is detected (net above 3 sigma). Use list_spectra to find spectra. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithNumber("marker_id",
+		mcp.Description("Marker with a spectrum (one of marker_id or track_id)"),
+		mcp.Min(1),
+	),
+	mcp.WithString("track_id",
+		mcp.Description("Track whose spectra to analyse (one of marker_id or track_id), e.g. '8eh5m1'"),
+	),
+	mcp.WithArray("windows",
+		mcp.Description("Energy windows to report (default: ['cs137', 'i131', 'k40'])"),
+		mcp.WithStringEnumItems(roiWindowKeys()),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("Maximum number of spectra for a track (default: 100, max: 500)"),
+		mcp.Min(1), mcp.Max(500),
+		mcp.DefaultNumber(100),
+	),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+func handleSpectrumROIRate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	markerID := req.GetInt("marker_id", 0)
+	trackID := strings.TrimSpace(req.GetString("track_id", ""))
+	if (markerID > 0) == (trackID != "") {
+		return mcp.NewToolResultError("Give exactly one of marker_id or track_id"), nil
+	}
+	defLimit, maxLimit := toolLimits("spectrum_roi_rate", 100, 500)
+	limit := req.GetInt("limit", defLimit)
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
+	}
+	keys := req.GetStringSlice("windows", defaultROIWindows)
+	if len(keys) == 0 {
+		keys = defaultROIWindows
+	}
+	var windows []roiWindow
+	for _, k := range keys {
+		i := slices.IndexFunc(roiWindows, func(w roiWindow) bool { return w.Key == strings.ToLower(k) })
+		if i < 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown window %q; available: %s", k, strings.Join(roiWindowKeys(), ", "))), nil
+		}
+		if !slices.Contains(windows, roiWindows[i]) {
+			windows = append(windows, roiWindows[i])
+		}
+	}
+
+	spectra, source, truncated, err := loadSpectra(ctx, spectrumQuery{MarkerID: markerID, TrackID: trackID}, limit)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if markerID > 0 && len(spectra) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No spectrum recorded at marker %d; use list_spectra to find markers with spectra", markerID)), nil
+	}
+
+	results := make([]map[string]any, 0, len(spectra))
+	for _, s := range spectra {
+		entry := s.summary()
+		energies, calibration := s.channelEnergies()
+		entry["energy_calibration"] = calibration
+		rates := make([]map[string]any, 0, len(windows))
+		for _, w := range windows {
+			rates = append(rates, roiRate(s, energies, w))
+		}
+		entry["windows"] = rates
+		results = append(results, entry)
+	}
+
+	defs := make([]map[string]any, len(windows))
+	for i, w := range windows {
+		defs[i] = map[string]any{
+			"window":     w.Key,
+			"isotope":    w.Isotope,
+			"line_kev":   w.LineKeV,
+			"window_kev": []float64{w.Lo, w.Hi},
+		}
+		if w.Note != "" {
+			defs[i]["note"] = w.Note
+		}
+	}
+
+	result := map[string]any{
+		"count":              len(results),
+		"source":             source,
+		"windows":            defs,
+		"spectra":            results,
+		"method":             fmt.Sprintf("Gross counts in each window minus the continuum, estimated from side bands of half the window's width below and above it and interpolated linearly; rates are per second of live time. A window is detected when the net rate exceeds %d standard deviations of counting statistics. Below about 500 keV the continuum curves, so the linear estimate can run above it and give small negative net rates.", roiDetectionSigma),
+		"_ai_hint":           "Net rates are counts per second above the continuum in the window, not dose rates. Compare them between spectra from the same detector model only; efficiency differs between detectors. detected: false means the line is not distinguishable from the continuum, not that the isotope is absent. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if markerID > 0 {
+		result["marker_id"] = markerID
+	} else {
+		result["track_id"] = trackID
+		result["summary"] = roiTrackSummary(results, windows)
+		if truncated {
+			result["truncated"] = true
+			result["note"] = fmt.Sprintf("Only the first %d spectra of the track, in time order; raise limit for more.", limit)
+		}
+		if len(results) == 0 {
+			result["note"] = "No spectra on this track. Use list_spectra to find tracks with spectra."
+		}
+	}
+	return jsonResult(result)
+}
+
+// roiRate measures one window of a spectrum.
+func roiRate(s spectrumRecord, energies []float64, w roiWindow) map[string]any {
+	out := map[string]any{"window": w.Key, "isotope": w.Isotope}
+	if energies == nil {
+		out["error"] = "no energy calibration or energy range"
+		return out
+	}
+	band := (w.Hi - w.Lo) / 2
+	gross, nW := sumCounts(s.Channels, energies, w.Lo, w.Hi)
+	left, nL := sumCounts(s.Channels, energies, w.Lo-band, w.Lo)
+	right, nR := sumCounts(s.Channels, energies, w.Hi, w.Hi+band)
+	if nW == 0 {
+		out["error"] = fmt.Sprintf("window outside the spectrum's energy range (%.0f–%.0f keV)", energies[0], energies[len(energies)-1])
+		return out
+	}
+
+	// Background per channel from the side bands available, and its variance.
+	var perChannel, variance float64
+	switch {
+	case nL > 0 && nR > 0:
+		perChannel = (left/float64(nL) + right/float64(nR)) / 2
+		variance = (left/float64(nL*nL) + right/float64(nR*nR)) / 4
+	case nL > 0:
+		perChannel, variance = left/float64(nL), left/float64(nL*nL)
+	case nR > 0:
+		perChannel, variance = right/float64(nR), right/float64(nR*nR)
+	}
+	background := perChannel * float64(nW)
+	net := gross - background
+	sigma := math.Sqrt(gross + variance*float64(nW*nW))
+
+	out["gross_counts"] = gross
+	out["net_counts"] = math.Round(net)
+	switch {
+	case nL == 0 && nR == 0:
+		out["background_note"] = "no side bands inside the spectrum; background not subtracted"
+	case nL == 0 || nR == 0:
+		out["background_note"] = "only one side band inside the spectrum; background may be less accurate"
+	}
+	if s.LiveTimeSec > 0 {
+		out["gross_cps"] = roundSignificant(gross/s.LiveTimeSec, 4)
+		out["background_cps"] = roundSignificant(background/s.LiveTimeSec, 4)
+		out["net_cps"] = roundSignificant(net/s.LiveTimeSec, 4)
+		out["net_cps_uncertainty"] = roundSignificant(sigma/s.LiveTimeSec, 2)
+	} else {
+		out["rate_note"] = "no live time recorded; counts only"
+	}
+	significance := 0.0
+	if sigma > 0 {
+		significance = net / sigma
+	}
+	out["significance_sigma"] = math.Round(significance*10) / 10
+	out["detected"] = significance > roiDetectionSigma
+	return out
+}
+
+// sumCounts adds the counts of channels whose centre energy is in [lo, hi).
+func sumCounts(counts, energies []float64, lo, hi float64) (float64, int) {
+	var sum float64
+	n := 0
+	for i, e := range energies {
+		if e >= lo && e < hi && i < len(counts) {
+			sum += counts[i]
+			n++
+		}
+	}
+	return sum, n
+}
+
+// roiTrackSummary condenses the windows over all spectra of a track.
+func roiTrackSummary(spectra []map[string]any, windows []roiWindow) []map[string]any {
+	out := make([]map[string]any, 0, len(windows))
+	for i, w := range windows {
+		var rates []float64
+		detected := 0
+		var peak map[string]any
+		var peakRate float64
+		for _, s := range spectra {
+			r := s["windows"].([]map[string]any)[i]
+			if d, _ := r["detected"].(bool); d {
+				detected++
+			}
+			v, ok := toFloat(r["net_cps"])
+			if !ok {
+				continue
+			}
+			rates = append(rates, v)
+			if peak == nil || v > peakRate {
+				peak, peakRate = s, v
+			}
+		}
+		entry := map[string]any{"window": w.Key, "isotope": w.Isotope, "spectra": len(rates), "detected_in": detected}
+		if len(rates) > 0 {
+			var sum float64
+			for _, v := range rates {
+				sum += v
+			}
+			entry["mean_net_cps"] = roundSignificant(sum/float64(len(rates)), 4)
+			entry["max_net_cps"] = peakRate
+			entry["max_at_marker_id"] = peak["marker_id"]
+		}
+		out = append(out, entry)
+	}
+	return out
+}
@@ -28,6 +28,8 @@ var topUploadersToolDef = mcp.NewTool("top_uploaders",
 		mcp.Description("Filter by year (e.g., 2024, 2026). Optional."),
 		mcp.Min(2000), mcp.Max(2100),
 	),
+	withExplainParam(),
+	withCitationParam(),
 	mcp.WithReadOnlyHintAnnotation(true),
 )
 
@@ -36,13 +38,14 @@ func handleTopUploaders(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 		return mcp.NewToolResultError("Database connection required for top uploaders query"), nil
 	}
 
-	limit := req.GetInt("limit", 20)
+	defLimit, maxLimit := toolLimits("top_uploaders", 20, 100)
+	limit := req.GetInt("limit", defLimit)
 	sortBy := req.GetString("sort_by", "marker_count")
 	groupBy := req.GetString("group_by", "user")
 	year := req.GetInt("year", 0)
 
-	if limit < 1 || limit > 100 {
-		return mcp.NewToolResultError("Limit must be between 1 and 100"), nil
+	if limit < 1 || limit > maxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Limit must be between 1 and %d", maxLimit)), nil
 	}
 	if year != 0 && (year < 2000 || year > 2100) {
 		return mcp.NewToolResultError("Year must be between 2000 and 2100"), nil
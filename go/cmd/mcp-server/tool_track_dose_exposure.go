@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── track_dose_exposure ────────────────────────────────────────────────────
+//
+// After a long survey in a contaminated area, volunteers ask how much dose
+// they picked up on the drive. The markers of a track (in time order, as
+// track_profile loads them) are integrated over their timestamps with the
+// trapezoid rule: each step adds the mean of its two dose rates times the
+// time between them. Steps longer than max_gap_minutes are treated as the
+// logger being off or the car parked somewhere unmeasured and are left out;
+// what they would add at the surrounding rates is reported separately.
+//
+// The markers record the ambient dose equivalent rate at the detector,
+// usually mounted outside the car, so the result describes what a person
+// standing at the detector would have received. shielding_factor scales it
+// for the car body. This is an estimate for perspective, not dosimetry.
+
+const (
+	defaultExposureMaxGapMinutes = 10.0
+	annualPublicLimitUSv         = 1000.0 // ICRP public dose limit, 1 mSv per year
+	typicalBackgroundUSvH        = 0.1    // outdoor gamma background in much of the world
+	chestXRayUSv                 = 20.0
+	longHaulFlightUSv            = 50.0 // e.g. Tokyo–Paris, mostly cosmic radiation
+)
+
+var trackDoseExposureToolDef = mcp.NewTool("track_dose_exposure",
+	mcp.WithDescription("Estimate the cumulative radiation dose received by the person who drove or walked a bGeigie track, by integrating the recorded dose rate over the measurement timestamps. Returns the total in µSv, recorded time, average and peak dose rate, the time-weighted contribution of the hottest part of the drive, and comparisons with background radiation, a chest X-ray, a long-haul flight and the annual public dose limit. Use list_tracks or nearest_tracks to find track IDs. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("track_id",
+		mcp.Description("Track identifier (bGeigie import ID or track ID)"),
+		mcp.Required(),
+	),
+	mcp.WithNumber("max_gap_minutes",
+		mcp.Description("Steps between consecutive measurements longer than this are treated as the logger being off and are not integrated (default: 10)"),
+		mcp.Min(0.5), mcp.Max(240),
+		mcp.DefaultNumber(defaultExposureMaxGapMinutes),
+	),
+	mcp.WithNumber("shielding_factor",
+		mcp.Description("Fraction of the outside dose rate reaching the person, e.g. about 0.7 inside a car with the detector mounted outside, 1 when walking with it (default: 1)"),
+		mcp.Min(0.05), mcp.Max(1),
+		mcp.DefaultNumber(1),
+	),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+// trackExposure is the integrated dose of one track.
+type trackExposure struct {
+	DoseUSv        float64 // integrated over the recorded steps
+	GapDoseUSv     float64 // what the skipped gaps would add at their end rates
+	Recorded       time.Duration
+	Gaps           int
+	GapTime        time.Duration
+	PeakStep       *profileMarker // marker ending the step that added the most dose
+	PeakStepDose   float64
+	HottestHourUSv float64 // most dose received in any 60 recorded minutes
+}
+
+// integrateExposure integrates the dose rate of markers (in time order)
+// over time, skipping steps longer than maxGap.
+func integrateExposure(markers []profileMarker, maxGap time.Duration) trackExposure {
+	var e trackExposure
+	type step struct {
+		end  time.Time
+		dose float64
+	}
+	var steps []step
+	for i := 1; i < len(markers); i++ {
+		prev, cur := &markers[i-1], &markers[i]
+		dt := cur.CapturedAt.Sub(prev.CapturedAt)
+		if dt <= 0 {
+			continue
+		}
+		dose := (prev.dose + cur.dose) / 2 * dt.Hours()
+		if dt > maxGap {
+			e.Gaps++
+			e.GapTime += dt
+			e.GapDoseUSv += dose
+			continue
+		}
+		e.DoseUSv += dose
+		e.Recorded += dt
+		steps = append(steps, step{cur.CapturedAt, dose})
+		if dose > e.PeakStepDose {
+			e.PeakStepDose, e.PeakStep = dose, cur
+		}
+	}
+
+	// Sliding 60-minute window over the recorded steps.
+	var window float64
+	start := 0
+	for _, s := range steps {
+		window += s.dose
+		for s.end.Sub(steps[start].end) >= time.Hour {
+			window -= steps[start].dose
+			start++
+		}
+		e.HottestHourUSv = max(e.HottestHourUSv, window)
+	}
+	return e
+}
+
+func handleTrackDoseExposure(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	trackID, err := req.RequireString("track_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	maxGapMinutes := req.GetFloat("max_gap_minutes", defaultExposureMaxGapMinutes)
+	if maxGapMinutes < 0.5 || maxGapMinutes > 240 {
+		return mcp.NewToolResultError("max_gap_minutes must be between 0.5 and 240"), nil
+	}
+	shielding := req.GetFloat("shielding_factor", 1)
+	if shielding < 0.05 || shielding > 1 {
+		return mcp.NewToolResultError("shielding_factor must be between 0.05 and 1"), nil
+	}
+
+	p, err := buildTrackProfile(ctx, trackID, defaultTrackProfilePoints)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	e := integrateExposure(p.Markers, time.Duration(maxGapMinutes*float64(time.Minute)))
+	if e.Recorded <= 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("track %s has no consecutive measurements less than %g minutes apart to integrate", trackID, maxGapMinutes)), nil
+	}
+	first, last := p.Markers[0], p.Markers[len(p.Markers)-1]
+	received := e.DoseUSv * shielding
+	hours := e.Recorded.Hours()
+
+	result := map[string]any{
+		"track_id":              trackID,
+		"map_url":               trackMapURL(trackID),
+		"started_at":            first.CapturedAt.UTC().Format(time.RFC3339),
+		"ended_at":              last.CapturedAt.UTC().Format(time.RFC3339),
+		"measurements_used":     len(p.Markers),
+		"recorded_minutes":      math.Round(e.Recorded.Minutes()*10) / 10,
+		"cumulative_dose_usv":   roundSignificant(e.DoseUSv, 4),
+		"shielding_factor":      shielding,
+		"estimated_dose_usv":    roundSignificant(received, 4),
+		"average_usvh":          roundSignificant(e.DoseUSv/hours, 4),
+		"hottest_hour_dose_usv": roundSignificant(e.HottestHourUSv*shielding, 4),
+		"comparison": map[string]any{
+			"background_hours_equivalent":    roundSignificant(received/typicalBackgroundUSvH, 3),
+			"chest_xrays_equivalent":         roundSignificant(received/chestXRayUSv, 4),
+			"long_haul_flights_equivalent":   roundSignificant(received/longHaulFlightUSv, 4),
+			"percent_of_annual_public_limit": roundSignificant(received/annualPublicLimitUSv*100, 4),
+			"excess_over_background_usv":     roundSignificant(math.Max(received-typicalBackgroundUSvH*hours*shielding, 0), 4),
+			"reference_values":               fmt.Sprintf("background %.1f µSv/h, chest X-ray %.0f µSv, long-haul flight about %.0f µSv, annual public limit %.0f µSv (1 mSv)", typicalBackgroundUSvH, chestXRayUSv, longHaulFlightUSv, annualPublicLimitUSv),
+		},
+		"method":             fmt.Sprintf("Trapezoid integration of the dose rate over the %d measurement timestamps; steps longer than %g minutes are not integrated. Multiplied by shielding_factor for the estimated dose.", len(p.Markers), maxGapMinutes),
+		"_ai_hint":           "estimated_dose_usv is the best estimate of what the surveyor received (cumulative_dose_usv times shielding_factor); cumulative_dose_usv is the dose at the detector. Report it in µSv with the comparisons, and say that it is an estimate from ambient dose rate readings, not a personal dosimeter reading. If gaps were skipped, mention that time spent there is not included. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if p.Peak != nil {
+		result["peak_usvh"] = p.Peak.dose
+	}
+	if e.PeakStep != nil {
+		result["largest_step"] = map[string]any{
+			"dose_usv":  roundSignificant(e.PeakStepDose*shielding, 4),
+			"ended_at":  e.PeakStep.CapturedAt.UTC().Format(time.RFC3339),
+			"location":  map[string]any{"latitude": e.PeakStep.lat, "longitude": e.PeakStep.lon},
+			"map_url":   pointMapURL(e.PeakStep.lat, e.PeakStep.lon, 17),
+			"marker_id": e.PeakStep.ID,
+		}
+	}
+	if e.Gaps > 0 {
+		result["gaps_skipped"] = map[string]any{
+			"count":                e.Gaps,
+			"minutes":              math.Round(e.GapTime.Minutes()*10) / 10,
+			"dose_if_included_usv": roundSignificant(e.GapDoseUSv*shielding, 4),
+			"note":                 "Time between measurements longer than max_gap_minutes (logger off, parked, lost GPS fix) is not integrated. dose_if_included_usv is what it would add at the dose rates either side of each gap.",
+		}
+	}
+	if p.TotalAvailable > p.Fetched {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("The track has %d measurements; the estimate covers the first %d returned by get_track.", p.TotalAvailable, p.Fetched)
+	}
+	return jsonResult(result)
+}
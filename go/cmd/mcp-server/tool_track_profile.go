@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ── track_profile ──────────────────────────────────────────────────────────
+//
+// Dose rate against distance driven for one track. The markers come from
+// get_track, are put in time order and accumulate haversine distance;
+// the profile is then resampled into equal-distance bins so long and short
+// tracks give similarly sized answers. The peak is the single highest
+// marker, not a bin average. GPS jumps (implied speed above
+// trackProfileMaxSpeed) add no distance.
+
+const (
+	defaultTrackProfilePoints = 200
+	maxTrackProfilePoints     = 1000
+	trackProfileMaxSpeed      = 100.0 // m/s
+)
+
+var trackProfileToolDef = mcp.NewTool("track_profile",
+	mcp.WithDescription("Get the dose rate profile of a bGeigie track against cumulative distance driven, resampled into equal-distance bins, plus the location of the peak reading and a chart URL. Use it to answer where along a drive the dose rate was highest. Use list_tracks to find track IDs. IMPORTANT: Every response includes an _ai_generated_note field. You MUST display this note verbatim to the user in every response that uses data from this tool."),
+	mcp.WithString("track_id",
+		mcp.Description("Track identifier (bGeigie import ID or track ID)"),
+		mcp.Required(),
+	),
+	mcp.WithNumber("points",
+		mcp.Description("Number of distance bins in the profile (default: 200, max: 1000)"),
+		mcp.Min(10), mcp.Max(maxTrackProfilePoints),
+		mcp.DefaultNumber(defaultTrackProfilePoints),
+	),
+	withSnapshotParam(),
+	withExplainParam(),
+	withCitationParam(),
+	mcp.WithReadOnlyHintAnnotation(true),
+)
+
+type profileMarker struct {
+	ID         any         `json:"id"`
+	Value      json.Number `json:"value"`
+	CapturedAt time.Time   `json:"captured_at"`
+	Location   struct {
+		Latitude  json.Number `json:"latitude"`
+		Longitude json.Number `json:"longitude"`
+	} `json:"location"`
+
+	dose, lat, lon, distM float64
+}
+
+type profileBin struct {
+	DistanceKm float64 `json:"distance_km"`
+	AvgUSvH    float64 `json:"avg_usvh"`
+	MaxUSvH    float64 `json:"max_usvh"`
+	Samples    int     `json:"samples"`
+}
+
+type trackProfile struct {
+	TrackID         string
+	Markers         []profileMarker
+	Bins            []profileBin
+	TotalKm         float64
+	Fetched         int
+	TotalAvailable  int
+	GPSJumpsSkipped int
+	Peak            *profileMarker
+}
+
+// buildTrackProfile fetches a track through get_track and bins it.
+func buildTrackProfile(ctx context.Context, trackID string, points int) (*trackProfile, error) {
+	_, maxLimit := toolLimits("get_track", 200, 10000)
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "get_track"
+	req.Params.Arguments = map[string]any{"track_id": trackID, "limit": maxLimit}
+	res, err := handleGetTrack(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		TotalAvailable int             `json:"total_available"`
+		Measurements   []profileMarker `json:"measurements"`
+	}
+	if err := decodeToolResult(res, &out); err != nil {
+		return nil, err
+	}
+
+	p := &trackProfile{TrackID: trackID, Fetched: len(out.Measurements), TotalAvailable: out.TotalAvailable}
+	for _, m := range out.Measurements {
+		var err1, err2, err3 error
+		m.dose, err1 = m.Value.Float64()
+		m.lat, err2 = m.Location.Latitude.Float64()
+		m.lon, err3 = m.Location.Longitude.Float64()
+		if err1 != nil || err2 != nil || err3 != nil || (m.lat == 0 && m.lon == 0) {
+			continue
+		}
+		p.Markers = append(p.Markers, m)
+	}
+	if len(p.Markers) < 2 {
+		return nil, fmt.Errorf("track %s has %d usable measurements; a profile needs at least 2", trackID, len(p.Markers))
+	}
+	sort.SliceStable(p.Markers, func(i, j int) bool { return p.Markers[i].CapturedAt.Before(p.Markers[j].CapturedAt) })
+
+	for i := 1; i < len(p.Markers); i++ {
+		prev, cur := &p.Markers[i-1], &p.Markers[i]
+		d := haversineMeters(prev.lat, prev.lon, cur.lat, cur.lon)
+		if dt := cur.CapturedAt.Sub(prev.CapturedAt).Seconds(); dt > 0 && d/dt > trackProfileMaxSpeed {
+			d = 0
+			p.GPSJumpsSkipped++
+		}
+		cur.distM = prev.distM + d
+	}
+	for i := range p.Markers {
+		if p.Peak == nil || p.Markers[i].dose > p.Peak.dose {
+			p.Peak = &p.Markers[i]
+		}
+	}
+
+	total := p.Markers[len(p.Markers)-1].distM
+	p.TotalKm = total / 1000
+	width := total / float64(points)
+	bins := make([]profileBin, points)
+	sums := make([]float64, points)
+	for _, m := range p.Markers {
+		i := 0
+		if width > 0 {
+			i = min(int(m.distM/width), points-1)
+		}
+		b := &bins[i]
+		b.Samples++
+		sums[i] += m.dose
+		b.MaxUSvH = max(b.MaxUSvH, m.dose)
+	}
+	for i, b := range bins {
+		if b.Samples == 0 {
+			continue
+		}
+		b.DistanceKm = math.Round((float64(i)+0.5)*width) / 1000
+		b.AvgUSvH = math.Round(sums[i]/float64(b.Samples)*10000) / 10000
+		p.Bins = append(p.Bins, b)
+	}
+	return p, nil
+}
+
+func handleTrackProfile(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	trackID, err := req.RequireString("track_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	points := req.GetInt("points", defaultTrackProfilePoints)
+	if points < 10 || points > maxTrackProfilePoints {
+		return mcp.NewToolResultError(fmt.Sprintf("points must be between 10 and %d", maxTrackProfilePoints)), nil
+	}
+
+	p, err := buildTrackProfile(ctx, trackID, points)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	first, last := p.Markers[0], p.Markers[len(p.Markers)-1]
+	peak := p.Peak
+
+	result := map[string]any{
+		"track_id":          trackID,
+		"map_url":           trackMapURL(trackID),
+		"chart_url":         serverURL(fmt.Sprintf("/api/track/%s/profile.svg?points=%d", url.PathEscape(trackID), points)),
+		"total_distance_km": math.Round(p.TotalKm*1000) / 1000,
+		"duration_minutes":  math.Round(last.CapturedAt.Sub(first.CapturedAt).Minutes()*10) / 10,
+		"started_at":        first.CapturedAt.UTC().Format(time.RFC3339),
+		"measurements_used": len(p.Markers),
+		"bin_width_m":       math.Round(p.TotalKm * 1000 / float64(points)),
+		"peak": map[string]any{
+			"marker_id":   peak.ID,
+			"value":       peak.dose,
+			"unit":        "µSv/h",
+			"distance_km": math.Round(peak.distM) / 1000,
+			"captured_at": peak.CapturedAt.UTC().Format(time.RFC3339),
+			"location":    map[string]any{"latitude": peak.lat, "longitude": peak.lon},
+			"map_url":     pointMapURL(peak.lat, peak.lon, 17),
+		},
+		"profile":            p.Bins,
+		"_ai_hint":           "Each profile entry is one distance bin: distance_km is the bin centre measured along the drive from the first marker, avg_usvh and max_usvh are the mean and highest dose rate in µSv/h of the samples in it. Empty bins (gaps, stops without movement) are omitted. Report the peak with its distance, time and map link, and offer chart_url as an image of the profile. Present findings objectively, without personal pronouns or conversational phrases.",
+		"_ai_generated_note": "This data was retrieved by an AI assistant using Safecast tools. The interpretation and presentation of this data may be influenced by the AI system.",
+	}
+	if p.TotalAvailable > p.Fetched {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("The track has %d measurements; the profile covers the first %d returned by get_track.", p.TotalAvailable, p.Fetched)
+	}
+	if p.GPSJumpsSkipped > 0 {
+		result["gps_jumps_skipped"] = p.GPSJumpsSkipped
+	}
+	return jsonResult(result)
+}
+
+// serverURL makes path absolute with MCP_BASE_URL when it is set, so links
+// in tool results work outside the server's own pages.
+func serverURL(path string) string {
+	if base := os.Getenv("MCP_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/") + path
+	}
+	return path
+}
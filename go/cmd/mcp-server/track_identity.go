@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ── Track identity ─────────────────────────────────────────────────────────
+//
+// The database lists uploads (id is the upload row, with filename and
+// recording date) while the simplemap API lists tracks (marker_count,
+// first_id, last_id); both carry the track ID, and a track that was
+// uploaded twice shows up as two uploads. track_key is a content hash that
+// names the same track the same way on either path, so listings that mix
+// sources, or several uploads of one track, can be merged into one entry.
+
+// trackKey returns the canonical key for a track listing entry: a hash of
+// the track ID, or of filename and recording date for uploads that have not
+// been processed into a track yet. It returns "" when neither is present.
+func trackKey(t map[string]any) string {
+	var content string
+	if id := strings.TrimSpace(fmt.Sprint(t["track_id"])); t["track_id"] != nil && id != "" {
+		content = "track:" + id
+	} else if name, ok := t["filename"].(string); ok && name != "" {
+		content = fmt.Sprintf("upload:%s|%v", name, t["recording_date"])
+	} else {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// mergeTracks combines track listings from one or more sources into one
+// list without duplicates. Entries are matched on track_key (computed when
+// missing); the first occurrence keeps its position and its values, and
+// later duplicates only fill fields it lacks. Each entry records the
+// sources it was seen in under "sources", and the upload IDs of all merged
+// database rows under "upload_ids" when there was more than one. It returns
+// the merged list and the number of entries folded into an earlier one.
+func mergeTracks(sources []string, lists ...[]map[string]any) ([]map[string]any, int) {
+	var out []map[string]any
+	byKey := map[string]map[string]any{}
+	merged := 0
+	for li, list := range lists {
+		source := ""
+		if li < len(sources) {
+			source = sources[li]
+		}
+		for _, t := range list {
+			key, _ := t["track_key"].(string)
+			if key == "" {
+				key = trackKey(t)
+				t["track_key"] = key
+			}
+			first, dup := byKey[key]
+			if key == "" || !dup {
+				if source != "" {
+					t["sources"] = []string{source}
+				}
+				if key != "" {
+					byKey[key] = t
+				}
+				out = append(out, t)
+				continue
+			}
+			merged++
+			if ids := append(uploadIDs(first), uploadIDs(t)...); len(ids) > 1 {
+				first["upload_ids"] = ids
+			}
+			for k, v := range t {
+				if cur, ok := first[k]; !ok || cur == nil || cur == "" {
+					first[k] = v
+				}
+			}
+			if source != "" {
+				seen, _ := first["sources"].([]string)
+				if !slices.Contains(seen, source) {
+					first["sources"] = append(seen, source)
+				}
+			}
+		}
+	}
+	return out, merged
+}
+
+// uploadIDs returns the database upload IDs behind a listing entry.
+func uploadIDs(t map[string]any) []any {
+	if ids, ok := t["upload_ids"].([]any); ok {
+		return ids
+	}
+	if id := t["id"]; id != nil {
+		return []any{id}
+	}
+	return nil
+}
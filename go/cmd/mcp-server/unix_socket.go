@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// defaultSocketPath is used for MCP_TRANSPORT=unix when MCP_SOCKET is unset.
+const defaultSocketPath = "/tmp/safecast-mcp.sock"
+
+// listenUnix opens a Unix socket at path. A stale socket left behind by a
+// crashed process is removed first; any other kind of file is left alone so
+// a typo in MCP_SOCKET cannot delete real data. The socket is owner-only
+// unless MCP_SOCKET_MODE (octal, e.g. 0660) says otherwise.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("MCP_SOCKET %s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("MCP_SOCKET %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+
+	mode := os.FileMode(0o600)
+	if v := os.Getenv("MCP_SOCKET_MODE"); v != "" {
+		m, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("MCP_SOCKET_MODE must be octal, got %q", v)
+		}
+		mode = os.FileMode(m)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// serveUntilSignal serves HTTP on ln until SIGINT/SIGTERM, then shuts down
+// so deferred cleanup (removing the socket file) runs.
+func serveUntilSignal(ln net.Listener, h http.Handler) error {
+	srv := &http.Server{Handler: h}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── New uploads ────────────────────────────────────────────────────────────
+//
+// In HTTP mode the server polls the uploads table every
+// UPLOAD_WATCH_INTERVAL for rows above the highest upload ID it has seen
+// and sends them to notification subscriptions following
+// newUploadsSchedule. The first poll only records the current highest ID,
+// so a restart announces nothing old; uploads that arrive while the server
+// is down are not announced either, and monitors catch up with
+// recent_uploads and the since_id cursor instead.
+
+const (
+	newUploadsSchedule         = "new-uploads"
+	defaultUploadWatchInterval = time.Minute
+	uploadWatchBatch           = 100 // uploads per notification
+	uploadWatchTimeout         = 30 * time.Second
+)
+
+var uploadWatch struct {
+	sync.Mutex
+	lastID int64
+}
+
+// recentUploadsSQL lists uploads after a cursor, oldest first.
+const recentUploadsSQL = `SELECT u.id, u.filename, u.track_id, u.detector, u.file_size,
+			u.recording_date, u.created_at,
+			COALESCE(NULLIF(usr.username, ''), u.username) AS username
+		FROM uploads u
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+		WHERE u.id > $1
+		ORDER BY u.id
+		LIMIT $2`
+
+// latestUploadsSQL lists the newest uploads, newest first.
+const latestUploadsSQL = `SELECT u.id, u.filename, u.track_id, u.detector, u.file_size,
+			u.recording_date, u.created_at,
+			COALESCE(NULLIF(usr.username, ''), u.username) AS username
+		FROM uploads u
+		LEFT JOIN users usr ON u.internal_user_id = usr.id::text
+		ORDER BY u.id DESC
+		LIMIT $1`
+
+// recentUploads returns up to limit uploads with an ID above sinceID, oldest
+// first. With sinceID 0 it returns the newest limit uploads, still oldest
+// first, so the last one is always the next cursor.
+func recentUploads(ctx context.Context, sinceID int64, limit int) ([]map[string]any, error) {
+	var rows []map[string]any
+	var err error
+	if sinceID > 0 {
+		rows, err = queryRows(ctx, recentUploadsSQL, sinceID, limit)
+	} else {
+		rows, err = queryRows(ctx, latestUploadsSQL, limit)
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := make([]map[string]any, len(rows))
+	for i, r := range rows {
+		u := map[string]any{
+			"id":             r["id"],
+			"filename":       r["filename"],
+			"track_id":       r["track_id"],
+			"detector":       r["detector"],
+			"file_size":      r["file_size"],
+			"username":       r["username"],
+			"recording_date": r["recording_date"],
+			"created_at":     r["created_at"],
+			"processed":      false,
+		}
+		if trackID, ok := r["track_id"].(string); ok && trackID != "" {
+			u["processed"] = true
+			u["map_url"] = trackMapURL(trackID)
+			u["thumbnail_url"] = trackThumbURL(trackID)
+		}
+		uploads[i] = u
+	}
+	return uploads, nil
+}
+
+// uploadID reads the id of a recentUploads entry.
+func uploadID(u map[string]any) int64 {
+	v, _ := toFloat(u["id"])
+	return int64(v)
+}
+
+// startUploadWatcher polls for new uploads while Postgres is connected and
+// subscriptions can be stored.
+func startUploadWatcher() {
+	interval := durationEnv("UPLOAD_WATCH_INTERVAL", defaultUploadWatchInterval)
+	if !dbAvailable() || duckDB == nil || interval <= 0 {
+		return
+	}
+	log.Printf("Upload watcher started (every %s)", interval)
+	go func() {
+		checkNewUploads()
+		for range time.Tick(interval) {
+			checkNewUploads()
+		}
+	}()
+}
+
+func checkNewUploads() {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadWatchTimeout)
+	defer cancel()
+	uploadWatch.Lock()
+	defer uploadWatch.Unlock()
+
+	if uploadWatch.lastID == 0 {
+		row, err := queryRow(ctx, `SELECT COALESCE(MAX(id), 0) AS max_id FROM uploads`)
+		if err != nil {
+			log.Printf("Upload watcher: %v", err)
+			return
+		}
+		maxID, _ := toFloat(row["max_id"])
+		uploadWatch.lastID = int64(maxID)
+		return
+	}
+
+	for {
+		uploads, err := recentUploads(ctx, uploadWatch.lastID, uploadWatchBatch)
+		if err != nil {
+			log.Printf("Upload watcher: %v", err)
+			return
+		}
+		if len(uploads) == 0 {
+			return
+		}
+		uploadWatch.lastID = uploadID(uploads[len(uploads)-1])
+		n := newUploadsNotification(uploads, uploadWatch.lastID)
+		log.Printf("%s (up to upload %d)", n.Subject, uploadWatch.lastID)
+		go notifySubscribers(context.Background(), newUploadsSchedule, n)
+		if len(uploads) < uploadWatchBatch {
+			return
+		}
+	}
+}
+
+// newUploadsNotification lists uploads for people and, in Data, for
+// webhook receivers.
+func newUploadsNotification(uploads []map[string]any, nextSinceID int64) notification {
+	subject := fmt.Sprintf("%d new Safecast uploads", len(uploads))
+	if len(uploads) == 1 {
+		subject = "1 new Safecast upload"
+	}
+	var md strings.Builder
+	for _, u := range uploads {
+		fmt.Fprintf(&md, "- %v", u["filename"])
+		if d, ok := u["detector"].(string); ok && d != "" {
+			fmt.Fprintf(&md, " (%s)", d)
+		}
+		if name, ok := u["username"].(string); ok && name != "" {
+			fmt.Fprintf(&md, " by %s", name)
+		}
+		if link, ok := u["map_url"].(string); ok {
+			fmt.Fprintf(&md, ": %s", link)
+		} else {
+			md.WriteString(": not processed into a track yet")
+		}
+		md.WriteByte('\n')
+	}
+	return notification{
+		Event:    newUploadsSchedule,
+		Subject:  subject,
+		Markdown: md.String(),
+		Data: map[string]any{
+			"count":         len(uploads),
+			"uploads":       uploads,
+			"next_since_id": nextSinceID,
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// ── Page assets ────────────────────────────────────────────────────────────
+//
+// The page, logo and theme are embedded so the binary runs on its own.
+// WEB_CHAT_TEMPLATES names a directory whose files take precedence over the
+// embedded ones, file by file, so a deployment can rebrand the chat without
+// recompiling: drop in index.html, safecast-square-ct.png, or just a
+// theme.css that overrides the colour variables (see theme.css). Files are
+// read per request, so edits show up on the next page load. Extra files in
+// the directory (other logos, fonts) are served under their own names.
+
+//go:embed index.html safecast-square-ct.png theme.css
+var embeddedAssets embed.FS
+
+// overlayFS opens files from dir when present there, from base otherwise.
+type overlayFS struct {
+	dir  fs.FS // nil without WEB_CHAT_TEMPLATES
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.dir != nil {
+		f, err := o.dir.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return o.base.Open(name)
+}
+
+// newAssets returns the asset file system for a templates directory ("" for
+// the embedded assets only).
+func newAssets(dir string) fs.FS {
+	assets := overlayFS{base: embeddedAssets}
+	if dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			log.Printf("WEB_CHAT_TEMPLATES %q is not a directory; using embedded assets", dir)
+		} else {
+			assets.dir = os.DirFS(dir)
+		}
+	}
+	return assets
+}
+
+// serveAssets serves a file of assets by URL path and the page for "/" and
+// any path that names no file.
+func serveAssets(assets fs.FS) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if name == "" || !fs.ValidPath(name) || !isAssetFile(assets, name) {
+			name = "index.html"
+		}
+		data, err := fs.ReadFile(assets, name)
+		if err != nil {
+			http.Error(w, "asset unavailable", http.StatusInternalServerError)
+			return
+		}
+		if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		if name != "index.html" {
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+		}
+		w.Write(data)
+	}
+}
+
+func isAssetFile(assets fs.FS, name string) bool {
+	info, err := fs.Stat(assets, name)
+	return err == nil && !info.IsDir()
+}
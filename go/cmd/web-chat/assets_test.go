@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeAssets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "theme.css"), []byte(":root { --green: #c00; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "fonts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	embeddedIndex, _ := embeddedAssets.ReadFile("index.html")
+	embeddedLogo, _ := embeddedAssets.ReadFile("safecast-square-ct.png")
+
+	tests := []struct {
+		name  string
+		dir   string
+		path  string
+		body  []byte
+		ctype string
+	}{
+		{"page", "", "/", embeddedIndex, "text/html"},
+		{"unknown path gets the page", "", "/some/route", embeddedIndex, "text/html"},
+		{"embedded logo", "", "/safecast-square-ct.png", embeddedLogo, "image/png"},
+		{"override", dir, "/theme.css", []byte(":root { --green: #c00; }"), "text/css"},
+		{"fallback to embedded", dir, "/safecast-square-ct.png", embeddedLogo, "image/png"},
+		{"directory is not served", dir, "/fonts", embeddedIndex, "text/html"},
+		{"no escape from the directory", dir, "/../main.go", embeddedIndex, "text/html"},
+		{"missing directory", filepath.Join(dir, "nope"), "/", embeddedIndex, "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			serveAssets(newAssets(tt.dir))(rec, httptest.NewRequest("GET", tt.path, nil))
+			if !bytes.Equal(rec.Body.Bytes(), tt.body) {
+				t.Errorf("GET %s: got %d bytes, want %d", tt.path, rec.Body.Len(), len(tt.body))
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.ctype) {
+				t.Errorf("GET %s: Content-Type %q, want %s", tt.path, ct, tt.ctype)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ── Home location ("my area") ──────────────────────────────────────────────
+//
+// A user can pin a home location with "/home <lat>,<lon> [radius] [label]"
+// (or the 📍 button, which asks the browser for its position). The server
+// keeps nothing: the page stores the location in localStorage and sends it
+// with every chat request, "/forget" removes it there. Questions that say
+// "near me" or "my area" get a note with the coordinates appended before
+// they reach the model; all other questions are sent without them, so the
+// location only leaves the browser when it is needed for the answer.
+// Coordinates are rounded to three decimals (about 100 m) when pinned.
+
+const (
+	defaultHomeRadiusM = 5000
+	minHomeRadiusM     = 500
+	maxHomeRadiusM     = 100000
+	maxHomeLabelLen    = 60
+)
+
+const homeUsage = "usage: /home <lat>,<lon> [radius, e.g. 5km] [label], /home to show the pinned area, /forget to remove it"
+
+// homeLocation is the pinned "my area" of a chat user.
+type homeLocation struct {
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	RadiusM int     `json:"radius_m"`
+	Label   string  `json:"label,omitempty"`
+}
+
+// nearMePatterns match questions about the user's own area, in English and
+// Japanese.
+var nearMePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(near|around|close to) (me|here|us)\b`),
+	regexp.MustCompile(`(?i)\bmy (area|neighbou?rhood|town|city|village|home|house|place|location|region)\b`),
+	regexp.MustCompile(`(?i)\b(where i live|near (my|our) (home|house|place))\b`),
+	regexp.MustCompile(`(私|わたし|自分|家|うち)の(近く|周り|周辺|地域|近所)|自宅|近所|この辺`),
+}
+
+var (
+	homeCoordsRe = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*[, ]\s*(-?\d+(?:\.\d+)?)(?:\s+|$)`)
+	homeRadiusRe = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(km|m)(?:\s+|$)`)
+)
+
+// validate checks the coordinates and radius, filling in the default radius.
+func (h *homeLocation) validate() error {
+	if h.Lat < -90 || h.Lat > 90 || h.Lon < -180 || h.Lon > 180 || math.IsNaN(h.Lat) || math.IsNaN(h.Lon) {
+		return fmt.Errorf("home location %g,%g is out of range", h.Lat, h.Lon)
+	}
+	if h.RadiusM == 0 {
+		h.RadiusM = defaultHomeRadiusM
+	}
+	if h.RadiusM < minHomeRadiusM || h.RadiusM > maxHomeRadiusM {
+		return fmt.Errorf("home radius must be between %d m and %d km", minHomeRadiusM, maxHomeRadiusM/1000)
+	}
+	if len([]rune(h.Label)) > maxHomeLabelLen {
+		h.Label = string([]rune(h.Label)[:maxHomeLabelLen])
+	}
+	return nil
+}
+
+// String is the location as the model and the user see it.
+func (h *homeLocation) String() string {
+	ns, ew := "N", "E"
+	if h.Lat < 0 {
+		ns = "S"
+	}
+	if h.Lon < 0 {
+		ew = "W"
+	}
+	s := fmt.Sprintf("%.3f°%s, %.3f°%s, radius %s", math.Abs(h.Lat), ns, math.Abs(h.Lon), ew, formatRadius(h.RadiusM))
+	if h.Label != "" {
+		s = h.Label + " (" + s + ")"
+	}
+	return s
+}
+
+func formatRadius(m int) string {
+	if m >= 1000 {
+		return strconv.FormatFloat(float64(m)/1000, 'f', -1, 64) + " km"
+	}
+	return strconv.Itoa(m) + " m"
+}
+
+// parseHomeCommand recognises the /home and /forget commands. ok is false
+// for any other message. For /forget and for /home without arguments home
+// is nil; the caller answers with the current location or its removal.
+func parseHomeCommand(message string) (home *homeLocation, forget, ok bool, err error) {
+	msg := strings.TrimSpace(message)
+	cmd, rest, _ := strings.Cut(msg, " ")
+	switch strings.ToLower(cmd) {
+	case "/forget", "/forget-home":
+		return nil, true, true, nil
+	case "/home":
+	default:
+		return nil, false, false, nil
+	}
+	rest = strings.TrimSpace(rest)
+	switch strings.ToLower(rest) {
+	case "":
+		return nil, false, true, nil
+	case "forget", "clear", "off":
+		return nil, true, true, nil
+	}
+
+	m := homeCoordsRe.FindStringSubmatch(rest)
+	if m == nil {
+		return nil, false, true, errors.New(homeUsage)
+	}
+	h := &homeLocation{}
+	h.Lat, _ = strconv.ParseFloat(m[1], 64)
+	h.Lon, _ = strconv.ParseFloat(m[2], 64)
+	rest = rest[len(m[0]):]
+	if r := homeRadiusRe.FindStringSubmatch(rest); r != nil {
+		v, _ := strconv.ParseFloat(r[1], 64)
+		if strings.EqualFold(r[2], "km") {
+			v *= 1000
+		}
+		h.RadiusM = int(math.Round(v))
+		rest = rest[len(r[0]):]
+	}
+	h.Label = strings.TrimSpace(rest)
+	h.Lat = math.Round(h.Lat*1000) / 1000
+	h.Lon = math.Round(h.Lon*1000) / 1000
+	if err := h.validate(); err != nil {
+		return nil, false, true, err
+	}
+	return h, false, true, nil
+}
+
+// mentionsOwnArea reports whether a question is about the user's own area.
+func mentionsOwnArea(message string) bool {
+	for _, re := range nearMePatterns {
+		if re.MatchString(message) {
+			return true
+		}
+	}
+	return false
+}
+
+// withHomeContext appends the pinned location to a question about the
+// user's own area, or asks the model to request a place when none is
+// pinned. Other questions are returned unchanged.
+func withHomeContext(message string, home *homeLocation) string {
+	if !mentionsOwnArea(message) {
+		return message
+	}
+	if home == nil {
+		return message + "\n\n[No home location is pinned. Ask for a place name or coordinates, and mention that a home area can be pinned with /home <lat>,<lon>.]"
+	}
+	return message + fmt.Sprintf("\n\n[Pinned home location of this user: %s. For \"near me\" / \"my area\", use lat=%g, lon=%g and radius=%d m unless the question says otherwise.]",
+		home, home.Lat, home.Lon, home.RadiusM)
+}
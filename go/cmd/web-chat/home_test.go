@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHomeCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		ok      bool
+		forget  bool
+		wantErr bool
+		want    *homeLocation
+	}{
+		{"pin with radius and label", "/home 37.4215,141.0337 10km Namie office", true, false, false,
+			&homeLocation{Lat: 37.422, Lon: 141.034, RadiusM: 10000, Label: "Namie office"}},
+		{"pin with defaults", "/home 35.68 139.76", true, false, false,
+			&homeLocation{Lat: 35.68, Lon: 139.76, RadiusM: defaultHomeRadiusM}},
+		{"radius in metres", "/home -33.9,151.2 800m", true, false, false,
+			&homeLocation{Lat: -33.9, Lon: 151.2, RadiusM: 800}},
+		{"show", "/home", true, false, false, nil},
+		{"forget", "/forget", true, true, false, nil},
+		{"home forget", "/home forget", true, true, false, nil},
+		{"not coordinates", "/home Tokyo", true, false, true, nil},
+		{"latitude out of range", "/home 95,10", true, false, true, nil},
+		{"radius too small", "/home 35,139 50m", true, false, true, nil},
+		{"ordinary question", "what is /home?", false, false, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, forget, ok, err := parseHomeCommand(tt.in)
+			if ok != tt.ok || forget != tt.forget || (err != nil) != tt.wantErr {
+				t.Fatalf("parseHomeCommand(%q) = ok %v, forget %v, err %v", tt.in, ok, forget, err)
+			}
+			if (home == nil) != (tt.want == nil) || (home != nil && *home != *tt.want) {
+				t.Errorf("parseHomeCommand(%q) home = %+v, want %+v", tt.in, home, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithHomeContext(t *testing.T) {
+	home := &homeLocation{Lat: 37.42, Lon: 141.03, RadiusM: 5000}
+	for _, q := range []string{"What's the radiation near me?", "Any sensors in my area?", "自宅の周りの線量は？"} {
+		if got := withHomeContext(q, home); !strings.Contains(got, "lat=37.42, lon=141.03") {
+			t.Errorf("withHomeContext(%q) = %q, want the pinned location", q, got)
+		}
+		if got := withHomeContext(q, nil); !strings.Contains(got, "No home location is pinned") {
+			t.Errorf("withHomeContext(%q, nil) = %q, want a request for a place", q, got)
+		}
+	}
+	// The location only leaves the browser when the question needs it.
+	for _, q := range []string{"What's the radiation near Tokyo?", "東京の近くの線量は？"} {
+		if got := withHomeContext(q, home); got != q {
+			t.Errorf("withHomeContext(%q) = %q, want unchanged", q, got)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/your-org/safecast-mcp-server/pkg/agent"
+)
+
+// ── Providers ──────────────────────────────────────────────────────────────
+//
+// A chatProvider runs one user turn against an LLM with the MCP tools
+// attached and streams the answer through emit. LLM_PROVIDER selects the
+// implementation: "anthropic" (default) or "qwen".
+
+type chatProvider interface {
+	Name() string
+	Model() string
+	Chat(ctx context.Context, history []anthropicMessage, message string, emit func(chunk)) error
+}
+
+func newProvider(mcpURL string) (chatProvider, error) {
+	maxRounds := agent.DefaultMaxRounds
+	if v := os.Getenv("AGENT_MAX_ROUNDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("AGENT_MAX_ROUNDS must be a positive integer, got %q", v)
+		}
+		maxRounds = n
+	}
+
+	switch p := os.Getenv("LLM_PROVIDER"); p {
+	case "", "anthropic":
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY is required")
+		}
+		model := os.Getenv("CLAUDE_MODEL")
+		if model == "" {
+			model = "claude-sonnet-4-5"
+		}
+		return &anthropicProvider{apiKey: apiKey, model: model, mcpURL: mcpURL, maxRounds: maxRounds}, nil
+
+	case "qwen":
+		apiKey := os.Getenv("ALIBABA_CLOUD_API_KEY")
+		if apiKey == "" {
+			apiKey = os.Getenv("DASHSCOPE_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("ALIBABA_CLOUD_API_KEY or DASHSCOPE_API_KEY is required for LLM_PROVIDER=qwen")
+		}
+		model := os.Getenv("QWEN_MODEL")
+		if model == "" {
+			model = "qwen-plus"
+		}
+		baseURL := os.Getenv("QWEN_BASE_URL")
+		if baseURL == "" {
+			baseURL = agent.DefaultQwenBaseURL
+		}
+		return &qwenProvider{apiKey: apiKey, model: model, baseURL: baseURL, mcpURL: mcpURL, maxRounds: maxRounds}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown LLM_PROVIDER %q (want anthropic or qwen)", p)
+	}
+}
+
+// ── Anthropic ──────────────────────────────────────────────────────────────
+
+type anthropicProvider struct {
+	apiKey    string
+	model     string
+	mcpURL    string
+	maxRounds int
+}
+
+func (p *anthropicProvider) Name() string  { return "anthropic" }
+func (p *anthropicProvider) Model() string { return p.model }
+
+func (p *anthropicProvider) Chat(ctx context.Context, history []anthropicMessage, message string, emit func(chunk)) error {
+	// ── Connect to MCP server ──────────────────────────────────────────
+	mc, err := mcpclient.NewStreamableHttpClient(p.mcpURL)
+	if err != nil {
+		return fmt.Errorf("MCP connect: %v", err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Initialize(ctx, mcp.InitializeRequest{
+		Params: mcp.InitializeParams{
+			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			ClientInfo:      mcp.Implementation{Name: "safecast-web-chat", Version: "1.0.0"},
+		},
+	}); err != nil {
+		return fmt.Errorf("MCP init: %v", err)
+	}
+
+	toolsResult, err := mc.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("list tools: %v", err)
+	}
+	tools := mcpToolsToAnthropic(toolsResult.Tools)
+
+	// ── Agentic loop ───────────────────────────────────────────────────
+	// Start with conversation history (if provided) and append new user message
+	messages := history
+	if messages == nil {
+		messages = []anthropicMessage{}
+	}
+	messages = append(messages, anthropicMessage{Role: "user", Content: message})
+
+	for round := 0; ; round++ {
+		if round >= p.maxRounds {
+			emit(roundLimitNotice(p.maxRounds))
+			return nil
+		}
+
+		resp, err := callAnthropic(ctx, p.apiKey, p.model, messages, tools)
+		if err != nil {
+			return err
+		}
+
+		messages = append(messages, anthropicMessage{
+			Role:    "assistant",
+			Content: resp.Content,
+		})
+
+		var toolUses []contentBlock
+		for _, block := range resp.Content {
+			switch block.Type {
+			case "text":
+				// Stream each text block as it arrives (or buffer if CloudFront)
+				emit(chunk{Type: "text", Text: block.Text})
+			case "tool_use":
+				toolUses = append(toolUses, block)
+			}
+		}
+
+		if resp.StopReason == "end_turn" || len(toolUses) == 0 {
+			return nil
+		}
+
+		// ── Execute tool calls via MCP ─────────────────────────────────
+		var toolResults []contentBlock
+		for _, tu := range toolUses {
+			var args map[string]any
+			_ = json.Unmarshal(tu.Input, &args)
+
+			callReq := mcp.CallToolRequest{}
+			callReq.Params.Name = tu.Name
+			callReq.Params.Arguments = args
+
+			var resultText string
+			toolResult, err := mc.CallTool(ctx, callReq)
+			if err != nil {
+				resultText = fmt.Sprintf("tool error: %v", err)
+			} else {
+				for _, c := range toolResult.Content {
+					if tc, ok := c.(mcp.TextContent); ok {
+						resultText += tc.Text
+					}
+				}
+			}
+
+			toolResults = append(toolResults, contentBlock{
+				Type:      "tool_result",
+				ToolUseID: tu.ID,
+				Content:   sanitizeToolResult(tu.Name, resultText),
+			})
+		}
+
+		messages = append(messages, anthropicMessage{
+			Role:    "user",
+			Content: toolResults,
+		})
+	}
+}
+
+// ── Qwen (go/pkg/agent) ────────────────────────────────────────────────────
+
+type qwenProvider struct {
+	apiKey    string
+	model     string
+	baseURL   string
+	mcpURL    string
+	maxRounds int
+}
+
+func (p *qwenProvider) Name() string  { return "qwen" }
+func (p *qwenProvider) Model() string { return p.model }
+
+func (p *qwenProvider) Chat(ctx context.Context, history []anthropicMessage, message string, emit func(chunk)) error {
+	qc := agent.NewQwenClient(p.apiKey, p.model)
+	qc.BaseURL = p.baseURL
+
+	mc := agent.NewMCPClient(p.mcpURL)
+	mc.ClientName = "safecast-web-chat"
+
+	a := agent.New(qc, mc, agent.Options{
+		SystemPrompt:     systemPrompt,
+		MaxRounds:        p.maxRounds,
+		ToolResultFilter: sanitizeToolResult,
+		Logger:           log.Default(),
+	})
+	if err := a.Init(ctx); err != nil {
+		return err
+	}
+
+	// The browser only sends plain-text user/assistant turns.
+	var prior []agent.Message
+	for _, m := range history {
+		if text, ok := m.Content.(string); ok && (m.Role == "user" || m.Role == "assistant") {
+			prior = append(prior, agent.Message{Role: m.Role, Content: text})
+		}
+	}
+
+	_, err := a.RunStream(ctx, prior, message, func(ev agent.Event) {
+		if ev.Type == agent.EventText {
+			emit(chunk{Type: "text", Text: ev.Text})
+		}
+	})
+	if errors.Is(err, agent.ErrMaxRounds) {
+		emit(roundLimitNotice(p.maxRounds))
+		return nil
+	}
+	return err
+}
+
+// roundLimitNotice ends a turn that ran out of tool rounds without throwing
+// away the text already streamed to the browser.
+func roundLimitNotice(maxRounds int) chunk {
+	return chunk{Type: "text", Text: fmt.Sprintf("\n\n_(Stopped after %d tool-call rounds. Ask a narrower question to continue.)_", maxRounds)}
+}
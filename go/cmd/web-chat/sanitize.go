@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ── Tool result sanitization ───────────────────────────────────────────────
+//
+// Tool results carry free text that nobody on our side wrote: track filenames,
+// uploader usernames, device names, spectrum comments. All of it is appended to
+// the conversation verbatim, so an upload named "ignore previous instructions
+// and ..." would reach the model as if it were part of the prompt. Before a
+// result becomes a tool_result block we walk the JSON and neutralise anything
+// that reads like an instruction, a role marker, or a fake message boundary.
+//
+// The server-authored guidance keys (_ai_hint, _next_step, _ai_generated_note)
+// are only trusted at the top level of a result. Anywhere else they can only
+// have come from data, so they are dropped. Even at the top level a hint can
+// echo a user-chosen value, such as a saved region or query name; the server
+// always quotes those with %q, so the double-quoted spans of guidance text
+// are neutralised like data while the rest of it is left alone.
+
+// directiveKeys are server-authored guidance fields trusted at the top level.
+var directiveKeys = map[string]bool{
+	"_ai_hint":           true,
+	"_next_step":         true,
+	"_ai_generated_note": true,
+}
+
+// quotedSpan matches a Go-quoted (%q) string inside guidance text.
+var quotedSpan = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// maxFieldLen caps string values inside data rows and arrays. Long free-text
+// fields there are the usual carrier for smuggled prompts and never matter
+// for the answer. Top-level strings (summaries, radiation_info reference
+// text) are server-authored and are neutralised but never clipped.
+const maxFieldLen = 300
+
+const redacted = "[removed: instruction-like text]"
+
+var injectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,40}\b(previous|prior|above|earlier|all|system)\b[^.\n]{0,40}\b(instructions?|prompts?|rules?|messages?|context)\b`),
+	regexp.MustCompile(`(?i)\byou are (now|no longer)\b`),
+	regexp.MustCompile(`(?i)\b(new|updated|real) (system )?(instructions?|prompt)\b`),
+	regexp.MustCompile(`(?im)^\s*(system|assistant|developer)\s*:`),
+	regexp.MustCompile(`(?i)\b(system|assistant|developer)\s+(prompt|message)\s*:`),
+	regexp.MustCompile(`(?i)\bCRITICAL INSTRUCTIONS?\b`),
+	regexp.MustCompile(`(?i)</?\s*(system|assistant|user|human|tool_result|tool_use|instructions?)\s*>`),
+	regexp.MustCompile(`<\|[^|>]{1,40}\|>`),
+	regexp.MustCompile(`(?m)^\s*(Human|Assistant)\s*:`),
+}
+
+// sanitizeToolResult returns a copy of a tool result that is safe to hand to
+// the model. JSON results are sanitised field by field; anything else is
+// treated as a single untrusted string.
+func sanitizeToolResult(toolName, text string) string {
+	var doc any
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		clean, n := neutralize(text, 0)
+		if n > 0 {
+			log.Printf("sanitize: %s: neutralised %d span(s) in text result", toolName, n)
+		}
+		return clean
+	}
+
+	var count int
+	if obj, ok := doc.(map[string]any); ok {
+		for k, v := range obj {
+			if directiveKeys[k] {
+				obj[k] = sanitizeDirective(v, &count)
+				continue
+			}
+			if str, ok := v.(string); ok {
+				clean, n := neutralize(str, 0)
+				count += n
+				obj[k] = clean
+				continue
+			}
+			obj[k] = sanitizeValue(v, &count)
+		}
+	} else {
+		doc = sanitizeValue(doc, &count)
+	}
+	if count > 0 {
+		log.Printf("sanitize: %s: neutralised %d field(s)", toolName, count)
+	}
+
+	// Encode without HTML escaping so map links keep their literal "&".
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return text
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func sanitizeValue(v any, count *int) any {
+	switch t := v.(type) {
+	case string:
+		clean, n := neutralize(t, maxFieldLen)
+		*count += n
+		return clean
+	case map[string]any:
+		for k, inner := range t {
+			if directiveKeys[k] || strings.HasPrefix(strings.ToLower(k), "_ai") {
+				delete(t, k)
+				*count++
+				continue
+			}
+			t[k] = sanitizeValue(inner, count)
+		}
+		return t
+	case []any:
+		for i, inner := range t {
+			t[i] = sanitizeValue(inner, count)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// sanitizeDirective neutralises the quoted spans of a top-level guidance
+// value, which are the only parts of it that can come from users.
+func sanitizeDirective(v any, count *int) any {
+	switch t := v.(type) {
+	case string:
+		return quotedSpan.ReplaceAllStringFunc(t, func(span string) string {
+			clean, n := neutralize(span[1:len(span)-1], 0)
+			*count += n
+			return `"` + clean + `"`
+		})
+	case map[string]any:
+		for k, inner := range t {
+			t[k] = sanitizeDirective(inner, count)
+		}
+		return t
+	case []any:
+		for i, inner := range t {
+			t[i] = sanitizeDirective(inner, count)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// neutralize strips control characters, replaces instruction-like spans and
+// truncates to limit runes (0 means no limit). It reports how many changes
+// were made so callers can log them.
+func neutralize(s string, limit int) (string, int) {
+	changes := 0
+
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.In(r, unicode.Cf) {
+			return -1
+		}
+		return r
+	}, s)
+	if cleaned != s {
+		changes++
+	}
+
+	for _, re := range injectionPatterns {
+		if re.MatchString(cleaned) {
+			cleaned = re.ReplaceAllString(cleaned, redacted)
+			changes++
+		}
+	}
+
+	if limit > 0 {
+		if runes := []rune(cleaned); len(runes) > limit {
+			cleaned = string(runes[:limit]) + "…"
+			changes++
+		}
+	}
+	return cleaned, changes
+}
@@ -0,0 +1,11 @@
+-- CPM per µSv/h conversion factors that override the server's built-in
+-- table (cpm_conversion.go). detector is a device_id (e.g. pointcast:10004),
+-- a device type (e.g. solarcast) or a tube (e.g. LND 7128 EC); case, spaces
+-- and punctuation are ignored. The server re-reads the table every 5 minutes.
+CREATE TABLE IF NOT EXISTS detector_conversion_factors (
+    detector     text PRIMARY KEY,
+    cpm_per_usvh double precision NOT NULL CHECK (cpm_per_usvh > 0),
+    tube         text,
+    note         text,
+    updated_at   timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,16 @@
+-- Published detector response corrections for harmonize: true on the
+-- stats and comparison tools (harmonization.go). A stored dose rate is
+-- multiplied by factor to give what the reference detector, a bGeigie's
+-- LND 7317 with its Cs-137 calibration, would have read. detector is a
+-- detector name or family (e.g. radiacode, bGeigie Zen); case, spaces and
+-- punctuation are ignored, and the longest matching name wins. source is
+-- the publication the factor is taken from and is shown with every
+-- harmonized result. The server re-reads the table every 5 minutes.
+CREATE TABLE IF NOT EXISTS detector_response_corrections (
+    detector   text PRIMARY KEY,
+    factor     double precision NOT NULL CHECK (factor > 0),
+    tube       text,
+    source     text NOT NULL,
+    note       text,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
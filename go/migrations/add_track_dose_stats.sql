@@ -0,0 +1,64 @@
+-- Per-track dose statistics for list_tracks' min_max_dose_usvh filter.
+--
+-- Listing the drives whose highest reading exceeded a dose rate needs the
+-- maximum of every track's markers. This table keeps it per track, with
+-- the average and the number of readings, and statement-level triggers on
+-- markers refresh the tracks touched by each ingest or delete, as for
+-- track_geometries (add_track_geometries.sql). The MCP server uses the
+-- table when it exists and takes the maximum from markers on each call
+-- otherwise.
+
+CREATE TABLE IF NOT EXISTS track_dose_stats (
+    track_id      TEXT PRIMARY KEY,
+    max_dose_usvh DOUBLE PRECISION NOT NULL,
+    avg_dose_usvh DOUBLE PRECISION NOT NULL,
+    marker_count  INTEGER NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_track_dose_stats_max ON track_dose_stats (max_dose_usvh DESC);
+
+-- Recompute the cached rows for the given tracks from markers; tracks
+-- without dose readings are dropped.
+CREATE OR REPLACE FUNCTION refresh_track_dose_stats(ids TEXT[]) RETURNS void
+LANGUAGE sql AS $$
+    DELETE FROM track_dose_stats t
+    WHERE t.track_id = ANY(ids)
+      AND NOT EXISTS (SELECT 1 FROM markers m WHERE m.trackid = t.track_id AND m.doserate IS NOT NULL);
+
+    INSERT INTO track_dose_stats (track_id, max_dose_usvh, avg_dose_usvh, marker_count, updated_at)
+    SELECT trackid, MAX(doserate), AVG(doserate), COUNT(*), now()
+    FROM markers
+    WHERE trackid = ANY(ids) AND doserate IS NOT NULL
+    GROUP BY trackid
+    ON CONFLICT (track_id) DO UPDATE SET
+        max_dose_usvh = EXCLUDED.max_dose_usvh,
+        avg_dose_usvh = EXCLUDED.avg_dose_usvh,
+        marker_count = EXCLUDED.marker_count,
+        updated_at = EXCLUDED.updated_at;
+$$;
+
+CREATE OR REPLACE FUNCTION markers_refresh_track_dose_stats() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM refresh_track_dose_stats(ARRAY(SELECT DISTINCT trackid FROM changed_markers WHERE trackid IS NOT NULL));
+    RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_markers_track_dose_stats ON markers;
+CREATE TRIGGER trg_markers_track_dose_stats
+    AFTER INSERT ON markers
+    REFERENCING NEW TABLE AS changed_markers
+    FOR EACH STATEMENT EXECUTE FUNCTION markers_refresh_track_dose_stats();
+
+DROP TRIGGER IF EXISTS trg_markers_track_dose_stats_delete ON markers;
+CREATE TRIGGER trg_markers_track_dose_stats_delete
+    AFTER DELETE ON markers
+    REFERENCING OLD TABLE AS changed_markers
+    FOR EACH STATEMENT EXECUTE FUNCTION markers_refresh_track_dose_stats();
+
+-- Backfill once after creating the table (takes a while on the full
+-- markers table; safe to re-run):
+--
+--   SELECT refresh_track_dose_stats(ARRAY(SELECT DISTINCT track_id FROM uploads WHERE track_id IS NOT NULL));
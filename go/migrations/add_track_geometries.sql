@@ -0,0 +1,73 @@
+-- Per-track geometry cache for track searches by area.
+--
+-- search_tracks_by_location used to collect every marker of every upload
+-- (ST_Collect in a LATERAL join) on each call. This table keeps the result
+-- per track, with its bounding box and centroid, and statement-level
+-- triggers on markers refresh the tracks touched by each ingest or delete.
+-- The MCP server uses the table when it exists and falls back to the
+-- LATERAL join otherwise.
+
+CREATE TABLE IF NOT EXISTS track_geometries (
+    track_id     TEXT PRIMARY KEY,
+    geom         geometry(Geometry, 4326) NOT NULL,
+    bbox         geometry(Geometry, 4326) NOT NULL,
+    centroid     geometry(Point, 4326) NOT NULL,
+    marker_count INTEGER NOT NULL,
+    first_date   BIGINT,
+    last_date    BIGINT,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_track_geometries_geom ON track_geometries USING GIST (geom);
+CREATE INDEX IF NOT EXISTS idx_track_geometries_last_date ON track_geometries (last_date DESC);
+
+-- Recompute the cached rows for the given tracks from markers; tracks
+-- without markers are dropped.
+CREATE OR REPLACE FUNCTION refresh_track_geometries(ids TEXT[]) RETURNS void
+LANGUAGE sql AS $$
+    DELETE FROM track_geometries t
+    WHERE t.track_id = ANY(ids)
+      AND NOT EXISTS (SELECT 1 FROM markers m WHERE m.trackid = t.track_id);
+
+    INSERT INTO track_geometries (track_id, geom, bbox, centroid, marker_count, first_date, last_date, updated_at)
+    SELECT trackid, g.geom, ST_Envelope(g.geom), ST_Centroid(g.geom), g.n, g.first_date, g.last_date, now()
+    FROM (
+        SELECT trackid, ST_Collect(geom) AS geom, COUNT(*) AS n, MIN(date) AS first_date, MAX(date) AS last_date
+        FROM markers
+        WHERE trackid = ANY(ids) AND geom IS NOT NULL
+        GROUP BY trackid
+    ) g
+    ON CONFLICT (track_id) DO UPDATE SET
+        geom = EXCLUDED.geom,
+        bbox = EXCLUDED.bbox,
+        centroid = EXCLUDED.centroid,
+        marker_count = EXCLUDED.marker_count,
+        first_date = EXCLUDED.first_date,
+        last_date = EXCLUDED.last_date,
+        updated_at = EXCLUDED.updated_at;
+$$;
+
+CREATE OR REPLACE FUNCTION markers_refresh_track_geometries() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM refresh_track_geometries(ARRAY(SELECT DISTINCT trackid FROM changed_markers WHERE trackid IS NOT NULL));
+    RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_markers_track_geometries ON markers;
+CREATE TRIGGER trg_markers_track_geometries
+    AFTER INSERT ON markers
+    REFERENCING NEW TABLE AS changed_markers
+    FOR EACH STATEMENT EXECUTE FUNCTION markers_refresh_track_geometries();
+
+DROP TRIGGER IF EXISTS trg_markers_track_geometries_delete ON markers;
+CREATE TRIGGER trg_markers_track_geometries_delete
+    AFTER DELETE ON markers
+    REFERENCING OLD TABLE AS changed_markers
+    FOR EACH STATEMENT EXECUTE FUNCTION markers_refresh_track_geometries();
+
+-- Backfill once after creating the table (takes a while on the full
+-- markers table; safe to re-run):
+--
+--   SELECT refresh_track_geometries(ARRAY(SELECT DISTINCT track_id FROM uploads WHERE track_id IS NOT NULL));
@@ -0,0 +1,190 @@
+// Package agent is a small Qwen + MCP tool-calling agent. It started life as
+// the prototype in test/webite entry.go; the web chat uses it as its Qwen
+// provider and it can be embedded anywhere else an OpenAI-compatible model
+// needs access to the Safecast MCP tools.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// DefaultMaxRounds caps model ↔ tool round-trips per user message.
+const DefaultMaxRounds = 10
+
+// ErrMaxRounds is returned (wrapped) when the model is still calling tools
+// after MaxRounds round-trips. Any text streamed before that point has
+// already been delivered to the caller.
+var ErrMaxRounds = errors.New("exceeded max tool-call rounds")
+
+// EventType identifies what an Event carries.
+type EventType string
+
+const (
+	EventText       EventType = "text"        // assistant text (delta when streaming)
+	EventToolCall   EventType = "tool_call"   // model asked for a tool
+	EventToolResult EventType = "tool_result" // tool finished (Text holds the result)
+)
+
+// Event is emitted by RunStream as the conversation progresses.
+type Event struct {
+	Type EventType
+	Text string
+	Tool string
+	Args string
+	Err  error
+}
+
+// Options configures an Agent. Zero values fall back to sensible defaults.
+type Options struct {
+	// SystemPrompt replaces DefaultSystemPrompt.
+	SystemPrompt string
+	// MaxRounds caps tool-call round-trips (default DefaultMaxRounds).
+	MaxRounds int
+	// ToolResultFilter, if set, rewrites each tool result before it is
+	// appended to the conversation (e.g. to strip prompt injection).
+	ToolResultFilter func(tool, result string) string
+	// Logger receives tool-call logging. Nil disables it.
+	Logger *log.Logger
+}
+
+// Agent ties Qwen + MCP together with a tool-call loop.
+type Agent struct {
+	qwen  *QwenClient
+	mcp   *MCPClient
+	tools []Tool
+	opts  Options
+}
+
+// New creates an agent. Call Init before Run.
+func New(qwen *QwenClient, mcp *MCPClient, opts Options) *Agent {
+	if opts.MaxRounds <= 0 {
+		opts.MaxRounds = DefaultMaxRounds
+	}
+	if opts.SystemPrompt == "" {
+		opts.SystemPrompt = DefaultSystemPrompt
+	}
+	return &Agent{qwen: qwen, mcp: mcp, opts: opts}
+}
+
+func (a *Agent) logf(format string, args ...any) {
+	if a.opts.Logger != nil {
+		a.opts.Logger.Printf(format, args...)
+	}
+}
+
+// Init connects to the MCP server and loads its tools.
+func (a *Agent) Init(ctx context.Context) error {
+	if err := a.mcp.Initialize(ctx); err != nil {
+		return fmt.Errorf("MCP init: %w", err)
+	}
+	mcpTools, err := a.mcp.ListTools(ctx)
+	if err != nil {
+		return fmt.Errorf("list tools: %w", err)
+	}
+	a.tools = MCPToolsToTools(mcpTools)
+	a.logf("Loaded %d tools from MCP server", len(a.tools))
+	return nil
+}
+
+// Tools returns the tools loaded by Init.
+func (a *Agent) Tools() []Tool {
+	return a.tools
+}
+
+// MCPToolsToTools converts MCP tool definitions to OpenAI function tools.
+func MCPToolsToTools(mcpTools []MCPTool) []Tool {
+	tools := make([]Tool, len(mcpTools))
+	for i, t := range mcpTools {
+		tools[i] = Tool{
+			Type: "function",
+			Function: Function{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+			},
+		}
+	}
+	return tools
+}
+
+// Run answers a single user message without streaming.
+func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
+	return a.run(ctx, nil, userMessage, nil)
+}
+
+// RunStream answers userMessage given prior history (user/assistant turns
+// only), streaming text deltas and tool activity to onEvent. It returns the
+// complete final answer.
+func (a *Agent) RunStream(ctx context.Context, history []Message, userMessage string, onEvent func(Event)) (string, error) {
+	if onEvent == nil {
+		onEvent = func(Event) {}
+	}
+	return a.run(ctx, history, userMessage, onEvent)
+}
+
+func (a *Agent) run(ctx context.Context, history []Message, userMessage string, onEvent func(Event)) (string, error) {
+	messages := make([]Message, 0, len(history)+2)
+	messages = append(messages, Message{Role: "system", Content: a.opts.SystemPrompt})
+	messages = append(messages, history...)
+	messages = append(messages, Message{Role: "user", Content: userMessage})
+
+	for round := 0; round < a.opts.MaxRounds; round++ {
+		var resp *Message
+		var err error
+		if onEvent != nil {
+			resp, err = a.qwen.ChatStream(ctx, messages, a.tools, func(delta string) {
+				onEvent(Event{Type: EventText, Text: delta})
+			})
+		} else {
+			resp, err = a.qwen.Chat(ctx, messages, a.tools)
+		}
+		if err != nil {
+			return "", fmt.Errorf("qwen chat round %d: %w", round, err)
+		}
+
+		// No tool calls → we have the final answer
+		if len(resp.ToolCalls) == 0 {
+			return resp.Content, nil
+		}
+
+		// Append assistant message with tool calls
+		messages = append(messages, *resp)
+
+		// Execute each tool call against MCP
+		for _, tc := range resp.ToolCalls {
+			a.logf("Tool call: %s(%s)", tc.Function.Name, tc.Function.Arguments)
+			if onEvent != nil {
+				onEvent(Event{Type: EventToolCall, Tool: tc.Function.Name, Args: tc.Function.Arguments})
+			}
+
+			var args map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+				args = map[string]interface{}{}
+			}
+
+			result, err := a.mcp.CallTool(ctx, tc.Function.Name, args)
+			if err != nil {
+				result = fmt.Sprintf("Error: %v", err)
+			}
+			if a.opts.ToolResultFilter != nil {
+				result = a.opts.ToolResultFilter(tc.Function.Name, result)
+			}
+			a.logf("Tool result: %.200s...", result)
+			if onEvent != nil {
+				onEvent(Event{Type: EventToolResult, Tool: tc.Function.Name, Text: result, Err: err})
+			}
+
+			messages = append(messages, Message{
+				Role:       "tool",
+				ToolCallID: tc.ID,
+				Content:    result,
+			})
+		}
+	}
+
+	return "", fmt.Errorf("%w (%d)", ErrMaxRounds, a.opts.MaxRounds)
+}
@@ -0,0 +1,263 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// MCP types
+// ============================================================
+
+type mcpRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// MCPTool is a tool advertised by the MCP server in tools/list.
+type MCPTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+type mcpToolsResult struct {
+	Tools []MCPTool `json:"tools"`
+}
+
+type mcpCallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type mcpToolResult struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	IsError bool `json:"isError"`
+}
+
+// ============================================================
+// MCP Client (Streamable HTTP transport)
+// ============================================================
+
+// MCPClient is a minimal streamable-HTTP MCP client. It understands both
+// direct JSON replies and SSE-wrapped replies, which covers the Safecast
+// server and most other MCP implementations.
+type MCPClient struct {
+	ServerURL  string
+	HTTPClient *http.Client
+	ClientName string
+
+	mu        sync.Mutex
+	sessionID string
+	reqID     int
+}
+
+// NewMCPClient returns a client for the streamable HTTP endpoint at serverURL
+// (e.g. https://vps-01.safecast.jp/mcp-http).
+func NewMCPClient(serverURL string) *MCPClient {
+	return &MCPClient{
+		ServerURL:  serverURL,
+		ClientName: "safecast-agent",
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// SessionID returns the Mcp-Session-Id assigned by the server, if any.
+func (c *MCPClient) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+func (c *MCPClient) nextID() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reqID++
+	return c.reqID
+}
+
+func (c *MCPClient) post(ctx context.Context, msg mcpRequest) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.ServerURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sid := c.SessionID(); sid != "" {
+		req.Header.Set("Mcp-Session-Id", sid)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		c.mu.Lock()
+		c.sessionID = sid
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// sendRequest sends a JSON-RPC request to the MCP server and returns the result.
+// Handles both direct JSON responses and SSE-wrapped responses.
+func (c *MCPClient) sendRequest(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	rpcReq := mcpRequest{
+		JSONRPC: "2.0",
+		ID:      c.nextID(),
+		Method:  method,
+		Params:  params,
+	}
+
+	resp, err := c.post(ctx, rpcReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("mcp %s: HTTP %d: %s", method, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	var rpcResp *mcpResponse
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		rpcResp, err = readSSEResponse(resp.Body, rpcReq.ID)
+	} else {
+		rpcResp = &mcpResponse{}
+		err = json.NewDecoder(resp.Body).Decode(rpcResp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("mcp %s: %s (code %d)", method, rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+	return rpcResp.Result, nil
+}
+
+func readSSEResponse(r io.Reader, expectedID int) (*mcpResponse, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var dataLines []string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "data:") {
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+
+		// Empty line = end of SSE event
+		if line == "" && len(dataLines) > 0 {
+			data := strings.Join(dataLines, "\n")
+			dataLines = nil
+
+			var rpcResp mcpResponse
+			if err := json.Unmarshal([]byte(data), &rpcResp); err != nil {
+				continue // skip non-JSON events
+			}
+			if rpcResp.ID == expectedID {
+				return &rpcResp, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no matching response found in SSE stream")
+}
+
+// Initialize performs the MCP handshake and sends notifications/initialized.
+func (c *MCPClient) Initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": "2025-03-26",
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]string{
+			"name":    c.ClientName,
+			"version": "1.0.0",
+		},
+	}
+	if _, err := c.sendRequest(ctx, "initialize", params); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+
+	// Send initialized notification (fire and forget)
+	resp, err := c.post(ctx, mcpRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+	if err == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// ListTools returns the tools advertised by the server.
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
+	result, err := c.sendRequest(ctx, "tools/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	var toolsResult mcpToolsResult
+	if err := json.Unmarshal(result, &toolsResult); err != nil {
+		return nil, fmt.Errorf("unmarshal tools: %w", err)
+	}
+	return toolsResult.Tools, nil
+}
+
+// CallTool invokes a tool and returns its text content joined by newlines.
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	params := mcpCallToolParams{
+		Name:      name,
+		Arguments: args,
+	}
+	result, err := c.sendRequest(ctx, "tools/call", params)
+	if err != nil {
+		return "", err
+	}
+	var toolResult mcpToolResult
+	if err := json.Unmarshal(result, &toolResult); err != nil {
+		return "", fmt.Errorf("unmarshal tool result: %w", err)
+	}
+
+	var texts []string
+	for _, c := range toolResult.Content {
+		if c.Type == "text" {
+			texts = append(texts, c.Text)
+		}
+	}
+	text := strings.Join(texts, "\n")
+	if toolResult.IsError {
+		return text, fmt.Errorf("tool %s: %s", name, text)
+	}
+	return text, nil
+}
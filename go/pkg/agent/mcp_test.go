@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadSSEResponse(t *testing.T) {
+	stream := strings.Join([]string{
+		"event: message",
+		`data: {"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`,
+		"",
+		"data: keep-alive",
+		"",
+		`data: {"jsonrpc":"2.0","id":6,"result":{"stale":true}}`,
+		"",
+		"event: message",
+		`data: {"jsonrpc":"2.0","id":7,`,
+		`data: "result":{"ok":true}}`,
+		"",
+	}, "\n") + "\n"
+
+	resp, err := readSSEResponse(strings.NewReader(stream), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 7 || string(resp.Result) != `{"ok":true}` {
+		t.Errorf("got id=%d result=%s", resp.ID, resp.Result)
+	}
+
+	if _, err := readSSEResponse(strings.NewReader(stream), 8); err == nil {
+		t.Error("expected an error when no event matches the id")
+	}
+}
+
+func TestMCPClientSessionAndSSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req mcpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		switch req.Method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "sess-1")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":{}}`, req.ID)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/call":
+			if sid := r.Header.Get("Mcp-Session-Id"); sid != "sess-1" {
+				t.Errorf("session id = %q", sid)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":%d,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"42 CPM\"}]}}\n\n", req.ID)
+		default:
+			t.Errorf("unexpected method %q", req.Method)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewMCPClient(srv.URL)
+	ctx := context.Background()
+	if err := c.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.SessionID() != "sess-1" {
+		t.Fatalf("session id = %q", c.SessionID())
+	}
+	got, err := c.CallTool(ctx, "query_radiation", map[string]interface{}{"lat": 37.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "42 CPM" {
+		t.Errorf("CallTool = %q", got)
+	}
+}
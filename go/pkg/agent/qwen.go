@@ -0,0 +1,275 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Qwen API types (OpenAI-compatible)
+// ============================================================
+
+type chatRequest struct {
+	Model      string    `json:"model"`
+	Messages   []Message `json:"messages"`
+	Tools      []Tool    `json:"tools,omitempty"`
+	ToolChoice string    `json:"tool_choice,omitempty"`
+	Stream     bool      `json:"stream,omitempty"`
+}
+
+// Message is a chat message in OpenAI-compatible format.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool is a function tool offered to the model.
+type Tool struct {
+	Type     string   `json:"type"`
+	Function Function `json:"function"`
+}
+
+// Function describes a callable function and its JSON schema.
+type Function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	Index    int          `json:"index,omitempty"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall carries the function name and its JSON-encoded arguments.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+}
+
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string     `json:"content"`
+			ToolCalls []ToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// ============================================================
+// Qwen Client
+// ============================================================
+
+// DefaultQwenBaseURL is DashScope's OpenAI-compatible endpoint. Self-hosted
+// Qwen works too: Ollama is http://localhost:11434/v1, vLLM
+// http://localhost:8000/v1.
+const DefaultQwenBaseURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
+
+// QwenClient talks to an OpenAI-compatible chat completions endpoint.
+type QwenClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a failed request is retried on network
+	// errors, 429 and 5xx. Backoff doubles from RetryBackoff on each attempt.
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// NewQwenClient returns a client for DashScope with two retries.
+func NewQwenClient(apiKey, model string) *QwenClient {
+	return &QwenClient{
+		APIKey:  apiKey,
+		BaseURL: DefaultQwenBaseURL,
+		Model:   model,
+		HTTPClient: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+		MaxRetries:   2,
+		RetryBackoff: 500 * time.Millisecond,
+	}
+}
+
+// apiError is a non-2xx response. Retryable reports whether the status code
+// is worth another attempt.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("qwen API error %d: %s", e.Status, e.Body)
+}
+
+func (e *apiError) Retryable() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// do POSTs the request, retrying transient failures. The caller owns the
+// returned body.
+func (q *QwenClient) do(ctx context.Context, chatReq chatRequest) (*http.Response, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := q.RetryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= q.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.BaseURL+"/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+q.APIKey)
+
+		resp, err := q.HTTPClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		apiErr := &apiError{Status: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+		if !apiErr.Retryable() {
+			return nil, apiErr
+		}
+		lastErr = apiErr
+	}
+	return nil, fmt.Errorf("after %d attempts: %w", q.MaxRetries+1, lastErr)
+}
+
+func (q *QwenClient) newRequest(messages []Message, tools []Tool, stream bool) chatRequest {
+	chatReq := chatRequest{
+		Model:    q.Model,
+		Messages: messages,
+		Tools:    tools,
+		Stream:   stream,
+	}
+	if len(tools) > 0 {
+		chatReq.ToolChoice = "auto"
+	}
+	return chatReq
+}
+
+// Chat sends one non-streaming completion request.
+func (q *QwenClient) Chat(ctx context.Context, messages []Message, tools []Tool) (*Message, error) {
+	resp, err := q.do(ctx, q.newRequest(messages, tools, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cr chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return nil, err
+	}
+	if len(cr.Choices) == 0 {
+		return nil, errors.New("no choices returned")
+	}
+	return &cr.Choices[0].Message, nil
+}
+
+// ChatStream sends a streaming completion request. Text deltas are passed to
+// onText as they arrive; tool call fragments are reassembled and returned on
+// the final message.
+func (q *QwenClient) ChatStream(ctx context.Context, messages []Message, tools []Tool, onText func(string)) (*Message, error) {
+	resp, err := q.do(ctx, q.newRequest(messages, tools, true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	msg := &Message{Role: "assistant"}
+	var content strings.Builder
+	calls := map[int]*ToolCall{}
+	var order []int
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var sc chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &sc); err != nil {
+			continue
+		}
+		for _, ch := range sc.Choices {
+			if ch.Delta.Content != "" {
+				content.WriteString(ch.Delta.Content)
+				if onText != nil {
+					onText(ch.Delta.Content)
+				}
+			}
+			for _, tc := range ch.Delta.ToolCalls {
+				acc, ok := calls[tc.Index]
+				if !ok {
+					acc = &ToolCall{Index: tc.Index, Type: "function"}
+					calls[tc.Index] = acc
+					order = append(order, tc.Index)
+				}
+				if tc.ID != "" {
+					acc.ID = tc.ID
+				}
+				if tc.Function.Name != "" {
+					acc.Function.Name += tc.Function.Name
+				}
+				acc.Function.Arguments += tc.Function.Arguments
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+
+	msg.Content = content.String()
+	for _, idx := range order {
+		msg.ToolCalls = append(msg.ToolCalls, *calls[idx])
+	}
+	return msg, nil
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestQwen(url string) *QwenClient {
+	qc := NewQwenClient("test-key", "qwen-test")
+	qc.BaseURL = url
+	qc.RetryBackoff = time.Millisecond
+	return qc
+}
+
+func TestChatStreamReassemblesToolCalls(t *testing.T) {
+	// Two parallel tool calls whose names and arguments arrive split across
+	// deltas, interleaved with a text delta.
+	events := []string{
+		`{"choices":[{"delta":{"content":"Let me "}}]}`,
+		`{"choices":[{"delta":{"content":"check."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"query_","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"radiation","arguments":"{\"lat\":37.4,"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"radiation_info","arguments":"{}"}}]}}]}`,
+		`not json`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"lon\":141.0}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`[DONE]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	msg, err := newTestQwen(srv.URL).ChatStream(context.Background(), nil, nil, func(s string) {
+		deltas = append(deltas, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Content != "Let me check." || strings.Join(deltas, "|") != "Let me |check." {
+		t.Errorf("content = %q, deltas = %q", msg.Content, deltas)
+	}
+	if len(msg.ToolCalls) != 2 {
+		t.Fatalf("got %d tool calls, want 2: %+v", len(msg.ToolCalls), msg.ToolCalls)
+	}
+	a, b := msg.ToolCalls[0], msg.ToolCalls[1]
+	if a.ID != "call_a" || a.Function.Name != "query_radiation" || a.Function.Arguments != `{"lat":37.4,"lon":141.0}` {
+		t.Errorf("first call = %+v", a)
+	}
+	if b.ID != "call_b" || b.Function.Name != "radiation_info" || b.Function.Arguments != "{}" {
+		t.Errorf("second call = %+v", b)
+	}
+}
+
+func TestChatRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	msg, err := newTestQwen(srv.URL).Chat(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "ok" || calls.Load() != 2 {
+		t.Errorf("content = %q after %d calls", msg.Content, calls.Load())
+	}
+}
+
+func TestChatDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := newTestQwen(srv.URL).Chat(context.Background(), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("401 was retried: %d calls", calls.Load())
+	}
+}
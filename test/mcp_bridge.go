@@ -1,21 +1,27 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // MCP Protocol Types
 type MCPRequest struct {
 	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
+	ID      interface{} `json:"id,omitempty"` // nil for notifications
 	Method  string      `json:"method"`
 	Params  interface{} `json:"params,omitempty"`
 }
@@ -68,27 +74,76 @@ type MCPTool struct {
 
 // MCPBridge handles forwarding requests to the upstream MCP server
 type MCPBridge struct {
-	upstreamURL string
-	sessions    sync.Map // Store session info: map[sessionID]map[string]interface{}
-	httpClient  *http.Client
+	upstreamURL  string
+	sessions     sync.Map // Store session info: map[sessionID]map[string]interface{}
+	streams      sync.Map // map[sessionID]*sessionStream
+	httpClient   *http.Client
+	streamClient *http.Client // no timeout: used for long-lived GET streams
+	cache        *responseCache
+	auth         bridgeAuth
+	idleTimeout  time.Duration // 0 keeps sessions until DELETE
 }
 
+// sessionStream holds the server-initiated messages (notifications, progress,
+// server requests) received from upstream for one downstream session, plus
+// the cancel func of the keep-alive GET stream feeding it.
+type sessionStream struct {
+	events     chan json.RawMessage
+	cancel     context.CancelFunc
+	lastActive atomic.Int64 // unix nanos of the last downstream request
+}
+
+func (st *sessionStream) touch() {
+	st.lastActive.Store(time.Now().UnixNano())
+}
+
+func (st *sessionStream) idleFor() time.Duration {
+	return time.Since(time.Unix(0, st.lastActive.Load()))
+}
+
+// defaultSessionIdleTimeout is how long a session may go without any
+// downstream traffic before its upstream GET stream is closed. Clients that
+// vanish without sending DELETE would otherwise hold a stream forever.
+const defaultSessionIdleTimeout = 10 * time.Minute
+
+// sessionEventBuffer bounds how many undelivered notifications we hold per
+// session before dropping the oldest-arriving ones.
+const sessionEventBuffer = 64
+
 func NewMCPBridge(upstreamURL string) *MCPBridge {
 	return &MCPBridge{
 		upstreamURL: upstreamURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		streamClient: &http.Client{},
+		cache:        newResponseCache(defaultListTTL, defaultCallTTL, nil),
+		idleTimeout:  defaultSessionIdleTimeout,
 	}
 }
 
 // ServeHTTP implements the MCP server protocol
 func (mb *MCPBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received request: %s %s", r.Method, r.URL.Path)
-	
-	if r.Method != http.MethodPost {
+
+	if !mb.auth.allowed(r) {
+		log.Printf("Rejected request from %s: missing or invalid bridge secret", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="safecast-mcp-bridge"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodGet:
+		mb.serveEventStream(w, r)
+		return
+	case http.MethodDelete:
+		mb.closeSession(w, r)
+		return
+	default:
 		log.Printf("Method not allowed: %s", r.Method)
-		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		http.Error(w, "POST, GET or DELETE only", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -119,9 +174,45 @@ func (mb *MCPBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else {
 		log.Printf("Using existing session ID: %s", sessionID)
 	}
+	if v, ok := mb.streams.Load(sessionID); ok {
+		v.(*sessionStream).touch()
+	}
+
+	// A message without a method is the client answering a server request
+	// (sampling, roots/list, elicitation) it received on the GET stream.
+	// Relay it upstream byte for byte; it gets no JSON-RPC reply of its own.
+	if req.Method == "" && isClientResponse(body) {
+		mb.forwardClientResponse(sessionID, body, mb.auth.forwarded(r))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
 
 	// Process the request based on method
-	response := mb.handleRequest(sessionID, &req)
+	response, notifications := mb.handleRequest(sessionID, &req, mb.auth.forwarded(r))
+
+	// Notifications from the client (no id) get no JSON-RPC response.
+	if req.ID == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// If the client accepts SSE, deliver any notifications upstream sent
+	// while handling this request in-line, followed by the response.
+	// Otherwise they wait on the session's GET stream.
+	if len(notifications) > 0 && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		for _, n := range notifications {
+			writeSSEEvent(w, n)
+		}
+		data, _ := json.Marshal(response)
+		writeSSEEvent(w, data)
+		log.Printf("Sent response with %d notification(s) as SSE", len(notifications))
+		return
+	}
+	for _, n := range notifications {
+		mb.queueEvent(sessionID, n)
+	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -131,9 +222,27 @@ func (mb *MCPBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (mb *MCPBridge) handleRequest(sessionID string, req *MCPRequest) *MCPResponse {
-	// Forward all requests to the upstream server
-	return mb.forwardRequest(sessionID, req)
+func (mb *MCPBridge) handleRequest(sessionID string, req *MCPRequest, fwd http.Header) (*MCPResponse, []json.RawMessage) {
+	key, ttl := mb.cache.keyFor(req, fwd)
+	if key != "" {
+		if result, ok := mb.cache.get(key); ok {
+			log.Printf("Cache hit: %s", key)
+			return &MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result}, nil
+		}
+	}
+
+	// Forward everything else to the upstream server
+	resp, notifications := mb.forwardRequest(sessionID, req, fwd)
+
+	if resp != nil && resp.Error == nil {
+		if req.Method == "tools/list" {
+			mb.cache.learnTools(resp.Result)
+		}
+		if key != "" && !isToolError(resp.Result) {
+			mb.cache.put(key, resp.Result, ttl)
+		}
+	}
+	return resp, notifications
 }
 
 func (mb *MCPBridge) handleInitialize(sessionID string, req *MCPRequest) *MCPResponse {
@@ -191,7 +300,7 @@ func (mb *MCPBridge) handleInitialize(sessionID string, req *MCPRequest) *MCPRes
 	}
 }
 
-func (mb *MCPBridge) handleToolsList(sessionID string, req *MCPRequest) *MCPResponse {
+func (mb *MCPBridge) handleToolsList(sessionID string, req *MCPRequest) (*MCPResponse, []json.RawMessage) {
 	// Forward the tools/list request to the upstream server
 	forwardedReq := MCPRequest{
 		JSONRPC: "2.0",
@@ -200,10 +309,10 @@ func (mb *MCPBridge) handleToolsList(sessionID string, req *MCPRequest) *MCPResp
 		Params:  req.Params,
 	}
 
-	return mb.forwardRequestWithSession(sessionID, &forwardedReq)
+	return mb.forwardRequestWithSession(sessionID, &forwardedReq, nil)
 }
 
-func (mb *MCPBridge) handleToolCall(sessionID string, req *MCPRequest) *MCPResponse {
+func (mb *MCPBridge) handleToolCall(sessionID string, req *MCPRequest) (*MCPResponse, []json.RawMessage) {
 	// Forward the tools/call request to the upstream server
 	forwardedReq := MCPRequest{
 		JSONRPC: "2.0",
@@ -212,14 +321,17 @@ func (mb *MCPBridge) handleToolCall(sessionID string, req *MCPRequest) *MCPRespo
 		Params:  req.Params,
 	}
 
-	return mb.forwardRequestWithSession(sessionID, &forwardedReq)
+	return mb.forwardRequestWithSession(sessionID, &forwardedReq, nil)
 }
 
-func (mb *MCPBridge) forwardRequest(sessionID string, req *MCPRequest) *MCPResponse {
-	return mb.forwardRequestWithSession(sessionID, req)
+func (mb *MCPBridge) forwardRequest(sessionID string, req *MCPRequest, fwd http.Header) (*MCPResponse, []json.RawMessage) {
+	return mb.forwardRequestWithSession(sessionID, req, fwd)
 }
 
-func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest) *MCPResponse {
+// forwardRequestWithSession sends req upstream and returns its response plus
+// any other JSON-RPC messages (notifications, progress) that arrived on the
+// same SSE response stream.
+func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest, fwd http.Header) (*MCPResponse, []json.RawMessage) {
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
 		return &MCPResponse{
@@ -229,7 +341,7 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 				Code:    -32603,
 				Message: "Internal error: unable to marshal request",
 			},
-		}
+		}, nil
 	}
 
 	httpReq, err := http.NewRequest("POST", mb.upstreamURL, bytes.NewReader(reqBytes))
@@ -241,11 +353,13 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 				Code:    -32603,
 				Message: "Internal error: unable to create HTTP request",
 			},
-		}
+		}, nil
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	mb.auth.applyUpstream(httpReq, fwd)
+
 	// Track upstream session ID separately from downstream session ID
 	upstreamSessionKey := "upstream_session_" + sessionID
 	if upstreamSID, ok := mb.sessions.Load(upstreamSessionKey); ok {
@@ -262,7 +376,7 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 					Code:    -32603,
 					Message: "Upstream session not initialized",
 				},
-			}
+			}, nil
 		}
 		// For initialize, don't set session ID in header
 	}
@@ -276,22 +390,10 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 				Code:    -32603,
 				Message: fmt.Sprintf("Upstream error: %v", err),
 			},
-		}
+		}, nil
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return &MCPResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Error: &MCPError{
-				Code:    -32603,
-				Message: "Upstream error: unable to read response",
-			},
-		}
-	}
-
 	// Check if this is an initialize response and store the upstream session ID
 	if req.Method == "initialize" {
 		upstreamSID := resp.Header.Get("Mcp-Session-Id")
@@ -299,7 +401,39 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 			// Store the upstream session ID associated with the downstream session
 			upstreamSessionKey := "upstream_session_" + sessionID
 			mb.sessions.Store(upstreamSessionKey, upstreamSID)
+			// Remember the client's forwarded headers so requests the bridge
+			// makes on its own (keep-alive GET, idle DELETE) carry them too.
+			if fwd != nil {
+				mb.sessions.Store("upstream_headers_"+sessionID, fwd)
+			}
+			mb.startKeepAlive(sessionID, upstreamSID)
+		}
+	}
+
+	// Notifications are acknowledged with 202 and no body.
+	if req.ID == nil {
+		if resp.StatusCode >= 300 {
+			log.Printf("Upstream rejected notification %s: HTTP %d", req.Method, resp.StatusCode)
 		}
+		return nil, nil
+	}
+
+	var respBody []byte
+	var notifications []json.RawMessage
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		respBody, notifications, err = readSSEResponse(resp.Body, req.ID)
+	} else {
+		respBody, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		return &MCPResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &MCPError{
+				Code:    -32603,
+				Message: "Upstream error: unable to read response: " + err.Error(),
+			},
+		}, notifications
 	}
 
 	// Debug: Print the raw response from upstream
@@ -318,7 +452,7 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 				Code:    -32603,
 				Message: "Upstream error: invalid response format: " + err.Error(),
 			},
-		}
+		}, notifications
 	}
 
 	// Extract result or error from the parsed response
@@ -343,7 +477,597 @@ func (mb *MCPBridge) forwardRequestWithSession(sessionID string, req *MCPRequest
 		ID:      req.ID, // Use original request ID
 		Result:  result,
 		Error:   responseError,
+	}, notifications
+}
+
+// isClientResponse reports whether body is a JSON-RPC response (it has an id
+// and a result or error) rather than a request or notification.
+func isClientResponse(body []byte) bool {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &msg) != nil {
+		return false
+	}
+	return len(msg.ID) > 0 && (msg.Result != nil || msg.Error != nil)
+}
+
+// forwardClientResponse posts a client's JSON-RPC response to upstream
+// unchanged, under the session's upstream ID.
+func (mb *MCPBridge) forwardClientResponse(sessionID string, body []byte, fwd http.Header) {
+	upstreamSID, ok := mb.sessions.Load("upstream_session_" + sessionID)
+	if !ok {
+		log.Printf("Dropping client response for session %s: upstream session not initialized", sessionID)
+		return
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, mb.upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating upstream request: %v", err)
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	httpReq.Header.Set("Mcp-Session-Id", upstreamSID.(string))
+	mb.auth.applyUpstream(httpReq, fwd)
+
+	resp, err := mb.httpClient.Do(httpReq)
+	if err != nil {
+		log.Printf("Error forwarding client response upstream: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Upstream rejected client response: HTTP %d", resp.StatusCode)
+	}
+}
+
+// ============================================================
+// SSE handling (streamable HTTP upstream)
+// ============================================================
+
+// readSSE calls fn with the data payload of each event in an SSE stream until
+// the stream ends or fn returns false.
+func readSSE(r io.Reader, fn func(data []byte) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var dataLines []string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "data:") {
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+
+		// Empty line = end of SSE event
+		if line == "" && len(dataLines) > 0 {
+			data := strings.Join(dataLines, "\n")
+			dataLines = nil
+			if !fn([]byte(data)) {
+				return nil
+			}
+		}
 	}
+	return scanner.Err()
+}
+
+// readSSEResponse reads an upstream SSE response until the JSON-RPC message
+// answering id arrives. Everything else on the stream is returned as
+// notifications so it can be relayed to the client.
+func readSSEResponse(r io.Reader, id interface{}) ([]byte, []json.RawMessage, error) {
+	wantID := fmt.Sprint(id)
+	var response []byte
+	var notifications []json.RawMessage
+
+	err := readSSE(r, func(data []byte) bool {
+		var msg struct {
+			ID     interface{}     `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return true // skip non-JSON events
+		}
+		if msg.Method == "" && msg.ID != nil && fmt.Sprint(msg.ID) == wantID {
+			response = data
+			return false
+		}
+		notifications = append(notifications, json.RawMessage(data))
+		return true
+	})
+	if err != nil {
+		return nil, notifications, err
+	}
+	if response == nil {
+		return nil, notifications, fmt.Errorf("no matching response found in SSE stream")
+	}
+	return response, notifications, nil
+}
+
+func writeSSEEvent(w http.ResponseWriter, data []byte) {
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// queueEvent hands a server-initiated message to the session's GET stream.
+func (mb *MCPBridge) queueEvent(sessionID string, msg json.RawMessage) {
+	v, ok := mb.streams.Load(sessionID)
+	if !ok {
+		log.Printf("Dropping notification for unknown session %s", sessionID)
+		return
+	}
+	select {
+	case v.(*sessionStream).events <- msg:
+	default:
+		log.Printf("Notification buffer full for session %s, dropping message", sessionID)
+	}
+}
+
+// startKeepAlive opens the upstream GET stream for a session. Holding it open
+// keeps the upstream session alive between tool calls and is how the server
+// pushes notifications that are not tied to a request. The stream is
+// re-opened with backoff until the session is closed, upstream refuses it
+// with a 4xx, or the downstream client has been idle for idleTimeout.
+func (mb *MCPBridge) startKeepAlive(sessionID, upstreamSID string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	st := &sessionStream{events: make(chan json.RawMessage, sessionEventBuffer), cancel: cancel}
+	st.touch()
+	if old, loaded := mb.streams.Swap(sessionID, st); loaded {
+		old.(*sessionStream).cancel()
+	}
+
+	if mb.idleTimeout > 0 {
+		go mb.expireIdle(ctx, sessionID, st)
+	}
+
+	fwd := mb.sessionHeaders(sessionID)
+	go func() {
+		backoff := time.Second
+		for {
+			started := time.Now()
+			status, err := mb.followUpstreamStream(ctx, upstreamSID, fwd, st)
+			if ctx.Err() != nil {
+				return
+			}
+			switch {
+			case status == http.StatusMethodNotAllowed:
+				log.Printf("Upstream does not offer a GET stream; keep-alive disabled for session %s", sessionID)
+				return
+			case status == http.StatusNotFound:
+				log.Printf("Upstream session for %s expired", sessionID)
+				return
+			case status >= 400 && status < 500:
+				// Auth failures, bad requests and the like will not fix
+				// themselves on retry.
+				log.Printf("Upstream refused GET stream for session %s (status %d); keep-alive disabled", sessionID, status)
+				return
+			}
+			if time.Since(started) > time.Minute {
+				backoff = time.Second
+			}
+			log.Printf("Upstream GET stream for session %s ended (status %d, err %v); reconnecting in %s", sessionID, status, err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// expireIdle ends the session once the downstream client has sent nothing
+// for idleTimeout.
+func (mb *MCPBridge) expireIdle(ctx context.Context, sessionID string, st *sessionStream) {
+	interval := mb.idleTimeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if idle := st.idleFor(); idle >= mb.idleTimeout {
+				log.Printf("Session %s idle for %s; closing", sessionID, idle.Round(time.Second))
+				mb.endSession(context.Background(), sessionID, mb.sessionHeaders(sessionID))
+				return
+			}
+		}
+	}
+}
+
+// sessionHeaders returns the forwarded headers captured when the session was
+// initialized, or nil.
+func (mb *MCPBridge) sessionHeaders(sessionID string) http.Header {
+	if v, ok := mb.sessions.Load("upstream_headers_" + sessionID); ok {
+		return v.(http.Header)
+	}
+	return nil
+}
+
+func (mb *MCPBridge) followUpstreamStream(ctx context.Context, upstreamSID string, fwd http.Header, st *sessionStream) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, mb.upstreamURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Mcp-Session-Id", upstreamSID)
+	mb.auth.applyUpstream(httpReq, fwd)
+
+	resp, err := mb.streamClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	err = readSSE(resp.Body, func(data []byte) bool {
+		select {
+		case st.events <- json.RawMessage(append([]byte(nil), data...)):
+		default:
+			log.Printf("Notification buffer full, dropping upstream message")
+		}
+		return true
+	})
+	return resp.StatusCode, err
+}
+
+// serveEventStream relays upstream notifications to a client holding a GET
+// stream open, with periodic comments so idle proxies don't cut it.
+func (mb *MCPBridge) serveEventStream(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	v, ok := mb.streams.Load(sessionID)
+	if sessionID == "" || !ok {
+		http.Error(w, "unknown or missing Mcp-Session-Id", http.StatusNotFound)
+		return
+	}
+	st := v.(*sessionStream)
+	st.touch()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	ping := time.NewTicker(25 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-st.events:
+			writeSSEEvent(w, msg)
+		case <-ping.C:
+			// A client holding the stream open is still active.
+			st.touch()
+			fmt.Fprint(w, ": keep-alive\n\n")
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+}
+
+// closeSession handles DELETE: tears down the keep-alive stream and ends the
+// upstream session.
+func (mb *MCPBridge) closeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	if sessionID == "" {
+		http.Error(w, "missing Mcp-Session-Id", http.StatusBadRequest)
+		return
+	}
+	fwd := mb.auth.forwarded(r)
+	if fwd == nil {
+		fwd = mb.sessionHeaders(sessionID)
+	}
+	mb.endSession(r.Context(), sessionID, fwd)
+	log.Printf("Closed session %s", sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// endSession stops the keep-alive stream, sends DELETE upstream and forgets
+// the session.
+func (mb *MCPBridge) endSession(ctx context.Context, sessionID string, fwd http.Header) {
+	if v, ok := mb.streams.LoadAndDelete(sessionID); ok {
+		v.(*sessionStream).cancel()
+	}
+	if upstreamSID, ok := mb.sessions.LoadAndDelete("upstream_session_" + sessionID); ok {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, mb.upstreamURL, nil)
+		if err == nil {
+			httpReq.Header.Set("Mcp-Session-Id", upstreamSID.(string))
+			mb.auth.applyUpstream(httpReq, fwd)
+			if resp, err := mb.httpClient.Do(httpReq); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	mb.sessions.Delete("upstream_headers_" + sessionID)
+	mb.sessions.Delete(sessionID)
+}
+
+// ============================================================
+// Response cache
+// ============================================================
+
+const (
+	defaultListTTL  = 5 * time.Minute
+	defaultCallTTL  = 30 * time.Second
+	maxCacheEntries = 1000
+)
+
+type cacheEntry struct {
+	result  json.RawMessage
+	expires time.Time
+}
+
+// responseCache holds upstream results for tools/list and for tools/call on
+// tools the server marks readOnlyHint. Agents tend to list tools on every
+// turn and repeat identical lookups while they reason, so even a short TTL
+// takes a lot of traffic off the upstream. Keys are the method plus the
+// params re-encoded by encoding/json (map keys sorted), so argument order
+// does not matter, plus any forwarded client headers: upstream may answer
+// differently per tenant or user, and one client's result must never be
+// served to another.
+type responseCache struct {
+	listTTL     time.Duration
+	callTTL     time.Duration
+	passthrough map[string]bool // "tools/list", "tools/call" or "tools/call:<tool>"
+
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	readOnly map[string]bool // learned from tools/list annotations
+}
+
+func newResponseCache(listTTL, callTTL time.Duration, passthrough []string) *responseCache {
+	c := &responseCache{
+		listTTL:     listTTL,
+		callTTL:     callTTL,
+		passthrough: map[string]bool{},
+		entries:     map[string]cacheEntry{},
+		readOnly:    map[string]bool{},
+	}
+	for _, p := range passthrough {
+		if p = strings.TrimSpace(p); p != "" {
+			c.passthrough[p] = true
+		}
+	}
+	return c
+}
+
+// keyFor returns the cache key and TTL for req, or "" if it must go upstream.
+func (c *responseCache) keyFor(req *MCPRequest, fwd http.Header) (string, time.Duration) {
+	if req.ID == nil || c.passthrough[req.Method] {
+		return "", 0
+	}
+
+	var ttl time.Duration
+	switch req.Method {
+	case "tools/list":
+		ttl = c.listTTL
+	case "tools/call":
+		var p struct {
+			Name string `json:"name"`
+		}
+		raw, _ := json.Marshal(req.Params)
+		if json.Unmarshal(raw, &p) != nil || p.Name == "" || c.passthrough["tools/call:"+p.Name] {
+			return "", 0
+		}
+		c.mu.Lock()
+		ro := c.readOnly[p.Name]
+		c.mu.Unlock()
+		if !ro {
+			return "", 0
+		}
+		ttl = c.callTTL
+	default:
+		return "", 0
+	}
+	if ttl <= 0 {
+		return "", 0
+	}
+
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return "", 0
+	}
+	key := req.Method + " " + string(params)
+	if len(fwd) > 0 {
+		names := make([]string, 0, len(fwd))
+		for name := range fwd {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			vals, _ := json.Marshal(fwd[name])
+			key += " " + name + "=" + string(vals)
+		}
+	}
+	return key, ttl
+}
+
+func (c *responseCache) get(key string) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.result, true
+}
+
+func (c *responseCache) put(key string, result json.RawMessage, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCacheEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{result: result, expires: time.Now().Add(ttl)}
+}
+
+// learnTools records which tools are read-only from a tools/list result.
+func (c *responseCache) learnTools(result json.RawMessage) {
+	var list struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			Annotations struct {
+				ReadOnlyHint *bool `json:"readOnlyHint"`
+			} `json:"annotations"`
+		} `json:"tools"`
+	}
+	if json.Unmarshal(result, &list) != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range list.Tools {
+		c.readOnly[t.Name] = t.Annotations.ReadOnlyHint != nil && *t.Annotations.ReadOnlyHint
+	}
+}
+
+// isToolError reports whether a tools/call result has isError set; those are
+// never cached.
+func isToolError(result json.RawMessage) bool {
+	var r struct {
+		IsError bool `json:"isError"`
+	}
+	return json.Unmarshal(result, &r) == nil && r.IsError
+}
+
+// durationEnv parses a duration env var ("30s", "5m"; "0" disables).
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	if v == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// ============================================================
+// Authentication and header forwarding
+// ============================================================
+
+// bridgeAuth controls what the bridge demands from clients and what it adds
+// on the way upstream. With a shared secret set the bridge can sit on a
+// corporate network without turning into an open relay to the Safecast
+// server.
+type bridgeAuth struct {
+	// sharedSecret, if set, must be presented by clients as
+	// "Authorization: Bearer <secret>" or "X-Bridge-Secret: <secret>".
+	sharedSecret string
+
+	// upstreamToken is sent upstream as "Authorization: Bearer <token>".
+	upstreamToken string
+	// upstreamAPIKey is sent upstream in upstreamAPIKeyHeader.
+	upstreamAPIKey       string
+	upstreamAPIKeyHeader string
+
+	// forwardHeaders are client headers copied to upstream requests
+	// (canonicalised names). Authorization and X-Bridge-Secret are never
+	// forwarded.
+	forwardHeaders []string
+}
+
+func (a bridgeAuth) allowed(r *http.Request) bool {
+	if a.sharedSecret == "" {
+		return true
+	}
+	presented := r.Header.Get("X-Bridge-Secret")
+	if presented == "" {
+		if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+			presented = strings.TrimPrefix(h, "Bearer ")
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(a.sharedSecret)) == 1
+}
+
+// forwarded extracts the configured client headers from r.
+func (a bridgeAuth) forwarded(r *http.Request) http.Header {
+	if len(a.forwardHeaders) == 0 {
+		return nil
+	}
+	out := http.Header{}
+	for _, name := range a.forwardHeaders {
+		if vals := r.Header.Values(name); len(vals) > 0 {
+			out[name] = vals
+		}
+	}
+	return out
+}
+
+// applyUpstream adds forwarded client headers and upstream credentials to an
+// outgoing request. Credentials are set last so a client cannot override them.
+func (a bridgeAuth) applyUpstream(req *http.Request, fwd http.Header) {
+	for name, vals := range fwd {
+		for _, v := range vals {
+			req.Header.Add(name, v)
+		}
+	}
+	if a.upstreamToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.upstreamToken)
+	}
+	if a.upstreamAPIKey != "" {
+		req.Header.Set(a.upstreamAPIKeyHeader, a.upstreamAPIKey)
+	}
+}
+
+func loadBridgeAuth() bridgeAuth {
+	a := bridgeAuth{
+		sharedSecret:         os.Getenv("BRIDGE_SHARED_SECRET"),
+		upstreamToken:        os.Getenv("BRIDGE_UPSTREAM_TOKEN"),
+		upstreamAPIKey:       os.Getenv("BRIDGE_UPSTREAM_API_KEY"),
+		upstreamAPIKeyHeader: os.Getenv("BRIDGE_UPSTREAM_API_KEY_HEADER"),
+	}
+	if a.upstreamAPIKeyHeader == "" {
+		a.upstreamAPIKeyHeader = "X-API-Key"
+	}
+	for _, h := range strings.Split(os.Getenv("BRIDGE_FORWARD_HEADERS"), ",") {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		switch h {
+		case "", "Authorization", "X-Bridge-Secret", "Mcp-Session-Id", "Content-Type", "Content-Length", "Accept":
+			continue
+		}
+		a.forwardHeaders = append(a.forwardHeaders, h)
+	}
+	return a
 }
 
 func (mb *MCPBridge) sendError(w http.ResponseWriter, id interface{}, code int, message string) {
@@ -377,6 +1101,24 @@ func main() {
 
 	bridge := NewMCPBridge(upstreamURL)
 
+	// BRIDGE_LIST_CACHE_TTL / BRIDGE_CALL_CACHE_TTL set the cache lifetimes
+	// ("0" disables). BRIDGE_PASSTHROUGH lists methods or tools that always go
+	// upstream, e.g. "tools/call:sensor_current,tools/call:sensor_history".
+	var passthrough []string
+	if v := os.Getenv("BRIDGE_PASSTHROUGH"); v != "" {
+		passthrough = strings.Split(v, ",")
+	}
+	bridge.auth = loadBridgeAuth()
+	if bridge.auth.sharedSecret == "" {
+		log.Printf("WARNING: BRIDGE_SHARED_SECRET not set; any client that can reach %s may use the bridge", listenAddr)
+	}
+	bridge.idleTimeout = durationEnv("BRIDGE_SESSION_IDLE_TIMEOUT", defaultSessionIdleTimeout)
+	bridge.cache = newResponseCache(
+		durationEnv("BRIDGE_LIST_CACHE_TTL", defaultListTTL),
+		durationEnv("BRIDGE_CALL_CACHE_TTL", defaultCallTTL),
+		passthrough,
+	)
+
 	log.Printf("MCP Bridge starting on %s, forwarding to %s", listenAddr, upstreamURL)
 	log.Fatal(http.ListenAndServe(listenAddr, bridge))
 }
\ No newline at end of file
@@ -1,3 +1,5 @@
+// Prototype Qwen + MCP agent. The maintained version lives in go/pkg/agent
+// (streaming, retries, configurable rounds) and backs web-chat's qwen provider.
 package main
 
 import (